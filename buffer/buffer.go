@@ -4,6 +4,7 @@ import (
 	"errors"
 	"io"
 	"math"
+	"sort"
 	"sync"
 
 	"github.com/itchyny/bed/mathutil"
@@ -44,21 +45,24 @@ func (b *Buffer) Read(p []byte) (int, error) {
 	return b.read(p)
 }
 
-func (b *Buffer) read(p []byte) (i int, err error) {
-	for _, rr := range b.rrs {
-		if b.index < rr.min {
+func (b *Buffer) read(p []byte) (int, error) {
+	n, err := b.readAt(p, b.index)
+	b.index += int64(n)
+	return n, err
+}
+
+func (b *Buffer) readAt(p []byte, offset int64) (i int, err error) {
+	for _, rr := range b.rrs[b.find(offset):] {
+		if offset < rr.min || i > 0 && i == len(p) {
 			break
 		}
-		if b.index >= rr.max {
-			continue
-		}
-		m := int(mathutil.MinInt64(int64(len(p)-i), rr.max-b.index))
+		m := int(mathutil.MinInt64(int64(len(p)-i), rr.max-offset))
 		var k int
-		if k, err = rr.r.ReadAt(p[i:i+m], b.index+rr.diff); err != nil && k == 0 {
+		if k, err = rr.r.ReadAt(p[i:i+m], offset+rr.diff); err != nil && k == 0 {
 			return
 		}
 		err = nil
-		b.index += int64(m)
+		offset += int64(m)
 		i += k
 	}
 	return
@@ -111,14 +115,15 @@ func (b *Buffer) len() (int64, error) {
 	return l - rr.diff, nil
 }
 
-// ReadAt reads bytes at the specific offset.
+// ReadAt reads bytes at the specific offset, without changing the offset
+// of the buffer.
 func (b *Buffer) ReadAt(p []byte, offset int64) (int, error) {
 	b.mu.Lock()
 	defer b.mu.Unlock()
-	if _, err := b.seek(offset, io.SeekStart); err != nil {
-		return 0, err
+	if offset < 0 {
+		return 0, errors.New("buffer.Buffer.ReadAt: negative position")
 	}
-	return b.read(p)
+	return b.readAt(p, offset)
 }
 
 // EditedIndices returns the indices of edited regions.
@@ -127,8 +132,7 @@ func (b *Buffer) EditedIndices() []int64 {
 	defer b.mu.Unlock()
 	eis := make([]int64, 0, len(b.rrs))
 	for _, rr := range b.rrs {
-		switch rr.r.(type) {
-		case *bytesReader:
+		if edited(rr.r) {
 			eis = append(eis, rr.min)
 			eis = append(eis, rr.max)
 		}
@@ -136,6 +140,263 @@ func (b *Buffer) EditedIndices() []int64 {
 	return eis
 }
 
+// ChangedRanges returns the ranges of the bytes which are not read from the
+// original reader at the same offset, as pairs of start and end offsets.
+func (b *Buffer) ChangedRanges() ([]int64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	l, err := b.len()
+	if err != nil {
+		return nil, err
+	}
+	var rs []int64
+	for _, rr := range b.rrs {
+		if rr.min >= l {
+			break
+		}
+		if !edited(rr.r) && rr.diff == 0 {
+			continue
+		}
+		max := mathutil.MinInt64(rr.max, l)
+		if n := len(rs); n > 0 && rs[n-1] == rr.min {
+			rs[n-1] = max
+		} else {
+			rs = append(rs, rr.min, max)
+		}
+	}
+	return rs, nil
+}
+
+// DirtyRanges returns the ranges of the bytes whose contents differ from the
+// original reader, as pairs of start and end offsets. Unlike EditedIndices,
+// the bytes which are replaced back to the original bytes are not included.
+func (b *Buffer) DirtyRanges() ([]int64, error) {
+	return b.DirtyRangesIn(0, math.MaxInt64)
+}
+
+// DirtyRangesIn returns the dirty ranges between the offsets.
+func (b *Buffer) DirtyRangesIn(from, to int64) ([]int64, error) {
+	return b.AppendDirtyRangesIn([]int64{}, from, to)
+}
+
+// AppendDirtyRangesIn appends the dirty ranges between the offsets to rs.
+func (b *Buffer) AppendDirtyRangesIn(rs []int64, from, to int64) ([]int64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	err := b.scanDirtyIn(from, to, func(i int64, _, _ byte) {
+		rs = appendRange(rs, i, i+1)
+	}, func(min, max int64) {
+		rs = appendRange(rs, min, max)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rs, nil
+}
+
+// AppendDirtyNibblesIn appends the nibbles of the dirty bytes between the
+// offsets, of which only one nibble differs from the original byte, to ns.
+// The nibble is 2*offset for the high nibble and 2*offset+1 for the low one.
+func (b *Buffer) AppendDirtyNibblesIn(ns []int64, from, to int64) ([]int64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	err := b.scanDirtyIn(from, to, func(i int64, c, orig byte) {
+		if d := c ^ orig; d&0x0f == 0 {
+			ns = append(ns, 2*i)
+		} else if d&0xf0 == 0 {
+			ns = append(ns, 2*i+1)
+		}
+	}, func(int64, int64) {})
+	if err != nil {
+		return nil, err
+	}
+	return ns, nil
+}
+
+// scanDirtyIn calls changed with the edited bytes between the offsets which
+// differ from the original bytes, and unknown with the ranges which cannot be
+// compared with the original reader.
+func (b *Buffer) scanDirtyIn(from, to int64, changed func(int64, byte, byte), unknown func(int64, int64)) error {
+	l, err := b.len()
+	if err != nil {
+		return err
+	}
+	to = mathutil.MinInt64(to, l)
+	for i := b.find(from); i < len(b.rrs); i++ {
+		rr := b.rrs[i]
+		if rr.min >= to {
+			break
+		}
+		if !edited(rr.r) || rr.max <= from {
+			continue
+		}
+		min, max := mathutil.MaxInt64(rr.min, from), mathutil.MinInt64(rr.max, to)
+		br, isBytes := rr.r.(*bytesReader)
+		r, diff, ok := b.originalAround(i)
+		if !isBytes || !ok {
+			unknown(min, max)
+			continue
+		}
+		bs := br.bs[min+rr.diff : max+rr.diff]
+		orig := make([]byte, len(bs))
+		n, err := r.ReadAt(orig, min+diff)
+		if err != nil && err != io.EOF {
+			return err
+		}
+		for j := range bs {
+			if j >= n {
+				unknown(min+int64(j), min+int64(j)+1)
+			} else if bs[j] != orig[j] {
+				changed(min+int64(j), bs[j], orig[j])
+			}
+		}
+	}
+	return nil
+}
+
+// originalAround returns the original reader and the offset difference of the
+// ranges around the edited range at the index. When the differences before
+// and after the range disagree, the bytes are inserted or deleted there and
+// the edited bytes cannot be compared with the original bytes.
+func (b *Buffer) originalAround(i int) (readAtSeeker, int64, bool) {
+	var r readAtSeeker
+	var diff int64 // the beginning of the buffer is not shifted
+	for j := i - 1; j >= 0; j-- {
+		if !edited(b.rrs[j].r) {
+			r, diff = b.rrs[j].r, b.rrs[j].diff
+			break
+		}
+	}
+	for j := i + 1; j < len(b.rrs); j++ {
+		if !edited(b.rrs[j].r) {
+			return b.rrs[j].r, diff, b.rrs[j].diff == diff && (r == nil || r == b.rrs[j].r)
+		}
+	}
+	return r, diff, false
+}
+
+func appendRange(rs []int64, from, to int64) []int64 {
+	if n := len(rs); n > 0 && rs[n-1] == from {
+		rs[n-1] = to
+		return rs
+	}
+	return append(rs, from, to)
+}
+
+// Shifted reports whether some bytes are read from the original reader at
+// different offsets, which happens after inserting or deleting bytes.
+func (b *Buffer) Shifted() (bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	l, err := b.len()
+	if err != nil {
+		return false, err
+	}
+	for _, rr := range b.rrs {
+		if rr.min >= l {
+			break
+		}
+		if !edited(rr.r) && rr.diff != 0 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Segment is a part of the contents of the buffer, which consists of the
+// bytes, or refers to the original reader at the offset. The segment of the
+// inserted reader refers to the Spool at the offset, without the bytes.
+type Segment struct {
+	Offset int64
+	Length int64
+	Bytes  []byte
+	Spool  io.ReaderAt
+}
+
+// Segments returns the contents of the buffer as the segments.
+func (b *Buffer) Segments() ([]Segment, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	l, err := b.len()
+	if err != nil {
+		return nil, err
+	}
+	var segments []Segment
+	for _, rr := range b.rrs {
+		if rr.min >= l {
+			break
+		}
+		max := mathutil.MinInt64(rr.max, l)
+		switch r := rr.r.(type) {
+		case *bytesReader:
+			bs := append([]byte(nil), r.bs[rr.min+rr.diff:max+rr.diff]...)
+			segments = append(segments, Segment{Length: max - rr.min, Bytes: bs})
+		case *spoolReader:
+			segments = append(segments, Segment{Offset: rr.min + rr.diff, Length: max - rr.min, Spool: r.readAtSeeker})
+		default:
+			segments = append(segments, Segment{Offset: rr.min + rr.diff, Length: max - rr.min})
+		}
+	}
+	return segments, nil
+}
+
+// Restore creates a new buffer from the original reader and the segments.
+func Restore(r readAtSeeker, segments []Segment) (*Buffer, error) {
+	l, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	rrs := make([]readerRange, 0, len(segments)+1)
+	var offset int64
+	for _, s := range segments {
+		if s.Bytes != nil {
+			n := int64(len(s.Bytes))
+			rrs = append(rrs, readerRange{newBytesReader(append([]byte(nil), s.Bytes...)), offset, offset + n, -offset})
+			offset += n
+		} else if s.Spool != nil {
+			r, ok := s.Spool.(readAtSeeker)
+			if !ok || s.Offset < 0 || s.Length <= 0 {
+				return nil, errors.New("invalid segment")
+			}
+			rrs = append(rrs, readerRange{&spoolReader{r}, offset, offset + s.Length, s.Offset - offset})
+			offset += s.Length
+		} else {
+			if s.Offset < 0 || s.Length <= 0 || s.Offset+s.Length > l {
+				return nil, errors.New("invalid segment")
+			}
+			rrs = append(rrs, readerRange{r, offset, offset + s.Length, s.Offset - offset})
+			offset += s.Length
+		}
+	}
+	rrs = append(rrs, readerRange{r, offset, math.MaxInt64, l - offset})
+	b := &Buffer{rrs: rrs, index: 0, mu: new(sync.Mutex)}
+	b.cleanup()
+	b.validate("Restore")
+	return b, nil
+}
+
+// MemoryLen returns the number of the bytes held in memory by the buffer,
+// which excludes the bytes of the original reader.
+func (b *Buffer) MemoryLen() int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var n int64
+	for _, rr := range b.rrs {
+		if r, ok := rr.r.(*bytesReader); ok {
+			n += int64(len(r.bs))
+		}
+	}
+	return n
+}
+
+// RangeCount returns the number of the reader ranges of the buffer, which
+// grows with the edits scattered over the buffer.
+func (b *Buffer) RangeCount() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.rrs)
+}
+
 // Clone the buffer.
 func (b *Buffer) Clone() *Buffer {
 	b.mu.Lock()
@@ -152,50 +413,74 @@ func (b *Buffer) Clone() *Buffer {
 
 // Insert inserts a byte at the specific position.
 func (b *Buffer) Insert(offset int64, c byte) {
+	b.InsertBytes(offset, []byte{c})
+}
+
+// InsertBytes inserts the bytes at the specific position.
+func (b *Buffer) InsertBytes(offset int64, bs []byte) {
+	if len(bs) == 0 {
+		return
+	}
 	b.mu.Lock()
 	defer b.mu.Unlock()
-	for i, rr := range b.rrs {
-		if offset >= rr.max {
-			continue
-		}
+	defer b.validate("InsertBytes")
+	n := int64(len(bs))
+	if i := b.find(offset); i < len(b.rrs) {
+		rr := b.rrs[i]
 		if offset == rr.min && i > 0 {
 			switch r := b.rrs[i-1].r.(type) {
 			case *bytesReader:
-				r.appendByte(c)
-				b.rrs[i-1].max++
+				r.appendBytes(bs)
+				b.rrs[i-1].max += n
 				for ; i < len(b.rrs); i++ {
-					b.rrs[i].min++
-					b.rrs[i].max = mathutil.MinInt64(b.rrs[i].max, math.MaxInt64-1) + 1
-					b.rrs[i].diff--
+					b.rrs[i].min += n
+					b.rrs[i].max = mathutil.MinInt64(b.rrs[i].max, math.MaxInt64-n) + n
+					b.rrs[i].diff -= n
 				}
 				return
 			}
 		}
-		b.rrs = append(b.rrs, readerRange{})
-		b.rrs = append(b.rrs, readerRange{})
-		copy(b.rrs[i+2:], b.rrs[i:])
-		b.rrs[i] = readerRange{rr.r, rr.min, offset, rr.diff}
-		b.rrs[i+1] = readerRange{newBytesReader([]byte{c}), offset, offset + 1, -offset}
-		b.rrs[i+2] = readerRange{b.clone(rr.r), offset + 1, mathutil.MinInt64(rr.max, math.MaxInt64-1) + 1, rr.diff - 1}
-		for i = i + 3; i < len(b.rrs); i++ {
-			b.rrs[i].min++
-			b.rrs[i].max = mathutil.MinInt64(b.rrs[i].max, math.MaxInt64-1) + 1
-			b.rrs[i].diff--
+		if r, ok := rr.r.(*bytesReader); ok {
+			r.insertBytes(offset+rr.diff, bs)
+			b.rrs[i].max = mathutil.MinInt64(rr.max, math.MaxInt64-n) + n
+			for i++; i < len(b.rrs); i++ {
+				b.rrs[i].min += n
+				b.rrs[i].max = mathutil.MinInt64(b.rrs[i].max, math.MaxInt64-n) + n
+				b.rrs[i].diff -= n
+			}
+			return
 		}
-		b.cleanup()
+		b.insertRange(i, offset, newBytesReader(append([]byte(nil), bs...)), n)
 		return
 	}
-	panic("buffer.Buffer.Insert: unreachable")
+	panic("buffer.Buffer.InsertBytes: unreachable")
+}
+
+// insertRange splits the range at the index by the offset, and inserts the
+// range of the reader between them.
+func (b *Buffer) insertRange(i int, offset int64, r readAtSeeker, n int64) {
+	rr := b.rrs[i]
+	b.rrs = append(b.rrs, readerRange{})
+	b.rrs = append(b.rrs, readerRange{})
+	copy(b.rrs[i+2:], b.rrs[i:])
+	b.rrs[i] = readerRange{rr.r, rr.min, offset, rr.diff}
+	b.rrs[i+1] = readerRange{r, offset, offset + n, -offset}
+	b.rrs[i+2] = readerRange{b.clone(rr.r), offset + n, mathutil.MinInt64(rr.max, math.MaxInt64-n) + n, rr.diff - n}
+	for i = i + 3; i < len(b.rrs); i++ {
+		b.rrs[i].min += n
+		b.rrs[i].max = mathutil.MinInt64(b.rrs[i].max, math.MaxInt64-n) + n
+		b.rrs[i].diff -= n
+	}
+	b.cleanup()
 }
 
 // Replace replaces a byte at the specific position.
 func (b *Buffer) Replace(offset int64, c byte) {
 	b.mu.Lock()
 	defer b.mu.Unlock()
-	for i, rr := range b.rrs {
-		if offset >= rr.max {
-			continue
-		}
+	defer b.validate("Replace")
+	if i := b.find(offset); i < len(b.rrs) {
+		rr := b.rrs[i]
 		switch r := rr.r.(type) {
 		case *bytesReader:
 			r.replaceByte(offset+rr.diff, c)
@@ -227,10 +512,9 @@ func (b *Buffer) Replace(offset int64, c byte) {
 func (b *Buffer) Delete(offset int64) {
 	b.mu.Lock()
 	defer b.mu.Unlock()
-	for i, rr := range b.rrs {
-		if offset >= rr.max {
-			continue
-		}
+	defer b.validate("Delete")
+	if i := b.find(offset); i < len(b.rrs) {
+		rr := b.rrs[i]
 		switch r := rr.r.(type) {
 		case *bytesReader:
 			r.deleteByte(offset + rr.diff)
@@ -266,6 +550,55 @@ func (b *Buffer) Delete(offset int64) {
 	panic("buffer.Buffer.Delete: unreachable")
 }
 
+// DeleteBytes deletes the bytes of the length at the specific position. The
+// bytes after the end of the buffer are not deleted.
+func (b *Buffer) DeleteBytes(offset, n int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	defer b.validate("DeleteBytes")
+	if l, err := b.len(); err == nil && offset+n > l {
+		n = l - offset
+	}
+	if n <= 0 {
+		return
+	}
+	end := offset + n
+	rrs := make([]readerRange, 0, len(b.rrs)+1)
+	for _, rr := range b.rrs {
+		if rr.max <= offset {
+			rrs = append(rrs, rr)
+			continue
+		}
+		if rr.min < offset {
+			rrs = append(rrs, readerRange{rr.r, rr.min, offset, rr.diff})
+			if rr.max > end {
+				rr.r = b.clone(rr.r)
+			}
+			// truncate the bytes so that appending to the range does not
+			// leave the deleted bytes before the appended ones
+			if r, ok := rrs[len(rrs)-1].r.(*bytesReader); ok {
+				r.bs = r.bs[:offset+rr.diff]
+			}
+		}
+		if rr.max > end {
+			max := rr.max
+			if max != math.MaxInt64 {
+				max -= n
+			}
+			rrs = append(rrs, readerRange{rr.r, mathutil.MaxInt64(rr.min, end) - n, max, rr.diff + n})
+		}
+	}
+	b.rrs = rrs
+	b.cleanup()
+}
+
+// find the index of the range which contains the offset.
+func (b *Buffer) find(offset int64) int {
+	return sort.Search(len(b.rrs), func(i int) bool {
+		return offset < b.rrs[i].max
+	})
+}
+
 func (b *Buffer) clone(r readAtSeeker) readAtSeeker {
 	switch br := r.(type) {
 	case *bytesReader:
@@ -277,11 +610,57 @@ func (b *Buffer) clone(r readAtSeeker) readAtSeeker {
 	}
 }
 
+const (
+	// maxReaderRanges is the number of the ranges to start compaction.
+	maxReaderRanges = 1024
+	// compactLength is the initial length of the ranges read into memory on
+	// compaction, which is doubled until the ranges are halved.
+	compactLength = 64
+	// maxCompactLength is the maximum length of the ranges read into memory.
+	maxCompactLength = 64 * 1024
+)
+
+// compact reads the short ranges of the reader between the edited bytes into
+// memory, so that scattered edits in a long session do not grow the ranges.
+func (b *Buffer) compact() {
+	for length := int64(compactLength); len(b.rrs) > maxReaderRanges/2 &&
+		length <= maxCompactLength; length *= 2 {
+		for i := 0; i < len(b.rrs)-1; i++ {
+			j, read := i, b.compactCost(i)
+			for ; j+1 < len(b.rrs)-1; j++ {
+				if read += b.compactCost(j + 1); read > length {
+					break
+				}
+			}
+			if j == i || b.compactCost(i) > length {
+				continue
+			}
+			min, max := b.rrs[i].min, b.rrs[j].max
+			bs := make([]byte, max-min)
+			if n, _ := b.readAt(bs, min); n < len(bs) {
+				continue
+			}
+			b.rrs[i] = readerRange{newBytesReader(bs), min, max, -min}
+			b.rrs = append(b.rrs[:i+1], b.rrs[j+1:]...)
+		}
+	}
+}
+
+// compactCost returns the number of bytes newly read into memory on
+// compaction of the range.
+func (b *Buffer) compactCost(i int) int64 {
+	if _, ok := b.rrs[i].r.(*bytesReader); ok {
+		return 0
+	}
+	return b.rrs[i].max - b.rrs[i].min
+}
+
 func (b *Buffer) cleanup() {
 	for i := 0; i < len(b.rrs); i++ {
 		if b.rrs[i].min == b.rrs[i].max {
 			copy(b.rrs[i:], b.rrs[i+1:])
 			b.rrs = b.rrs[:len(b.rrs)-1]
+			i--
 		}
 	}
 	for i := 1; i < len(b.rrs); i++ {
@@ -306,4 +685,7 @@ func (b *Buffer) cleanup() {
 			b.rrs = b.rrs[:len(b.rrs)-1]
 		}
 	}
+	if len(b.rrs) > maxReaderRanges {
+		b.compact()
+	}
 }
@@ -1,6 +1,7 @@
 package buffer
 
 import (
+	"bytes"
 	"io"
 	"reflect"
 	"strings"
@@ -119,6 +120,36 @@ func TestBuffer(t *testing.T) {
 	}
 }
 
+func TestBufferReadAt(t *testing.T) {
+	b := NewBuffer(strings.NewReader("0123456789abcdef"))
+	b.InsertBytes(4, []byte("xyz"))
+	b.InsertBytes(5, []byte("w"))
+	if _, err := b.Seek(2, io.SeekStart); err != nil {
+		t.Fatalf("err should be nil but got: %v", err)
+	}
+
+	p := make([]byte, 8)
+	n, err := b.ReadAt(p, 3)
+	if err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	if expected := "3xwyz456"; string(p[:n]) != expected {
+		t.Errorf("p should be %q but got: %q", expected, string(p[:n]))
+	}
+
+	n, err = b.Read(p[:4])
+	if err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	if expected := "23xw"; string(p[:n]) != expected {
+		t.Errorf("ReadAt should not change the offset but Read got: %q", string(p[:n]))
+	}
+
+	if _, err = b.ReadAt(p, -1); err == nil {
+		t.Errorf("err should not be nil for a negative offset")
+	}
+}
+
 func TestBufferClone(t *testing.T) {
 	b0 := NewBuffer(strings.NewReader("0123456789abcdef"))
 	b1 := b0.Clone()
@@ -248,6 +279,94 @@ func TestBufferInsert(t *testing.T) {
 	}
 }
 
+func TestBufferInsertBytes(t *testing.T) {
+	b := NewBuffer(strings.NewReader("0123456789abcdef"))
+
+	tests := []struct {
+		index    int64
+		bs       string
+		offset   int64
+		expected string
+		len      int64
+	}{
+		{0, "xy", 0, "xy012345", 18},
+		{2, "zz", 0, "xyzz0123", 20},
+		{8, "", 4, "01234567", 20},
+		{8, "www", 4, "0123www4", 23},
+		{23, "end", 18, "bcdefend", 26},
+	}
+
+	for _, test := range tests {
+		b.InsertBytes(test.index, []byte(test.bs))
+		p := make([]byte, 8)
+
+		n, err := b.ReadAt(p, test.offset)
+		if err != nil && err != io.EOF {
+			t.Errorf("err should be nil or io.EOF but got: %v", err)
+		}
+		if n != 8 {
+			t.Errorf("n should be 8 but got: %d", n)
+		}
+		if string(p) != test.expected {
+			t.Errorf("p should be %s but got: %s", test.expected, string(p))
+		}
+
+		l, err := b.Len()
+		if err != nil {
+			t.Errorf("err should be nil but got: %v", err)
+		}
+		if l != test.len {
+			t.Errorf("l should be %d but got: %d", test.len, l)
+		}
+	}
+
+	eis := b.EditedIndices()
+	expected := []int64{0, 4, 8, 11, 23, 26}
+	if !reflect.DeepEqual(eis, expected) {
+		t.Errorf("edited indices should be %v but got: %v", expected, eis)
+	}
+}
+
+func TestBufferInsertReader(t *testing.T) {
+	r, spool := strings.NewReader("0123456789abcdef"), strings.NewReader("0123")
+	b := NewBuffer(r)
+	b.InsertReader(0, spool, 4)
+	b.InsertReader(6, strings.NewReader("xyz"), 3)
+	b.Replace(7, 'Y')
+
+	p := make([]byte, 24)
+	n, _ := b.ReadAt(p, 0)
+	if expected := "012301xYz23456789abcdef"; string(p[:n]) != expected {
+		t.Errorf("p should be %q but got: %q", expected, string(p[:n]))
+	}
+	eis := b.EditedIndices()
+	expected := []int64{0, 4, 6, 7, 7, 8, 8, 9}
+	if !reflect.DeepEqual(eis, expected) {
+		t.Errorf("edited indices should be %v but got: %v", expected, eis)
+	}
+	if n := b.MemoryLen(); n != 1 {
+		t.Errorf("memory length should be %d but got: %d", 1, n)
+	}
+	segments, err := b.Segments()
+	if err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	if s := segments[0]; s.Spool != spool || s.Offset != 0 || s.Length != 4 || s.Bytes != nil {
+		t.Errorf("segment should refer to the inserted reader but got: %+v", s)
+	}
+	c, err := Restore(r, segments)
+	if err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	n, _ = c.ReadAt(p, 0)
+	if expected := "012301xYz23456789abcdef"; string(p[:n]) != expected {
+		t.Errorf("restored buffer should be %q but got %q", expected, string(p[:n]))
+	}
+	if eis := c.EditedIndices(); !reflect.DeepEqual(eis, expected) {
+		t.Errorf("edited indices should be %v but got: %v", expected, eis)
+	}
+}
+
 func TestBufferReplace(t *testing.T) {
 	b := NewBuffer(strings.NewReader("0123456789abcdef"))
 
@@ -378,3 +497,471 @@ func TestBufferDelete(t *testing.T) {
 		t.Errorf("len(b.rrs) should be 4 but got: %d", len(b.rrs))
 	}
 }
+
+func TestBufferDeleteBytes(t *testing.T) {
+	b := NewBuffer(strings.NewReader("0123456789abcdef"))
+	b.InsertBytes(4, []byte("xyz"))
+
+	tests := []struct {
+		offset   int64
+		n        int64
+		expected string
+	}{
+		{2, 4, "01z456789abcdef"},
+		{10, 3, "01z456789aef"},
+		{0, 3, "456789aef"},
+		{3, 0, "456789aef"},
+		{7, 10, "456789a"},
+	}
+
+	for _, test := range tests {
+		b.DeleteBytes(test.offset, test.n)
+		p := make([]byte, 20)
+
+		n, err := b.ReadAt(p, 0)
+		if err != nil && err != io.EOF {
+			t.Errorf("err should be nil or io.EOF but got: %v", err)
+		}
+		if string(p[:n]) != test.expected {
+			t.Errorf("p should be %s but got: %s", test.expected, string(p[:n]))
+		}
+
+		l, err := b.Len()
+		if err != nil {
+			t.Errorf("err should be nil but got: %v", err)
+		}
+		if l != int64(len(test.expected)) {
+			t.Errorf("l should be %d but got: %d", len(test.expected), l)
+		}
+	}
+}
+
+func TestBufferDeleteInserted(t *testing.T) {
+	tests := []struct {
+		name     string
+		f        func(*Buffer)
+		expected string
+	}{
+		{
+			name: "append after deleting the tail of the inserted bytes",
+			f: func(b *Buffer) {
+				b.InsertBytes(2, []byte("abcd"))
+				b.DeleteBytes(4, 2)
+				b.InsertBytes(4, []byte("xy"))
+			},
+			expected: "01abxy23",
+		},
+		{
+			name: "delete between the inserted bytes",
+			f: func(b *Buffer) {
+				b.Insert(1, '0')
+				b.Insert(3, '0')
+				b.Delete(2)
+			},
+			expected: "00023",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			b := NewBuffer(strings.NewReader("0123"))
+			test.f(b)
+			p := make([]byte, 20)
+			n, err := b.ReadAt(p, 0)
+			if err != nil && err != io.EOF {
+				t.Errorf("err should be nil or io.EOF but got: %v", err)
+			}
+			if string(p[:n]) != test.expected {
+				t.Errorf("p should be %q but got: %q", test.expected, string(p[:n]))
+			}
+			for _, rr := range b.rrs {
+				if rr.min == rr.max {
+					t.Errorf("empty ranges should be removed but got: %+v", b.rrs)
+				}
+			}
+		})
+	}
+}
+
+func TestBufferCompact(t *testing.T) {
+	bs := []byte(strings.Repeat("0123456789abcdef", 1024))
+	b := NewBuffer(bytes.NewReader(bs))
+	expected := append([]byte(nil), bs...)
+	for i := 0; i < 2000; i++ {
+		offset := int64(i * 8)
+		b.Insert(offset, 'x')
+		expected = append(expected[:offset], append([]byte{'x'}, expected[offset:]...)...)
+		b.Replace(offset+3, 'y')
+		expected[offset+3] = 'y'
+		b.Delete(offset + 5)
+		expected = append(expected[:offset+5], expected[offset+6:]...)
+	}
+	if len(b.rrs) > maxReaderRanges {
+		t.Errorf("len(b.rrs) should be bounded but got: %d", len(b.rrs))
+	}
+	got := make([]byte, len(expected)+1)
+	n, err := b.ReadAt(got, 0)
+	if err != io.EOF && err != nil {
+		t.Errorf("err should be nil or EOF but got: %v", err)
+	}
+	if !bytes.Equal(got[:n], expected) {
+		t.Errorf("contents should be preserved after compaction")
+	}
+	if l, _ := b.Len(); l != int64(len(expected)) {
+		t.Errorf("Len should be %d but got %d", len(expected), l)
+	}
+}
+
+func TestBufferChangedRanges(t *testing.T) {
+	b := NewBuffer(strings.NewReader("0123456789abcdef"))
+
+	tests := []struct {
+		f        func()
+		expected []int64
+	}{
+		{func() {}, nil},
+		{func() { b.Replace(4, 0x40) }, []int64{4, 5}},
+		{func() { b.Replace(5, 0x41) }, []int64{4, 6}},
+		{func() { b.Replace(10, 0x42) }, []int64{4, 6, 10, 11}},
+		{func() { b.Delete(12) }, []int64{4, 6, 10, 11, 12, 15}},
+		{func() { b.Insert(2, 0x43) }, []int64{2, 13}},
+	}
+
+	for _, test := range tests {
+		test.f()
+		rs, err := b.ChangedRanges()
+		if err != nil {
+			t.Errorf("err should be nil but got: %v", err)
+		}
+		if !reflect.DeepEqual(rs, test.expected) {
+			t.Errorf("changed ranges should be %v but got: %v", test.expected, rs)
+		}
+	}
+}
+
+func TestBufferDirtyRanges(t *testing.T) {
+	b := NewBuffer(strings.NewReader("0123456789abcdef"))
+
+	tests := []struct {
+		f        func()
+		expected []int64
+	}{
+		{func() {}, []int64{}},
+		{func() { b.Replace(4, 0x40) }, []int64{4, 5}},
+		{func() { b.Replace(4, 0x34) }, []int64{}},
+		{func() { b.Replace(5, 0x41); b.Replace(6, 0x36) }, []int64{5, 6}},
+		{func() { b.Replace(10, 0x61) }, []int64{5, 6}},
+		{func() { b.Insert(2, 0x43) }, []int64{2, 3, 6, 7}},
+		{func() { b.Delete(0) }, []int64{1, 2, 5, 6}},
+		{func() { b.Insert(16, 0x44) }, []int64{1, 2, 5, 6, 16, 17}},
+	}
+
+	for _, test := range tests {
+		test.f()
+		rs, err := b.DirtyRanges()
+		if err != nil {
+			t.Errorf("err should be nil but got: %v", err)
+		}
+		if !reflect.DeepEqual(rs, test.expected) {
+			t.Errorf("dirty ranges should be %v but got: %v", test.expected, rs)
+		}
+	}
+
+	rs, err := b.DirtyRangesIn(2, 6)
+	if err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	if expected := []int64{5, 6}; !reflect.DeepEqual(rs, expected) {
+		t.Errorf("dirty ranges should be %v but got: %v", expected, rs)
+	}
+}
+
+func TestBufferDirtyNibbles(t *testing.T) {
+	b := NewBuffer(strings.NewReader("0123456789abcdef"))
+	b.Replace(1, 0x41) // 0x31 -> 0x41
+	b.Replace(2, 0x3f) // 0x32 -> 0x3f
+	b.Replace(3, 0x00) // 0x33 -> 0x00
+	b.Insert(8, 0x43)
+
+	ns, err := b.AppendDirtyNibblesIn(nil, 0, 16)
+	if err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	if expected := []int64{2, 5}; !reflect.DeepEqual(ns, expected) {
+		t.Errorf("dirty nibbles should be %v but got: %v", expected, ns)
+	}
+	if ns, _ = b.AppendDirtyNibblesIn(nil, 2, 16); !reflect.DeepEqual(ns, []int64{5}) {
+		t.Errorf("dirty nibbles should be %v but got: %v", []int64{5}, ns)
+	}
+}
+
+func TestBufferShifted(t *testing.T) {
+	b := NewBuffer(strings.NewReader("0123456789abcdef"))
+
+	tests := []struct {
+		f        func()
+		expected bool
+	}{
+		{func() {}, false},
+		{func() { b.Replace(4, 0x40) }, false},
+		{func() { b.Insert(8, 0x41) }, true},
+		{func() { b.Delete(9) }, false},
+		{func() { b.Delete(2) }, true},
+	}
+
+	for _, test := range tests {
+		test.f()
+		shifted, err := b.Shifted()
+		if err != nil {
+			t.Errorf("err should be nil but got: %v", err)
+		}
+		if shifted != test.expected {
+			t.Errorf("shifted should be %v but got: %v", test.expected, shifted)
+		}
+	}
+}
+
+func TestBufferSegments(t *testing.T) {
+	r := strings.NewReader("0123456789abcdef")
+	b := NewBuffer(r)
+	b.Replace(4, 0x40)
+	b.Insert(8, 0x41)
+	b.Delete(12)
+	b.Delete(0)
+
+	segments, err := b.Segments()
+	if err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	expected := []Segment{
+		{Offset: 1, Length: 3},
+		{Length: 1, Bytes: []byte{0x40}},
+		{Offset: 5, Length: 3},
+		{Length: 1, Bytes: []byte{0x41}},
+		{Offset: 8, Length: 3},
+		{Offset: 12, Length: 4},
+	}
+	if !reflect.DeepEqual(segments, expected) {
+		t.Errorf("segments should be %+v but got: %+v", expected, segments)
+	}
+
+	c, err := Restore(r, segments)
+	if err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	p := make([]byte, 20)
+	n, _ := c.ReadAt(p, 0)
+	if expected := "123@567A89acdef"; string(p[:n]) != expected {
+		t.Errorf("restored buffer should be %q but got %q", expected, string(p[:n]))
+	}
+	if l, _ := c.Len(); l != 15 {
+		t.Errorf("l should be %d but got: %d", 15, l)
+	}
+
+	if _, err := Restore(r, []Segment{{Offset: 10, Length: 10}}); err == nil {
+		t.Errorf("err should not be nil for an invalid segment")
+	}
+}
+
+func newScatteredBuffer(n int) *Buffer {
+	b := NewBuffer(strings.NewReader(strings.Repeat("0123456789abcdef", n)))
+	for i := 0; i < n; i++ {
+		b.Replace(int64(i)*16, 'x')
+	}
+	return b
+}
+
+func TestBufferDiff(t *testing.T) {
+	b := NewBuffer(strings.NewReader("Hello, world!"))
+	testCases := []struct {
+		name           string
+		edit           func(*Buffer)
+		offset, length int64
+	}{
+		{"none", func(*Buffer) {}, 13, 0},
+		{"replace", func(b *Buffer) { b.Replace(7, 'W') }, 7, 1},
+		{"insert", func(b *Buffer) { b.InsertBytes(5, []byte("!!!")) }, 5, 3},
+		{"delete", func(b *Buffer) { b.Delete(0); b.Delete(0) }, 0, 2},
+		{"append", func(b *Buffer) { b.InsertBytes(13, []byte("\n")) }, 13, 1},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := b.Clone()
+			tc.edit(c)
+			offset, length, err := Diff(b, c)
+			if err != nil {
+				t.Fatalf("err should be nil but got: %v", err)
+			}
+			if offset != tc.offset || length != tc.length {
+				t.Errorf("diff should be (%d, %d) but got (%d, %d)",
+					tc.offset, tc.length, offset, length)
+			}
+		})
+	}
+}
+
+// countReader counts the reads, to check that the spooled bytes are not read.
+type countReader struct {
+	*strings.Reader
+	count int
+}
+
+func (r *countReader) ReadAt(p []byte, off int64) (int, error) {
+	r.count++
+	return r.Reader.ReadAt(p, off)
+}
+
+func TestBufferDiffSpool(t *testing.T) {
+	spool := &countReader{Reader: strings.NewReader(strings.Repeat("x", 1024))}
+	b := NewBuffer(strings.NewReader("Hello, world!"))
+	b.InsertReader(7, spool, 1024)
+	c := b.Clone()
+	c.Replace(1030, 'W')
+	offset, length, err := Diff(b, c)
+	if err != nil {
+		t.Fatalf("err should be nil but got: %v", err)
+	}
+	if offset != 1030 || length != 1 {
+		t.Errorf("diff should be (%d, %d) but got (%d, %d)", 1030, 1, offset, length)
+	}
+	c.InsertReader(7, strings.NewReader(strings.Repeat("x", 1024)), 1024)
+	if offset, length, err = Diff(b, c); err != nil {
+		t.Fatalf("err should be nil but got: %v", err)
+	}
+	if offset != 7 || length != 2048 {
+		t.Errorf("diff should be (%d, %d) but got (%d, %d)", 7, 2048, offset, length)
+	}
+	if spool.count != 0 {
+		t.Errorf("spooled bytes should not be read but got %d reads", spool.count)
+	}
+}
+
+func BenchmarkBufferReadAt(b *testing.B) {
+	buf := newScatteredBuffer(4096)
+	p := make([]byte, 4096)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, _ = buf.ReadAt(p, int64(i*4096%(4096*16)))
+	}
+}
+
+func BenchmarkBufferInsert(b *testing.B) {
+	buf := newScatteredBuffer(4096)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		buf.Insert(int64(i*61%(4096*16)), 'y')
+	}
+}
+
+func BenchmarkBufferDelete(b *testing.B) {
+	buf := newScatteredBuffer(4096)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if l, _ := buf.Len(); l < 4096*8 {
+			b.StopTimer()
+			buf = newScatteredBuffer(4096)
+			b.StartTimer()
+		}
+		buf.Delete(int64(i * 61 % (4096 * 8)))
+	}
+}
+
+// FuzzBuffer applies the operations decoded from the input to the buffer and
+// to the byte slice, and checks that the buffer keeps the same bytes. The
+// undo restores the clone of the buffer, as the history does.
+func FuzzBuffer(f *testing.F) {
+	f.Add("0123456789abcdef", []byte{0, 3, 'x', 2, 5, 3, 1, 4, 2, 6})
+	f.Add("", []byte{1, 0, 3, 'a', 'b', 'c', 5, 0, 4, 1, 2, 0, 0})
+	f.Add("abc", []byte{4, 1, 100, 0, 0, 'z', 5, 5, 6, 3, 2})
+	f.Add("0000000000000", []byte("20200C70B110100"))
+	f.Add("00", []byte("110170B2"))
+	f.Fuzz(func(t *testing.T, s string, ops []byte) {
+		b, xs := NewBuffer(strings.NewReader(s)), []byte(s)
+		type snapshot struct {
+			b  *Buffer
+			xs []byte
+		}
+		var undos []snapshot
+		next := func() int64 {
+			if len(ops) == 0 {
+				return 0
+			}
+			c := ops[0]
+			ops = ops[1:]
+			return int64(c)
+		}
+		for len(ops) > 0 {
+			op := next() % 7
+			offset := next()
+			switch op {
+			case 0: // Insert
+				offset %= int64(len(xs)) + 1
+				c := byte(next())
+				b.Insert(offset, c)
+				xs = append(xs[:offset], append([]byte{c}, xs[offset:]...)...)
+			case 1: // InsertBytes
+				offset %= int64(len(xs)) + 1
+				n := int(next() % 8)
+				if n > len(ops) {
+					n = len(ops)
+				}
+				bs := append([]byte(nil), ops[:n]...)
+				ops = ops[n:]
+				b.InsertBytes(offset, bs)
+				xs = append(xs[:offset], append(bs, xs[offset:]...)...)
+			case 2: // Replace
+				if len(xs) == 0 {
+					continue
+				}
+				offset %= int64(len(xs))
+				c := byte(next())
+				b.Replace(offset, c)
+				xs[offset] = c
+			case 3: // Delete
+				if len(xs) == 0 {
+					continue
+				}
+				offset %= int64(len(xs))
+				b.Delete(offset)
+				xs = append(xs[:offset], xs[offset+1:]...)
+			case 4: // DeleteBytes
+				offset %= int64(len(xs)) + 1
+				n := next()
+				b.DeleteBytes(offset, n)
+				if end := offset + n; end < int64(len(xs)) {
+					xs = append(xs[:offset], xs[end:]...)
+				} else {
+					xs = xs[:offset]
+				}
+			case 5: // push to the undo history
+				undos = append(undos, snapshot{b.Clone(), append([]byte(nil), xs...)})
+			case 6: // undo
+				if len(undos) == 0 {
+					continue
+				}
+				u := undos[len(undos)-1]
+				undos = undos[:len(undos)-1]
+				b, xs = u.b.Clone(), u.xs
+			}
+			l, err := b.Len()
+			if err != nil {
+				t.Fatalf("err should be nil but got: %v", err)
+			}
+			if l != int64(len(xs)) {
+				t.Fatalf("Len should be %d but got %d", len(xs), l)
+			}
+		}
+		p := make([]byte, len(xs)+1)
+		n, err := b.ReadAt(p, 0)
+		if err != nil && err != io.EOF {
+			t.Fatalf("err should be nil or EOF but got: %v", err)
+		}
+		if !bytes.Equal(p[:n], xs) {
+			t.Fatalf("bytes should be %q but got %q", xs, p[:n])
+		}
+		for offset, q := int64(0), make([]byte, 3); offset <= int64(len(xs)); offset++ {
+			if _, err := b.ReadAt(q, offset); err != nil && err != io.EOF {
+				t.Fatalf("err should be nil or EOF but got: %v", err)
+			}
+		}
+	})
+}
@@ -56,6 +56,10 @@ func (r *bytesReader) appendByte(b byte) {
 	r.bs = append(r.bs, b)
 }
 
+func (r *bytesReader) appendBytes(bs []byte) {
+	r.bs = append(r.bs, bs...)
+}
+
 func (r *bytesReader) replaceByte(offset int64, b byte) {
 	r.bs[offset] = b
 }
@@ -64,3 +68,9 @@ func (r *bytesReader) deleteByte(offset int64) {
 	copy(r.bs[offset:], r.bs[offset+1:])
 	r.bs = r.bs[:len(r.bs)-1]
 }
+
+func (r *bytesReader) insertBytes(offset int64, bs []byte) {
+	r.bs = append(r.bs, bs...)
+	copy(r.bs[offset+int64(len(bs)):], r.bs[offset:])
+	copy(r.bs[offset:], bs)
+}
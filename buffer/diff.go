@@ -0,0 +1,70 @@
+package buffer
+
+import "github.com/itchyny/bed/mathutil"
+
+// Diff returns the offset of the first byte which differs between the
+// buffers, and the number of the differing bytes; the larger one of the bytes
+// removed from a and the bytes inserted to b. The buffers are compared by the
+// segments, so the bytes referring to the same offset of the original reader
+// or the spooled reader are not read, and it works for the clones of a large
+// buffer.
+func Diff(a, b *Buffer) (int64, int64, error) {
+	sa, err := a.Segments()
+	if err != nil {
+		return 0, 0, err
+	}
+	sb, err := b.Segments()
+	if err != nil {
+		return 0, 0, err
+	}
+	la, lb := segmentsLength(sa), segmentsLength(sb)
+	prefix := commonLength(sa, sb, false)
+	suffix := mathutil.MinInt64(commonLength(sa, sb, true), mathutil.MinInt64(la, lb)-prefix)
+	return prefix, mathutil.MaxInt64(la, lb) - prefix - suffix, nil
+}
+
+func segmentsLength(segments []Segment) (n int64) {
+	for _, s := range segments {
+		n += s.Length
+	}
+	return
+}
+
+// commonLength returns the length of the common prefix of the segments, or
+// the common suffix when rev is true. The segments referring to the reader
+// are common when they refer to the same offset of the same reader.
+func commonLength(sa, sb []Segment, rev bool) (n int64) {
+	var ia, ib int
+	var oa, ob int64 // offsets in the segments from the walking direction
+	for ia < len(sa) && ib < len(sb) {
+		x, y := sa[ia], sb[ib]
+		if rev {
+			x, y = sa[len(sa)-1-ia], sb[len(sb)-1-ib]
+		}
+		k := mathutil.MinInt64(x.Length-oa, y.Length-ob)
+		switch {
+		case x.Bytes == nil && y.Bytes == nil && x.Spool == y.Spool:
+			if rev && x.Offset+x.Length-oa != y.Offset+y.Length-ob ||
+				!rev && x.Offset+oa != y.Offset+ob {
+				return
+			}
+		case x.Bytes != nil && y.Bytes != nil:
+			for j := int64(0); j < k; j++ {
+				if rev && x.Bytes[x.Length-1-oa-j] != y.Bytes[y.Length-1-ob-j] ||
+					!rev && x.Bytes[oa+j] != y.Bytes[ob+j] {
+					return n + j
+				}
+			}
+		default:
+			return
+		}
+		n, oa, ob = n+k, oa+k, ob+k
+		if oa == x.Length {
+			ia, oa = ia+1, 0
+		}
+		if ob == y.Length {
+			ib, ob = ib+1, 0
+		}
+	}
+	return
+}
@@ -0,0 +1,35 @@
+package buffer
+
+// spoolReader is the reader of the bytes inserted from a temporary file, so
+// that the buffer does not hold the huge inserted bytes in memory. Unlike the
+// original reader, the bytes are handled as the edited bytes.
+type spoolReader struct {
+	readAtSeeker
+}
+
+// InsertReader inserts the n bytes of the reader at the specific position,
+// without reading the bytes into memory. The reader should not be changed
+// after the insertion.
+func (b *Buffer) InsertReader(offset int64, r readAtSeeker, n int64) {
+	if n <= 0 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	defer b.validate("InsertReader")
+	if i := b.find(offset); i < len(b.rrs) {
+		b.insertRange(i, offset, &spoolReader{r}, n)
+		return
+	}
+	panic("buffer.Buffer.InsertReader: unreachable")
+}
+
+// edited reports whether the reader holds the edited bytes.
+func edited(r readAtSeeker) bool {
+	switch r.(type) {
+	case *bytesReader, *spoolReader:
+		return true
+	default:
+		return false
+	}
+}
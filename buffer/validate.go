@@ -0,0 +1,62 @@
+// +build buffercheck
+
+package buffer
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// validate panics with the ranges when the buffer is broken by the operation.
+// The check is enabled by the buffercheck build tag; go test -tags buffercheck.
+func (b *Buffer) validate(op string) {
+	if err := b.check(); err != nil {
+		var sb strings.Builder
+		for i, rr := range b.rrs {
+			fmt.Fprintf(&sb, "\n  %d: %T min=%d max=%d diff=%d", i, rr.r, rr.min, rr.max, rr.diff)
+			if r, ok := rr.r.(*bytesReader); ok {
+				fmt.Fprintf(&sb, " len=%d", len(r.bs))
+			}
+		}
+		panic(fmt.Sprintf("buffer.Buffer.%s: %s%s", op, err, sb.String()))
+	}
+}
+
+// check reports the first violation of the invariants of the ranges; they are
+// contiguous from 0 to math.MaxInt64, and the bytes readers are not shared and
+// end at the ranges.
+func (b *Buffer) check() error {
+	if len(b.rrs) == 0 {
+		return errors.New("no ranges")
+	}
+	if b.rrs[0].min != 0 {
+		return fmt.Errorf("the first range starts at %d", b.rrs[0].min)
+	}
+	if rr := b.rrs[len(b.rrs)-1]; rr.max != math.MaxInt64 {
+		return fmt.Errorf("the last range ends at %d", rr.max)
+	}
+	readers := make(map[*bytesReader]int, len(b.rrs))
+	for i, rr := range b.rrs {
+		if rr.min >= rr.max {
+			return fmt.Errorf("range %d is empty", i)
+		}
+		if i > 0 && b.rrs[i-1].max != rr.min {
+			return fmt.Errorf("range %d is not contiguous to the previous", i)
+		}
+		if rr.min+rr.diff < 0 {
+			return fmt.Errorf("range %d reads at the negative offset", i)
+		}
+		if r, ok := rr.r.(*bytesReader); ok {
+			if j, ok := readers[r]; ok {
+				return fmt.Errorf("ranges %d and %d share the bytes", j, i)
+			}
+			readers[r] = i
+			if rr.max+rr.diff != int64(len(r.bs)) {
+				return fmt.Errorf("range %d ends at %d of %d bytes", i, rr.max+rr.diff, len(r.bs))
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,28 @@
+// +build buffercheck
+
+package buffer
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBufferValidate(t *testing.T) {
+	b := NewBuffer(strings.NewReader("0123456789abcdef"))
+	b.InsertBytes(4, []byte("xyz"))
+	b.Replace(10, 'w')
+	b.DeleteBytes(2, 4)
+	if err := b.check(); err != nil {
+		t.Fatalf("err should be nil but got: %v", err)
+	}
+
+	b.rrs[1].max--
+	defer func() {
+		r := recover()
+		if s, ok := r.(string); !ok || !strings.HasPrefix(s, "buffer.Buffer.Delete: range 1 is not contiguous to the previous\n") ||
+			!strings.Contains(s, "\n  2: *buffer.bytesReader min=5 max=6 diff=-5 len=1\n") {
+			t.Errorf("validate should panic with the ranges but got: %v", r)
+		}
+	}()
+	b.Delete(0)
+}
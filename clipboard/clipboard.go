@@ -0,0 +1,133 @@
+// Package clipboard copies the bytes to and pastes from the system clipboard.
+// It runs the clipboard command of the platform (pbcopy, wl-copy, xclip, xsel
+// or clip), or writes the OSC 52 escape sequence to the terminal when there is
+// no command, for example in the SSH session.
+package clipboard
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// Clipboard is the system clipboard. It remembers the bytes copied last, so
+// they can be pasted even when the clipboard cannot be read.
+type Clipboard struct {
+	last []byte
+	tty  func() (io.WriteCloser, error)
+	mu   *sync.Mutex
+}
+
+// New creates a new Clipboard.
+func New() *Clipboard {
+	return &Clipboard{tty: openTTY, mu: new(sync.Mutex)}
+}
+
+func openTTY() (io.WriteCloser, error) {
+	return os.OpenFile("/dev/tty", os.O_WRONLY, 0)
+}
+
+// command is the pair of the commands to copy and paste.
+type command struct {
+	copy, paste []string
+}
+
+// lookupCommand returns the clipboard command available in the environment.
+func lookupCommand() (*command, bool) {
+	var cmds []*command
+	switch runtime.GOOS {
+	case "darwin":
+		cmds = []*command{{[]string{"pbcopy"}, []string{"pbpaste"}}}
+	case "windows":
+		cmds = []*command{{
+			[]string{"clip"},
+			[]string{"powershell", "-NoProfile", "-Command", "Get-Clipboard -Raw"},
+		}}
+	default:
+		if os.Getenv("WAYLAND_DISPLAY") != "" {
+			cmds = append(cmds, &command{
+				[]string{"wl-copy"}, []string{"wl-paste", "--no-newline"},
+			})
+		}
+		if os.Getenv("DISPLAY") != "" {
+			cmds = append(cmds, &command{
+				[]string{"xclip", "-selection", "clipboard"},
+				[]string{"xclip", "-selection", "clipboard", "-o"},
+			}, &command{
+				[]string{"xsel", "--clipboard", "--input"},
+				[]string{"xsel", "--clipboard", "--output"},
+			})
+		}
+	}
+	for _, cmd := range cmds {
+		if _, err := exec.LookPath(cmd.copy[0]); err == nil {
+			return cmd, true
+		}
+	}
+	return nil, false
+}
+
+// Copy the bytes to the clipboard.
+func (c *Clipboard) Copy(bs []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.last = append([]byte(nil), bs...)
+	if cmd, ok := lookupCommand(); ok {
+		return run(cmd.copy, bytes.NewReader(bs), nil)
+	}
+	w, err := c.tty()
+	if err != nil {
+		return fmt.Errorf("clipboard is not available: %s", err)
+	}
+	defer w.Close()
+	_, err = w.Write(osc52(bs, os.Getenv("TMUX") != ""))
+	return err
+}
+
+// Paste returns the bytes of the clipboard. When the clipboard cannot be read,
+// it returns the bytes copied last.
+func (c *Clipboard) Paste() ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if cmd, ok := lookupCommand(); ok {
+		var b bytes.Buffer
+		if err := run(cmd.paste, nil, &b); err != nil {
+			return nil, err
+		}
+		return b.Bytes(), nil
+	}
+	if c.last == nil {
+		return nil, errors.New("clipboard is not available")
+	}
+	return c.last, nil
+}
+
+func run(args []string, stdin io.Reader, stdout io.Writer) error {
+	cmd := exec.Command(args[0], args[1:]...)
+	var stderr bytes.Buffer
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = stdin, stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("%s: %s", args[0], msg)
+		}
+		return fmt.Errorf("%s: %s", args[0], err)
+	}
+	return nil
+}
+
+// osc52 returns the escape sequence to set the clipboard of the terminal. In
+// tmux, the sequence is passed through to the outer terminal.
+func osc52(bs []byte, tmux bool) []byte {
+	seq := "\x1b]52;c;" + base64.StdEncoding.EncodeToString(bs) + "\x07"
+	if tmux {
+		seq = "\x1bPtmux;" + strings.Replace(seq, "\x1b", "\x1b\x1b", -1) + "\x1b\\"
+	}
+	return []byte(seq)
+}
@@ -0,0 +1,55 @@
+package clipboard
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"runtime"
+	"testing"
+)
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error { return nil }
+
+func TestOSC52(t *testing.T) {
+	if expected, got := "\x1b]52;c;SGVsbG8=\x07", string(osc52([]byte("Hello"), false)); got != expected {
+		t.Errorf("osc52 should be %q but got %q", expected, got)
+	}
+	if expected, got := "\x1bPtmux;\x1b\x1b]52;c;SGVsbG8=\x07\x1b\\", string(osc52([]byte("Hello"), true)); got != expected {
+		t.Errorf("osc52 should be %q but got %q", expected, got)
+	}
+}
+
+func TestClipboardOSC52(t *testing.T) {
+	if runtime.GOOS == "darwin" || runtime.GOOS == "windows" {
+		t.Skip("the clipboard command is always available")
+	}
+	for _, name := range []string{"WAYLAND_DISPLAY", "DISPLAY", "TMUX"} {
+		if value, ok := os.LookupEnv(name); ok {
+			defer os.Setenv(name, value)
+			os.Unsetenv(name)
+		}
+	}
+	var b bytes.Buffer
+	c := New()
+	c.tty = func() (io.WriteCloser, error) { return nopCloser{&b}, nil }
+	if _, err := c.Paste(); err == nil || err.Error() != "clipboard is not available" {
+		t.Errorf("err should be %q but got: %v", "clipboard is not available", err)
+	}
+	if err := c.Copy([]byte("\x00\x01\x02")); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	if expected := "\x1b]52;c;AAEC\x07"; b.String() != expected {
+		t.Errorf("terminal should receive %q but got %q", expected, b.String())
+	}
+	bs, err := c.Paste()
+	if err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	if expected := "\x00\x01\x02"; string(bs) != expected {
+		t.Errorf("pasted bytes should be %q but got %q", expected, string(bs))
+	}
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/itchyny/bed/window"
+)
+
+// runDiff writes the hunks of the differing bytes of the files. The exit
+// status is 0 for the identical files, 1 for the different files, and 2 for
+// the errors, as with diff(1).
+func runDiff(args []string) int {
+	if len(args) != 2 {
+		fmt.Fprintf(os.Stderr, "%s: two files are required for diff\n", name)
+		return 2
+	}
+	identical, err := window.DiffFiles(os.Stdout, args[0], args[1])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", name, err)
+		return 2
+	}
+	if !identical {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/itchyny/bed/tui"
+)
+
+// runDump writes the formatted bytes of the file without the editor;
+// bed dump [--offset offset] [--length length] [--width width] [--format hex|c|go] file
+func runDump(args []string) int {
+	offset, length, width, format := int64(0), int64(-1), int64(16), "hex"
+	var file string
+	for i := 0; i < len(args); i++ {
+		switch arg := args[i]; arg {
+		case "--offset", "--length", "--width", "--format":
+			if i++; i == len(args) {
+				fmt.Fprintf(os.Stderr, "%s: an argument is required for %s\n", name, arg)
+				return 1
+			}
+			if arg == "--format" {
+				format = args[i]
+				continue
+			}
+			v, err := strconv.ParseInt(args[i], 0, 64)
+			if err != nil || v < 0 || arg == "--width" && (v == 0 || v > tui.MaxDumpWidth) {
+				fmt.Fprintf(os.Stderr, "%s: invalid argument for %s: %s\n", name, arg, args[i])
+				return 1
+			}
+			switch arg {
+			case "--offset":
+				offset = v
+			case "--length":
+				length = v
+			default:
+				width = v
+			}
+		default:
+			if file != "" {
+				fmt.Fprintf(os.Stderr, "%s: too many files\n", name)
+				return 1
+			}
+			file = arg
+		}
+	}
+	if file == "" {
+		fmt.Fprintf(os.Stderr, "%s: a file is required for dump\n", name)
+		return 1
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", name, err)
+		return 1
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", name, err)
+		return 1
+	}
+	if err := tui.Dump(os.Stdout, f, fi.Size(), offset, length, int(width), format); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", name, err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/itchyny/bed/window"
+)
+
+// runPatch overwrites the bytes of the file in place without the editor;
+// bed patch [--at offset --write hex]... [--spec file] file
+func runPatch(args []string) int {
+	var pairs [][2]string
+	var spec, file, at string
+	for i := 0; i < len(args); i++ {
+		switch arg := args[i]; arg {
+		case "--at", "--write", "--spec":
+			if i++; i == len(args) {
+				fmt.Fprintf(os.Stderr, "%s: an argument is required for %s\n", name, arg)
+				return 1
+			}
+			switch arg {
+			case "--at":
+				at = args[i]
+			case "--write":
+				if at == "" {
+					fmt.Fprintf(os.Stderr, "%s: --at is required for --write\n", name)
+					return 1
+				}
+				pairs, at = append(pairs, [2]string{at, args[i]}), ""
+			default:
+				spec = args[i]
+			}
+		default:
+			if file != "" {
+				fmt.Fprintf(os.Stderr, "%s: too many files\n", name)
+				return 1
+			}
+			file = arg
+		}
+	}
+	if at != "" {
+		fmt.Fprintf(os.Stderr, "%s: --write is required for --at\n", name)
+		return 1
+	}
+	if file == "" {
+		fmt.Fprintf(os.Stderr, "%s: a file is required for patch\n", name)
+		return 1
+	}
+	if _, err := window.PatchFile(file, pairs, spec); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", name, err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"runtime/trace"
+)
+
+// profiler writes the profiles of the editor to the files specified by
+// --cpuprofile, --memprofile and --trace.
+type profiler struct {
+	cpuProfile string
+	memProfile string
+	trace      string
+	files      []*os.File
+}
+
+// start starts the cpu profiling and the execution tracing.
+func (p *profiler) start() error {
+	if p.cpuProfile != "" {
+		f, err := p.create(p.cpuProfile)
+		if err != nil {
+			return err
+		}
+		if err := pprof.StartCPUProfile(f); err != nil {
+			return err
+		}
+	}
+	if p.trace != "" {
+		f, err := p.create(p.trace)
+		if err != nil {
+			return err
+		}
+		if err := trace.Start(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *profiler) create(name string) (*os.File, error) {
+	f, err := os.Create(name)
+	if err != nil {
+		return nil, err
+	}
+	p.files = append(p.files, f)
+	return f, nil
+}
+
+// stop stops the profiling, and writes the heap profile.
+func (p *profiler) stop() error {
+	if p.cpuProfile != "" {
+		pprof.StopCPUProfile()
+	}
+	if p.trace != "" {
+		trace.Stop()
+	}
+	var err error
+	if p.memProfile != "" {
+		var f *os.File
+		if f, err = p.create(p.memProfile); err == nil {
+			runtime.GC()
+			err = pprof.WriteHeapProfile(f)
+		}
+	}
+	for _, f := range p.files {
+		if cerr := f.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
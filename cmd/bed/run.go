@@ -6,27 +6,194 @@ import (
 
 	"github.com/itchyny/bed/cmdline"
 	"github.com/itchyny/bed/editor"
+	"github.com/itchyny/bed/record"
+	"github.com/itchyny/bed/remote"
+	"github.com/itchyny/bed/script"
 	"github.com/itchyny/bed/tui"
 	"github.com/itchyny/bed/window"
 )
 
-func run(args []string) int {
-	if len(args) > 2 {
+// run the subcommand of the first argument, or the editor. The arguments
+// after -- are the files, so that the files named like the subcommands or the
+// flags can be opened (bed -- patch).
+func run(args []string) (code int) {
+	if len(args) > 1 {
+		switch args[1] {
+		case "patch":
+			return runPatch(args[2:])
+		case "dump":
+			return runDump(args[2:])
+		case "diff":
+			return runDiff(args[2:])
+		}
+	}
+	var readonly, recovery, lock bool
+	var scriptFile, listenAddr, serverAddr, remoteCmd, recordFile, replayFile string
+	var prof profiler
+	var files []string
+	for i := 1; i < len(args); i++ {
+		switch arg := args[i]; arg {
+		case "--":
+			files, i = append(files, args[i+1:]...), len(args)
+		case "-R":
+			readonly = true
+		case "-r":
+			recovery = true
+		case "-lock":
+			lock = true
+		case "--script", "--listen", "--server", "--remote-send",
+			"--cpuprofile", "--memprofile", "--trace", "--record", "--replay":
+			if i++; i == len(args) {
+				fmt.Fprintf(os.Stderr, "%s: an argument is required for %s\n", name, arg)
+				return 1
+			}
+			switch arg {
+			case "--script":
+				scriptFile = args[i]
+			case "--listen":
+				listenAddr = args[i]
+			case "--server":
+				serverAddr = args[i]
+			case "--cpuprofile":
+				prof.cpuProfile = args[i]
+			case "--memprofile":
+				prof.memProfile = args[i]
+			case "--trace":
+				prof.trace = args[i]
+			case "--record":
+				recordFile = args[i]
+			case "--replay":
+				replayFile = args[i]
+			default:
+				remoteCmd = args[i]
+			}
+		default:
+			files = append(files, arg)
+		}
+	}
+	if remoteCmd != "" {
+		if serverAddr == "" {
+			fmt.Fprintf(os.Stderr, "%s: --server is required for --remote-send\n", name)
+			return 1
+		}
+		if err := remote.Send(serverAddr, remoteCmd); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", name, err)
+			return 1
+		}
+		return 0
+	}
+	if len(files) > 1 {
 		fmt.Fprintf(os.Stderr, "%s: too many files\n", name)
 		return 1
 	}
-	editor := editor.NewEditor(
-		tui.NewTui(), window.NewManager(), cmdline.NewCmdline(),
-	)
+	if recordFile != "" && replayFile != "" {
+		fmt.Fprintf(os.Stderr, "%s: --record and --replay cannot be used together\n", name)
+		return 1
+	}
+	if err := prof.start(); err != nil {
+		_ = prof.stop()
+		fmt.Fprintf(os.Stderr, "%s: %s\n", name, err)
+		return 1
+	}
+	defer func() {
+		if err := prof.stop(); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", name, err)
+			code = 1
+		}
+	}()
+	cmdline := cmdline.NewCmdline()
+	var ui editor.UI
+	if scriptFile != "" {
+		f, err := os.Open(scriptFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", name, err)
+			return 1
+		}
+		defer f.Close()
+		ui = script.NewScript(f, scriptFile, cmdline.Parse)
+	} else {
+		// the command line history is not loaded on recording and replaying,
+		// so that the recorded keys execute the same commands
+		if recordFile == "" && replayFile == "" {
+			if err := cmdline.LoadHistory(""); err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %s\n", name, err)
+				return 1
+			}
+			if err := cmdline.LoadSearchHistory(""); err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %s\n", name, err)
+				return 1
+			}
+		}
+		ui = tui.NewTui()
+	}
+	if recordFile != "" {
+		f, err := os.Create(recordFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", name, err)
+			return 1
+		}
+		defer f.Close()
+		ui = record.NewRecorder(ui, f)
+	} else if replayFile != "" {
+		f, err := os.Open(replayFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", name, err)
+			return 1
+		}
+		defer f.Close()
+		ui = record.NewReplayer(ui, f, replayFile)
+	}
+	editor := editor.NewEditor(ui, window.NewManager(), cmdline)
 	if err := editor.Init(); err != nil {
 		fmt.Fprintf(os.Stderr, "%s: %s\n", name, err)
 		return 1
 	}
-	if len(args) > 1 {
-		if err := editor.Open(args[1]); err != nil {
+	if listenAddr != "" {
+		server, err := remote.Listen(listenAddr, cmdline.Parse, editor.State)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", name, err)
+			return 1
+		}
+		editor.AddEventSource(server)
+	}
+	if err := editor.LoadConfig(""); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", name, err)
+		return 1
+	}
+	if err := editor.LoadPlugins(""); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", name, err)
+		return 1
+	}
+	if err := editor.LoadScript(""); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", name, err)
+		return 1
+	}
+	if readonly {
+		if err := editor.SetOption("readonly"); err != nil {
 			fmt.Fprintf(os.Stderr, "%s: %s\n", name, err)
 			return 1
 		}
+	}
+	if lock {
+		if err := editor.SetOption("lock"); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", name, err)
+			return 1
+		}
+	}
+	if len(files) > 0 {
+		if err := editor.Open(files[0]); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", name, err)
+			return 1
+		}
+		if recovery {
+			if err := editor.Recover(); err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %s\n", name, err)
+				return 1
+			}
+		}
+	} else if recovery {
+		fmt.Fprintf(os.Stderr, "%s: a file is required for -r\n", name)
+		return 1
 	} else {
 		if err := editor.OpenEmpty(); err != nil {
 			fmt.Fprintf(os.Stderr, "%s: %s\n", name, err)
@@ -35,6 +202,7 @@ func run(args []string) int {
 	}
 	if err := editor.Run(); err != nil {
 		fmt.Fprintf(os.Stderr, "%s: %s\n", name, err)
+		printRecovery(err)
 		return 1
 	}
 	if err := editor.Close(); err != nil {
@@ -43,3 +211,19 @@ func run(args []string) int {
 	}
 	return 0
 }
+
+// printRecovery prints how to recover the unsaved changes after the crash or
+// the termination by the signal.
+func printRecovery(err error) {
+	var files []string
+	switch err := err.(type) {
+	case *editor.CrashError:
+		files = err.Files
+	case *editor.SignalError:
+		files = err.Files
+	}
+	for _, file := range files {
+		fmt.Fprintf(os.Stderr, "%s: the unsaved changes of %s are kept in the swap file; run %s -r %s to recover them\n",
+			name, file, name, file)
+	}
+}
@@ -1,11 +1,14 @@
 package cmdline
 
 import (
+	"strings"
 	"sync"
 	"unicode"
 
 	"github.com/itchyny/bed/event"
+	"github.com/itchyny/bed/expr"
 	"github.com/itchyny/bed/mathutil"
+	"github.com/itchyny/bed/option"
 )
 
 // Cmdline implements editor.Cmdline
@@ -15,7 +18,13 @@ type Cmdline struct {
 	completor         *completor
 	completionResults []string
 	completionIndex   int
+	history           *history
+	searchHistory     *history
+	searchHistoryPath string
+	options           *option.Options
+	userCommands      *userCommands
 	typ               rune
+	passphraseCmd     string
 	eventCh           chan<- event.Event
 	cmdlineCh         <-chan event.Event
 	redrawCh          chan<- struct{}
@@ -25,8 +34,11 @@ type Cmdline struct {
 // NewCmdline creates a new Cmdline.
 func NewCmdline() *Cmdline {
 	return &Cmdline{
-		completor: newCompletor(&filesystem{}),
-		mu:        new(sync.Mutex),
+		completor:     newCompletor(&filesystem{}),
+		history:       newHistory(),
+		searchHistory: newHistory(),
+		userCommands:  newUserCommands(),
+		mu:            new(sync.Mutex),
 	}
 }
 
@@ -35,6 +47,44 @@ func (c *Cmdline) Init(eventCh chan<- event.Event, cmdlineCh <-chan event.Event,
 	c.eventCh, c.cmdlineCh, c.redrawCh = eventCh, cmdlineCh, redrawCh
 }
 
+// LoadHistory loads the command history file and saves the history to the
+// file on executing commands. When the filename is empty, it uses the default
+// history file.
+func (c *Cmdline) LoadHistory(filename string) error {
+	if filename == "" {
+		if filename = historyPath(); filename == "" {
+			return nil
+		}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.history.load(filename)
+}
+
+// LoadSearchHistory loads the search history file, which is shared by the
+// forward and backward searches. The history is saved to the file on searching
+// when the searchhistory option is enabled. When the filename is empty, it uses
+// the default file in the configuration directory.
+func (c *Cmdline) LoadSearchHistory(filename string) error {
+	if filename == "" {
+		if filename = searchHistoryPath(); filename == "" {
+			return nil
+		}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	err := c.searchHistory.load(filename)
+	c.searchHistory.path, c.searchHistoryPath = "", filename
+	return err
+}
+
+// SetOptions sets the options of the editor.
+func (c *Cmdline) SetOptions(options *option.Options) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.options = options
+}
+
 // Run the cmdline.
 func (c *Cmdline) Run() {
 	for e := range c.cmdlineCh {
@@ -49,6 +99,12 @@ func (c *Cmdline) Run() {
 		case event.StartCmdlineSearchBackward:
 			c.typ = '?'
 			c.clear()
+		case event.StartCmdlineExpression:
+			c.typ = '='
+			c.clear()
+		case event.StartCmdlinePassphrase:
+			c.typ, c.passphraseCmd = '*', e.CmdName
+			c.clear()
 		case event.ExitCmdline:
 			c.clear()
 		case event.CursorLeft:
@@ -81,6 +137,16 @@ func (c *Cmdline) Run() {
 			c.redrawCh <- struct{}{}
 			c.mu.Unlock()
 			continue
+		case event.PrevHistoryCmdline:
+			c.prevHistory()
+			c.redrawCh <- struct{}{}
+			c.mu.Unlock()
+			continue
+		case event.NextHistoryCmdline:
+			c.nextHistory()
+			c.redrawCh <- struct{}{}
+			c.mu.Unlock()
+			continue
 		case event.ExecuteCmdline:
 			c.execute()
 		default:
@@ -88,6 +154,8 @@ func (c *Cmdline) Run() {
 			continue
 		}
 		c.completor.clear()
+		c.history.reset()
+		c.searchHistory.reset()
 		c.mu.Unlock()
 		c.redrawCh <- struct{}{}
 	}
@@ -165,7 +233,49 @@ func (c *Cmdline) insert(ch rune) {
 	}
 }
 
+// currentHistory returns the history of the command line type.
+func (c *Cmdline) currentHistory() *history {
+	switch c.typ {
+	case ':':
+		return c.history
+	case '/', '?':
+		return c.searchHistory
+	}
+	return nil
+}
+
+func (c *Cmdline) prevHistory() {
+	h := c.currentHistory()
+	if h == nil {
+		return
+	}
+	c.completor.clear()
+	if line, ok := h.prev(string(c.cmdline)); ok {
+		c.start(line)
+	}
+}
+
+func (c *Cmdline) nextHistory() {
+	h := c.currentHistory()
+	if h == nil {
+		return
+	}
+	c.completor.clear()
+	if line, ok := h.next(); ok {
+		c.start(line)
+	}
+}
+
 func (c *Cmdline) complete(forward bool) {
+	if c.typ != ':' {
+		return
+	}
+	if prefix, name, ok := parseCommandName(c.cmdline); ok {
+		c.cmdline = []rune(c.completor.completeCommandNames(
+			string(c.cmdline), prefix, name, forward))
+		c.cursor = len(c.cmdline)
+		return
+	}
 	cmd, _, prefix, arg, err := parse(c.cmdline)
 	if err != nil {
 		c.completor.clear()
@@ -179,26 +289,68 @@ func (c *Cmdline) complete(forward bool) {
 func (c *Cmdline) execute() {
 	switch c.typ {
 	case ':':
-		cmd, r, _, arg, err := parse(c.cmdline)
+		herr := c.history.add(string(c.cmdline))
+		e, err := c.Parse(string(c.cmdline))
 		if err != nil {
 			c.eventCh <- event.Event{Type: event.Error, Error: err}
 			return
 		}
-		if cmd.name != "" {
-			c.eventCh <- event.Event{Type: cmd.eventType, Range: r, CmdName: cmd.name, Arg: arg}
+		if e.Type != event.Nop {
+			c.eventCh <- e
 		}
-	case '/':
-		c.eventCh <- event.Event{Type: event.ExecuteSearch, Arg: string(c.cmdline), Rune: '/'}
-	case '?':
-		c.eventCh <- event.Event{Type: event.ExecuteSearch, Arg: string(c.cmdline), Rune: '?'}
+		if herr != nil {
+			c.eventCh <- event.Event{Type: event.Error, Error: herr}
+		}
+	case '/', '?':
+		c.searchHistory.path = ""
+		if c.options != nil && c.options.Bool("searchhistory") {
+			c.searchHistory.path = c.searchHistoryPath
+		}
+		herr := c.searchHistory.add(string(c.cmdline))
+		c.eventCh <- event.Event{Type: event.ExecuteSearch, Arg: string(c.cmdline), Rune: c.typ}
+		if herr != nil {
+			c.eventCh <- event.Event{Type: event.Error, Error: herr}
+		}
+	case '=':
+		v, err := expr.Eval(string(c.cmdline))
+		if err != nil {
+			c.eventCh <- event.Event{Type: event.Error, Error: err}
+			return
+		}
+		c.eventCh <- event.Event{Type: event.InsertExpression, Bytes: expr.Bytes(v)}
+	case '*':
+		// the passphrase is not kept in the history nor the command line
+		bs := []byte(string(c.cmdline))
+		c.clear()
+		c.eventCh <- event.Event{Type: event.SetPassphrase, CmdName: c.passphraseCmd, Bytes: bs}
 	default:
 		panic("cmdline.Cmdline.execute: unreachable")
 	}
 }
 
+// Parse the command line and returns the event of the command.
+func (c *Cmdline) Parse(line string) (event.Event, error) {
+	if e, ok := c.parseUserCommand(line); ok {
+		return e, nil
+	}
+	cmd, r, prefix, arg, err := parse([]rune(line))
+	if err != nil {
+		return event.Event{}, err
+	}
+	if cmd.eventType == event.CursorGoto && arg != "" {
+		if r, err = parseGotoArg(cmd, arg); err != nil {
+			return event.Event{}, err
+		}
+	}
+	bang := strings.HasSuffix(strings.TrimRightFunc(prefix, unicode.IsSpace), "!")
+	return event.Event{Type: cmd.eventType, Range: r, CmdName: cmd.name, Bang: bang, Arg: arg}, nil
+}
+
 // Get returns the current state of cmdline.
 func (c *Cmdline) Get() ([]rune, int, []string, int) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	return c.cmdline, c.cursor, c.completor.results, c.completor.index
+	cmdline := make([]rune, len(c.cmdline))
+	copy(cmdline, c.cmdline)
+	return cmdline, c.cursor, c.completor.results, c.completor.index
 }
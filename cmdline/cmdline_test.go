@@ -1,10 +1,14 @@
 package cmdline
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
 
 	"github.com/itchyny/bed/event"
+	"github.com/itchyny/bed/option"
 )
 
 func TestNewCmdline(t *testing.T) {
@@ -340,6 +344,53 @@ func TestCmdlineExecuteQuitAll(t *testing.T) {
 	}
 }
 
+func TestCmdlineParseBang(t *testing.T) {
+	c := NewCmdline()
+	for _, cmd := range []struct {
+		cmd  string
+		typ  event.Type
+		bang bool
+	}{
+		{"q", event.Quit, false},
+		{"q!", event.Quit, true},
+		{"qa!", event.QuitAll, true},
+		{"e!", event.Edit, true},
+		{"w! sample.txt", event.Write, true},
+		{"w !sample.txt", event.Write, false},
+	} {
+		e, err := c.Parse(cmd.cmd)
+		if err != nil {
+			t.Errorf("err should be nil but got: %v", err)
+		}
+		if e.Type != cmd.typ {
+			t.Errorf("cmdline should emit %d event with %q but got %d", cmd.typ, cmd.cmd, e.Type)
+		}
+		if e.Bang != cmd.bang {
+			t.Errorf("e.Bang should be %v with %q but got %v", cmd.bang, cmd.cmd, e.Bang)
+		}
+	}
+	if _, err := c.Parse("set! ruler"); err == nil || err.Error() != "! not allowed for se[t]" {
+		t.Errorf("err should be %q but got: %v", "! not allowed for se[t]", err)
+	}
+}
+
+func TestCmdlineParseSubstitute(t *testing.T) {
+	c := NewCmdline()
+	e, err := c.Parse(":%s/foo/bar/gc")
+	if err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	if e.Type != event.Substitute || e.Arg != "/foo/bar/gc" {
+		t.Errorf("cmdline should emit Substitute event but got: %+v", e)
+	}
+	if !reflect.DeepEqual(e.Range, &event.Range{From: event.Absolute{}, To: event.End{}}) {
+		t.Errorf("range should be the whole buffer but got: %+v", e.Range)
+	}
+	if e, err = c.Parse("s #a b#c"); err != nil || e.Type != event.Substitute || e.Range != nil || e.Arg != "#a b#c" {
+		t.Errorf("cmdline should emit Substitute event but got: %+v, %v", e, err)
+	}
+}
+
 func TestCmdlineExecuteWrite(t *testing.T) {
 	c := NewCmdline()
 	ch := make(chan event.Event, 1)
@@ -377,8 +428,6 @@ func TestCmdlineExecuteWriteQuit(t *testing.T) {
 		{"wq", "wq"},
 		{"x", "x[it]"},
 		{"xit", "x[it]"},
-		{"xa", "xa[ll]"},
-		{"xall", "xa[ll]"},
 	} {
 		c.clear()
 		c.cmdline = []rune(cmd.cmd)
@@ -392,6 +441,27 @@ func TestCmdlineExecuteWriteQuit(t *testing.T) {
 			t.Errorf("cmdline should emit WriteQuit event with %q", cmd.cmd)
 		}
 	}
+	for _, cmd := range []struct {
+		cmd  string
+		name string
+	}{
+		{"wqa", "wqa[ll]"},
+		{"wqall", "wqa[ll]"},
+		{"xa", "xa[ll]"},
+		{"xall", "xa[ll]"},
+	} {
+		c.clear()
+		c.cmdline = []rune(cmd.cmd)
+		c.typ = ':'
+		c.execute()
+		e := <-ch
+		if e.CmdName != cmd.name {
+			t.Errorf("cmdline should report command name %q but got %q", cmd.name, e.CmdName)
+		}
+		if e.Type != event.WriteQuitAll {
+			t.Errorf("cmdline should emit WriteQuitAll event with %q", cmd.cmd)
+		}
+	}
 }
 
 func TestCmdlineExecuteGoto(t *testing.T) {
@@ -429,6 +499,77 @@ func TestCmdlineExecuteGoto(t *testing.T) {
 	}
 }
 
+func TestCmdlineExecuteGotoExpression(t *testing.T) {
+	c := NewCmdline()
+	ch := make(chan event.Event, 1)
+	c.Init(ch, make(chan event.Event), make(chan struct{}))
+	for _, cmd := range []struct {
+		cmd  string
+		pos  event.Position
+		name string
+	}{
+		{"0x400+3*0x20", event.Absolute{0x460}, "goto"},
+		{"goto 0x400+3*0x20", event.Absolute{0x460}, "go[to]"},
+		{"go $-(0x10<<4)", event.End{-0x100}, "go[to]"},
+		{"50%", event.Percent{50, 0}, "goto"},
+		{"goto 10%-0x10", event.Percent{10, -0x10}, "go[to]"},
+	} {
+		c.clear()
+		c.cmdline = []rune(cmd.cmd)
+		c.typ = ':'
+		c.execute()
+		e := <-ch
+		if e.CmdName != cmd.name {
+			t.Errorf("cmdline should report command name %q but got %q", cmd.name, e.CmdName)
+		}
+		if e.Type != event.CursorGoto || !reflect.DeepEqual(e.Range.From, cmd.pos) {
+			t.Errorf("cmdline should report command with position %#v but got %#v", cmd.pos, e.Range.From)
+		}
+	}
+
+	c.clear()
+	c.cmdline = []rune("goto 0x10/0")
+	c.execute()
+	if e := <-ch; e.Type != event.Error || e.Error.Error() != "division by zero" {
+		t.Errorf("cmdline should emit an error but got %+v", e)
+	}
+}
+
+func TestCmdlineExecuteExpression(t *testing.T) {
+	c := NewCmdline()
+	ch := make(chan event.Event, 1)
+	c.Init(ch, make(chan event.Event), make(chan struct{}))
+	c.typ = '='
+	c.cmdline = []rune("0xdead^0xbeef")
+	c.execute()
+	e := <-ch
+	if e.Type != event.InsertExpression || !reflect.DeepEqual(e.Bytes, []byte{0x60, 0x42}) {
+		t.Errorf("cmdline should emit InsertExpression event but got %+v", e)
+	}
+
+	c.cmdline = []rune("0xdead^")
+	c.execute()
+	if e := <-ch; e.Type != event.Error || e.Error.Error() != "invalid expression: 0xdead^" {
+		t.Errorf("cmdline should emit an error but got %+v", e)
+	}
+}
+
+func TestCmdlineExecutePassphrase(t *testing.T) {
+	c := NewCmdline()
+	ch := make(chan event.Event, 1)
+	c.Init(ch, make(chan event.Event), make(chan struct{}))
+	c.typ, c.passphraseCmd = '*', "encrypt"
+	c.cmdline = []rune("secret")
+	c.execute()
+	e := <-ch
+	if e.Type != event.SetPassphrase || e.CmdName != "encrypt" || string(e.Bytes) != "secret" {
+		t.Errorf("cmdline should emit SetPassphrase event but got %+v", e)
+	}
+	if len(c.cmdline) != 0 || len(c.history.entries) != 0 {
+		t.Errorf("passphrase should not be kept but got %q, %q", string(c.cmdline), c.history.entries)
+	}
+}
+
 func TestCmdlineComplete(t *testing.T) {
 	c := NewCmdline()
 	c.completor = newCompletor(&mockFilesystem{})
@@ -571,3 +712,181 @@ func TestCmdlineSearch(t *testing.T) {
 		t.Errorf("cmdline should emit search event with Rune %q but got %q", '?', e.Rune)
 	}
 }
+
+func TestCmdlineHistory(t *testing.T) {
+	c := NewCmdline()
+	ch := make(chan event.Event, 1)
+	c.Init(ch, make(chan event.Event), make(chan struct{}))
+	c.typ = ':'
+	for _, cmd := range []string{"undo", "set ruler", "redo"} {
+		c.start(cmd)
+		c.execute()
+		<-ch
+	}
+	c.clear()
+	c.prevHistory()
+	if string(c.cmdline) != "redo" || c.cursor != 4 {
+		t.Errorf("cmdline should be %q but got %q", "redo", string(c.cmdline))
+	}
+	c.prevHistory()
+	c.prevHistory()
+	c.prevHistory()
+	if string(c.cmdline) != "undo" {
+		t.Errorf("cmdline should be %q but got %q", "undo", string(c.cmdline))
+	}
+	c.nextHistory()
+	c.nextHistory()
+	c.nextHistory()
+	if string(c.cmdline) != "" {
+		t.Errorf("cmdline should be %q but got %q", "", string(c.cmdline))
+	}
+	c.history.reset()
+	c.start("s")
+	c.prevHistory()
+	if string(c.cmdline) != "set ruler" {
+		t.Errorf("cmdline should be %q but got %q", "set ruler", string(c.cmdline))
+	}
+}
+
+func TestCmdlineSearchHistory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bed-cmdline")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "search_history")
+	c := NewCmdline()
+	ch := make(chan event.Event, 1)
+	c.Init(ch, make(chan event.Event), make(chan struct{}))
+	if err := c.LoadSearchHistory(filename); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	options := option.New()
+	c.SetOptions(options)
+	for _, typ := range []rune{'/', '?'} {
+		c.typ = typ
+		c.start(string(typ) + "abc")
+		c.execute()
+		<-ch
+	}
+	if _, err := os.Stat(filename); !os.IsNotExist(err) {
+		t.Errorf("search history should not be saved without the option: %v", err)
+	}
+	c.typ = ':'
+	c.start("undo")
+	c.execute()
+	<-ch
+	c.typ = '/'
+	c.clear()
+	c.prevHistory()
+	if string(c.cmdline) != "?abc" {
+		t.Errorf("cmdline should be %q but got %q", "?abc", string(c.cmdline))
+	}
+	c.prevHistory()
+	if string(c.cmdline) != "/abc" {
+		t.Errorf("cmdline should be %q but got %q", "/abc", string(c.cmdline))
+	}
+	if err := options.Set("searchhistory"); err != nil {
+		t.Fatal(err)
+	}
+	c.start("xyz")
+	c.execute()
+	<-ch
+	c = NewCmdline()
+	if err := c.LoadSearchHistory(filename); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	c.typ = '?'
+	c.prevHistory()
+	if string(c.cmdline) != "xyz" {
+		t.Errorf("cmdline should be %q but got %q", "xyz", string(c.cmdline))
+	}
+	c.prevHistory()
+	if string(c.cmdline) != "?abc" {
+		t.Errorf("cmdline should be %q but got %q", "?abc", string(c.cmdline))
+	}
+}
+
+func TestCmdlineUserCommand(t *testing.T) {
+	c := NewCmdline()
+	if err := c.DefineCommand("Head", "goto 0 | echo <args>"); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	for _, name := range []string{"head", "Head!", ""} {
+		if err := c.DefineCommand(name, "goto 0"); err == nil {
+			t.Errorf("DefineCommand(%q) should return an error", name)
+		}
+	}
+	e, err := c.Parse(":Head 1 + 2")
+	if err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	if e.Type != event.UserCommand || e.CmdName != "Head" || e.Arg != "goto 0 | echo 1 + 2" {
+		t.Errorf("user command should be parsed but got: %+v", e)
+	}
+	if cmds := c.UserCommands(); len(cmds) != 1 || cmds[0] != "Head         goto 0 | echo <args>" {
+		t.Errorf("user commands should be listed but got: %q", cmds)
+	}
+	if err := c.DeleteCommand("Head"); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	if _, err := c.Parse("Head"); err == nil || err.Error() != "unknown command: Head" {
+		t.Errorf("err should be %q but got: %v", "unknown command: Head", err)
+	}
+	if err := c.DeleteCommand("Head"); err == nil || err.Error() != "no such user command: Head" {
+		t.Errorf("err should be %q but got: %v", "no such user command: Head", err)
+	}
+}
+
+func TestCmdlineProgramCommand(t *testing.T) {
+	c := NewCmdline()
+	if err := c.DefineCommand("Chunk", "goto 0"); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	if err := c.DefineProgram("Chunk", "/plugins/png/chunk"); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	if err := c.DefineProgram("Chunk", ""); err == nil || err.Error() != "program is empty: Chunk" {
+		t.Errorf("err should be %q but got: %v", "program is empty: Chunk", err)
+	}
+	e, err := c.Parse("Chunk IHDR  IEND")
+	if err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	if e.Type != event.PluginCommand || e.CmdName != "Chunk" || e.Arg != "IHDR  IEND" {
+		t.Errorf("program command should be parsed but got: %+v", e)
+	}
+	if cmds := c.UserCommands(); len(cmds) != 1 || cmds[0] != "Chunk        !/plugins/png/chunk" {
+		t.Errorf("user commands should be listed but got: %q", cmds)
+	}
+	if err := c.DeleteCommand("Chunk"); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	if cmds := c.UserCommands(); len(cmds) != 0 {
+		t.Errorf("user commands should be deleted but got: %q", cmds)
+	}
+}
+
+// FuzzCmdlineParse checks that parsing the command line never panics, and the
+// leading colon does not change the command.
+func FuzzCmdlineParse(f *testing.F) {
+	for _, line := range []string{
+		"", "w", "wq!", "10,20w foo.bin", "'<,'>fill 0x00", "%s/foo/bar/gc",
+		".+3", "$-0x10", "goto 0x100", `searchall "a b"`, `insertbytes 2 \u{3042}`,
+		"set recordsize=24", "q!!", "'a,'b", "/\x7fELF", ":::",
+	} {
+		f.Add(line)
+	}
+	f.Fuzz(func(t *testing.T, line string) {
+		c := NewCmdline()
+		e, err := c.Parse(line)
+		if err != nil {
+			return
+		}
+		if g, err := c.Parse(":" + line); err != nil || !reflect.DeepEqual(e, g) {
+			t.Errorf("parse result should not change with the colon: %+v, %+v, %v", e, g, err)
+		}
+		_, _ = event.SplitArgs(e.Arg)
+		_, _ = event.UnquoteArg(e.Arg)
+	})
+}
@@ -7,12 +7,85 @@ type command struct {
 	eventType event.Type
 }
 
+// allowBang reports whether the command accepts ! after the name.
+func (cmd command) allowBang() bool {
+	switch cmd.eventType {
+	case event.Edit, event.Quit, event.QuitAll, event.Write, event.WriteQuit, event.WriteQuitAll:
+		return true
+	}
+	return false
+}
+
 var commands = []command{
 	{"e[dit]", event.Edit},
 	{"new", event.New},
 	{"vne[w]", event.Vnew},
+	{"sp[lit]", event.Split},
+	{"vs[plit]", event.Vsplit},
 	{"winc[md]", event.Wincmd},
 
+	{"go[to]", event.CursorGoto},
+	{"ins[ertbytes]", event.InsertBytes},
+	{"cop[y]", event.Copy},
+	{"disas[semble]", event.Disassemble},
+	{"plot", event.Plot},
+	{"previ[ew]", event.Preview},
+	{"fixs[um]", event.FixChecksum},
+	{"fil[l]", event.Fill},
+	{"deflate", event.Compress},
+	{"inflate", event.Compress},
+	{"gzip", event.Compress},
+	{"gunzip", event.Compress},
+	{"zstd", event.Compress},
+	{"unzstd", event.Compress},
+	{"s[ubstitute]", event.Substitute},
+
+	{"bookm[ark]", event.Bookmark},
+	{"bookmarks", event.Bookmarks},
+	{"gotob[ookmark]", event.GotoBookmark},
+	{"delb[ookmark]", event.DeleteBookmark},
+	{"annot[ate]", event.Annotate},
+	{"annotations", event.Annotations},
+	{"dela[nnotation]", event.DeleteAnnotation},
+	{"hi[ghlight]", event.Highlight},
+	{"nohi[ghlight]", event.NoHighlight},
+	{"changes", event.Changes},
+	{"undol[ist]", event.UndoList},
+	{"undop[artial]", event.UndoPartial},
+	{"searcha[ll]", event.SearchAll},
+	{"str[ings]", event.Strings},
+	{"sections", event.Sections},
+	{"sec[tion]", event.GotoSection},
+	{"packets", event.Packets},
+	{"file", event.Identify},
+	{"scan", event.Scan},
+	{"carve", event.Carve},
+	{"xorscan", event.XorScan},
+	{"comparestat", event.CompareStat},
+	{"overlay", event.Overlay},
+	{"overlays", event.Overlays},
+	{"toggleo[verlay]", event.ToggleOverlay},
+	{"commito[verlay]", event.CommitOverlay},
+	{"discardo[verlay]", event.DiscardOverlay},
+	{"cl[ist]", event.QuickfixList},
+	{"cc", event.QuickfixGoto},
+	{"cn[ext]", event.QuickfixNext},
+	{"cN[ext]", event.QuickfixPrevious},
+	{"cp[revious]", event.QuickfixPrevious},
+	{"cfir[st]", event.QuickfixFirst},
+	{"cla[st]", event.QuickfixLast},
+	{"se[t]", event.Set},
+	{"colo[rscheme]", event.ColorScheme},
+	{"map", event.Map},
+	{"nm[ap]", event.Map},
+	{"vm[ap]", event.Map},
+	{"im[ap]", event.Map},
+	{"ec[ho]", event.Echo},
+	{"com[mand]", event.DefineCommand},
+	{"delc[ommand]", event.DeleteCommand},
+	{"plug[ins]", event.Plugins},
+	{"deb[ug]", event.Debug},
+
 	{"u[ndo]", event.Undo},
 	{"red[o]", event.Redo},
 
@@ -23,5 +96,9 @@ var commands = []command{
 	{"w[rite]", event.Write},
 	{"wq", event.WriteQuit},
 	{"x[it]", event.WriteQuit},
-	{"xa[ll]", event.WriteQuit},
+	{"wqa[ll]", event.WriteQuitAll},
+	{"xa[ll]", event.WriteQuitAll},
+	{"rec[over]", event.Recover},
+	{"encrypt", event.Encrypt},
+	{"passphrase", event.StartCmdlinePassphrase},
 }
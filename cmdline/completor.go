@@ -9,6 +9,7 @@ import (
 	"github.com/mitchellh/go-homedir"
 
 	"github.com/itchyny/bed/event"
+	"github.com/itchyny/bed/option"
 )
 
 type completor struct {
@@ -32,10 +33,13 @@ func (c *completor) clear() {
 
 func (c *completor) complete(cmdline string, cmd command, prefix string, arg string, forward bool) string {
 	switch cmd.eventType {
-	case event.Edit, event.New, event.Vnew, event.Write:
+	case event.Edit, event.New, event.Vnew, event.Split, event.Vsplit, event.Write, event.Carve,
+		event.CompareStat, event.Overlay:
 		return c.completeFilepaths(cmdline, prefix, arg, forward)
 	case event.Wincmd:
 		return c.completeWincmd(cmdline, prefix, arg, forward)
+	case event.Set:
+		return c.completeOptions(cmdline, prefix, arg, forward)
 	default:
 		c.results = nil
 		c.index = 0
@@ -210,7 +214,86 @@ func (c *completor) completeWincmd(cmdline string, prefix string, arg string, fo
 		return cmdline
 	}
 	c.target = cmdline
-	c.results = []string{"n", "h", "l", "k", "j", "H", "L", "K", "J", "t", "b", "p"}
+	c.results = []string{"n", "h", "l", "k", "j", "H", "L", "K", "J", "t", "b", "p", "s", "v", "+", "-", ">", "<", "="}
 	c.index = -1
 	return cmdline
 }
+
+func (c *completor) completeCandidates(cmdline string, prefix string, arg string, candidates []string, forward bool) string {
+	if len(c.results) > 0 {
+		return c.completeNext(prefix, forward)
+	}
+	c.target = cmdline
+	c.arg = arg
+	c.results = candidates
+	if len(c.results) == 1 {
+		cmdline := prefix + c.arg + c.results[0]
+		c.results = nil
+		return cmdline
+	}
+	if len(c.results) > 1 {
+		if forward {
+			c.index = 0
+			return prefix + c.arg + c.results[0]
+		}
+		c.index = len(c.results) - 1
+		return prefix + c.arg + c.results[len(c.results)-1]
+	}
+	return cmdline
+}
+
+func (c *completor) completeCommandNames(cmdline string, prefix string, name string, forward bool) string {
+	var names []string
+	if len(c.results) == 0 {
+		for _, cmd := range commands {
+			if n := strings.Replace(strings.Replace(cmd.name, "[", "", 1), "]", "", 1); strings.HasPrefix(n, name) {
+				names = append(names, n)
+			}
+		}
+		sort.Strings(names)
+		names = uniqStrings(names)
+	}
+	return c.completeCandidates(cmdline, prefix, "", names, forward)
+}
+
+func (c *completor) completeOptions(cmdline string, prefix string, arg string, forward bool) string {
+	if !strings.HasSuffix(prefix, " ") {
+		prefix += " "
+	}
+	if strings.IndexByte(arg, '=') >= 0 {
+		return cmdline
+	}
+	var names []string
+	if strings.HasSuffix(cmdline, " ") && arg != "" {
+		arg += " "
+	}
+	i := strings.LastIndexByte(arg, ' ') + 1
+	if len(c.results) == 0 {
+		word := arg[i:]
+		for _, name := range option.Names() {
+			if strings.HasPrefix(name, word) {
+				names = append(names, name)
+			}
+			def, _ := option.Lookup(name)
+			if _, ok := def.Default.(bool); ok {
+				for _, p := range []string{"no", "inv"} {
+					if strings.HasPrefix(word, p) && strings.HasPrefix(name, word[len(p):]) {
+						names = append(names, p+name)
+					}
+				}
+			}
+		}
+		sort.Strings(names)
+	}
+	return c.completeCandidates(cmdline, prefix, arg[:i], names, forward)
+}
+
+func uniqStrings(xs []string) []string {
+	var ys []string
+	for i, x := range xs {
+		if i == 0 || xs[i-1] != x {
+			ys = append(ys, x)
+		}
+	}
+	return ys
+}
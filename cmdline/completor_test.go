@@ -286,3 +286,72 @@ func TestCompletorCompleteWincmd(t *testing.T) {
 		t.Errorf("completion index should be %d but got %d", 0, c.index)
 	}
 }
+
+func TestCompletorCompleteCommandNames(t *testing.T) {
+	c := newCompletor(&mockFilesystem{})
+	cmdline := "10,20wr"
+	prefix, name, _ := parseCommandName([]rune(cmdline))
+	if cmdline = c.completeCommandNames(cmdline, prefix, name, true); cmdline != "10,20write" {
+		t.Errorf("cmdline should be %q but got %q", "10,20write", cmdline)
+	}
+	if c.results != nil {
+		t.Errorf("completion results should be nil but got %v", c.results)
+	}
+
+	cmdline = "w"
+	prefix, name, _ = parseCommandName([]rune(cmdline))
+	for _, expected := range []string{"wincmd", "wq", "wqall", "write", "w"} {
+		if cmdline = c.completeCommandNames(cmdline, prefix, name, true); cmdline != expected {
+			t.Errorf("cmdline should be %q but got %q", expected, cmdline)
+		}
+	}
+	if c.index != -1 {
+		t.Errorf("completion index should be %d but got %d", -1, c.index)
+	}
+
+	c.clear()
+	cmdline = "qa"
+	prefix, name, _ = parseCommandName([]rune(cmdline))
+	cmdline = c.completeCommandNames(cmdline, prefix, name, false)
+	if cmdline != "qall" {
+		t.Errorf("cmdline should be %q but got %q", "qall", cmdline)
+	}
+
+	if _, _, ok := parseCommandName([]rune("set ")); ok {
+		t.Errorf("parseCommandName should return false for a command with an argument")
+	}
+}
+
+func TestCompletorCompleteOptions(t *testing.T) {
+	c := newCompletor(&mockFilesystem{})
+	cmdline := "set noruler w"
+	cmd, _, prefix, arg, _ := parse([]rune(cmdline))
+	for _, expected := range []string{"set noruler width", "set noruler wrapscan", "set noruler writeinplace", "set noruler w"} {
+		if cmdline = c.complete(cmdline, cmd, prefix, arg, true); cmdline != expected {
+			t.Errorf("cmdline should be %q but got %q", expected, cmdline)
+		}
+	}
+
+	c.clear()
+	cmdline = "se inv"
+	cmd, _, prefix, arg, _ = parse([]rune(cmdline))
+	for _, expected := range []string{"se invautowrite", "se invbackup", "se invdecompress", "se inverrorbells", "se invlock", "se invmodifiable", "se invnibble", "se invpreservemtime", "se invreadonly", "se invrelativeoffset", "se invruler", "se invsearchhistory", "se invsparse", "se invswapfile", "se invtable", "se invtitle", "se invtruecolor", "se invvisualbell", "se invwrapscan", "se invwriteinplace"} {
+		if cmdline = c.complete(cmdline, cmd, prefix, arg, true); cmdline != expected {
+			t.Errorf("cmdline should be %q but got %q", expected, cmdline)
+		}
+	}
+
+	c.clear()
+	cmdline = "set ruler "
+	cmd, _, prefix, arg, _ = parse([]rune(cmdline))
+	if cmdline = c.complete(cmdline, cmd, prefix, arg, false); cmdline != "set ruler writeinplace" {
+		t.Errorf("cmdline should be %q but got %q", "set ruler writeinplace", cmdline)
+	}
+
+	c.clear()
+	cmdline = "set stl=%f"
+	cmd, _, prefix, arg, _ = parse([]rune(cmdline))
+	if cmdline = c.complete(cmdline, cmd, prefix, arg, true); cmdline != "set stl=%f" {
+		t.Errorf("cmdline should be %q but got %q", "set stl=%f", cmdline)
+	}
+}
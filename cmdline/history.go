@@ -0,0 +1,139 @@
+package cmdline
+
+import (
+	"bufio"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mitchellh/go-homedir"
+)
+
+const maxHistory = 100
+
+type history struct {
+	entries []string
+	index   int
+	prefix  string
+	path    string
+}
+
+func newHistory() *history {
+	return &history{}
+}
+
+func (h *history) load(path string) error {
+	h.path = path
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if line := scanner.Text(); line != "" {
+			h.entries = append(h.entries, line)
+		}
+	}
+	if len(h.entries) > maxHistory {
+		h.entries = h.entries[len(h.entries)-maxHistory:]
+	}
+	h.reset()
+	return scanner.Err()
+}
+
+func (h *history) save() error {
+	if h.path == "" {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(h.path), 0755); err != nil {
+		return err
+	}
+	var sb strings.Builder
+	for _, line := range h.entries {
+		sb.WriteString(line)
+		sb.WriteByte('\n')
+	}
+	return ioutil.WriteFile(h.path, []byte(sb.String()), 0600)
+}
+
+// add the line to the history, moving the same entry to the newest.
+func (h *history) add(line string) error {
+	defer h.reset()
+	if strings.TrimSpace(line) == "" {
+		return nil
+	}
+	for i, entry := range h.entries {
+		if entry == line {
+			h.entries = append(h.entries[:i], h.entries[i+1:]...)
+			break
+		}
+	}
+	h.entries = append(h.entries, line)
+	if len(h.entries) > maxHistory {
+		h.entries = h.entries[len(h.entries)-maxHistory:]
+	}
+	return h.save()
+}
+
+func (h *history) reset() {
+	h.index = len(h.entries)
+	h.prefix = ""
+}
+
+// prev returns the previous entry which starts with the line typed
+// before the history navigation started.
+func (h *history) prev(line string) (string, bool) {
+	if h.index == len(h.entries) {
+		h.prefix = line
+	}
+	for i := h.index - 1; i >= 0; i-- {
+		if strings.HasPrefix(h.entries[i], h.prefix) {
+			h.index = i
+			return h.entries[i], true
+		}
+	}
+	return "", false
+}
+
+// next returns the next entry, or the originally typed line
+// after reaching the newest entry.
+func (h *history) next() (string, bool) {
+	if h.index == len(h.entries) {
+		return "", false
+	}
+	for i := h.index + 1; i < len(h.entries); i++ {
+		if strings.HasPrefix(h.entries[i], h.prefix) {
+			h.index = i
+			return h.entries[i], true
+		}
+	}
+	h.index = len(h.entries)
+	return h.prefix, true
+}
+
+func historyPath() string {
+	return dataPath("history")
+}
+
+func searchHistoryPath() string {
+	return dataPath("search_history")
+}
+
+// dataPath returns the path of the file in the data directory, where the
+// histories of the command line are saved.
+func dataPath(name string) string {
+	home, err := homedir.Dir()
+	if err != nil {
+		return ""
+	}
+	dir := os.Getenv("XDG_DATA_HOME")
+	if dir == "" {
+		dir = filepath.Join(home, ".local", "share")
+	}
+	return filepath.Join(dir, "bed", name)
+}
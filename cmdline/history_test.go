@@ -0,0 +1,79 @@
+package cmdline
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestHistoryPrevNext(t *testing.T) {
+	h := newHistory()
+	for _, line := range []string{"set ruler", "write", "set width=8", "write"} {
+		if err := h.add(line); err != nil {
+			t.Errorf("err should be nil but got: %v", err)
+		}
+	}
+	if expected := []string{"set ruler", "set width=8", "write"}; !reflect.DeepEqual(h.entries, expected) {
+		t.Errorf("history entries should be %v but got %v", expected, h.entries)
+	}
+	for _, expected := range []string{"set width=8", "set ruler"} {
+		if line, ok := h.prev("se"); !ok || line != expected {
+			t.Errorf("history.prev should return %q but got %q", expected, line)
+		}
+	}
+	if _, ok := h.prev("set ruler"); ok {
+		t.Errorf("history.prev should return false at the oldest entry")
+	}
+	for _, expected := range []string{"set width=8", "se"} {
+		if line, ok := h.next(); !ok || line != expected {
+			t.Errorf("history.next should return %q but got %q", expected, line)
+		}
+	}
+	if _, ok := h.next(); ok {
+		t.Errorf("history.next should return false after the newest entry")
+	}
+}
+
+func TestHistoryLoadSave(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bed-test-cmdline-history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "bed", "history")
+	h := newHistory()
+	if err := h.load(path); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	for i := 0; i < maxHistory+10; i++ {
+		if err := h.add(string(rune('a'+i%26)) + string(rune('0'+i/26))); err != nil {
+			t.Errorf("err should be nil but got: %v", err)
+		}
+	}
+	h = newHistory()
+	if err := h.load(path); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	if len(h.entries) != maxHistory {
+		t.Errorf("history should have %d entries but got %d", maxHistory, len(h.entries))
+	}
+	if h.entries[0] != "k0" || h.entries[maxHistory-1] != "f4" {
+		t.Errorf("history entries should be from %q to %q but got %v", "k0", "f4", h.entries)
+	}
+	if line, ok := h.prev(""); !ok || line != "f4" {
+		t.Errorf("history.prev should return %q but got %q", "f4", line)
+	}
+}
+
+func TestHistoryPath(t *testing.T) {
+	defer os.Setenv("XDG_DATA_HOME", os.Getenv("XDG_DATA_HOME"))
+	os.Setenv("XDG_DATA_HOME", "/data")
+	if got, expected := historyPath(), filepath.Join("/data", "bed", "history"); got != expected {
+		t.Errorf("historyPath should be %q but got %q", expected, got)
+	}
+	if got, expected := searchHistoryPath(), filepath.Join("/data", "bed", "search_history"); got != expected {
+		t.Errorf("searchHistoryPath should be %q but got %q", expected, got)
+	}
+}
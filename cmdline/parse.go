@@ -6,6 +6,7 @@ import (
 	"unicode"
 
 	"github.com/itchyny/bed/event"
+	"github.com/itchyny/bed/expr"
 )
 
 func parse(cmdline []rune) (command, *event.Range, string, string, error) {
@@ -18,7 +19,7 @@ func parse(cmdline []rune) (command, *event.Range, string, string, error) {
 	}
 	r, i := event.ParseRange(cmdline, i)
 	j := i
-	for j < l && !unicode.IsSpace(cmdline[j]) {
+	for j < l && !unicode.IsSpace(cmdline[j]) && cmdline[j] != '/' {
 		j++
 	}
 	k := j
@@ -26,12 +27,19 @@ func parse(cmdline []rune) (command, *event.Range, string, string, error) {
 		k++
 	}
 	cmdName := string(cmdline[i:j])
+	bang := len(cmdName) > 1 && strings.HasSuffix(cmdName, "!")
+	if bang {
+		cmdName = cmdName[:len(cmdName)-1]
+	}
 	for _, cmd := range commands {
 		if len(cmdName) == 0 || cmdName[0] != cmd.name[0] {
 			continue
 		}
 		for _, c := range expand(cmd.name) {
 			if cmdName == c {
+				if bang && !cmd.allowBang() {
+					return command{}, nil, "", "", fmt.Errorf("! not allowed for %s", cmd.name)
+				}
 				return cmd, r, string(cmdline[:k]), strings.TrimSpace(string(cmdline[k:])), nil
 			}
 		}
@@ -57,3 +65,31 @@ func expand(name string) []string {
 	}
 	return cmds
 }
+
+// parseCommandName returns the prefix and the command name when the cmdline
+// consists of the command name being typed, without any argument.
+func parseCommandName(cmdline []rune) (string, string, bool) {
+	i, l := 0, len(cmdline)
+	for i < l && (unicode.IsSpace(cmdline[i]) || cmdline[i] == ':') {
+		i++
+	}
+	_, i = event.ParseRange(cmdline, i)
+	for j := i; j < l; j++ {
+		if unicode.IsSpace(cmdline[j]) {
+			return "", "", false
+		}
+	}
+	return string(cmdline[:i]), string(cmdline[i:]), true
+}
+
+func parseGotoArg(cmd command, arg string) (*event.Range, error) {
+	xs := []rune(arg)
+	r, i := event.ParseRange(xs, 0)
+	if r == nil || i < len(xs) {
+		if _, err := expr.Eval(arg); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("invalid position for %s: %s", cmd.name, arg)
+	}
+	return r, nil
+}
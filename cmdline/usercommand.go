@@ -0,0 +1,128 @@
+package cmdline
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/itchyny/bed/event"
+)
+
+// userCommands holds the commands defined by :command. The body of a user
+// command is the command lines separated by |, and <args> in the body is
+// replaced with the arguments. The commands of the plugin programs are held
+// with the paths of the programs.
+type userCommands struct {
+	bodies   map[string]string
+	programs map[string]string
+	mu       *sync.RWMutex
+}
+
+func newUserCommands() *userCommands {
+	return &userCommands{
+		bodies:   make(map[string]string),
+		programs: make(map[string]string),
+		mu:       new(sync.RWMutex),
+	}
+}
+
+func validUserCommandName(name string) bool {
+	if name == "" || !unicode.IsUpper(rune(name[0])) {
+		return false
+	}
+	for _, c := range name {
+		if !unicode.IsLetter(c) && !unicode.IsDigit(c) {
+			return false
+		}
+	}
+	return true
+}
+
+// DefineCommand defines the user command. The name should start with an
+// uppercase letter to be distinguished from the builtin commands.
+func (c *Cmdline) DefineCommand(name, body string) error {
+	if !validUserCommandName(name) {
+		return fmt.Errorf("invalid command name: %s", name)
+	}
+	if body = strings.TrimSpace(body); body == "" {
+		return fmt.Errorf("command body is empty: %s", name)
+	}
+	c.userCommands.mu.Lock()
+	defer c.userCommands.mu.Unlock()
+	c.userCommands.bodies[name] = body
+	delete(c.userCommands.programs, name)
+	return nil
+}
+
+// DefineProgram defines the user command executed by the plugin program.
+func (c *Cmdline) DefineProgram(name, path string) error {
+	if !validUserCommandName(name) {
+		return fmt.Errorf("invalid command name: %s", name)
+	}
+	if path == "" {
+		return fmt.Errorf("program is empty: %s", name)
+	}
+	c.userCommands.mu.Lock()
+	defer c.userCommands.mu.Unlock()
+	c.userCommands.programs[name] = path
+	delete(c.userCommands.bodies, name)
+	return nil
+}
+
+// DeleteCommand deletes the user command.
+func (c *Cmdline) DeleteCommand(name string) error {
+	c.userCommands.mu.Lock()
+	defer c.userCommands.mu.Unlock()
+	_, ok := c.userCommands.bodies[name]
+	if _, found := c.userCommands.programs[name]; !ok && !found {
+		return fmt.Errorf("no such user command: %s", name)
+	}
+	delete(c.userCommands.bodies, name)
+	delete(c.userCommands.programs, name)
+	return nil
+}
+
+// UserCommands returns the user commands with the bodies.
+func (c *Cmdline) UserCommands() []string {
+	c.userCommands.mu.RLock()
+	defer c.userCommands.mu.RUnlock()
+	xs := make([]string, 0, len(c.userCommands.bodies)+len(c.userCommands.programs))
+	for name, body := range c.userCommands.bodies {
+		xs = append(xs, fmt.Sprintf("%-12s %s", name, body))
+	}
+	for name, path := range c.userCommands.programs {
+		xs = append(xs, fmt.Sprintf("%-12s !%s", name, path))
+	}
+	sort.Strings(xs)
+	return xs
+}
+
+// parseUserCommand parses the invocation of the user command, and returns
+// the event with the body in which <args> is replaced, or the event with the
+// arguments for the command of the plugin program.
+func (c *Cmdline) parseUserCommand(line string) (event.Event, bool) {
+	line = strings.TrimLeftFunc(line, func(r rune) bool { return unicode.IsSpace(r) || r == ':' })
+	name, arg := line, ""
+	if i := strings.IndexFunc(line, unicode.IsSpace); i >= 0 {
+		name, arg = line[:i], strings.TrimSpace(line[i:])
+	}
+	if !validUserCommandName(name) {
+		return event.Event{}, false
+	}
+	c.userCommands.mu.RLock()
+	body, ok := c.userCommands.bodies[name]
+	_, program := c.userCommands.programs[name]
+	c.userCommands.mu.RUnlock()
+	if program {
+		return event.Event{Type: event.PluginCommand, CmdName: name, Arg: arg}, true
+	}
+	if !ok {
+		return event.Event{}, false
+	}
+	return event.Event{
+		Type: event.UserCommand, CmdName: name,
+		Arg: strings.Replace(body, "<args>", arg, -1),
+	}, true
+}
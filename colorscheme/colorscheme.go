@@ -0,0 +1,130 @@
+package colorscheme
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Color is a pair of the foreground and background color names.
+// An empty name means the color of the normal group.
+type Color struct {
+	Foreground string
+	Background string
+}
+
+// Scheme maps the highlight groups to the colors.
+type Scheme map[string]Color
+
+// Highlight groups of the user interface.
+const (
+	Normal     = "normal"
+	Offset     = "offset"
+	Header     = "header"
+	Edited     = "edited"
+	Saved      = "saved"
+	ScrollBar  = "scrollbar"
+	StatusLine = "statusline"
+	Separator  = "separator"
+	Cmdline    = "cmdline"
+	Completion = "completion"
+	Error      = "error"
+	Info       = "info"
+)
+
+var groups = []string{
+	Normal, Offset, Header, Edited, Saved, ScrollBar,
+	StatusLine, Separator, Cmdline, Completion, Error, Info,
+}
+
+var builtins = map[string]Scheme{
+	"default": {
+		Edited: {Foreground: "lightseagreen"},
+		Saved:  {Foreground: "teal"},
+		Error:  {Foreground: "red"},
+		Info:   {Foreground: "yellow"},
+	},
+	"monokai": {
+		Normal:     {Foreground: "#f8f8f2", Background: "#272822"},
+		Offset:     {Foreground: "#75715e"},
+		Header:     {Foreground: "#a6e22e"},
+		Edited:     {Foreground: "#f92672"},
+		Saved:      {Foreground: "#fd971f"},
+		ScrollBar:  {Foreground: "#75715e"},
+		StatusLine: {Foreground: "#272822", Background: "#a6e22e"},
+		Separator:  {Foreground: "#272822", Background: "#75715e"},
+		Completion: {Foreground: "#272822", Background: "#e6db74"},
+		Error:      {Foreground: "#f92672"},
+		Info:       {Foreground: "#e6db74"},
+	},
+	"solarized": {
+		Normal:     {Foreground: "#839496", Background: "#002b36"},
+		Offset:     {Foreground: "#586e75"},
+		Header:     {Foreground: "#268bd2"},
+		Edited:     {Foreground: "#cb4b16"},
+		Saved:      {Foreground: "#b58900"},
+		ScrollBar:  {Foreground: "#586e75"},
+		StatusLine: {Foreground: "#002b36", Background: "#93a1a1"},
+		Separator:  {Foreground: "#002b36", Background: "#586e75"},
+		Completion: {Foreground: "#002b36", Background: "#2aa198"},
+		Error:      {Foreground: "#dc322f"},
+		Info:       {Foreground: "#b58900"},
+	},
+}
+
+// Builtin returns the builtin color scheme of the name.
+func Builtin(name string) (Scheme, bool) {
+	s, ok := builtins[name]
+	if !ok {
+		return nil, false
+	}
+	return s.Clone(), true
+}
+
+// Builtins returns the sorted names of the builtin color schemes.
+func Builtins() []string {
+	names := make([]string, 0, len(builtins))
+	for name := range builtins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Clone the color scheme.
+func (s Scheme) Clone() Scheme {
+	t := make(Scheme, len(s))
+	for group, color := range s {
+		t[group] = color
+	}
+	return t
+}
+
+// Define the color of a group with the definition: group=fg[/bg].
+func (s Scheme) Define(def string) error {
+	i := strings.IndexByte(def, '=')
+	if i < 0 {
+		return fmt.Errorf("invalid color definition: %s", def)
+	}
+	group := def[:i]
+	if !isGroup(group) {
+		return fmt.Errorf("unknown highlight group: %s", group)
+	}
+	var color Color
+	if j := strings.IndexByte(def[i+1:], '/'); j >= 0 {
+		color.Foreground, color.Background = def[i+1:i+1+j], def[i+2+j:]
+	} else {
+		color.Foreground = def[i+1:]
+	}
+	s[group] = color
+	return nil
+}
+
+func isGroup(group string) bool {
+	for _, g := range groups {
+		if g == group {
+			return true
+		}
+	}
+	return false
+}
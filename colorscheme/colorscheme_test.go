@@ -0,0 +1,58 @@
+package colorscheme
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBuiltin(t *testing.T) {
+	for _, name := range Builtins() {
+		s, ok := Builtin(name)
+		if !ok {
+			t.Errorf("Builtin(%q) should be found", name)
+		}
+		for group := range s {
+			if !isGroup(group) {
+				t.Errorf("color scheme %q contains unknown group %q", name, group)
+			}
+		}
+	}
+	if _, ok := Builtin("unknown"); ok {
+		t.Errorf("Builtin(%q) should not be found", "unknown")
+	}
+	s, _ := Builtin("default")
+	s[Normal] = Color{Foreground: "white"}
+	if t2, _ := Builtin("default"); reflect.DeepEqual(s, t2) {
+		t.Errorf("Builtin should return a copy of the color scheme")
+	}
+}
+
+func TestSchemeDefine(t *testing.T) {
+	testCases := []struct {
+		def      string
+		expected Scheme
+		err      string
+	}{
+		{"edited=red", Scheme{Edited: {Foreground: "red"}}, ""},
+		{"normal=white/#000000", Scheme{Normal: {"white", "#000000"}}, ""},
+		{"statusline=/blue", Scheme{StatusLine: {"", "blue"}}, ""},
+		{"edited", nil, "invalid color definition: edited"},
+		{"unknown=red", nil, "unknown highlight group: unknown"},
+	}
+	for _, testCase := range testCases {
+		s := make(Scheme)
+		err := s.Define(testCase.def)
+		if testCase.err != "" {
+			if err == nil || err.Error() != testCase.err {
+				t.Errorf("Define(%q) should return error %q but got: %v", testCase.def, testCase.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("err should be nil but got: %v", err)
+		}
+		if !reflect.DeepEqual(s, testCase.expected) {
+			t.Errorf("Define(%q) should be %+v but got %+v", testCase.def, testCase.expected, s)
+		}
+	}
+}
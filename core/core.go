@@ -0,0 +1,309 @@
+// Package core provides the editing engine of bed without the user
+// interface, so that other programs can open, edit, search and save binary
+// files in the same way as the editor.
+//
+//	doc, err := core.Open("image.bin")
+//	if err != nil {
+//		return err
+//	}
+//	defer doc.Close()
+//	if offset, err := doc.Search([]byte("IEND"), 0, true); err == nil && offset >= 0 {
+//		err = doc.Replace(offset, []byte("iend"))
+//	}
+//	err = doc.Save()
+//
+// The methods of Document are safe for concurrent use.
+package core
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"strconv"
+	"sync"
+
+	"github.com/itchyny/bed/buffer"
+	"github.com/itchyny/bed/history"
+	"github.com/itchyny/bed/mathutil"
+)
+
+// searchChunk is the size of the chunk to read the buffer while searching.
+const searchChunk = 1 << 20
+
+// Document is an editable buffer of a file with the undo history. The edits
+// are kept in the memory until the document is saved, so opening a large
+// file is fast.
+type Document struct {
+	filename string
+	perm     os.FileMode
+	files    []*os.File
+	buffer   *buffer.Buffer
+	history  *history.History
+	length   int64
+	modified bool
+	mu       *sync.Mutex
+}
+
+// New creates a new Document of the bytes, which has no file name.
+func New(bs []byte) *Document {
+	return newDocument(bytes.NewReader(bs), int64(len(bs)))
+}
+
+// Open the file and creates a new Document.
+func Open(filename string) (*Document, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if info.IsDir() {
+		f.Close()
+		return nil, fmt.Errorf("%s is a directory", filename)
+	}
+	d := newDocument(f, info.Size())
+	d.filename, d.perm, d.files = filename, info.Mode().Perm(), []*os.File{f}
+	return d, nil
+}
+
+type readAtSeeker interface {
+	io.ReaderAt
+	io.Seeker
+}
+
+func newDocument(r readAtSeeker, length int64) *Document {
+	b := buffer.NewBuffer(r)
+	h := history.NewHistory()
+	h.Push(b, 0, 0)
+	return &Document{perm: 0644, buffer: b, history: h, length: length, mu: new(sync.Mutex)}
+}
+
+// Name returns the file name of the Document.
+func (d *Document) Name() string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.filename
+}
+
+// Len returns the length of the Document.
+func (d *Document) Len() int64 {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.length
+}
+
+// Modified reports whether the Document is modified since it was opened or
+// saved.
+func (d *Document) Modified() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.modified
+}
+
+// ReadAt reads the bytes of the Document at the offset.
+func (d *Document) ReadAt(p []byte, offset int64) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.buffer.ReadAt(p, offset)
+}
+
+// WriteTo writes the contents of the Document.
+func (d *Document) WriteTo(w io.Writer) (int64, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.writeTo(w)
+}
+
+func (d *Document) writeTo(w io.Writer) (int64, error) {
+	if _, err := d.buffer.Seek(0, io.SeekStart); err != nil {
+		return 0, err
+	}
+	return io.Copy(w, d.buffer)
+}
+
+func (d *Document) checkOffset(offset int64) error {
+	if offset < 0 || offset > d.length {
+		return fmt.Errorf("offset out of range: %d", offset)
+	}
+	return nil
+}
+
+// changed pushes the buffer to the history.
+func (d *Document) changed() {
+	d.history.Push(d.buffer, 0, 0)
+	d.modified = true
+}
+
+// Insert the bytes at the offset.
+func (d *Document) Insert(offset int64, bs []byte) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.checkOffset(offset); err != nil {
+		return err
+	}
+	if len(bs) == 0 {
+		return nil
+	}
+	d.buffer.InsertBytes(offset, bs)
+	d.length += int64(len(bs))
+	d.changed()
+	return nil
+}
+
+// Replace the bytes at the offset. The bytes after the end of the Document
+// are appended.
+func (d *Document) Replace(offset int64, bs []byte) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.checkOffset(offset); err != nil {
+		return err
+	}
+	if len(bs) == 0 {
+		return nil
+	}
+	n := int(mathutil.MinInt64(int64(len(bs)), d.length-offset))
+	for i := 0; i < n; i++ {
+		d.buffer.Replace(offset+int64(i), bs[i])
+	}
+	if n < len(bs) {
+		d.buffer.InsertBytes(d.length, bs[n:])
+		d.length += int64(len(bs) - n)
+	}
+	d.changed()
+	return nil
+}
+
+// Delete the bytes of the size at the offset.
+func (d *Document) Delete(offset, size int64) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.checkOffset(offset); err != nil {
+		return err
+	}
+	size = mathutil.MinInt64(size, d.length-offset)
+	if size <= 0 {
+		return nil
+	}
+	for i := int64(0); i < size; i++ {
+		d.buffer.Delete(offset)
+	}
+	d.length -= size
+	d.changed()
+	return nil
+}
+
+// Undo the last change.
+func (d *Document) Undo() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if buffer, _, _, _ := d.history.Undo(); buffer != nil {
+		d.buffer, d.modified = buffer, true
+		d.length, _ = d.buffer.Len()
+	}
+}
+
+// Redo the undone change.
+func (d *Document) Redo() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if buffer, _, _ := d.history.Redo(); buffer != nil {
+		d.buffer, d.modified = buffer, true
+		d.length, _ = d.buffer.Len()
+	}
+}
+
+// Search the bytes from the offset, and returns the offset of the first
+// occurrence, or -1 if not found. When searching backward, the occurrence
+// before the offset is returned.
+func (d *Document) Search(target []byte, offset int64, forward bool) (int64, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if len(target) == 0 {
+		return -1, errors.New("empty search target")
+	}
+	bs := make([]byte, searchChunk+len(target)-1)
+	if forward {
+		for base := mathutil.MaxInt64(offset, 0); base < d.length; base += searchChunk {
+			n, err := d.buffer.ReadAt(bs, base)
+			if err != nil && err != io.EOF {
+				return -1, err
+			}
+			if i := bytes.Index(bs[:n], target); i >= 0 {
+				return base + int64(i), nil
+			}
+		}
+		return -1, nil
+	}
+	for end := mathutil.MinInt64(offset, d.length); end > 0; end -= searchChunk {
+		base := mathutil.MaxInt64(end-searchChunk, 0)
+		n, err := d.buffer.ReadAt(bs[:end-base+int64(len(target))-1], base)
+		if err != nil && err != io.EOF {
+			return -1, err
+		}
+		if i := bytes.LastIndex(bs[:n], target); i >= 0 {
+			return base + int64(i), nil
+		}
+	}
+	return -1, nil
+}
+
+// Save the Document to the file.
+func (d *Document) Save() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.filename == "" {
+		return errors.New("no file name")
+	}
+	return d.saveAs(d.filename)
+}
+
+// SaveAs saves the Document to the file, and the file becomes the name of
+// the Document.
+func (d *Document) SaveAs(filename string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.saveAs(filename)
+}
+
+// saveAs writes to the temporary file and renames it, so the original file,
+// from which the buffer reads, is kept until the Document is closed.
+func (d *Document) saveAs(filename string) error {
+	tmpf, err := os.OpenFile(
+		filename+"-"+strconv.FormatUint(rand.Uint64(), 16),
+		os.O_RDWR|os.O_CREATE|os.O_EXCL, d.perm,
+	)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpf.Name())
+	_, err = d.writeTo(tmpf)
+	tmpf.Close()
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(tmpf.Name(), filename); err != nil {
+		return err
+	}
+	d.filename, d.modified = filename, false
+	return nil
+}
+
+// Close the files of the Document.
+func (d *Document) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var err error
+	for _, f := range d.files {
+		if e := f.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	d.files = nil
+	return err
+}
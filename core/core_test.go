@@ -0,0 +1,127 @@
+package core
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+func contents(t *testing.T, d *Document) string {
+	var b bytes.Buffer
+	if _, err := d.WriteTo(&b); err != nil {
+		t.Fatal(err)
+	}
+	return b.String()
+}
+
+func TestDocumentEdit(t *testing.T) {
+	d := New([]byte("Hello, world!"))
+	if err := d.Insert(7, []byte("new ")); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	if err := d.Replace(0, []byte("J")); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	if err := d.Delete(4, 1); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	if err := d.Replace(d.Len()-1, []byte("?!")); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	if expected := "Jell, new world?!"; contents(t, d) != expected {
+		t.Errorf("contents should be %q but got %q", expected, contents(t, d))
+	}
+	if d.Len() != 17 {
+		t.Errorf("d.Len() should be %d but got %d", 17, d.Len())
+	}
+	if !d.Modified() {
+		t.Errorf("d.Modified() should be true")
+	}
+	if err := d.Insert(18, []byte("x")); err == nil || err.Error() != "offset out of range: 18" {
+		t.Errorf("err should be %q but got: %v", "offset out of range: 18", err)
+	}
+
+	d.Undo()
+	d.Undo()
+	if expected := "Jello, new world!"; contents(t, d) != expected {
+		t.Errorf("contents should be %q but got %q", expected, contents(t, d))
+	}
+	d.Redo()
+	if expected := "Jell, new world!"; contents(t, d) != expected {
+		t.Errorf("contents should be %q but got %q", expected, contents(t, d))
+	}
+	if d.Len() != 16 {
+		t.Errorf("d.Len() should be %d but got %d", 16, d.Len())
+	}
+}
+
+func TestDocumentSearch(t *testing.T) {
+	d := New([]byte(strings.Repeat("abcde", 1000) + "xyz" + strings.Repeat("abcde", 1<<18)))
+	for _, tc := range []struct {
+		target   string
+		offset   int64
+		forward  bool
+		expected int64
+	}{
+		{"cde", 0, true, 2},
+		{"cde", 3, true, 7},
+		{"xyz", 0, true, 5000},
+		{"xyz", 5001, true, -1},
+		{"xyz", d.Len(), false, 5000},
+		{"xyz", 5000, false, -1},
+		{"abc", 5003, false, 4995},
+		{"cde", d.Len(), false, d.Len() - 3},
+	} {
+		got, err := d.Search([]byte(tc.target), tc.offset, tc.forward)
+		if err != nil {
+			t.Errorf("err should be nil but got: %v", err)
+		}
+		if got != tc.expected {
+			t.Errorf("search %q from %d should be %d but got %d", tc.target, tc.offset, tc.expected, got)
+		}
+	}
+}
+
+func TestDocumentSave(t *testing.T) {
+	f, err := ioutil.TempFile("", "bed-test-core-save")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.WriteString("Hello, world!"); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	d, err := Open(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if err := d.Replace(7, []byte("W")); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	if err := d.Save(); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	if d.Modified() {
+		t.Errorf("d.Modified() should be false")
+	}
+	bs, err := ioutil.ReadFile(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "Hello, World!"; string(bs) != expected {
+		t.Errorf("file contents should be %q but got %q", expected, string(bs))
+	}
+	if expected := "Hello, World!"; contents(t, d) != expected {
+		t.Errorf("contents should be %q but got %q", expected, contents(t, d))
+	}
+
+	if err := New(nil).Save(); err == nil || err.Error() != "no file name" {
+		t.Errorf("err should be %q but got: %v", "no file name", err)
+	}
+}
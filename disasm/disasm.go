@@ -0,0 +1,116 @@
+// Package disasm disassembles the bytes by the decoders of golang.org/x/arch,
+// which support x86, ARM, ARM64 and PowerPC 64-bit.
+package disasm
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"golang.org/x/arch/arm/armasm"
+	"golang.org/x/arch/arm64/arm64asm"
+	"golang.org/x/arch/ppc64/ppc64asm"
+	"golang.org/x/arch/x86/x86asm"
+)
+
+// Instruction is a disassembled instruction.
+type Instruction struct {
+	Address int64
+	Bytes   []byte
+	Text    string
+}
+
+// decoder decodes the instruction at the head of the bytes, and returns the
+// text and the length of the instruction.
+type decoder struct {
+	decode func([]byte, uint64) (string, int, error)
+	unit   int // the minimum length of the instructions
+}
+
+// decoders maps the architecture names to the decoders.
+var decoders = map[string]decoder{
+	"x86":     {decodeX86(32), 1},
+	"x86-64":  {decodeX86(64), 1},
+	"arm":     {decodeARM, 4},
+	"arm64":   {decodeARM64, 4},
+	"aarch64": {decodeARM64, 4},
+	"ppc64":   {decodePPC64(binary.BigEndian), 4},
+	"ppc64le": {decodePPC64(binary.LittleEndian), 4},
+}
+
+func decodeX86(mode int) func([]byte, uint64) (string, int, error) {
+	return func(bs []byte, pc uint64) (string, int, error) {
+		inst, err := x86asm.Decode(bs, mode)
+		if err != nil {
+			return "", 0, err
+		}
+		return x86asm.IntelSyntax(inst, pc, nil), inst.Len, nil
+	}
+}
+
+func decodeARM(bs []byte, _ uint64) (string, int, error) {
+	inst, err := armasm.Decode(bs, armasm.ModeARM)
+	if err != nil {
+		return "", 0, err
+	}
+	return armasm.GNUSyntax(inst), inst.Len, nil
+}
+
+func decodeARM64(bs []byte, _ uint64) (string, int, error) {
+	inst, err := arm64asm.Decode(bs)
+	if err != nil {
+		return "", 0, err
+	}
+	return arm64asm.GNUSyntax(inst), 4, nil
+}
+
+func decodePPC64(ord binary.ByteOrder) func([]byte, uint64) (string, int, error) {
+	return func(bs []byte, pc uint64) (string, int, error) {
+		inst, err := ppc64asm.Decode(bs, ord)
+		if err != nil {
+			return "", 0, err
+		}
+		return ppc64asm.GNUSyntax(inst, pc), inst.Len, nil
+	}
+}
+
+// Check returns an error if the architecture is not supported.
+func Check(arch string) error {
+	if _, ok := decoders[arch]; !ok {
+		archs := make([]string, 0, len(decoders))
+		for arch := range decoders {
+			archs = append(archs, arch)
+		}
+		sort.Strings(archs)
+		return fmt.Errorf("unsupported architecture: %s (supported: %s)", arch, strings.Join(archs, ", "))
+	}
+	return nil
+}
+
+// Disassemble the bytes for the architecture, which is one of x86, x86-64,
+// arm, arm64 (or aarch64), ppc64 and ppc64le. The address is the address of
+// the first byte. The bytes which cannot be decoded are shown as (bad).
+func Disassemble(bs []byte, arch string, address int64) ([]Instruction, error) {
+	if err := Check(arch); err != nil {
+		return nil, err
+	}
+	if len(bs) == 0 {
+		return nil, errors.New("no bytes to disassemble")
+	}
+	d := decoders[arch]
+	var insts []Instruction
+	for i := 0; i+d.unit <= len(bs); {
+		text, n, err := d.decode(bs[i:], uint64(address)+uint64(i))
+		if err != nil || n <= 0 {
+			text, n = "(bad)", d.unit
+		}
+		insts = append(insts, Instruction{
+			Address: address + int64(i), Bytes: append([]byte(nil), bs[i:i+n]...),
+			Text: strings.TrimSpace(text),
+		})
+		i += n
+	}
+	return insts, nil
+}
@@ -0,0 +1,83 @@
+package disasm
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDisassemble(t *testing.T) {
+	testCases := []struct {
+		name     string
+		arch     string
+		bytes    []byte
+		expected []Instruction
+	}{
+		{
+			name:  "x86-64",
+			arch:  "x86-64",
+			bytes: []byte{0x55, 0x48, 0x89, 0xe5, 0x06, 0xc3},
+			expected: []Instruction{
+				{0x400000, []byte{0x55}, "push rbp"},
+				{0x400001, []byte{0x48, 0x89, 0xe5}, "mov rbp, rsp"},
+				{0x400004, []byte{0x06}, "(bad)"},
+				{0x400005, []byte{0xc3}, "ret"},
+			},
+		},
+		{
+			name:  "x86",
+			arch:  "x86",
+			bytes: []byte{0x06, 0xc3},
+			expected: []Instruction{
+				{0x400000, []byte{0x06}, "push es"},
+				{0x400001, []byte{0xc3}, "ret"},
+			},
+		},
+		{
+			name:  "arm64",
+			arch:  "arm64",
+			bytes: []byte{0x1f, 0x20, 0x03, 0xd5, 0xc0, 0x03, 0x5f, 0xd6, 0x00},
+			expected: []Instruction{
+				{0x400000, []byte{0x1f, 0x20, 0x03, 0xd5}, "nop"},
+				{0x400004, []byte{0xc0, 0x03, 0x5f, 0xd6}, "ret"},
+			},
+		},
+		{
+			name:  "arm",
+			arch:  "arm",
+			bytes: []byte{0x1e, 0xff, 0x2f, 0xe1},
+			expected: []Instruction{
+				{0x400000, []byte{0x1e, 0xff, 0x2f, 0xe1}, "bx lr"},
+			},
+		},
+		{
+			name:  "ppc64",
+			arch:  "ppc64",
+			bytes: []byte{0x4e, 0x80, 0x00, 0x20},
+			expected: []Instruction{
+				{0x400000, []byte{0x4e, 0x80, 0x00, 0x20}, "blr"},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			insts, err := Disassemble(tc.bytes, tc.arch, 0x400000)
+			if err != nil {
+				t.Fatalf("err should be nil but got: %v", err)
+			}
+			if !reflect.DeepEqual(insts, tc.expected) {
+				t.Errorf("instructions should be %+v but got %+v", tc.expected, insts)
+			}
+		})
+	}
+}
+
+func TestDisassembleError(t *testing.T) {
+	if _, err := Disassemble(nil, "x86-64", 0); err == nil || err.Error() != "no bytes to disassemble" {
+		t.Errorf("err should be %q but got: %v", "no bytes to disassemble", err)
+	}
+	if _, err := Disassemble([]byte{0xc3}, "mips", 0); err == nil ||
+		!strings.HasPrefix(err.Error(), "unsupported architecture: mips (supported: aarch64, arm, arm64,") {
+		t.Errorf("err should be unsupported architecture but got: %v", err)
+	}
+}
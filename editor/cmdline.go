@@ -1,10 +1,19 @@
 package editor
 
-import "github.com/itchyny/bed/event"
+import (
+	"github.com/itchyny/bed/event"
+	"github.com/itchyny/bed/option"
+)
 
 // Cmdline defines the required cmdline interface for the editor.
 type Cmdline interface {
 	Init(chan<- event.Event, <-chan event.Event, chan<- struct{})
 	Run()
+	SetOptions(*option.Options)
 	Get() ([]rune, int, []string, int)
+	Parse(string) (event.Event, error)
+	DefineCommand(string, string) error
+	DefineProgram(string, string) error
+	DeleteCommand(string) error
+	UserCommands() []string
 }
@@ -0,0 +1,283 @@
+package editor
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/mitchellh/go-homedir"
+
+	"github.com/itchyny/bed/event"
+	"github.com/itchyny/bed/key"
+	"github.com/itchyny/bed/mode"
+	"github.com/itchyny/bed/option"
+)
+
+// LoadConfig loads the configuration file, which consists of set, map,
+// colorscheme, command and highlight commands. When the filename is empty, it loads the
+// default configuration file if exists.
+func (e *Editor) LoadConfig(filename string) error {
+	if filename == "" {
+		if filename = configPath(); filename == "" {
+			return nil
+		}
+	}
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '"' || line[0] == '#' {
+			continue
+		}
+		if err := e.executeConfig(line); err != nil {
+			return fmt.Errorf("%s:%d: %s", filename, n, err)
+		}
+	}
+	return scanner.Err()
+}
+
+// SetOption sets the option with the argument of :set.
+func (e *Editor) SetOption(arg string) error {
+	return e.options.Set(arg)
+}
+
+func (e *Editor) executeConfig(line string) error {
+	ev, err := e.cmdline.Parse(line)
+	if err != nil {
+		return err
+	}
+	switch ev.Type {
+	case event.Set:
+		for _, arg := range option.SplitArgs(ev.Arg) {
+			if err := e.options.Set(arg); err != nil {
+				return err
+			}
+		}
+		return nil
+	case event.ColorScheme:
+		return e.setColorScheme(ev)
+	case event.Map:
+		return e.mapKeys(ev)
+	case event.DefineCommand:
+		return e.defineCommand(ev)
+	case event.DeleteCommand:
+		return e.cmdline.DeleteCommand(ev.Arg)
+	case event.Highlight:
+		xs := strings.SplitN(ev.Arg, " ", 2)
+		if len(xs) != 2 {
+			return fmt.Errorf("a color and a pattern are required for %s", ev.CmdName)
+		}
+		return e.wm.AddHighlight(xs[0], xs[1])
+	default:
+		return fmt.Errorf("command not allowed in the configuration file: %s", line)
+	}
+}
+
+// configDir returns the configuration directory of bed.
+func configDir() (string, error) {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := homedir.Dir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "bed"), nil
+}
+
+func configPath() string {
+	home, err := homedir.Dir()
+	if err != nil {
+		return ""
+	}
+	dir, err := configDir()
+	if err != nil {
+		return ""
+	}
+	for _, path := range []string{
+		filepath.Join(dir, "config"),
+		filepath.Join(home, ".bedrc"),
+	} {
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+// mapKeys maps the keys to the action, or to the command lines after : like
+// the body of the user command (:map <C-p> :Patch 10 | write).
+func (e *Editor) mapKeys(ev event.Event) error {
+	xs := strings.Fields(ev.Arg)
+	if len(xs) > 2 && strings.HasPrefix(xs[1], ":") {
+		xs = []string{xs[0], strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(ev.Arg), xs[0]))}
+	}
+	if len(xs) != 2 {
+		return fmt.Errorf("keys and an action are required for %s", ev.CmdName)
+	}
+	keys, err := parseKeys(xs[0])
+	if err != nil {
+		return err
+	}
+	m := mode.Normal
+	switch ev.CmdName {
+	case "vm[ap]":
+		m = mode.Visual
+	case "im[ap]":
+		m = mode.Insert
+	}
+	if strings.HasPrefix(xs[1], ":") {
+		if xs[1] = strings.TrimSpace(xs[1][1:]); xs[1] == "" {
+			return fmt.Errorf("command is empty for %s", ev.CmdName)
+		}
+		e.kms[m].RegisterCommand(xs[1], keys...)
+		return nil
+	}
+	eventType, ok := actions[strings.ToLower(xs[1])]
+	if !ok {
+		return fmt.Errorf("unknown action: %s", xs[1])
+	}
+	e.kms[m].Register(eventType, keys...)
+	return nil
+}
+
+// defineCommand defines the user command by the argument of :command, which
+// is the name followed by the body.
+func (e *Editor) defineCommand(ev event.Event) error {
+	xs := strings.SplitN(ev.Arg, " ", 2)
+	if len(xs) != 2 {
+		return fmt.Errorf("a name and a body are required for %s", ev.CmdName)
+	}
+	return e.cmdline.DefineCommand(xs[0], xs[1])
+}
+
+// parseKeys parses the key notation like <c-w>n into the key sequence.
+func parseKeys(str string) ([]key.Key, error) {
+	var keys []key.Key
+	for len(str) > 0 {
+		if str[0] == '<' {
+			if i := strings.IndexByte(str, '>'); i > 1 {
+				name := strings.ToLower(str[1:i])
+				if k, ok := keyNames[name]; ok {
+					keys = append(keys, k)
+				} else {
+					keys = append(keys, key.Key(name))
+				}
+				str = str[i+1:]
+				continue
+			}
+		}
+		r, size := utf8.DecodeRuneInString(str)
+		keys = append(keys, key.Key(string(r)))
+		str = str[size:]
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("keys are empty")
+	}
+	return keys, nil
+}
+
+var keyNames = map[string]key.Key{
+	"space": " ",
+	"lt":    "<",
+	"esc":   "escape",
+	"cr":    "enter",
+	"bs":    "backspace",
+	"del":   "delete",
+}
+
+var actions = map[string]event.Type{
+	"nop":                    event.Nop,
+	"quit":                   event.Quit,
+	"suspend":                event.Suspend,
+	"cursorup":               event.CursorUp,
+	"cursordown":             event.CursorDown,
+	"cursorleft":             event.CursorLeft,
+	"cursorright":            event.CursorRight,
+	"cursorprev":             event.CursorPrev,
+	"cursornext":             event.CursorNext,
+	"cursorhead":             event.CursorHead,
+	"cursorend":              event.CursorEnd,
+	"scrollup":               event.ScrollUp,
+	"scrolldown":             event.ScrollDown,
+	"scrollcursorcenter":     event.ScrollCursorCenter,
+	"scrollcursortop":        event.ScrollCursorTop,
+	"scrollcursorbottom":     event.ScrollCursorBottom,
+	"pageup":                 event.PageUp,
+	"pagedown":               event.PageDown,
+	"pageuphalf":             event.PageUpHalf,
+	"pagedownhalf":           event.PageDownHalf,
+	"pagetop":                event.PageTop,
+	"pageend":                event.PageEnd,
+	"jumpto":                 event.JumpTo,
+	"jumptopointer":          event.JumpToPointer,
+	"jumpback":               event.JumpBack,
+	"jumpforward":            event.JumpForward,
+	"nextchange":             event.NextChange,
+	"previouschange":         event.PreviousChange,
+	"nextstring":             event.NextString,
+	"previousstring":         event.PreviousString,
+	"nextdata":               event.NextData,
+	"previousdata":           event.PreviousData,
+	"nextpacket":             event.NextPacket,
+	"previouspacket":         event.PreviousPacket,
+	"deletebyte":             event.DeleteByte,
+	"deleteprevbyte":         event.DeletePrevByte,
+	"increment":              event.Increment,
+	"decrement":              event.Decrement,
+	"startinsert":            event.StartInsert,
+	"startinserthead":        event.StartInsertHead,
+	"startappend":            event.StartAppend,
+	"startappendend":         event.StartAppendEnd,
+	"startreplacebyte":       event.StartReplaceByte,
+	"startreplace":           event.StartReplace,
+	"yank":                   event.Yank,
+	"paste":                  event.Paste,
+	"pastebefore":            event.PasteBefore,
+	"exitinsert":             event.ExitInsert,
+	"backspace":              event.Backspace,
+	"delete":                 event.Delete,
+	"startliteral":           event.StartLiteral,
+	"undo":                   event.Undo,
+	"redo":                   event.Redo,
+	"startvisual":            event.StartVisual,
+	"startvisualblock":       event.StartVisualBlock,
+	"switchvisualend":        event.SwitchVisualEnd,
+	"switchvisualblock":      event.SwitchVisualBlock,
+	"exitvisual":             event.ExitVisual,
+	"replacevisual":          event.ReplaceVisual,
+	"switchfocus":            event.SwitchFocus,
+	"startcmdlinecommand":    event.StartCmdlineCommand,
+	"startcmdlinesearch":     event.StartCmdlineSearchForward,
+	"startcmdlinesearchback": event.StartCmdlineSearchBackward,
+	"nextsearch":             event.NextSearch,
+	"previoussearch":         event.PreviousSearch,
+	"new":                    event.New,
+	"split":                  event.Split,
+	"vsplit":                 event.Vsplit,
+	"focuswindowdown":        event.FocusWindowDown,
+	"focuswindowup":          event.FocusWindowUp,
+	"focuswindowleft":        event.FocusWindowLeft,
+	"focuswindowright":       event.FocusWindowRight,
+	"focuswindowtopleft":     event.FocusWindowTopLeft,
+	"focuswindowbottomright": event.FocusWindowBottomRight,
+	"focuswindowprevious":    event.FocusWindowPrevious,
+	"movewindowtop":          event.MoveWindowTop,
+	"movewindowbottom":       event.MoveWindowBottom,
+	"movewindowleft":         event.MoveWindowLeft,
+	"movewindowright":        event.MoveWindowRight,
+	"increasewindowheight":   event.IncreaseWindowHeight,
+	"decreasewindowheight":   event.DecreaseWindowHeight,
+	"increasewindowwidth":    event.IncreaseWindowWidth,
+	"decreasewindowwidth":    event.DecreaseWindowWidth,
+	"equalizewindows":        event.EqualizeWindows,
+}
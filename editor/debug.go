@@ -0,0 +1,42 @@
+package editor
+
+import (
+	"fmt"
+	"runtime"
+	"time"
+)
+
+// latencyStats is the statistics of the time to handle the events.
+type latencyStats struct {
+	count int64
+	total time.Duration
+	max   time.Duration
+	last  time.Duration
+}
+
+func (s *latencyStats) record(d time.Duration) {
+	s.count++
+	s.total += d
+	s.last = d
+	if d > s.max {
+		s.max = d
+	}
+}
+
+// debugInfo returns the lines of the event latency, the memory usage, and the
+// internal state of the windows.
+func (e *Editor) debugInfo() []string {
+	var avg time.Duration
+	if e.latency.count > 0 {
+		avg = e.latency.total / time.Duration(e.latency.count)
+	}
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	xs := []string{
+		fmt.Sprintf("events: %d, latency last %v, avg %v, max %v",
+			e.latency.count, e.latency.last, avg, e.latency.max),
+		fmt.Sprintf("memory: heap %d bytes, sys %d bytes, %d gc, %d goroutines",
+			ms.HeapAlloc, ms.Sys, ms.NumGC, runtime.NumGoroutine()),
+	}
+	return append(xs, e.wm.Debug()...)
+}
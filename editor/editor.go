@@ -1,41 +1,79 @@
 package editor
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"io"
+	"runtime/debug"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
+	"go.starlark.net/starlark"
+
+	"github.com/itchyny/bed/colorscheme"
 	"github.com/itchyny/bed/event"
+	"github.com/itchyny/bed/expr"
+	"github.com/itchyny/bed/key"
 	"github.com/itchyny/bed/mode"
+	"github.com/itchyny/bed/option"
 	"github.com/itchyny/bed/state"
 )
 
 // Editor is the main struct for this command.
 type Editor struct {
 	ui            UI
+	sources       []EventSource
+	plugins       []*plugin
+	programs      map[string]*program
 	wm            Manager
 	cmdline       Cmdline
 	mode          mode.Mode
 	prevMode      mode.Mode
 	searchTarget  string
 	searchMode    rune
+	searchLast    rune
+	options       *option.Options
+	colorScheme   string
+	schemes       map[string]colorscheme.Scheme
+	kms           map[mode.Mode]*key.Manager
 	prevEventType event.Type
+	commandDepth  int
+	scripts       map[string]starlark.Callable
+	hooks         map[string][]starlark.Callable
+	lastFilename  string
+	lastCursor    int64
+	register      rune
 	err           error
 	errtyp        int
 	eventCh       chan event.Event
 	redrawCh      chan struct{}
 	cmdlineCh     chan event.Event
+	suspended     int32
+	stopSignals   func()
+	exitErr       error
+	bell          bool
+	latency       latencyStats
+	ctx           context.Context
+	cancel        func()
+	wg            *sync.WaitGroup
 	mu            *sync.Mutex
+	redrawMu      *sync.Mutex
 }
 
 // NewEditor creates a new editor.
 func NewEditor(ui UI, wm Manager, cmdline Cmdline) *Editor {
 	return &Editor{
-		ui:       ui,
-		wm:       wm,
-		cmdline:  cmdline,
-		mode:     mode.Normal,
-		prevMode: mode.Normal,
+		ui:          ui,
+		wm:          wm,
+		cmdline:     cmdline,
+		mode:        mode.Normal,
+		prevMode:    mode.Normal,
+		options:     option.New(),
+		colorScheme: "default",
+		schemes:     make(map[string]colorscheme.Scheme),
 	}
 }
 
@@ -46,19 +84,33 @@ func (e *Editor) Init() error {
 	e.cmdlineCh = make(chan event.Event)
 	e.cmdline.Init(e.eventCh, e.cmdlineCh, e.redrawCh)
 	e.wm.Init(e.eventCh, e.redrawCh)
-	e.mu = new(sync.Mutex)
+	e.wm.SetOptions(e.options)
+	e.cmdline.SetOptions(e.options)
+	e.kms = defaultKeyManagers()
+	e.ctx, e.cancel = context.WithCancel(context.Background())
+	e.wg, e.mu, e.redrawMu = new(sync.WaitGroup), new(sync.Mutex), new(sync.Mutex)
 	return nil
 }
 
 func (e *Editor) listen() {
-	go func() {
-		for {
-			<-e.redrawCh
-			e.redraw()
-		}
-	}()
+	go e.scheduleRedraw()
+	if redraw, finish := e.runHooks(); finish {
+		return
+	} else if redraw {
+		e.redrawCh <- struct{}{}
+	}
 	for ev := range e.eventCh {
-		if redraw, finish := e.emit(ev); redraw {
+		start := time.Now()
+		redraw, finish := e.emit(ev)
+		if !finish {
+			var r bool
+			r, finish = e.runHooks()
+			redraw = redraw && !finish || r
+		}
+		e.mu.Lock()
+		e.latency.record(time.Since(start))
+		e.mu.Unlock()
+		if redraw {
 			e.redrawCh <- struct{}{}
 		} else if finish {
 			break
@@ -66,6 +118,25 @@ func (e *Editor) listen() {
 	}
 }
 
+// scheduleRedraw receives the requests of redrawing without waiting for the
+// redraws, so that the event loop does not stall on a slow terminal. The
+// requests during a redraw are coalesced into the next redraw.
+func (e *Editor) scheduleRedraw() {
+	pending := make(chan struct{}, 1)
+	e.spawn(func() {
+		for range pending {
+			e.redraw()
+		}
+	})
+	for range e.redrawCh {
+		select {
+		case pending <- struct{}{}:
+		default:
+		}
+	}
+	close(pending)
+}
+
 func (e *Editor) emit(ev event.Event) (redraw bool, finish bool) {
 	e.mu.Lock()
 	if ev.Type != event.Redraw {
@@ -76,6 +147,10 @@ func (e *Editor) emit(ev event.Event) (redraw bool, finish bool) {
 		if len(ev.Arg) > 0 {
 			e.err, e.errtyp = fmt.Errorf("too many arguments for %s", ev.CmdName), state.MessageError
 			redraw = true
+		} else if !ev.Bang && e.wm.Modified() {
+			e.mu.Unlock()
+			e.wm.Emit(ev)
+			return
 		} else {
 			finish = true
 		}
@@ -93,17 +168,164 @@ func (e *Editor) emit(ev event.Event) (redraw bool, finish bool) {
 			return
 		}
 		redraw = true
+	case event.Resume:
+		e.mu.Unlock()
+		if err := e.resume(); err != nil {
+			e.mu.Lock()
+			e.err, e.errtyp = err, state.MessageError
+			e.mu.Unlock()
+		}
+		redraw = true
+		return
+	case event.Terminate:
+		e.exitErr = &SignalError{Signal: ev.Arg, Files: e.wm.Rescue()}
+		finish = true
+	case event.Crash:
+		if err, ok := ev.Error.(*event.PanicError); ok {
+			e.exitErr = &CrashError{Value: err.Value, Stack: err.Stack, Files: e.wm.Rescue()}
+		}
+		finish = true
 	case event.Info:
 		e.err, e.errtyp = ev.Error, state.MessageInfo
 		redraw = true
 	case event.Error:
-		e.err, e.errtyp = ev.Error, state.MessageError
+		e.err, e.errtyp, e.bell = ev.Error, state.MessageError, true
+		if e.mode == mode.Confirm {
+			e.mode, e.prevMode = mode.Normal, e.mode
+		}
+		redraw = true
+	case event.StartConfirm:
+		if e.mode != mode.Confirm {
+			e.mode, e.prevMode = mode.Confirm, e.mode
+		}
+		e.err, e.errtyp = ev.Error, state.MessageInfo
+		redraw = true
+	case event.ExitConfirm:
+		e.mode, e.prevMode = mode.Normal, e.mode
+		e.err, e.errtyp = ev.Error, state.MessageInfo
+		redraw = true
+	case event.SelectRegister:
+		e.register = ev.Rune
+		redraw = true
+	case event.Map:
+		if err := e.mapKeys(ev); err != nil {
+			e.err, e.errtyp = err, state.MessageError
+		}
+		redraw = true
+	case event.ColorScheme:
+		if err := e.setColorScheme(ev); err != nil {
+			e.err, e.errtyp = err, state.MessageError
+		}
+		redraw = true
+	case event.DefineCommand:
+		if ev.Arg == "" {
+			if cmds := e.cmdline.UserCommands(); len(cmds) > 0 {
+				e.err, e.errtyp = errors.New(strings.Join(cmds, "\n")), state.MessageInfo
+			} else {
+				e.err, e.errtyp = errors.New("no user commands"), state.MessageInfo
+			}
+		} else if err := e.defineCommand(ev); err != nil {
+			e.err, e.errtyp = err, state.MessageError
+		}
+		redraw = true
+	case event.DeleteCommand:
+		if err := e.cmdline.DeleteCommand(ev.Arg); err != nil {
+			e.err, e.errtyp = err, state.MessageError
+		}
+		redraw = true
+	case event.Plugins:
+		if xs := e.pluginList(); len(xs) > 0 {
+			e.err, e.errtyp = errors.New(strings.Join(xs, "\n")), state.MessageInfo
+		} else {
+			e.err, e.errtyp = errors.New("no plugins loaded"), state.MessageInfo
+		}
+		redraw = true
+	case event.Debug:
+		if len(ev.Arg) > 0 {
+			e.err, e.errtyp = fmt.Errorf("too many arguments for %s", ev.CmdName), state.MessageError
+		} else {
+			e.err, e.errtyp = errors.New(strings.Join(e.debugInfo(), "\n")), state.MessageInfo
+		}
 		redraw = true
+	case event.UserCommand:
+		if e.commandDepth >= maxCommandDepth {
+			e.err, e.errtyp = fmt.Errorf("user commands nested too deeply: %s", ev.CmdName), state.MessageError
+			redraw = true
+			break
+		}
+		e.commandDepth++
+		e.mu.Unlock()
+		redraw, finish = e.executeCommands(ev.CmdName, strings.Split(ev.Arg, "|"))
+		e.mu.Lock()
+		e.commandDepth--
+		e.mu.Unlock()
+		return
+	case event.PluginCommand:
+		if fn, ok := e.scripts[ev.CmdName]; ok {
+			e.mu.Unlock()
+			return e.callScript(ev.CmdName, fn, starlark.String(ev.Arg))
+		}
+		if e.commandDepth >= maxCommandDepth {
+			e.err, e.errtyp = fmt.Errorf("user commands nested too deeply: %s", ev.CmdName), state.MessageError
+			redraw = true
+			break
+		}
+		depth := e.commandDepth + 1
+		e.mu.Unlock()
+		if err := e.startProgram(ev, depth); err != nil {
+			e.mu.Lock()
+			e.err, e.errtyp = err, state.MessageError
+			e.mu.Unlock()
+			redraw = true
+		}
+		return
+	case event.ProgramOutput:
+		if ev.Error != nil {
+			e.err, e.errtyp = ev.Error, state.MessageError
+			redraw = true
+			break
+		}
+		depth := e.commandDepth
+		e.commandDepth = int(ev.Count)
+		e.mu.Unlock()
+		redraw, finish = e.executeCommands(ev.CmdName, strings.Split(ev.Arg, "\n"))
+		e.mu.Lock()
+		e.commandDepth = depth
+		e.mu.Unlock()
+		return
+	case event.Echo:
+		if v, err := expr.Eval(ev.Arg); err != nil {
+			e.err, e.errtyp = err, state.MessageError
+		} else {
+			e.err, e.errtyp = fmt.Errorf("%d 0x%x 0%o", v, uint64(v), uint64(v)), state.MessageInfo
+		}
+		redraw = true
+	case event.CancelTask, event.FinishTask:
+		if ev.Type == event.FinishTask && e.errtyp == state.MessageInfo {
+			e.err = nil
+		}
+		e.mu.Unlock()
+		e.wm.Emit(ev)
+		return
 	case event.Redraw:
 		width, height := e.ui.Size()
 		e.wm.Resize(width, height-1)
 		redraw = true
 	default:
+		switch ev.Type {
+		case event.StartInsert, event.StartInsertHead, event.StartAppend, event.StartAppendEnd,
+			event.StartReplaceByte, event.StartReplace:
+			if err := e.wm.Modifiable(); err != nil {
+				e.err, e.errtyp = err, state.MessageError
+				e.mu.Unlock()
+				return true, false
+			}
+		}
+		switch ev.Type {
+		case event.Yank, event.Paste, event.PasteBefore, event.ReplaceVisual:
+			ev.Rune = e.register
+		}
+		e.register = 0
 		switch ev.Type {
 		case event.StartInsert, event.StartInsertHead, event.StartAppend, event.StartAppendEnd:
 			e.mode, e.prevMode = mode.Insert, e.mode
@@ -111,10 +333,14 @@ func (e *Editor) emit(ev event.Event) (redraw bool, finish bool) {
 			e.mode, e.prevMode = mode.Replace, e.mode
 		case event.ExitInsert:
 			e.mode, e.prevMode = mode.Normal, e.mode
-		case event.StartVisual:
+		case event.StartVisual, event.StartVisualBlock:
 			e.mode, e.prevMode = mode.Visual, e.mode
 		case event.ExitVisual:
 			e.mode, e.prevMode = mode.Normal, e.mode
+		case event.Yank, event.ReplaceVisual:
+			if e.mode == mode.Visual {
+				e.mode, e.prevMode = mode.Normal, e.mode
+			}
 		case event.StartCmdlineCommand:
 			if e.mode == mode.Visual {
 				ev.Arg = "'<,'>"
@@ -131,18 +357,26 @@ func (e *Editor) emit(ev event.Event) (redraw bool, finish bool) {
 			e.mode, e.prevMode = mode.Search, e.mode
 			e.err = nil
 			e.searchMode = '?'
-		case event.ExitCmdline:
-			e.mode, e.prevMode = mode.Normal, e.mode
-		case event.ExecuteCmdline:
-			e.mode, e.prevMode = mode.Normal, e.mode
+		case event.StartCmdlineExpression:
+			e.mode, e.prevMode = mode.Expression, e.mode
+			e.err = nil
+		case event.StartCmdlinePassphrase:
+			e.mode, e.prevMode = mode.Passphrase, e.mode
+			e.err = nil
+		case event.ExitCmdline, event.ExecuteCmdline:
+			if e.mode == mode.Expression {
+				e.mode, e.prevMode = e.prevMode, e.mode
+			} else {
+				e.mode, e.prevMode = mode.Normal, e.mode
+			}
 		case event.ExecuteSearch:
-			e.searchTarget, e.searchMode = ev.Arg, ev.Rune
+			e.searchTarget, e.searchMode, e.searchLast = ev.Arg, ev.Rune, ev.Rune
 		case event.NextSearch:
-			ev.Arg, ev.Rune = e.searchTarget, e.searchMode
+			ev.Arg, ev.Rune = e.searchTarget, e.searchLast
 		case event.PreviousSearch:
-			ev.Arg, ev.Rune = e.searchTarget, e.searchMode
+			ev.Arg, ev.Rune = e.searchTarget, e.searchLast
 		}
-		if e.mode == mode.Cmdline || e.mode == mode.Search ||
+		if e.mode == mode.Cmdline || e.mode == mode.Search || e.mode == mode.Expression || e.mode == mode.Passphrase ||
 			ev.Type == event.ExitCmdline || ev.Type == event.ExecuteCmdline {
 			e.mu.Unlock()
 			e.cmdlineCh <- ev
@@ -153,7 +387,11 @@ func (e *Editor) emit(ev event.Event) (redraw bool, finish bool) {
 			ev.Mode = e.mode
 			width, height := e.ui.Size()
 			e.wm.Resize(width, height-1)
+			nested := e.commandDepth > 0
 			e.mu.Unlock()
+			if nested {
+				return e.emitNested(ev)
+			}
 			e.wm.Emit(ev)
 		}
 		return
@@ -162,6 +400,55 @@ func (e *Editor) emit(ev event.Event) (redraw bool, finish bool) {
 	return
 }
 
+// maxCommandDepth is the maximum depth of the user commands calling others.
+const maxCommandDepth = 20
+
+// executeCommands emits the command lines of the user command, which are in
+// the body of the command or the output of the plugin program.
+func (e *Editor) executeCommands(name string, lines []string) (redraw bool, finish bool) {
+	for _, line := range lines {
+		if line = strings.TrimSpace(line); line == "" {
+			continue
+		}
+		cmd, err := e.cmdline.Parse(line)
+		if err != nil {
+			e.mu.Lock()
+			e.err, e.errtyp = fmt.Errorf("%s: %s", name, err), state.MessageError
+			e.mu.Unlock()
+			return true, false
+		}
+		r, f := e.emit(cmd)
+		if redraw = redraw || r; f {
+			return false, true
+		}
+	}
+	return
+}
+
+// emitNested emits the event of the command in the user command to the window
+// manager, handling the events sent meanwhile. The manager sends the result to
+// the channel of one slot, which is read only by this goroutine, so the manager
+// would block on the second command without handling them.
+func (e *Editor) emitNested(ev event.Event) (redraw bool, finish bool) {
+	doneCh := make(chan struct{})
+	go func() {
+		defer close(doneCh)
+		e.wm.Emit(ev)
+	}()
+	for {
+		select {
+		case <-doneCh:
+			if finish {
+				return false, true
+			}
+			return
+		case ev := <-e.eventCh:
+			r, f := e.emit(ev)
+			redraw, finish = redraw || r, finish || f
+		}
+	}
+}
+
 // Open opens a new file.
 func (e *Editor) Open(filename string) (err error) {
 	return e.wm.Open(filename)
@@ -172,63 +459,236 @@ func (e *Editor) OpenEmpty() (err error) {
 	return e.wm.Open("")
 }
 
+// Recover restores the unsaved changes of the opened file from the swap file.
+func (e *Editor) Recover() error {
+	return e.wm.Recover()
+}
+
+// AddEventSource adds the source of the events in addition to the user
+// interface. The source is closed with the editor if it implements io.Closer.
+func (e *Editor) AddEventSource(src EventSource) {
+	e.sources = append(e.sources, src)
+}
+
+// CrashError is returned by Run when the editor panics. The user interface
+// is closed, and the unsaved changes of the files are kept in the swap files.
+type CrashError struct {
+	Value interface{}
+	Stack []byte
+	Files []string
+}
+
+func (err *CrashError) Error() string {
+	return fmt.Sprintf("panic: %v\n\n%s", err.Value, err.Stack)
+}
+
+// SignalError is returned by Run when the editor is terminated by the signal.
+// The user interface is closed, and the unsaved changes of the files are kept
+// in the swap files.
+type SignalError struct {
+	Signal string
+	Files  []string
+}
+
+func (err *SignalError) Error() string {
+	return "terminated by signal: " + err.Signal
+}
+
 // Run the editor.
-func (e *Editor) Run() error {
+func (e *Editor) Run() (err error) {
 	if err := e.ui.Init(e.eventCh); err != nil {
 		return err
 	}
+	for _, src := range e.sources {
+		if err := src.Init(e.eventCh); err != nil {
+			return err
+		}
+	}
 	if err := e.redraw(); err != nil {
 		return err
 	}
-	go e.ui.Run(defaultKeyManagers())
-	go e.cmdline.Run()
+	e.spawn(func() { e.ui.Run(e.kms) })
+	for _, src := range e.sources {
+		src := src
+		e.spawn(func() { src.Run(e.kms) })
+	}
+	e.spawn(e.cmdline.Run)
+	e.stopSignals = e.notifySignals()
+	defer func() {
+		if r := recover(); r != nil {
+			files := e.wm.Rescue()
+			_ = e.ui.Close()
+			err = &CrashError{Value: r, Stack: debug.Stack(), Files: files}
+		}
+	}()
 	e.listen()
+	if e.exitErr != nil {
+		_ = e.ui.Close()
+		return e.exitErr
+	}
 	return nil
 }
 
-func (e *Editor) redraw() (err error) {
+// spawn runs the function in a goroutine, which sends the panic to the editor
+// to keep the unsaved changes in the swap files as the panic of the editor.
+func (e *Editor) spawn(f func()) {
+	go func() {
+		defer event.RecoverPanic(e.eventCh, nil)
+		f()
+	}()
+}
+
+// redraw draws the snapshot of the state. The editor is not locked while
+// drawing, so the events are handled during the redraw.
+func (e *Editor) redraw() error {
+	e.redrawMu.Lock()
+	defer e.redrawMu.Unlock()
+	e.mu.Lock()
+	s, err := e.state()
+	if err != nil {
+		e.mu.Unlock()
+		return err
+	}
+	if e.bell {
+		if e.options.Bool("visualbell") {
+			s.Bell = state.BellVisual
+		} else if e.options.Bool("errorbells") {
+			s.Bell = state.BellAudible
+		}
+		e.bell = false
+	}
+	e.mu.Unlock()
+	return e.ui.Redraw(s)
+}
+
+// State returns the current state of the editor.
+func (e *Editor) State() (state.State, error) {
 	e.mu.Lock()
 	defer e.mu.Unlock()
-	var s state.State
+	return e.state()
+}
+
+func (e *Editor) state() (s state.State, err error) {
 	var windowIndex int
 	s.WindowStates, s.Layout, windowIndex, err = e.wm.State()
 	if err != nil {
-		return err
+		return s, err
 	}
 	if s.WindowStates[windowIndex] == nil {
-		return errors.New("index out of windows")
+		return s, errors.New("index out of windows")
 	}
 	s.WindowStates[windowIndex].Mode = e.mode
 	s.Mode, s.PrevMode, s.Error, s.ErrorType = e.mode, e.prevMode, e.err, e.errtyp
 	if s.Mode != mode.Visual && s.PrevMode != mode.Visual {
 		for _, ws := range s.WindowStates {
-			ws.VisualStart = -1
+			ws.VisualStart, ws.Selection = -1, nil
 		}
 	}
+	s.StatusLine = e.options.String("statusline")
+	if e.options.Bool("title") {
+		s.Title = title(s.WindowStates[windowIndex])
+	}
+	s.ColorScheme, s.DowngradeColors = e.scheme(e.colorScheme), !e.options.Bool("truecolor")
 	s.Cmdline, s.CmdlineCursor, s.CompletionResults, s.CompletionIndex = e.cmdline.Get()
 	if e.mode == mode.Search || e.prevEventType == event.ExecuteSearch {
 		s.SearchMode = e.searchMode
 	} else if e.prevEventType == event.NextSearch {
-		s.SearchMode, s.Cmdline = e.searchMode, []rune(e.searchTarget)
+		s.SearchMode, s.Cmdline = e.searchLast, []rune(e.searchTarget)
 	} else if e.prevEventType == event.PreviousSearch {
-		if e.searchMode == '/' {
+		if e.searchLast == '/' {
 			s.SearchMode, s.Cmdline = '?', []rune(e.searchTarget)
 		} else {
 			s.SearchMode, s.Cmdline = '/', []rune(e.searchTarget)
 		}
 	}
-	return e.ui.Redraw(s)
+	return s, nil
+}
+
+// title returns the title of the terminal for the window.
+func title(ws *state.WindowState) string {
+	name := ws.Name
+	if name == "" {
+		name = "[No name]"
+	}
+	if ws.Modified {
+		name += " [+]"
+	}
+	return "bed — " + name
+}
+
+func (e *Editor) setColorScheme(ev event.Event) error {
+	xs := strings.Fields(ev.Arg)
+	if len(xs) == 0 {
+		e.err, e.errtyp = errors.New(e.colorScheme), state.MessageInfo
+		return nil
+	}
+	name, defs := xs[0], xs[1:]
+	scheme := e.scheme(name)
+	if scheme == nil {
+		if len(defs) == 0 {
+			return fmt.Errorf("unknown color scheme: %s", name)
+		}
+		scheme = e.scheme("default")
+	}
+	scheme = scheme.Clone()
+	for _, def := range defs {
+		if err := scheme.Define(def); err != nil {
+			return err
+		}
+	}
+	e.schemes[name], e.colorScheme = scheme, name
+	return nil
+}
+
+func (e *Editor) scheme(name string) colorscheme.Scheme {
+	if scheme, ok := e.schemes[name]; ok {
+		return scheme
+	}
+	scheme, _ := colorscheme.Builtin(name)
+	return scheme
 }
 
 func (e *Editor) suspend() error {
+	atomic.StoreInt32(&e.suspended, 1)
 	return suspend(e)
 }
 
-// Close terminates the editor.
+// resume re-initializes the terminal and redraws the screen, after the editor
+// is stopped and continued by the signals, which the editor cannot handle.
+func (e *Editor) resume() error {
+	if err := e.ui.Close(); err != nil {
+		return err
+	}
+	if err := e.ui.Init(e.eventCh); err != nil {
+		return err
+	}
+	if err := e.redraw(); err != nil {
+		return err
+	}
+	e.spawn(func() { e.ui.Run(e.kms) })
+	return nil
+}
+
+// Close terminates the editor. The user interface, the event sources and the
+// window manager are closed first so that they stop before the channels are
+// closed.
 func (e *Editor) Close() error {
+	if e.stopSignals != nil {
+		e.stopSignals()
+	}
+	err := e.ui.Close()
+	for _, src := range e.sources {
+		if c, ok := src.(io.Closer); ok {
+			if cerr := c.Close(); cerr != nil && err == nil {
+				err = cerr
+			}
+		}
+	}
+	e.wm.Close()
+	e.cancel()
+	e.wg.Wait()
 	close(e.eventCh)
 	close(e.redrawCh)
 	close(e.cmdlineCh)
-	e.wm.Close()
-	return e.ui.Close()
+	return err
 }
@@ -1,12 +1,17 @@
 package editor
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"reflect"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -240,3 +245,800 @@ func TestEditorCmdlineQuit(t *testing.T) {
 		t.Errorf("err should be nil but got: %v", err)
 	}
 }
+
+func TestEditorInsertExpression(t *testing.T) {
+	ui := newTestUI()
+	editor := NewEditor(ui, window.NewManager(), cmdline.NewCmdline())
+	if err := editor.Init(); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	f, err := ioutil.TempFile("", "bed-test-editor-insert-expression")
+	if err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	if err := editor.Open(f.Name()); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	defer os.Remove(f.Name())
+	go func() {
+		ui.Emit(event.Event{Type: event.StartInsert})
+		ui.Emit(event.Event{Type: event.Rune, Rune: '1'})
+		ui.Emit(event.Event{Type: event.Rune, Rune: '2'})
+		ui.Emit(event.Event{Type: event.StartCmdlineExpression})
+		for _, c := range "0xdead^0xbeef" {
+			ui.Emit(event.Event{Type: event.Rune, Rune: c})
+		}
+		ui.Emit(event.Event{Type: event.ExecuteCmdline})
+		time.Sleep(100 * time.Millisecond)
+		ui.Emit(event.Event{Type: event.ExitInsert})
+		ui.Emit(event.Event{Type: event.Echo, Arg: "0x400+3*0x20"})
+		time.Sleep(100 * time.Millisecond)
+		editor.mu.Lock()
+		if err := editor.err; err == nil || err.Error() != "1120 0x460 02140" {
+			t.Errorf("err should be %q but got: %v", "1120 0x460 02140", err)
+		}
+		editor.mu.Unlock()
+		ui.Emit(event.Event{Type: event.WriteQuit})
+	}()
+	if err := editor.Run(); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	if err := editor.Close(); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	bs, err := ioutil.ReadFile(f.Name())
+	if err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	if string(bs) != "\x12\x60\x42" {
+		t.Errorf("file contents should be %q but got %q", "\x12\x60\x42", string(bs))
+	}
+}
+
+func TestEditorDebug(t *testing.T) {
+	ui := newTestUI()
+	editor := NewEditor(ui, window.NewManager(), cmdline.NewCmdline())
+	if err := editor.Init(); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	if err := editor.OpenEmpty(); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	go func() {
+		ui.Emit(event.Event{Type: event.Redraw})
+		ui.Emit(event.Event{Type: event.Debug})
+		time.Sleep(100 * time.Millisecond)
+		editor.mu.Lock()
+		if err := editor.err; err == nil {
+			t.Errorf("err should not be nil")
+		} else if xs := strings.Split(err.Error(), "\n"); len(xs) != 3 ||
+			!strings.HasPrefix(xs[0], "events: 1, latency last ") ||
+			!strings.HasPrefix(xs[1], "memory: heap ") ||
+			xs[2] != "  1 [No name]            0 bytes, 1 ranges (0 bytes in memory), 1 history entries (0 bytes)" {
+			t.Errorf("debug should show the internal state but got: %q", err.Error())
+		}
+		editor.mu.Unlock()
+		ui.Emit(event.Event{Type: event.QuitAll, Bang: true})
+	}()
+	if err := editor.Run(); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	if err := editor.Close(); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+}
+
+func TestEditorLoadConfig(t *testing.T) {
+	f, err := ioutil.TempFile("", "bed-test-editor-load-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.WriteString(`" comment
+set noruler wrapscan
+set statusline=%f %m
+map <c-j> pagedown
+vmap <space>x cursorend
+colorscheme mine edited=red/black
+`); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	editor := NewEditor(newTestUI(), window.NewManager(), cmdline.NewCmdline())
+	if err := editor.Init(); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	if err := editor.LoadConfig(f.Name()); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	if editor.options.Bool("ruler") || !editor.options.Bool("wrapscan") {
+		t.Errorf("boolean options should be set but got: %s, %s",
+			editor.options.Format("ruler"), editor.options.Format("wrapscan"))
+	}
+	if got := editor.options.String("statusline"); got != "%f %m" {
+		t.Errorf("statusline should be %q but got %q", "%f %m", got)
+	}
+	if e := editor.kms[mode.Normal].Press("c-j"); e.Type != event.PageDown {
+		t.Errorf("pressing c-j should emit event.PageDown but got: %d", e.Type)
+	}
+	editor.kms[mode.Visual].Press(" ")
+	if e := editor.kms[mode.Visual].Press("x"); e.Type != event.CursorEnd {
+		t.Errorf("pressing <space>x should emit event.CursorEnd but got: %d", e.Type)
+	}
+	if editor.colorScheme != "mine" || editor.scheme("mine")["edited"].Background != "black" {
+		t.Errorf("color scheme should be defined but got: %s, %+v", editor.colorScheme, editor.scheme("mine"))
+	}
+
+	if err := ioutil.WriteFile(f.Name(), []byte("set ruler\nquit\n"), 0644); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	expected := f.Name() + ":2: command not allowed in the configuration file: quit"
+	if err := editor.LoadConfig(f.Name()); err == nil || err.Error() != expected {
+		t.Errorf("err should be %q but got: %v", expected, err)
+	}
+	if err := editor.Close(); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+}
+
+func TestEditorYankPaste(t *testing.T) {
+	ui := newTestUI()
+	editor := NewEditor(ui, window.NewManager(), cmdline.NewCmdline())
+	if err := editor.Init(); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	f, err := ioutil.TempFile("", "bed-test-editor-yank-paste")
+	if err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.WriteString("Hello, world!"); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	if err := editor.Open(f.Name()); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	go func() {
+		for _, e := range []event.Event{
+			{Type: event.StartVisual},
+			{Type: event.CursorNext, Count: 4},
+			{Type: event.Yank},
+			{Type: event.CursorEnd},
+			{Type: event.Paste, Count: 2},
+		} {
+			ui.Emit(e)
+		}
+		time.Sleep(100 * time.Millisecond)
+		ui.Emit(event.Event{Type: event.WriteQuit})
+	}()
+	if err := editor.Run(); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	if editor.mode != mode.Normal {
+		t.Errorf("mode should be %d but got %d", mode.Normal, editor.mode)
+	}
+	if err := editor.Close(); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	bs, err := ioutil.ReadFile(f.Name())
+	if err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	if expected := "HellHelloHelloo, world!"; string(bs) != expected {
+		t.Errorf("file contents should be %q but got %q", expected, string(bs))
+	}
+}
+
+type crashUI struct {
+	*testUI
+	crash int32
+}
+
+func (ui *crashUI) Size() (int, int) {
+	if atomic.LoadInt32(&ui.crash) != 0 {
+		panic("crash")
+	}
+	return ui.testUI.Size()
+}
+
+func TestEditorCrashRecover(t *testing.T) {
+	f, err := ioutil.TempFile("", "bed-test-editor-crash-recover")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer os.Remove(f.Name() + ".bedswp")
+	if _, err := f.WriteString("Hello, world!"); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	ui := &crashUI{testUI: newTestUI()}
+	editor := NewEditor(ui, window.NewManager(), cmdline.NewCmdline())
+	if err := editor.Init(); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	if err := editor.Open(f.Name()); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	go func() {
+		ui.Emit(event.Event{Type: event.DeleteByte})
+		time.Sleep(100 * time.Millisecond)
+		atomic.StoreInt32(&ui.crash, 1)
+		ui.Emit(event.Event{Type: event.Redraw})
+	}()
+	err = editor.Run()
+	if err, ok := err.(*CrashError); !ok || err.Value != "crash" ||
+		!reflect.DeepEqual(err.Files, []string{f.Name()}) {
+		t.Errorf("err should be a crash error but got: %#v", err)
+	}
+	if _, err := os.Stat(f.Name() + ".bedswp"); err != nil {
+		t.Errorf("swap file should be kept but got: %v", err)
+	}
+
+	editor = NewEditor(newTestUI(), window.NewManager(), cmdline.NewCmdline())
+	if err := editor.Init(); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	if err := editor.Open(f.Name()); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	if err := editor.Recover(); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	s, err := editor.State()
+	if err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	if ws := s.WindowStates[0]; !ws.Modified || ws.Length != 12 || !strings.HasPrefix(string(ws.Bytes), "ello") {
+		t.Errorf("changes should be recovered but got: %q", string(ws.Bytes))
+	}
+	if err := editor.Close(); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+}
+
+func TestEditorCrashWindow(t *testing.T) {
+	f, err := ioutil.TempFile("", "bed-test-editor-crash-window")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer os.Remove(f.Name() + ".bedswp")
+	if _, err := f.WriteString("Hello, world!"); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	ui := newTestUI()
+	editor := NewEditor(ui, window.NewManager(), cmdline.NewCmdline())
+	if err := editor.Init(); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	if err := editor.Open(f.Name()); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	go func() {
+		ui.Emit(event.Event{Type: event.DeleteByte})
+		ui.Emit(event.Event{Type: event.StartInsert})
+		time.Sleep(100 * time.Millisecond)
+		ui.Emit(event.Event{Type: event.Undo})
+	}()
+	err = editor.Run()
+	if err, ok := err.(*CrashError); !ok || err.Value != "event.Undo should be emitted under normal mode" ||
+		!strings.Contains(string(err.Stack), "window.(*window).run") ||
+		!reflect.DeepEqual(err.Files, []string{f.Name()}) {
+		t.Errorf("err should be a crash error but got: %#v", err)
+	}
+	if _, err := os.Stat(f.Name() + ".bedswp"); err != nil {
+		t.Errorf("swap file should be kept but got: %v", err)
+	}
+}
+
+func TestEditorTerminate(t *testing.T) {
+	f, err := ioutil.TempFile("", "bed-test-editor-terminate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer os.Remove(f.Name() + ".bedswp")
+	if _, err := f.WriteString("Hello, world!"); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	ui := newTestUI()
+	editor := NewEditor(ui, window.NewManager(), cmdline.NewCmdline())
+	if err := editor.Init(); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	if err := editor.Open(f.Name()); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	go func() {
+		ui.Emit(event.Event{Type: event.DeleteByte})
+		time.Sleep(100 * time.Millisecond)
+		ui.Emit(event.Event{Type: event.Terminate, Arg: "hangup"})
+	}()
+	err = editor.Run()
+	if err, ok := err.(*SignalError); !ok || err.Error() != "terminated by signal: hangup" ||
+		!reflect.DeepEqual(err.Files, []string{f.Name()}) {
+		t.Errorf("err should be a signal error but got: %#v", err)
+	}
+	if _, err := os.Stat(f.Name() + ".bedswp"); err != nil {
+		t.Errorf("swap file should be kept but got: %v", err)
+	}
+}
+
+type bellUI struct {
+	*testUI
+	titles []string
+	bells  []int
+}
+
+func (ui *bellUI) Redraw(s state.State) error {
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
+	ui.titles, ui.bells = append(ui.titles, s.Title), append(ui.bells, s.Bell)
+	return nil
+}
+
+func TestEditorTitleBell(t *testing.T) {
+	ui := &bellUI{testUI: newTestUI()}
+	editor := NewEditor(ui, window.NewManager(), cmdline.NewCmdline())
+	if err := editor.Init(); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	if err := editor.OpenEmpty(); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	if err := editor.SetOption("errorbells"); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	go func() {
+		ui.Emit(event.Event{Type: event.Increment})
+		time.Sleep(100 * time.Millisecond)
+		ui.Emit(event.Event{Type: event.Error, Error: errors.New("pattern not found")})
+		time.Sleep(100 * time.Millisecond)
+		ui.Emit(event.Event{Type: event.QuitAll, Bang: true})
+	}()
+	if err := editor.Run(); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
+	if expected := "bed — [No name] [+]"; ui.titles[len(ui.titles)-1] != expected {
+		t.Errorf("title should be %q but got %q", expected, ui.titles[len(ui.titles)-1])
+	}
+	var bells int
+	for _, bell := range ui.bells {
+		if bell == state.BellAudible {
+			bells++
+		} else if bell != state.BellNone {
+			t.Errorf("bell should be audible but got: %d", bell)
+		}
+	}
+	if bells != 1 {
+		t.Errorf("bell should ring once but got: %d", bells)
+	}
+}
+
+type slowUI struct {
+	*testUI
+	redraws int32
+}
+
+func (ui *slowUI) Redraw(_ state.State) error {
+	atomic.AddInt32(&ui.redraws, 1)
+	time.Sleep(20 * time.Millisecond)
+	return nil
+}
+
+func TestEditorCoalesceRedraws(t *testing.T) {
+	ui := &slowUI{testUI: newTestUI()}
+	editor := NewEditor(ui, window.NewManager(), cmdline.NewCmdline())
+	if err := editor.Init(); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	if err := editor.OpenEmpty(); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	start := time.Now()
+	var elapsed time.Duration
+	go func() {
+		for i := 0; i < 50; i++ {
+			ui.Emit(event.Event{Type: event.Increment})
+		}
+		elapsed = time.Since(start)
+		time.Sleep(100 * time.Millisecond)
+		ui.Emit(event.Event{Type: event.QuitAll, Bang: true})
+	}()
+	if err := editor.Run(); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	if redraws := atomic.LoadInt32(&ui.redraws); redraws >= 50 {
+		t.Errorf("redraws should be coalesced but got: %d", redraws)
+	}
+	if elapsed >= 50*20*time.Millisecond {
+		t.Errorf("events should not wait for the redraws but took: %v", elapsed)
+	}
+	s, err := editor.State()
+	if err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	if ws := s.WindowStates[0]; ws.Bytes[0] != 50 {
+		t.Errorf("all the events should be handled but got: %d", ws.Bytes[0])
+	}
+}
+
+type resumeUI struct {
+	*testUI
+	inits int32
+}
+
+func (ui *resumeUI) Init(eventCh chan<- event.Event) error {
+	atomic.AddInt32(&ui.inits, 1)
+	return ui.testUI.Init(eventCh)
+}
+
+func TestEditorResume(t *testing.T) {
+	ui := &resumeUI{testUI: newTestUI()}
+	editor := NewEditor(ui, window.NewManager(), cmdline.NewCmdline())
+	if err := editor.Init(); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	if err := editor.OpenEmpty(); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	go func() {
+		ui.Emit(event.Event{Type: event.Resume})
+		time.Sleep(100 * time.Millisecond)
+		ui.Emit(event.Event{Type: event.Quit})
+	}()
+	if err := editor.Run(); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	if inits := atomic.LoadInt32(&ui.inits); inits != 2 {
+		t.Errorf("ui should be initialized again on resume but got: %d", inits)
+	}
+	if err := editor.Close(); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+}
+
+func TestEditorLoadPlugins(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bed-test-editor-load-plugins")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, path := range []string{"png", "notes"} {
+		if err := os.Mkdir(filepath.Join(dir, path), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for path, contents := range map[string]string{
+		"png/plugin.json": `{
+  "name": "png",
+  "description": "PNG chunk helpers",
+  "commands": { "PngIend": "goto 0|/IEND" },
+  "highlights": [ { "color": "yellow", "pattern": "/IHDR|IDAT|IEND/" } ],
+  "config": "config"
+}`,
+		"png/config":   "set wrapscan\ncommand PngHead goto 0\n",
+		"notes/README": "not a plugin",
+	} {
+		if err := ioutil.WriteFile(filepath.Join(dir, path), []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cmdline := cmdline.NewCmdline()
+	editor := NewEditor(newTestUI(), window.NewManager(), cmdline)
+	if err := editor.Init(); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	if err := editor.LoadPlugins(dir); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	if got := cmdline.UserCommands(); len(got) != 2 ||
+		!strings.HasPrefix(got[0], "PngHead ") || !strings.HasPrefix(got[1], "PngIend ") {
+		t.Errorf("user commands should be defined but got: %v", got)
+	}
+	if !editor.options.Bool("wrapscan") {
+		t.Errorf("wrapscan should be set by the plugin config")
+	}
+	if got, expected := editor.pluginList(), []string{"png          PNG chunk helpers"}; !reflect.DeepEqual(got, expected) {
+		t.Errorf("plugins should be %q but got %q", expected, got)
+	}
+
+	expected := filepath.Join(dir, "png", "plugin.json") + ": plugin already loaded: png"
+	if err := editor.LoadPlugins(dir); err == nil || err.Error() != expected {
+		t.Errorf("err should be %q but got: %v", expected, err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "notes", "plugin.json"),
+		[]byte(`{"highlights": [ { "color": "red" } ]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	expected = filepath.Join(dir, "notes", "plugin.json") + ": highlight pattern is empty"
+	if err := editor.LoadPlugins(dir); err == nil || err.Error() != expected {
+		t.Errorf("err should be %q but got: %v", expected, err)
+	}
+	if err := editor.LoadPlugins(filepath.Join(dir, "nonexistent")); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	if err := editor.Close(); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+}
+
+func TestEditorPluginProgram(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bed-test-editor-plugin-program")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := os.Mkdir(filepath.Join(dir, "patch"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for path, contents := range map[string]string{
+		"patch/plugin.json": `{ "programs": { "Patch": "patch.sh", "Fail": "fail.sh" } }`,
+		"patch/patch.sh":    "#!/bin/sh\ncat > request.json\necho \"goto $1\"\necho insertbytes $2 0x41\n",
+		"patch/fail.sh":     "#!/bin/sh\necho broken chunk >&2\nexit 1\n",
+		"contents":          "Hello, world!",
+	} {
+		if err := ioutil.WriteFile(filepath.Join(dir, path), []byte(contents), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ui := newTestUI()
+	cmdline := cmdline.NewCmdline()
+	editor := NewEditor(ui, window.NewManager(), cmdline)
+	if err := editor.Init(); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	if err := editor.LoadPlugins(dir); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	if err := editor.Open(filepath.Join(dir, "contents")); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	go func() {
+		for _, line := range []string{"goto 7", "Patch 3 2", "Fail"} {
+			e, err := cmdline.Parse(line)
+			if err != nil {
+				t.Errorf("err should be nil but got: %v", err)
+			}
+			ui.Emit(e)
+			time.Sleep(50 * time.Millisecond)
+		}
+		time.Sleep(100 * time.Millisecond)
+		ui.Emit(event.Event{Type: event.WriteQuit})
+	}()
+	if err := editor.Run(); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	if expected := "Fail: broken chunk"; editor.err == nil || editor.err.Error() != expected {
+		t.Errorf("err should be %q but got: %v", expected, editor.err)
+	}
+	if err := editor.Close(); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	bs, err := ioutil.ReadFile(filepath.Join(dir, "contents"))
+	if err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	if expected := "HelAAlo, world!"; string(bs) != expected {
+		t.Errorf("file contents should be %q but got %q", expected, string(bs))
+	}
+	bs, err = ioutil.ReadFile(filepath.Join(dir, "patch", "request.json"))
+	if err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	expected := programRequest{Command: "Patch", Args: "3 2",
+		Filename: filepath.Join(dir, "contents"), Cursor: 7, Length: 13, Bytes: []byte("world!")}
+	var got programRequest
+	if err := json.Unmarshal(bs, &got); err != nil || !reflect.DeepEqual(got, expected) {
+		t.Errorf("request should be %+v but got %+v, %v", expected, got, err)
+	}
+}
+
+func TestEditorPluginProgramBackground(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bed-test-editor-plugin-background")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := os.Mkdir(filepath.Join(dir, "slow"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for path, contents := range map[string]string{
+		"slow/plugin.json": `{ "programs": { "Slow": "slow.sh" } }`,
+		"slow/slow.sh":     "#!/bin/sh\nsleep 0.5\necho goto 1\necho insertbytes 1 0x42\n",
+		"contents":         "Hello, world!",
+	} {
+		if err := ioutil.WriteFile(filepath.Join(dir, path), []byte(contents), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ui := newTestUI()
+	cmdline := cmdline.NewCmdline()
+	editor := NewEditor(ui, window.NewManager(), cmdline)
+	if err := editor.Init(); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	if err := editor.LoadPlugins(dir); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	if err := editor.Open(filepath.Join(dir, "contents")); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	go func() {
+		start := time.Now()
+		for _, line := range []string{"Slow", "goto 7", "insertbytes 1 0x41", "goto 0"} {
+			e, err := cmdline.Parse(line)
+			if err != nil {
+				t.Errorf("err should be nil but got: %v", err)
+			}
+			ui.Emit(e)
+		}
+		if elapsed := time.Since(start); elapsed > 300*time.Millisecond {
+			t.Errorf("events should be handled while the program runs but took %v", elapsed)
+		}
+		time.Sleep(time.Second)
+		ui.Emit(event.Event{Type: event.WriteQuit})
+	}()
+	if err := editor.Run(); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	if err := editor.Close(); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	bs, err := ioutil.ReadFile(filepath.Join(dir, "contents"))
+	if err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	if expected := "HBello, Aworld!"; string(bs) != expected {
+		t.Errorf("file contents should be %q but got %q", expected, string(bs))
+	}
+}
+
+func TestEditorUserCommand(t *testing.T) {
+	ui := newTestUI()
+	cmdline := cmdline.NewCmdline()
+	editor := NewEditor(ui, window.NewManager(), cmdline)
+	if err := editor.Init(); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	f, err := ioutil.TempFile("", "bed-test-editor-user-command")
+	if err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.WriteString("command Patch goto <args> | insertbytes 2 0x41\ncommand Loop Loop\n" +
+		"command Ruler set ruler | set noruler | set ruler? | set ruler?\n"); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	if err := editor.LoadConfig(f.Name()); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	if err := ioutil.WriteFile(f.Name(), []byte("Hello, world!"), 0644); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	if err := editor.Open(f.Name()); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	go func() {
+		for _, line := range []string{"Ruler", "Patch 3", "Loop"} {
+			e, err := cmdline.Parse(line)
+			if err != nil {
+				t.Errorf("err should be nil but got: %v", err)
+			}
+			ui.Emit(e)
+			time.Sleep(50 * time.Millisecond)
+		}
+		time.Sleep(100 * time.Millisecond)
+		ui.Emit(event.Event{Type: event.WriteQuit})
+	}()
+	if err := editor.Run(); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	if expected := "user commands nested too deeply: Loop"; editor.err == nil || editor.err.Error() != expected {
+		t.Errorf("err should be %q but got: %v", expected, editor.err)
+	}
+	if err := editor.Close(); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	bs, err := ioutil.ReadFile(f.Name())
+	if err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	if expected := "HelAAlo, world!"; string(bs) != expected {
+		t.Errorf("file contents should be %q but got %q", expected, string(bs))
+	}
+}
+
+func TestEditorScript(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bed-test-editor-script")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for path, contents := range map[string]string{
+		"init.star": `
+def patch(args):
+    bed.write(int(args), bed.read(0, 2))
+    bed.goto(0)
+
+def cursor(offset):
+    if offset == 3:
+        bed.write(offset, "L")
+
+bed.command("Patch", patch)
+bed.map("<C-p>", ":Patch 7 | goto 3")
+bed.hook("cursor", cursor)
+bed.hook("save", lambda name: bed.goto(bed.length() - len(name.split("/")[-1])))
+`,
+		"error.star": `bed.hook("close", print)`,
+		"contents":   "Hello, world!",
+	} {
+		if err := ioutil.WriteFile(filepath.Join(dir, path), []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ui := newTestUI()
+	cmdline := cmdline.NewCmdline()
+	editor := NewEditor(ui, window.NewManager(), cmdline)
+	if err := editor.Init(); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	if err := editor.LoadScript(filepath.Join(dir, "init.star")); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	if err, expected := editor.LoadScript(filepath.Join(dir, "error.star")),
+		"hook: unknown hook: close"; err == nil || !strings.Contains(err.Error(), expected) {
+		t.Errorf("err should contain %q but got: %v", expected, err)
+	}
+	if err := editor.Open(filepath.Join(dir, "contents")); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	go func() {
+		for _, line := range []string{"Patch 7", "goto 3", "write", "quit"} {
+			e, err := cmdline.Parse(line)
+			if err != nil {
+				t.Errorf("err should be nil but got: %v", err)
+			}
+			ui.Emit(e)
+			time.Sleep(50 * time.Millisecond)
+		}
+	}()
+	if err := editor.Run(); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	if expected := int64(5); editor.lastCursor != expected {
+		t.Errorf("cursor should be %d but got: %d", expected, editor.lastCursor)
+	}
+	if err := editor.Close(); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	bs, err := ioutil.ReadFile(filepath.Join(dir, "contents"))
+	if err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	if expected := "HelLo, Herld!"; string(bs) != expected {
+		t.Errorf("file contents should be %q but got %q", expected, string(bs))
+	}
+}
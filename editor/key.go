@@ -14,6 +14,7 @@ func defaultKeyManagers() map[mode.Mode]*key.Manager {
 	km.Register(event.Quit, "c-w", "c-q")
 	km.Register(event.Quit, "c-w", "c")
 	km.Register(event.Suspend, "c-z")
+	km.Register(event.CancelTask, "c-c")
 
 	km.Register(event.CursorUp, "up")
 	km.Register(event.CursorDown, "down")
@@ -34,14 +35,38 @@ func defaultKeyManagers() map[mode.Mode]*key.Manager {
 	km.Register(event.CursorEnd, "$")
 	km.Register(event.ScrollUp, "c-y")
 	km.Register(event.ScrollDown, "c-e")
+	km.Register(event.ScrollCursorCenter, "z", "z")
+	km.Register(event.ScrollCursorCenter, "z", ".")
+	km.Register(event.ScrollCursorTop, "z", "t")
+	km.Register(event.ScrollCursorBottom, "z", "b")
 	km.Register(event.PageUp, "c-b")
 	km.Register(event.PageDown, "c-f")
 	km.Register(event.PageUpHalf, "c-u")
 	km.Register(event.PageDownHalf, "c-d")
 	km.Register(event.PageTop, "g", "g")
 	km.Register(event.PageEnd, "G")
+	km.Register(event.GotoPercent, "%")
 	km.Register(event.JumpTo, "\x1d")
+	km.Register(event.JumpToPointer, "g", "f")
+	km.Register(event.JumpToPointer, "g", "F")
 	km.Register(event.JumpBack, "c-t")
+	km.Register(event.JumpBack, "c-o")
+	km.Register(event.JumpForward, "c-n")
+	km.Register(event.GotoMark, "`", "`")
+	km.Register(event.GotoMarkLine, "'", "'")
+	for c := 'a'; c <= 'z'; c++ {
+		km.Register(event.SetMark, "m", key.Key(c))
+		km.Register(event.GotoMark, "`", key.Key(c))
+		km.Register(event.GotoMarkLine, "'", key.Key(c))
+	}
+	km.Register(event.NextChange, "]", "e")
+	km.Register(event.PreviousChange, "[", "e")
+	km.Register(event.NextString, ")", "s")
+	km.Register(event.PreviousString, "(", "s")
+	km.Register(event.NextData, "}")
+	km.Register(event.PreviousData, "{")
+	km.Register(event.NextPacket, "]", "p")
+	km.Register(event.PreviousPacket, "[", "p")
 	km.Register(event.DeleteByte, "x")
 	km.Register(event.DeletePrevByte, "X")
 	km.Register(event.Increment, "c-a")
@@ -56,10 +81,18 @@ func defaultKeyManagers() map[mode.Mode]*key.Manager {
 	km.Register(event.StartReplaceByte, "r")
 	km.Register(event.StartReplace, "R")
 
+	for _, c := range []string{`"`, "+", "*"} {
+		km.Register(event.SelectRegister, `"`, key.Key(c))
+	}
+	km.Register(event.Yank, "y")
+	km.Register(event.Paste, "p")
+	km.Register(event.PasteBefore, "P")
+
 	km.Register(event.Undo, "u")
 	km.Register(event.Redo, "c-r")
 
 	km.Register(event.StartVisual, "v")
+	km.Register(event.StartVisualBlock, "c-v")
 
 	km.Register(event.SwitchFocus, "tab")
 	km.Register(event.SwitchFocus, "backtab")
@@ -71,6 +104,11 @@ func defaultKeyManagers() map[mode.Mode]*key.Manager {
 
 	km.Register(event.New, "c-w", "n")
 	km.Register(event.New, "c-w", "c-n")
+	km.Register(event.Split, "c-w", "s")
+	km.Register(event.Split, "c-w", "S")
+	km.Register(event.Split, "c-w", "c-s")
+	km.Register(event.Vsplit, "c-w", "v")
+	km.Register(event.Vsplit, "c-w", "c-v")
 	km.Register(event.FocusWindowDown, "c-w", "down")
 	km.Register(event.FocusWindowDown, "c-w", "c-j")
 	km.Register(event.FocusWindowDown, "c-w", "j")
@@ -94,6 +132,11 @@ func defaultKeyManagers() map[mode.Mode]*key.Manager {
 	km.Register(event.MoveWindowBottom, "c-w", "J")
 	km.Register(event.MoveWindowLeft, "c-w", "H")
 	km.Register(event.MoveWindowRight, "c-w", "L")
+	km.Register(event.IncreaseWindowHeight, "c-w", "+")
+	km.Register(event.DecreaseWindowHeight, "c-w", "-")
+	km.Register(event.IncreaseWindowWidth, "c-w", ">")
+	km.Register(event.DecreaseWindowWidth, "c-w", "<")
+	km.Register(event.EqualizeWindows, "c-w", "=")
 	kms[mode.Normal] = km
 
 	km = key.NewManager(false)
@@ -116,15 +159,24 @@ func defaultKeyManagers() map[mode.Mode]*key.Manager {
 	km.Register(event.Delete, "delete")
 	km.Register(event.SwitchFocus, "tab")
 	km.Register(event.SwitchFocus, "backtab")
+	km.Register(event.StartCmdlineExpression, "c-r", "=")
+	km.Register(event.StartLiteral, "c-v")
 	kms[mode.Insert] = km
 	kms[mode.Replace] = km
 
-	km = key.NewManager(false)
+	km = key.NewManager(true)
 	km.Register(event.ExitVisual, "escape")
 	km.Register(event.ExitVisual, "c-c")
 	km.Register(event.SwitchVisualEnd, "o")
 	km.Register(event.SwitchVisualEnd, "O")
+	km.Register(event.SwitchVisualBlock, "c-v")
 	km.Register(event.StartCmdlineCommand, ":")
+	for _, c := range []string{`"`, "+", "*"} {
+		km.Register(event.SelectRegister, `"`, key.Key(c))
+	}
+	km.Register(event.Yank, "y")
+	km.Register(event.ReplaceVisual, "p")
+	km.Register(event.ReplaceVisual, "P")
 
 	km.Register(event.CursorUp, "up")
 	km.Register(event.CursorDown, "down")
@@ -145,12 +197,23 @@ func defaultKeyManagers() map[mode.Mode]*key.Manager {
 	km.Register(event.CursorEnd, "$")
 	km.Register(event.ScrollUp, "c-y")
 	km.Register(event.ScrollDown, "c-e")
+	km.Register(event.ScrollCursorCenter, "z", "z")
+	km.Register(event.ScrollCursorCenter, "z", ".")
+	km.Register(event.ScrollCursorTop, "z", "t")
+	km.Register(event.ScrollCursorBottom, "z", "b")
 	km.Register(event.PageUp, "c-b")
 	km.Register(event.PageDown, "c-f")
 	km.Register(event.PageUpHalf, "c-u")
 	km.Register(event.PageDownHalf, "c-d")
 	km.Register(event.PageTop, "g", "g")
 	km.Register(event.PageEnd, "G")
+	km.Register(event.GotoPercent, "%")
+	km.Register(event.NextString, ")", "s")
+	km.Register(event.PreviousString, "(", "s")
+	km.Register(event.NextData, "}")
+	km.Register(event.PreviousData, "{")
+	km.Register(event.NextPacket, "]", "p")
+	km.Register(event.PreviousPacket, "[", "p")
 	km.Register(event.SwitchFocus, "tab")
 	km.Register(event.SwitchFocus, "backtab")
 	kms[mode.Visual] = km
@@ -175,10 +238,26 @@ func defaultKeyManagers() map[mode.Mode]*key.Manager {
 	km.Register(event.ExitCmdline, "c-c")
 	km.Register(event.CompleteForwardCmdline, "tab")
 	km.Register(event.CompleteBackCmdline, "backtab")
+	km.Register(event.PrevHistoryCmdline, "up")
+	km.Register(event.PrevHistoryCmdline, "c-p")
+	km.Register(event.NextHistoryCmdline, "down")
+	km.Register(event.NextHistoryCmdline, "c-n")
 	km.Register(event.ExecuteCmdline, "enter")
 	km.Register(event.ExecuteCmdline, "c-j")
 	km.Register(event.ExecuteCmdline, "c-m")
 	kms[mode.Cmdline] = km
 	kms[mode.Search] = km
+	kms[mode.Expression] = km
+	kms[mode.Passphrase] = km
+
+	km = key.NewManager(false)
+	km.Register(event.ConfirmSubstitute, "y")
+	km.Register(event.ConfirmSubstitute, "n")
+	km.Register(event.ConfirmSubstitute, "a")
+	km.Register(event.ConfirmSubstitute, "l")
+	km.Register(event.ConfirmSubstitute, "q")
+	km.Register(event.ConfirmSubstitute, "escape")
+	km.Register(event.ConfirmSubstitute, "c-c")
+	kms[mode.Confirm] = km
 	return kms
 }
@@ -3,6 +3,7 @@ package editor
 import (
 	"github.com/itchyny/bed/event"
 	"github.com/itchyny/bed/layout"
+	"github.com/itchyny/bed/option"
 	"github.com/itchyny/bed/state"
 )
 
@@ -12,7 +13,17 @@ type Manager interface {
 	Open(string) error
 	SetSize(int, int)
 	Resize(int, int)
+	SetOptions(*option.Options)
 	Emit(event.Event)
+	Modifiable() error
+	Modified() bool
+	ReadCursor(int) (string, int64, int64, []byte, error)
+	ReadBytes(int64, int) ([]byte, error)
+	TakeHooks() []event.Event
+	AddHighlight(string, string) error
+	Recover() error
+	Rescue() []string
+	Debug() []string
 	State() (map[int]*state.WindowState, layout.Layout, int, error)
 	Close()
 }
@@ -0,0 +1,246 @@
+package editor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/itchyny/bed/event"
+)
+
+// manifestName is the file name of the plugin manifest.
+const manifestName = "plugin.json"
+
+// manifest describes the plugin. The commands are defined as the user
+// commands, the highlights are added as the highlight rules, and the config
+// file in the plugin directory is loaded in the same way as the configuration
+// file, so it can set options, map keys and define more commands. The
+// programs are the user commands executed by the programs in the plugin
+// directory (see startProgram).
+//
+//	{
+//	  "name": "png",
+//	  "description": "PNG chunk helpers",
+//	  "commands": { "PngIend": "go 0|/IEND" },
+//	  "programs": { "PngChunk": "bin/png-chunk" },
+//	  "highlights": [ { "color": "yellow", "pattern": "/IHDR|IDAT|IEND/" } ],
+//	  "config": "config"
+//	}
+type manifest struct {
+	Name        string            `json:"name"`
+	Description string            `json:"description"`
+	Commands    map[string]string `json:"commands"`
+	Programs    map[string]string `json:"programs"`
+	Highlights  []struct {
+		Color   string `json:"color"`
+		Pattern string `json:"pattern"`
+	} `json:"highlights"`
+	Config string `json:"config"`
+}
+
+// plugin is the loaded plugin.
+type plugin struct {
+	name        string
+	description string
+}
+
+// program is the program of the plugin, which is executed in the plugin
+// directory.
+type program struct {
+	path string
+	dir  string
+}
+
+// LoadPlugins loads the plugins in the subdirectories of the directory, each
+// of which has the plugin.json manifest. When the directory is empty, it
+// loads the plugins in the default plugin directory if exists.
+func (e *Editor) LoadPlugins(dir string) error {
+	if dir == "" {
+		var err error
+		if dir, err = configDir(); err != nil {
+			return nil
+		}
+		dir = filepath.Join(dir, "plugins")
+	}
+	fis, err := ioutil.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	for _, fi := range fis {
+		if !fi.IsDir() {
+			continue
+		}
+		if err := e.loadPlugin(filepath.Join(dir, fi.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *Editor) loadPlugin(dir string) error {
+	path := filepath.Join(dir, manifestName)
+	bs, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	var m manifest
+	if err := json.Unmarshal(bs, &m); err != nil {
+		return fmt.Errorf("%s: %s", path, err)
+	}
+	if m.Name == "" {
+		m.Name = filepath.Base(dir)
+	}
+	for _, p := range e.plugins {
+		if p.name == m.Name {
+			return fmt.Errorf("%s: plugin already loaded: %s", path, m.Name)
+		}
+	}
+	names := make([]string, 0, len(m.Commands))
+	for name := range m.Commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := e.cmdline.DefineCommand(name, m.Commands[name]); err != nil {
+			return fmt.Errorf("%s: %s", path, err)
+		}
+	}
+	names = names[:0]
+	for name := range m.Programs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		p := m.Programs[name]
+		if p == "" {
+			return fmt.Errorf("%s: program is empty: %s", path, name)
+		}
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(dir, p)
+		}
+		if _, err := os.Stat(p); err != nil {
+			return fmt.Errorf("%s: %s", path, err)
+		}
+		if err := e.cmdline.DefineProgram(name, p); err != nil {
+			return fmt.Errorf("%s: %s", path, err)
+		}
+		if e.programs == nil {
+			e.programs = make(map[string]*program)
+		}
+		e.programs[name] = &program{path: p, dir: dir}
+		delete(e.scripts, name)
+	}
+	for _, h := range m.Highlights {
+		if err := e.wm.AddHighlight(h.Color, h.Pattern); err != nil {
+			return fmt.Errorf("%s: %s", path, err)
+		}
+	}
+	if m.Config != "" {
+		if err := e.LoadConfig(filepath.Join(dir, m.Config)); err != nil {
+			return err
+		}
+	}
+	e.plugins = append(e.plugins, &plugin{name: m.Name, description: m.Description})
+	return nil
+}
+
+// pluginList returns the names and the descriptions of the loaded plugins.
+func (e *Editor) pluginList() []string {
+	xs := make([]string, len(e.plugins))
+	for i, p := range e.plugins {
+		xs[i] = fmt.Sprintf("%-12s %s", p.name, p.description)
+	}
+	return xs
+}
+
+// programTimeout is the time limit of the plugin program.
+const programTimeout = 10 * time.Second
+
+// programReadLength is the maximum number of the bytes from the cursor passed
+// to the plugin program.
+const programReadLength = 1 << 20
+
+// programRequest is written to the standard input of the plugin program in
+// JSON. The bytes from the cursor are encoded in base64.
+type programRequest struct {
+	Command  string `json:"command"`
+	Args     string `json:"args"`
+	Filename string `json:"filename"`
+	Cursor   int64  `json:"cursor"`
+	Length   int64  `json:"length"`
+	Bytes    []byte `json:"bytes"`
+}
+
+// startProgram starts the plugin program of the command in the background, so
+// that the editor handles the events while the program runs. The program is
+// invoked with the arguments of the command, and reads the request of the
+// current window from the standard input. The program can move the cursor,
+// edit the bytes and define commands and mappings by the command lines of the
+// standard output, like the user commands, which are sent to the editor by the
+// ProgramOutput event with the depth of the nested commands.
+func (e *Editor) startProgram(ev event.Event, depth int) error {
+	e.mu.Lock()
+	p, ok := e.programs[ev.CmdName]
+	e.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("no such program: %s", ev.CmdName)
+	}
+	req := programRequest{Command: ev.CmdName, Args: ev.Arg}
+	var err error
+	if req.Filename, req.Cursor, req.Length, req.Bytes, err =
+		e.wm.ReadCursor(programReadLength); err != nil {
+		return err
+	}
+	bs, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	e.wg.Add(1)
+	e.spawn(func() {
+		defer e.wg.Done()
+		out := event.Event{Type: event.ProgramOutput, CmdName: ev.CmdName, Count: int64(depth)}
+		out.Arg, out.Error = runProgram(e.ctx, p, ev, bs)
+		select {
+		case e.eventCh <- out:
+		case <-e.ctx.Done():
+		}
+	})
+	return nil
+}
+
+// runProgram executes the plugin program with the request, and returns the
+// standard output. When the program exits with an error, the standard error
+// output is reported as the error. The program is killed on closing the
+// editor.
+func runProgram(ctx context.Context, p *program, ev event.Event, req []byte) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, programTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, p.path, strings.Fields(ev.Arg)...)
+	var stdout, stderr bytes.Buffer
+	cmd.Dir, cmd.Stdin, cmd.Stdout, cmd.Stderr = p.dir, bytes.NewReader(req), &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return "", fmt.Errorf("%s: program timed out", ev.CmdName)
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			err = errors.New(msg)
+		}
+		return "", fmt.Errorf("%s: %s", ev.CmdName, err)
+	}
+	return stdout.String(), nil
+}
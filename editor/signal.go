@@ -0,0 +1,43 @@
+package editor
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/itchyny/bed/event"
+)
+
+// notifySignals handles the signals; the editor terminates on SIGTERM and
+// SIGHUP keeping the unsaved changes in the swap files, and handles the
+// signals of the job control on the platforms supporting them. It returns
+// the function to stop handling the signals.
+func (e *Editor) notifySignals() func() {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, append([]os.Signal{syscall.SIGTERM, syscall.SIGHUP}, jobControlSignals...)...)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case sig := <-ch:
+				ev, ok := event.Event{Type: event.Terminate, Arg: sig.String()}, true
+				if sig != syscall.SIGTERM && sig != syscall.SIGHUP {
+					if ev, ok = e.jobControlEvent(sig); !ok {
+						continue
+					}
+				}
+				select {
+				case e.eventCh <- ev:
+				case <-done:
+					return
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		signal.Stop(ch)
+		close(done)
+	}
+}
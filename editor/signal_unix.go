@@ -0,0 +1,26 @@
+// +build !windows
+
+package editor
+
+import (
+	"os"
+	"sync/atomic"
+	"syscall"
+
+	"github.com/itchyny/bed/event"
+)
+
+var jobControlSignals = []os.Signal{syscall.SIGTSTP, syscall.SIGCONT}
+
+// jobControlEvent returns the event for the signal of the job control; the
+// editor suspends on SIGTSTP (kill -TSTP), and re-initializes the terminal on
+// SIGCONT unless the editor resumes from its own suspension.
+func (e *Editor) jobControlEvent(sig os.Signal) (event.Event, bool) {
+	if sig == syscall.SIGCONT {
+		if atomic.CompareAndSwapInt32(&e.suspended, 1, 0) {
+			return event.Event{}, false
+		}
+		return event.Event{Type: event.Resume}, true
+	}
+	return event.Event{Type: event.Suspend}, true
+}
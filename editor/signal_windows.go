@@ -0,0 +1,15 @@
+// +build windows
+
+package editor
+
+import (
+	"os"
+
+	"github.com/itchyny/bed/event"
+)
+
+var jobControlSignals []os.Signal
+
+func (e *Editor) jobControlEvent(_ os.Signal) (event.Event, bool) {
+	return event.Event{}, false
+}
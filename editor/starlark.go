@@ -0,0 +1,384 @@
+package editor
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+
+	"github.com/itchyny/bed/event"
+	"github.com/itchyny/bed/state"
+)
+
+// scriptName is the file name of the script in the configuration directory.
+const scriptName = "init.star"
+
+// scriptMaxSteps is the limit of the execution steps of a call of the script,
+// so that the script in an infinite loop does not stall the editor.
+const scriptMaxSteps = 10000000
+
+// scriptReadLength is the maximum number of the bytes read by bed.read.
+const scriptReadLength = 1 << 20
+
+// scriptHooks are the names of the hooks; open and save are called with the
+// file name, and cursor is called with the offset of the cursor.
+var scriptHooks = []string{"open", "save", "cursor"}
+
+// scriptCall is the state of a call of the script function, which holds the
+// results of the events emitted by the script.
+type scriptCall struct {
+	redraw bool
+	finish bool
+}
+
+// LoadScript loads the Starlark script, which defines the commands, the key
+// mappings and the hooks by the functions of the bed module. When the filename
+// is empty, it loads init.star in the configuration directory if exists.
+//
+//	def patch(args):
+//	    bed.write(bed.cursor(), b"\x90\x90")
+//
+//	bed.command("Nop", patch)
+//	bed.map("<C-n>", ":Nop")
+//	bed.hook("save", lambda name: print("saved " + name))
+func (e *Editor) LoadScript(filename string) error {
+	if filename == "" {
+		dir, err := configDir()
+		if err != nil {
+			return nil
+		}
+		if filename = filepath.Join(dir, scriptName); !fileExists(filename) {
+			return nil
+		}
+	}
+	thread := e.scriptThread(filename, nil)
+	if _, err := starlark.ExecFile(thread, filename, nil,
+		starlark.StringDict{"bed": e.scriptModule()}); err != nil {
+		if err, ok := err.(*starlark.EvalError); ok {
+			return errors.New(err.Backtrace())
+		}
+		return err
+	}
+	return nil
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// scriptThread creates the thread to execute the script. The call is nil on
+// loading the script, when the commands are executed as the configuration.
+func (e *Editor) scriptThread(name string, call *scriptCall) *starlark.Thread {
+	thread := &starlark.Thread{
+		Name: name,
+		Print: func(_ *starlark.Thread, msg string) {
+			e.mu.Lock()
+			defer e.mu.Unlock()
+			e.err, e.errtyp = errors.New(msg), state.MessageInfo
+		},
+	}
+	thread.SetMaxExecutionSteps(scriptMaxSteps)
+	thread.SetLocal("call", call)
+	return thread
+}
+
+// callScript calls the function of the script as the nested command, and
+// reports the error of the function.
+func (e *Editor) callScript(name string, fn starlark.Callable, args ...starlark.Value) (redraw bool, finish bool) {
+	e.mu.Lock()
+	if e.commandDepth >= maxCommandDepth {
+		e.err, e.errtyp = fmt.Errorf("user commands nested too deeply: %s", name), state.MessageError
+		e.mu.Unlock()
+		return true, false
+	}
+	e.commandDepth++
+	e.mu.Unlock()
+	call := new(scriptCall)
+	_, err := starlark.Call(e.scriptThread(name, call), fn, args, nil)
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.commandDepth--
+	if call.finish {
+		return false, true
+	}
+	if err != nil {
+		e.err, e.errtyp = fmt.Errorf("%s: %s", name, err), state.MessageError
+		return true, false
+	}
+	return call.redraw, false
+}
+
+// runHooks calls the hooks of the scripts on opening and writing the files,
+// and on moving the cursor, after handling each event.
+func (e *Editor) runHooks() (redraw bool, finish bool) {
+	events := e.wm.TakeHooks()
+	e.mu.Lock()
+	hooks := e.hooks
+	e.mu.Unlock()
+	if len(hooks) == 0 {
+		return
+	}
+	for _, ev := range events {
+		name := "open"
+		if ev.Type == event.Write {
+			name = "save"
+		}
+		for _, fn := range hooks[name] {
+			r, f := e.callScript(name, fn, starlark.String(ev.Arg))
+			if f {
+				return false, true
+			}
+			redraw = redraw || r
+		}
+	}
+	if len(hooks["cursor"]) == 0 {
+		return
+	}
+	filename, cursor, _, _, err := e.wm.ReadCursor(0)
+	if err != nil || filename == e.lastFilename && cursor == e.lastCursor {
+		return
+	}
+	e.lastFilename, e.lastCursor = filename, cursor
+	for _, fn := range hooks["cursor"] {
+		r, f := e.callScript("cursor", fn, starlark.MakeInt64(cursor))
+		if f {
+			return false, true
+		}
+		redraw = redraw || r
+	}
+	return
+}
+
+// scriptModule returns the bed module of the script. The offsets are the
+// offsets in the file, not including the base address.
+func (e *Editor) scriptModule() *starlarkstruct.Module {
+	return &starlarkstruct.Module{
+		Name: "bed",
+		Members: starlark.StringDict{
+			"command":  starlark.NewBuiltin("command", e.scriptCommand),
+			"map":      starlark.NewBuiltin("map", e.scriptMap),
+			"hook":     starlark.NewBuiltin("hook", e.scriptHook),
+			"execute":  starlark.NewBuiltin("execute", e.scriptExecute),
+			"read":     starlark.NewBuiltin("read", e.scriptRead),
+			"write":    starlark.NewBuiltin("write", e.scriptWrite),
+			"goto":     starlark.NewBuiltin("goto", e.scriptGoto),
+			"cursor":   starlark.NewBuiltin("cursor", e.scriptCursor),
+			"length":   starlark.NewBuiltin("length", e.scriptLength),
+			"filename": starlark.NewBuiltin("filename", e.scriptFilename),
+		},
+	}
+}
+
+// scriptCommand defines the command calling the function with the arguments.
+func (e *Editor) scriptCommand(thread *starlark.Thread, b *starlark.Builtin,
+	args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var name string
+	var fn starlark.Callable
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs, "name", &name, "fn", &fn); err != nil {
+		return nil, err
+	}
+	if err := e.cmdline.DefineProgram(name, thread.Name); err != nil {
+		return nil, err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.scripts == nil {
+		e.scripts = make(map[string]starlark.Callable)
+	}
+	e.scripts[name] = fn
+	delete(e.programs, name)
+	return starlark.None, nil
+}
+
+// scriptMap maps the keys to the action or the command lines in the mode.
+func (e *Editor) scriptMap(_ *starlark.Thread, b *starlark.Builtin,
+	args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var keys, action string
+	m := "normal"
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs,
+		"keys", &keys, "action", &action, "mode?", &m); err != nil {
+		return nil, err
+	}
+	var cmdName string
+	switch m {
+	case "normal":
+		cmdName = "nm[ap]"
+	case "visual":
+		cmdName = "vm[ap]"
+	case "insert":
+		cmdName = "im[ap]"
+	default:
+		return nil, fmt.Errorf("%s: unknown mode: %s", b.Name(), m)
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return starlark.None, e.mapKeys(event.Event{CmdName: cmdName, Arg: keys + " " + action})
+}
+
+// scriptHook registers the function called on the event.
+func (e *Editor) scriptHook(_ *starlark.Thread, b *starlark.Builtin,
+	args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var name string
+	var fn starlark.Callable
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs, "name", &name, "fn", &fn); err != nil {
+		return nil, err
+	}
+	for _, hook := range scriptHooks {
+		if hook == name {
+			e.mu.Lock()
+			defer e.mu.Unlock()
+			if e.hooks == nil {
+				e.hooks = make(map[string][]starlark.Callable)
+			}
+			e.hooks[name] = append(e.hooks[name], fn)
+			return starlark.None, nil
+		}
+	}
+	return nil, fmt.Errorf("%s: unknown hook: %s", b.Name(), name)
+}
+
+// scriptExecute executes the command line. On loading the script, only the
+// commands of the configuration file are allowed.
+func (e *Editor) scriptExecute(thread *starlark.Thread, b *starlark.Builtin,
+	args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var line string
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs, "line", &line); err != nil {
+		return nil, err
+	}
+	call, _ := thread.Local("call").(*scriptCall)
+	if call == nil {
+		return starlark.None, e.executeConfig(line)
+	}
+	ev, err := e.cmdline.Parse(line)
+	if err != nil {
+		return nil, err
+	}
+	return starlark.None, e.emitScript(thread, ev)
+}
+
+// emitScript emits the event of the script. The thread is cancelled when the
+// event quits the editor.
+func (e *Editor) emitScript(thread *starlark.Thread, ev event.Event) error {
+	call, _ := thread.Local("call").(*scriptCall)
+	if call == nil {
+		return errors.New("cannot edit on loading the script")
+	}
+	r, f := e.emit(ev)
+	if call.redraw = call.redraw || r; f {
+		call.finish = true
+		thread.Cancel("quit")
+	}
+	return nil
+}
+
+// scriptRead returns the bytes at the offset.
+func (e *Editor) scriptRead(_ *starlark.Thread, b *starlark.Builtin,
+	args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var offset int64
+	var length int
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs, "offset", &offset, "length", &length); err != nil {
+		return nil, err
+	}
+	if length < 0 || length > scriptReadLength {
+		return nil, fmt.Errorf("%s: invalid length: %d", b.Name(), length)
+	}
+	bs, err := e.wm.ReadBytes(offset, length)
+	if err != nil {
+		return nil, err
+	}
+	return starlark.Bytes(bs), nil
+}
+
+// scriptWrite overwrites the bytes at the offset, which is undone at once.
+func (e *Editor) scriptWrite(thread *starlark.Thread, b *starlark.Builtin,
+	args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var offset int64
+	var data starlark.Value
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs, "offset", &offset, "data", &data); err != nil {
+		return nil, err
+	}
+	var bs string
+	switch data := data.(type) {
+	case starlark.Bytes:
+		bs = string(data)
+	case starlark.String:
+		bs = string(data)
+	default:
+		return nil, fmt.Errorf("%s: got %s, want bytes or string", b.Name(), data.Type())
+	}
+	_, cursor, length, _, err := e.wm.ReadCursor(0)
+	if err != nil {
+		return nil, err
+	}
+	if offset < 0 || offset+int64(len(bs)) > length {
+		return nil, fmt.Errorf("%s: out of range: %d", b.Name(), offset)
+	}
+	if len(bs) == 0 {
+		return starlark.None, nil
+	}
+	return starlark.None, e.emitScript(thread, event.Event{
+		Type: event.Fill, CmdName: "fill", Arg: "0x" + hex.EncodeToString([]byte(bs)),
+		Range: &event.Range{
+			From: event.Relative{Offset: offset - cursor},
+			To:   event.Relative{Offset: offset + int64(len(bs)) - 1 - cursor},
+		},
+	})
+}
+
+// scriptGoto moves the cursor to the offset.
+func (e *Editor) scriptGoto(thread *starlark.Thread, b *starlark.Builtin,
+	args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var offset int64
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs, "offset", &offset); err != nil {
+		return nil, err
+	}
+	_, cursor, _, _, err := e.wm.ReadCursor(0)
+	if err != nil {
+		return nil, err
+	}
+	return starlark.None, e.emitScript(thread, event.Event{
+		Type: event.CursorGoto, CmdName: "goto",
+		Range: &event.Range{From: event.Relative{Offset: offset - cursor}},
+	})
+}
+
+func (e *Editor) scriptCursor(_ *starlark.Thread, b *starlark.Builtin,
+	args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs); err != nil {
+		return nil, err
+	}
+	_, cursor, _, _, err := e.wm.ReadCursor(0)
+	if err != nil {
+		return nil, err
+	}
+	return starlark.MakeInt64(cursor), nil
+}
+
+func (e *Editor) scriptLength(_ *starlark.Thread, b *starlark.Builtin,
+	args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs); err != nil {
+		return nil, err
+	}
+	_, _, length, _, err := e.wm.ReadCursor(0)
+	if err != nil {
+		return nil, err
+	}
+	return starlark.MakeInt64(length), nil
+}
+
+func (e *Editor) scriptFilename(_ *starlark.Thread, b *starlark.Builtin,
+	args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs); err != nil {
+		return nil, err
+	}
+	filename, _, _, _, err := e.wm.ReadCursor(0)
+	if err != nil {
+		return nil, err
+	}
+	return starlark.String(filename), nil
+}
@@ -18,6 +18,6 @@ func suspend(e *Editor) error {
 	if err := e.redraw(); err != nil {
 		return err
 	}
-	go e.ui.Run(defaultKeyManagers())
+	go e.ui.Run(e.kms)
 	return nil
 }
@@ -7,10 +7,16 @@ import (
 	"github.com/itchyny/bed/state"
 )
 
-// UI defines the required user interface for the editor.
-type UI interface {
+// EventSource defines the source of the events for the editor, which is the
+// terminal or the script.
+type EventSource interface {
 	Init(chan<- event.Event) error
 	Run(map[mode.Mode]*key.Manager)
+}
+
+// UI defines the required user interface for the editor.
+type UI interface {
+	EventSource
 	Size() (int, int)
 	Redraw(state.State) error
 	Close() error
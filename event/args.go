@@ -0,0 +1,125 @@
+package event
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"unicode"
+	"unicode/utf8"
+)
+
+// SplitArgs splits the arguments of the command by the spaces. The spaces in
+// the quoted strings are not separators, and the quotes are kept in the
+// arguments so that the commands can tell the quoted strings from the numbers.
+func SplitArgs(s string) ([]string, error) {
+	var args []string
+	var quote rune
+	start := -1
+	for i, r := range s {
+		switch {
+		case quote != 0:
+			if r == quote && !escaped(s, i, quote) {
+				quote = 0
+			}
+			continue
+		case unicode.IsSpace(r):
+			if start >= 0 {
+				args, start = append(args, s[start:i]), -1
+			}
+			continue
+		case (r == '"' || r == '\'') && !escaped(s, i, '"'):
+			quote = r
+		}
+		if start < 0 {
+			start = i
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated quoted string: %s", s[start:])
+	}
+	if start >= 0 {
+		args = append(args, s[start:])
+	}
+	return args, nil
+}
+
+// escaped reports whether the character at the index is escaped by the odd
+// number of the preceding backslashes. The backslashes are not escapes in the
+// single quoted strings.
+func escaped(s string, i int, quote rune) bool {
+	if quote == '\'' {
+		return false
+	}
+	var n int
+	for ; i > 0 && s[i-1] == '\\'; i-- {
+		n++
+	}
+	return n%2 == 1
+}
+
+// UnquoteArg returns the bytes of the argument. The escape sequences are
+// interpreted in the double quoted strings and the unquoted arguments, and the
+// single quoted strings are taken literally.
+func UnquoteArg(s string) ([]byte, error) {
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		if s[0] == '\'' {
+			return []byte(s[1 : len(s)-1]), nil
+		}
+		return Unescape(s[1 : len(s)-1])
+	}
+	return Unescape(s)
+}
+
+// Unescape interprets the escape sequences; \xNN for the byte, \u{N..} for
+// the Unicode character, \n, \r, \t, \0, and the escaped backslash and
+// quotes.
+func Unescape(s string) ([]byte, error) {
+	bs := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			bs = append(bs, s[i])
+			continue
+		}
+		if i++; i == len(s) {
+			return nil, errors.New("incomplete escape sequence: \\")
+		}
+		switch s[i] {
+		case '\\', '"', '\'':
+			bs = append(bs, s[i])
+		case 'n':
+			bs = append(bs, '\n')
+		case 'r':
+			bs = append(bs, '\r')
+		case 't':
+			bs = append(bs, '\t')
+		case '0':
+			bs = append(bs, 0)
+		case 'x':
+			if i+3 > len(s) {
+				return nil, fmt.Errorf("invalid escape sequence: %s", s[i-1:])
+			}
+			b, err := strconv.ParseUint(s[i+1:i+3], 16, 8)
+			if err != nil {
+				return nil, fmt.Errorf("invalid escape sequence: %s", s[i-1:i+3])
+			}
+			bs, i = append(bs, byte(b)), i+2
+		case 'u':
+			j := i + 1
+			for j < len(s) && s[j] != '}' {
+				j++
+			}
+			if i+1 >= len(s) || s[i+1] != '{' || j == len(s) {
+				return nil, fmt.Errorf("invalid escape sequence: %s", s[i-1:j])
+			}
+			r, err := strconv.ParseUint(s[i+2:j], 16, 32)
+			if err != nil || !utf8.ValidRune(rune(r)) {
+				return nil, fmt.Errorf("invalid escape sequence: %s", s[i-1:j+1])
+			}
+			var buf [utf8.UTFMax]byte
+			bs, i = append(bs, buf[:utf8.EncodeRune(buf[:], rune(r))]...), j
+		default:
+			return nil, fmt.Errorf("invalid escape sequence: %s", s[i-1:i+1])
+		}
+	}
+	return bs, nil
+}
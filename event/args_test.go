@@ -0,0 +1,72 @@
+package event
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitArgs(t *testing.T) {
+	testCases := []struct {
+		target   string
+		expected []string
+		err      string
+	}{
+		{"", nil, ""},
+		{" 10  0xff ", []string{"10", "0xff"}, ""},
+		{`3 "a b\" c" 'd e\'`, []string{"3", `"a b\" c"`, `'d e\'`}, ""},
+		{`\"a b`, []string{`\"a`, "b"}, ""},
+		{`4 "a b`, nil, `unterminated quoted string: "a b`},
+	}
+	for _, testCase := range testCases {
+		got, err := SplitArgs(testCase.target)
+		if testCase.err != "" {
+			if err == nil || err.Error() != testCase.err {
+				t.Errorf("SplitArgs(%q) should return error %q but got: %v", testCase.target, testCase.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("SplitArgs(%q) should not return an error but got: %v", testCase.target, err)
+		}
+		if !reflect.DeepEqual(got, testCase.expected) {
+			t.Errorf("SplitArgs(%q) should return %q but got %q", testCase.target, testCase.expected, got)
+		}
+	}
+}
+
+func TestUnquoteArg(t *testing.T) {
+	testCases := []struct {
+		target   string
+		expected string
+		err      string
+	}{
+		{"abc", "abc", ""},
+		{`\x00\xFF\x7f`, "\x00\xff\x7f", ""},
+		{`\u{3042}\u{1F600}`, "あ\U0001f600", ""},
+		{`\n\r\t\0\\\"\'`, "\n\r\t\x00\\\"'", ""},
+		{`"a b\x20c"`, "a b c", ""},
+		{`'a\x20b'`, `a\x20b`, ""},
+		{`"`, `"`, ""},
+		{`\`, "", `incomplete escape sequence: \`},
+		{`\x4`, "", `invalid escape sequence: \x4`},
+		{`\xzz`, "", `invalid escape sequence: \xzz`},
+		{`\u3042`, "", `invalid escape sequence: \u3042`},
+		{`\u{110000}`, "", `invalid escape sequence: \u{110000}`},
+		{`\q`, "", `invalid escape sequence: \q`},
+	}
+	for _, testCase := range testCases {
+		got, err := UnquoteArg(testCase.target)
+		if testCase.err != "" {
+			if err == nil || err.Error() != testCase.err {
+				t.Errorf("UnquoteArg(%q) should return error %q but got: %v", testCase.target, testCase.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("UnquoteArg(%q) should not return an error but got: %v", testCase.target, err)
+		}
+		if string(got) != testCase.expected {
+			t.Errorf("UnquoteArg(%q) should return %q but got %q", testCase.target, testCase.expected, got)
+		}
+	}
+}
@@ -9,7 +9,9 @@ type Event struct {
 	Count   int64
 	Rune    rune
 	CmdName string
+	Bang    bool
 	Arg     string
+	Bytes   []byte
 	Error   error
 	Mode    mode.Mode
 }
@@ -33,19 +35,52 @@ const (
 	CursorGoto
 	ScrollUp
 	ScrollDown
+	ScrollCursorCenter
+	ScrollCursorTop
+	ScrollCursorBottom
 	PageUp
 	PageDown
 	PageUpHalf
 	PageDownHalf
 	PageTop
 	PageEnd
+	GotoPercent
 	JumpTo
+	JumpToPointer
+	JumpForward
+	GotoMark
+	GotoMarkLine
+	NextChange
+	PreviousChange
+	NextString
+	PreviousString
+	NextData
+	PreviousData
+	NextPacket
+	PreviousPacket
 	JumpBack
+	SetMark
 
 	DeleteByte
 	DeletePrevByte
 	Increment
 	Decrement
+	InsertBytes
+	InsertExpression
+	PasteText
+	SelectRegister
+	Yank
+	Paste
+	PasteBefore
+	Copy
+	Disassemble
+	Plot
+	Preview
+	FixChecksum
+	Fill
+	Compress
+	Substitute
+	ConfirmSubstitute
 	SwitchFocus
 
 	StartInsert
@@ -59,17 +94,23 @@ const (
 	Backspace
 	Delete
 	Rune
+	StartLiteral
 
 	Undo
 	Redo
 
 	StartVisual
+	StartVisualBlock
 	SwitchVisualEnd
+	SwitchVisualBlock
 	ExitVisual
+	ReplaceVisual
 
 	StartCmdlineCommand
 	StartCmdlineSearchForward
 	StartCmdlineSearchBackward
+	StartCmdlineExpression
+	StartCmdlinePassphrase
 	BackspaceCmdline
 	DeleteCmdline
 	DeleteWordCmdline
@@ -78,6 +119,8 @@ const (
 	ExitCmdline
 	CompleteForwardCmdline
 	CompleteBackCmdline
+	PrevHistoryCmdline
+	NextHistoryCmdline
 	ExecuteCmdline
 	ExecuteSearch
 	NextSearch
@@ -86,6 +129,8 @@ const (
 	Edit
 	New
 	Vnew
+	Split
+	Vsplit
 	Wincmd
 	FocusWindowUp
 	FocusWindowDown
@@ -98,11 +143,71 @@ const (
 	MoveWindowBottom
 	MoveWindowLeft
 	MoveWindowRight
+	IncreaseWindowHeight
+	DecreaseWindowHeight
+	IncreaseWindowWidth
+	DecreaseWindowWidth
+	EqualizeWindows
 	Suspend
+	Resume
+	Terminate
+	Crash
 	Quit
 	QuitAll
 	Write
 	WriteQuit
+	WriteQuitAll
+	Recover
+	Encrypt
+	SetPassphrase
+	Bookmark
+	Bookmarks
+	GotoBookmark
+	DeleteBookmark
+	Annotate
+	Annotations
+	DeleteAnnotation
+	Highlight
+	NoHighlight
+	Changes
+	UndoList
+	UndoPartial
+	SearchAll
+	Strings
+	Sections
+	GotoSection
+	Packets
+	Identify
+	Scan
+	Carve
+	XorScan
+	CompareStat
+	Overlay
+	Overlays
+	ToggleOverlay
+	CommitOverlay
+	DiscardOverlay
+	QuickfixList
+	QuickfixGoto
+	QuickfixNext
+	QuickfixPrevious
+	QuickfixFirst
+	QuickfixLast
+	Set
+	ColorScheme
+	Map
+	Echo
+	DefineCommand
+	DeleteCommand
+	UserCommand
+	PluginCommand
+	ProgramOutput
+	Plugins
+	Debug
+	StartConfirm
+	ExitConfirm
+	CancelTask
+	FinishTask
 	Info
 	Error
 )
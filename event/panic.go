@@ -0,0 +1,30 @@
+package event
+
+import (
+	"fmt"
+	"runtime/debug"
+)
+
+// PanicError is the panic recovered in the goroutine, which is sent to the
+// editor with the Crash event.
+type PanicError struct {
+	Value interface{}
+	Stack []byte
+}
+
+func (err *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", err.Value)
+}
+
+// RecoverPanic sends the panic of the goroutine to the channel with the Crash
+// event, which is given up when the done channel is closed. This should be
+// deferred directly to recover the panic.
+func RecoverPanic(eventCh chan<- Event, doneCh <-chan struct{}) {
+	if r := recover(); r != nil {
+		e := Event{Type: Crash, Error: &PanicError{Value: r, Stack: debug.Stack()}}
+		select {
+		case eventCh <- e:
+		case <-doneCh:
+		}
+	}
+}
@@ -1,9 +1,20 @@
 package event
 
-import "unicode"
+import (
+	"unicode"
+
+	"github.com/itchyny/bed/expr"
+)
 
 // ParseRange parses a Range.
 func ParseRange(xs []rune, i int) (*Range, int) {
+	j := i
+	for j < len(xs) && unicode.IsSpace(xs[j]) {
+		j++
+	}
+	if j < len(xs) && xs[j] == '%' {
+		return &Range{From: Absolute{0}, To: End{}}, j + 1
+	}
 	from, i := ParsePos(xs, i)
 	if from == nil {
 		return nil, i
@@ -31,10 +42,11 @@ var states = map[int]map[rune]struct {
 }
 
 // ParsePos parses a Position.
-//    +---- num.. ----+
-//    +-- [-+]num.. --+   +---------------+
+//    +---- expr.. ---+
+//    +--- expr.. % --+
+//    +-- [-+]term.. -+   +---------------+
 //    +------ $ ------+   |               |
-// ---+------ . ------+---+-- [-+]num.. --+---
+// ---+------ . ------+---+-- [-+]term.. -+---
 //    +-- ' -+- < -+--+
 //           +- > -+
 func ParsePos(xs []rune, i int) (Position, int) {
@@ -44,23 +56,31 @@ func ParsePos(xs []rune, i int) (Position, int) {
 		if state <= 1 && unicode.IsSpace(xs[i]) {
 			continue
 		}
-		if state == 0 && '0' <= xs[i] && xs[i] <= '9' {
-			var offset int64
-			offset, i = parseNum(xs, i)
+		if state == 0 && ('0' <= xs[i] && xs[i] <= '9' || xs[i] == '(') {
+			offset, j, err := expr.Parse(xs, i)
+			if err != nil {
+				return position, i
+			}
 			if position == nil {
-				position = Absolute{offset}
+				if j < len(xs) && xs[j] == '%' {
+					position = Percent{Percent: offset}
+					j++
+				} else {
+					position = Absolute{offset}
+				}
 			}
-			state = 1
+			i, state = j-1, 1
 			continue
 		}
 		if state <= 1 && (xs[i] == '+' || xs[i] == '-') {
-			var offset int64
-			sign := int64(1)
+			offset, j, err := expr.ParseTerm(xs, i+1)
+			if err != nil {
+				return position, i
+			}
 			if xs[i] == '-' {
-				sign = -1
+				offset = -offset
 			}
-			offset, i = parseNum(xs, i+1)
-			offset *= sign
+			i = j - 1
 			if position == nil {
 				position = Relative{offset}
 			} else {
@@ -82,31 +102,3 @@ func ParsePos(xs []rune, i int) (Position, int) {
 	}
 	return position, i
 }
-
-func parseNum(xs []rune, i int) (int64, int) {
-	var offset int64
-	var hex int
-	for ; i < len(xs); i++ {
-		c := xs[i]
-		if hex == 0 && c == '0' {
-			hex = 1
-		} else if hex == 1 && c == 'x' {
-			hex = 2
-		} else if '0' <= c && c <= '9' || hex == 2 && 'a' <= c && c <= 'f' {
-			if hex == 2 {
-				offset *= 0x10
-			} else {
-				hex = 3
-				offset *= 10
-			}
-			if '0' <= c && c <= '9' {
-				offset += int64(c - '0')
-			} else {
-				offset += int64(c - 'a' + 0x0a)
-			}
-		} else {
-			return offset, i - 1
-		}
-	}
-	return offset, i - 1
-}
@@ -17,6 +17,10 @@ func TestParseRange(t *testing.T) {
 		{"1024,4096", &Range{Absolute{1024}, Absolute{4096}}, 9},
 		{"1+2+3+4+5+6+7+8+9,0xa+0xb+0xc+0xd+0xe+0xf", &Range{Absolute{45}, Absolute{75}}, 41},
 		{".-100,.+100", &Range{Relative{-100}, Relative{100}}, 11},
+		{"0x400+3*0x20,$-0x10*2", &Range{Absolute{0x460}, End{-0x20}}, 21},
+		{"(1+2)*3 , .+4 write", &Range{Absolute{9}, Relative{4}}, 14},
+		{"25%,75%-1", &Range{Percent{25, 0}, Percent{75, -1}}, 9},
+		{" %s/a/b/", &Range{Absolute{0}, End{}}, 2},
 		{"'<", &Range{VisualStart{}, nil}, 2},
 		{"'>", &Range{VisualEnd{}, nil}, 2},
 		{" '<  ,  '>  write", &Range{VisualStart{}, VisualEnd{}}, 12},
@@ -50,6 +54,15 @@ func TestParsePos(t *testing.T) {
 		{"+10+20+30-40", Relative{20}, 12},
 		{" . ", Relative{0}, 3},
 		{" . +0xff ", Relative{255}, 9},
+		{"0x400+3*0x20", Absolute{0x460}, 12},
+		{"$-0x10*2+1", End{-0x1f}, 10},
+		{"+2<<4", Relative{32}, 5},
+		{"50%", Percent{50, 0}, 3},
+		{" 10 % + 0x10 ", Absolute{10}, 4},
+		{"(10%3)%", Percent{1, 0}, 7},
+		{" 10% + 0x10 ", Percent{10, 0x10}, 12},
+		{"$-0x100", End{-0x100}, 7},
+		{"1/0", nil, 0},
 		{"'<", VisualStart{}, 2},
 		{"'>", VisualEnd{}, 2},
 		{" '<  ,  '> ", VisualStart{}, 5},
@@ -45,6 +45,18 @@ func (p End) addOffset(offset int64) Position {
 	return End{p.Offset + offset}
 }
 
+// Percent is the position at the percentage of the buffer.
+type Percent struct {
+	Percent int64
+	Offset  int64
+}
+
+func (p Percent) isPosition() {}
+
+func (p Percent) addOffset(offset int64) Position {
+	return Percent{p.Percent, p.Offset + offset}
+}
+
 // VisualStart is the start position of visual selection.
 type VisualStart struct {
 	Offset int64
@@ -0,0 +1,293 @@
+package expr
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/bits"
+	"strconv"
+	"unicode"
+
+	"github.com/itchyny/bed/mathutil"
+)
+
+// Eval evaluates the arithmetic expression. The expression consists of
+// integer literals (255, 0xff, 0377, 0o377, 0b11111111), parentheses,
+// unary operators (+, -, ^, ~) and binary operators with the precedence of
+// Go (*, /, %, <<, >>, &, &^ over +, -, |, ^). The arithmetic and the left
+// shift overflowing 64 bits are errors.
+func Eval(s string) (int64, error) {
+	xs := []rune(s)
+	p := &parser{xs: xs}
+	v, err := p.parseExpr()
+	if err != nil {
+		return 0, err
+	}
+	i := p.i
+	for ; i < len(xs); i++ {
+		if !unicode.IsSpace(xs[i]) {
+			return 0, fmt.Errorf("invalid expression: %s", s)
+		}
+	}
+	return v, nil
+}
+
+// Parse the expression from the index and returns the value and the index
+// after the expression. Trailing operators without the operand are not
+// consumed so that the caller can continue parsing. The modulo operator is
+// only available in parentheses not to conflict with the percentage suffix.
+func Parse(xs []rune, i int) (int64, int, error) {
+	p := &parser{xs: xs, i: i, percent: true}
+	v, err := p.parseExpr()
+	return v, p.i, err
+}
+
+// ParseTerm parses the multiplicative expression from the index.
+func ParseTerm(xs []rune, i int) (int64, int, error) {
+	p := &parser{xs: xs, i: i, percent: true}
+	v, err := p.parseTerm()
+	return v, p.i, err
+}
+
+var (
+	errDivisionByZero = errors.New("division by zero")
+	errOverflow       = errors.New("integer overflow")
+)
+
+type parser struct {
+	xs      []rune
+	i       int
+	depth   int
+	percent bool
+}
+
+func (p *parser) skipSpaces() {
+	for p.i < len(p.xs) && unicode.IsSpace(p.xs[p.i]) {
+		p.i++
+	}
+}
+
+func (p *parser) peek(op string) bool {
+	if p.i+len(op) > len(p.xs) {
+		return false
+	}
+	for j, c := range op {
+		if p.xs[p.i+j] != c {
+			return false
+		}
+	}
+	return true
+}
+
+// operator consumes one of the operators when the operand follows.
+func (p *parser) operator(ops ...string) (string, bool) {
+	i := p.i
+	p.skipSpaces()
+	for _, op := range ops {
+		if p.peek(op) {
+			p.i += len(op)
+			p.skipSpaces()
+			if p.i < len(p.xs) && isOperandStart(p.xs[p.i]) {
+				return op, true
+			}
+			break
+		}
+	}
+	p.i = i
+	return "", false
+}
+
+func isOperandStart(c rune) bool {
+	return '0' <= c && c <= '9' || c == '(' ||
+		c == '+' || c == '-' || c == '^' || c == '~'
+}
+
+func (p *parser) parseExpr() (int64, error) {
+	x, err := p.parseTerm()
+	if err != nil {
+		return 0, err
+	}
+	for {
+		op, ok := p.operator("+", "-", "|", "^")
+		if !ok {
+			return x, nil
+		}
+		y, err := p.parseTerm()
+		if err != nil {
+			return 0, err
+		}
+		switch op {
+		case "+":
+			z := x + y
+			if (z > x) != (y > 0) {
+				return 0, errOverflow
+			}
+			x = z
+		case "-":
+			z := x - y
+			if (z < x) != (y > 0) {
+				return 0, errOverflow
+			}
+			x = z
+		case "|":
+			x |= y
+		case "^":
+			x ^= y
+		}
+	}
+}
+
+func (p *parser) parseTerm() (int64, error) {
+	x, err := p.parseUnary()
+	if err != nil {
+		return 0, err
+	}
+	for {
+		ops := []string{"*", "/", "%", "<<", ">>", "&^", "&"}
+		if p.percent && p.depth == 0 {
+			ops = append(ops[:2], ops[3:]...)
+		}
+		op, ok := p.operator(ops...)
+		if !ok {
+			return x, nil
+		}
+		y, err := p.parseUnary()
+		if err != nil {
+			return 0, err
+		}
+		switch op {
+		case "*":
+			z := x * y
+			if x != 0 && (z/x != y || x == -1 && y == math.MinInt64) {
+				return 0, errOverflow
+			}
+			x = z
+		case "/", "%":
+			if y == 0 {
+				return 0, errDivisionByZero
+			}
+			if x == math.MinInt64 && y == -1 {
+				if op == "/" {
+					return 0, errOverflow
+				}
+				x = 0
+				break
+			}
+			if op == "/" {
+				x /= y
+			} else {
+				x %= y
+			}
+		case "<<", ">>":
+			if y < 0 {
+				return 0, fmt.Errorf("negative shift count: %d", y)
+			}
+			if op == "<<" {
+				if x, err = shiftLeft(x, uint64(y)); err != nil {
+					return 0, err
+				}
+			} else {
+				x >>= uint64(y)
+			}
+		case "&^":
+			x &^= y
+		case "&":
+			x &= y
+		}
+	}
+}
+
+// shiftLeft shifts the value, which overflows when the shifted out bits are
+// not the copies of the sign bit, or not zero as the unsigned value, so that
+// 1<<63 is allowed like 0x8000000000000000.
+func shiftLeft(x int64, y uint64) (int64, error) {
+	if x == 0 {
+		return 0, nil
+	}
+	if y >= 64 {
+		return 0, errOverflow
+	}
+	z := x << y
+	if z>>y != x && int64(uint64(z)>>y) != x {
+		return 0, errOverflow
+	}
+	return z, nil
+}
+
+func (p *parser) parseUnary() (int64, error) {
+	p.skipSpaces()
+	if p.i >= len(p.xs) {
+		return 0, errors.New("unexpected end of expression")
+	}
+	switch c := p.xs[p.i]; c {
+	case '+', '-', '^', '~':
+		p.i++
+		x, err := p.parseUnary()
+		if err != nil {
+			return 0, err
+		}
+		switch c {
+		case '-':
+			return -x, nil
+		case '^', '~':
+			return ^x, nil
+		}
+		return x, nil
+	case '(':
+		p.i++
+		p.depth++
+		x, err := p.parseExpr()
+		p.depth--
+		if err != nil {
+			return 0, err
+		}
+		p.skipSpaces()
+		if p.i >= len(p.xs) || p.xs[p.i] != ')' {
+			return 0, errors.New("missing closing parenthesis")
+		}
+		p.i++
+		return x, nil
+	default:
+		return p.parseNumber()
+	}
+}
+
+func (p *parser) parseNumber() (int64, error) {
+	start := p.i
+	isDigit := func(c rune) bool { return '0' <= c && c <= '9' }
+	if p.peek("0x") || p.peek("0X") {
+		p.i += 2
+		isDigit = func(c rune) bool {
+			return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+		}
+	} else if p.peek("0b") || p.peek("0B") || p.peek("0o") || p.peek("0O") {
+		p.i += 2
+	}
+	for p.i < len(p.xs) && (isDigit(p.xs[p.i]) || p.xs[p.i] == '_') {
+		p.i++
+	}
+	if start == p.i {
+		return 0, fmt.Errorf("unexpected character: %c", p.xs[p.i])
+	}
+	lit := string(p.xs[start:p.i])
+	v, err := strconv.ParseUint(lit, 0, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number: %s", lit)
+	}
+	return int64(v), nil
+}
+
+// Bytes returns the big-endian representation of the value without the
+// leading zero bytes. Negative values are represented in eight bytes.
+func Bytes(v int64) []byte {
+	n := 8
+	if v >= 0 {
+		n = mathutil.MaxInt((bits.Len64(uint64(v))+7)/8, 1)
+	}
+	bs := make([]byte, n)
+	for i := n - 1; i >= 0; i-- {
+		bs[i] = byte(v)
+		v >>= 8
+	}
+	return bs
+}
@@ -0,0 +1,126 @@
+package expr
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEval(t *testing.T) {
+	testCases := []struct {
+		src      string
+		expected int64
+	}{
+		{"0", 0},
+		{"255", 255},
+		{"0xff", 255},
+		{"0XFF", 255},
+		{"0377", 255},
+		{"0o377", 255},
+		{"0b1111_1111", 255},
+		{"0x400+3*0x20", 0x460},
+		{" 0x400 + 3 * 0x20 ", 0x460},
+		{"(0x400+3)*0x20", 0x8060},
+		{"0xdead^0xbeef", 0x6042},
+		{"10-2-3", 5},
+		{"-10/3", -3},
+		{"-10%3", -1},
+		{"1<<10|0xf", 0x40f},
+		{"0xff&^0x0f", 0xf0},
+		{"0x1234>>8&0xff", 0x12},
+		{"^0", -1},
+		{"~0xff&0xffff", 0xff00},
+		{"--1", 1},
+		{"0xffffffffffffffff", -1},
+		{"0x7ffffffffffffffe+1", 0x7fffffffffffffff},
+		{"0xffffffffffffffff+1", 0},
+		{"-0x7fffffffffffffff-1", -0x7fffffffffffffff - 1},
+		{"-0x4000000000000000*2", -0x7fffffffffffffff - 1},
+		{"1<<63", -0x7fffffffffffffff - 1},
+		{"-1<<63", -0x7fffffffffffffff - 1},
+		{"0<<100", 0},
+		{"-(1<<63)%-1", 0},
+	}
+	for _, tc := range testCases {
+		got, err := Eval(tc.src)
+		if err != nil {
+			t.Errorf("Eval(%q) should not return an error but got: %v", tc.src, err)
+		}
+		if got != tc.expected {
+			t.Errorf("Eval(%q) should return %d but got %d", tc.src, tc.expected, got)
+		}
+	}
+}
+
+func TestEvalError(t *testing.T) {
+	testCases := []struct {
+		src      string
+		expected string
+	}{
+		{"", "unexpected end of expression"},
+		{"1+", "invalid expression: 1+"},
+		{"1/0", "division by zero"},
+		{"1%(2-2)", "division by zero"},
+		{"(1+2", "missing closing parenthesis"},
+		{"1<<-1", "negative shift count: -1"},
+		{"9223372036854775807+1", "integer overflow"},
+		{"-9223372036854775807-2", "integer overflow"},
+		{"0x100000000*0x100000000", "integer overflow"},
+		{"-1*(1<<63)", "integer overflow"},
+		{"(1<<63)/-1", "integer overflow"},
+		{"1<<64", "integer overflow"},
+		{"1<<70", "integer overflow"},
+		{"3<<63", "integer overflow"},
+		{"0x", "invalid number: 0x"},
+		{"09", "invalid number: 09"},
+		{"x", "unexpected character: x"},
+		{"1 2", "invalid expression: 1 2"},
+	}
+	for _, tc := range testCases {
+		_, err := Eval(tc.src)
+		if err == nil || err.Error() != tc.expected {
+			t.Errorf("Eval(%q) should return error %q but got: %v", tc.src, tc.expected, err)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	testCases := []struct {
+		src      string
+		expected int64
+		index    int
+	}{
+		{"10,20", 10, 2},
+		{"0x10 write", 0x10, 4},
+		{"50%", 50, 2},
+		{"50%+1", 50, 2},
+		{"(50%7)", 1, 6},
+		{"1+2 -", 3, 3},
+	}
+	for _, tc := range testCases {
+		got, index, err := Parse([]rune(tc.src), 0)
+		if err != nil {
+			t.Errorf("Parse(%q) should not return an error but got: %v", tc.src, err)
+		}
+		if got != tc.expected || index != tc.index {
+			t.Errorf("Parse(%q) should return %d, %d but got %d, %d", tc.src, tc.expected, tc.index, got, index)
+		}
+	}
+}
+
+func TestBytes(t *testing.T) {
+	testCases := []struct {
+		value    int64
+		expected []byte
+	}{
+		{0, []byte{0x00}},
+		{0xff, []byte{0xff}},
+		{0x6042, []byte{0x60, 0x42}},
+		{0x10000, []byte{0x01, 0x00, 0x00}},
+		{-2, []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xfe}},
+	}
+	for _, tc := range testCases {
+		if got := Bytes(tc.value); !reflect.DeepEqual(got, tc.expected) {
+			t.Errorf("Bytes(%d) should return %v but got %v", tc.value, tc.expected, got)
+		}
+	}
+}
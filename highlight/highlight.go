@@ -0,0 +1,168 @@
+package highlight
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/itchyny/bed/state"
+)
+
+// Rule is a user-defined highlight rule.
+type Rule struct {
+	Color   string
+	Pattern string
+	matcher matcher
+}
+
+type matcher interface {
+	match([]byte) [][2]int
+}
+
+// NewRule creates a new Rule. The pattern is one of
+//   - a regular expression enclosed with slashes: /PK\x03\x04/
+//   - a byte predicate: byte==0x00, byte<0x20, byte>=0x80, byte!=0xff
+//   - a hex sequence: deadbeef, 0xdeadbeef, de ad be ef
+func NewRule(color, pattern string) (*Rule, error) {
+	if color == "" {
+		return nil, errors.New("highlight color is empty")
+	}
+	pattern = strings.TrimSpace(pattern)
+	var m matcher
+	var err error
+	switch {
+	case pattern == "":
+		return nil, errors.New("highlight pattern is empty")
+	case len(pattern) >= 2 && pattern[0] == '/' && pattern[len(pattern)-1] == '/':
+		m, err = newRegexpMatcher(pattern[1 : len(pattern)-1])
+	case strings.HasPrefix(pattern, "byte"):
+		m, err = newPredicateMatcher(strings.TrimSpace(pattern[len("byte"):]))
+	default:
+		m, err = newHexMatcher(pattern)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &Rule{Color: color, Pattern: pattern, matcher: m}, nil
+}
+
+// Match returns the highlighted ranges of the bytes at the offset.
+func Match(rules []*Rule, bs []byte, offset int64) []state.Highlight {
+	var hs []state.Highlight
+	for _, r := range rules {
+		for _, m := range r.matcher.match(bs) {
+			hs = append(hs, state.Highlight{
+				From:  offset + int64(m[0]),
+				To:    offset + int64(m[1]) - 1,
+				Color: r.Color,
+			})
+		}
+	}
+	return hs
+}
+
+type regexpMatcher struct {
+	re *regexp.Regexp
+}
+
+func newRegexpMatcher(pattern string) (matcher, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	return &regexpMatcher{re}, nil
+}
+
+func (m *regexpMatcher) match(bs []byte) [][2]int {
+	var ms [][2]int
+	for _, loc := range m.re.FindAllIndex(bs, -1) {
+		if loc[0] < loc[1] {
+			ms = append(ms, [2]int{loc[0], loc[1]})
+		}
+	}
+	return ms
+}
+
+type predicateMatcher struct {
+	op    string
+	value byte
+}
+
+func newPredicateMatcher(pattern string) (matcher, error) {
+	for _, op := range []string{"==", "!=", "<=", ">=", "<", ">"} {
+		if strings.HasPrefix(pattern, op) {
+			v, err := strconv.ParseUint(strings.TrimSpace(pattern[len(op):]), 0, 8)
+			if err != nil {
+				return nil, fmt.Errorf("invalid byte value: %s", pattern[len(op):])
+			}
+			return &predicateMatcher{op, byte(v)}, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid byte predicate: byte%s", pattern)
+}
+
+func (m *predicateMatcher) test(b byte) bool {
+	switch m.op {
+	case "==":
+		return b == m.value
+	case "!=":
+		return b != m.value
+	case "<=":
+		return b <= m.value
+	case ">=":
+		return b >= m.value
+	case "<":
+		return b < m.value
+	default:
+		return b > m.value
+	}
+}
+
+func (m *predicateMatcher) match(bs []byte) [][2]int {
+	var ms [][2]int
+	for i := 0; i < len(bs); i++ {
+		if !m.test(bs[i]) {
+			continue
+		}
+		j := i + 1
+		for j < len(bs) && m.test(bs[j]) {
+			j++
+		}
+		ms = append(ms, [2]int{i, j})
+		i = j
+	}
+	return ms
+}
+
+type hexMatcher struct {
+	target []byte
+}
+
+func newHexMatcher(pattern string) (matcher, error) {
+	pattern = strings.Join(strings.Fields(pattern), "")
+	if strings.HasPrefix(pattern, "0x") || strings.HasPrefix(pattern, "0X") {
+		pattern = pattern[2:]
+	}
+	target, err := hex.DecodeString(pattern)
+	if err != nil || len(target) == 0 {
+		return nil, fmt.Errorf("invalid hex sequence: %s", pattern)
+	}
+	return &hexMatcher{target}, nil
+}
+
+func (m *hexMatcher) match(bs []byte) [][2]int {
+	var ms [][2]int
+	for i := 0; i < len(bs); {
+		j := bytes.Index(bs[i:], m.target)
+		if j < 0 {
+			break
+		}
+		ms = append(ms, [2]int{i + j, i + j + len(m.target)})
+		i += j + len(m.target)
+	}
+	return ms
+}
@@ -0,0 +1,60 @@
+package highlight
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/itchyny/bed/state"
+)
+
+func TestMatch(t *testing.T) {
+	bs := []byte("\x00\x00PK\x03\x04abc\xde\xad\xbe\xef\x00")
+	testCases := []struct {
+		pattern  string
+		expected []state.Highlight
+	}{
+		{"deadbeef", []state.Highlight{{From: 19, To: 22, Color: "red"}}},
+		{"0xDEADBEEF", []state.Highlight{{From: 19, To: 22, Color: "red"}}},
+		{"de ad", []state.Highlight{{From: 19, To: 20, Color: "red"}}},
+		{"byte==0x00", []state.Highlight{
+			{From: 10, To: 11, Color: "red"}, {From: 23, To: 23, Color: "red"}}},
+		{"byte>=0xbe", []state.Highlight{
+			{From: 19, To: 19, Color: "red"}, {From: 21, To: 22, Color: "red"}}},
+		{"byte < 1", []state.Highlight{
+			{From: 10, To: 11, Color: "red"}, {From: 23, To: 23, Color: "red"}}},
+		{`/PK\x03\x04/`, []state.Highlight{{From: 12, To: 15, Color: "red"}}},
+		{"/[a-c]+/", []state.Highlight{{From: 16, To: 18, Color: "red"}}},
+		{"cafe", nil},
+	}
+	for _, testCase := range testCases {
+		rule, err := NewRule("red", testCase.pattern)
+		if err != nil {
+			t.Errorf("err should be nil but got: %v", err)
+			continue
+		}
+		got := Match([]*Rule{rule}, bs, 10)
+		if !reflect.DeepEqual(got, testCase.expected) {
+			t.Errorf("Match(%q) should be %+v but got %+v", testCase.pattern, testCase.expected, got)
+		}
+	}
+}
+
+func TestNewRuleError(t *testing.T) {
+	testCases := []struct {
+		color, pattern, expected string
+	}{
+		{"", "00", "highlight color is empty"},
+		{"red", "", "highlight pattern is empty"},
+		{"red", "xyz", "invalid hex sequence: xyz"},
+		{"red", "byte=0", "invalid byte predicate: byte=0"},
+		{"red", "byte==256", "invalid byte value: 256"},
+		{"red", "/[/", "error parsing regexp: missing closing ]: `[`"},
+	}
+	for _, testCase := range testCases {
+		_, err := NewRule(testCase.color, testCase.pattern)
+		if err == nil || err.Error() != testCase.expected {
+			t.Errorf("NewRule(%q, %q) should return error %q but got: %v",
+				testCase.color, testCase.pattern, testCase.expected, err)
+		}
+	}
+}
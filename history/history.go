@@ -1,17 +1,37 @@
 package history
 
-import "github.com/itchyny/bed/buffer"
+import (
+	"time"
+
+	"github.com/itchyny/bed/buffer"
+)
 
 // History manages the buffer history.
 type History struct {
-	entries []*historyEntry
-	index   int
+	entries    []*historyEntry
+	index      int
+	seq        int
+	size       int64
+	maxEntries int
+	maxSize    int64
 }
 
 type historyEntry struct {
 	buffer *buffer.Buffer
 	offset int64
 	cursor int64
+	size   int64
+	change Change
+}
+
+// Change describes the history entry; the sequence number, the time, and the
+// range of the bytes changed from the previous entry. The first entry, which
+// has the sequence number 0, is the original buffer.
+type Change struct {
+	Seq    int
+	Time   time.Time
+	Offset int64
+	Size   int64
 }
 
 // NewHistory creates a new history manager.
@@ -19,17 +39,61 @@ func NewHistory() *History {
 	return &History{index: -1}
 }
 
+// SetLimit sets the maximum number of the entries and the maximum bytes of
+// the buffers held in memory by the entries, zero for no limit. The oldest
+// entries are discarded on pushing to the history, but the current entry is
+// always kept.
+func (h *History) SetLimit(maxEntries int, maxSize int64) {
+	h.maxEntries, h.maxSize = maxEntries, maxSize
+}
+
 // Push a new buffer to the history.
-func (h *History) Push(buffer *buffer.Buffer, offset int64, cursor int64) {
-	newEntry := &historyEntry{buffer.Clone(), offset, cursor}
-	if len(h.entries)-1 > h.index {
-		h.index++
-		h.entries[h.index] = newEntry
-		h.entries = h.entries[:h.index+1]
-	} else {
-		h.entries = append(h.entries, newEntry)
-		h.index++
+func (h *History) Push(b *buffer.Buffer, offset int64, cursor int64) {
+	newEntry := &historyEntry{buffer: b.Clone(), offset: offset, cursor: cursor}
+	newEntry.size = newEntry.buffer.MemoryLen()
+	newEntry.change.Time = time.Now()
+	if h.index >= 0 {
+		h.seq++
+		newEntry.change.Seq = h.seq
+		newEntry.change.Offset, newEntry.change.Size, _ = buffer.Diff(h.entries[h.index].buffer, newEntry.buffer)
+	}
+	for _, e := range h.entries[h.index+1:] {
+		h.size -= e.size
 	}
+	h.entries = append(h.entries[:h.index+1], newEntry)
+	h.index++
+	h.size += newEntry.size
+	h.evict()
+}
+
+// Amend replaces the current entry with the buffer, to record the repeated
+// edits as one change. It pushes the buffer when the current entry is the
+// original buffer or there are the entries to redo.
+func (h *History) Amend(b *buffer.Buffer, offset int64, cursor int64) {
+	if h.index <= 0 || h.index < len(h.entries)-1 {
+		h.Push(b, offset, cursor)
+		return
+	}
+	e := h.entries[h.index]
+	h.size -= e.size
+	e.buffer, e.offset, e.cursor = b.Clone(), offset, cursor
+	e.size = e.buffer.MemoryLen()
+	e.change.Time = time.Now()
+	e.change.Offset, e.change.Size, _ = buffer.Diff(h.entries[h.index-1].buffer, e.buffer)
+	h.size += e.size
+	h.evict()
+}
+
+// evict discards the oldest entries exceeding the limits.
+func (h *History) evict() {
+	var i int
+	for i < h.index && (h.maxEntries > 0 && len(h.entries)-i > h.maxEntries ||
+		h.maxSize > 0 && h.size > h.maxSize) {
+		h.size -= h.entries[i].size
+		h.entries[i] = nil
+		i++
+	}
+	h.entries, h.index = h.entries[i:], h.index-i
 }
 
 // Undo the history.
@@ -53,3 +117,30 @@ func (h *History) Redo() (*buffer.Buffer, int64, int64) {
 	e := h.entries[h.index]
 	return e.buffer.Clone(), e.offset, e.cursor
 }
+
+// Current returns the change of the current entry.
+func (h *History) Current() Change {
+	if h.index < 0 {
+		return Change{}
+	}
+	return h.entries[h.index].change
+}
+
+// Len returns the number of the entries.
+func (h *History) Len() int {
+	return len(h.entries)
+}
+
+// MemoryLen returns the number of the bytes held in memory by the entries.
+func (h *History) MemoryLen() int64 {
+	return h.size
+}
+
+// Changes returns the changes of the entries and the index of the current one.
+func (h *History) Changes() ([]Change, int) {
+	changes := make([]Change, len(h.entries))
+	for i, e := range h.entries {
+		changes[i] = e.change
+	}
+	return changes, h.index
+}
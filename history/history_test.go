@@ -73,3 +73,109 @@ func TestHistoryUndo(t *testing.T) {
 		t.Errorf("history.Redo should return cursor 0 but got %d", cursor)
 	}
 }
+
+func TestHistoryChanges(t *testing.T) {
+	history := NewHistory()
+	b := buffer.NewBuffer(strings.NewReader("Hello, world!"))
+	history.Push(b, 0, 0)
+	b.Replace(7, 'W')
+	history.Push(b, 0, 7)
+	b.InsertBytes(5, []byte("!!!"))
+	history.Push(b, 0, 5)
+
+	if c := history.Current(); c.Seq != 2 || c.Offset != 5 || c.Size != 3 {
+		t.Errorf("history.Current should return #2 at 5 of 3 bytes but got %+v", c)
+	}
+	history.Undo()
+	if c := history.Current(); c.Seq != 1 || c.Offset != 7 || c.Size != 1 {
+		t.Errorf("history.Current should return #1 at 7 of 1 byte but got %+v", c)
+	}
+	changes, index := history.Changes()
+	if len(changes) != 3 || index != 1 {
+		t.Fatalf("history.Changes should return 3 changes and index 1 but got %d and %d", len(changes), index)
+	}
+	for i, c := range changes {
+		if c.Seq != i || c.Time.IsZero() {
+			t.Errorf("changes[%d] should have sequence number %d and time but got %+v", i, i, c)
+		}
+	}
+
+	b.Delete(0)
+	history.Push(b, 0, 0)
+	if c := history.Current(); c.Seq != 3 {
+		t.Errorf("history.Current should return #3 but got %+v", c)
+	}
+	if changes, index = history.Changes(); len(changes) != 3 || index != 2 {
+		t.Errorf("history.Changes should return 3 changes and index 2 but got %d and %d", len(changes), index)
+	}
+}
+
+func TestHistoryAmend(t *testing.T) {
+	history := NewHistory()
+	b := buffer.NewBuffer(strings.NewReader("Hello, world!"))
+	history.Amend(b, 0, 0)
+	b.Delete(0)
+	history.Amend(b, 0, 0)
+	b.Delete(0)
+	history.Amend(b, 0, 0)
+	if changes, index := history.Changes(); len(changes) != 2 || index != 1 {
+		t.Fatalf("history.Changes should return 2 changes and index 1 but got %d and %d", len(changes), index)
+	}
+	if c := history.Current(); c.Seq != 1 || c.Offset != 0 || c.Size != 2 {
+		t.Errorf("history.Current should return #1 at 0 of 2 bytes but got %+v", c)
+	}
+	buf := make([]byte, 13)
+	u, _, _, _ := history.Undo()
+	u.Read(buf)
+	if string(buf) != "Hello, world!" {
+		t.Errorf("buf should be %q but got %q", "Hello, world!", string(buf))
+	}
+
+	b.Delete(0)
+	history.Amend(b, 0, 0)
+	if changes, index := history.Changes(); len(changes) != 2 || index != 1 {
+		t.Fatalf("history.Changes should return 2 changes and index 1 but got %d and %d", len(changes), index)
+	}
+	if c := history.Current(); c.Seq != 2 || c.Size != 3 {
+		t.Errorf("history.Current should return #2 of 3 bytes but got %+v", c)
+	}
+}
+
+func TestHistoryLimit(t *testing.T) {
+	history := NewHistory()
+	history.SetLimit(3, 0)
+	b := buffer.NewBuffer(strings.NewReader("Hello, world!"))
+	history.Push(b, 0, 0)
+	for i := 0; i < 5; i++ {
+		b.Insert(0, '!')
+		history.Push(b, 0, 0)
+	}
+	if changes, index := history.Changes(); len(changes) != 3 || index != 2 || changes[0].Seq != 3 {
+		t.Errorf("history.Changes should return 3 changes from #3 but got %+v and %d", changes, index)
+	}
+	history.Undo()
+	history.Undo()
+	history.Undo()
+	if c := history.Current(); c.Seq != 3 {
+		t.Errorf("history.Current should return #3 but got %+v", c)
+	}
+
+	history = NewHistory()
+	history.SetLimit(0, 8)
+	b = buffer.NewBuffer(strings.NewReader("Hello, world!"))
+	history.Push(b, 0, 0)
+	for i := 0; i < 4; i++ {
+		b.Insert(0, '!')
+		history.Push(b, 0, 0)
+	}
+	changes, index := history.Changes()
+	if len(changes) != 2 || index != 1 || changes[0].Seq != 3 {
+		t.Errorf("history.Changes should return 2 changes from #3 but got %+v and %d", changes, index)
+	}
+	history.SetLimit(0, 1)
+	b.Delete(0)
+	history.Push(b, 0, 0)
+	if changes, index = history.Changes(); len(changes) != 1 || index != 0 {
+		t.Errorf("history.Changes should keep the current entry but got %+v and %d", changes, index)
+	}
+}
@@ -2,6 +2,8 @@ package key
 
 import (
 	"strconv"
+	"strings"
+	"sync"
 
 	"github.com/itchyny/bed/event"
 )
@@ -9,9 +11,17 @@ import (
 // Key represents one keyboard stroke.
 type Key string
 
+func (k Key) rune() rune {
+	if rs := []rune(string(k)); len(rs) == 1 {
+		return rs[0]
+	}
+	return 0
+}
+
 type keyEvent struct {
 	keys  []Key
 	event event.Type
+	line  string
 }
 
 const (
@@ -39,36 +49,103 @@ func (ke keyEvent) cmp(ks []Key) int {
 type Manager struct {
 	keys   []Key
 	events []keyEvent
+	queue  []event.Event
 	count  bool
+	mu     *sync.Mutex
 }
 
 // NewManager creates a new Manager.
 func NewManager(count bool) *Manager {
-	return &Manager{count: count}
+	return &Manager{count: count, mu: new(sync.Mutex)}
 }
 
-// Register adds a new key mapping.
+// Register adds a new key mapping. The mapping of the same keys is replaced.
 func (km *Manager) Register(eventType event.Type, keys ...Key) {
-	km.events = append(km.events, keyEvent{keys, eventType})
+	km.register(keyEvent{keys, eventType, ""})
+}
+
+// RegisterCommand adds a new key mapping to the command lines separated by |,
+// which are executed like the body of the user command.
+func (km *Manager) RegisterCommand(line string, keys ...Key) {
+	km.register(keyEvent{keys, event.UserCommand, line})
+}
+
+func (km *Manager) register(ke keyEvent) {
+	km.mu.Lock()
+	defer km.mu.Unlock()
+	for i, e := range km.events {
+		if e.cmp(ke.keys) == keysEq && len(e.keys) == len(ke.keys) {
+			km.events[i] = ke
+			return
+		}
+	}
+	km.events = append(km.events, ke)
 }
 
-// Press checks the new key down event.
+// Press checks the new key down event. The count prefix is a decimal number
+// or a hexadecimal number with 0x prefix. Since 0 can start the hexadecimal
+// count, the key is pending until the next key is pressed, and then the
+// events of the keys are returned in order by Press and Next.
 func (km *Manager) Press(k Key) event.Event {
+	km.mu.Lock()
+	defer km.mu.Unlock()
+	if km.count && isHexPrefix(km.keys) && !isHexPrefix(append(km.keys[:len(km.keys):len(km.keys)], k)) &&
+		(len(km.keys) == 1 || !isHexDigit(k)) {
+		keys := append(km.keys, k)
+		km.keys = nil
+		for i, k := range keys {
+			if e := km.press(k, i > 0); e.Type != event.Nop {
+				km.queue = append(km.queue, e)
+			}
+		}
+		if len(km.queue) == 0 {
+			return event.Event{Type: event.Nop}
+		}
+		e := km.queue[0]
+		km.queue = km.queue[1:]
+		return e
+	}
+	return km.press(k, true)
+}
+
+// Next returns the event queued by Press.
+func (km *Manager) Next() (event.Event, bool) {
+	km.mu.Lock()
+	defer km.mu.Unlock()
+	if len(km.queue) == 0 {
+		return event.Event{}, false
+	}
+	e := km.queue[0]
+	km.queue = km.queue[1:]
+	return e, true
+}
+
+// Pending returns the pending key sequence including the count.
+func (km *Manager) Pending() string {
+	km.mu.Lock()
+	defer km.mu.Unlock()
+	var sb strings.Builder
+	for _, k := range km.keys {
+		sb.WriteString(string(k))
+	}
+	return sb.String()
+}
+
+func (km *Manager) press(k Key, hex bool) event.Event {
 	km.keys = append(km.keys, k)
+	if km.count && hex && isHexPrefix(km.keys) {
+		return event.Event{Type: event.Nop}
+	}
 	for i := 0; i < len(km.keys); i++ {
 		keys := km.keys[i:]
 		var count int64
 		if km.count {
-			numStr := ""
-			for j, k := range keys {
-				if len(k) == 1 && ('1' <= k[0] && k[0] <= '9' || k[0] == '0' && j > 0) {
-					numStr += string(k)
-				} else {
-					break
-				}
-			}
-			keys = keys[len(numStr):]
-			count, _ = strconv.ParseInt(numStr, 10, 64)
+			var n int
+			count, n = parseCount(keys)
+			keys = keys[n:]
+		}
+		if len(keys) == 0 {
+			return event.Event{Type: event.Nop}
 		}
 		for _, ke := range km.events {
 			switch ke.cmp(keys) {
@@ -76,10 +153,48 @@ func (km *Manager) Press(k Key) event.Event {
 				return event.Event{Type: event.Nop}
 			case keysEq:
 				km.keys = nil
-				return event.Event{Type: ke.event, Count: count}
+				if ke.line != "" {
+					return event.Event{Type: ke.event, CmdName: "map", Arg: ke.line}
+				}
+				return event.Event{Type: ke.event, Count: count, Rune: keys[len(keys)-1].rune()}
 			}
 		}
 	}
 	km.keys = nil
 	return event.Event{Type: event.Nop}
 }
+
+// isHexPrefix reports whether the keys can be followed by hexadecimal digits
+// of the count.
+func isHexPrefix(keys []Key) bool {
+	return len(keys) == 1 && keys[0] == "0" ||
+		len(keys) == 2 && keys[0] == "0" && keys[1] == "x"
+}
+
+// parseCount parses the count prefix and returns the count and the number of
+// the keys of the count.
+func parseCount(keys []Key) (int64, int) {
+	if len(keys) > 2 && keys[0] == "0" && keys[1] == "x" && isHexDigit(keys[2]) {
+		n := 2
+		var sb strings.Builder
+		for ; n < len(keys) && isHexDigit(keys[n]); n++ {
+			sb.WriteString(string(keys[n]))
+		}
+		count, _ := strconv.ParseInt(sb.String(), 16, 64)
+		return count, n
+	}
+	var sb strings.Builder
+	for j, k := range keys {
+		if len(k) == 1 && ('1' <= k[0] && k[0] <= '9' || k[0] == '0' && j > 0) {
+			sb.WriteString(string(k))
+		} else {
+			break
+		}
+	}
+	count, _ := strconv.ParseInt(sb.String(), 10, 64)
+	return count, sb.Len()
+}
+
+func isHexDigit(k Key) bool {
+	return len(k) == 1 && ('0' <= k[0] && k[0] <= '9' || 'a' <= k[0] && k[0] <= 'f')
+}
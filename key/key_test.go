@@ -1,6 +1,7 @@
 package key
 
 import (
+	"reflect"
 	"testing"
 
 	"github.com/itchyny/bed/event"
@@ -69,3 +70,90 @@ func TestKeyManagerPressCount(t *testing.T) {
 		t.Errorf("pressing 37kj should emit event.CursorUp with count 37 but got: %d", e.Count)
 	}
 }
+
+func TestKeyManagerPressRune(t *testing.T) {
+	km := NewManager(true)
+	km.Register(event.SetMark, "m", "a")
+	km.Register(event.SetMark, "m", "b")
+	e := km.Press("m")
+	if e.Type != event.Nop {
+		t.Errorf("pressing m should be nop but got: %d", e.Type)
+	}
+	e = km.Press("b")
+	if e.Type != event.SetMark {
+		t.Errorf("pressing mb should emit event.SetMark but got: %d", e.Type)
+	}
+	if e.Rune != 'b' {
+		t.Errorf("pressing mb should emit event.SetMark with rune %q but got: %q", 'b', e.Rune)
+	}
+}
+
+func TestKeyManagerRegisterCommand(t *testing.T) {
+	km := NewManager(true)
+	km.Register(event.CursorUp, "g", "p")
+	km.RegisterCommand("Patch 10 | write", "g", "p")
+	km.Press("g")
+	e := km.Press("p")
+	if e.Type != event.UserCommand || e.CmdName != "map" || e.Arg != "Patch 10 | write" {
+		t.Errorf("pressing gp should emit event.UserCommand but got: %+v", e)
+	}
+	km.Register(event.CursorUp, "g", "p")
+	km.Press("g")
+	if e := km.Press("p"); e.Type != event.CursorUp || e.Arg != "" {
+		t.Errorf("pressing gp should emit event.CursorUp but got: %+v", e)
+	}
+}
+
+func TestKeyManagerPressHexCount(t *testing.T) {
+	km := NewManager(true)
+	km.Register(event.CursorHead, "0")
+	km.Register(event.CursorDown, "j")
+	km.Register(event.DeleteByte, "x")
+	for _, k := range []Key{"0", "x", "1", "f"} {
+		if e := km.Press(k); e.Type != event.Nop {
+			t.Errorf("pressing %s should be nop but got: %d", k, e.Type)
+		}
+	}
+	if pending := km.Pending(); pending != "0x1f" {
+		t.Errorf("pending keys should be %q but got %q", "0x1f", pending)
+	}
+	e := km.Press("j")
+	if e.Type != event.CursorDown || e.Count != 0x1f {
+		t.Errorf("pressing 0x1fj should emit event.CursorDown with count 31 but got: %+v", e)
+	}
+	if _, ok := km.Next(); ok {
+		t.Errorf("no event should be queued")
+	}
+	if pending := km.Pending(); pending != "" {
+		t.Errorf("pending keys should be empty but got %q", pending)
+	}
+}
+
+func TestKeyManagerPressZero(t *testing.T) {
+	km := NewManager(true)
+	km.Register(event.CursorHead, "0")
+	km.Register(event.CursorDown, "j")
+	km.Register(event.DeleteByte, "x")
+	for _, testCase := range []struct {
+		keys     []Key
+		expected []event.Type
+	}{
+		{[]Key{"0", "j"}, []event.Type{event.CursorHead, event.CursorDown}},
+		{[]Key{"0", "x", "j"}, []event.Type{event.CursorHead, event.DeleteByte, event.CursorDown}},
+		{[]Key{"0", "0", "j"}, []event.Type{event.CursorHead, event.CursorHead, event.CursorDown}},
+		{[]Key{"1", "0", "j"}, []event.Type{event.CursorDown}},
+	} {
+		var got []event.Type
+		for _, k := range testCase.keys {
+			if e := km.Press(k); e.Type != event.Nop {
+				got = append(got, e.Type)
+			}
+			for e, ok := km.Next(); ok; e, ok = km.Next() {
+				got = append(got, e.Type)
+			}
+		}
+		if !reflect.DeepEqual(got, testCase.expected) {
+			t.Errorf("pressing %v should emit %v but got %v", testCase.keys, testCase.expected, got)
+		}
+	}
+}
@@ -22,6 +22,9 @@ type Layout interface {
 	ActiveWindow() Window
 	Lookup(func(Window) bool) Window
 	Close() Layout
+	AdjustWidth(int) Layout
+	AdjustHeight(int) Layout
+	Equalize() Layout
 }
 
 // Window holds the window index and it is active or not.
@@ -165,6 +168,21 @@ func (l Window) Close() Layout {
 	return l
 }
 
+// AdjustWidth adjusts the width of the active window.
+func (l Window) AdjustWidth(int) Layout {
+	return l
+}
+
+// AdjustHeight adjusts the height of the active window.
+func (l Window) AdjustHeight(int) Layout {
+	return l
+}
+
+// Equalize makes the windows almost the same size.
+func (l Window) Equalize() Layout {
+	return l
+}
+
 // Horizontal holds two layout horizontally.
 type Horizontal struct {
 	Top    Layout
@@ -173,6 +191,7 @@ type Horizontal struct {
 	top    int
 	width  int
 	height int
+	delta  int
 }
 
 func (l Horizontal) isLayout() {}
@@ -195,6 +214,7 @@ func (l Horizontal) Replace(index int) Layout {
 		top:    l.top,
 		width:  l.width,
 		height: l.height,
+		delta:  l.delta,
 	}
 }
 
@@ -202,7 +222,7 @@ func (l Horizontal) Replace(index int) Layout {
 func (l Horizontal) Resize(left, top, width, height int) Layout {
 	_, h1 := l.Top.Count()
 	_, h2 := l.Bottom.Count()
-	topHeight := height * h1 / (h1 + h2)
+	topHeight := split(height, h1, h2, l.delta)
 	return Horizontal{
 		Top:    l.Top.Resize(left, top, width, topHeight),
 		Bottom: l.Bottom.Resize(left, top+topHeight, width, height-topHeight),
@@ -210,6 +230,7 @@ func (l Horizontal) Resize(left, top, width, height int) Layout {
 		top:    top,
 		width:  width,
 		height: height,
+		delta:  l.delta,
 	}
 }
 
@@ -238,6 +259,7 @@ func (l Horizontal) SplitTop(index int) Layout {
 	return Horizontal{
 		Top:    l.Top.SplitTop(index),
 		Bottom: l.Bottom.SplitTop(index),
+		delta:  l.delta,
 	}
 }
 
@@ -246,6 +268,7 @@ func (l Horizontal) SplitBottom(index int) Layout {
 	return Horizontal{
 		Top:    l.Top.SplitBottom(index),
 		Bottom: l.Bottom.SplitBottom(index),
+		delta:  l.delta,
 	}
 }
 
@@ -254,6 +277,7 @@ func (l Horizontal) SplitLeft(index int) Layout {
 	return Horizontal{
 		Top:    l.Top.SplitLeft(index),
 		Bottom: l.Bottom.SplitLeft(index),
+		delta:  l.delta,
 	}
 }
 
@@ -262,6 +286,7 @@ func (l Horizontal) SplitRight(index int) Layout {
 	return Horizontal{
 		Top:    l.Top.SplitRight(index),
 		Bottom: l.Bottom.SplitRight(index),
+		delta:  l.delta,
 	}
 }
 
@@ -281,6 +306,7 @@ func (l Horizontal) Activate(i int) Layout {
 		top:    l.top,
 		width:  l.width,
 		height: l.height,
+		delta:  l.delta,
 	}
 }
 
@@ -293,6 +319,7 @@ func (l Horizontal) ActivateFirst() Layout {
 		top:    l.top,
 		width:  l.width,
 		height: l.height,
+		delta:  l.delta,
 	}
 }
 
@@ -329,7 +356,42 @@ func (l Horizontal) Close() Layout {
 	return Horizontal{
 		Top:    l.Top.Close(),
 		Bottom: l.Bottom.Close(),
+		delta:  l.delta,
+	}
+}
+
+// AdjustWidth adjusts the width of the active window.
+func (l Horizontal) AdjustWidth(n int) Layout {
+	if l.Top.ActiveWindow().Index >= 0 {
+		l.Top = l.Top.AdjustWidth(n)
+	} else {
+		l.Bottom = l.Bottom.AdjustWidth(n)
 	}
+	return l
+}
+
+// AdjustHeight adjusts the height of the active window.
+func (l Horizontal) AdjustHeight(n int) Layout {
+	_, h1 := l.Top.Count()
+	_, h2 := l.Bottom.Count()
+	if l.Top.ActiveWindow().Index >= 0 {
+		if splitsActive(l.Top, true) {
+			l.Top = l.Top.AdjustHeight(n)
+		} else {
+			l.delta = adjust(l.height, h1, h2, l.delta, n)
+		}
+	} else if splitsActive(l.Bottom, true) {
+		l.Bottom = l.Bottom.AdjustHeight(n)
+	} else {
+		l.delta = adjust(l.height, h1, h2, l.delta, -n)
+	}
+	return l
+}
+
+// Equalize makes the windows almost the same size.
+func (l Horizontal) Equalize() Layout {
+	l.Top, l.Bottom, l.delta = l.Top.Equalize(), l.Bottom.Equalize(), 0
+	return l
 }
 
 // Vertical holds two layout vertically.
@@ -340,6 +402,7 @@ type Vertical struct {
 	top    int
 	width  int
 	height int
+	delta  int
 }
 
 func (l Vertical) isLayout() {}
@@ -362,6 +425,7 @@ func (l Vertical) Replace(index int) Layout {
 		top:    l.top,
 		width:  l.width,
 		height: l.height,
+		delta:  l.delta,
 	}
 }
 
@@ -369,7 +433,7 @@ func (l Vertical) Replace(index int) Layout {
 func (l Vertical) Resize(left, top, width, height int) Layout {
 	w1, _ := l.Left.Count()
 	w2, _ := l.Right.Count()
-	leftWidth := width * w1 / (w1 + w2)
+	leftWidth := split(width, w1, w2, l.delta)
 	return Vertical{
 		Left: l.Left.Resize(left, top, leftWidth, height),
 		Right: l.Right.Resize(
@@ -379,6 +443,7 @@ func (l Vertical) Resize(left, top, width, height int) Layout {
 		top:    top,
 		width:  width,
 		height: height,
+		delta:  l.delta,
 	}
 }
 
@@ -407,6 +472,7 @@ func (l Vertical) SplitTop(index int) Layout {
 	return Vertical{
 		Left:  l.Left.SplitTop(index),
 		Right: l.Right.SplitTop(index),
+		delta: l.delta,
 	}
 }
 
@@ -415,6 +481,7 @@ func (l Vertical) SplitBottom(index int) Layout {
 	return Vertical{
 		Left:  l.Left.SplitBottom(index),
 		Right: l.Right.SplitBottom(index),
+		delta: l.delta,
 	}
 }
 
@@ -423,6 +490,7 @@ func (l Vertical) SplitLeft(index int) Layout {
 	return Vertical{
 		Left:  l.Left.SplitLeft(index),
 		Right: l.Right.SplitLeft(index),
+		delta: l.delta,
 	}
 }
 
@@ -431,6 +499,7 @@ func (l Vertical) SplitRight(index int) Layout {
 	return Vertical{
 		Left:  l.Left.SplitRight(index),
 		Right: l.Right.SplitRight(index),
+		delta: l.delta,
 	}
 }
 
@@ -450,6 +519,7 @@ func (l Vertical) Activate(i int) Layout {
 		top:    l.top,
 		width:  l.width,
 		height: l.height,
+		delta:  l.delta,
 	}
 }
 
@@ -462,6 +532,7 @@ func (l Vertical) ActivateFirst() Layout {
 		top:    l.top,
 		width:  l.width,
 		height: l.height,
+		delta:  l.delta,
 	}
 }
 
@@ -498,5 +569,82 @@ func (l Vertical) Close() Layout {
 	return Vertical{
 		Left:  l.Left.Close(),
 		Right: l.Right.Close(),
+		delta: l.delta,
+	}
+}
+
+// AdjustWidth adjusts the width of the active window.
+func (l Vertical) AdjustWidth(n int) Layout {
+	w1, _ := l.Left.Count()
+	w2, _ := l.Right.Count()
+	if l.Left.ActiveWindow().Index >= 0 {
+		if splitsActive(l.Left, false) {
+			l.Left = l.Left.AdjustWidth(n)
+		} else {
+			l.delta = adjust(l.width, w1, w2, l.delta, n)
+		}
+	} else if splitsActive(l.Right, false) {
+		l.Right = l.Right.AdjustWidth(n)
+	} else {
+		l.delta = adjust(l.width, w1, w2, l.delta, -n)
+	}
+	return l
+}
+
+// AdjustHeight adjusts the height of the active window.
+func (l Vertical) AdjustHeight(n int) Layout {
+	if l.Left.ActiveWindow().Index >= 0 {
+		l.Left = l.Left.AdjustHeight(n)
+	} else {
+		l.Right = l.Right.AdjustHeight(n)
+	}
+	return l
+}
+
+// Equalize makes the windows almost the same size.
+func (l Vertical) Equalize() Layout {
+	l.Left, l.Right, l.delta = l.Left.Equalize(), l.Right.Equalize(), 0
+	return l
+}
+
+// minSize is the minimum width and height of the windows on adjusting.
+const minSize = 2
+
+// split calculates the size of the first layout, which is proportional to
+// the number of the windows and shifted by the adjusted delta.
+func split(size, n1, n2, delta int) int {
+	s := size * n1 / (n1 + n2)
+	if delta != 0 && size >= 2*minSize {
+		s = mathutil.MaxInt(mathutil.MinInt(s+delta, size-minSize), minSize)
+	}
+	return s
+}
+
+// adjust returns the delta adjusted by n, within the size of the layout.
+func adjust(size, n1, n2, delta, n int) int {
+	return split(size, n1, n2, delta+n) - size*n1/(n1+n2)
+}
+
+// splitsActive reports whether the layout splits the active window
+// horizontally, or vertically.
+func splitsActive(l Layout, horizontal bool) bool {
+	switch l := l.(type) {
+	case Horizontal:
+		if horizontal {
+			return true
+		}
+		if l.Top.ActiveWindow().Index >= 0 {
+			return splitsActive(l.Top, horizontal)
+		}
+		return splitsActive(l.Bottom, horizontal)
+	case Vertical:
+		if !horizontal {
+			return true
+		}
+		if l.Left.ActiveWindow().Index >= 0 {
+			return splitsActive(l.Left, horizontal)
+		}
+		return splitsActive(l.Right, horizontal)
 	}
+	return false
 }
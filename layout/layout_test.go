@@ -305,3 +305,42 @@ func TestLayout(t *testing.T) {
 		t.Errorf("Height() should be %+v but layout %+v", 10, layout.Height())
 	}
 }
+
+func TestLayoutAdjust(t *testing.T) {
+	layout := NewLayout(0).SplitTop(1).SplitLeft(2).Resize(0, 0, 21, 20)
+
+	layout = layout.AdjustHeight(3).AdjustWidth(4).Resize(0, 0, 21, 20)
+	expected := map[int]Window{
+		2: {Index: 2, Active: true, left: 0, top: 0, width: 14, height: 13},
+		1: {Index: 1, Active: false, left: 15, top: 0, width: 6, height: 13},
+		0: {Index: 0, Active: false, left: 0, top: 13, width: 21, height: 7},
+	}
+	if got := layout.Collect(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("layout should be %+v but got %+v", expected, got)
+	}
+
+	layout = layout.AdjustHeight(-100).AdjustWidth(100).Resize(0, 0, 21, 20)
+	expected = map[int]Window{
+		2: {Index: 2, Active: true, left: 0, top: 0, width: 19, height: 2},
+		1: {Index: 1, Active: false, left: 20, top: 0, width: 1, height: 2},
+		0: {Index: 0, Active: false, left: 0, top: 2, width: 21, height: 18},
+	}
+	if got := layout.Collect(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("layout should be %+v but got %+v", expected, got)
+	}
+
+	layout = layout.Activate(0).AdjustHeight(-1).Resize(0, 0, 21, 20)
+	if got := layout.Collect()[0].height; got != 17 {
+		t.Errorf("height should be %d but got %d", 17, got)
+	}
+
+	layout = layout.Equalize().Resize(0, 0, 21, 20)
+	expected = map[int]Window{
+		2: {Index: 2, Active: false, left: 0, top: 0, width: 10, height: 10},
+		1: {Index: 1, Active: false, left: 11, top: 0, width: 10, height: 10},
+		0: {Index: 0, Active: true, left: 0, top: 10, width: 21, height: 10},
+	}
+	if got := layout.Collect(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("layout should be %+v but got %+v", expected, got)
+	}
+}
@@ -11,4 +11,7 @@ const (
 	Visual
 	Cmdline
 	Search
+	Expression
+	Confirm
+	Passphrase
 )
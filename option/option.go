@@ -0,0 +1,271 @@
+package option
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Definition defines an option.
+type Definition struct {
+	Name    string
+	Abbr    string
+	Default interface{}
+	Local   bool
+}
+
+var definitions = []Definition{
+	{Name: "arch", Abbr: "ar", Default: "x86-64", Local: true},
+	{Name: "autosave", Abbr: "as", Default: time.Duration(0)},
+	{Name: "autowrite", Abbr: "aw", Default: false},
+	{Name: "backup", Abbr: "bk", Default: false},
+	{Name: "backupdir", Abbr: "bdir", Default: ""},
+	{Name: "baseaddress", Abbr: "ba", Default: int64(0), Local: true},
+	{Name: "clipformat", Abbr: "cf", Default: "raw"},
+	{Name: "decompress", Abbr: "dc", Default: false},
+	{Name: "encoding", Abbr: "enc", Default: "ascii", Local: true},
+	{Name: "errorbells", Abbr: "eb", Default: false},
+	{Name: "keyfile", Abbr: "kf", Default: ""},
+	{Name: "lock", Abbr: "lk", Default: false},
+	{Name: "modifiable", Abbr: "ma", Default: true, Local: true},
+	{Name: "nibble", Abbr: "nib", Default: false, Local: true},
+	{Name: "packetcolor", Abbr: "pkc", Default: "teal", Local: true},
+	{Name: "preservemtime", Abbr: "pmt", Default: false},
+	{Name: "readonly", Abbr: "ro", Default: false, Local: true},
+	{Name: "recordsize", Abbr: "rs", Default: 0, Local: true},
+	{Name: "relativeoffset", Abbr: "rof", Default: false, Local: true},
+	{Name: "rowsum", Abbr: "rsm", Default: "", Local: true},
+	{Name: "ruler", Abbr: "ru", Default: true},
+	{Name: "schema", Abbr: "sch", Default: "", Local: true},
+	{Name: "searchhistory", Abbr: "shi", Default: false},
+	{Name: "sparse", Abbr: "sps", Default: false, Local: true},
+	{Name: "statusline", Abbr: "stl", Default: ""},
+	{Name: "stringlength", Abbr: "sl", Default: 4, Local: true},
+	{Name: "swapfile", Abbr: "swf", Default: true, Local: true},
+	{Name: "table", Abbr: "tbl", Default: false, Local: true},
+	{Name: "title", Abbr: "ti", Default: true},
+	{Name: "truecolor", Abbr: "tc", Default: true},
+	{Name: "undolevels", Abbr: "ul", Default: 1000, Local: true},
+	{Name: "undomemory", Abbr: "um", Default: 1024, Local: true},
+	{Name: "visualbell", Abbr: "vb", Default: false},
+	{Name: "width", Abbr: "wi", Default: 0, Local: true},
+	{Name: "wrapscan", Abbr: "ws", Default: false},
+	{Name: "writeinplace", Abbr: "wip", Default: false},
+}
+
+// Lookup the definition of the option name or the abbreviation.
+func Lookup(name string) (*Definition, bool) {
+	for i, def := range definitions {
+		if def.Name == name || def.Abbr == name {
+			return &definitions[i], true
+		}
+	}
+	return nil, false
+}
+
+// Options holds the values of the options.
+type Options struct {
+	values map[string]interface{}
+	mu     *sync.Mutex
+}
+
+// New creates a new Options with the default values.
+func New() *Options {
+	values := make(map[string]interface{}, len(definitions))
+	for _, def := range definitions {
+		values[def.Name] = def.Default
+	}
+	return &Options{values: values, mu: new(sync.Mutex)}
+}
+
+// Clone the options.
+func (o *Options) Clone() *Options {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	values := make(map[string]interface{}, len(o.values))
+	for name, value := range o.values {
+		values[name] = value
+	}
+	return &Options{values: values, mu: new(sync.Mutex)}
+}
+
+func (o *Options) get(name string) interface{} {
+	if o == nil {
+		def, _ := Lookup(name)
+		return def.Default
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.values[name]
+}
+
+// Bool returns the value of the boolean option.
+func (o *Options) Bool(name string) bool {
+	return o.get(name).(bool)
+}
+
+// Int returns the value of the number option.
+func (o *Options) Int(name string) int {
+	return o.get(name).(int)
+}
+
+// Int64 returns the value of the 64-bit number option.
+func (o *Options) Int64(name string) int64 {
+	return o.get(name).(int64)
+}
+
+// Duration returns the value of the duration option.
+func (o *Options) Duration(name string) time.Duration {
+	return o.get(name).(time.Duration)
+}
+
+// String returns the value of the string option.
+func (o *Options) String(name string) string {
+	return o.get(name).(string)
+}
+
+// Setting is a parsed argument of :set.
+type Setting struct {
+	Definition *Definition
+	value      interface{}
+	op         settingOp
+}
+
+type settingOp int
+
+const (
+	opAssign settingOp = iota
+	opQuery
+	opReset
+	opInvert
+)
+
+// Parse an argument of :set; name, noname, invname, name!, name? or name=value.
+func Parse(arg string) (*Setting, error) {
+	if i := strings.IndexByte(arg, '='); i >= 0 {
+		def, ok := Lookup(arg[:i])
+		if !ok {
+			return nil, fmt.Errorf("unknown option: %s", arg[:i])
+		}
+		switch def.Default.(type) {
+		case bool:
+			return nil, fmt.Errorf("invalid argument: %s", arg)
+		case int:
+			n, err := strconv.ParseUint(arg[i+1:], 0, 31)
+			if err != nil {
+				return nil, fmt.Errorf("number required after =: %s", arg)
+			}
+			return &Setting{Definition: def, value: int(n)}, nil
+		case int64:
+			n, err := strconv.ParseUint(arg[i+1:], 0, 63)
+			if err != nil {
+				return nil, fmt.Errorf("number required after =: %s", arg)
+			}
+			return &Setting{Definition: def, value: int64(n)}, nil
+		case time.Duration:
+			d, err := time.ParseDuration(arg[i+1:])
+			if err != nil || d < 0 {
+				return nil, fmt.Errorf("duration required after =: %s", arg)
+			}
+			return &Setting{Definition: def, value: d}, nil
+		default:
+			return &Setting{Definition: def, value: arg[i+1:]}, nil
+		}
+	}
+	if strings.HasSuffix(arg, "?") {
+		def, ok := Lookup(arg[:len(arg)-1])
+		if !ok {
+			return nil, fmt.Errorf("unknown option: %s", arg[:len(arg)-1])
+		}
+		return &Setting{Definition: def, op: opQuery}, nil
+	}
+	if def, ok := Lookup(arg); ok {
+		if _, ok := def.Default.(bool); ok {
+			return &Setting{Definition: def, value: true}, nil
+		}
+		return &Setting{Definition: def, op: opQuery}, nil
+	}
+	for prefix, op := range map[string]settingOp{"no": opReset, "inv": opInvert} {
+		if strings.HasPrefix(arg, prefix) {
+			if def, ok := Lookup(arg[len(prefix):]); ok {
+				if _, ok := def.Default.(bool); !ok {
+					return nil, fmt.Errorf("invalid argument: %s", arg)
+				}
+				return &Setting{Definition: def, op: op}, nil
+			}
+		}
+	}
+	if strings.HasSuffix(arg, "!") {
+		if def, ok := Lookup(arg[:len(arg)-1]); ok {
+			if _, ok := def.Default.(bool); !ok {
+				return nil, fmt.Errorf("invalid argument: %s", arg)
+			}
+			return &Setting{Definition: def, op: opInvert}, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown option: %s", arg)
+}
+
+// IsQuery reports whether the setting only shows the value.
+func (s *Setting) IsQuery() bool {
+	return s.op == opQuery
+}
+
+// Apply the setting to the options.
+func (o *Options) Apply(s *Setting) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	switch s.op {
+	case opAssign:
+		o.values[s.Definition.Name] = s.value
+	case opReset:
+		o.values[s.Definition.Name] = false
+	case opInvert:
+		o.values[s.Definition.Name] = !o.values[s.Definition.Name].(bool)
+	}
+}
+
+// Set the option with the argument of :set.
+func (o *Options) Set(arg string) error {
+	s, err := Parse(arg)
+	if err != nil {
+		return err
+	}
+	o.Apply(s)
+	return nil
+}
+
+// Format the value of the option.
+func (o *Options) Format(name string) string {
+	switch value := o.get(name).(type) {
+	case bool:
+		if value {
+			return name
+		}
+		return "no" + name
+	default:
+		return fmt.Sprintf("%s=%v", name, value)
+	}
+}
+
+// Names returns the sorted option names.
+func Names() []string {
+	names := make([]string, len(definitions))
+	for i, def := range definitions {
+		names[i] = def.Name
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SplitArgs splits the argument of :set into the settings. The argument
+// containing = is a single setting so that string values can contain spaces.
+func SplitArgs(arg string) []string {
+	if strings.IndexByte(arg, '=') >= 0 {
+		return []string{strings.TrimSpace(arg)}
+	}
+	return strings.Fields(arg)
+}
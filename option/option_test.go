@@ -0,0 +1,81 @@
+package option
+
+import (
+	"testing"
+	"time"
+)
+
+func TestOptionsSet(t *testing.T) {
+	o := New()
+	testCases := []struct {
+		arg, name, expected string
+	}{
+		{"noruler", "ruler", "noruler"},
+		{"ruler", "ruler", "ruler"},
+		{"invruler", "ruler", "noruler"},
+		{"ru!", "ruler", "ruler"},
+		{"ws", "wrapscan", "wrapscan"},
+		{"width=0x10", "width", "width=16"},
+		{"ba=0x80000000", "baseaddress", "baseaddress=2147483648"},
+		{"autosave=30s", "autosave", "autosave=30s"},
+		{"stl= %f %m", "statusline", "statusline= %f %m"},
+		{"ruler?", "ruler", "ruler"},
+	}
+	for _, testCase := range testCases {
+		if err := o.Set(testCase.arg); err != nil {
+			t.Errorf("err should be nil but got: %v", err)
+		}
+		if got := o.Format(testCase.name); got != testCase.expected {
+			t.Errorf("%s should be %q after set %s but got %q", testCase.name, testCase.expected, testCase.arg, got)
+		}
+	}
+	if !o.Bool("ruler") || o.Int("width") != 16 || o.String("statusline") != " %f %m" ||
+		o.Duration("autosave") != 30*time.Second || o.Int64("baseaddress") != 0x80000000 {
+		t.Errorf("options should be updated but got: %+v", o.values)
+	}
+}
+
+func TestOptionsSetError(t *testing.T) {
+	testCases := []struct {
+		arg, expected string
+	}{
+		{"unknown", "unknown option: unknown"},
+		{"unknown=1", "unknown option: unknown"},
+		{"ruler=1", "invalid argument: ruler=1"},
+		{"nowidth", "invalid argument: nowidth"},
+		{"width!", "invalid argument: width!"},
+		{"width=x", "number required after =: width=x"},
+		{"baseaddress=-1", "number required after =: baseaddress=-1"},
+		{"autosave=30", "duration required after =: autosave=30"},
+	}
+	for _, testCase := range testCases {
+		err := New().Set(testCase.arg)
+		if err == nil || err.Error() != testCase.expected {
+			t.Errorf("set %s should return error %q but got: %v", testCase.arg, testCase.expected, err)
+		}
+	}
+}
+
+func TestOptionsClone(t *testing.T) {
+	o := New()
+	p := o.Clone()
+	if err := p.Set("width=8"); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	if o.Int("width") != 0 || p.Int("width") != 8 {
+		t.Errorf("cloned options should be independent but got %d and %d", o.Int("width"), p.Int("width"))
+	}
+	var q *Options
+	if !q.Bool("ruler") {
+		t.Errorf("nil options should return the default value")
+	}
+}
+
+func TestSplitArgs(t *testing.T) {
+	if xs := SplitArgs(" ruler  nows "); len(xs) != 2 || xs[0] != "ruler" || xs[1] != "nows" {
+		t.Errorf("SplitArgs should split the arguments but got %q", xs)
+	}
+	if xs := SplitArgs("stl=%f %m"); len(xs) != 1 || xs[0] != "stl=%f %m" {
+		t.Errorf("SplitArgs should not split the string value but got %q", xs)
+	}
+}
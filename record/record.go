@@ -0,0 +1,114 @@
+package record
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/itchyny/bed/event"
+	"github.com/itchyny/bed/key"
+	"github.com/itchyny/bed/mode"
+	"github.com/itchyny/bed/state"
+)
+
+// UI is the user interface of the editor, which the Recorder and the Replayer
+// wrap.
+type UI interface {
+	Init(chan<- event.Event) error
+	Run(map[mode.Mode]*key.Manager)
+	Size() (int, int)
+	Redraw(state.State) error
+	Close() error
+}
+
+// entry is a line of the event log. The time is the milliseconds from the
+// start of the recording, and the size is the screen size at the event. The
+// types of the events are the numbers, so the log is replayed by the same
+// version of the editor.
+type entry struct {
+	Time    int64      `json:"time"`
+	Width   int        `json:"width"`
+	Height  int        `json:"height"`
+	Type    event.Type `json:"type"`
+	Count   int64      `json:"count,omitempty"`
+	Rune    rune       `json:"rune,omitempty"`
+	CmdName string     `json:"cmdname,omitempty"`
+	Bang    bool       `json:"bang,omitempty"`
+	Arg     string     `json:"arg,omitempty"`
+	Bytes   []byte     `json:"bytes,omitempty"`
+	Mode    mode.Mode  `json:"mode,omitempty"`
+}
+
+// Recorder implements UI, which writes the events of the user interface to
+// the event log in JSON lines, for replaying them against the same file.
+// The events of the other sources, and the ones emitted by the editor itself,
+// are not recorded since they are emitted again on replaying.
+type Recorder struct {
+	ui      UI
+	w       io.Writer
+	enc     *json.Encoder
+	start   time.Time
+	eventCh chan<- event.Event
+	uiCh    chan event.Event
+	err     error
+	mu      *sync.Mutex
+}
+
+// NewRecorder creates a new Recorder, which writes the log to the writer.
+func NewRecorder(ui UI, w io.Writer) *Recorder {
+	return &Recorder{ui: ui, w: w, enc: json.NewEncoder(w), mu: new(sync.Mutex)}
+}
+
+// Init initializes the Recorder. It is called again on resuming the editor.
+func (r *Recorder) Init(eventCh chan<- event.Event) error {
+	if r.uiCh == nil {
+		r.eventCh, r.uiCh, r.start = eventCh, make(chan event.Event), time.Now()
+		go r.forward()
+	}
+	return r.ui.Init(r.uiCh)
+}
+
+// forward records the events of the user interface, and sends them to the
+// editor. The first error of writing the log is reported on closing.
+func (r *Recorder) forward() {
+	for e := range r.uiCh {
+		width, height := r.ui.Size()
+		r.mu.Lock()
+		if r.err == nil {
+			r.err = r.enc.Encode(&entry{
+				Time: time.Since(r.start).Milliseconds(), Width: width, Height: height,
+				Type: e.Type, Count: e.Count, Rune: e.Rune, CmdName: e.CmdName,
+				Bang: e.Bang, Arg: e.Arg, Bytes: e.Bytes, Mode: e.Mode,
+			})
+		}
+		r.mu.Unlock()
+		r.eventCh <- e
+	}
+}
+
+// Run the Recorder.
+func (r *Recorder) Run(kms map[mode.Mode]*key.Manager) {
+	r.ui.Run(kms)
+}
+
+// Size returns the size of the user interface.
+func (r *Recorder) Size() (int, int) {
+	return r.ui.Size()
+}
+
+// Redraw the user interface.
+func (r *Recorder) Redraw(s state.State) error {
+	return r.ui.Redraw(s)
+}
+
+// Close the user interface, and returns the error of writing the log.
+func (r *Recorder) Close() error {
+	err := r.ui.Close()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err == nil {
+		err = r.err
+	}
+	return err
+}
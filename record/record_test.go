@@ -0,0 +1,100 @@
+package record
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/itchyny/bed/event"
+	"github.com/itchyny/bed/key"
+	"github.com/itchyny/bed/mode"
+	"github.com/itchyny/bed/state"
+)
+
+type testUI struct {
+	events  []event.Event
+	eventCh chan<- event.Event
+}
+
+func (ui *testUI) Init(eventCh chan<- event.Event) error {
+	ui.eventCh = eventCh
+	return nil
+}
+
+func (ui *testUI) Run(_ map[mode.Mode]*key.Manager) {
+	for _, e := range ui.events {
+		ui.eventCh <- e
+	}
+}
+
+func (ui *testUI) Size() (int, int) { return 80, 24 }
+
+func (ui *testUI) Redraw(_ state.State) error { return nil }
+
+func (ui *testUI) Close() error { return nil }
+
+func TestRecordReplay(t *testing.T) {
+	events := []event.Event{
+		{Type: event.CursorDown, Count: 3},
+		{Type: event.StartInsert, Mode: mode.Normal},
+		{Type: event.Rune, Rune: 'a', Mode: mode.Insert},
+		{Type: event.ExitInsert},
+	}
+	var buf bytes.Buffer
+	r := NewRecorder(&testUI{events: events}, &buf)
+	eventCh := make(chan event.Event)
+	if err := r.Init(eventCh); err != nil {
+		t.Fatalf("err should be nil but got: %v", err)
+	}
+	go r.Run(nil)
+	for i := range events {
+		if e := <-eventCh; !reflect.DeepEqual(e, events[i]) {
+			t.Errorf("event should be %+v but got: %+v", events[i], e)
+		}
+	}
+	if err := r.Close(); err != nil {
+		t.Fatalf("err should be nil but got: %v", err)
+	}
+	if lines := strings.Split(strings.TrimSpace(buf.String()), "\n"); len(lines) != len(events) ||
+		!strings.HasSuffix(lines[0], fmt.Sprintf(`"width":80,"height":24,"type":%d,"count":3}`, event.CursorDown)) {
+		t.Errorf("log should have the events but got: %s", buf.String())
+	}
+
+	p := NewReplayer(&testUI{events: []event.Event{{Type: event.Quit}}}, &buf, "test.log")
+	eventCh = make(chan event.Event)
+	if err := p.Init(eventCh); err != nil {
+		t.Fatalf("err should be nil but got: %v", err)
+	}
+	go p.Run(nil)
+	for i := range events {
+		if e := <-eventCh; !reflect.DeepEqual(e, events[i]) {
+			t.Errorf("event should be %+v but got: %+v", events[i], e)
+		}
+	}
+	if e := <-eventCh; e.Type != event.Info || e.Error.Error() != "replayed 4 events from test.log" {
+		t.Errorf("replay should finish but got: %+v", e)
+	}
+	if err := p.Close(); err != nil {
+		t.Fatalf("err should be nil but got: %v", err)
+	}
+}
+
+func TestReplayError(t *testing.T) {
+	p := NewReplayer(&testUI{}, strings.NewReader("{\"type\":1}\n\n{\n"), "test.log")
+	eventCh := make(chan event.Event)
+	if err := p.Init(eventCh); err != nil {
+		t.Fatalf("err should be nil but got: %v", err)
+	}
+	go p.Run(nil)
+	if e := <-eventCh; e.Type != event.Redraw {
+		t.Errorf("event type should be %d but got: %d", event.Redraw, e.Type)
+	}
+	if e := <-eventCh; e.Type != event.Error || e.Error.Error() != "test.log:3: unexpected end of JSON input" {
+		t.Errorf("replay should fail but got: %+v", e)
+	}
+	if err := p.Close(); err != nil {
+		t.Fatalf("err should be nil but got: %v", err)
+	}
+}
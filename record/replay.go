@@ -0,0 +1,158 @@
+package record
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/itchyny/bed/event"
+	"github.com/itchyny/bed/key"
+	"github.com/itchyny/bed/mode"
+	"github.com/itchyny/bed/state"
+)
+
+// Replayer implements UI, which emits the events of the event log with the
+// recorded intervals and screen sizes, instead of the events of the user
+// interface. After the last event, the events of the user interface are sent
+// to the editor, so that the user inspects the replayed state.
+type Replayer struct {
+	ui        UI
+	r         io.Reader
+	name      string
+	eventCh   chan<- event.Event
+	uiCh      chan event.Event
+	doneCh    chan struct{}
+	width     int
+	height    int
+	started   bool
+	replaying bool
+	closeOnce sync.Once
+	mu        *sync.Mutex
+}
+
+// NewReplayer creates a new Replayer, which reads the log from the reader.
+// The name is used in the error messages.
+func NewReplayer(ui UI, r io.Reader, name string) *Replayer {
+	return &Replayer{ui: ui, r: r, name: name, mu: new(sync.Mutex)}
+}
+
+// Init initializes the Replayer. It is called again on resuming the editor.
+func (r *Replayer) Init(eventCh chan<- event.Event) error {
+	r.mu.Lock()
+	if r.uiCh == nil {
+		r.eventCh, r.uiCh, r.doneCh = eventCh, make(chan event.Event), make(chan struct{})
+		r.replaying = true
+		go r.forward()
+	}
+	r.mu.Unlock()
+	return r.ui.Init(r.uiCh)
+}
+
+// forward discards the events of the user interface while replaying.
+func (r *Replayer) forward() {
+	for e := range r.uiCh {
+		r.mu.Lock()
+		replaying := r.replaying
+		r.mu.Unlock()
+		if !replaying {
+			r.eventCh <- e
+		}
+	}
+}
+
+// Run the Replayer. The replay starts on the first run.
+func (r *Replayer) Run(kms map[mode.Mode]*key.Manager) {
+	r.mu.Lock()
+	if !r.started {
+		r.started = true
+		go r.replay()
+	}
+	r.mu.Unlock()
+	r.ui.Run(kms)
+}
+
+func (r *Replayer) replay() {
+	br := bufio.NewReader(r.r)
+	var count int
+	var last int64
+	for n := 1; ; n++ {
+		line, err := br.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			var en entry
+			if err := json.Unmarshal(line, &en); err != nil {
+				r.finish(event.Event{Type: event.Error, Error: fmt.Errorf("%s:%d: %s", r.name, n, err)})
+				return
+			}
+			if en.Time > last {
+				select {
+				case <-time.After(time.Duration(en.Time-last) * time.Millisecond):
+				case <-r.doneCh:
+					return
+				}
+				last = en.Time
+			}
+			r.mu.Lock()
+			r.width, r.height = en.Width, en.Height
+			r.mu.Unlock()
+			select {
+			case r.eventCh <- event.Event{
+				Type: en.Type, Count: en.Count, Rune: en.Rune, CmdName: en.CmdName,
+				Bang: en.Bang, Arg: en.Arg, Bytes: en.Bytes, Mode: en.Mode,
+			}:
+			case <-r.doneCh:
+				return
+			}
+			count++
+		}
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			r.finish(event.Event{Type: event.Error, Error: err})
+			return
+		}
+	}
+	r.finish(event.Event{Type: event.Info, Error: fmt.Errorf("replayed %d events from %s", count, r.name)})
+}
+
+// finish passes the events of the user interface to the editor, and emits
+// the event of the result of the replay.
+func (r *Replayer) finish(e event.Event) {
+	r.mu.Lock()
+	r.replaying = false
+	r.mu.Unlock()
+	select {
+	case r.eventCh <- e:
+	case <-r.doneCh:
+	}
+}
+
+// Size returns the recorded size of the screen while replaying, so that the
+// events move the cursor as recorded.
+func (r *Replayer) Size() (int, int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.replaying && r.width > 0 && r.height > 0 {
+		return r.width, r.height
+	}
+	return r.ui.Size()
+}
+
+// Redraw the user interface.
+func (r *Replayer) Redraw(s state.State) error {
+	return r.ui.Redraw(s)
+}
+
+// Close the user interface, and stops the replay.
+func (r *Replayer) Close() error {
+	r.mu.Lock()
+	r.replaying = false
+	r.mu.Unlock()
+	if r.doneCh != nil {
+		r.closeOnce.Do(func() { close(r.doneCh) })
+	}
+	return r.ui.Close()
+}
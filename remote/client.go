@@ -0,0 +1,27 @@
+package remote
+
+import (
+	"net"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+)
+
+// Dial connects to the editor listening on the unix domain socket.
+func Dial(addr string) (*rpc.Client, error) {
+	conn, err := net.Dial("unix", addr)
+	if err != nil {
+		return nil, err
+	}
+	return jsonrpc.NewClient(conn), nil
+}
+
+// Send the command line to the editor listening on the address.
+func Send(addr, line string) error {
+	client, err := Dial(addr)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	var reply bool
+	return client.Call("Bed.Command", line, &reply)
+}
@@ -0,0 +1,251 @@
+package remote
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/itchyny/bed/event"
+	"github.com/itchyny/bed/key"
+	"github.com/itchyny/bed/mode"
+	"github.com/itchyny/bed/state"
+)
+
+// Server is the RPC server to control the running editor, which implements
+// EventSource of the editor. Each connection speaks JSON-RPC 1.0, and the
+// methods are Bed.Command, Bed.Goto, Bed.Search, Bed.Replace, Bed.Save and
+// Bed.State. The events are emitted asynchronously, so Bed.State reflects
+// them after the editor handles them.
+type Server struct {
+	listener net.Listener
+	parse    func(string) (event.Event, error)
+	state    func() (state.State, error)
+	eventCh  chan<- event.Event
+	doneCh   chan struct{}
+	closed   bool
+	once     *sync.Once
+	mu       *sync.RWMutex
+}
+
+// Listen on the unix domain socket at the path. The parse function parses
+// the command line, and the state function returns the state of the editor.
+func Listen(addr string, parse func(string) (event.Event, error), state func() (state.State, error)) (*Server, error) {
+	listener, err := listen(addr)
+	if err != nil {
+		return nil, err
+	}
+	return &Server{
+		listener: listener, parse: parse, state: state,
+		doneCh: make(chan struct{}), once: new(sync.Once), mu: new(sync.RWMutex),
+	}, nil
+}
+
+// listen on the unix domain socket. Since the server accepts any command
+// without the authentication, TCP is not supported, and the socket is made
+// accessible only by the user. The socket is changed the mode after created,
+// instead of changing the umask of the process, which is shared by the other
+// goroutines creating files.
+func listen(addr string) (net.Listener, error) {
+	if !strings.ContainsRune(addr, '/') && strings.ContainsRune(addr, ':') {
+		return nil, fmt.Errorf("not a path of the unix domain socket: %s", addr)
+	}
+	listener, err := net.Listen("unix", addr)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(addr, 0600); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	return listener, nil
+}
+
+// Addr returns the address of the Server.
+func (s *Server) Addr() net.Addr {
+	return s.listener.Addr()
+}
+
+// Init initializes the Server.
+func (s *Server) Init(eventCh chan<- event.Event) error {
+	s.eventCh = eventCh
+	return nil
+}
+
+// Run the Server.
+func (s *Server) Run(_ map[mode.Mode]*key.Manager) {
+	server := rpc.NewServer()
+	if err := server.RegisterName("Bed", &Service{s}); err != nil {
+		panic(err)
+	}
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go server.ServeCodec(jsonrpc.NewServerCodec(conn))
+	}
+}
+
+// emit sends the events to the editor.
+func (s *Server) emit(es ...event.Event) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		return errors.New("the editor is closed")
+	}
+	for _, e := range es {
+		select {
+		case s.eventCh <- e:
+		case <-s.doneCh:
+			return errors.New("the editor is closed")
+		}
+	}
+	return nil
+}
+
+// Close the Server. It waits for the events being emitted, and does nothing
+// on the second call.
+func (s *Server) Close() (err error) {
+	s.once.Do(func() {
+		err = s.listener.Close()
+		close(s.doneCh)
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.closed = true
+	})
+	return
+}
+
+// Service defines the methods of the RPC.
+type Service struct {
+	server *Server
+}
+
+// Command executes the command line, or searches the pattern prefixed by /
+// or ?.
+func (t *Service) Command(line string, reply *bool) error {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return errors.New("empty command")
+	}
+	e := event.Event{Type: event.ExecuteSearch, Arg: line[1:], Rune: rune(line[0])}
+	if line[0] != '/' && line[0] != '?' {
+		var err error
+		if e, err = t.server.parse(line); err != nil {
+			return err
+		}
+	}
+	*reply = true
+	return t.server.emit(e)
+}
+
+// Goto moves the cursor to the offset.
+func (t *Service) Goto(offset int64, reply *bool) error {
+	*reply = true
+	return t.server.emit(event.Event{
+		Type: event.CursorGoto, Range: &event.Range{From: event.Absolute{Offset: offset}},
+	})
+}
+
+// Search the pattern forward from the cursor.
+func (t *Service) Search(pattern string, reply *bool) error {
+	*reply = true
+	return t.server.emit(event.Event{Type: event.ExecuteSearch, Arg: pattern, Rune: '/'})
+}
+
+// ReplaceArgs is the argument of Bed.Replace.
+type ReplaceArgs struct {
+	Offset int64  `json:"offset"`
+	Data   string `json:"data"` // hex digits
+}
+
+// Replace the bytes at the offset.
+func (t *Service) Replace(args ReplaceArgs, reply *bool) error {
+	bs, err := hex.DecodeString(args.Data)
+	if err != nil {
+		return err
+	}
+	if len(bs) == 0 {
+		return errors.New("no bytes to replace")
+	}
+	*reply = true
+	return t.server.emit(
+		event.Event{Type: event.CursorGoto, Range: &event.Range{From: event.Absolute{Offset: args.Offset}}},
+		event.Event{Type: event.StartReplace},
+		event.Event{Type: event.InsertExpression, Bytes: bs},
+		event.Event{Type: event.ExitInsert},
+	)
+}
+
+// Save the file. The file name is optional.
+func (t *Service) Save(name string, reply *bool) error {
+	*reply = true
+	return t.server.emit(event.Event{Type: event.Write, Arg: name})
+}
+
+// State is the reply of Bed.State, which describes the active window.
+type State struct {
+	Name     string `json:"name"`
+	Cursor   int64  `json:"cursor"`
+	Offset   int64  `json:"offset"`
+	Length   int64  `json:"length"`
+	Modified bool   `json:"modified"`
+	Mode     string `json:"mode"`
+	Message  string `json:"message"`
+}
+
+// State returns the state of the active window.
+func (t *Service) State(_ struct{}, reply *State) error {
+	t.server.mu.RLock()
+	defer t.server.mu.RUnlock()
+	if t.server.closed {
+		return errors.New("the editor is closed")
+	}
+	s, err := t.server.state()
+	if err != nil {
+		return err
+	}
+	ws := s.WindowStates[s.Layout.ActiveWindow().Index]
+	if ws == nil {
+		return errors.New("no active window")
+	}
+	*reply = State{
+		Name:     ws.Name,
+		Cursor:   ws.Cursor,
+		Offset:   ws.Offset,
+		Length:   ws.Length,
+		Modified: ws.Modified,
+		Mode:     modeName(s.Mode),
+	}
+	if s.Error != nil {
+		reply.Message = s.Error.Error()
+	}
+	return nil
+}
+
+func modeName(m mode.Mode) string {
+	switch m {
+	case mode.Insert:
+		return "insert"
+	case mode.Replace:
+		return "replace"
+	case mode.Visual:
+		return "visual"
+	case mode.Cmdline:
+		return "cmdline"
+	case mode.Search:
+		return "search"
+	case mode.Expression:
+		return "expression"
+	case mode.Confirm:
+		return "confirm"
+	default:
+		return "normal"
+	}
+}
@@ -0,0 +1,152 @@
+package remote
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/itchyny/bed/cmdline"
+	"github.com/itchyny/bed/editor"
+	"github.com/itchyny/bed/event"
+	"github.com/itchyny/bed/key"
+	"github.com/itchyny/bed/mode"
+	"github.com/itchyny/bed/state"
+	"github.com/itchyny/bed/window"
+)
+
+type testUI struct{}
+
+func (ui *testUI) Init(chan<- event.Event) error { return nil }
+
+func (ui *testUI) Run(map[mode.Mode]*key.Manager) {}
+
+func (ui *testUI) Size() (int, int) { return 80, 24 }
+
+func (ui *testUI) Redraw(state.State) error { return nil }
+
+func (ui *testUI) Close() error { return nil }
+
+func TestServer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bed-test-remote")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	name := filepath.Join(dir, "test.bin")
+	if err := ioutil.WriteFile(name, []byte("Hello, world!"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cmdline := cmdline.NewCmdline()
+	editor := editor.NewEditor(&testUI{}, window.NewManager(), cmdline)
+	if err := editor.Init(); err != nil {
+		t.Fatal(err)
+	}
+	addr := filepath.Join(dir, "bed.sock")
+	server, err := Listen(addr, cmdline.Parse, editor.State)
+	if err != nil {
+		t.Fatal(err)
+	}
+	editor.AddEventSource(server)
+	if err := editor.Open(name); err != nil {
+		t.Fatal(err)
+	}
+	doneCh := make(chan error)
+	go func() { doneCh <- editor.Run() }()
+
+	client, err := Dial(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	waitState := func(cond func(*State) bool) *State {
+		var s State
+		for i := 0; i < 100; i++ {
+			if err := client.Call("Bed.State", struct{}{}, &s); err != nil {
+				t.Fatal(err)
+			}
+			if cond(&s) {
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		return &s
+	}
+
+	var reply bool
+	if err := client.Call("Bed.Goto", int64(7), &reply); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	s := waitState(func(s *State) bool { return s.Cursor == 7 })
+	if s.Cursor != 7 || s.Name != "test.bin" || s.Length != 13 || s.Mode != "normal" {
+		t.Errorf("state is not expected: %+v", s)
+	}
+
+	if err := client.Call("Bed.Replace", ReplaceArgs{Offset: 1, Data: "4142"}, &reply); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	s = waitState(func(s *State) bool { return s.Modified && s.Mode == "normal" })
+	if !s.Modified || s.Cursor != 3 {
+		t.Errorf("state is not expected: %+v", s)
+	}
+
+	if err := client.Call("Bed.Command", "foo", &reply); err == nil || err.Error() != "unknown command: foo" {
+		t.Errorf("err should be %q but got: %v", "unknown command: foo", err)
+	}
+	if err := client.Call("Bed.Save", "", &reply); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	waitState(func(s *State) bool { return !s.Modified })
+	bs, err := ioutil.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "HABlo, world!"; string(bs) != expected {
+		t.Errorf("file contents should be %q but got %q", expected, string(bs))
+	}
+
+	if err := Send(addr, "quit"); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	select {
+	case err := <-doneCh:
+		if err != nil {
+			t.Errorf("err should be nil but got: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the editor should quit")
+	}
+	if err := editor.Close(); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	if err := server.Close(); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	if err := client.Call("Bed.Goto", int64(0), &reply); err == nil {
+		t.Errorf("err should not be nil")
+	}
+}
+
+func TestListen(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bed-test-remote")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	addr := filepath.Join(dir, "bed.sock")
+	listener, err := listen(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	if fi, err := os.Stat(addr); err != nil || fi.Mode().Perm() != 0600 {
+		t.Errorf("the socket should be accessible only by the user but got: %v, %v", fi.Mode(), err)
+	}
+
+	for _, addr := range []string{":0", "localhost:0", "127.0.0.1:8080"} {
+		if _, err := listen(addr); err == nil || err.Error() != "not a path of the unix domain socket: "+addr {
+			t.Errorf("listening on %s should fail but got: %v", addr, err)
+		}
+	}
+}
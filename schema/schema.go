@@ -0,0 +1,192 @@
+package schema
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Type is the type of a value in the bytes; the unsigned or signed integer,
+// the floating point number or the characters.
+type Type struct {
+	Kind  byte // one of 'u', 'i', 'f' or 'c'
+	Size  int
+	Order binary.ByteOrder
+}
+
+// ParseType parses the type name. The name is one of u8, i8, u16, i16, u32,
+// i32, u64, i64, f32, f64, optionally followed by le (default) or be for the
+// byte order, or cN for the N characters.
+func ParseType(name string) (Type, error) {
+	t := Type{Order: binary.LittleEndian}
+	s := name
+	if strings.HasSuffix(s, "le") {
+		s = s[:len(s)-2]
+	} else if strings.HasSuffix(s, "be") {
+		s, t.Order = s[:len(s)-2], binary.BigEndian
+	}
+	if len(s) < 2 {
+		return Type{}, fmt.Errorf("unknown type: %s", name)
+	}
+	t.Kind = s[0]
+	n, err := strconv.Atoi(s[1:])
+	if err != nil || n <= 0 {
+		return Type{}, fmt.Errorf("unknown type: %s", name)
+	}
+	switch t.Kind {
+	case 'u', 'i':
+		if n != 8 && n != 16 && n != 32 && n != 64 {
+			return Type{}, fmt.Errorf("unknown type: %s", name)
+		}
+		t.Size = n / 8
+	case 'f':
+		if n != 32 && n != 64 {
+			return Type{}, fmt.Errorf("unknown type: %s", name)
+		}
+		t.Size = n / 8
+	case 'c':
+		if s != name || n > 1024 {
+			return Type{}, fmt.Errorf("unknown type: %s", name)
+		}
+		t.Size = n
+	default:
+		return Type{}, fmt.Errorf("unknown type: %s", name)
+	}
+	return t, nil
+}
+
+// Float returns the numeric value of the bytes, which should be of the size.
+func (t Type) Float(bs []byte) float64 {
+	switch t.Kind {
+	case 'u':
+		return float64(t.uint(bs))
+	case 'i':
+		return float64(t.int(bs))
+	case 'f':
+		if t.Size == 4 {
+			return float64(math.Float32frombits(uint32(t.uint(bs))))
+		}
+		return math.Float64frombits(t.uint(bs))
+	default:
+		return 0
+	}
+}
+
+// Format formats the value of the bytes, which should be of the size.
+func (t Type) Format(bs []byte) string {
+	switch t.Kind {
+	case 'u':
+		return strconv.FormatUint(t.uint(bs), 10)
+	case 'i':
+		return strconv.FormatInt(t.int(bs), 10)
+	case 'f':
+		return strconv.FormatFloat(t.Float(bs), 'g', -1, t.Size*8)
+	default:
+		var sb strings.Builder
+		for _, b := range bs {
+			if b == 0 {
+				break
+			}
+			if 0x20 <= b && b < 0x7f {
+				sb.WriteByte(b)
+			} else {
+				sb.WriteByte('.')
+			}
+		}
+		return strconv.Quote(sb.String())
+	}
+}
+
+func (t Type) uint(bs []byte) uint64 {
+	switch t.Size {
+	case 1:
+		return uint64(bs[0])
+	case 2:
+		return uint64(t.Order.Uint16(bs))
+	case 4:
+		return uint64(t.Order.Uint32(bs))
+	default:
+		return t.Order.Uint64(bs)
+	}
+}
+
+func (t Type) int(bs []byte) int64 {
+	switch t.Size {
+	case 1:
+		return int64(int8(bs[0]))
+	case 2:
+		return int64(int16(t.Order.Uint16(bs)))
+	case 4:
+		return int64(int32(t.Order.Uint32(bs)))
+	default:
+		return int64(t.Order.Uint64(bs))
+	}
+}
+
+// Field is a field of the record at the offset from the start of the record.
+type Field struct {
+	Name   string
+	Type   Type
+	Offset int
+}
+
+// Schema is the fields of a fixed-size record.
+type Schema []Field
+
+// Parse parses the schema, which is the fields separated by commas. A field
+// is [name:]type[@offset], and the offset defaults to the end of the previous
+// field.
+func Parse(str string) (Schema, error) {
+	var s Schema
+	var offset int
+	for _, f := range strings.Split(str, ",") {
+		if f = strings.TrimSpace(f); f == "" {
+			continue
+		}
+		var field Field
+		if i := strings.IndexByte(f, ':'); i >= 0 {
+			field.Name, f = f[:i], f[i+1:]
+		}
+		if i := strings.IndexByte(f, '@'); i >= 0 {
+			n, err := strconv.ParseUint(f[i+1:], 0, 31)
+			if err != nil {
+				return nil, fmt.Errorf("invalid field offset: %s", f[i+1:])
+			}
+			offset, f = int(n), f[:i]
+		}
+		t, err := ParseType(f)
+		if err != nil {
+			return nil, err
+		}
+		field.Type, field.Offset = t, offset
+		s = append(s, field)
+		offset += t.Size
+	}
+	return s, nil
+}
+
+// Size returns the size of the record, which is the end of the last field.
+func (s Schema) Size() int {
+	var size int
+	for _, f := range s {
+		if end := f.Offset + f.Type.Size; end > size {
+			size = end
+		}
+	}
+	return size
+}
+
+// Format formats the field of the record, or returns an empty string when the
+// record ends before the field.
+func (f Field) Format(record []byte) string {
+	if f.Offset+f.Type.Size > len(record) {
+		return ""
+	}
+	value := f.Type.Format(record[f.Offset : f.Offset+f.Type.Size])
+	if f.Name == "" {
+		return value
+	}
+	return f.Name + "=" + value
+}
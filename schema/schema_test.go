@@ -0,0 +1,62 @@
+package schema
+
+import (
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	record := []byte("\x01\x00\xff\xff\x00\x00\xc0\x3f\x12\x34abc\x00\x01")
+	testCases := []struct {
+		schema   string
+		size     int
+		expected []string
+	}{
+		{"u16", 2, []string{"1"}},
+		{"id:u16,i16,x:f32,u16be", 10, []string{"id=1", "-1", "x=1.5", "4660"}},
+		{"u8@3, i8, u32be@6", 10, []string{"255", "0", "3225358900"}},
+		{"name:c4@10", 14, []string{`name="abc"`}},
+		{"c2@2, c2@13, u64, f64", 31, []string{`".."`, `""`, "", ""}},
+	}
+	for _, testCase := range testCases {
+		s, err := Parse(testCase.schema)
+		if err != nil {
+			t.Errorf("err should be nil but got: %v", err)
+			continue
+		}
+		if got := s.Size(); got != testCase.size {
+			t.Errorf("Parse(%q).Size() should be %d but got %d", testCase.schema, testCase.size, got)
+		}
+		if len(s) != len(testCase.expected) {
+			t.Errorf("Parse(%q) should have %d fields but got %d", testCase.schema, len(testCase.expected), len(s))
+			continue
+		}
+		for i, f := range s {
+			if got := f.Format(record); got != testCase.expected[i] {
+				t.Errorf("Parse(%q)[%d].Format should be %q but got %q", testCase.schema, i, testCase.expected[i], got)
+			}
+		}
+	}
+}
+
+func TestParseError(t *testing.T) {
+	testCases := []struct {
+		schema   string
+		expected string
+	}{
+		{"u24", "unknown type: u24"},
+		{"x:f16", "unknown type: f16"},
+		{"c4be", "unknown type: c4be"},
+		{"int", "unknown type: int"},
+		{"u8,u8@x", "invalid field offset: x"},
+	}
+	for _, testCase := range testCases {
+		_, err := Parse(testCase.schema)
+		if err == nil {
+			t.Errorf("Parse(%q) should return an error", testCase.schema)
+			continue
+		}
+		if err.Error() != testCase.expected {
+			t.Errorf("err should be %q but got %q", testCase.expected, err.Error())
+		}
+	}
+}
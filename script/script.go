@@ -0,0 +1,141 @@
+package script
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/itchyny/bed/event"
+	"github.com/itchyny/bed/key"
+	"github.com/itchyny/bed/mode"
+	"github.com/itchyny/bed/state"
+)
+
+// waitTimeout is the time to wait for the redraw after each command. Most
+// commands redraw the editor, but some of them have nothing to redraw.
+const waitTimeout = time.Second
+
+// Script implements UI, which executes the commands in the script file
+// instead of reading the keys from the terminal. Each line of the script is
+// a command like the command line, or a search pattern prefixed by / or ?.
+// After the last command, the script writes the file and quits the editor.
+type Script struct {
+	r        io.Reader
+	name     string
+	parse    func(string) (event.Event, error)
+	eventCh  chan<- event.Event
+	redrawCh chan state.State
+	doneCh   chan struct{}
+	waitCh   chan struct{}
+	err      error
+	mu       *sync.Mutex
+}
+
+// NewScript creates a new Script. The parse function parses each line into
+// the event.
+func NewScript(r io.Reader, name string, parse func(string) (event.Event, error)) *Script {
+	return &Script{r: r, name: name, parse: parse, mu: new(sync.Mutex)}
+}
+
+// Init initializes the Script.
+func (s *Script) Init(eventCh chan<- event.Event) error {
+	s.eventCh = eventCh
+	s.redrawCh = make(chan state.State, 1)
+	s.doneCh = make(chan struct{})
+	s.waitCh = make(chan struct{})
+	return nil
+}
+
+// Run the Script.
+func (s *Script) Run(_ map[mode.Mode]*key.Manager) {
+	defer close(s.waitCh)
+	scanner := bufio.NewScanner(s.r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '"' || line[0] == '#' {
+			continue
+		}
+		e, err := s.parseLine(line)
+		if err == nil && e.Type != event.Nop {
+			err = s.execute(e)
+		}
+		if err != nil {
+			s.abort(fmt.Errorf("%s:%d: %s", s.name, n, err))
+			return
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		s.abort(err)
+		return
+	}
+	if err := s.execute(event.Event{Type: event.WriteQuit}); err != nil {
+		s.abort(err)
+	}
+}
+
+func (s *Script) parseLine(line string) (event.Event, error) {
+	if line[0] == '/' || line[0] == '?' {
+		return event.Event{Type: event.ExecuteSearch, Arg: line[1:], Rune: rune(line[0])}, nil
+	}
+	return s.parse(line)
+}
+
+// execute emits the event and waits for the redraw, and returns the error
+// message of the editor.
+func (s *Script) execute(e event.Event) error {
+	select {
+	case <-s.redrawCh:
+	default:
+	}
+	select {
+	case s.eventCh <- e:
+	case <-s.doneCh:
+		return nil
+	}
+	select {
+	case st := <-s.redrawCh:
+		if st.Error != nil && st.ErrorType == state.MessageError {
+			return st.Error
+		}
+	case <-time.After(waitTimeout):
+	case <-s.doneCh:
+	}
+	return nil
+}
+
+// abort records the error and quits the editor without writing.
+func (s *Script) abort(err error) {
+	s.mu.Lock()
+	s.err = err
+	s.mu.Unlock()
+	select {
+	case s.eventCh <- event.Event{Type: event.QuitAll, Bang: true}:
+	case <-s.doneCh:
+	}
+}
+
+// Size returns the size for the screen.
+func (s *Script) Size() (int, int) {
+	return 80, 24
+}
+
+// Redraw notifies the state to the running command.
+func (s *Script) Redraw(st state.State) error {
+	select {
+	case s.redrawCh <- st:
+	default:
+	}
+	return nil
+}
+
+// Close terminates the Script, and returns the error of the script.
+func (s *Script) Close() error {
+	close(s.doneCh)
+	<-s.waitCh
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
@@ -0,0 +1,77 @@
+package script
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/itchyny/bed/cmdline"
+	"github.com/itchyny/bed/editor"
+	"github.com/itchyny/bed/window"
+)
+
+func runScript(t *testing.T, src, contents string) (string, error) {
+	f, err := ioutil.TempFile("", "bed-test-script")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.WriteString(contents); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	cmdline := cmdline.NewCmdline()
+	editor := editor.NewEditor(NewScript(strings.NewReader(src), "test.bed", cmdline.Parse), window.NewManager(), cmdline)
+	if err := editor.Init(); err != nil {
+		t.Fatal(err)
+	}
+	if err := editor.Open(f.Name()); err != nil {
+		t.Fatal(err)
+	}
+	if err := editor.Run(); err != nil {
+		t.Fatal(err)
+	}
+	err = editor.Close()
+	bs, rerr := ioutil.ReadFile(f.Name())
+	if rerr != nil {
+		t.Fatal(rerr)
+	}
+	return string(bs), err
+}
+
+func TestScript(t *testing.T) {
+	got, err := runScript(t, `" patch the bytes
+goto 2
+insertbytes 2 0x41
+
+/world
+insertbytes 1 0x2d
+`, "Hello, world!")
+	if err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	if expected := "HeAAllo, -world!"; got != expected {
+		t.Errorf("file contents should be %q but got %q", expected, got)
+	}
+}
+
+func TestScriptError(t *testing.T) {
+	got, err := runScript(t, "insertbytes 2 0x41\nfoo\nw\n", "Hello, world!")
+	if expected := "test.bed:2: unknown command: foo"; err == nil || err.Error() != expected {
+		t.Errorf("err should be %q but got: %v", expected, err)
+	}
+	if expected := "Hello, world!"; got != expected {
+		t.Errorf("file contents should be %q but got %q", expected, got)
+	}
+
+	got, err = runScript(t, "insertbytes 0\n", "Hello, world!")
+	if expected := "test.bed:1: invalid count for ins[ertbytes]: 0"; err == nil || err.Error() != expected {
+		t.Errorf("err should be %q but got: %v", expected, err)
+	}
+	if expected := "Hello, world!"; got != expected {
+		t.Errorf("file contents should be %q but got %q", expected, got)
+	}
+}
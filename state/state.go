@@ -1,6 +1,9 @@
 package state
 
 import (
+	"strings"
+
+	"github.com/itchyny/bed/colorscheme"
 	"github.com/itchyny/bed/layout"
 	"github.com/itchyny/bed/mode"
 )
@@ -16,25 +19,139 @@ type State struct {
 	CompletionResults []string
 	CompletionIndex   int
 	SearchMode        rune
+	StatusLine        string
+	ColorScheme       colorscheme.Scheme
 	Error             error
 	ErrorType         int
+	Title             string
+	Bell              int
+	DowngradeColors   bool
 }
 
 // WindowState holds the state of one window.
 type WindowState struct {
-	Name          string
-	Width         int
-	Offset        int64
-	Cursor        int64
-	Bytes         []byte
-	Size          int
-	Length        int64
-	Mode          mode.Mode
-	Pending       bool
-	PendingByte   byte
-	VisualStart   int64
-	EditedIndices []int64
-	FocusText     bool
+	Name           string
+	Width          int
+	Offset         int64
+	Cursor         int64
+	Bytes          []byte
+	Size           int
+	Length         int64
+	LengthUnknown  bool
+	Modified       bool
+	Readonly       bool
+	Mode           mode.Mode
+	Pending        bool
+	PendingByte    byte
+	Literal        string
+	Nibble         bool
+	LowNibble      bool
+	VisualStart    int64
+	VisualBlock    bool
+	Selection      *Selection
+	EditedIndices  []int64
+	UnsavedIndices []int64
+	EditedNibbles  []int64
+	UnsavedNibbles []int64
+	FocusText      bool
+	Ruler          bool
+	RelativeOffset bool
+	BaseAddress    int64
+	RecordSize     int64
+	RowSum         string
+	Encoding       string
+	Annotations    []Annotation
+	Highlights     []Highlight
+	Skips          []Skip
+	Labels         []Label
+	Disassembly    []string
+	Records        [][]Field
+}
+
+// Annotation is a note attached to a byte range.
+type Annotation struct {
+	From  int64  `json:"from"`
+	To    int64  `json:"to"`
+	Note  string `json:"note"`
+	Color string `json:"color"`
+}
+
+// Selection is the summary of the visual selection; the offsets of the first
+// and the last bytes, and the checksums of the bytes when they are calculated.
+// The numbers of the rows and the columns are set for the block selection.
+type Selection struct {
+	From        int64
+	To          int64
+	Rows        int64
+	Columns     int64
+	Sum         uint32
+	CRC32       uint32
+	Checksummed bool
+}
+
+// Label is the name of the section starting at the offset, which is drawn
+// at the right of the row.
+type Label struct {
+	Offset int64
+	Text   string
+}
+
+// Skip is the identical rows collapsed into the marker row of the sparse view;
+// the marker is displayed at the row, and the bytes of the length from the
+// offset are not in the bytes of the window state.
+type Skip struct {
+	Row    int
+	Offset int64
+	Length int64
+}
+
+// Field is a decoded field of the record displayed in a row of the table view;
+// the text, and the offset and the size of the bytes in the record.
+type Field struct {
+	Text   string
+	Offset int
+	Size   int
+}
+
+// Highlight is a byte range matched by a highlight rule.
+type Highlight struct {
+	From  int64
+	To    int64
+	Color string
+}
+
+// Image is the image previewed in the message lines. The image implements the
+// error interface to be emitted as the info message, and the text is drawn
+// with the shading characters when the colors are not available. The colors
+// are 0xRRGGBB, and each line of the text has the two rows of the pixels.
+type Image struct {
+	Title  string
+	Width  int
+	Height int
+	Pixels []uint32
+}
+
+// Error returns the title and the shaded lines of the image.
+func (img *Image) Error() string {
+	var sb strings.Builder
+	sb.WriteString(img.Title)
+	for y := 0; y < img.Height; y += 2 {
+		sb.WriteByte('\n')
+		for x := 0; x < img.Width; x++ {
+			l := img.Luminance(x, y)
+			if y+1 < img.Height {
+				l = (l + img.Luminance(x, y+1)) / 2
+			}
+			sb.WriteRune([]rune(" ░▒▓█")[l*5/256])
+		}
+	}
+	return sb.String()
+}
+
+// Luminance returns the luminance of the pixel from 0 to 255.
+func (img *Image) Luminance(x, y int) int {
+	c := img.Pixels[y*img.Width+x]
+	return int(299*(c>>16&0xff)+587*(c>>8&0xff)+114*(c&0xff)) / 1000
 }
 
 // Message types
@@ -42,3 +159,10 @@ const (
 	MessageInfo = iota
 	MessageError
 )
+
+// Bell types
+const (
+	BellNone = iota
+	BellAudible
+	BellVisual
+)
@@ -0,0 +1,81 @@
+package tui
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/gdamore/tcell"
+)
+
+// getColor returns the color of the name; the W3C color names, the hex values
+// (#ff8000 or #f80), the decimal values (rgb(255, 128, 0)) and the indices of
+// the 256-color palette (208). It returns the default color for the unknown
+// names.
+func getColor(name string) tcell.Color {
+	if color := tcell.GetColor(name); color != tcell.ColorDefault {
+		return color
+	}
+	name = strings.TrimSpace(name)
+	if len(name) == 4 && name[0] == '#' {
+		if v, err := strconv.ParseInt(name[1:], 16, 32); err == nil {
+			return tcell.NewHexColor(int32(v&0xf00*0x1100 | v&0xf0*0x110 | v&0xf*0x11))
+		}
+	} else if strings.HasPrefix(name, "rgb(") && strings.HasSuffix(name, ")") {
+		xs := strings.Split(name[4:len(name)-1], ",")
+		if len(xs) == 3 {
+			var rgb [3]int32
+			for i, x := range xs {
+				v, err := strconv.ParseUint(strings.TrimSpace(x), 10, 8)
+				if err != nil {
+					return tcell.ColorDefault
+				}
+				rgb[i] = int32(v)
+			}
+			return tcell.NewRGBColor(rgb[0], rgb[1], rgb[2])
+		}
+	} else if v, err := strconv.ParseUint(name, 10, 8); err == nil {
+		return tcell.Color(v)
+	}
+	return tcell.ColorDefault
+}
+
+// palette256 is the 256-color palette for the colors downgraded from 24-bit.
+var palette256 = func() []tcell.Color {
+	palette := make([]tcell.Color, 256)
+	for i := range palette {
+		palette[i] = tcell.Color(i)
+	}
+	return palette
+}()
+
+// downgradeColors replaces the 24-bit colors on the screen with the nearest
+// colors of the 256-color palette. The colors are downgraded to the palette
+// of the terminal by tcell when the terminal does not support 24-bit color,
+// and this is for the terminals which do not render them correctly.
+func (ui *Tui) downgradeColors() {
+	if ui.colors == nil {
+		ui.colors = make(map[tcell.Color]tcell.Color)
+	}
+	downgrade := func(color tcell.Color) tcell.Color {
+		if color == tcell.ColorDefault || color&tcell.ColorIsRGB == 0 {
+			return color
+		}
+		c, ok := ui.colors[color]
+		if !ok {
+			c = tcell.FindColor(color, palette256)
+			ui.colors[color] = c
+		}
+		return c
+	}
+	width, height := ui.screen.Size()
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			mainc, combc, style, _ := ui.screen.GetContent(x, y)
+			fg, bg, _ := style.Decompose()
+			if fg&tcell.ColorIsRGB != 0 || bg&tcell.ColorIsRGB != 0 {
+				ui.screen.SetContent(x, y, mainc, combc,
+					style.Foreground(downgrade(fg)).Background(downgrade(bg)))
+			}
+		}
+	}
+}
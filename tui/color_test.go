@@ -0,0 +1,57 @@
+package tui
+
+import (
+	"testing"
+
+	"github.com/gdamore/tcell"
+
+	"github.com/itchyny/bed/event"
+)
+
+func TestGetColor(t *testing.T) {
+	testCases := []struct {
+		name     string
+		expected tcell.Color
+	}{
+		{"red", tcell.ColorRed},
+		{"#ff8000", tcell.NewHexColor(0xff8000)},
+		{"#f80", tcell.NewHexColor(0xff8800)},
+		{"rgb(255, 128, 0)", tcell.NewRGBColor(255, 128, 0)},
+		{"208", tcell.Color(208)},
+		{"256", tcell.ColorDefault},
+		{"rgb(256, 0, 0)", tcell.ColorDefault},
+		{"#ff80", tcell.ColorDefault},
+		{"unknown", tcell.ColorDefault},
+	}
+	for _, tc := range testCases {
+		if got := getColor(tc.name); got != tc.expected {
+			t.Errorf("getColor(%q) should be %v but got %v", tc.name, tc.expected, got)
+		}
+	}
+}
+
+func TestTuiDowngradeColors(t *testing.T) {
+	ui := NewTui()
+	screen := tcell.NewSimulationScreen("")
+	if err := ui.initForTest(make(chan event.Event), screen); err != nil {
+		t.Fatal(err)
+	}
+	screen.SetSize(4, 1)
+	screen.SetContent(0, 0, 'a', nil, tcell.StyleDefault.Foreground(tcell.NewHexColor(0xff0000)).Bold(true))
+	screen.SetContent(1, 0, 'b', nil, tcell.StyleDefault.Background(tcell.NewHexColor(0x5f87af)))
+	screen.SetContent(2, 0, 'c', nil, tcell.StyleDefault.Foreground(tcell.ColorGreen))
+	ui.downgradeColors()
+	for i, expected := range []struct {
+		fg, bg tcell.Color
+		attrs  tcell.AttrMask
+	}{
+		{tcell.Color(9), tcell.ColorDefault, tcell.AttrBold},
+		{tcell.ColorDefault, tcell.Color(67), 0},
+		{tcell.ColorGreen, tcell.ColorDefault, 0},
+	} {
+		_, _, style, _ := screen.GetContent(i, 0)
+		if fg, bg, attrs := style.Decompose(); fg != expected.fg || bg != expected.bg || attrs != expected.attrs {
+			t.Errorf("color should be downgraded to %v/%v but got %v/%v", expected.fg, expected.bg, fg, bg)
+		}
+	}
+}
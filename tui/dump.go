@@ -0,0 +1,132 @@
+package tui
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/gdamore/tcell"
+
+	"github.com/itchyny/bed/mathutil"
+	"github.com/itchyny/bed/state"
+)
+
+// dumpRows is the number of the rows drawn at once.
+const dumpRows = 256
+
+// MaxDumpWidth is the maximum number of the bytes in a row of the dump.
+const MaxDumpWidth = 256
+
+// Dump writes the bytes of the reader from the offset of the length (to the
+// end for the negative length) in the format; the hex view (hex) drawn by the
+// same code as the windows on the screen, or the array literals of C (c) and
+// Go (go).
+func Dump(w io.Writer, r io.ReaderAt, size, offset, length int64, width int, format string) error {
+	if width <= 0 || width > MaxDumpWidth {
+		return fmt.Errorf("invalid width: %d", width)
+	}
+	if offset < 0 || offset > size {
+		return fmt.Errorf("invalid offset: %d", offset)
+	}
+	if length < 0 || offset+length > size {
+		length = size - offset
+	}
+	bw := bufio.NewWriter(w)
+	var err error
+	switch format {
+	case "hex":
+		err = dumpHex(bw, r, size, offset, length, width)
+	case "c", "go":
+		err = dumpArray(bw, r, offset, length, width, format)
+	default:
+		return fmt.Errorf("unknown format: %s", format)
+	}
+	if err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// dumpHex draws the rows on the simulation screen, and writes the lines of
+// the screen.
+func dumpHex(w io.Writer, r io.ReaderAt, size, offset, length int64, width int) error {
+	screen := tcell.NewSimulationScreen("")
+	if err := screen.Init(); err != nil {
+		return err
+	}
+	defer screen.Fini()
+	bs := make([]byte, dumpRows*width)
+	for base := offset; base < offset+length; base += int64(len(bs)) {
+		n, err := r.ReadAt(bs[:mathutil.MinInt64(int64(len(bs)), offset+length-base)], base)
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if n == 0 {
+			return io.ErrUnexpectedEOF
+		}
+		for i := n; i < len(bs); i++ {
+			bs[i] = 0
+		}
+		s := &state.WindowState{
+			Width:       width,
+			Offset:      base,
+			Cursor:      base,
+			Bytes:       bs,
+			Size:        n,
+			Length:      size,
+			VisualStart: -1,
+		}
+		ui := &tuiWindow{screen: screen}
+		right := ui.offsetStyleWidth(s) + 4*width + 6
+		rows := (n + width - 1) / width
+		screen.SetSize(right+2, rows+1)
+		screen.Clear()
+		ui.region = region{height: rows + 1, width: right + 2}
+		ui.drawWindow(s, false)
+		screen.Show()
+		cells, _, _ := screen.GetContents()
+		for i := 0; i < rows; i++ {
+			var sb strings.Builder
+			for _, cell := range cells[i*(right+2) : i*(right+2)+right] {
+				for _, r := range cell.Runes {
+					sb.WriteRune(r)
+				}
+			}
+			if _, err := fmt.Fprintln(w, strings.TrimRight(sb.String(), " ")); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// dumpArray writes the bytes as the array literal of C or Go.
+func dumpArray(w io.Writer, r io.ReaderAt, offset, length int64, width int, format string) error {
+	header, indent, footer := "unsigned char data[] = {", "  ", "};"
+	if format == "go" {
+		header, indent, footer = "var data = []byte{", "\t", "}"
+	}
+	if _, err := fmt.Fprintln(w, header); err != nil {
+		return err
+	}
+	bs := make([]byte, width)
+	for base := offset; base < offset+length; base += int64(width) {
+		n, err := r.ReadAt(bs[:mathutil.MinInt64(int64(width), offset+length-base)], base)
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if n == 0 {
+			return io.ErrUnexpectedEOF
+		}
+		xs := make([]string, n)
+		for i, b := range bs[:n] {
+			xs[i] = fmt.Sprintf("0x%02x,", b)
+		}
+		if _, err := fmt.Fprintln(w, indent+strings.Join(xs, " ")); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintln(w, footer)
+	return err
+}
@@ -0,0 +1,52 @@
+package tui
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestDump(t *testing.T) {
+	src := []byte("Hello, world!\x00\x01\x02 and more")
+	testCases := []struct {
+		offset, length int64
+		width          int
+		format         string
+		expected       string
+	}{
+		{0, -1, 16, "hex", ` 000000 | 48 65 6c 6c 6f 2c 20 77 6f 72 6c 64 21 00 01 02 | Hello, world!...
+ 000010 | 20 61 6e 64 20 6d 6f 72 65                      |  and more
+`},
+		{3, 10, 8, "hex", ` 000003 | 6c 6f 2c 20 77 6f 72 6c | lo, worl
+ 00000b | 64 21                   | d!
+`},
+		{12, 5, 4, "c", `unsigned char data[] = {
+  0x21, 0x00, 0x01, 0x02,
+  0x20,
+};
+`},
+		{0, 3, 8, "go", `var data = []byte{
+	0x48, 0x65, 0x6c,
+}
+`},
+	}
+	for _, tc := range testCases {
+		var b bytes.Buffer
+		err := Dump(&b, bytes.NewReader(src), int64(len(src)), tc.offset, tc.length, tc.width, tc.format)
+		if err != nil {
+			t.Fatalf("err should be nil but got: %v", err)
+		}
+		if got := b.String(); got != tc.expected {
+			t.Errorf("Dump(%d, %d, %d, %s) should write\n%s\nbut got\n%s",
+				tc.offset, tc.length, tc.width, tc.format, tc.expected, got)
+		}
+	}
+	if err := Dump(new(bytes.Buffer), strings.NewReader(""), 0, 0, -1, 16, "xxd"); err == nil ||
+		err.Error() != "unknown format: xxd" {
+		t.Errorf("err should be %q but got: %v", "unknown format: xxd", err)
+	}
+	if err := Dump(new(bytes.Buffer), strings.NewReader(""), 0, 0, -1, MaxDumpWidth+1, "hex"); err == nil ||
+		err.Error() != "invalid width: 257" {
+		t.Errorf("err should be %q but got: %v", "invalid width: 257", err)
+	}
+}
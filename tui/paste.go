@@ -0,0 +1,100 @@
+package tui
+
+import (
+	"strings"
+
+	"github.com/gdamore/tcell"
+)
+
+// The sequences enabling and disabling the bracketed paste of the terminal.
+const (
+	enableBracketedPaste  = "\x1b[?2004h"
+	disableBracketedPaste = "\x1b[?2004l"
+)
+
+// The markers of the bracketed paste. The escape character is delivered as
+// the alt modifier of the following '[' by the screen.
+const (
+	pasteStart = "[200~"
+	pasteEnd   = "[201~"
+)
+
+// bracketedPaste collects the keys between the markers of the bracketed paste.
+// The keys partially matching the marker are held until they turn out not to
+// be the marker.
+type bracketedPaste struct {
+	pending []*tcell.EventKey
+	pasting bool
+	text    strings.Builder
+}
+
+// feed takes the key event, and returns the keys to be handled as usual and
+// the pasted text on the end marker.
+func (p *bracketedPaste) feed(ev *tcell.EventKey) ([]*tcell.EventKey, string, bool) {
+	marker := pasteStart
+	if p.pasting {
+		marker = pasteEnd
+	}
+	if i := len(p.pending); matchMarker(ev, marker[i], i == 0) {
+		if p.pending = append(p.pending, ev); len(p.pending) < len(marker) {
+			return nil, "", false
+		}
+		p.pending = nil
+		if p.pasting = !p.pasting; p.pasting {
+			return nil, "", false
+		}
+		text := p.text.String()
+		p.text.Reset()
+		return nil, text, true
+	} else if i > 0 {
+		evs := p.pending
+		p.pending = nil
+		if p.pasting {
+			for _, ev := range evs {
+				p.write(ev)
+			}
+			evs = nil
+		}
+		es, text, ok := p.feed(ev)
+		return append(evs, es...), text, ok
+	}
+	if p.pasting {
+		p.write(ev)
+		return nil, "", false
+	}
+	return []*tcell.EventKey{ev}, "", false
+}
+
+func matchMarker(ev *tcell.EventKey, b byte, first bool) bool {
+	if ev.Key() != tcell.KeyRune || ev.Rune() != rune(b) {
+		return false
+	}
+	if first {
+		return ev.Modifiers()&tcell.ModAlt != 0
+	}
+	return ev.Modifiers() == tcell.ModNone
+}
+
+// write appends the text of the key to the pasted text.
+func (p *bracketedPaste) write(ev *tcell.EventKey) {
+	switch ev.Key() {
+	case tcell.KeyRune:
+		if ev.Modifiers()&tcell.ModAlt != 0 {
+			p.text.WriteByte('\x1b')
+		}
+		p.text.WriteRune(ev.Rune())
+	default:
+		if ev.Key() < tcell.KeyRune {
+			p.text.WriteByte(byte(ev.Key()))
+		}
+	}
+}
+
+// pastedKeys returns the keys typing the pasted text.
+func pastedKeys(text string) []*tcell.EventKey {
+	evs := make([]*tcell.EventKey, 0, len(text))
+	for _, ch := range text {
+		evs = append(evs, tcell.NewEventKey(tcell.KeyRune, ch, tcell.ModNone))
+	}
+	return evs
+}
@@ -0,0 +1,86 @@
+package tui
+
+import (
+	"testing"
+
+	"github.com/gdamore/tcell"
+)
+
+func pasteKeys(str string) []*tcell.EventKey {
+	var evs []*tcell.EventKey
+	for i, ch := range str {
+		if ch == '\x1b' {
+			continue
+		}
+		mod := tcell.ModNone
+		if i > 0 && str[i-1] == '\x1b' {
+			mod = tcell.ModAlt
+		}
+		evs = append(evs, tcell.NewEventKey(tcell.KeyRune, ch, mod))
+	}
+	return evs
+}
+
+func TestBracketedPaste(t *testing.T) {
+	testCases := []struct {
+		name  string
+		input string
+		keys  string
+		texts []string
+	}{
+		{
+			name:  "no paste",
+			input: "jj",
+			keys:  "jj",
+		},
+		{
+			name:  "paste",
+			input: "j\x1b[200~de ad\rbe ef\x1b[201~j",
+			keys:  "jj",
+			texts: []string{"de ad\rbe ef"},
+		},
+		{
+			name:  "paste twice",
+			input: "\x1b[200~0xde\x1b[201~\x1b[200~0xad\x1b[201~",
+			texts: []string{"0xde", "0xad"},
+		},
+		{
+			name:  "partial markers",
+			input: "\x1b[20j\x1b[200~\x1b[20x\x1b[201~",
+			keys:  "[20j",
+			texts: []string{"\x1b[20x"},
+		},
+		{
+			name:  "empty paste",
+			input: "\x1b[200~\x1b[201~",
+			texts: []string{""},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var p bracketedPaste
+			var keys []rune
+			var texts []string
+			for _, ev := range pasteKeys(tc.input) {
+				evs, text, ok := p.feed(ev)
+				for _, ev := range evs {
+					keys = append(keys, ev.Rune())
+				}
+				if ok {
+					texts = append(texts, text)
+				}
+			}
+			if got := string(keys); got != tc.keys {
+				t.Errorf("keys should be %q but got %q", tc.keys, got)
+			}
+			if len(texts) != len(tc.texts) {
+				t.Fatalf("texts should be %q but got %q", tc.texts, texts)
+			}
+			for i, text := range texts {
+				if text != tc.texts[i] {
+					t.Errorf("texts should be %q but got %q", tc.texts, texts)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,134 @@
+package tui
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/itchyny/bed/mathutil"
+	"github.com/itchyny/bed/state"
+)
+
+// defaultStatusLine is used when the statusline option is empty.
+const defaultStatusLine = " %M%f%r : %x%n : '%c'%a%k%v%=%o/%l : %O/%L : %p "
+
+// formatStatusLine expands the status line format and returns the left and
+// right aligned parts, which are separated by %=. Available items are
+//
+//	%f  file name             %m  modified flag
+//	%o  cursor offset         %O  cursor offset in hex
+//	%l  file length           %L  file length in hex
+//	%p  percentage            %M  mode
+//	%d  byte value            %x  byte value in hex
+//	%b  byte value in binary  %c  byte character
+//	%s  selection size        %a  annotation at the cursor
+//	%k  pending count and keys %r  read-only flag
+//	%n  focused nibble        %v  visual selection summary
+//	%%  literal percent sign
+func formatStatusLine(format string, s *state.WindowState, offsetStyleWidth int, pending string) (string, string) {
+	var left, right strings.Builder
+	sb := &left
+	offsetStyle := "0x%0" + strconv.Itoa(offsetStyleWidth) + "x"
+	b := s.Bytes[cursorIndex(s)]
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' || i == len(format)-1 {
+			sb.WriteByte(format[i])
+			continue
+		}
+		i++
+		switch format[i] {
+		case 'f':
+			if s.Name == "" {
+				sb.WriteString("[No name]")
+			} else {
+				sb.WriteString(s.Name)
+			}
+		case 'm':
+			if s.Modified {
+				sb.WriteString("[+]")
+			}
+		case 'r':
+			if s.Readonly {
+				sb.WriteString(" [RO]")
+			}
+		case 'o':
+			sb.WriteString(strconv.FormatInt(s.BaseAddress+s.Cursor, 10))
+		case 'O':
+			fmt.Fprintf(sb, offsetStyle, s.BaseAddress+s.Cursor)
+		case 'l':
+			if s.LengthUnknown {
+				sb.WriteString("?")
+			} else {
+				sb.WriteString(strconv.FormatInt(s.Length, 10))
+			}
+		case 'L':
+			if s.LengthUnknown {
+				sb.WriteString("?")
+			} else {
+				fmt.Fprintf(sb, offsetStyle, s.Length)
+			}
+		case 'p':
+			if s.LengthUnknown {
+				sb.WriteString("?%")
+			} else {
+				fmt.Fprintf(sb, "%.2f%%", float64(s.Cursor*100)/float64(mathutil.MaxInt64(s.Length, 1)))
+			}
+		case 'M':
+			sb.WriteString(prettyMode(s.Mode))
+		case 'd':
+			sb.WriteString(strconv.Itoa(int(b)))
+		case 'x':
+			fmt.Fprintf(sb, "0x%02x", b)
+		case 'b':
+			fmt.Fprintf(sb, "%08b", b)
+		case 'c':
+			sb.WriteString(prettyRune(b))
+		case 's':
+			if v := s.Selection; v != nil && v.Rows > 0 {
+				sb.WriteString(strconv.FormatInt(v.Rows*v.Columns, 10))
+			} else if s.VisualStart >= 0 {
+				size := s.Cursor - s.VisualStart
+				if size < 0 {
+					size = -size
+				}
+				sb.WriteString(strconv.FormatInt(size+1, 10))
+			}
+		case 'v':
+			if v := s.Selection; v != nil && v.Rows > 0 {
+				fmt.Fprintf(sb, " : "+offsetStyle+"-"+offsetStyle+" %dx%d block",
+					s.BaseAddress+v.From, s.BaseAddress+v.To, v.Rows, v.Columns)
+				if v.Checksummed {
+					fmt.Fprintf(sb, " : sum 0x%x crc32 0x%08x", v.Sum, v.CRC32)
+				}
+			} else if v != nil {
+				fmt.Fprintf(sb, " : "+offsetStyle+"-"+offsetStyle+" %d (0x%x) bytes",
+					s.BaseAddress+v.From, s.BaseAddress+v.To, v.To-v.From+1, v.To-v.From+1)
+				if v.Checksummed {
+					fmt.Fprintf(sb, " : sum 0x%x crc32 0x%08x", v.Sum, v.CRC32)
+				}
+			}
+		case 'n':
+			if s.LowNibble {
+				sb.WriteString(" : low nibble")
+			} else if s.Nibble {
+				sb.WriteString(" : high nibble")
+			}
+		case 'a':
+			if a := annotationAt(s.Annotations, s.Cursor); a != nil {
+				sb.WriteString(" : " + a.Note)
+			}
+		case 'k':
+			if pending != "" {
+				sb.WriteString(" : " + pending)
+			}
+		case '=':
+			sb = &right
+		case '%':
+			sb.WriteByte('%')
+		default:
+			sb.WriteByte('%')
+			sb.WriteByte(format[i])
+		}
+	}
+	return left.String(), right.String()
+}
@@ -0,0 +1,122 @@
+package tui
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/itchyny/bed/mode"
+	"github.com/itchyny/bed/state"
+)
+
+func TestFormatStatusLine(t *testing.T) {
+	s := &state.WindowState{
+		Name:        "test.bin",
+		Width:       16,
+		Offset:      0,
+		Cursor:      2,
+		Bytes:       []byte("\x00\x10A\xff"),
+		Size:        4,
+		Length:      4,
+		Modified:    true,
+		Mode:        mode.Visual,
+		VisualStart: 0,
+		Selection:   &state.Selection{From: 0, To: 2, Sum: 0x51, CRC32: 0x2f0a3c1b, Checksummed: true},
+		Annotations: []state.Annotation{{From: 1, To: 2, Note: "magic"}},
+	}
+	testCases := []struct {
+		format, left, right string
+	}{
+		{defaultStatusLine, " [VISUAL] test.bin : 0x41 : 'A' : magic : 0x1f : 0x000000-0x000002 3 (0x3) bytes : sum 0x51 crc32 0x2f0a3c1b", "2/4 : 0x000002/0x000004 : 50.00% "},
+		{"%f%m %M", "test.bin[+] [VISUAL] ", ""},
+		{"%d %x %b %c%=%s", "65 0x41 01000001 A", "3"},
+		{"100%% %q %", "100% %q %", ""},
+		{"%x%n", "0x41", ""},
+		{"%v", " : 0x000000-0x000002 3 (0x3) bytes : sum 0x51 crc32 0x2f0a3c1b", ""},
+	}
+	for _, testCase := range testCases {
+		left, right := formatStatusLine(testCase.format, s, 6, "0x1f")
+		if left != testCase.left {
+			t.Errorf("left of %q should be %q but got %q", testCase.format, testCase.left, left)
+		}
+		if right != testCase.right {
+			t.Errorf("right of %q should be %q but got %q", testCase.format, testCase.right, right)
+		}
+	}
+}
+
+func TestFormatStatusLineVisualBlock(t *testing.T) {
+	s := &state.WindowState{
+		Width:       16,
+		Cursor:      0x35,
+		Bytes:       make([]byte, 64),
+		Size:        64,
+		Length:      64,
+		VisualStart: 0x12,
+		VisualBlock: true,
+		Selection:   &state.Selection{From: 0x12, To: 0x35, Rows: 3, Columns: 4, Sum: 0x1aa, Checksummed: true},
+	}
+	left, right := formatStatusLine("%v%=%s", s, 6, "")
+	if expected := " : 0x000012-0x000035 3x4 block : sum 0x1aa crc32 0x00000000"; left != expected {
+		t.Errorf("left should be %q but got %q", expected, left)
+	}
+	if expected := "12"; right != expected {
+		t.Errorf("right should be %q but got %q", expected, right)
+	}
+}
+
+func TestFormatStatusLineNibble(t *testing.T) {
+	s := &state.WindowState{
+		Width:  16,
+		Cursor: 1,
+		Bytes:  []byte("\x00A"),
+		Size:   2,
+		Length: 2,
+		Nibble: true,
+	}
+	if left, _ := formatStatusLine("%x%n", s, 6, ""); left != "0x41 : high nibble" {
+		t.Errorf("left should be %q but got %q", "0x41 : high nibble", left)
+	}
+	s.LowNibble = true
+	if left, _ := formatStatusLine("%x%n", s, 6, ""); left != "0x41 : low nibble" {
+		t.Errorf("left should be %q but got %q", "0x41 : low nibble", left)
+	}
+}
+
+func TestFormatStatusLineLengthUnknown(t *testing.T) {
+	s := &state.WindowState{
+		Name:          "[stdin]",
+		Width:         16,
+		Cursor:        1,
+		Bytes:         []byte("\x00A"),
+		Size:          2,
+		Length:        2,
+		LengthUnknown: true,
+	}
+	left, right := formatStatusLine("%f%=%o/%l : %O/%L : %p", s, 6, "")
+	if expected := "[stdin]"; left != expected {
+		t.Errorf("left should be %q but got %q", expected, left)
+	}
+	if expected := "1/? : 0x000001/? : ?%"; right != expected {
+		t.Errorf("right should be %q but got %q", expected, right)
+	}
+}
+
+func TestFormatStatusLineBaseAddress(t *testing.T) {
+	s := &state.WindowState{
+		Width:       16,
+		Cursor:      0x12,
+		Bytes:       []byte(strings.Repeat("\x00", 32)),
+		Size:        32,
+		Length:      32,
+		VisualStart: 0x10,
+		Selection:   &state.Selection{From: 0x10, To: 0x12},
+		BaseAddress: 0x8000000,
+	}
+	left, right := formatStatusLine("%v%=%o : %O/%L", s, 8, "")
+	if expected := " : 0x08000010-0x08000012 3 (0x3) bytes"; left != expected {
+		t.Errorf("left should be %q but got %q", expected, left)
+	}
+	if expected := "134217746 : 0x08000012/0x00000020"; right != expected {
+		t.Errorf("right should be %q but got %q", expected, right)
+	}
+}
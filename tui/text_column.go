@@ -0,0 +1,98 @@
+package tui
+
+import (
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/gdamore/tcell"
+	"github.com/mattn/go-runewidth"
+	"golang.org/x/text/encoding/japanese"
+)
+
+// textCell is the character drawn in the text column at the byte. The wide
+// character covers the cell of the next byte, and the other bytes of the
+// multi-byte character are drawn as the spaces.
+type textCell struct {
+	r     rune
+	comb  []rune
+	size  int
+	width int
+}
+
+// decodeText decodes the bytes of the row in the encoding, and returns the
+// cells at the first bytes of the characters. The characters are decoded from
+// the head of the row, so the character across the rows is not decoded.
+func decodeText(bs []byte, encoding string) []*textCell {
+	cells := make([]*textCell, len(bs))
+	var last *textCell
+	for i := 0; i < len(bs); {
+		r, size := decodeRune(bs[i:], encoding)
+		if r == utf8.RuneError || unicode.IsControl(r) {
+			cells[i], last = &textCell{r: '.', size: 1, width: 1}, nil
+			i++
+			continue
+		}
+		width := runewidth.RuneWidth(r)
+		if width == 0 && last != nil && unicode.Is(unicode.Mn, r) {
+			last.comb = append(last.comb, r)
+			cells[i] = &textCell{r: ' ', size: size, width: 1}
+		} else if 0 < width && width <= size {
+			cells[i] = &textCell{r: r, size: size, width: width}
+			last = cells[i]
+		} else {
+			cells[i], last = &textCell{r: '.', size: 1, width: 1}, nil
+			size = 1
+		}
+		i += size
+	}
+	return cells
+}
+
+// decodeRune decodes the first character of the bytes in the encoding, and
+// returns the replacement character for the invalid or incomplete bytes.
+func decodeRune(bs []byte, encoding string) (rune, int) {
+	switch encoding {
+	case "utf-8":
+		return utf8.DecodeRune(bs)
+	case "shift_jis":
+		switch b := bs[0]; {
+		case b < 0x80:
+			return rune(b), 1
+		case 0xa1 <= b && b <= 0xdf:
+			return rune(b) - 0xa1 + '｡', 1
+		case len(bs) >= 2 && (0x81 <= b && b <= 0x9f || 0xe0 <= b && b <= 0xfc):
+			if xs, err := japanese.ShiftJIS.NewDecoder().Bytes(bs[:2]); err == nil {
+				if r, size := utf8.DecodeRune(xs); r != utf8.RuneError && size == len(xs) {
+					return r, 2
+				}
+			}
+		}
+		return utf8.RuneError, 1
+	default:
+		if b := bs[0]; b < 0x80 {
+			return rune(b), 1
+		}
+		return utf8.RuneError, 1
+	}
+}
+
+// drawText draws the decoded characters of the row in the text column. The
+// style of the character is of the byte at the cursor when the cursor is on
+// one of the bytes of the character.
+func drawText(d *textDrawer, cells []*textCell, styles []tcell.Style, cursor int) {
+	offset := d.offset
+	for j, cell := range cells {
+		if cell == nil {
+			continue
+		}
+		style := styles[j]
+		if j < cursor && cursor < j+cell.size {
+			style = styles[cursor]
+		}
+		d.setOffset(offset+j).setRune(cell.r, cell.comb, style)
+		for k := j + cell.width; k < j+cell.size; k++ {
+			d.setOffset(offset+k).setRune(' ', nil, styles[k])
+		}
+	}
+	d.setOffset(offset)
+}
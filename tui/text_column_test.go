@@ -0,0 +1,86 @@
+package tui
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gdamore/tcell"
+
+	"github.com/itchyny/bed/event"
+	"github.com/itchyny/bed/layout"
+	"github.com/itchyny/bed/mode"
+	"github.com/itchyny/bed/state"
+)
+
+func TestDecodeText(t *testing.T) {
+	testCases := []struct {
+		bytes    string
+		encoding string
+		expected string
+	}{
+		{"a\xe3\x81\x82b", "ascii", "a...b"},
+		{"a\xe3\x81\x82b", "utf-8", "aあ __b"},
+		{"e\xcc\x81\xe3\x81", "utf-8", "e\u0301  _.."},
+		{"\x81\x82\xe3\x81\x00", "utf-8", "....."},
+		{"a\x82\xa0\xb1\x88\x9f", "shift_jis", "aあ_ｱ亜_"},
+		{"\x82", "shift_jis", "."},
+		{"\x82\x7f", "shift_jis", ".."},
+	}
+	for _, tc := range testCases {
+		var sb strings.Builder
+		for _, cell := range decodeText([]byte(tc.bytes), tc.encoding) {
+			if cell == nil {
+				sb.WriteRune('_')
+				continue
+			}
+			sb.WriteRune(cell.r)
+			for _, r := range cell.comb {
+				sb.WriteRune(r)
+			}
+			sb.WriteString(strings.Repeat(" ", cell.size-cell.width))
+		}
+		if got := sb.String(); got != tc.expected {
+			t.Errorf("decodeText(%q, %q) should be %q but got %q", tc.bytes, tc.encoding, tc.expected, got)
+		}
+	}
+}
+
+func TestTuiEncoding(t *testing.T) {
+	ui := NewTui()
+	eventCh := make(chan event.Event)
+	screen := tcell.NewSimulationScreen("")
+	if err := ui.initForTest(eventCh, screen); err != nil {
+		t.Fatal(err)
+	}
+	screen.SetSize(90, 20)
+	width, height := screen.Size()
+	go ui.Run(mockKeyManager())
+
+	bs := []byte("Hello, \xe4\xb8\x96\xe7\x95\x8c!\xe3\x81\x82")
+	s := state.State{
+		WindowStates: map[int]*state.WindowState{
+			0: &state.WindowState{
+				Name:     "test",
+				Width:    16,
+				Cursor:   8,
+				Bytes:    append(bs, make([]byte, 16*(height-1)-len(bs))...),
+				Size:     len(bs),
+				Length:   int64(len(bs)),
+				Mode:     mode.Normal,
+				Encoding: "utf-8",
+			},
+		},
+		Layout: layout.NewLayout(0).Resize(0, 0, width, height-1),
+	}
+	if err := ui.Redraw(s); err != nil {
+		t.Errorf("ui.Redraw should return nil but got: %v", err)
+	}
+	shouldContain(t, screen, []string{
+		" 000000 | 48 65 6c 6c 6f 2c 20 e4 b8 96 e7 95 8c 21 e3 81 | Hello, 世 界 !.. #",
+		" 000010 | 82                                              | .                #",
+	})
+	mainc, _, style, width := screen.GetContent(len(" 000000 | ")+3*16+len("| Hello, "), 0)
+	if _, _, attrs := style.Decompose(); mainc != '世' || width != 2 || attrs&tcell.AttrBold == 0 {
+		t.Errorf("wide character should be drawn with the cursor but got %q, %d", mainc, width)
+	}
+}
@@ -21,7 +21,7 @@ func (d *textDrawer) setString(str string, style tcell.Style) {
 			break
 		}
 		if left+w == right && c != ' ' {
-			if int(style)&int(tcell.AttrReverse) != 0 {
+			if _, bg, attr := style.Decompose(); attr&tcell.AttrReverse != 0 || bg != tcell.ColorDefault {
 				d.screen.SetContent(left, top, ' ', nil, style)
 			}
 			break
@@ -31,6 +31,16 @@ func (d *textDrawer) setString(str string, style tcell.Style) {
 	}
 }
 
+// setRune sets the character with the combining characters, which is not
+// drawn when it exceeds the region.
+func (d *textDrawer) setRune(c rune, combc []rune, style tcell.Style) {
+	top := d.region.top + d.top
+	left := d.region.left + d.left + d.offset
+	if left+runewidth.RuneWidth(c) <= d.region.left+d.region.width {
+		d.screen.SetContent(left, top, c, combc, style)
+	}
+}
+
 func (d *textDrawer) setTop(top int) *textDrawer {
 	d.top = top
 	return d
@@ -1,29 +1,45 @@
 package tui
 
 import (
+	"fmt"
+	"io"
+	"os"
 	"strings"
+	"sync"
+	"time"
+	"unicode"
 
 	"github.com/gdamore/tcell"
 	"github.com/mattn/go-runewidth"
 
+	"github.com/itchyny/bed/colorscheme"
 	"github.com/itchyny/bed/event"
 	"github.com/itchyny/bed/key"
 	"github.com/itchyny/bed/layout"
+	"github.com/itchyny/bed/mathutil"
 	"github.com/itchyny/bed/mode"
 	"github.com/itchyny/bed/state"
 )
 
 // Tui implements UI
 type Tui struct {
-	eventCh chan<- event.Event
-	mode    mode.Mode
-	screen  tcell.Screen
-	waitCh  chan struct{}
+	eventCh    chan<- event.Event
+	mode       mode.Mode
+	statusLine string
+	scheme     colorscheme.Scheme
+	pending    string
+	paste      bracketedPaste
+	screen     tcell.Screen
+	waitCh     chan struct{}
+	title      string
+	titleOut   io.Writer
+	colors     map[tcell.Color]tcell.Color
+	mu         *sync.Mutex
 }
 
 // NewTui creates a new Tui.
 func NewTui() *Tui {
-	return &Tui{}
+	return &Tui{mu: new(sync.Mutex)}
 }
 
 // Init initializes the Tui.
@@ -34,7 +50,12 @@ func (ui *Tui) Init(eventCh chan<- event.Event) (err error) {
 		return
 	}
 	ui.waitCh = make(chan struct{})
-	return ui.screen.Init()
+	ui.title, ui.titleOut = "", os.Stdout
+	if err = ui.screen.Init(); err != nil {
+		return
+	}
+	fmt.Fprint(ui.titleOut, enableBracketedPaste)
+	return
 }
 
 func (ui *Tui) initForTest(eventCh chan<- event.Event, screen tcell.SimulationScreen) (err error) {
@@ -51,10 +72,16 @@ func (ui *Tui) Run(kms map[mode.Mode]*key.Manager) {
 		e := ui.screen.PollEvent()
 		switch ev := e.(type) {
 		case *tcell.EventKey:
-			if e := kms[ui.mode].Press(eventToKey(ev)); e.Type != event.Nop {
-				ui.eventCh <- e
-			} else {
-				ui.eventCh <- event.Event{Type: event.Rune, Rune: ev.Rune()}
+			evs, text, ok := ui.paste.feed(ev)
+			if ok {
+				if ui.mode == mode.Insert || ui.mode == mode.Replace {
+					ui.eventCh <- event.Event{Type: event.PasteText, Arg: text}
+				} else {
+					evs = pastedKeys(text)
+				}
+			}
+			for _, ev := range evs {
+				ui.press(kms, ev)
 			}
 		case *tcell.EventResize:
 			if ui.eventCh != nil {
@@ -67,6 +94,22 @@ func (ui *Tui) Run(kms map[mode.Mode]*key.Manager) {
 	}
 }
 
+func (ui *Tui) press(kms map[mode.Mode]*key.Manager, ev *tcell.EventKey) {
+	km := kms[ui.mode]
+	e := km.Press(eventToKey(ev))
+	ui.mu.Lock()
+	ui.pending = km.Pending()
+	ui.mu.Unlock()
+	if e.Type != event.Nop {
+		ui.eventCh <- e
+	} else {
+		ui.eventCh <- event.Event{Type: event.Rune, Rune: ev.Rune()}
+	}
+	for e, ok := km.Next(); ok; e, ok = km.Next() {
+		ui.eventCh <- e
+	}
+}
+
 // Size returns the size for the screen.
 func (ui *Tui) Size() (int, int) {
 	return ui.screen.Size()
@@ -81,14 +124,59 @@ func (ui *Tui) setLine(line int, offset int, str string, style tcell.Style) {
 
 // Redraw redraws the state.
 func (ui *Tui) Redraw(s state.State) error {
-	ui.mode = s.Mode
+	ui.mode, ui.statusLine, ui.scheme = s.Mode, s.StatusLine, s.ColorScheme
+	if ui.scheme == nil {
+		ui.scheme, _ = colorscheme.Builtin("default")
+	}
+	normal, _ := schemeStyle(ui.scheme, colorscheme.Normal)
+	ui.screen.SetStyle(normal)
 	ui.screen.Clear()
 	ui.drawWindows(s.WindowStates, s.Layout)
 	ui.drawCmdline(s)
+	if s.DowngradeColors {
+		ui.downgradeColors()
+	}
+	if s.Bell == state.BellVisual {
+		ui.flash()
+	}
 	ui.screen.Show()
+	if s.Bell == state.BellAudible {
+		_ = ui.screen.Beep()
+	}
+	if s.Title != ui.title && s.Title != "" && ui.titleOut != nil {
+		fmt.Fprintf(ui.titleOut, "\x1b]2;%s\x07", strings.Map(func(r rune) rune {
+			if unicode.IsControl(r) {
+				return -1
+			}
+			return r
+		}, s.Title))
+	}
+	ui.title = s.Title
 	return nil
 }
 
+// visualBellDuration is the duration of the reversed screen of the visual bell.
+const visualBellDuration = 50 * time.Millisecond
+
+// flash shows the reversed screen for a moment as the visual bell.
+func (ui *Tui) flash() {
+	ui.reverseScreen()
+	ui.screen.Show()
+	time.Sleep(visualBellDuration)
+	ui.reverseScreen()
+}
+
+func (ui *Tui) reverseScreen() {
+	width, height := ui.screen.Size()
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			mainc, combc, style, _ := ui.screen.GetContent(x, y)
+			_, _, attrs := style.Decompose()
+			ui.screen.SetContent(x, y, mainc, combc, style.Reverse(attrs&tcell.AttrReverse == 0))
+		}
+	}
+}
+
 func (ui *Tui) drawWindows(windowStates map[int]*state.WindowState, l layout.Layout) {
 	switch l := l.(type) {
 	case layout.Window:
@@ -107,37 +195,80 @@ func (ui *Tui) drawWindows(windowStates map[int]*state.WindowState, l layout.Lay
 }
 
 func (ui *Tui) newTuiWindow(region region) *tuiWindow {
-	return &tuiWindow{region: region, screen: ui.screen}
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
+	return &tuiWindow{
+		region: region, screen: ui.screen, statusLine: ui.statusLine,
+		scheme: ui.scheme, pending: ui.pending,
+	}
 }
 
 func (ui *Tui) drawVerticalSplit(region region) {
+	style, ok := schemeStyle(ui.scheme, colorscheme.Separator)
+	if !ok {
+		style = style.Reverse(true)
+	}
 	for i := 0; i < region.height; i++ {
-		ui.setLine(region.top+i, region.left+region.width, "|", tcell.StyleDefault.Reverse(true))
+		ui.setLine(region.top+i, region.left+region.width, "|", style)
 	}
 }
 
 func (ui *Tui) drawCmdline(s state.State) {
 	width, height := ui.Size()
 	if s.Error != nil {
-		style := tcell.StyleDefault.Foreground(tcell.ColorRed)
+		style, _ := schemeStyle(ui.scheme, colorscheme.Error)
 		if s.ErrorType == state.MessageInfo {
-			style = style.Foreground(tcell.ColorYellow)
+			style, _ = schemeStyle(ui.scheme, colorscheme.Info)
+		}
+		lines := strings.Split(s.Error.Error(), "\n")
+		for i, line := range lines {
+			if len(lines) > 1 {
+				line += strings.Repeat(" ", mathutil.MaxInt(width-runewidth.StringWidth(line), 0))
+			}
+			ui.setLine(height-len(lines)+i, 0, line, style)
+		}
+		if img, ok := s.Error.(*state.Image); ok {
+			ui.drawImage(img, height-len(lines)+1)
 		}
-		ui.setLine(height-1, 0, s.Error.Error(), style)
 	} else if s.Mode == mode.Cmdline || s.PrevMode == mode.Cmdline && len(s.Cmdline) > 0 {
-		ui.setLine(height-1, 0, ":"+string(s.Cmdline), tcell.StyleDefault)
+		style, _ := schemeStyle(ui.scheme, colorscheme.Cmdline)
+		ui.setLine(height-1, 0, ":"+string(s.Cmdline), style)
 		if s.Mode == mode.Cmdline {
 			ui.drawCompletionResults(s, width, height)
 			ui.screen.ShowCursor(1+runewidth.StringWidth(string(s.Cmdline[:s.CmdlineCursor])), height-1)
 		}
+	} else if s.Mode == mode.Expression {
+		style, _ := schemeStyle(ui.scheme, colorscheme.Cmdline)
+		ui.setLine(height-1, 0, "="+string(s.Cmdline), style)
+		ui.screen.ShowCursor(1+runewidth.StringWidth(string(s.Cmdline[:s.CmdlineCursor])), height-1)
+	} else if s.Mode == mode.Passphrase {
+		// the passphrase is masked with the same width regardless of the runes
+		style, _ := schemeStyle(ui.scheme, colorscheme.Cmdline)
+		ui.setLine(height-1, 0, "Passphrase: "+strings.Repeat("*", len(s.Cmdline)), style)
+		ui.screen.ShowCursor(12+s.CmdlineCursor, height-1)
 	} else if s.SearchMode != '\x00' {
-		ui.setLine(height-1, 0, string(s.SearchMode)+string(s.Cmdline), tcell.StyleDefault)
+		style, _ := schemeStyle(ui.scheme, colorscheme.Cmdline)
+		ui.setLine(height-1, 0, string(s.SearchMode)+string(s.Cmdline), style)
 		if s.Mode == mode.Search {
 			ui.screen.ShowCursor(1+runewidth.StringWidth(string(s.Cmdline[:s.CmdlineCursor])), height-1)
 		}
 	}
 }
 
+// drawImage draws the image from the line with the upper half block characters,
+// of which the foreground and the background are the colors of the two pixels.
+func (ui *Tui) drawImage(img *state.Image, line int) {
+	for y := 0; y < img.Height; y += 2 {
+		for x := 0; x < img.Width; x++ {
+			style := tcell.StyleDefault.Foreground(tcell.NewHexColor(int32(img.Pixels[y*img.Width+x])))
+			if y+1 < img.Height {
+				style = style.Background(tcell.NewHexColor(int32(img.Pixels[(y+1)*img.Width+x])))
+			}
+			ui.screen.SetContent(x, line+y/2, '▀', nil, style)
+		}
+	}
+}
+
 func (ui *Tui) drawCompletionResults(s state.State, width int, height int) {
 	if len(s.CompletionResults) > 0 {
 		var line string
@@ -153,10 +284,14 @@ func (ui *Tui) drawCompletionResults(s state.State, width int, height int) {
 			line += " " + result + " "
 			lineWidth += w + 2
 		}
-		ui.setLine(height-2, 0, line+strings.Repeat(" ", width), tcell.StyleDefault.Reverse(true))
+		style, ok := schemeStyle(ui.scheme, colorscheme.Completion)
+		selected := style.Reverse(true)
+		if !ok {
+			style, selected = style.Reverse(true), style.Foreground(tcell.ColorGrey).Reverse(true)
+		}
+		ui.setLine(height-2, 0, line+strings.Repeat(" ", width), style)
 		if s.CompletionIndex >= 0 {
-			ui.setLine(height-2, pos, " "+s.CompletionResults[s.CompletionIndex]+" ",
-				tcell.StyleDefault.Foreground(tcell.ColorGrey).Reverse(true))
+			ui.setLine(height-2, pos, " "+s.CompletionResults[s.CompletionIndex]+" ", selected)
 		}
 	}
 }
@@ -164,7 +299,34 @@ func (ui *Tui) drawCompletionResults(s state.State, width int, height int) {
 // Close terminates the Tui.
 func (ui *Tui) Close() error {
 	ui.eventCh = nil
+	if ui.titleOut != nil {
+		fmt.Fprint(ui.titleOut, disableBracketedPaste)
+	}
 	ui.screen.Fini()
 	<-ui.waitCh
 	return nil
 }
+
+// schemeStyle returns the style of the group on the normal style,
+// and reports whether the group is defined in the color scheme.
+func schemeStyle(scheme colorscheme.Scheme, group string) (tcell.Style, bool) {
+	style := tcell.StyleDefault
+	if color, ok := scheme[colorscheme.Normal]; ok {
+		style = applyColor(style, color)
+	}
+	color, ok := scheme[group]
+	if ok {
+		style = applyColor(style, color)
+	}
+	return style, ok
+}
+
+func applyColor(style tcell.Style, color colorscheme.Color) tcell.Style {
+	if color.Foreground != "" {
+		style = style.Foreground(getColor(color.Foreground))
+	}
+	if color.Background != "" {
+		style = style.Background(getColor(color.Background))
+	}
+	return style
+}
@@ -106,6 +106,7 @@ func TestTuiEmpty(t *testing.T) {
 				Size:   16 * (height - 1),
 				Length: 0,
 				Mode:   mode.Normal,
+				Ruler:  true,
 			},
 		},
 		Layout: layout.NewLayout(0).Resize(0, 0, width, height-1),
@@ -115,7 +116,7 @@ func TestTuiEmpty(t *testing.T) {
 	}
 
 	shouldContain(t, screen, []string{
-		"        |  0  1  2  3  4  5  6  7  8  9  a  b  c  d  e  f |                   ",
+		"        | 00 01 02 03 04 05 06 07 08 09 0a 0b 0c 0d 0e 0f |                   ",
 		" 000000 | 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 | ................ #",
 		" 000010 | 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 | ................ #",
 		" 000020 | 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 | ................ #",
@@ -135,6 +136,360 @@ func TestTuiEmpty(t *testing.T) {
 	}
 }
 
+func TestTuiNoRuler(t *testing.T) {
+	ui := NewTui()
+	eventCh := make(chan event.Event)
+	screen := tcell.NewSimulationScreen("")
+	if err := ui.initForTest(eventCh, screen); err != nil {
+		t.Fatal(err)
+	}
+	screen.SetSize(90, 20)
+	width, height := screen.Size()
+	go ui.Run(mockKeyManager())
+
+	s := state.State{
+		WindowStates: map[int]*state.WindowState{
+			0: &state.WindowState{
+				Name:   "",
+				Width:  16,
+				Offset: 0,
+				Cursor: 0,
+				Bytes:  []byte(strings.Repeat("\x00", 16*height)),
+				Size:   16 * height,
+				Length: 0,
+				Mode:   mode.Normal,
+			},
+		},
+		Layout: layout.NewLayout(0).Resize(0, 0, width, height-1),
+	}
+	if err := ui.Redraw(s); err != nil {
+		t.Errorf("ui.Redraw should return nil but got: %v", err)
+	}
+
+	shouldContain(t, screen, []string{
+		" 000110 | 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 | ................ #",
+	})
+	if got := getContents(screen); !strings.HasPrefix(got, " 000000 | 00 00 00") {
+		t.Errorf("screen should start with the first line but got\n%v", got)
+	}
+
+	x, y, _ := screen.GetCursor()
+	if x != 10 || y != 0 {
+		t.Errorf("cursor position should be (%d, %d) but got (%d, %d)", 10, 0, x, y)
+	}
+	if err := ui.Close(); err != nil {
+		t.Errorf("ui.Close should return nil but got %v", err)
+	}
+}
+
+func TestTuiRelativeOffset(t *testing.T) {
+	ui := NewTui()
+	eventCh := make(chan event.Event)
+	screen := tcell.NewSimulationScreen("")
+	if err := ui.initForTest(eventCh, screen); err != nil {
+		t.Fatal(err)
+	}
+	screen.SetSize(90, 20)
+	width, height := screen.Size()
+	go ui.Run(mockKeyManager())
+
+	s := state.State{
+		WindowStates: map[int]*state.WindowState{
+			0: &state.WindowState{
+				Name:           "",
+				Width:          16,
+				Offset:         0x100,
+				Cursor:         0x132,
+				Bytes:          []byte(strings.Repeat("\x00", 16*height)),
+				Size:           16 * height,
+				Length:         0x1000,
+				Mode:           mode.Normal,
+				RelativeOffset: true,
+			},
+		},
+		Layout: layout.NewLayout(0).Resize(0, 0, width, height-1),
+	}
+	if err := ui.Redraw(s); err != nil {
+		t.Errorf("ui.Redraw should return nil but got: %v", err)
+	}
+
+	if got := getContents(screen); !strings.HasPrefix(got, "     30 | 00 00 00") {
+		t.Errorf("screen should start with the relative offset but got\n%v", got)
+	}
+	shouldContain(t, screen, []string{
+		"     10 | 00 00 00",
+		" 000130 | 00 00 00",
+		"     30 | 00 00 00",
+	})
+	if err := ui.Close(); err != nil {
+		t.Errorf("ui.Close should return nil but got %v", err)
+	}
+}
+
+func TestTuiSparse(t *testing.T) {
+	ui := NewTui()
+	eventCh := make(chan event.Event)
+	screen := tcell.NewSimulationScreen("")
+	if err := ui.initForTest(eventCh, screen); err != nil {
+		t.Fatal(err)
+	}
+	screen.SetSize(90, 20)
+	width, height := screen.Size()
+	go ui.Run(mockKeyManager())
+
+	s := state.State{
+		WindowStates: map[int]*state.WindowState{
+			0: &state.WindowState{
+				Name:   "",
+				Width:  16,
+				Offset: 0,
+				Cursor: 0x640,
+				Bytes:  []byte(strings.Repeat("\x00", 16*height)),
+				Size:   16 * (height - 1),
+				Length: 0x1000,
+				Mode:   mode.Normal,
+				Skips:  []state.Skip{{Row: 2, Offset: 0x20, Length: 0x620}},
+			},
+		},
+		Layout: layout.NewLayout(0).Resize(0, 0, width, height-1),
+	}
+	if err := ui.Redraw(s); err != nil {
+		t.Errorf("ui.Redraw should return nil but got: %v", err)
+	}
+
+	shouldContain(t, screen, []string{
+		" 000010 | 00 00 00",
+		"      * | 98 identical rows, 0x620 bytes",
+		" 000640 | 00 00 00",
+		" 000650 | 00 00 00",
+		"0x000640",
+	})
+	if err := ui.Close(); err != nil {
+		t.Errorf("ui.Close should return nil but got %v", err)
+	}
+}
+
+func TestTuiRecordSize(t *testing.T) {
+	ui := NewTui()
+	eventCh := make(chan event.Event)
+	screen := tcell.NewSimulationScreen("")
+	if err := ui.initForTest(eventCh, screen); err != nil {
+		t.Fatal(err)
+	}
+	screen.SetSize(90, 20)
+	width, height := screen.Size()
+	go ui.Run(mockKeyManager())
+
+	s := state.State{
+		WindowStates: map[int]*state.WindowState{
+			0: &state.WindowState{
+				Name:       "",
+				Width:      16,
+				Offset:     0,
+				Cursor:     0,
+				Bytes:      []byte(strings.Repeat("\x00", 16*height)),
+				Size:       16 * (height - 1),
+				Length:     0x1000,
+				Mode:       mode.Normal,
+				RecordSize: 12,
+			},
+		},
+		Layout: layout.NewLayout(0).Resize(0, 0, width, height-1),
+	}
+	if err := ui.Redraw(s); err != nil {
+		t.Errorf("ui.Redraw should return nil but got: %v", err)
+	}
+
+	shouldContain(t, screen, []string{
+		" 000000 | 00 00 00 00 00 00 00 00 00 00 00 00|00 00 00 00 | ",
+		" 000010 | 00 00 00 00 00 00 00 00|00 00 00 00 00 00 00 00 | ",
+		" 000020 | 00 00 00 00|00 00 00 00 00 00 00 00 00 00 00 00 | ",
+	})
+	if err := ui.Close(); err != nil {
+		t.Errorf("ui.Close should return nil but got %v", err)
+	}
+}
+
+func TestTuiLabels(t *testing.T) {
+	ui := NewTui()
+	eventCh := make(chan event.Event)
+	screen := tcell.NewSimulationScreen("")
+	if err := ui.initForTest(eventCh, screen); err != nil {
+		t.Fatal(err)
+	}
+	screen.SetSize(100, 20)
+	width, height := screen.Size()
+	go ui.Run(mockKeyManager())
+
+	s := state.State{
+		WindowStates: map[int]*state.WindowState{
+			0: &state.WindowState{
+				Name:   "",
+				Width:  16,
+				Offset: 0,
+				Cursor: 0,
+				Bytes:  []byte(strings.Repeat("\x00", 16*height)),
+				Size:   16 * (height - 1),
+				Length: 0x1000,
+				Mode:   mode.Normal,
+				Labels: []state.Label{{Offset: 0x18, Text: ".text"}, {Offset: 0x1c, Text: ".data"}, {Offset: 0x40, Text: ".bss"}},
+			},
+		},
+		Layout: layout.NewLayout(0).Resize(0, 0, width, height-1),
+	}
+	if err := ui.Redraw(s); err != nil {
+		t.Errorf("ui.Redraw should return nil but got: %v", err)
+	}
+
+	shouldContain(t, screen, []string{
+		" 000010 | 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 | ................ | .text .data",
+		" 000040 | 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 | ................ | .bss",
+	})
+	if err := ui.Close(); err != nil {
+		t.Errorf("ui.Close should return nil but got %v", err)
+	}
+}
+
+func TestTuiDisassembly(t *testing.T) {
+	ui := NewTui()
+	eventCh := make(chan event.Event)
+	screen := tcell.NewSimulationScreen("")
+	if err := ui.initForTest(eventCh, screen); err != nil {
+		t.Fatal(err)
+	}
+	screen.SetSize(120, 20)
+	width, height := screen.Size()
+	go ui.Run(mockKeyManager())
+
+	s := state.State{
+		WindowStates: map[int]*state.WindowState{
+			0: &state.WindowState{
+				Name:        "",
+				Width:       16,
+				Offset:      0,
+				Cursor:      0,
+				Bytes:       []byte(strings.Repeat("\x00", 16*height)),
+				Size:        16 * (height - 1),
+				Length:      0x1000,
+				Mode:        mode.Normal,
+				Labels:      []state.Label{{Offset: 0x10, Text: ".text"}},
+				Disassembly: []string{"00000000  55           push rbp", "00000001  4889e5       mov rbp, rsp"},
+			},
+		},
+		Layout: layout.NewLayout(0).Resize(0, 0, width, height-1),
+	}
+	if err := ui.Redraw(s); err != nil {
+		t.Errorf("ui.Redraw should return nil but got: %v", err)
+	}
+
+	shouldContain(t, screen, []string{
+		" 000000 | 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 | ................ # 00000000  55           push rbp",
+		" 000010 | 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 | ................ | 00000001  4889e5       mov rbp, rsp",
+	})
+	if err := ui.Close(); err != nil {
+		t.Errorf("ui.Close should return nil but got %v", err)
+	}
+}
+
+func TestTuiRowSum(t *testing.T) {
+	ui := NewTui()
+	eventCh := make(chan event.Event)
+	screen := tcell.NewSimulationScreen("")
+	if err := ui.initForTest(eventCh, screen); err != nil {
+		t.Fatal(err)
+	}
+	screen.SetSize(90, 20)
+	width, height := screen.Size()
+	go ui.Run(mockKeyManager())
+
+	s := state.State{
+		WindowStates: map[int]*state.WindowState{
+			0: &state.WindowState{
+				Name:   "",
+				Width:  4,
+				Offset: 0,
+				Cursor: 0,
+				Bytes:  append([]byte("\x01\x02\x03\x04\xff\x01"), make([]byte, 4*height)...),
+				Size:   6,
+				Length: 6,
+				Mode:   mode.Normal,
+				RowSum: "sum8",
+			},
+		},
+		Layout: layout.NewLayout(0).Resize(0, 0, width, height-1),
+	}
+	if err := ui.Redraw(s); err != nil {
+		t.Errorf("ui.Redraw should return nil but got: %v", err)
+	}
+
+	shouldContain(t, screen, []string{
+		" 000000 | 01 02 03 04 | .... 0a #",
+		" 000004 | ff 01       | ..   00 #",
+		" 000008 |             |         |",
+	})
+	if err := ui.Close(); err != nil {
+		t.Errorf("ui.Close should return nil but got %v", err)
+	}
+}
+
+func TestRowSum(t *testing.T) {
+	for _, testCase := range []struct {
+		kind     string
+		expected byte
+	}{
+		{"sum8", 0xdd},
+		{"xor8", 0x31},
+		{"crc8", 0xf4},
+	} {
+		if got := rowSum(testCase.kind, []byte("123456789")); got != testCase.expected {
+			t.Errorf("rowSum(%q) should be %02x but got %02x", testCase.kind, testCase.expected, got)
+		}
+	}
+}
+
+func TestTuiTable(t *testing.T) {
+	ui := NewTui()
+	eventCh := make(chan event.Event)
+	screen := tcell.NewSimulationScreen("")
+	if err := ui.initForTest(eventCh, screen); err != nil {
+		t.Fatal(err)
+	}
+	screen.SetSize(90, 20)
+	width, height := screen.Size()
+	go ui.Run(mockKeyManager())
+
+	s := state.State{
+		WindowStates: map[int]*state.WindowState{
+			0: &state.WindowState{
+				Name:   "",
+				Width:  6,
+				Offset: 0,
+				Cursor: 8,
+				Bytes:  []byte(strings.Repeat("\x01\x00\x00\x00\xc0\x3f", height)),
+				Size:   12,
+				Length: 12,
+				Mode:   mode.Normal,
+				Records: [][]state.Field{
+					{{Text: "id=1", Offset: 0, Size: 2}, {Text: "v=1.5", Offset: 2, Size: 4}},
+					{{Text: "id=1", Offset: 0, Size: 2}, {Text: "v=1.5", Offset: 2, Size: 4}},
+				},
+			},
+		},
+		Layout: layout.NewLayout(0).Resize(0, 0, width, height-1),
+	}
+	if err := ui.Redraw(s); err != nil {
+		t.Errorf("ui.Redraw should return nil but got: %v", err)
+	}
+
+	shouldContain(t, screen, []string{
+		" 000000 | 01 00 00 00 c0 3f | id=1 v=1.5",
+		" 000006 | 01 00 00 00 c0 3f | id=1 v=1.5",
+	})
+	if err := ui.Close(); err != nil {
+		t.Errorf("ui.Close should return nil but got %v", err)
+	}
+}
+
 func TestTuiScrollBar(t *testing.T) {
 	ui := NewTui()
 	eventCh := make(chan event.Event)
@@ -157,6 +512,7 @@ func TestTuiScrollBar(t *testing.T) {
 				Size:   16 * (height - 1),
 				Length: int64(16 * (height - 1) * 3),
 				Mode:   mode.Normal,
+				Ruler:  true,
 			},
 		},
 		Layout: layout.NewLayout(0).Resize(0, 0, width, height-1),
@@ -166,7 +522,7 @@ func TestTuiScrollBar(t *testing.T) {
 	}
 
 	shouldContain(t, screen, []string{
-		"        |  0  1  2  3  4  5  6  7  8  9  a  b  c  d  e  f |                    ",
+		"        | 00 01 02 03 04 05 06 07 08 09 0a 0b 0c 0d 0e 0f |                    ",
 		" 000000 | 61 61 61 61 61 61 61 61 61 61 61 61 61 61 61 61 | aaaaaaaaaaaaaaaa # ",
 		" 000050 | 61 61 61 61 61 61 61 61 61 61 61 61 61 61 61 61 | aaaaaaaaaaaaaaaa # ",
 		" 000060 | 61 61 61 61 61 61 61 61 61 61 61 61 61 61 61 61 | aaaaaaaaaaaaaaaa | ",
@@ -208,6 +564,7 @@ func TestTuiHorizontalSplit(t *testing.T) {
 				Size:   110 * 10,
 				Length: 600,
 				Mode:   mode.Normal,
+				Ruler:  true,
 			},
 			1: &state.WindowState{
 				Name:   "test1",
@@ -218,6 +575,7 @@ func TestTuiHorizontalSplit(t *testing.T) {
 				Size:   110 * 10,
 				Length: 800,
 				Mode:   mode.Normal,
+				Ruler:  true,
 			},
 		},
 		Layout: layout.NewLayout(0).SplitBottom(1).Resize(0, 0, width, height-1),
@@ -230,7 +588,7 @@ func TestTuiHorizontalSplit(t *testing.T) {
 		" 000000 | 54 65 73 74 20 77 69 6e 64 6f 77 20 30 2e 00 00 | Test window 0... #",
 		" 000010 | 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 | ................ #",
 		" test0 : 0x54 : 'T'                                                         0/600 : 0x000000/0x000258 : 0.00%",
-		"        |  0  1  2  3  4  5  6  7  8  9  a  b  c  d  e  f |                   ",
+		"        | 00 01 02 03 04 05 06 07 08 09 0a 0b 0c 0d 0e 0f |                   ",
 		" 000000 | 54 65 73 74 20 77 69 6e 64 6f 77 20 31 2e 20 20 | Test window 1.   #",
 		" 000010 | 20 20 20 20 20 20 20 20 20 20 20 20 20 20 20 20 |                  #",
 		" test1 : 0x54 : 'T'                                                         0/800 : 0x000000/0x000320 : 0.00%",
@@ -270,6 +628,7 @@ func TestTuiVerticalSplit(t *testing.T) {
 				Size:   55 * 19,
 				Length: 600,
 				Mode:   mode.Normal,
+				Ruler:  true,
 			},
 			1: &state.WindowState{
 				Name:   "test1",
@@ -280,6 +639,7 @@ func TestTuiVerticalSplit(t *testing.T) {
 				Size:   54 * 19,
 				Length: 800,
 				Mode:   mode.Normal,
+				Ruler:  true,
 			},
 		},
 		Layout: layout.NewLayout(0).SplitRight(1).Resize(0, 0, width, height-1),
@@ -289,7 +649,7 @@ func TestTuiVerticalSplit(t *testing.T) {
 	}
 
 	shouldContain(t, screen, []string{
-		"        |  0  1  2  3  4  5  6  7 |                    |        |  0  1  2  3  4  5  6  7 |",
+		"        | 00 01 02 03 04 05 06 07 |                    |        | 00 01 02 03 04 05 06 07 |",
 		" 000000 | 54 65 73 74 20 77 69 6e | Test win #         | 000000 | 54 65 73 74 20 77 69 6e | Test win #",
 		" 000008 | 64 6f 77 20 30 2e 00 00 | dow 0... #         | 000008 | 64 6f 77 20 31 2e 20 20 | dow 1.   #",
 		" 000010 | 00 00 00 00 00 00 00 00 | ........ #         | 000010 | 20 20 20 20 20 20 20 20 |          #",
@@ -354,6 +714,20 @@ func TestTuiCmdline(t *testing.T) {
 	if !strings.HasPrefix(got, expected) {
 		t.Errorf("cmdline should start with %q but got %q", expected, got)
 	}
+
+	s = state.State{
+		Mode:          mode.Passphrase,
+		Cmdline:       []rune("secret"),
+		CmdlineCursor: 6,
+	}
+	if err := ui.Redraw(s); err != nil {
+		t.Errorf("ui.Redraw should return nil but got: %v", err)
+	}
+
+	got, expected = getCmdline(), "Passphrase: ****** "
+	if !strings.HasPrefix(got, expected) {
+		t.Errorf("cmdline should start with %q but got %q", expected, got)
+	}
 	if err := ui.Close(); err != nil {
 		t.Errorf("ui.Close should return nil but got %v", err)
 	}
@@ -399,3 +773,141 @@ func TestTuiCmdlineCompletionCandidates(t *testing.T) {
 		t.Errorf("ui.Close should return nil but got %v", err)
 	}
 }
+
+func TestTuiMessageLines(t *testing.T) {
+	ui := NewTui()
+	eventCh := make(chan event.Event)
+	screen := tcell.NewSimulationScreen("")
+	if err := ui.initForTest(eventCh, screen); err != nil {
+		t.Fatal(err)
+	}
+	screen.SetSize(90, 20)
+	width, height := screen.Size()
+	go ui.Run(mockKeyManager())
+
+	s := state.State{
+		WindowStates: map[int]*state.WindowState{
+			0: &state.WindowState{
+				Name:   "",
+				Width:  16,
+				Offset: 0,
+				Cursor: 0,
+				Bytes:  []byte(strings.Repeat("a", 16*(height-1))),
+				Size:   16 * (height - 1),
+				Length: int64(16 * (height - 1)),
+				Mode:   mode.Normal,
+				Ruler:  true,
+			},
+		},
+		Layout:    layout.NewLayout(0).Resize(0, 0, width, height-1),
+		Error:     errors.New("first line\nsecond line"),
+		ErrorType: state.MessageInfo,
+	}
+	if err := ui.Redraw(s); err != nil {
+		t.Errorf("ui.Redraw should return nil but got: %v", err)
+	}
+
+	shouldContain(t, screen, []string{
+		"first line" + strings.Repeat(" ", width-10) + "\nsecond line",
+	})
+	if err := ui.Close(); err != nil {
+		t.Errorf("ui.Close should return nil but got %v", err)
+	}
+}
+
+func TestTuiImage(t *testing.T) {
+	ui := NewTui()
+	eventCh := make(chan event.Event)
+	screen := tcell.NewSimulationScreen("")
+	if err := ui.initForTest(eventCh, screen); err != nil {
+		t.Fatal(err)
+	}
+	screen.SetSize(90, 20)
+	width, height := screen.Size()
+	go ui.Run(mockKeyManager())
+
+	s := state.State{
+		WindowStates: map[int]*state.WindowState{
+			0: &state.WindowState{
+				Name:   "",
+				Width:  16,
+				Offset: 0,
+				Cursor: 0,
+				Bytes:  []byte(strings.Repeat("a", 16*(height-1))),
+				Size:   16 * (height - 1),
+				Length: int64(16 * (height - 1)),
+				Mode:   mode.Normal,
+			},
+		},
+		Layout: layout.NewLayout(0).Resize(0, 0, width, height-1),
+		Error: &state.Image{Title: "2x3 rgb888 at 0x0", Width: 2, Height: 3,
+			Pixels: []uint32{0xff0000, 0x00ff00, 0x0000ff, 0xffffff, 0x000000, 0x808080}},
+		ErrorType: state.MessageInfo,
+	}
+	if err := ui.Redraw(s); err != nil {
+		t.Errorf("ui.Redraw should return nil but got: %v", err)
+	}
+
+	shouldContain(t, screen, []string{"2x3 rgb888 at 0x0", "\n▀▀", "\n▀▀"})
+	for _, testCase := range []struct {
+		x, y   int
+		fg, bg tcell.Color
+	}{
+		{0, height - 2, tcell.NewHexColor(0xff0000), tcell.NewHexColor(0x0000ff)},
+		{1, height - 2, tcell.NewHexColor(0x00ff00), tcell.NewHexColor(0xffffff)},
+		{1, height - 1, tcell.NewHexColor(0x808080), tcell.ColorDefault},
+	} {
+		_, _, style, _ := screen.GetContent(testCase.x, testCase.y)
+		if fg, bg, _ := style.Decompose(); fg != testCase.fg || bg != testCase.bg {
+			t.Errorf("colors at (%d, %d) should be %v and %v but got %v and %v",
+				testCase.x, testCase.y, testCase.fg, testCase.bg, fg, bg)
+		}
+	}
+	if err := ui.Close(); err != nil {
+		t.Errorf("ui.Close should return nil but got %v", err)
+	}
+}
+
+func TestTuiTitleBell(t *testing.T) {
+	ui := NewTui()
+	eventCh := make(chan event.Event)
+	screen := tcell.NewSimulationScreen("")
+	if err := ui.initForTest(eventCh, screen); err != nil {
+		t.Fatal(err)
+	}
+	var b strings.Builder
+	ui.titleOut = &b
+	screen.SetSize(90, 20)
+	width, height := screen.Size()
+	go ui.Run(mockKeyManager())
+
+	s := state.State{
+		WindowStates: map[int]*state.WindowState{
+			0: &state.WindowState{
+				Name:   "test.bin",
+				Width:  16,
+				Bytes:  []byte(strings.Repeat("\x00", 16*(height-1))),
+				Size:   16,
+				Length: 16,
+				Mode:   mode.Normal,
+			},
+		},
+		Layout: layout.NewLayout(0).Resize(0, 0, width, height-1),
+		Error:  errors.New("pattern not found: \"x\""),
+		Title:  "bed — test.bin [+]\x07",
+		Bell:   state.BellVisual,
+	}
+	for i := 0; i < 2; i++ {
+		if err := ui.Redraw(s); err != nil {
+			t.Errorf("ui.Redraw should return nil but got: %v", err)
+		}
+	}
+	if expected := "\x1b]2;bed — test.bin [+]\x07"; b.String() != expected {
+		t.Errorf("title should be written once as %q but got %q", expected, b.String())
+	}
+	_, _, style, _ := screen.GetContent(0, height-1)
+	if _, _, attrs := style.Decompose(); attrs&tcell.AttrReverse != 0 {
+		t.Errorf("screen should not be reversed after the visual bell")
+	}
+	shouldContain(t, screen, []string{`pattern not found: "x"`})
+}
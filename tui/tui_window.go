@@ -8,14 +8,18 @@ import (
 
 	"github.com/gdamore/tcell"
 
+	"github.com/itchyny/bed/colorscheme"
 	"github.com/itchyny/bed/mathutil"
 	"github.com/itchyny/bed/mode"
 	"github.com/itchyny/bed/state"
 )
 
 type tuiWindow struct {
-	region region
-	screen tcell.Screen
+	region     region
+	screen     tcell.Screen
+	statusLine string
+	scheme     colorscheme.Scheme
+	pending    string
 }
 
 func (ui *tuiWindow) getTextDrawer() *textDrawer {
@@ -29,7 +33,7 @@ func (ui *tuiWindow) setCursor(line int, offset int) {
 func (ui *tuiWindow) offsetStyleWidth(s *state.WindowState) int {
 	threshold := int64(0xfffff)
 	for i := 0; i < 10; i++ {
-		if s.Length <= threshold {
+		if s.BaseAddress+s.Length <= threshold {
 			return 6 + i
 		}
 		threshold = (threshold << 4) | 0x0f
@@ -38,71 +42,209 @@ func (ui *tuiWindow) offsetStyleWidth(s *state.WindowState) int {
 }
 
 func (ui *tuiWindow) drawWindow(s *state.WindowState, active bool) {
-	height, width := ui.region.height-2, s.Width
-	bytes, styles := ui.bytesArray(height, width, s)
-	cursorPos := int(s.Cursor - s.Offset)
-	cursorLine := cursorPos / width
+	var top int
+	if s.Ruler {
+		top = 1
+	}
+	height, width := ui.region.height-1-top, s.Width
+	rows := windowRows(s, height)
+	bytes, styles, halves := ui.bytesArray(rows, width, s)
+	cursorLine := cursorRow(rows, s)
+	if cursorLine < 0 {
+		cursorLine = int(s.Cursor-s.Offset) / width
+	}
 	offsetStyleWidth := ui.offsetStyleWidth(s)
 	offsetStyle := " %0" + strconv.Itoa(offsetStyleWidth) + "x"
+	relativeStyle := " %" + strconv.Itoa(offsetStyleWidth) + "x"
+	normal, _ := schemeStyle(ui.scheme, colorscheme.Normal)
+	offsetColor, _ := schemeStyle(ui.scheme, colorscheme.Offset)
+	table, fieldCursor := len(s.Records) > 0, -1
+	decode := s.Encoding != "" && s.Encoding != "ascii"
+	right := 4*width + 3
+	if table {
+		right = ui.region.width - offsetStyleWidth - 5
+	} else if s.RowSum != "" {
+		right += 3
+	}
 	d := ui.getTextDrawer()
 	for i := 0; i < height; i++ {
-		d.setTop(i + 1).setLeft(0).setOffset(0)
-		d.setString(fmt.Sprintf(offsetStyle, s.Offset+int64(i*width)), tcell.StyleDefault.Bold(i == cursorLine))
+		d.setTop(i + top).setLeft(0).setOffset(0)
+		if rows[i].skip > 0 {
+			d.setString(fmt.Sprintf(" %*s", offsetStyleWidth, "*"), offsetColor)
+			d.setLeft(offsetStyleWidth + 3)
+			d.setOffset(0).setString(fmt.Sprintf(" %-*s", 3*width-1, skipMarker(rows[i].skip, width)), normal)
+			d.setOffset(3*width+3).setString(strings.Repeat(" ", width), normal)
+		} else if s.RelativeOffset && i != cursorLine && cursorLine < height {
+			d.setString(fmt.Sprintf(relativeStyle, mathutil.MaxInt64(
+				rows[i].offset-rows[cursorLine].offset, rows[cursorLine].offset-rows[i].offset)), offsetColor)
+		} else {
+			d.setString(fmt.Sprintf(offsetStyle, s.BaseAddress+rows[i].offset), offsetColor.Bold(i == cursorLine).
+				Underline(s.RecordSize > 0 && rows[i].offset%s.RecordSize == 0))
+		}
 		d.setLeft(offsetStyleWidth + 3)
-		for j := 0; j < width; j++ {
+		for j := 0; j < width && rows[i].skip == 0; j++ {
 			if styles[i][j] == math.MaxUint16 {
-				d.setOffset(3*j).setString("   ", tcell.StyleDefault)
-				d.setOffset(3*width+j+3).setString(" ", tcell.StyleDefault)
+				d.setOffset(3*j).setString("   ", normal)
+				if !table {
+					d.setOffset(3*width+j+3).setString(" ", normal)
+				}
 			} else {
-				d.setOffset(3*j).setString(" ", tcell.StyleDefault)
-				if i*width+j == cursorPos {
+				if j > 0 && s.RecordSize > 0 && (rows[i].offset+int64(j))%s.RecordSize == 0 {
+					d.setOffset(3*j).setString("|", normal)
+				} else {
+					d.setOffset(3*j).setString(" ", normal)
+				}
+				if rows[i].offset+int64(j) == s.Cursor {
 					styles[i][j] = styles[i][j].Reverse(active && !s.FocusText).Bold(
 						!active || s.FocusText).Underline(!active || s.FocusText)
+					if halves != nil {
+						halves[i][j].style = halves[i][j].style.Reverse(active && !s.FocusText).Bold(
+							!active || s.FocusText).Underline(!active || s.FocusText)
+					}
+				}
+				if halves != nil && halves[i][j].ok {
+					drawNibbles(d, 3*j+1, bytes[i][j], styles[i][j], halves[i][j])
+				} else {
+					d.setOffset(3*j+1).setString(fmt.Sprintf("%02x", bytes[i][j]), styles[i][j])
 				}
-				d.setOffset(3*j+1).setString(fmt.Sprintf("%02x", bytes[i][j]), styles[i][j])
-				if i*width+j == cursorPos {
+				if rows[i].offset+int64(j) == s.Cursor {
 					styles[i][j] = styles[i][j].Reverse(active && s.FocusText).Bold(
 						!active || !s.FocusText).Underline(!active || !s.FocusText)
 				}
-				d.setOffset(3*width+j+3).setString(string(prettyByte(bytes[i][j])), styles[i][j])
+				if !table && !decode {
+					d.setOffset(3*width+j+3).setString(string(prettyByte(bytes[i][j])), styles[i][j])
+				}
+			}
+		}
+		if decode && !table && rows[i].skip == 0 {
+			n := rowLength(styles[i])
+			drawText(d.setOffset(3*width+3), decodeText(bytes[i][:n], s.Encoding),
+				styles[i][:n], int(s.Cursor-rows[i].offset))
+		}
+		if table {
+			if k := ui.drawRecord(d, s, rows[i], right, active); i == cursorLine {
+				fieldCursor = k
+			}
+		} else if s.RowSum != "" {
+			text := "   "
+			if n := rowLength(styles[i]); rows[i].skip == 0 && n > 0 {
+				text = fmt.Sprintf(" %02x", rowSum(s.RowSum, bytes[i][:n]))
 			}
+			d.setOffset(4*width+3).setString(text, offsetColor.Bold(i == cursorLine))
 		}
-		d.setOffset(-2).setString(" | ", tcell.StyleDefault)
-		d.setOffset(3*width).setString(" | ", tcell.StyleDefault)
-		d.setOffset(4*width+3).setString(" ", tcell.StyleDefault)
+		d.setOffset(-2).setString(" | ", normal)
+		d.setOffset(3*width).setString(" | ", normal)
+		d.setOffset(right).setString(" ", normal)
 	}
 	i := int(s.Cursor % int64(width))
 	if active {
-		if s.FocusText {
-			ui.setCursor(cursorLine+1, 3*width+i+6+offsetStyleWidth)
-		} else if s.Pending {
-			ui.setCursor(cursorLine+1, 3*i+5+offsetStyleWidth)
+		if s.FocusText && table {
+			ui.setCursor(cursorLine+top, mathutil.MaxInt(fieldCursor, 3*width+3)+offsetStyleWidth+3)
+		} else if s.FocusText {
+			ui.setCursor(cursorLine+top, 3*width+i+6+offsetStyleWidth)
+		} else if s.Pending || s.LowNibble {
+			ui.setCursor(cursorLine+top, 3*i+5+offsetStyleWidth)
 		} else {
-			ui.setCursor(cursorLine+1, 3*i+4+offsetStyleWidth)
+			ui.setCursor(cursorLine+top, 3*i+4+offsetStyleWidth)
 		}
 	}
-	ui.drawHeader(s, offsetStyleWidth)
-	ui.drawScrollBar(s, height, 4*width+7+offsetStyleWidth)
-	ui.drawFooter(s, offsetStyleWidth)
+	if s.Ruler {
+		ui.drawHeader(s, offsetStyleWidth)
+	}
+	ui.drawScrollBar(s, top, height, right+offsetStyleWidth+4)
+	if len(s.Disassembly) > 0 {
+		ui.drawDisassembly(s, top, height, right+offsetStyleWidth+6)
+	} else {
+		ui.drawLabels(s, rows, top, right+offsetStyleWidth+6)
+	}
+	if active && s.Literal != "" {
+		ui.drawFooter(s, offsetStyleWidth, s.Literal)
+	} else if active {
+		ui.drawFooter(s, offsetStyleWidth, ui.pending)
+	} else {
+		ui.drawFooter(s, offsetStyleWidth, "")
+	}
+}
+
+// drawRecord draws the decoded fields of the record in the row of the table
+// view up to the right, and returns the offset of the field at the cursor.
+func (ui *tuiWindow) drawRecord(d *textDrawer, s *state.WindowState, row windowRow, right int, active bool) int {
+	normal, _ := schemeStyle(ui.scheme, colorscheme.Normal)
+	offset, cursor := 3*s.Width+3, -1
+	d.setOffset(offset).setString(strings.Repeat(" ", mathutil.MaxInt(right-offset, 0)), normal)
+	if k := row.index / s.Width; row.skip > 0 || k >= len(s.Records) {
+		return cursor
+	}
+	for _, f := range s.Records[row.index/s.Width] {
+		if offset >= right {
+			break
+		}
+		text, style := f.Text, normal
+		if len(text) > right-offset {
+			text = text[:right-offset]
+		}
+		if pos := s.Cursor - row.offset; int64(f.Offset) <= pos && pos < int64(f.Offset+f.Size) {
+			style, cursor = style.Bold(true).Reverse(active && s.FocusText), offset
+		}
+		d.setOffset(offset).setString(text, style)
+		offset += len(text) + 1
+	}
+	return cursor
 }
 
-func (ui *tuiWindow) bytesArray(height, width int, s *state.WindowState) ([][]byte, [][]tcell.Style) {
-	var k int
+// halfStyle is the style of the nibble which is not edited, when the other
+// nibble of the byte is only edited.
+type halfStyle struct {
+	style tcell.Style
+	low   bool // the low nibble is edited
+	ok    bool
+}
+
+// drawNibbles draws the byte in hex at the offset with the edited nibble in
+// the style, and the other nibble in the style of the half.
+func drawNibbles(d *textDrawer, offset int, b byte, style tcell.Style, half halfStyle) {
+	high, low := style, half.style
+	if half.low {
+		high, low = low, style
+	}
+	d.setOffset(offset).setString(fmt.Sprintf("%x", b>>4), high)
+	d.setOffset(offset+1).setString(fmt.Sprintf("%x", b&0x0f), low)
+}
+
+func (ui *tuiWindow) bytesArray(rows []windowRow, width int, s *state.WindowState) ([][]byte, [][]tcell.Style, [][]halfStyle) {
+	height := len(rows)
 	if height <= 0 {
-		return nil, nil
+		return nil, nil, nil
 	}
-	eis := s.EditedIndices
+	eis, uis := s.EditedIndices, s.UnsavedIndices
+	ens, uns := s.EditedNibbles, s.UnsavedNibbles
+	hls := highlightColors(s, rows)
 	bytes := make([][]byte, height)
 	styles := make([][]tcell.Style, height)
-	color := tcell.ColorLightSeaGreen
+	var halves [][]halfStyle
+	if s.Nibble {
+		halves = make([][]halfStyle, height)
+	}
+	normal, _ := schemeStyle(ui.scheme, colorscheme.Normal)
+	color := schemeColor(ui.scheme, colorscheme.Edited)
+	savedColor := schemeColor(ui.scheme, colorscheme.Saved)
 	for i := 0; i < height; i++ {
 		bytes[i] = make([]byte, width)
 		styles[i] = make([]tcell.Style, width)
+		if halves != nil {
+			halves[i] = make([]halfStyle, width)
+		}
+		if rows[i].skip > 0 {
+			continue
+		}
+		k := rows[i].index
 		for j := 0; j < width; j++ {
+			styles[i][j] = normal
 			if k >= s.Size {
 				styles[i][j] = tcell.Style(math.MaxUint16)
 			}
-			if s.Pending && i*width+j == int(s.Cursor-s.Offset) {
+			pos := rows[i].offset + int64(j)
+			if s.Pending && pos == s.Cursor {
 				bytes[i][j] = s.PendingByte
 				styles[i][j] = styles[i][j].Foreground(color)
 				if s.Mode == mode.Replace {
@@ -111,37 +253,64 @@ func (ui *tuiWindow) bytesArray(height, width int, s *state.WindowState) ([][]by
 				continue
 			}
 			bytes[i][j] = s.Bytes[k]
-			pos := int64(k) + s.Offset
-			if 0 < len(eis) && eis[0] <= pos && pos < eis[1] {
-				styles[i][j] = styles[i][j].Foreground(color)
-			} else if 0 < len(eis) && eis[1] <= pos {
+			if hls[k] != tcell.ColorDefault {
+				styles[i][j] = styles[i][j].Foreground(hls[k])
+			}
+			for 0 < len(eis) && eis[1] <= pos {
 				eis = eis[2:]
 			}
-			if s.VisualStart >= 0 && s.Cursor < s.Length &&
-				(s.VisualStart <= pos && pos <= s.Cursor ||
-					s.Cursor <= pos && pos <= s.VisualStart) {
+			for 0 < len(uis) && uis[1] <= pos {
+				uis = uis[2:]
+			}
+			for 0 < len(ens) && ens[0] < 2*pos {
+				ens = ens[1:]
+			}
+			for 0 < len(uns) && uns[0] < 2*pos {
+				uns = uns[1:]
+			}
+			plain := styles[i][j]
+			if 0 < len(uis) && uis[0] <= pos {
+				styles[i][j] = styles[i][j].Foreground(color)
+				if halves != nil && 0 < len(uns) && uns[0]/2 == pos {
+					halves[i][j] = halfStyle{plain, uns[0]%2 == 1, true}
+				}
+			} else if 0 < len(eis) && eis[0] <= pos {
+				styles[i][j] = styles[i][j].Foreground(savedColor)
+				if halves != nil && 0 < len(ens) && ens[0]/2 == pos {
+					halves[i][j] = halfStyle{plain, ens[0]%2 == 1, true}
+				}
+			}
+			if a := annotationAt(s.Annotations, pos); a != nil {
+				styles[i][j] = styles[i][j].Background(annotationColor(a))
+			}
+			if s.VisualStart >= 0 && s.Cursor < s.Length && inSelection(s, pos) {
 				styles[i][j] = styles[i][j].Underline(true)
 			}
 			k++
 		}
 	}
-	return bytes, styles
+	return bytes, styles, halves
 }
 
 func (ui *tuiWindow) drawHeader(s *state.WindowState, offsetStyleWidth int) {
-	style := tcell.StyleDefault.Underline(true)
+	style, _ := schemeStyle(ui.scheme, colorscheme.Header)
+	style = style.Underline(true)
 	d := ui.getTextDrawer()
-	d.setString(strings.Repeat(" ", 4*s.Width+8+offsetStyleWidth), style)
+	width := 4*s.Width + 8 + offsetStyleWidth
+	if s.RowSum != "" {
+		width += 3
+	}
+	d.setString(strings.Repeat(" ", width), style)
 	d.setLeft(offsetStyleWidth)
 	cursor := int(s.Cursor % int64(s.Width))
 	for i := 0; i < s.Width; i++ {
-		d.setOffset(3*i+4).setString(fmt.Sprintf("%2x", i), style.Bold(cursor == i))
+		d.setOffset(3*i+4).setString(fmt.Sprintf("%02x", i), style.Bold(cursor == i))
 	}
 	d.setOffset(2).setString("|", style)
 	d.setOffset(3*s.Width+4).setString("|", style)
 }
 
-func (ui *tuiWindow) drawScrollBar(s *state.WindowState, height int, left int) {
+func (ui *tuiWindow) drawScrollBar(s *state.WindowState, top int, height int, left int) {
 	stateSize := s.Size
 	if s.Cursor+1 == s.Length && s.Cursor == s.Offset+int64(s.Size) {
 		stateSize++
@@ -150,34 +319,210 @@ func (ui *tuiWindow) drawScrollBar(s *state.WindowState, height int, left int) {
 	len := mathutil.MaxInt64((s.Length+int64(s.Width)-1)/int64(s.Width), 1)
 	size := mathutil.MaxInt64(total*total/len, 1)
 	pad := (total*total + len - len*size - 1) / mathutil.MaxInt64(total-size+1, 1)
-	top := (s.Offset / int64(s.Width) * total) / (len - pad)
+	barTop := (s.Offset / int64(s.Width) * total) / (len - pad)
+	style, _ := schemeStyle(ui.scheme, colorscheme.ScrollBar)
 	d := ui.getTextDrawer().setLeft(left)
 	for i := 0; i < height; i++ {
-		d.setTop(i + 1)
-		if int(top) <= i && i < int(top+size) {
-			d.setString("#", tcell.StyleDefault)
+		d.setTop(top + i)
+		if int(barTop) <= i && i < int(barTop+size) {
+			d.setString("#", style)
 		} else {
-			d.setString("|", tcell.StyleDefault)
+			d.setString("|", style)
+		}
+	}
+}
+
+// drawLabels draws the labels of the sections starting in the rows at the
+// right of the scroll bar, when the window has the room for them.
+func (ui *tuiWindow) drawLabels(s *state.WindowState, rows []windowRow, top int, left int) {
+	if len(s.Labels) == 0 || left >= ui.region.width {
+		return
+	}
+	style, _ := schemeStyle(ui.scheme, colorscheme.Offset)
+	d := ui.getTextDrawer().setLeft(left)
+	for i, r := range rows {
+		end := r.offset + int64(s.Width)
+		if r.skip > 0 {
+			end = r.offset + r.skip
+		}
+		var texts []string
+		for _, l := range s.Labels {
+			if r.offset <= l.Offset && l.Offset < end {
+				texts = append(texts, l.Text)
+			}
+		}
+		if len(texts) > 0 {
+			d.setTop(top+i).setString(strings.Join(texts, " "), style)
+		}
+	}
+}
+
+// drawDisassembly draws the instructions from the cursor in the side pane at
+// the right of the scroll bar, in place of the labels of the sections.
+func (ui *tuiWindow) drawDisassembly(s *state.WindowState, top int, height int, left int) {
+	if left >= ui.region.width {
+		return
+	}
+	normal, _ := schemeStyle(ui.scheme, colorscheme.Normal)
+	d := ui.getTextDrawer().setLeft(left)
+	for i, line := range s.Disassembly {
+		if i >= height {
+			break
 		}
+		d.setTop(top+i).setString(line, normal.Bold(i == 0))
 	}
 }
 
-func (ui *tuiWindow) drawFooter(s *state.WindowState, offsetStyleWidth int) {
-	offsetStyle := "0x%0" + strconv.Itoa(offsetStyleWidth) + "x"
-	j := int(s.Cursor - s.Offset)
-	name := s.Name
-	if name == "" {
-		name = "[No name]"
+func (ui *tuiWindow) drawFooter(s *state.WindowState, offsetStyleWidth int, pending string) {
+	format := ui.statusLine
+	if format == "" {
+		format = defaultStatusLine
 	}
-	left := fmt.Sprintf(" %s%s : 0x%02x : '%s'",
-		prettyMode(s.Mode), name, s.Bytes[j], prettyRune(s.Bytes[j]))
-	right := fmt.Sprintf("%d/%d : "+offsetStyle+"/"+offsetStyle+" : %.2f%% ",
-		s.Cursor, s.Length, s.Cursor, s.Length,
-		float64(s.Cursor*100)/float64(mathutil.MaxInt64(s.Length, 1)))
+	left, right := formatStatusLine(format, s, offsetStyleWidth, pending)
 	line := left + strings.Repeat(
 		" ", mathutil.MaxInt(2, ui.region.width-len(left)-len(right)),
 	) + right
-	ui.getTextDrawer().setTop(ui.region.height-1).setString(line, tcell.StyleDefault.Reverse(true))
+	style, ok := schemeStyle(ui.scheme, colorscheme.StatusLine)
+	if !ok {
+		style = style.Reverse(true)
+	}
+	ui.getTextDrawer().setTop(ui.region.height-1).setString(line, style)
+}
+
+func annotationAt(annotations []state.Annotation, pos int64) *state.Annotation {
+	for i := len(annotations) - 1; i >= 0; i-- {
+		if annotations[i].From <= pos && pos <= annotations[i].To {
+			return &annotations[i]
+		}
+	}
+	return nil
+}
+
+func annotationColor(a *state.Annotation) tcell.Color {
+	if color := getColor(a.Color); color != tcell.ColorDefault {
+		return color
+	}
+	return tcell.ColorYellow
+}
+
+func schemeColor(scheme colorscheme.Scheme, group string) tcell.Color {
+	return getColor(scheme[group].Foreground)
+}
+
+func highlightColors(s *state.WindowState, rows []windowRow) []tcell.Color {
+	colors := make([]tcell.Color, len(s.Bytes))
+	for i := range colors {
+		colors[i] = tcell.ColorDefault
+	}
+	for _, h := range s.Highlights {
+		color := getColor(h.Color)
+		for _, r := range rows {
+			if r.skip > 0 {
+				continue
+			}
+			from := mathutil.MaxInt64(h.From-r.offset, 0)
+			to := mathutil.MinInt64(mathutil.MinInt64(h.To-r.offset, int64(s.Width-1)),
+				int64(len(colors)-1-r.index))
+			for k := from; k <= to; k++ {
+				colors[int64(r.index)+k] = color
+			}
+		}
+	}
+	return colors
+}
+
+// windowRow is a row of the window; the offset of the first byte, the index in
+// the bytes of the window state, and the length of the collapsed bytes of the
+// marker row of the sparse view.
+type windowRow struct {
+	offset int64
+	index  int
+	skip   int64
+}
+
+func windowRows(s *state.WindowState, height int) []windowRow {
+	if height <= 0 {
+		return nil
+	}
+	rows := make([]windowRow, height)
+	offset, index, skips := s.Offset, 0, s.Skips
+	for i := range rows {
+		if len(skips) > 0 && skips[0].Row == i {
+			rows[i] = windowRow{offset: skips[0].Offset, index: index, skip: skips[0].Length}
+			offset, skips = skips[0].Offset+skips[0].Length, skips[1:]
+			continue
+		}
+		rows[i] = windowRow{offset: offset, index: index}
+		offset, index = offset+int64(s.Width), index+s.Width
+	}
+	return rows
+}
+
+// cursorRow returns the row of the cursor, or -1 if the cursor is not in the
+// rows.
+func cursorRow(rows []windowRow, s *state.WindowState) int {
+	for i, r := range rows {
+		if r.skip == 0 && r.offset <= s.Cursor && s.Cursor < r.offset+int64(s.Width) {
+			return i
+		}
+	}
+	return -1
+}
+
+// cursorIndex returns the index of the cursor in the bytes of the window state.
+func cursorIndex(s *state.WindowState) int {
+	index := s.Cursor - s.Offset
+	for _, skip := range s.Skips {
+		if skip.Offset+skip.Length <= s.Cursor {
+			index -= skip.Length
+		}
+	}
+	return int(index)
+}
+
+func skipMarker(skip int64, width int) string {
+	marker := fmt.Sprintf("%d identical rows, 0x%x bytes", skip/int64(width), skip)
+	if len(marker) > 3*width-1 {
+		marker = fmt.Sprintf("0x%x bytes", skip)
+	}
+	if len(marker) > 3*width-1 {
+		marker = marker[:3*width-1]
+	}
+	return marker
+}
+
+// rowLength returns the number of the bytes in the row.
+func rowLength(styles []tcell.Style) int {
+	for j, style := range styles {
+		if style == math.MaxUint16 {
+			return j
+		}
+	}
+	return len(styles)
+}
+
+// rowSum computes the checksum of the bytes in the row; the sum, the xor or
+// the CRC-8 (polynomial 0x07) of the bytes.
+func rowSum(kind string, bs []byte) byte {
+	var sum byte
+	for _, b := range bs {
+		switch kind {
+		case "sum8":
+			sum += b
+		case "xor8":
+			sum ^= b
+		case "crc8":
+			sum ^= b
+			for i := 0; i < 8; i++ {
+				if sum&0x80 != 0 {
+					sum = sum<<1 ^ 0x07
+				} else {
+					sum <<= 1
+				}
+			}
+		}
+	}
+	return sum
 }
 
 func prettyByte(b byte) byte {
@@ -228,3 +573,19 @@ func prettyMode(m mode.Mode) string {
 		return ""
 	}
 }
+
+// inSelection reports whether the offset is in the visual selection, which is
+// the bytes in the columns between the corners on the block selection.
+func inSelection(s *state.WindowState, pos int64) bool {
+	from, to := mathutil.MinInt64(s.Cursor, s.VisualStart), mathutil.MaxInt64(s.Cursor, s.VisualStart)
+	if pos < from || to < pos {
+		return false
+	}
+	if !s.VisualBlock {
+		return true
+	}
+	width := int64(s.Width)
+	left := mathutil.MinInt64(s.Cursor%width, s.VisualStart%width)
+	right := mathutil.MaxInt64(s.Cursor%width, s.VisualStart%width)
+	return left <= pos%width && pos%width <= right
+}
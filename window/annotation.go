@@ -0,0 +1,110 @@
+package window
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/itchyny/bed/state"
+)
+
+const defaultAnnotationColor = "yellow"
+
+func annotationsPath(filename string) string {
+	return filename + ".bednotes"
+}
+
+func loadAnnotations(filename string) ([]state.Annotation, error) {
+	var annotations []state.Annotation
+	if err := loadSidecar(annotationsPath(filename), &annotations); err != nil {
+		return nil, fmt.Errorf("%s: %s", annotationsPath(filename), err)
+	}
+	return annotations, nil
+}
+
+func saveAnnotations(filename string, annotations []state.Annotation) error {
+	return saveSidecar(annotationsPath(filename), annotations, len(annotations) == 0)
+}
+
+// parseAnnotation parses the argument of :annotate, which is
+// [from-to] [color=name] note. The note can be quoted with double quotes.
+func parseAnnotation(arg string) (a state.Annotation, hasRange bool, err error) {
+	a.Color = defaultAnnotationColor
+	first := arg
+	if i := strings.IndexByte(arg, ' '); i >= 0 {
+		first = arg[:i]
+	}
+	if xs := strings.SplitN(first, "-", 2); len(xs) == 2 {
+		from, err1 := strconv.ParseInt(xs[0], 0, 64)
+		to, err2 := strconv.ParseInt(xs[1], 0, 64)
+		if err1 == nil && err2 == nil {
+			if from > to {
+				from, to = to, from
+			}
+			a.From, a.To, hasRange = from, to, true
+			arg = strings.TrimSpace(arg[len(first):])
+		}
+	}
+	if strings.HasPrefix(arg, "color=") {
+		i := strings.IndexByte(arg, ' ')
+		if i < 0 {
+			i = len(arg)
+		}
+		a.Color = arg[len("color="):i]
+		arg = strings.TrimSpace(arg[i:])
+	}
+	if len(arg) >= 2 && arg[0] == '"' && arg[len(arg)-1] == '"' {
+		arg = arg[1 : len(arg)-1]
+	}
+	if arg == "" {
+		return a, hasRange, fmt.Errorf("annotation note is empty")
+	}
+	a.Note = arg
+	return a, hasRange, nil
+}
+
+func addAnnotation(annotations []state.Annotation, a state.Annotation) []state.Annotation {
+	annotations = append(append([]state.Annotation(nil), annotations...), a)
+	sort.SliceStable(annotations, func(i, j int) bool {
+		return annotations[i].From < annotations[j].From
+	})
+	return annotations
+}
+
+// deleteAnnotations removes the annotations which contain the offset.
+func deleteAnnotations(annotations []state.Annotation, offset int64) ([]state.Annotation, int) {
+	var newAnnotations []state.Annotation
+	for _, a := range annotations {
+		if a.From <= offset && offset <= a.To {
+			continue
+		}
+		newAnnotations = append(newAnnotations, a)
+	}
+	return newAnnotations, len(annotations) - len(newAnnotations)
+}
+
+// shiftAnnotations moves the ranges of the annotations by the bytes inserted
+// at the offset, or deleted from the offset when n is negative. The range
+// grows or shrinks by the bytes inserted or deleted within the range, and the
+// annotation is removed when all the bytes are deleted. The annotations are
+// copied since the slice is shared with the state of the window.
+func shiftAnnotations(annotations []state.Annotation, offset, n int64) ([]state.Annotation, bool) {
+	var moved bool
+	newAnnotations := make([]state.Annotation, 0, len(annotations))
+	for _, a := range annotations {
+		from, to := shiftOffset(a.From, offset, n), shiftOffset(a.To, offset, n)
+		if n < 0 && offset <= a.To && a.To < offset-n {
+			to = offset - 1
+		}
+		if from != a.From || to != a.To {
+			moved = true
+		}
+		if from > to {
+			continue
+		}
+		a.From, a.To = from, to
+		newAnnotations = append(newAnnotations, a)
+	}
+	return newAnnotations, moved
+}
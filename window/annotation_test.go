@@ -0,0 +1,88 @@
+package window
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/itchyny/bed/state"
+)
+
+func TestParseAnnotation(t *testing.T) {
+	for _, testCase := range []struct {
+		arg      string
+		expected state.Annotation
+		hasRange bool
+		err      bool
+	}{
+		{"IV", state.Annotation{Note: "IV", Color: "yellow"}, false, false},
+		{`0x100-0x11f "IV"`, state.Annotation{From: 0x100, To: 0x11f, Note: "IV", Color: "yellow"}, true, false},
+		{"32-16 color=red magic number", state.Annotation{From: 16, To: 32, Note: "magic number", Color: "red"}, true, false},
+		{`color=blue "header size"`, state.Annotation{Note: "header size", Color: "blue"}, false, false},
+		{"a-b note", state.Annotation{Note: "a-b note", Color: "yellow"}, false, false},
+		{"0x10-0x20", state.Annotation{From: 0x10, To: 0x20, Color: "yellow"}, true, true},
+		{`""`, state.Annotation{Color: "yellow"}, false, true},
+	} {
+		got, hasRange, err := parseAnnotation(testCase.arg)
+		if testCase.err {
+			if err == nil {
+				t.Errorf("parseAnnotation(%q) should return an error", testCase.arg)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("err should be nil but got: %v", err)
+		}
+		if !reflect.DeepEqual(got, testCase.expected) {
+			t.Errorf("parseAnnotation(%q) should be %+v but got %+v", testCase.arg, testCase.expected, got)
+		}
+		if hasRange != testCase.hasRange {
+			t.Errorf("parseAnnotation(%q) should return hasRange %v but got %v", testCase.arg, testCase.hasRange, hasRange)
+		}
+	}
+}
+
+func TestDeleteAnnotations(t *testing.T) {
+	annotations := []state.Annotation{
+		{From: 0, To: 15, Note: "header"},
+		{From: 4, To: 7, Note: "size"},
+		{From: 16, To: 31, Note: "body"},
+	}
+	got, n := deleteAnnotations(annotations, 5)
+	if n != 2 {
+		t.Errorf("deleteAnnotations should delete %d annotations but got %d", 2, n)
+	}
+	expected := []state.Annotation{{From: 16, To: 31, Note: "body"}}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("deleteAnnotations should return %+v but got %+v", expected, got)
+	}
+}
+
+func TestShiftAnnotations(t *testing.T) {
+	for _, testCase := range []struct {
+		offset, n int64
+		expected  [][2]int64
+		moved     bool
+	}{
+		{0x20, 4, [][2]int64{{0x10, 0x1f}, {0x24, 0x33}}, true},
+		{0x28, 4, [][2]int64{{0x10, 0x1f}, {0x20, 0x33}}, true},
+		{0x40, 4, [][2]int64{{0x10, 0x1f}, {0x20, 0x2f}}, false},
+		{0x18, -0x10, [][2]int64{{0x10, 0x17}, {0x18, 0x1f}}, true},
+		{0x20, -0x10, [][2]int64{{0x10, 0x1f}}, true},
+		{0x00, -0x08, [][2]int64{{0x08, 0x17}, {0x18, 0x27}}, true},
+	} {
+		annotations := []state.Annotation{{From: 0x10, To: 0x1f, Note: "a"}, {From: 0x20, To: 0x2f, Note: "b"}}
+		got, moved := shiftAnnotations(annotations, testCase.offset, testCase.n)
+		var ranges [][2]int64
+		for _, a := range got {
+			ranges = append(ranges, [2]int64{a.From, a.To})
+		}
+		if !reflect.DeepEqual(ranges, testCase.expected) || moved != testCase.moved {
+			t.Errorf("shiftAnnotations(%d, %d) should be %v, %v but got %v, %v",
+				testCase.offset, testCase.n, testCase.expected, testCase.moved, ranges, moved)
+		}
+		if annotations[1].From != 0x20 || annotations[1].To != 0x2f {
+			t.Errorf("shiftAnnotations(%d, %d) should not modify the annotations but got %+v",
+				testCase.offset, testCase.n, annotations)
+		}
+	}
+}
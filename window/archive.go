@@ -0,0 +1,210 @@
+package window
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"strconv"
+	"time"
+)
+
+// archiveFormat detects the format of the archive file by the magic bytes.
+func archiveFormat(name string) string {
+	f, err := os.Open(name)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	bs := make([]byte, 512)
+	n, _ := io.ReadFull(f, bs)
+	bs = bs[:n]
+	switch {
+	case bytes.HasPrefix(bs, []byte("PK\x03\x04")), bytes.HasPrefix(bs, []byte("PK\x05\x06")):
+		return "zip"
+	case len(bs) >= 262 && bytes.Equal(bs[257:262], []byte("ustar")):
+		return "tar"
+	}
+	return ""
+}
+
+// splitArchive splits the name like archive.zip:path/inner.bin to the archive
+// file and the entry name, when the archive file exists.
+func splitArchive(name string) (string, string, bool) {
+	for i := 0; i < len(name); i++ {
+		if name[i] != ':' || i+1 == len(name) {
+			continue
+		}
+		if info, err := os.Stat(name[:i]); err == nil && info.Mode().IsRegular() &&
+			archiveFormat(name[:i]) != "" {
+			return name[:i], name[i+1:], true
+		}
+	}
+	return "", "", false
+}
+
+// readArchiveEntry reads the contents of the entry in the archive.
+func readArchiveEntry(archive, entry string) ([]byte, error) {
+	switch archiveFormat(archive) {
+	case "zip":
+		r, err := zip.OpenReader(archive)
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		for _, f := range r.File {
+			if f.Name == entry {
+				rc, err := f.Open()
+				if err != nil {
+					return nil, err
+				}
+				defer rc.Close()
+				return ioutil.ReadAll(rc)
+			}
+		}
+	case "tar":
+		f, err := os.Open(archive)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r := tar.NewReader(f)
+		for {
+			hdr, err := r.Next()
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				return nil, err
+			}
+			if hdr.Name == entry && hdr.Typeflag == tar.TypeReg {
+				return ioutil.ReadAll(r)
+			}
+		}
+	}
+	return nil, fmt.Errorf("%s: entry not found: %s", archive, entry)
+}
+
+// writeArchiveEntry re-packs the archive with the contents of the entry. The
+// other entries are copied without recompression, and the new entry is added
+// at the end when the archive does not contain it.
+func writeArchiveEntry(archive, entry string, contents []byte) error {
+	info, err := os.Stat(archive)
+	if err != nil {
+		return err
+	}
+	tmpf, err := os.OpenFile(
+		archive+"-"+strconv.FormatUint(rand.Uint64(), 16),
+		os.O_RDWR|os.O_CREATE|os.O_EXCL, info.Mode().Perm(),
+	)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpf.Name())
+	switch archiveFormat(archive) {
+	case "zip":
+		err = repackZip(tmpf, archive, entry, contents)
+	case "tar":
+		err = repackTar(tmpf, archive, entry, contents)
+	default:
+		err = errors.New("unknown archive format")
+	}
+	if cerr := tmpf.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmpf.Name(), archive)
+}
+
+func repackZip(dst io.Writer, archive, entry string, contents []byte) error {
+	r, err := zip.OpenReader(archive)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	w := zip.NewWriter(dst)
+	var found bool
+	for _, f := range r.File {
+		if f.Name != entry {
+			if err := w.Copy(f); err != nil {
+				return err
+			}
+			continue
+		}
+		found = true
+		if err := writeZipEntry(w, f.FileHeader, contents); err != nil {
+			return err
+		}
+	}
+	if !found {
+		hdr := zip.FileHeader{Name: entry, Method: zip.Deflate}
+		if err := writeZipEntry(w, hdr, contents); err != nil {
+			return err
+		}
+	}
+	if err := w.SetComment(r.Comment); err != nil {
+		return err
+	}
+	return w.Close()
+}
+
+func writeZipEntry(w *zip.Writer, hdr zip.FileHeader, contents []byte) error {
+	hdr.CRC32, hdr.CompressedSize64, hdr.UncompressedSize64 = 0, 0, 0
+	hdr.CompressedSize, hdr.UncompressedSize = 0, 0
+	hdr.Modified = time.Now()
+	fw, err := w.CreateHeader(&hdr)
+	if err != nil {
+		return err
+	}
+	_, err = fw.Write(contents)
+	return err
+}
+
+func repackTar(dst io.Writer, archive, entry string, contents []byte) error {
+	f, err := os.Open(archive)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	r, w := tar.NewReader(f), tar.NewWriter(dst)
+	var found bool
+	for {
+		hdr, err := r.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		var src io.Reader = r
+		if hdr.Name == entry && hdr.Typeflag == tar.TypeReg {
+			found = true
+			hdr.Size, hdr.ModTime = int64(len(contents)), time.Now()
+			src = bytes.NewReader(contents)
+		}
+		if err := w.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := io.Copy(w, src); err != nil {
+			return err
+		}
+	}
+	if !found {
+		hdr := &tar.Header{
+			Name: entry, Typeflag: tar.TypeReg, Mode: 0644,
+			Size: int64(len(contents)), ModTime: time.Now(),
+		}
+		if err := w.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := w.Write(contents); err != nil {
+			return err
+		}
+	}
+	return w.Close()
+}
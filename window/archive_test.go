@@ -0,0 +1,83 @@
+package window
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func createZip(t *testing.T, files map[string]string) string {
+	f, _ := ioutil.TempFile("", "bed-test-archive-*.zip")
+	defer f.Close()
+	w := zip.NewWriter(f)
+	for _, name := range []string{"a.txt", "dir/b.bin"} {
+		fw, err := w.Create(name)
+		if err != nil {
+			t.Fatalf("err should be nil but got: %v", err)
+		}
+		_, _ = fw.Write([]byte(files[name]))
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("err should be nil but got: %v", err)
+	}
+	return f.Name()
+}
+
+func createTar(t *testing.T, files map[string]string) string {
+	f, _ := ioutil.TempFile("", "bed-test-archive-*.tar")
+	defer f.Close()
+	w := tar.NewWriter(f)
+	for _, name := range []string{"a.txt", "dir/b.bin"} {
+		hdr := &tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(files[name]))}
+		if err := w.WriteHeader(hdr); err != nil {
+			t.Fatalf("err should be nil but got: %v", err)
+		}
+		_, _ = w.Write([]byte(files[name]))
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("err should be nil but got: %v", err)
+	}
+	return f.Name()
+}
+
+func TestArchiveEntry(t *testing.T) {
+	files := map[string]string{"a.txt": "Hello, world!", "dir/b.bin": "\x00\x01\x02\x03"}
+	for _, create := range []func(*testing.T, map[string]string) string{createZip, createTar} {
+		archive := create(t, files)
+		defer os.Remove(archive)
+		name, entry, ok := splitArchive(archive + ":dir/b.bin")
+		if !ok || name != archive || entry != "dir/b.bin" {
+			t.Errorf("splitArchive should split the entry but got: %q, %q, %v", name, entry, ok)
+		}
+		bs, err := readArchiveEntry(archive, entry)
+		if err != nil {
+			t.Errorf("err should be nil but got: %v", err)
+		}
+		if string(bs) != files[entry] {
+			t.Errorf("entry should be %q but got %q", files[entry], string(bs))
+		}
+		if err := writeArchiveEntry(archive, entry, []byte("\xff\xfe")); err != nil {
+			t.Errorf("err should be nil but got: %v", err)
+		}
+		if err := writeArchiveEntry(archive, "c.bin", []byte("new")); err != nil {
+			t.Errorf("err should be nil but got: %v", err)
+		}
+		for entry, expected := range map[string]string{"a.txt": "Hello, world!", "dir/b.bin": "\xff\xfe", "c.bin": "new"} {
+			if bs, err = readArchiveEntry(archive, entry); err != nil {
+				t.Errorf("err should be nil but got: %v", err)
+			}
+			if string(bs) != expected {
+				t.Errorf("entry %s should be %q but got %q", entry, expected, string(bs))
+			}
+		}
+		expected := archive + ": entry not found: d.bin"
+		if _, err = readArchiveEntry(archive, "d.bin"); err == nil || err.Error() != expected {
+			t.Errorf("err should be %q but got: %v", expected, err)
+		}
+	}
+	if _, _, ok := splitArchive("/tmp/not-exists.zip:a.txt"); ok {
+		t.Errorf("splitArchive should not split the name of a file which does not exist")
+	}
+}
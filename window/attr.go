@@ -0,0 +1,29 @@
+package window
+
+import (
+	"os"
+	"time"
+)
+
+// preserveAttrs copies the owner, the extended attributes and optionally the
+// modification time of the original file to the temporary file, which is
+// renamed to the original file on saving.
+func preserveAttrs(tmpname, name string, mtime bool) error {
+	info, err := os.Stat(name)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	if err := copyOwner(tmpname, info); err != nil {
+		return err
+	}
+	if err := copyXattrs(tmpname, name); err != nil {
+		return err
+	}
+	if mtime {
+		return os.Chtimes(tmpname, time.Now(), info.ModTime())
+	}
+	return nil
+}
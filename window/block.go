@@ -0,0 +1,110 @@
+package window
+
+import (
+	"github.com/itchyny/bed/event"
+	"github.com/itchyny/bed/mathutil"
+)
+
+// blockRanges returns the first and the last offsets of the bytes in each row
+// of the block between the offsets, which are the corners of the block.
+func (w *window) blockRanges(from, to int64) [][2]int64 {
+	if from > to {
+		from, to = to, from
+	}
+	left := mathutil.MinInt64(from%w.width, to%w.width)
+	right := mathutil.MaxInt64(from%w.width, to%w.width)
+	var ranges [][2]int64
+	for row := from / w.width * w.width; row <= to; row += w.width {
+		if start, end := row+left, mathutil.MinInt64(row+right, w.length-1); start <= end {
+			ranges = append(ranges, [2]int64{start, end})
+		}
+	}
+	return ranges
+}
+
+// visualRanges returns the ranges of the bytes in the visual selection.
+func (w *window) visualRanges() [][2]int64 {
+	if w.visualBlock {
+		return w.blockRanges(w.visualStart, w.cursor)
+	}
+	from := mathutil.MinInt64(w.cursor, w.visualStart)
+	to := mathutil.MinInt64(mathutil.MaxInt64(w.cursor, w.visualStart), w.length-1)
+	if from > to {
+		return nil
+	}
+	return [][2]int64{{from, to}}
+}
+
+// overwrite replaces the bytes in the ranges with the pattern repeatedly. The
+// pattern restarts at each range when restart is true, otherwise continues to
+// the next range.
+func (w *window) overwrite(ranges [][2]int64, pattern []byte, restart bool) {
+	var i int
+	for _, r := range ranges {
+		if restart {
+			i = 0
+		}
+		for offset := r[0]; offset <= r[1]; offset++ {
+			w.replace(offset, pattern[i%len(pattern)])
+			i++
+		}
+	}
+}
+
+// replaceVisual replaces the visual selection with the bytes of the register,
+// which are repeated to the end of the selection, and exits the visual mode.
+func (w *window) replaceVisual(bs []byte) {
+	if w.visualStart < 0 || len(bs) == 0 {
+		return
+	}
+	ranges := w.visualRanges()
+	w.overwrite(ranges, bs, false)
+	w.visualStart = -1
+	if len(ranges) > 0 {
+		w.cursorGotoPos(event.Absolute{Offset: ranges[0][0]})
+	}
+}
+
+// fill overwrites the bytes of the range with the pattern. The pattern starts
+// at each row of the visual block selection when the range is '<,'>.
+func (w *window) fill(r *event.Range, pattern []byte) {
+	if len(pattern) == 0 || w.length == 0 {
+		return
+	}
+	from, to := w.cursor, w.cursor
+	if r != nil {
+		var err error
+		if from, err = w.positionToOffset(r.From); err != nil {
+			return
+		}
+		to = from
+		if r.To != nil {
+			if to, err = w.positionToOffset(r.To); err != nil {
+				return
+			}
+		}
+	}
+	var ranges [][2]int64
+	if w.visualBlock && w.visualStart >= 0 && isVisualRange(r) {
+		ranges = w.blockRanges(from, to)
+	} else {
+		if from > to {
+			from, to = to, from
+		}
+		if to = mathutil.MinInt64(to, w.length-1); from <= to {
+			ranges = [][2]int64{{from, to}}
+		}
+	}
+	w.overwrite(ranges, pattern, true)
+	w.visualStart = -1
+}
+
+// isVisualRange reports whether the range is of the visual selection.
+func isVisualRange(r *event.Range) bool {
+	if r == nil || r.To == nil {
+		return false
+	}
+	_, from := r.From.(event.VisualStart)
+	_, to := r.To.(event.VisualEnd)
+	return from && to
+}
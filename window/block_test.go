@@ -0,0 +1,77 @@
+package window
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/itchyny/bed/event"
+)
+
+func TestWindowVisualBlock(t *testing.T) {
+	bs := make([]byte, 256)
+	for i := range bs {
+		bs[i] = byte(i)
+	}
+	window, err := newWindow(bytes.NewReader(bs), "test", "test", make(chan struct{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	window.setSize(16, 10)
+
+	window.cursor = 0x35
+	window.startVisual(true)
+	window.cursor = 0x12
+	if expected := [][2]int64{{0x12, 0x15}, {0x22, 0x25}, {0x32, 0x35}}; !reflect.DeepEqual(window.visualRanges(), expected) {
+		t.Errorf("visual ranges should be %v but got %v", expected, window.visualRanges())
+	}
+	s, err := window.selection()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Rows != 3 || s.Columns != 4 || s.Sum != 0x1aa || !s.Checksummed {
+		t.Errorf("selection should be 3x4 block with sum 0x1aa but got %+v", s)
+	}
+
+	got, err := window.yank(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []byte{0x12, 0x13, 0x14, 0x15, 0x22, 0x23, 0x24, 0x25, 0x32, 0x33, 0x34, 0x35}; !bytes.Equal(got, expected) {
+		t.Errorf("yanked bytes should be %x but got %x", expected, got)
+	}
+	if window.visualStart != -1 || window.cursor != 0x12 {
+		t.Errorf("visual mode should be exited and cursor should be 0x12 but got %d, %d", window.visualStart, window.cursor)
+	}
+
+	window.cursor = 0x40
+	window.startVisual(true)
+	window.cursor = 0x62
+	window.replaceVisual([]byte{0xaa, 0xbb})
+	window.cursor = 0x86
+	window.startVisual(true)
+	window.cursor = 0x99
+	window.fill(&event.Range{From: event.VisualStart{}, To: event.VisualEnd{}}, []byte{0xde, 0xad})
+	if window.visualStart != -1 {
+		t.Errorf("visual mode should be exited but got %d", window.visualStart)
+	}
+	window.fill(&event.Range{From: event.Absolute{Offset: 0xfe}, To: event.Absolute{Offset: 0x10f}}, []byte{0x00})
+	window.cursor = 0xa0
+	window.fill(nil, []byte{0xff})
+
+	got, err = window.readFull(0, window.length)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := append([]byte(nil), bs...)
+	copy(expected[0x40:], []byte{0xaa, 0xbb, 0xaa})
+	copy(expected[0x50:], []byte{0xbb, 0xaa, 0xbb})
+	copy(expected[0x60:], []byte{0xaa, 0xbb, 0xaa})
+	copy(expected[0x86:], []byte{0xde, 0xad, 0xde, 0xad})
+	copy(expected[0x96:], []byte{0xde, 0xad, 0xde, 0xad})
+	copy(expected[0xa0:], []byte{0xff})
+	copy(expected[0xfe:], []byte{0x00, 0x00})
+	if !bytes.Equal(got, expected) {
+		t.Errorf("bytes should be\n%x\nbut got\n%x", expected, got)
+	}
+}
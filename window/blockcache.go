@@ -0,0 +1,195 @@
+package window
+
+import (
+	"container/list"
+	"io"
+	"sync"
+)
+
+// blockCache reads the remote file by the blocks, and keeps the recently read
+// blocks so that browsing does not download the whole file.
+type blockCache struct {
+	blockSize int64
+	capacity  int
+	size      int64
+	blocks    map[int64]*list.Element
+	lru       *list.List
+	fetch     func(from, to int64) ([]byte, error)
+	fetching  map[int64]bool
+	updated   uint64
+}
+
+type cachedBlock struct {
+	index int64
+	bytes []byte
+}
+
+func newBlockCache(blockSize int64, capacity int, size int64,
+	fetch func(from, to int64) ([]byte, error)) *blockCache {
+	return &blockCache{
+		blockSize: blockSize, capacity: capacity, size: size,
+		blocks: make(map[int64]*list.Element), lru: list.New(), fetch: fetch,
+		fetching: make(map[int64]bool),
+	}
+}
+
+// block returns the block of the index from the cache, or fetches it.
+func (c *blockCache) block(index int64) ([]byte, error) {
+	if e, ok := c.blocks[index]; ok {
+		c.lru.MoveToFront(e)
+		return e.Value.(*cachedBlock).bytes, nil
+	}
+	from := index * c.blockSize
+	to := from + c.blockSize
+	if to > c.size {
+		to = c.size
+	}
+	bs, err := c.fetch(from, to)
+	if err != nil {
+		return nil, err
+	}
+	c.add(index, bs)
+	return bs, nil
+}
+
+func (c *blockCache) add(index int64, bs []byte) {
+	c.blocks[index] = c.lru.PushFront(&cachedBlock{index, bs})
+	if c.lru.Len() > c.capacity {
+		e := c.lru.Back()
+		delete(c.blocks, e.Value.(*cachedBlock).index)
+		c.lru.Remove(e)
+	}
+}
+
+// prefetcher is the reader which reads the blocks into the cache ahead.
+type prefetcher interface {
+	prefetch(from, to int64)
+}
+
+// prefetch fetches the blocks of the range which are not cached yet in the
+// background. The mutex guards the cache, and is not held while fetching so
+// that reading the cached blocks does not wait for the prefetch. The fetched
+// blocks are dropped when the cache is updated meanwhile.
+func (c *blockCache) prefetch(mu *sync.Mutex, from, to int64) {
+	mu.Lock()
+	var indices []int64
+	for i := from / c.blockSize; i*c.blockSize < to && i*c.blockSize < c.size; i++ {
+		if _, ok := c.blocks[i]; !ok && !c.fetching[i] {
+			c.fetching[i] = true
+			indices = append(indices, i)
+		}
+	}
+	updated := c.updated
+	mu.Unlock()
+	if len(indices) == 0 {
+		return
+	}
+	go func() {
+		for _, i := range indices {
+			from := i * c.blockSize
+			to := from + c.blockSize
+			if to > c.size {
+				to = c.size
+			}
+			bs, err := c.fetch(from, to)
+			mu.Lock()
+			delete(c.fetching, i)
+			if _, ok := c.blocks[i]; err == nil && !ok && c.updated == updated {
+				c.add(i, bs)
+			}
+			mu.Unlock()
+		}
+	}()
+}
+
+// ReadAt reads the bytes from the blocks which cover the range.
+func (c *blockCache) ReadAt(p []byte, offset int64) (int, error) {
+	var n int
+	for n < len(p) {
+		if offset >= c.size {
+			return n, io.EOF
+		}
+		bs, err := c.block(offset / c.blockSize)
+		if err != nil {
+			return n, err
+		}
+		if int64(len(bs)) <= offset%c.blockSize {
+			return n, io.EOF // the file is truncated after opening
+		}
+		k := copy(p[n:], bs[offset%c.blockSize:])
+		n += k
+		offset += int64(k)
+	}
+	return n, nil
+}
+
+// readAt reads the bytes like ReadAt, but holds the mutex which guards the
+// cache only while accessing the blocks, not while fetching them. The fetched
+// block is fetched again when the cache is updated meanwhile.
+func (c *blockCache) readAt(mu *sync.Mutex, p []byte, offset int64) (int, error) {
+	var n int
+	for n < len(p) {
+		if offset >= c.size {
+			return n, io.EOF
+		}
+		index := offset / c.blockSize
+		mu.Lock()
+		e, ok := c.blocks[index]
+		if !ok {
+			updated := c.updated
+			mu.Unlock()
+			from := index * c.blockSize
+			to := from + c.blockSize
+			if to > c.size {
+				to = c.size
+			}
+			bs, err := c.fetch(from, to)
+			if err != nil {
+				return n, err
+			}
+			mu.Lock()
+			if e, ok = c.blocks[index]; !ok {
+				if c.updated != updated {
+					mu.Unlock()
+					continue
+				}
+				c.add(index, bs)
+				e = c.blocks[index]
+			}
+		}
+		c.lru.MoveToFront(e)
+		bs := e.Value.(*cachedBlock).bytes
+		if int64(len(bs)) <= offset%c.blockSize {
+			mu.Unlock()
+			return n, io.EOF // the file is truncated after opening
+		}
+		k := copy(p[n:], bs[offset%c.blockSize:])
+		mu.Unlock()
+		n += k
+		offset += int64(k)
+	}
+	return n, nil
+}
+
+// update the cached blocks with the bytes written to the file.
+func (c *blockCache) update(p []byte, offset int64) {
+	c.updated++
+	for _, e := range c.blocks {
+		b := e.Value.(*cachedBlock)
+		from := b.index * c.blockSize
+		if from < offset+int64(len(p)) && offset < from+int64(len(b.bytes)) {
+			if offset >= from {
+				copy(b.bytes[offset-from:], p)
+			} else {
+				copy(b.bytes, p[from-offset:])
+			}
+		}
+	}
+}
+
+// clear the cached blocks.
+func (c *blockCache) clear() {
+	c.updated++
+	c.blocks = make(map[int64]*list.Element)
+	c.lru.Init()
+}
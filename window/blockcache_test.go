@@ -0,0 +1,44 @@
+package window
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBlockCachePrefetch(t *testing.T) {
+	var mu sync.Mutex
+	var fetched [][2]int64
+	c := newBlockCache(16, 8, 100, func(from, to int64) ([]byte, error) {
+		fetched = append(fetched, [2]int64{from, to})
+		return make([]byte, to-from), nil
+	})
+	p := make([]byte, 4)
+	mu.Lock()
+	if _, err := c.ReadAt(p, 20); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	mu.Unlock()
+	c.prefetch(&mu, 10, 200)
+	for i := 0; ; i++ {
+		mu.Lock()
+		n := len(c.blocks)
+		mu.Unlock()
+		if n == 7 {
+			break
+		} else if i == 100 {
+			t.Fatalf("blocks should be prefetched but got %d blocks", n)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	expected := [][2]int64{{16, 32}, {0, 16}, {32, 48}, {48, 64}, {64, 80}, {80, 96}, {96, 100}}
+	if !reflect.DeepEqual(fetched, expected) {
+		t.Errorf("fetched ranges should be %v but got %v", expected, fetched)
+	}
+	if len(c.fetching) != 0 {
+		t.Errorf("fetching blocks should be empty but got %v", c.fetching)
+	}
+}
@@ -0,0 +1,76 @@
+package window
+
+import (
+	"fmt"
+	"sort"
+)
+
+// bookmark is a labeled offset which survives restarts.
+type bookmark struct {
+	Label  string `json:"label"`
+	Offset int64  `json:"offset"`
+}
+
+func bookmarksPath(filename string) string {
+	return filename + ".bedmarks"
+}
+
+func loadBookmarks(filename string) ([]bookmark, error) {
+	var bookmarks []bookmark
+	if err := loadSidecar(bookmarksPath(filename), &bookmarks); err != nil {
+		return nil, fmt.Errorf("%s: %s", bookmarksPath(filename), err)
+	}
+	return bookmarks, nil
+}
+
+func saveBookmarks(filename string, bookmarks []bookmark) error {
+	return saveSidecar(bookmarksPath(filename), bookmarks, len(bookmarks) == 0)
+}
+
+func addBookmark(bookmarks []bookmark, label string, offset int64) []bookmark {
+	for i, b := range bookmarks {
+		if b.Label == label {
+			bookmarks = append(bookmarks[:i], bookmarks[i+1:]...)
+			break
+		}
+	}
+	bookmarks = append(bookmarks, bookmark{label, offset})
+	sort.SliceStable(bookmarks, func(i, j int) bool {
+		return bookmarks[i].Offset < bookmarks[j].Offset
+	})
+	return bookmarks
+}
+
+// shiftBookmarks moves the bookmarks after the offset by the bytes inserted at
+// the offset, or deleted from the offset when n is negative. The bookmarks in
+// the deleted bytes move to the offset. It reports whether any bookmark moves.
+func shiftBookmarks(bookmarks []bookmark, offset, n int64) bool {
+	var moved bool
+	for i, b := range bookmarks {
+		if o := shiftOffset(b.Offset, offset, n); o != b.Offset {
+			bookmarks[i].Offset, moved = o, true
+		}
+	}
+	return moved
+}
+
+// shiftOffset returns the offset moved by the bytes inserted at the position,
+// or deleted from the position when n is negative.
+func shiftOffset(x, offset, n int64) int64 {
+	if x < offset {
+		return x
+	}
+	if n < 0 && x < offset-n {
+		return offset
+	}
+	return x + n
+}
+
+func findBookmark(bookmarks []bookmark, label string) int {
+	for i, b := range bookmarks {
+		if b.Label == label {
+			return i
+		}
+	}
+	return -1
+}
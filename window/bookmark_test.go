@@ -0,0 +1,32 @@
+package window
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestShiftBookmarks(t *testing.T) {
+	for _, testCase := range []struct {
+		offset, n int64
+		expected  []int64
+		moved     bool
+	}{
+		{0x20, 4, []int64{0x10, 0x24, 0x34}, true},
+		{0x21, 4, []int64{0x10, 0x20, 0x34}, true},
+		{0x40, 4, []int64{0x10, 0x20, 0x30}, false},
+		{0x18, -0x10, []int64{0x10, 0x18, 0x20}, true},
+		{0x20, -0x10, []int64{0x10, 0x20, 0x20}, true},
+		{0x00, -0x08, []int64{0x08, 0x18, 0x28}, true},
+	} {
+		bookmarks := []bookmark{{"a", 0x10}, {"b", 0x20}, {"c", 0x30}}
+		moved := shiftBookmarks(bookmarks, testCase.offset, testCase.n)
+		var got []int64
+		for _, b := range bookmarks {
+			got = append(got, b.Offset)
+		}
+		if !reflect.DeepEqual(got, testCase.expected) || moved != testCase.moved {
+			t.Errorf("shiftBookmarks(%d, %d) should be %v, %v but got %v, %v",
+				testCase.offset, testCase.n, testCase.expected, testCase.moved, got, moved)
+		}
+	}
+}
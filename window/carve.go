@@ -0,0 +1,345 @@
+package window
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/mitchellh/go-homedir"
+
+	"github.com/itchyny/bed/event"
+)
+
+// carving is the file to be carved from the buffer.
+type carving struct {
+	offset int64
+	size   int64
+	sig    *signature
+}
+
+// carvings detects the sizes of the files of the signatures in the buffer.
+// The file of unknown size ends at the next signature, and the signatures in
+// the carved files are skipped.
+func (w *window) carvings() ([]carving, error) {
+	matches, err := w.scanSignatures()
+	if err != nil {
+		return nil, err
+	}
+	var cs []carving
+	var end int64
+	for i, m := range matches {
+		if m.offset < end {
+			continue
+		}
+		size, ok := int64(0), false
+		if m.sig.size != nil {
+			size, ok = m.sig.size(w, m.offset)
+		}
+		if !ok || size <= 0 {
+			size = w.length - m.offset
+			if i+1 < len(matches) {
+				size = matches[i+1].offset - m.offset
+			}
+		}
+		if m.offset+size > w.length {
+			size = w.length - m.offset
+		}
+		cs = append(cs, carving{m.offset, size, m.sig})
+		end = m.offset + size
+	}
+	return cs, nil
+}
+
+func (m *Manager) carve(e event.Event) error {
+	if e.Arg == "" {
+		return fmt.Errorf("an argument is required for %s", e.CmdName)
+	}
+	dir, err := homedir.Expand(e.Arg)
+	if err != nil {
+		return err
+	}
+	window := m.windows[m.windowIndex]
+	waitWindow(window)
+	window.mu.Lock()
+	items, err := window.carve(dir)
+	window.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if len(items) == 0 {
+		return errors.New("no files to carve")
+	}
+	m.setQuickfix(window, items)
+	return m.listQuickfix(event.Event{})
+}
+
+// carve writes the files detected in the buffer to the directory, which are
+// named by the offsets and the extensions.
+func (w *window) carve(dir string) ([]quickfixItem, error) {
+	cs, err := w.carvings()
+	if err != nil || len(cs) == 0 {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	items := make([]quickfixItem, len(cs))
+	for i, c := range cs {
+		path := filepath.Join(dir, fmt.Sprintf("%08x.%s", c.offset, c.sig.ext))
+		if err := w.writeRange(path, c.offset, c.size); err != nil {
+			return nil, err
+		}
+		items[i] = quickfixItem{c.offset, c.size, fmt.Sprintf("%s, %d bytes: %s", c.sig.name, c.size, path)}
+	}
+	return items, nil
+}
+
+// writeRange writes the bytes of the range to the file.
+func (w *window) writeRange(path string, offset, size int64) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(f, io.NewSectionReader(w.buffer, offset, size)); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// indexAfter returns the offset of the target after the offset, or -1.
+func (w *window) indexAfter(offset int64, target string) int64 {
+	bs := make([]byte, 64*1024+len(target)-1)
+	for base := offset; base < w.length; base += int64(len(bs) - len(target) + 1) {
+		n, err := w.buffer.ReadAt(bs, base)
+		if err != nil && err != io.EOF {
+			return -1
+		}
+		if i := bytes.Index(bs[:n], []byte(target)); i >= 0 {
+			return base + int64(i)
+		}
+		if n < len(bs) {
+			break
+		}
+	}
+	return -1
+}
+
+// sizePNG walks the chunks to the IEND chunk.
+func sizePNG(w *window, start int64) (int64, bool) {
+	for offset := start + 8; ; {
+		bs, err := w.readFull(offset, 8)
+		if err != nil {
+			return 0, false
+		}
+		offset += 12 + int64(binary.BigEndian.Uint32(bs))
+		if string(bs[4:]) == "IEND" {
+			return offset - start, true
+		}
+	}
+}
+
+// sizeJPEG walks the segments to the start of scan, and finds the end of
+// image marker after the entropy-coded data.
+func sizeJPEG(w *window, start int64) (int64, bool) {
+	for offset := start + 2; ; {
+		bs, err := w.readFull(offset, 4)
+		if err != nil || bs[0] != 0xff {
+			return 0, false
+		}
+		switch {
+		case bs[1] == 0xd9:
+			return offset + 2 - start, true
+		case bs[1] == 0x01, 0xd0 <= bs[1] && bs[1] <= 0xd7:
+			offset += 2
+			continue
+		case bs[1] == 0xda:
+			if end := w.indexAfter(offset+2, "\xff\xd9"); end >= 0 {
+				return end + 2 - start, true
+			}
+			return 0, false
+		}
+		offset += 2 + int64(binary.BigEndian.Uint16(bs[2:]))
+	}
+}
+
+// sizeBMP reads the size in the header.
+func sizeBMP(w *window, start int64) (int64, bool) {
+	bs, err := w.readFull(start+2, 4)
+	if err != nil {
+		return 0, false
+	}
+	return int64(binary.LittleEndian.Uint32(bs)), true
+}
+
+// sizeRIFF reads the size of the RIFF chunk.
+func sizeRIFF(w *window, start int64) (int64, bool) {
+	bs, err := w.readFull(start+4, 4)
+	if err != nil {
+		return 0, false
+	}
+	return 8 + int64(binary.LittleEndian.Uint32(bs)), true
+}
+
+// sizePDF finds the end-of-file marker and the following newline.
+func sizePDF(w *window, start int64) (int64, bool) {
+	end := w.indexAfter(start, "%%EOF")
+	if end < 0 {
+		return 0, false
+	}
+	end += 5
+	if bs, err := w.readFull(end, 2); err == nil && string(bs) == "\r\n" {
+		end += 2
+	} else if err == nil && (bs[0] == '\n' || bs[0] == '\r') {
+		end++
+	}
+	return end - start, true
+}
+
+// sizeZip finds the end of central directory record and the comment.
+func sizeZip(w *window, start int64) (int64, bool) {
+	end := w.indexAfter(start, "PK\x05\x06")
+	if end < 0 {
+		return 0, false
+	}
+	bs, err := w.readFull(end, 22)
+	if err != nil {
+		return 0, false
+	}
+	return end + 22 + int64(binary.LittleEndian.Uint16(bs[20:])) - start, true
+}
+
+// byteCounter counts the bytes read by the decompressor. This implements the
+// io.ByteReader so that the decompressor does not read ahead.
+type byteCounter struct {
+	*bufio.Reader
+	n int64
+}
+
+func (c *byteCounter) Read(p []byte) (int, error) {
+	n, err := c.Reader.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+func (c *byteCounter) ReadByte() (byte, error) {
+	b, err := c.Reader.ReadByte()
+	if err == nil {
+		c.n++
+	}
+	return b, err
+}
+
+// sizeGzip decompresses the gzip member to find the end.
+func sizeGzip(w *window, start int64) (int64, bool) {
+	c := &byteCounter{Reader: bufio.NewReader(io.NewSectionReader(w.buffer, start, w.length-start))}
+	r, err := gzip.NewReader(c)
+	if err != nil {
+		return 0, false
+	}
+	r.Multistream(false)
+	if _, err := io.Copy(ioutil.Discard, r); err != nil {
+		return 0, false
+	}
+	return c.n, true
+}
+
+// sizeZlib decompresses the zlib stream to find the end.
+func sizeZlib(w *window, start int64) (int64, bool) {
+	c := &byteCounter{Reader: bufio.NewReader(io.NewSectionReader(w.buffer, start, w.length-start))}
+	r, err := zlib.NewReader(c)
+	if err != nil {
+		return 0, false
+	}
+	if _, err := io.Copy(ioutil.Discard, r); err != nil {
+		return 0, false
+	}
+	return c.n, true
+}
+
+// sizeELF finds the end of the section headers and the segments.
+func sizeELF(w *window, start int64) (int64, bool) {
+	bs, err := w.readFull(start, 64)
+	if err != nil {
+		return 0, false
+	}
+	var order binary.ByteOrder = binary.LittleEndian
+	if bs[5] == 2 {
+		order = binary.BigEndian
+	}
+	var phoff, shoff int64
+	var phentsize, phnum, shentsize, shnum int64
+	if bs[4] == 1 {
+		phoff, shoff = int64(order.Uint32(bs[28:])), int64(order.Uint32(bs[32:]))
+		phentsize, phnum = int64(order.Uint16(bs[42:])), int64(order.Uint16(bs[44:]))
+		shentsize, shnum = int64(order.Uint16(bs[46:])), int64(order.Uint16(bs[48:]))
+	} else {
+		phoff, shoff = int64(order.Uint64(bs[32:])), int64(order.Uint64(bs[40:]))
+		phentsize, phnum = int64(order.Uint16(bs[54:])), int64(order.Uint16(bs[56:]))
+		shentsize, shnum = int64(order.Uint16(bs[58:])), int64(order.Uint16(bs[60:]))
+	}
+	size := shoff + shentsize*shnum
+	for i := int64(0); i < phnum; i++ {
+		ph, err := w.readFull(start+phoff+i*phentsize, phentsize)
+		if err != nil {
+			return 0, false
+		}
+		var end int64
+		if bs[4] == 1 && len(ph) >= 20 {
+			end = int64(order.Uint32(ph[4:])) + int64(order.Uint32(ph[16:]))
+		} else if len(ph) >= 40 {
+			end = int64(order.Uint64(ph[8:])) + int64(order.Uint64(ph[32:]))
+		}
+		if end > size {
+			size = end
+		}
+	}
+	return size, true
+}
+
+// sizePE finds the end of the raw data of the sections.
+func sizePE(w *window, start int64) (int64, bool) {
+	bs, err := w.readFull(start+0x3c, 4)
+	if err != nil {
+		return 0, false
+	}
+	pe := int64(binary.LittleEndian.Uint32(bs))
+	if bs, err = w.readFull(start+pe, 24); err != nil {
+		return 0, false
+	}
+	count := int64(binary.LittleEndian.Uint16(bs[6:]))
+	table := pe + 24 + int64(binary.LittleEndian.Uint16(bs[20:]))
+	size := table + 40*count
+	for i := int64(0); i < count; i++ {
+		sh, err := w.readFull(start+table+40*i, 40)
+		if err != nil {
+			return 0, false
+		}
+		if end := int64(binary.LittleEndian.Uint32(sh[20:])) +
+			int64(binary.LittleEndian.Uint32(sh[16:])); end > size {
+			size = end
+		}
+	}
+	return size, true
+}
+
+// sizeSQLite computes the size from the page size and the number of pages.
+func sizeSQLite(w *window, start int64) (int64, bool) {
+	bs, err := w.readFull(start, 32)
+	if err != nil {
+		return 0, false
+	}
+	pageSize := int64(binary.BigEndian.Uint16(bs[16:]))
+	if pageSize == 1 {
+		pageSize = 65536
+	}
+	return pageSize * int64(binary.BigEndian.Uint32(bs[28:])), true
+}
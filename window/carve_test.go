@@ -0,0 +1,72 @@
+package window
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestWindowCarve(t *testing.T) {
+	png := "\x89PNG\r\n\x1a\n" +
+		"\x00\x00\x00\x0dIHDR\x00\x00\x00\x01\x00\x00\x00\x01\x08\x00\x00\x00\x00\x3a\x7e\x9b\x55" +
+		"\x00\x00\x00\x00IEND\xae\x42\x60\x82"
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	if _, err := zw.Write(bytes.Repeat([]byte("hello, world\n"), 100)); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	wav := "RIFF\x0c\x00\x00\x00WAVEdata\x00\x00\x00\x00"
+	var b bytes.Buffer
+	b.WriteString("\x01\x02\x03\x04")
+	b.WriteString(png)
+	b.WriteString("\xff\xff")
+	b.Write(gz.Bytes())
+	b.WriteString(wav)
+	b.WriteString("\x00\x00")
+	window, err := newWindow(bytes.NewReader(b.Bytes()), "test", "test", make(chan struct{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err := ioutil.TempDir("", "bed-test-window-carve")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	items, err := window.carve(filepath.Join(dir, "carved"))
+	if err != nil {
+		t.Fatalf("err should be nil but got: %v", err)
+	}
+	gzStart, wavStart := int64(4+len(png)+2), int64(4+len(png)+2+gz.Len())
+	expected := []quickfixItem{
+		{4, int64(len(png)), fmt.Sprintf("PNG image, %d bytes: %s", len(png),
+			filepath.Join(dir, "carved", "00000004.png"))},
+		{gzStart, int64(gz.Len()), fmt.Sprintf("gzip compressed data, %d bytes: %s", gz.Len(),
+			filepath.Join(dir, "carved", fmt.Sprintf("%08x.gz", gzStart)))},
+		{wavStart, int64(len(wav)), fmt.Sprintf("RIFF WAVE audio, %d bytes: %s", len(wav),
+			filepath.Join(dir, "carved", fmt.Sprintf("%08x.wav", wavStart)))},
+	}
+	if !reflect.DeepEqual(items, expected) {
+		t.Errorf("items should be %+v but got %+v", expected, items)
+	}
+	for path, expected := range map[string][]byte{
+		"00000004.png":                    []byte(png),
+		fmt.Sprintf("%08x.gz", gzStart):   gz.Bytes(),
+		fmt.Sprintf("%08x.wav", wavStart): []byte(wav),
+	} {
+		bs, err := ioutil.ReadFile(filepath.Join(dir, "carved", path))
+		if err != nil {
+			t.Errorf("err should be nil but got: %v", err)
+		} else if !bytes.Equal(bs, expected) {
+			t.Errorf("%s should be %q but got %q", path, expected, bs)
+		}
+	}
+}
@@ -0,0 +1,306 @@
+package window
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"strings"
+
+	"github.com/itchyny/bed/event"
+)
+
+// patch is the bytes to be written at the offset.
+type patch struct {
+	offset int64
+	bytes  []byte
+}
+
+// fixChecksum recomputes the checksum of the structure at the cursor, or of
+// the range, and writes it to the buffer. The kind is one of png (the CRC of
+// the PNG chunk), zip (the CRC of the zip entry), ip (the IPv4 header, TCP and
+// UDP checksums of the packet) or sum8 (the last byte of the range to make
+// the 8-bit sum zero).
+func (m *Manager) fixChecksum(e event.Event) error {
+	args := strings.Fields(e.Arg)
+	if len(args) == 0 {
+		return fmt.Errorf("an argument is required for %s", e.CmdName)
+	}
+	if len(args) > 1 {
+		return fmt.Errorf("too many arguments for %s", e.CmdName)
+	}
+	window := m.windows[m.windowIndex]
+	waitWindow(window)
+	window.mu.Lock()
+	msg, err := window.fixChecksum(args[0], e.Range)
+	window.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	m.eventCh <- event.Event{Type: event.Info, Error: errors.New(msg)}
+	return nil
+}
+
+func (w *window) fixChecksum(kind string, r *event.Range) (string, error) {
+	var ps []patch
+	var msg string
+	var err error
+	switch kind {
+	case "png":
+		ps, msg, err = w.pngChecksum()
+	case "zip":
+		ps, msg, err = w.zipChecksum()
+	case "ip":
+		ps, msg, err = w.ipChecksum()
+	case "sum8":
+		ps, msg, err = w.sum8Checksum(r)
+	default:
+		return "", fmt.Errorf("unknown checksum: %s", kind)
+	}
+	if err != nil {
+		return "", err
+	}
+	for _, p := range ps {
+		for i, b := range p.bytes {
+			w.replace(p.offset+int64(i), b)
+		}
+	}
+	w.pushHistory(w.offset, w.cursor)
+	w.changedSwap()
+	return msg, nil
+}
+
+// readFull reads the bytes at the offset, or returns an error when the buffer
+// ends before the length.
+func (w *window) readFull(offset, length int64) ([]byte, error) {
+	if offset < 0 || length < 0 || offset+length > w.length {
+		return nil, errors.New("unexpected end of the buffer")
+	}
+	bs := make([]byte, length)
+	if _, err := w.buffer.ReadAt(bs, offset); err != nil && err != io.EOF {
+		return nil, err
+	}
+	return bs, nil
+}
+
+// pngChecksum computes the CRC of the PNG chunk at the cursor.
+func (w *window) pngChecksum() ([]patch, string, error) {
+	if bs, err := w.readFull(0, 8); err != nil || string(bs) != "\x89PNG\r\n\x1a\n" {
+		return nil, "", errors.New("not a PNG file")
+	}
+	for offset := int64(8); offset+12 <= w.length; {
+		bs, err := w.readFull(offset, 8)
+		if err != nil {
+			return nil, "", err
+		}
+		length := int64(binary.BigEndian.Uint32(bs))
+		if offset+12+length > w.length {
+			return nil, "", fmt.Errorf("broken PNG chunk at 0x%x", offset)
+		}
+		if w.cursor < offset+12+length {
+			if w.cursor < offset {
+				break
+			}
+			data, err := w.readFull(offset+4, 4+length)
+			if err != nil {
+				return nil, "", err
+			}
+			crc := make([]byte, 4)
+			binary.BigEndian.PutUint32(crc, crc32.ChecksumIEEE(data))
+			return []patch{{offset + 8 + length, crc}},
+				fmt.Sprintf("fixed the CRC of the %s chunk: 0x%x", bs[4:], crc), nil
+		}
+		offset += 12 + length
+	}
+	return nil, "", errors.New("no PNG chunk at the cursor")
+}
+
+// zipChecksum computes the CRC of the zip entry at the cursor, and writes it
+// to the local file header, the data descriptor and the central directory.
+func (w *window) zipChecksum() ([]patch, string, error) {
+	// the end of central directory record is at most 64KiB before the end
+	size := w.length
+	if size > 65535+22 {
+		size = 65535 + 22
+	}
+	bs, err := w.readFull(w.length-size, size)
+	if err != nil {
+		return nil, "", err
+	}
+	i := bytes.LastIndex(bs, []byte("PK\x05\x06"))
+	if i < 0 || len(bs)-i < 22 {
+		return nil, "", errors.New("not a zip file")
+	}
+	count := int(binary.LittleEndian.Uint16(bs[i+10:]))
+	offset := int64(binary.LittleEndian.Uint32(bs[i+16:]))
+	for ; count > 0; count-- {
+		cd, err := w.readFull(offset, 46)
+		if err != nil || string(cd[:4]) != "PK\x01\x02" {
+			return nil, "", fmt.Errorf("broken central directory at 0x%x", offset)
+		}
+		flags := binary.LittleEndian.Uint16(cd[8:])
+		method := binary.LittleEndian.Uint16(cd[10:])
+		compressed := int64(binary.LittleEndian.Uint32(cd[20:]))
+		nameLen := int64(binary.LittleEndian.Uint16(cd[28:]))
+		local := int64(binary.LittleEndian.Uint32(cd[42:]))
+		lh, err := w.readFull(local, 30)
+		if err != nil || string(lh[:4]) != "PK\x03\x04" {
+			return nil, "", fmt.Errorf("broken local file header at 0x%x", local)
+		}
+		start := local + 30 + int64(binary.LittleEndian.Uint16(lh[26:])) +
+			int64(binary.LittleEndian.Uint16(lh[28:]))
+		if local <= w.cursor && w.cursor < start+compressed {
+			if compressed == 0xffffffff {
+				return nil, "", errors.New("zip64 is not supported")
+			}
+			name, err := w.readFull(offset+46, nameLen)
+			if err != nil {
+				return nil, "", err
+			}
+			var r io.Reader = io.NewSectionReader(w.buffer, start, compressed)
+			switch method {
+			case 0:
+			case 8:
+				r = flate.NewReader(r)
+			default:
+				return nil, "", fmt.Errorf("unsupported compression method: %d", method)
+			}
+			h := crc32.NewIEEE()
+			if _, err := io.Copy(h, r); err != nil {
+				return nil, "", fmt.Errorf("%s: %s", name, err)
+			}
+			crc := make([]byte, 4)
+			binary.LittleEndian.PutUint32(crc, h.Sum32())
+			ps := []patch{{local + 14, crc}, {offset + 16, crc}}
+			if flags&0x08 != 0 {
+				// the data descriptor follows the data, with an optional signature
+				dd := start + compressed
+				if sig, err := w.readFull(dd, 4); err == nil && string(sig) == "PK\x07\x08" {
+					dd += 4
+				}
+				ps = append(ps, patch{dd, crc})
+			}
+			return ps, fmt.Sprintf("fixed the CRC of %s: 0x%08x", name, h.Sum32()), nil
+		}
+		offset += 46 + nameLen + int64(binary.LittleEndian.Uint16(cd[30:])) +
+			int64(binary.LittleEndian.Uint16(cd[32:]))
+	}
+	return nil, "", errors.New("no zip entry at the cursor")
+}
+
+// ipChecksum computes the checksums of the IPv4 packet. In a pcap file, the
+// packet is of the record at the cursor, otherwise the packet starts at the
+// cursor.
+func (w *window) ipChecksum() ([]patch, string, error) {
+	start, end := w.cursor, w.length
+	if p, err := w.pcap(); err == nil {
+		rec, ok, err := p.recordAt(w.cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		if !ok {
+			return nil, "", errors.New("no packet at the cursor")
+		}
+		if start, err = rec.network(); err != nil {
+			return nil, "", err
+		}
+		end = rec.data + rec.captured
+	}
+	hdr, err := w.readFull(start, 20)
+	if err != nil || hdr[0]>>4 != 4 {
+		return nil, "", errors.New("not an IPv4 packet")
+	}
+	ihl := int64(hdr[0]&0x0f) * 4
+	total := int64(binary.BigEndian.Uint16(hdr[2:]))
+	if ihl < 20 || total < ihl {
+		return nil, "", errors.New("broken IPv4 header")
+	}
+	if hdr, err = w.readFull(start, ihl); err != nil {
+		return nil, "", err
+	}
+	hdr[10], hdr[11] = 0, 0
+	sum := make([]byte, 2)
+	binary.BigEndian.PutUint16(sum, internetChecksum(0, hdr))
+	ps := []patch{{start + 10, sum}}
+	msg := fmt.Sprintf("fixed the IPv4 header checksum: 0x%x", sum)
+	var name string
+	var field int64
+	switch hdr[9] {
+	case 6:
+		name, field = "TCP", 16
+	case 17:
+		name, field = "UDP", 6
+	default:
+		return ps, msg, nil
+	}
+	if start+total > end || total-ihl < field+2 {
+		return ps, msg + fmt.Sprintf(" (the %s segment is truncated)", name), nil
+	}
+	segment, err := w.readFull(start+ihl, total-ihl)
+	if err != nil {
+		return nil, "", err
+	}
+	segment[field], segment[field+1] = 0, 0
+	pseudo := append(append([]byte(nil), hdr[12:20]...), 0, hdr[9], 0, 0)
+	binary.BigEndian.PutUint16(pseudo[10:], uint16(len(segment)))
+	s := internetChecksum(internetSum(0, pseudo), segment)
+	if s == 0 && name == "UDP" {
+		s = 0xffff
+	}
+	sum = make([]byte, 2)
+	binary.BigEndian.PutUint16(sum, s)
+	ps = append(ps, patch{start + ihl + field, sum})
+	return ps, msg + fmt.Sprintf(" and the %s checksum: 0x%x", name, sum), nil
+}
+
+// internetSum adds the bytes as the 16-bit big-endian words to the sum.
+func internetSum(sum uint32, bs []byte) uint32 {
+	for i := 0; i+1 < len(bs); i += 2 {
+		sum += uint32(bs[i])<<8 | uint32(bs[i+1])
+	}
+	if len(bs)%2 == 1 {
+		sum += uint32(bs[len(bs)-1]) << 8
+	}
+	return sum
+}
+
+// internetChecksum returns the checksum of RFC 1071.
+func internetChecksum(sum uint32, bs []byte) uint16 {
+	sum = internetSum(sum, bs)
+	for sum>>16 != 0 {
+		sum = sum&0xffff + sum>>16
+	}
+	return ^uint16(sum)
+}
+
+// sum8Checksum computes the last byte of the range which makes the 8-bit sum
+// of the bytes in the range zero.
+func (w *window) sum8Checksum(r *event.Range) ([]patch, string, error) {
+	if r == nil || r.To == nil {
+		return nil, "", errors.New("a range is required for sum8")
+	}
+	from, err := w.positionToOffset(r.From)
+	if err != nil {
+		return nil, "", err
+	}
+	to, err := w.positionToOffset(r.To)
+	if err != nil {
+		return nil, "", err
+	}
+	if from > to {
+		from, to = to, from
+	}
+	bs, err := w.readFull(from, to-from)
+	if err != nil {
+		return nil, "", err
+	}
+	var sum byte
+	for _, b := range bs {
+		sum += b
+	}
+	return []patch{{to, []byte{-sum}}},
+		fmt.Sprintf("fixed the 8-bit sum at 0x%x: 0x%02x", to, -sum), nil
+}
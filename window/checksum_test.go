@@ -0,0 +1,171 @@
+package window
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"image"
+	"image/png"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/itchyny/bed/event"
+)
+
+func windowBytes(t *testing.T, w *window) []byte {
+	bs, err := w.readFull(0, w.length)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bs
+}
+
+func TestWindowFixChecksumPNG(t *testing.T) {
+	var b bytes.Buffer
+	if err := png.Encode(&b, image.NewGray(image.Rect(0, 0, 4, 4))); err != nil {
+		t.Fatal(err)
+	}
+	bs := b.Bytes()
+	bs[29] ^= 0xff // CRC of IHDR
+	window, err := newWindow(bytes.NewReader(bs), "test", "test", make(chan struct{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	window.setSize(16, 10)
+	if _, err := png.Decode(bytes.NewReader(windowBytes(t, window))); err == nil {
+		t.Fatalf("png.Decode should fail with the broken CRC")
+	}
+	window.cursor = 20
+	msg, err := window.fixChecksum("png", nil)
+	if err != nil {
+		t.Fatalf("err should be nil but got: %v", err)
+	}
+	if !strings.HasPrefix(msg, "fixed the CRC of the IHDR chunk: 0x") {
+		t.Errorf("message should be about the IHDR chunk but got %q", msg)
+	}
+	img, err := png.Decode(bytes.NewReader(windowBytes(t, window)))
+	if err != nil {
+		t.Fatalf("png.Decode should succeed but got: %v", err)
+	}
+	if width := img.Bounds().Dx(); width != 4 {
+		t.Errorf("width should be %d but got %d", 4, width)
+	}
+	window.cursor = 0
+	if _, err := window.fixChecksum("png", nil); err == nil || err.Error() != "no PNG chunk at the cursor" {
+		t.Errorf("err should be %q but got: %v", "no PNG chunk at the cursor", err)
+	}
+}
+
+func TestWindowFixChecksumZip(t *testing.T) {
+	var b bytes.Buffer
+	zw := zip.NewWriter(&b)
+	for _, name := range []string{"a.txt", "b.txt"} {
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Store})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(strings.Repeat("Hello, "+name+"\n", 10))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	bs := b.Bytes()
+	// replace a byte of b.txt
+	i := bytes.LastIndex(bs, []byte("PK\x03\x04"))
+	bs[i+30+5] = 'h'
+	window, err := newWindow(bytes.NewReader(bs), "test", "test", make(chan struct{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	window.setSize(16, 10)
+	window.cursor = int64(i + 40)
+	msg, err := window.fixChecksum("zip", nil)
+	if err != nil {
+		t.Fatalf("err should be nil but got: %v", err)
+	}
+	if !strings.HasPrefix(msg, "fixed the CRC of b.txt: 0x") {
+		t.Errorf("message should be about b.txt but got %q", msg)
+	}
+	bs = windowBytes(t, window)
+	zr, err := zip.NewReader(bytes.NewReader(bs), int64(len(bs)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range zr.File {
+		r, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bs, err := ioutil.ReadAll(r); err != nil {
+			t.Errorf("%s should be read without error but got: %v", f.Name, err)
+		} else if f.Name == "b.txt" && !bytes.HasPrefix(bs, []byte("hello")) {
+			t.Errorf("%s should start with %q but got %q", f.Name, "hello", bs)
+		}
+		r.Close()
+	}
+}
+
+func TestWindowFixChecksumIP(t *testing.T) {
+	packet := []byte{
+		0x45, 0x00, 0x00, 0x21, 0x12, 0x34, 0x00, 0x00, 0x40, 0x11, 0xff, 0xff,
+		0xc0, 0xa8, 0x00, 0x01, 0xc0, 0xa8, 0x00, 0x02, // IPv4 header
+		0x30, 0x39, 0x00, 0x35, 0x00, 0x0d, 0xff, 0xff, // UDP header
+		'H', 'e', 'l', 'l', 'o',
+	}
+	var b bytes.Buffer
+	b.Write([]byte{0xd4, 0xc3, 0xb2, 0xa1, 0x02, 0x00, 0x04, 0x00})
+	b.Write(make([]byte, 8))
+	b.Write([]byte{0xff, 0xff, 0x00, 0x00, 0x65, 0x00, 0x00, 0x00}) // raw IP
+	b.Write(make([]byte, 8))
+	b.Write([]byte{byte(len(packet)), 0, 0, 0, byte(len(packet)), 0, 0, 0})
+	b.Write(packet)
+	window, err := newWindow(bytes.NewReader(b.Bytes()), "test", "test", make(chan struct{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	window.setSize(16, 10)
+	window.cursor = 50
+	msg, err := window.fixChecksum("ip", nil)
+	if err != nil {
+		t.Fatalf("err should be nil but got: %v", err)
+	}
+	bs := windowBytes(t, window)[40:]
+	if expected := fmt.Sprintf("fixed the IPv4 header checksum: 0x%x and the UDP checksum: 0x%x",
+		bs[10:12], bs[26:28]); msg != expected {
+		t.Errorf("message should be %q but got %q", expected, msg)
+	}
+	if sum := internetChecksum(0, bs[:20]); sum != 0 {
+		t.Errorf("checksum of the IPv4 header should be zero but got 0x%x", sum)
+	}
+	pseudo := append(append([]byte(nil), bs[12:20]...), 0, 17, 0, 13)
+	if sum := internetChecksum(internetSum(0, pseudo), bs[20:]); sum != 0 {
+		t.Errorf("checksum of the UDP segment should be zero but got 0x%x", sum)
+	}
+}
+
+func TestWindowFixChecksumSum8(t *testing.T) {
+	window, err := newWindow(strings.NewReader("\x01\x02\x03\x04\x00"), "test", "test", make(chan struct{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	window.setSize(16, 10)
+	if _, err := window.fixChecksum("sum8", nil); err == nil || err.Error() != "a range is required for sum8" {
+		t.Errorf("err should be %q but got: %v", "a range is required for sum8", err)
+	}
+	msg, err := window.fixChecksum("sum8", &event.Range{From: event.Absolute{}, To: event.Absolute{Offset: 4}})
+	if err != nil {
+		t.Fatalf("err should be nil but got: %v", err)
+	}
+	if expected := "fixed the 8-bit sum at 0x4: 0xf6"; msg != expected {
+		t.Errorf("message should be %q but got %q", expected, msg)
+	}
+	if bs := windowBytes(t, window); string(bs) != "\x01\x02\x03\x04\xf6" {
+		t.Errorf("bytes should be %q but got %q", "\x01\x02\x03\x04\xf6", bs)
+	}
+	if _, err := window.fixChecksum("crc64", nil); err == nil || err.Error() != "unknown checksum: crc64" {
+		t.Errorf("err should be %q but got: %v", "unknown checksum: crc64", err)
+	}
+}
@@ -0,0 +1,131 @@
+package window
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os/exec"
+)
+
+// codec decompresses the file to edit the contents, and compresses the
+// contents on writing to the file. The encrypted contents are kept in memory.
+type codec struct {
+	name       string
+	magic      []byte
+	encrypted  bool
+	decompress func(dst io.Writer, src io.Reader) error
+	compress   func(dst io.Writer) (io.WriteCloser, error)
+}
+
+var codecs = []*codec{
+	{
+		name:  "gzip",
+		magic: []byte{0x1f, 0x8b},
+		decompress: func(dst io.Writer, src io.Reader) error {
+			r, err := gzip.NewReader(src)
+			if err != nil {
+				return err
+			}
+			if _, err := io.Copy(dst, r); err != nil {
+				return err
+			}
+			return r.Close()
+		},
+		compress: func(dst io.Writer) (io.WriteCloser, error) {
+			return gzip.NewWriter(dst), nil
+		},
+	},
+	commandCodec("xz", []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}),
+	commandCodec("zstd", []byte{0x28, 0xb5, 0x2f, 0xfd}),
+}
+
+// commandCodec creates the codec using the command, which reads the standard
+// input and writes to the standard output with -c, and decompresses with -d.
+func commandCodec(name string, magic []byte) *codec {
+	return &codec{
+		name:  name,
+		magic: magic,
+		decompress: func(dst io.Writer, src io.Reader) error {
+			cmd := exec.Command(name, "-d", "-c")
+			cmd.Stdin, cmd.Stdout = src, dst
+			return runCodecCommand(cmd)
+		},
+		compress: func(dst io.Writer) (io.WriteCloser, error) {
+			cmd := exec.Command(name, "-c")
+			cmd.Stdout = dst
+			w, err := cmd.StdinPipe()
+			if err != nil {
+				return nil, err
+			}
+			stderr := new(bytes.Buffer)
+			cmd.Stderr = stderr
+			if err := cmd.Start(); err != nil {
+				return nil, err
+			}
+			return &commandWriter{w, cmd, stderr}, nil
+		},
+	}
+}
+
+func runCodecCommand(cmd *exec.Cmd) error {
+	stderr := new(bytes.Buffer)
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		return commandError(cmd, err, stderr)
+	}
+	return nil
+}
+
+func commandError(cmd *exec.Cmd, err error, stderr *bytes.Buffer) error {
+	if msg := bytes.TrimSpace(stderr.Bytes()); len(msg) > 0 {
+		return fmt.Errorf("%s: %s", cmd.Path, msg)
+	}
+	return fmt.Errorf("%s: %s", cmd.Path, err)
+}
+
+// commandWriter writes to the standard input of the command, and waits for
+// the command on closing.
+type commandWriter struct {
+	io.WriteCloser
+	cmd    *exec.Cmd
+	stderr *bytes.Buffer
+}
+
+func (w *commandWriter) Close() error {
+	if err := w.WriteCloser.Close(); err != nil {
+		return err
+	}
+	if err := w.cmd.Wait(); err != nil {
+		return commandError(w.cmd, err, w.stderr)
+	}
+	return nil
+}
+
+// detectCodec detects the compression format of the file by the magic bytes.
+func detectCodec(r io.ReaderAt) *codec {
+	bs := make([]byte, 8)
+	n, _ := r.ReadAt(bs, 0)
+	for _, c := range codecs {
+		if bytes.HasPrefix(bs[:n], c.magic) {
+			return c
+		}
+	}
+	return nil
+}
+
+func (c *codec) notice(filename string) error {
+	if c.encrypted {
+		return fmt.Errorf("%s: editing the %s decrypted contents in memory", filename, c.name)
+	}
+	return fmt.Errorf("%s: editing the %s decompressed contents (offsets differ from the file on disk)", filename, c.name)
+}
+
+// offer returns the message on opening the compressed file without
+// decompressing the contents, or after failing to decompress them.
+func (c *codec) offer(filename string, err error) error {
+	if err != nil {
+		return fmt.Errorf("%s: editing the original bytes (%s)", filename, err)
+	}
+	return fmt.Errorf("%s: %s compressed (:set decompress and :edit to edit the decompressed contents)", filename, c.name)
+}
@@ -0,0 +1,37 @@
+package window
+
+import (
+	"bytes"
+	"os/exec"
+	"testing"
+)
+
+func TestCodecs(t *testing.T) {
+	for _, c := range codecs {
+		if _, err := exec.LookPath(c.name); err != nil && c.name != "gzip" {
+			continue
+		}
+		compressed := new(bytes.Buffer)
+		w, err := c.compress(compressed)
+		if err != nil {
+			t.Fatalf("%s: err should be nil but got: %v", c.name, err)
+		}
+		_, _ = w.Write([]byte("Hello, world!"))
+		if err := w.Close(); err != nil {
+			t.Errorf("%s: err should be nil but got: %v", c.name, err)
+		}
+		if got := detectCodec(bytes.NewReader(compressed.Bytes())); got != c {
+			t.Errorf("%s: detectCodec should detect the codec but got: %v", c.name, got)
+		}
+		decompressed := new(bytes.Buffer)
+		if err := c.decompress(decompressed, compressed); err != nil {
+			t.Errorf("%s: err should be nil but got: %v", c.name, err)
+		}
+		if decompressed.String() != "Hello, world!" {
+			t.Errorf("%s: contents should be %q but got %q", c.name, "Hello, world!", decompressed.String())
+		}
+	}
+	if got := detectCodec(bytes.NewReader([]byte("Hello, world!"))); got != nil {
+		t.Errorf("detectCodec should return nil but got: %v", got)
+	}
+}
@@ -0,0 +1,154 @@
+package window
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/mitchellh/go-homedir"
+
+	"github.com/itchyny/bed/event"
+	"github.com/itchyny/bed/mathutil"
+)
+
+// compareStats is the summary of the comparison of the buffer and the file.
+type compareStats struct {
+	length, otherLength int64
+	diffs               int64
+	first               int64
+	prefix, suffix      int64
+}
+
+// compareStat compares the buffer with the file, and reports the number of
+// the differing bytes, the first difference and the lengths of the identical
+// prefix and suffix, without opening the windows.
+func (m *Manager) compareStat(e event.Event) error {
+	if e.Arg == "" {
+		return fmt.Errorf("an argument is required for %s", e.CmdName)
+	}
+	name, err := homedir.Expand(e.Arg)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	if fi.IsDir() {
+		f.Close()
+		return fmt.Errorf("%s is a directory", e.Arg)
+	}
+	window := m.windows[m.windowIndex]
+	waitWindow(window)
+	window.mu.Lock()
+	r, length := window.buffer.Clone(), window.length
+	window.mu.Unlock()
+	if err := m.startTask("comparing", 2*mathutil.MinInt64(length, fi.Size()),
+		func(t *task) (func() error, error) {
+			defer f.Close()
+			s, err := compareReaders(r, length, f, fi.Size(), t)
+			if err != nil {
+				return nil, err
+			}
+			return func() error {
+				m.eventCh <- event.Event{Type: event.Info, Error: errors.New(s.message(e.Arg))}
+				return nil
+			}, nil
+		}); err != nil {
+		f.Close()
+		return err
+	}
+	return nil
+}
+
+const compareChunkSize = 1 << 20
+
+// compareReaders compares the readers from the heads, and then from the tails
+// for the identical suffix. The task is nil when it runs without the progress.
+func compareReaders(r io.ReaderAt, length int64, other io.ReaderAt, otherLength int64, t *task) (*compareStats, error) {
+	s := &compareStats{length: length, otherLength: otherLength, first: -1}
+	size := mathutil.MinInt64(length, otherLength)
+	xs, ys := make([]byte, compareChunkSize), make([]byte, compareChunkSize)
+	for base := int64(0); base < size; base += compareChunkSize {
+		if err := t.check(base); err != nil {
+			return nil, err
+		}
+		n := int(mathutil.MinInt64(compareChunkSize, size-base))
+		if err := readChunks(r, xs[:n], other, ys[:n], base, base); err != nil {
+			return nil, err
+		}
+		diffs, first := countDiffs(xs[:n], ys[:n])
+		if s.diffs += diffs; s.first < 0 && first >= 0 {
+			s.first = base + int64(first)
+		}
+	}
+	if s.first < 0 {
+		if length == otherLength {
+			s.prefix, s.suffix = length, length
+			return s, nil
+		}
+		s.first = size
+	}
+	s.prefix = s.first
+	for s.suffix < size {
+		if err := t.check(size + s.suffix); err != nil {
+			return nil, err
+		}
+		n := int(mathutil.MinInt64(compareChunkSize, size-s.suffix))
+		if err := readChunks(r, xs[:n], other, ys[:n],
+			length-s.suffix-int64(n), otherLength-s.suffix-int64(n)); err != nil {
+			return nil, err
+		}
+		i := n
+		for i > 0 && xs[i-1] == ys[i-1] {
+			i--
+		}
+		if s.suffix += int64(n - i); i > 0 {
+			break
+		}
+	}
+	return s, nil
+}
+
+func readChunks(r io.ReaderAt, xs []byte, other io.ReaderAt, ys []byte, offset, otherOffset int64) error {
+	if _, err := r.ReadAt(xs, offset); err != nil && err != io.EOF {
+		return err
+	}
+	if _, err := other.ReadAt(ys, otherOffset); err != nil && err != io.EOF {
+		return err
+	}
+	return nil
+}
+
+// countDiffs counts the differing bytes, and returns the index of the first
+// difference, or -1 when the bytes are identical.
+func countDiffs(xs, ys []byte) (int64, int) {
+	var n int64
+	first := -1
+	for i := range xs {
+		if xs[i] != ys[i] {
+			if n++; first < 0 {
+				first = i
+			}
+		}
+	}
+	return n, first
+}
+
+func (s *compareStats) message(name string) string {
+	if s.first < 0 {
+		return fmt.Sprintf("identical to %s (%d bytes)", name, s.length)
+	}
+	msg := fmt.Sprintf("%d bytes differ from %s, first at 0x%x, identical prefix %d bytes, suffix %d bytes",
+		s.diffs, name, s.first, s.prefix, s.suffix)
+	if s.length != s.otherLength {
+		msg += fmt.Sprintf(" (%d bytes, %d bytes in %s)", s.length, s.otherLength, name)
+	}
+	return msg
+}
@@ -0,0 +1,44 @@
+package window
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestCompareReaders(t *testing.T) {
+	testCases := []struct {
+		x, y     string
+		expected string
+	}{
+		{"abcdef", "abcdef", "identical to f (6 bytes)"},
+		{"abcdef", "abXdeY", "2 bytes differ from f, first at 0x2, identical prefix 2 bytes, suffix 0 bytes"},
+		{"abcdef", "aXcdef", "1 bytes differ from f, first at 0x1, identical prefix 1 bytes, suffix 4 bytes"},
+		{"abcdef", "abc", "0 bytes differ from f, first at 0x3, identical prefix 3 bytes, suffix 0 bytes (6 bytes, 3 bytes in f)"},
+		{"xxabc", "abc", "3 bytes differ from f, first at 0x0, identical prefix 0 bytes, suffix 3 bytes (5 bytes, 3 bytes in f)"},
+		{"", "a", "0 bytes differ from f, first at 0x0, identical prefix 0 bytes, suffix 0 bytes (0 bytes, 1 bytes in f)"},
+	}
+	for _, tc := range testCases {
+		s, err := compareReaders(strings.NewReader(tc.x), int64(len(tc.x)), strings.NewReader(tc.y), int64(len(tc.y)), nil)
+		if err != nil {
+			t.Fatalf("err should be nil but got: %v", err)
+		}
+		if got := s.message("f"); got != tc.expected {
+			t.Errorf("compareReaders(%q, %q) should report %q but got %q", tc.x, tc.y, tc.expected, got)
+		}
+	}
+}
+
+func TestCompareReadersLarge(t *testing.T) {
+	x := bytes.Repeat([]byte{0x55}, compareChunkSize*2+100)
+	y := append([]byte(nil), x...)
+	y[compareChunkSize+10] = 0
+	y[compareChunkSize*2+50] = 0
+	s, err := compareReaders(bytes.NewReader(x), int64(len(x)), bytes.NewReader(y), int64(len(y)), nil)
+	if err != nil {
+		t.Fatalf("err should be nil but got: %v", err)
+	}
+	if s.diffs != 2 || s.first != compareChunkSize+10 || s.prefix != compareChunkSize+10 || s.suffix != 49 {
+		t.Errorf("compareReaders should report the differences but got %+v", s)
+	}
+}
@@ -0,0 +1,187 @@
+package window
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/itchyny/bed/event"
+	"github.com/itchyny/bed/mathutil"
+)
+
+// compressMaxSize is the maximum bytes of the range, and of the decompressed
+// bytes.
+const compressMaxSize = 64 * 1024 * 1024
+
+// compression is the format of the commands which compress and decompress the
+// bytes in place. The decompressor which finds the end of the stream does not
+// require the range, and decompresses the stream at the cursor.
+type compression struct {
+	compress   func(dst io.Writer) (io.WriteCloser, error)
+	decompress func(dst io.Writer, src io.Reader) error
+	stream     bool
+}
+
+var compressions = map[string]*compression{
+	"deflate": {
+		compress: func(dst io.Writer) (io.WriteCloser, error) {
+			return flate.NewWriter(dst, flate.BestCompression)
+		},
+		decompress: func(dst io.Writer, src io.Reader) error {
+			r := flate.NewReader(src)
+			if _, err := io.Copy(dst, r); err != nil {
+				return err
+			}
+			return r.Close()
+		},
+		stream: true,
+	},
+	"gzip": {
+		compress: func(dst io.Writer) (io.WriteCloser, error) {
+			return gzip.NewWriter(dst), nil
+		},
+		decompress: func(dst io.Writer, src io.Reader) error {
+			r, err := gzip.NewReader(src)
+			if err != nil {
+				return err
+			}
+			r.Multistream(false)
+			if _, err := io.Copy(dst, r); err != nil {
+				return err
+			}
+			return r.Close()
+		},
+		stream: true,
+	},
+	"zstd": {
+		compress:   commandCodec("zstd", nil).compress,
+		decompress: commandCodec("zstd", nil).decompress,
+	},
+}
+
+// compressionCommands is the formats of the commands, and reports whether the
+// command decompresses the bytes.
+var compressionCommands = map[string]struct {
+	format     string
+	decompress bool
+}{
+	"deflate": {"deflate", false},
+	"inflate": {"deflate", true},
+	"gzip":    {"gzip", false},
+	"gunzip":  {"gzip", true},
+	"zstd":    {"zstd", false},
+	"unzstd":  {"zstd", true},
+}
+
+func (m *Manager) compress(e event.Event) error {
+	if len(e.Arg) > 0 {
+		return fmt.Errorf("too many arguments for %s", e.CmdName)
+	}
+	cmd, ok := compressionCommands[e.CmdName]
+	if !ok {
+		return fmt.Errorf("unknown command: %s", e.CmdName)
+	}
+	window := m.windows[m.windowIndex]
+	waitWindow(window)
+	window.mu.Lock()
+	msg, err := window.compress(e.CmdName, compressions[cmd.format], cmd.decompress, e.Range)
+	window.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	m.eventCh <- event.Event{Type: event.Info, Error: errors.New(msg)}
+	return nil
+}
+
+// compress replaces the bytes of the range with the compressed or the
+// decompressed bytes. The decompressed bytes are reported with the range, so
+// that they can be compressed again after editing.
+func (w *window) compress(name string, c *compression, decompress bool, r *event.Range) (string, error) {
+	if w.length == 0 {
+		return "", fmt.Errorf("no bytes to %s", name)
+	}
+	from, to := w.cursor, w.length-1
+	if r != nil {
+		var err error
+		if from, err = w.positionToOffset(r.From); err != nil {
+			return "", err
+		}
+		to = from
+		if r.To != nil {
+			if to, err = w.positionToOffset(r.To); err != nil {
+				return "", err
+			}
+		}
+		if from > to {
+			from, to = to, from
+		}
+		to = mathutil.MinInt64(to, w.length-1)
+	} else if !decompress || !c.stream {
+		return "", fmt.Errorf("a range is required for %s", name)
+	}
+	if !decompress || r != nil {
+		if to-from+1 > compressMaxSize {
+			return "", fmt.Errorf("too large range to %s: %d bytes", name, to-from+1)
+		}
+	}
+	src := &byteCounter{Reader: bufio.NewReader(io.NewSectionReader(w.buffer, from, to-from+1))}
+	dst := &limitedBuffer{limit: compressMaxSize}
+	if decompress {
+		if err := c.decompress(dst, src); err != nil {
+			return "", fmt.Errorf("%s: %s", name, err)
+		}
+		if r == nil {
+			to = from + src.n - 1
+		}
+	} else {
+		cw, err := c.compress(dst)
+		if err != nil {
+			return "", err
+		}
+		if _, err := io.Copy(cw, src); err != nil {
+			cw.Close()
+			return "", fmt.Errorf("%s: %s", name, err)
+		}
+		if err := cw.Close(); err != nil {
+			return "", fmt.Errorf("%s: %s", name, err)
+		}
+	}
+	w.replaceBytes(from, to, dst.Bytes())
+	w.pushHistory(w.offset, w.cursor)
+	w.changedSwap()
+	w.visualStart = -1
+	w.cursorGotoPos(event.Absolute{Offset: from})
+	msg := fmt.Sprintf("%s: %d bytes at 0x%x to %d bytes", name, to-from+1, from, dst.Len())
+	if dst.Len() > 0 {
+		msg += fmt.Sprintf(" (0x%x-0x%x)", from, from+int64(dst.Len())-1)
+	}
+	return msg, nil
+}
+
+// replaceBytes replaces the bytes from the offset to the offset (inclusive).
+func (w *window) replaceBytes(from, to int64, bs []byte) {
+	w.buffer.DeleteBytes(from, to-from+1)
+	w.buffer.InsertBytes(from, bs)
+	w.changedTick++
+	w.length, _ = w.buffer.Len()
+	w.shiftMarks(from, -(to - from + 1))
+	w.shiftMarks(from, int64(len(bs)))
+}
+
+// limitedBuffer is the buffer which fails on exceeding the limit, to stop
+// the decompression of the large stream.
+type limitedBuffer struct {
+	bytes.Buffer
+	limit int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if b.Len()+len(p) > b.limit {
+		return 0, fmt.Errorf("too large bytes exceeding %d bytes", b.limit)
+	}
+	return b.Buffer.Write(p)
+}
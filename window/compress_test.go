@@ -0,0 +1,82 @@
+package window
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/itchyny/bed/event"
+)
+
+func TestWindowCompress(t *testing.T) {
+	text := strings.Repeat("hello, world\n", 10)
+	var b bytes.Buffer
+	b.WriteString("AB")
+	fw, _ := flate.NewWriter(&b, flate.DefaultCompression)
+	_, _ = fw.Write([]byte(text))
+	_ = fw.Close()
+	deflated := int64(b.Len() - 2)
+	b.WriteString("CD")
+	window, err := newWindow(bytes.NewReader(b.Bytes()), "test", "test", make(chan struct{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	window.setSize(16, 10)
+	window.cursorGotoPos(event.Absolute{Offset: 2})
+
+	msg, err := window.compress("inflate", compressions["deflate"], true, nil)
+	if err != nil {
+		t.Fatalf("err should be nil but got: %v", err)
+	}
+	if expected := fmt.Sprintf("inflate: %d bytes at 0x2 to 130 bytes (0x2-0x83)", deflated); msg != expected {
+		t.Errorf("message should be %q but got %q", expected, msg)
+	}
+	if bs, _ := window.readFull(0, window.length); string(bs) != "AB"+text+"CD" {
+		t.Errorf("bytes should be %q but got %q", "AB"+text+"CD", bs)
+	}
+	if window.cursor != 2 || !window.modified() {
+		t.Errorf("cursor should be 2 and the window should be modified")
+	}
+
+	r := &event.Range{From: event.Absolute{Offset: 2}, To: event.Absolute{Offset: 0x83}}
+	if _, err = window.compress("gzip", compressions["gzip"], false, r); err != nil {
+		t.Fatalf("err should be nil but got: %v", err)
+	}
+	bs, _ := window.readFull(0, window.length)
+	if !bytes.HasPrefix(bs, []byte("AB\x1f\x8b")) || !bytes.HasSuffix(bs, []byte("CD")) {
+		t.Fatalf("bytes should be compressed but got %q", bs)
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(bs[2 : len(bs)-2]))
+	if err != nil {
+		t.Fatalf("err should be nil but got: %v", err)
+	}
+	if got, _ := ioutil.ReadAll(zr); string(got) != text {
+		t.Errorf("decompressed bytes should be %q but got %q", text, got)
+	}
+
+	window.cursorGotoPos(event.Absolute{Offset: 2})
+	if _, err = window.compress("gunzip", compressions["gzip"], true, nil); err != nil {
+		t.Fatalf("err should be nil but got: %v", err)
+	}
+	if bs, _ := window.readFull(0, window.length); string(bs) != "AB"+text+"CD" {
+		t.Errorf("bytes should be %q but got %q", "AB"+text+"CD", bs)
+	}
+
+	if _, err = window.compress("deflate", compressions["deflate"], false, nil); err == nil ||
+		err.Error() != "a range is required for deflate" {
+		t.Errorf("deflate should fail without the range but got: %v", err)
+	}
+	if _, err = window.compress("unzstd", compressions["zstd"], true, nil); err == nil ||
+		err.Error() != "a range is required for unzstd" {
+		t.Errorf("unzstd should fail without the range but got: %v", err)
+	}
+	window.cursorGotoPos(event.Absolute{Offset: 0})
+	if _, err = window.compress("gunzip", compressions["gzip"], true, nil); err == nil ||
+		!strings.HasPrefix(err.Error(), "gunzip: ") {
+		t.Errorf("gunzip should fail for the invalid bytes but got: %v", err)
+	}
+}
@@ -0,0 +1,29 @@
+package window
+
+import "fmt"
+
+// Debug returns the lines of the internal state of the windows; the number
+// of the reader ranges of the buffer, and the entries of the history.
+func (m *Manager) Debug() []string {
+	m.mu.Lock()
+	windows := make([]*window, len(m.windows))
+	copy(windows, m.windows)
+	m.mu.Unlock()
+	xs := make([]string, len(windows))
+	for i, window := range windows {
+		xs[i] = window.debugInfo(i + 1)
+	}
+	return xs
+}
+
+func (w *window) debugInfo(index int) string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	name := w.name
+	if name == "" {
+		name = "[No name]"
+	}
+	return fmt.Sprintf("%3d %-20s %d bytes, %d ranges (%d bytes in memory), %d history entries (%d bytes)",
+		index, name, w.length, w.buffer.RangeCount(), w.buffer.MemoryLen(),
+		w.history.Len(), w.history.MemoryLen())
+}
@@ -0,0 +1,133 @@
+package window
+
+import (
+	"errors"
+	"io"
+
+	"github.com/itchyny/bed/mathutil"
+)
+
+// sectorSize is the alignment of the reads and writes for block devices.
+const sectorSize = 4096
+
+var errNotWritable = errors.New("device is not writable")
+
+// blockDevice reads and writes the block device in the aligned sectors.
+type blockDevice struct {
+	r      io.ReaderAt
+	size   int64
+	offset int64
+}
+
+func newBlockDevice(r readAtSeeker) (*blockDevice, error) {
+	size, err := r.Seek(0, io.SeekEnd)
+	if err != nil || size <= 0 {
+		if size, err = probeSize(r); err != nil {
+			return nil, err
+		}
+	}
+	return &blockDevice{r: r, size: size}, nil
+}
+
+// probeSize finds the size of the device by reading the sectors, doubling
+// the sector index and then bisecting it. Some devices report zero on seeking
+// to the end, and multi-terabyte images should not be read through.
+func probeSize(r io.ReaderAt) (int64, error) {
+	buf := make([]byte, sectorSize)
+	read := func(i int64) (int64, error) {
+		n, err := r.ReadAt(buf, i*sectorSize)
+		if err != nil && err != io.EOF {
+			return 0, err
+		}
+		return int64(n), nil
+	}
+	n, err := read(0)
+	if n == 0 || err != nil {
+		return 0, err
+	}
+	lo, hi := int64(0), int64(1)
+	for {
+		if n, err = read(hi); err != nil {
+			return 0, err
+		} else if n == 0 {
+			break
+		}
+		lo, hi = hi, hi*2
+	}
+	for hi-lo > 1 {
+		mid := lo + (hi-lo)/2
+		if n, err = read(mid); err != nil {
+			return 0, err
+		} else if n > 0 {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	if n, err = read(lo); err != nil {
+		return 0, err
+	}
+	return lo*sectorSize + n, nil
+}
+
+func alignSectors(from, to int64) (int64, int64) {
+	return from / sectorSize * sectorSize, (to + sectorSize - 1) / sectorSize * sectorSize
+}
+
+// ReadAt reads the sectors which cover the bytes.
+func (d *blockDevice) ReadAt(p []byte, offset int64) (int, error) {
+	if offset >= d.size {
+		return 0, io.EOF
+	}
+	end := mathutil.MinInt64(offset+int64(len(p)), d.size)
+	from, to := alignSectors(offset, end)
+	buf := make([]byte, to-from)
+	n, err := d.r.ReadAt(buf, from)
+	if int64(n) < end-from {
+		if err == nil {
+			err = io.ErrUnexpectedEOF
+		}
+		return copy(p, buf[mathutil.MinInt64(offset-from, int64(n)):n]), err
+	}
+	n = copy(p, buf[offset-from:end-from])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// WriteAt writes the bytes to the sectors. The sectors partially covered by
+// the bytes are read before writing.
+func (d *blockDevice) WriteAt(p []byte, offset int64) (int, error) {
+	w, ok := d.r.(io.WriterAt)
+	if !ok {
+		return 0, errNotWritable
+	}
+	end := offset + int64(len(p))
+	from, to := alignSectors(offset, end)
+	to = mathutil.MinInt64(to, mathutil.MaxInt64(d.size, end))
+	buf := make([]byte, to-from)
+	if from < offset || end < to {
+		if _, err := d.r.ReadAt(buf, from); err != nil && err != io.EOF {
+			return 0, err
+		}
+	}
+	copy(buf[offset-from:], p)
+	if _, err := w.WriteAt(buf, from); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Seek sets the offset, which is used to get the size of the device.
+func (d *blockDevice) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+		d.offset = offset
+	case io.SeekCurrent:
+		d.offset += offset
+	case io.SeekEnd:
+		d.offset = d.size + offset
+	}
+	return d.offset, nil
+}
@@ -0,0 +1,84 @@
+package window
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+// mockDevice requires the aligned reads and writes, and reports zero on
+// seeking to the end.
+type mockDevice struct {
+	bs []byte
+}
+
+func (d *mockDevice) check(p []byte, offset int64) error {
+	if offset%sectorSize != 0 || len(p)%sectorSize != 0 && offset+int64(len(p)) < int64(len(d.bs)) {
+		return errors.New("unaligned access")
+	}
+	return nil
+}
+
+func (d *mockDevice) ReadAt(p []byte, offset int64) (int, error) {
+	if err := d.check(p, offset); err != nil {
+		return 0, err
+	}
+	if offset >= int64(len(d.bs)) {
+		return 0, io.EOF
+	}
+	n := copy(p, d.bs[offset:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (d *mockDevice) WriteAt(p []byte, offset int64) (int, error) {
+	if err := d.check(p, offset); err != nil {
+		return 0, err
+	}
+	return copy(d.bs[offset:], p), nil
+}
+
+func (d *mockDevice) Seek(int64, int) (int64, error) {
+	return 0, nil
+}
+
+func TestBlockDeviceSize(t *testing.T) {
+	for _, size := range []int{0, 1, sectorSize - 1, sectorSize, sectorSize + 1, 37*sectorSize + 123, 1024 * sectorSize} {
+		d, err := newBlockDevice(&mockDevice{make([]byte, size)})
+		if err != nil {
+			t.Errorf("err should be nil but got: %v", err)
+		}
+		if d.size != int64(size) {
+			t.Errorf("size should be %d but got %d", size, d.size)
+		}
+	}
+}
+
+func TestBlockDeviceReadWrite(t *testing.T) {
+	bs := []byte(strings.Repeat("0123456789abcdef", 3*sectorSize/16))
+	d, _ := newBlockDevice(&mockDevice{bs})
+	p := make([]byte, 8)
+	n, err := d.ReadAt(p, sectorSize-4)
+	if err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	if n != 8 || string(p) != "cdef0123" {
+		t.Errorf("ReadAt should read %q but got %q", "cdef0123", string(p[:n]))
+	}
+	n, err = d.ReadAt(p, 3*sectorSize-4)
+	if err != io.EOF {
+		t.Errorf("err should be EOF but got: %v", err)
+	}
+	if n != 4 || string(p[:n]) != "cdef" {
+		t.Errorf("ReadAt should read %q but got %q", "cdef", string(p[:n]))
+	}
+	if _, err = d.WriteAt([]byte("xyz"), 2*sectorSize-1); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	if s := string(bs[2*sectorSize-2 : 2*sectorSize+3]); s != "exyz2" {
+		t.Errorf("WriteAt should write %q but got %q", "exyz2", s)
+	}
+}
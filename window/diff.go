@@ -0,0 +1,130 @@
+package window
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/itchyny/bed/mathutil"
+)
+
+const (
+	diffHunkSize = 256 // the maximum bytes of a hunk
+	diffLineSize = 16  // the bytes of a line of a hunk
+)
+
+// diffHunk is the run of the bytes differing at the same offsets. The bytes
+// beyond the end of the shorter file are in one side.
+type diffHunk struct {
+	offset int64
+	xs, ys []byte
+}
+
+// DiffFiles writes the hunks of the bytes differing at the same offsets of the
+// files, and reports whether the files are identical. The bytes are compared
+// by the offsets, so the inserted or deleted bytes are not aligned.
+func DiffFiles(w io.Writer, name, otherName string) (bool, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	g, err := os.Open(otherName)
+	if err != nil {
+		return false, err
+	}
+	defer g.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return false, err
+	}
+	gi, err := g.Stat()
+	if err != nil {
+		return false, err
+	}
+	bw := bufio.NewWriter(w)
+	var hunks int
+	err = diffReaders(f, fi.Size(), g, gi.Size(), func(h *diffHunk) error {
+		if hunks++; hunks == 1 {
+			if _, err := fmt.Fprintf(bw, "--- %s\t%d bytes\n+++ %s\t%d bytes\n",
+				name, fi.Size(), otherName, gi.Size()); err != nil {
+				return err
+			}
+		}
+		return h.write(bw)
+	})
+	if err != nil {
+		return false, err
+	}
+	return hunks == 0, bw.Flush()
+}
+
+// diffReaders calls the function with the hunks in the order of the offsets.
+func diffReaders(r io.ReaderAt, length int64, other io.ReaderAt, otherLength int64, f func(*diffHunk) error) error {
+	var h *diffHunk
+	flush := func() error {
+		if h == nil {
+			return nil
+		}
+		err := f(h)
+		h = nil
+		return err
+	}
+	size := mathutil.MaxInt64(length, otherLength)
+	xs, ys := make([]byte, compareChunkSize), make([]byte, compareChunkSize)
+	for base := int64(0); base < size; base += compareChunkSize {
+		n := int(mathutil.MinInt64(compareChunkSize, size-base))
+		nx := int(mathutil.MaxInt64(mathutil.MinInt64(int64(n), length-base), 0))
+		ny := int(mathutil.MaxInt64(mathutil.MinInt64(int64(n), otherLength-base), 0))
+		if err := readChunks(r, xs[:nx], other, ys[:ny], base, base); err != nil {
+			return err
+		}
+		for i := 0; i < n; i++ {
+			if i < nx && i < ny && xs[i] == ys[i] {
+				if err := flush(); err != nil {
+					return err
+				}
+				continue
+			}
+			if h != nil && (len(h.xs) == diffHunkSize || len(h.ys) == diffHunkSize) {
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+			if h == nil {
+				h = &diffHunk{offset: base + int64(i)}
+			}
+			if i < nx {
+				h.xs = append(h.xs, xs[i])
+			}
+			if i < ny {
+				h.ys = append(h.ys, ys[i])
+			}
+		}
+	}
+	return flush()
+}
+
+func (h *diffHunk) write(w io.Writer) error {
+	if _, err := fmt.Fprintf(w, "@@ 0x%08x -%d +%d @@\n", h.offset, len(h.xs), len(h.ys)); err != nil {
+		return err
+	}
+	for _, side := range []struct {
+		prefix string
+		bs     []byte
+	}{{"-", h.xs}, {"+", h.ys}} {
+		for i := 0; i < len(side.bs); i += diffLineSize {
+			line := side.bs[i:mathutil.MinInt(i+diffLineSize, len(side.bs))]
+			var sb strings.Builder
+			for _, b := range line {
+				sb.WriteString(fmt.Sprintf(" %02x", b))
+			}
+			if _, err := fmt.Fprintf(w, "%s%s\n", side.prefix, sb.String()); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
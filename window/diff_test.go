@@ -0,0 +1,65 @@
+package window
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDiffFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bed-diff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	x, y := filepath.Join(dir, "x.bin"), filepath.Join(dir, "y.bin")
+	if err := ioutil.WriteFile(x, []byte("0123456789"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(y, []byte("01AB4567890a"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var b bytes.Buffer
+	identical, err := DiffFiles(&b, x, y)
+	if err != nil {
+		t.Fatalf("err should be nil but got: %v", err)
+	}
+	expected := "--- " + x + "\t10 bytes\n+++ " + y + "\t12 bytes\n" +
+		"@@ 0x00000002 -2 +2 @@\n- 32 33\n+ 41 42\n" +
+		"@@ 0x0000000a -0 +2 @@\n+ 30 61\n"
+	if identical || b.String() != expected {
+		t.Errorf("DiffFiles should write\n%s\nbut got\n%s", expected, b.String())
+	}
+
+	b.Reset()
+	if identical, err = DiffFiles(&b, x, x); err != nil || !identical || b.Len() != 0 {
+		t.Errorf("DiffFiles should report the identical files but got %v, %v, %q", identical, err, b.String())
+	}
+}
+
+func TestDiffReadersLargeHunk(t *testing.T) {
+	xs := bytes.Repeat([]byte{0}, diffHunkSize+20)
+	ys := bytes.Repeat([]byte{1}, diffHunkSize+20)
+	var hunks []*diffHunk
+	err := diffReaders(bytes.NewReader(xs), int64(len(xs)), bytes.NewReader(ys), int64(len(ys)),
+		func(h *diffHunk) error {
+			hunks = append(hunks, h)
+			return nil
+		})
+	if err != nil {
+		t.Fatalf("err should be nil but got: %v", err)
+	}
+	if len(hunks) != 2 || len(hunks[0].xs) != diffHunkSize || hunks[1].offset != diffHunkSize || len(hunks[1].ys) != 20 {
+		t.Errorf("the hunk should be split but got %d hunks", len(hunks))
+	}
+	var b strings.Builder
+	if err := hunks[1].write(&b); err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Split(b.String(), "\n"); len(lines) != 6 || lines[0] != "@@ 0x00000100 -20 +20 @@" {
+		t.Errorf("the hunk should be written in the lines of 16 bytes but got %q", b.String())
+	}
+}
@@ -0,0 +1,74 @@
+package window
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/itchyny/bed/disasm"
+	"github.com/itchyny/bed/event"
+)
+
+// maxInstructionLength is the maximum length of an instruction of the
+// architectures, which is 15 bytes of x86.
+const maxInstructionLength = 15
+
+// defaultDisasmCount is the number of the instructions in the side pane.
+const defaultDisasmCount = 16
+
+// disassemble toggles the side pane of the instructions from the cursor for
+// the architecture of the arch option. The count sets the number of the
+// instructions, and the range moves the cursor to the start.
+func (m *Manager) disassemble(e event.Event) error {
+	count := defaultDisasmCount
+	if e.Arg != "" {
+		var err error
+		if count, err = strconv.Atoi(e.Arg); err != nil || count <= 0 {
+			return fmt.Errorf("invalid count for %s: %s", e.CmdName, e.Arg)
+		}
+	}
+	window := m.windows[m.windowIndex]
+	if err := disasm.Check(window.options.String("arch")); err != nil {
+		return err
+	}
+	waitWindow(window)
+	window.mu.Lock()
+	if e.Range != nil {
+		offset, err := window.positionToOffset(e.Range.From)
+		if err != nil {
+			window.mu.Unlock()
+			return err
+		}
+		window.cursorGotoPos(event.Absolute{Offset: offset})
+	} else if e.Arg == "" && window.disasmCount > 0 {
+		count = 0
+	}
+	window.disasmCount = count
+	window.mu.Unlock()
+	m.eventCh <- event.Event{Type: event.Redraw}
+	return nil
+}
+
+// disassembly returns the lines of the instructions from the cursor in the
+// side pane, or the error of the disassembler.
+func (w *window) disassembly() []string {
+	if w.disasmCount == 0 {
+		return nil
+	}
+	n, bs, err := w.readBytes(w.cursor, w.disasmCount*maxInstructionLength)
+	if err != nil {
+		return []string{err.Error()}
+	}
+	insts, err := disasm.Disassemble(bs[:n], w.options.String("arch"),
+		w.options.Int64("baseaddress")+w.cursor)
+	if err != nil {
+		return []string{err.Error()}
+	}
+	if len(insts) > w.disasmCount {
+		insts = insts[:w.disasmCount]
+	}
+	lines := make([]string, len(insts))
+	for i, inst := range insts {
+		lines[i] = fmt.Sprintf("%08x  %-12x %s", inst.Address, inst.Bytes, inst.Text)
+	}
+	return lines
+}
@@ -0,0 +1,233 @@
+package window
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/mitchellh/go-homedir"
+
+	"github.com/itchyny/bed/event"
+)
+
+// encryptMagic is the magic bytes of the file encrypted with AES-256-GCM. The
+// magic is followed by the salt of the key derivation, the nonce and the
+// sealed contents.
+var encryptMagic = []byte("bed-aes\x01")
+
+const (
+	encryptSaltSize   = 16
+	encryptIterations = 100000
+)
+
+// isEncrypted reports whether the file is encrypted by the magic bytes.
+func isEncrypted(r io.ReaderAt) bool {
+	bs := make([]byte, len(encryptMagic))
+	n, _ := r.ReadAt(bs, 0)
+	return bytes.Equal(bs[:n], encryptMagic)
+}
+
+// encryptionCodec creates the codec which decrypts the contents with the key
+// derived from the passphrase, and encrypts the contents on writing.
+func encryptionCodec(passphrase []byte) *codec {
+	return &codec{
+		name:      "AES-GCM",
+		magic:     encryptMagic,
+		encrypted: true,
+		decompress: func(dst io.Writer, src io.Reader) error {
+			bs, err := ioutil.ReadAll(src)
+			if err != nil {
+				return err
+			}
+			if bs, err = decrypt(bs, passphrase); err != nil {
+				return err
+			}
+			_, err = dst.Write(bs)
+			return err
+		},
+		compress: func(dst io.Writer) (io.WriteCloser, error) {
+			return &sealWriter{dst: dst, passphrase: passphrase}, nil
+		},
+	}
+}
+
+// sealWriter holds the contents in memory, and writes the encrypted contents
+// on closing.
+type sealWriter struct {
+	bytes.Buffer
+	dst        io.Writer
+	passphrase []byte
+}
+
+func (w *sealWriter) Close() error {
+	bs, err := encrypt(w.Bytes(), w.passphrase)
+	if err != nil {
+		return err
+	}
+	_, err = w.dst.Write(bs)
+	return err
+}
+
+// encrypt the contents with the passphrase.
+func encrypt(plaintext, passphrase []byte) ([]byte, error) {
+	header := make([]byte, len(encryptMagic)+encryptSaltSize)
+	copy(header, encryptMagic)
+	if _, err := io.ReadFull(rand.Reader, header[len(encryptMagic):]); err != nil {
+		return nil, err
+	}
+	gcm, err := newGCM(passphrase, header[len(encryptMagic):])
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(append(header, nonce...), nonce, plaintext, header), nil
+}
+
+// decrypt the contents with the passphrase. The header is authenticated along
+// with the contents.
+func decrypt(bs, passphrase []byte) ([]byte, error) {
+	headerSize := len(encryptMagic) + encryptSaltSize
+	if len(bs) < headerSize || !bytes.HasPrefix(bs, encryptMagic) {
+		return nil, errors.New("not an encrypted file")
+	}
+	gcm, err := newGCM(passphrase, bs[len(encryptMagic):headerSize])
+	if err != nil {
+		return nil, err
+	}
+	if len(bs) < headerSize+gcm.NonceSize()+gcm.Overhead() {
+		return nil, errors.New("encrypted file is truncated")
+	}
+	nonce := bs[headerSize : headerSize+gcm.NonceSize()]
+	plaintext, err := gcm.Open(nil, nonce, bs[headerSize+gcm.NonceSize():], bs[:headerSize])
+	if err != nil {
+		return nil, errors.New("wrong key or corrupted file")
+	}
+	return plaintext, nil
+}
+
+func newGCM(passphrase, salt []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(pbkdf2(passphrase, salt, encryptIterations, 32))
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// pbkdf2 derives the key from the passphrase with PBKDF2-HMAC-SHA256.
+func pbkdf2(passphrase, salt []byte, iterations, size int) []byte {
+	prf := hmac.New(sha256.New, passphrase)
+	var key []byte
+	for block := uint32(1); len(key) < size; block++ {
+		prf.Reset()
+		prf.Write(salt)
+		_ = binary.Write(prf, binary.BigEndian, block)
+		u := prf.Sum(nil)
+		t := append([]byte(nil), u...)
+		for i := 1; i < iterations; i++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+		key = append(key, t...)
+	}
+	return key[:size]
+}
+
+// errNoKey is the error of the missing passphrase.
+var errNoKey = errors.New("no key to decrypt (enter :passphrase, set the keyfile option or BED_KEY)")
+
+// passphrase returns the passphrase entered at the masked prompt, or reads it
+// from the file of the keyfile option, or the BED_KEY environment variable.
+// The passphrase is not set by the command line so that it is not saved in
+// the history.
+func (m *Manager) passphrase() ([]byte, error) {
+	if m.key != nil {
+		return m.key, nil
+	}
+	if name := m.options.String("keyfile"); name != "" {
+		name, err := homedir.Expand(name)
+		if err != nil {
+			return nil, err
+		}
+		bs, err := ioutil.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		if bs = bytes.TrimRight(bs, "\r\n"); len(bs) == 0 {
+			return nil, fmt.Errorf("%s: empty key file", name)
+		}
+		return bs, nil
+	}
+	if key := os.Getenv("BED_KEY"); key != "" {
+		return []byte(key), nil
+	}
+	return nil, errNoKey
+}
+
+// setPassphrase keeps the passphrase entered at the prompt in memory for the
+// session, and encrypts the window when the prompt is opened by :encrypt.
+func (m *Manager) setPassphrase(e event.Event) error {
+	if len(e.Bytes) == 0 {
+		return errors.New("empty passphrase")
+	}
+	m.key = e.Bytes
+	if e.CmdName == "encrypt" {
+		return m.encrypt(event.Event{CmdName: e.CmdName})
+	}
+	m.eventCh <- event.Event{Type: event.Info, Error: errors.New("passphrase is set")}
+	return nil
+}
+
+// decrypt the file in memory, so that the plaintext is not written to the
+// disk. The window encrypts the contents on writing to the file.
+func (m *Manager) decrypt(filename string, f *os.File) (*codec, readAtSeeker, error) {
+	key, err := m.passphrase()
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %s", filename, err)
+	}
+	c, b := encryptionCodec(key), new(bytes.Buffer)
+	if err := c.decompress(b, io.NewSectionReader(f, 0, 1<<62)); err != nil {
+		return nil, nil, fmt.Errorf("%s: %s", filename, err)
+	}
+	return c, bytes.NewReader(b.Bytes()), nil
+}
+
+// encrypt the contents of the window on writing to the file. The swap file is
+// removed so that the changes are not written to the disk. The passphrase is
+// asked when no key is available.
+func (m *Manager) encrypt(e event.Event) error {
+	if len(e.Arg) > 0 {
+		return fmt.Errorf("too many arguments for %s", e.CmdName)
+	}
+	key, err := m.passphrase()
+	if err == errNoKey {
+		m.eventCh <- event.Event{Type: event.StartCmdlinePassphrase, CmdName: e.CmdName}
+		return nil
+	} else if err != nil {
+		return err
+	}
+	window := m.windows[m.windowIndex]
+	if err := window.removeSwap(); err != nil {
+		return err
+	}
+	window.mu.Lock()
+	window.codec = encryptionCodec(key)
+	window.mu.Unlock()
+	m.eventCh <- event.Event{Type: event.Info, Error: errors.New("the contents are encrypted on writing")}
+	return nil
+}
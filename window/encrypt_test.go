@@ -0,0 +1,41 @@
+package window
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+func TestPBKDF2(t *testing.T) {
+	expected := "55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc" +
+		"49ca9cccf179b645991664b39d77ef317c71b845b1e30bd509112041d3a19783"
+	if got := hex.EncodeToString(pbkdf2([]byte("passwd"), []byte("salt"), 1, 64)); got != expected {
+		t.Errorf("pbkdf2 should be %s but got %s", expected, got)
+	}
+}
+
+func TestEncrypt(t *testing.T) {
+	bs, err := encrypt([]byte("Hello, world!"), []byte("secret"))
+	if err != nil {
+		t.Fatalf("err should be nil but got: %v", err)
+	}
+	if !isEncrypted(bytes.NewReader(bs)) || bytes.Contains(bs, []byte("Hello")) {
+		t.Errorf("contents should be encrypted but got %q", bs)
+	}
+	if got, err := decrypt(bs, []byte("secret")); err != nil || string(got) != "Hello, world!" {
+		t.Errorf("decrypted contents should be %q but got %q (%v)", "Hello, world!", got, err)
+	}
+	if _, err := decrypt(bs, []byte("wrong")); err == nil || err.Error() != "wrong key or corrupted file" {
+		t.Errorf("decrypt with the wrong key should fail but got: %v", err)
+	}
+	bs[len(bs)-1] ^= 1
+	if _, err := decrypt(bs, []byte("secret")); err == nil || err.Error() != "wrong key or corrupted file" {
+		t.Errorf("decrypt of the corrupted contents should fail but got: %v", err)
+	}
+	if _, err := decrypt(bs[:30], []byte("secret")); err == nil || err.Error() != "encrypted file is truncated" {
+		t.Errorf("decrypt of the truncated contents should fail but got: %v", err)
+	}
+	if isEncrypted(bytes.NewReader([]byte("bed-aes"))) {
+		t.Errorf("isEncrypted should return false for the short contents")
+	}
+}
@@ -0,0 +1,75 @@
+package window
+
+import (
+	"io"
+	"os"
+	"sync"
+)
+
+const (
+	// fileBlockSize is the size of the blocks read from the file at once.
+	fileBlockSize = 64 * 1024
+	// fileCacheBlocks is the number of the blocks kept in the cache.
+	fileCacheBlocks = 256
+)
+
+// fileReader reads the local file by the blocks through the cache, so that
+// redrawing and searching do not read the same bytes from the disk again.
+type fileReader struct {
+	f      *os.File
+	size   int64
+	offset int64
+	cache  *blockCache
+	mu     *sync.Mutex
+}
+
+func newFileReader(f *os.File, size int64) *fileReader {
+	r := &fileReader{f: f, size: size, mu: new(sync.Mutex)}
+	r.cache = newBlockCache(fileBlockSize, fileCacheBlocks, size, r.fetch)
+	return r
+}
+
+// fetch the bytes of the range from the file, which may be shorter than the
+// range when the file is truncated after opening.
+func (r *fileReader) fetch(from, to int64) ([]byte, error) {
+	bs := make([]byte, to-from)
+	n, err := r.f.ReadAt(bs, from)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	return bs[:n], nil
+}
+
+// prefetch reads the blocks of the range into the cache in the background.
+func (r *fileReader) prefetch(from, to int64) {
+	r.cache.prefetch(r.mu, from, to)
+}
+
+// ReadAt reads the bytes from the cached blocks.
+func (r *fileReader) ReadAt(p []byte, offset int64) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.cache.ReadAt(p, offset)
+}
+
+// Seek sets the offset, which is used to get the size of the file.
+func (r *fileReader) Seek(offset int64, whence int) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	switch whence {
+	case io.SeekStart:
+		r.offset = offset
+	case io.SeekCurrent:
+		r.offset += offset
+	case io.SeekEnd:
+		r.offset = r.size + offset
+	}
+	return r.offset, nil
+}
+
+// reset drops the cached blocks after the file is written in place.
+func (r *fileReader) reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cache.clear()
+}
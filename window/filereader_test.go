@@ -0,0 +1,52 @@
+package window
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestFileReader(t *testing.T) {
+	f, err := ioutil.TempFile("", "bed-test-file-reader")
+	if err != nil {
+		t.Fatalf("err should be nil but got: %v", err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	content := strings.Repeat("0123456789abcdef", 2*fileBlockSize/16+1)
+	if _, err := f.WriteString(content); err != nil {
+		t.Fatalf("err should be nil but got: %v", err)
+	}
+	r := newFileReader(f, int64(len(content)))
+	p := make([]byte, 8)
+	n, err := r.ReadAt(p, fileBlockSize-4)
+	if err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	if n != 8 || string(p) != "cdef0123" {
+		t.Errorf("ReadAt should read %q but got %q", "cdef0123", string(p[:n]))
+	}
+	if l, _ := r.Seek(0, io.SeekEnd); l != int64(len(content)) {
+		t.Errorf("Seek should return %d but got %d", len(content), l)
+	}
+
+	if _, err := f.WriteAt([]byte("xyz"), fileBlockSize); err != nil {
+		t.Fatalf("err should be nil but got: %v", err)
+	}
+	if n, _ = r.ReadAt(p, fileBlockSize-4); string(p[:n]) != "cdef0123" {
+		t.Errorf("ReadAt should read the cached %q but got %q", "cdef0123", string(p[:n]))
+	}
+	r.reset()
+	if n, _ = r.ReadAt(p, fileBlockSize-4); string(p[:n]) != "cdefxyz3" {
+		t.Errorf("ReadAt should read %q after reset but got %q", "cdefxyz3", string(p[:n]))
+	}
+
+	if err := f.Truncate(fileBlockSize * 2); err != nil {
+		t.Fatalf("err should be nil but got: %v", err)
+	}
+	if n, err = r.ReadAt(p, fileBlockSize*2); n != 0 || err != io.EOF {
+		t.Errorf("ReadAt should return io.EOF for the truncated file but got: %d, %v", n, err)
+	}
+}
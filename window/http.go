@@ -0,0 +1,170 @@
+package window
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// httpBlockSize is the size of the range requested at once.
+	httpBlockSize = 64 * 1024
+	// httpCacheBlocks is the number of the blocks kept in the cache.
+	httpCacheBlocks = 256
+)
+
+// httpClient is the client of the remote files, which gives up on the servers
+// not responding, instead of blocking the editor forever.
+var httpClient = &http.Client{
+	Transport: &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           (&net.Dialer{Timeout: 10 * time.Second, KeepAlive: 30 * time.Second}).DialContext,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: 30 * time.Second,
+		IdleConnTimeout:       90 * time.Second,
+	},
+	Timeout: time.Minute,
+}
+
+// httpReader reads the remote file with the range requests.
+type httpReader struct {
+	client *http.Client
+	url    string
+	size   int64
+	offset int64
+	cache  *blockCache
+	mu     *sync.Mutex
+}
+
+func isURL(name string) bool {
+	return strings.HasPrefix(name, "http://") || strings.HasPrefix(name, "https://")
+}
+
+// urlBaseName returns the base name of the path of the url.
+func urlBaseName(rawurl string) string {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return rawurl
+	}
+	if name := path.Base(u.Path); name != "." && name != "/" {
+		return name
+	}
+	return u.Host
+}
+
+// newHTTPReader requests the first byte to get the size of the file, and to
+// check whether the server supports the range requests. Servers may respond
+// to the range request of an empty file with the empty contents.
+func newHTTPReader(client *http.Client, url string) (*httpReader, error) {
+	r := &httpReader{client: client, url: url, mu: new(sync.Mutex)}
+	res, err := r.request(0, 1)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	switch res.StatusCode {
+	case http.StatusPartialContent, http.StatusRequestedRangeNotSatisfiable:
+	case http.StatusOK:
+		if res.ContentLength != 0 {
+			return nil, fmt.Errorf("range requests are not supported: %s", url)
+		}
+	default:
+		return nil, fmt.Errorf("%s: %s", url, res.Status)
+	}
+	if res.StatusCode != http.StatusOK {
+		if _, r.size, err = parseContentRange(res.Header.Get("Content-Range")); err != nil {
+			return nil, fmt.Errorf("%s: %s", url, err)
+		}
+	}
+	r.cache = newBlockCache(httpBlockSize, httpCacheBlocks, r.size, r.fetch)
+	return r, nil
+}
+
+// parseContentRange parses the first byte position and the complete length
+// of Content-Range header; bytes 0-0/1234 or bytes */1234, where the position
+// is -1 for the latter.
+func parseContentRange(s string) (int64, int64, error) {
+	i := strings.LastIndexByte(s, '/')
+	if !strings.HasPrefix(s, "bytes ") || i < 0 {
+		return 0, 0, fmt.Errorf("invalid Content-Range: %q", s)
+	}
+	size, err := strconv.ParseInt(s[i+1:], 10, 64)
+	if err != nil || size < 0 {
+		return 0, 0, fmt.Errorf("unknown size in Content-Range: %q", s)
+	}
+	from := int64(-1)
+	if r := s[len("bytes "):i]; r != "*" {
+		j := strings.IndexByte(r, '-')
+		if j < 0 {
+			return 0, 0, fmt.Errorf("invalid Content-Range: %q", s)
+		}
+		if from, err = strconv.ParseInt(r[:j], 10, 64); err != nil || from < 0 {
+			return 0, 0, fmt.Errorf("invalid Content-Range: %q", s)
+		}
+	}
+	return from, size, nil
+}
+
+func (r *httpReader) request(from, to int64) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, r.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", from, to-1))
+	return r.client.Do(req)
+}
+
+// fetch the bytes of the range from the server.
+func (r *httpReader) fetch(from, to int64) ([]byte, error) {
+	res, err := r.request(from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusPartialContent {
+		return nil, fmt.Errorf("%s: %s", r.url, res.Status)
+	}
+	if start, _, err := parseContentRange(res.Header.Get("Content-Range")); err != nil {
+		return nil, fmt.Errorf("%s: %s", r.url, err)
+	} else if start != from {
+		return nil, fmt.Errorf("%s: unexpected range from %d instead of %d", r.url, start, from)
+	}
+	bs := make([]byte, to-from)
+	if _, err := io.ReadFull(res.Body, bs); err != nil {
+		return nil, err
+	}
+	return bs, nil
+}
+
+// prefetch reads the blocks of the range into the cache in the background.
+func (r *httpReader) prefetch(from, to int64) {
+	r.cache.prefetch(r.mu, from, to)
+}
+
+// ReadAt reads the bytes from the cached blocks. The mutex is not held while
+// fetching the blocks, so that a slow server does not block the other reads.
+func (r *httpReader) ReadAt(p []byte, offset int64) (int, error) {
+	return r.cache.readAt(r.mu, p, offset)
+}
+
+// Seek sets the offset, which is used to get the size of the file.
+func (r *httpReader) Seek(offset int64, whence int) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	switch whence {
+	case io.SeekStart:
+		r.offset = offset
+	case io.SeekCurrent:
+		r.offset += offset
+	case io.SeekEnd:
+		r.offset = r.size + offset
+	}
+	return r.offset, nil
+}
@@ -0,0 +1,146 @@
+package window
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newRangeServer(content []byte, requests *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(requests, 1)
+		http.ServeContent(w, r, "test.bin", time.Time{}, bytes.NewReader(content))
+	}))
+}
+
+func TestHTTPReader(t *testing.T) {
+	content := []byte(strings.Repeat("0123456789abcdef", 3*httpBlockSize/16+1))
+	var requests int32
+	server := newRangeServer(content, &requests)
+	defer server.Close()
+	r, err := newHTTPReader(server.Client(), server.URL+"/test.bin")
+	if err != nil {
+		t.Fatalf("err should be nil but got: %v", err)
+	}
+	if r.size != int64(len(content)) {
+		t.Errorf("size should be %d but got %d", len(content), r.size)
+	}
+	p := make([]byte, 8)
+	n, err := r.ReadAt(p, httpBlockSize-4)
+	if err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	if n != 8 || string(p) != "cdef0123" {
+		t.Errorf("ReadAt should read %q but got %q", "cdef0123", string(p[:n]))
+	}
+	if _, err = r.ReadAt(p, httpBlockSize+4); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	if requests != 3 {
+		t.Errorf("cached blocks should not be requested again but got %d requests", requests)
+	}
+	n, err = r.ReadAt(p, int64(len(content))-4)
+	if err != io.EOF {
+		t.Errorf("err should be EOF but got: %v", err)
+	}
+	if n != 4 || string(p[:n]) != "cdef" {
+		t.Errorf("ReadAt should read %q but got %q", "cdef", string(p[:n]))
+	}
+}
+
+func TestHTTPReaderEmpty(t *testing.T) {
+	var requests int32
+	server := newRangeServer(nil, &requests)
+	defer server.Close()
+	r, err := newHTTPReader(server.Client(), server.URL)
+	if err != nil {
+		t.Fatalf("err should be nil but got: %v", err)
+	}
+	if r.size != 0 {
+		t.Errorf("size should be %d but got %d", 0, r.size)
+	}
+}
+
+func TestHTTPReaderNoRange(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("Hello, world!"))
+	}))
+	defer server.Close()
+	_, err := newHTTPReader(server.Client(), server.URL)
+	if expected := "range requests are not supported: " + server.URL; err == nil || err.Error() != expected {
+		t.Errorf("err should be %q but got: %v", expected, err)
+	}
+}
+
+func TestHTTPReaderRangeMismatch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Range", "bytes 0-0/1000000")
+		w.WriteHeader(http.StatusPartialContent)
+		_, _ = w.Write([]byte("0"))
+	}))
+	defer server.Close()
+	r, err := newHTTPReader(server.Client(), server.URL)
+	if err != nil {
+		t.Fatalf("err should be nil but got: %v", err)
+	}
+	_, err = r.ReadAt(make([]byte, 1), httpBlockSize+1)
+	if expected := server.URL + ": unexpected range from 0 instead of 65536"; err == nil || err.Error() != expected {
+		t.Errorf("err should be %q but got: %v", expected, err)
+	}
+}
+
+func TestHTTPReaderSlowFetch(t *testing.T) {
+	content := []byte(strings.Repeat("0123456789abcdef", 2*httpBlockSize/16))
+	blockCh := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Range"), "bytes=0-") {
+			<-blockCh
+		}
+		http.ServeContent(w, r, "test.bin", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+	defer close(blockCh)
+	r, err := newHTTPReader(server.Client(), server.URL)
+	if err != nil {
+		t.Fatalf("err should be nil but got: %v", err)
+	}
+	p := make([]byte, 4)
+	if _, err := r.ReadAt(p, 0); err != nil {
+		t.Fatalf("err should be nil but got: %v", err)
+	}
+	go func() { _, _ = r.ReadAt(make([]byte, 4), httpBlockSize) }()
+	time.Sleep(50 * time.Millisecond)
+	doneCh := make(chan struct{})
+	go func() {
+		defer close(doneCh)
+		_, _ = r.ReadAt(p, 4)
+	}()
+	select {
+	case <-doneCh:
+	case <-time.After(time.Second):
+		t.Fatalf("reading the cached block should not wait for the fetch")
+	}
+	if string(p) != "4567" {
+		t.Errorf("ReadAt should read %q but got %q", "4567", string(p))
+	}
+}
+
+func TestURLBaseName(t *testing.T) {
+	for _, testCase := range []struct {
+		url, expected string
+	}{
+		{"https://example.com/firmware.bin", "firmware.bin"},
+		{"https://example.com/images/disk.img?token=x", "disk.img"},
+		{"http://example.com/", "example.com"},
+		{"http://example.com", "example.com"},
+	} {
+		if got := urlBaseName(testCase.url); got != testCase.expected {
+			t.Errorf("urlBaseName(%q) should be %q but got %q", testCase.url, testCase.expected, got)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package window
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+
+	"github.com/itchyny/bed/event"
+)
+
+// largeInsertLength is the number of the inserted bytes to spool to the
+// temporary file instead of holding them in memory, on pasting or inserting
+// hundreds of megabytes.
+const largeInsertLength = 16 << 20
+
+// maxMemoryInsertLength is the limit of the bytes inserted in memory at once,
+// for the encrypted contents which are not spooled to the disk.
+const maxMemoryInsertLength = 1 << 30
+
+// spoolChunk is the size of the pattern repeated to write at once.
+const spoolChunk = 64 << 10
+
+// spoolBytes writes the pattern repeatedly for the count of bytes to the
+// temporary file, which is removed on closing the Manager. The task reports
+// the progress and cancels the spooling.
+func spoolBytes(count int64, pattern []byte, t *task) (*os.File, error) {
+	f, err := ioutil.TempFile("", "bed-insert-")
+	if err != nil {
+		return nil, err
+	}
+	chunk := make([]byte, (spoolChunk+len(pattern)-1)/len(pattern)*len(pattern))
+	for i := 0; i < len(chunk); i += len(pattern) {
+		copy(chunk[i:], pattern)
+	}
+	for n := count; n > 0; {
+		if err := t.check(count - n); err != nil {
+			f.Close()
+			os.Remove(f.Name())
+			return nil, err
+		}
+		bs := chunk
+		if n < int64(len(bs)) {
+			bs = bs[:n]
+		}
+		if _, err := f.Write(bs); err != nil {
+			f.Close()
+			os.Remove(f.Name())
+			return nil, err
+		}
+		n -= int64(len(bs))
+	}
+	if err := t.check(count); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, err
+	}
+	return f, nil
+}
+
+// checkInsert checks the number of the bytes to insert, and reports whether
+// the bytes should be spooled to the temporary file. The bytes are not spooled
+// for the encrypted contents, which should not be written to the disk in
+// plaintext.
+func (w *window) checkInsert(count int64) (bool, error) {
+	if count > math.MaxInt64-w.length {
+		return false, fmt.Errorf("too many bytes to insert: %d", count)
+	}
+	if w.codec != nil && w.codec.encrypted {
+		if count > maxMemoryInsertLength {
+			return false, fmt.Errorf("too many bytes to insert in memory: %d", count)
+		}
+		return false, nil
+	}
+	return count >= largeInsertLength, nil
+}
+
+// insertRepeat is the insertion of the pattern repeated for the count of
+// bytes at the offset, which is spooled by the Manager in the background.
+type insertRepeat struct {
+	offset  int64
+	count   int64
+	pattern []byte
+}
+
+// repeatInsert repeats the bytes inserted in the insert mode started with the
+// count, before exiting the insert mode. The large repetition is returned to
+// be spooled in the background.
+func (w *window) repeatInsert() (*insertRepeat, error) {
+	count, n := w.insertCount, w.cursor-w.insertFrom
+	w.insertCount = 0
+	if count <= 1 || n <= 0 {
+		return nil, nil
+	}
+	if n > math.MaxInt64/(count-1) {
+		return nil, fmt.Errorf("too many bytes to insert: %d times of %d bytes", count, n)
+	}
+	_, pattern, err := w.readBytes(w.insertFrom, int(n))
+	if err != nil {
+		return nil, err
+	}
+	count = n * (count - 1)
+	spool, err := w.checkInsert(count)
+	if err != nil {
+		return nil, err
+	}
+	if spool {
+		return &insertRepeat{w.cursor, count, pattern}, nil
+	}
+	w.insertBytes(count, pattern)
+	w.cursor += count
+	return nil, nil
+}
+
+// insertSpool inserts the bytes spooled to the temporary file at the cursor,
+// or after the cursor on Paste, and pushes the insertion to the history. The
+// cursor moves to the last byte of the pasted bytes.
+func (w *window) insertSpool(e event.Event, f *os.File, count int64) error {
+	if _, err := w.checkInsert(count); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	w.spools = append(w.spools, f)
+	if e.Type == event.Paste && w.cursor < w.length {
+		w.cursor++
+	}
+	w.buffer.InsertReader(w.cursor, f, count)
+	w.changedTick++
+	w.length += count
+	w.shiftMarks(w.cursor, count)
+	if e.Type == event.Paste || e.Type == event.PasteBefore {
+		w.cursorGotoPos(event.Absolute{Offset: w.cursor + count - 1})
+	}
+	w.pushHistory(w.offset, w.cursor)
+	w.changedSwap()
+	return nil
+}
+
+// removeSpools removes the temporary files of the inserted bytes.
+func (w *window) removeSpools() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, f := range w.spools {
+		f.Close()
+		os.Remove(f.Name())
+	}
+	w.spools = nil
+}
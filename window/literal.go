@@ -0,0 +1,137 @@
+package window
+
+import (
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/itchyny/bed/mode"
+)
+
+// literal is the byte entered by Ctrl-V in insert and replace mode, like Vim;
+// the decimal (065), the octal (o101), the hex (x41) bytes, or the unicode
+// code points (u3042, U0001f600) encoded in UTF-8.
+type literal struct {
+	mode   mode.Mode
+	kind   byte // 0 for decimal, 'o', 'x', 'u' or 'U'
+	digits string
+}
+
+func (l *literal) base() int {
+	switch l.kind {
+	case 'o':
+		return 8
+	case 0:
+		return 10
+	default:
+		return 16
+	}
+}
+
+func (l *literal) maxDigits() int {
+	switch l.kind {
+	case 'x':
+		return 2
+	case 'u':
+		return 4
+	case 'U':
+		return 8
+	default:
+		return 3
+	}
+}
+
+// accept reports whether the rune can be appended to the digits.
+func (l *literal) accept(ch rune) bool {
+	if ch >= utf8.RuneSelf || len(l.digits) >= l.maxDigits() {
+		return false
+	}
+	v, err := strconv.ParseUint(l.digits+string(ch), l.base(), 32)
+	if err != nil {
+		return false
+	}
+	if l.kind == 'u' || l.kind == 'U' {
+		return v <= utf8.MaxRune
+	}
+	return v <= 0xff
+}
+
+// bytes returns the entered bytes.
+func (l *literal) bytes() []byte {
+	if l.digits == "" {
+		if l.kind == 0 {
+			return nil
+		}
+		return []byte{l.kind}
+	}
+	v, _ := strconv.ParseUint(l.digits, l.base(), 32)
+	if l.kind == 'u' || l.kind == 'U' {
+		buf := make([]byte, utf8.UTFMax)
+		return buf[:utf8.EncodeRune(buf, rune(v))]
+	}
+	return []byte{byte(v)}
+}
+
+// String returns the representation of the literal entry.
+func (l *literal) String() string {
+	s := "^V"
+	if l.kind != 0 {
+		s += string(l.kind)
+	}
+	return s + l.digits
+}
+
+func (w *window) literalString() string {
+	if w.literal == nil {
+		return ""
+	}
+	return w.literal.String()
+}
+
+func (w *window) startLiteral(m mode.Mode) {
+	w.pending = false
+	w.pendingByte = '\x00'
+	w.literal = &literal{mode: m}
+}
+
+// inputLiteral feeds the rune to the literal entry, and reports whether the
+// rune is consumed. The rune which cannot be a digit ends the entry, and is
+// inserted as usual.
+func (w *window) inputLiteral(ch rune) bool {
+	l := w.literal
+	if l.kind == 0 && l.digits == "" {
+		switch ch {
+		case 'o', 'O':
+			l.kind = 'o'
+			return true
+		case 'x', 'X':
+			l.kind = 'x'
+			return true
+		case 'u', 'U':
+			l.kind = byte(ch)
+			return true
+		}
+		if ch < '0' || '9' < ch {
+			w.literal = nil
+			buf := make([]byte, utf8.UTFMax)
+			w.insertExpression(l.mode, buf[:utf8.EncodeRune(buf, ch)])
+			return true
+		}
+	}
+	if !l.accept(ch) {
+		w.finishLiteral()
+		return false
+	}
+	if l.digits += string(ch); len(l.digits) == l.maxDigits() {
+		w.finishLiteral()
+	}
+	return true
+}
+
+// finishLiteral inserts the bytes of the literal entry in the mode where the
+// entry is started.
+func (w *window) finishLiteral() {
+	if l := w.literal; l != nil {
+		w.literal = nil
+		w.insertExpression(l.mode, l.bytes())
+	}
+}
@@ -0,0 +1,20 @@
+// +build linux darwin freebsd netbsd openbsd dragonfly
+
+package window
+
+import (
+	"os"
+	"syscall"
+)
+
+// lockFile takes the exclusive advisory lock of the file without blocking.
+// The lock is released on closing the file.
+func lockFile(f *os.File) (bool, error) {
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		if err == syscall.EWOULDBLOCK {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
@@ -2,20 +2,32 @@ package window
 
 import (
 	"bytes"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"math/rand"
 	"os"
+	"path"
 	"path/filepath"
 	"runtime"
+	"runtime/debug"
 	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/mitchellh/go-homedir"
 
+	"github.com/itchyny/bed/clipboard"
 	"github.com/itchyny/bed/event"
+	"github.com/itchyny/bed/highlight"
 	"github.com/itchyny/bed/layout"
 	"github.com/itchyny/bed/mathutil"
+	"github.com/itchyny/bed/option"
+	"github.com/itchyny/bed/schema"
 	"github.com/itchyny/bed/state"
 )
 
@@ -29,25 +41,221 @@ type Manager struct {
 	windowIndex     int
 	prevWindowIndex int
 	files           []file
+	highlights      []*highlight.Rule
+	register        []byte
+	clipboard       systemClipboard
+	options         *option.Options
+	stdin           io.Reader
+	stdout          io.Writer
+	remotes         []*sftpFile
+	streams         []*stream
+	quickfix        *quickfix
+	task            *task
+	quitting        []*window
+	dialSFTP        func(string) (*sftpClient, error)
+	key             []byte
+	hooks           []event.Event
+	hookMu          *sync.Mutex
 	eventCh         chan<- event.Event
 	redrawCh        chan<- struct{}
+	doneCh          chan struct{}
+	wg              *sync.WaitGroup
+	emitting        int32
 }
 
 type file struct {
-	name string
-	file *os.File
-	perm os.FileMode
+	name    string
+	file    *os.File
+	reader  *fileReader
+	perm    os.FileMode
+	modTime time.Time
+	size    int64
+	temp    bool
+	device  bool
 }
 
+// watchInterval is the interval to check the modification of the files.
+const watchInterval = time.Second
+
 // NewManager creates a new Manager.
 func NewManager() *Manager {
-	return &Manager{}
+	return &Manager{
+		options: option.New(), stdin: os.Stdin, stdout: os.Stdout,
+		clipboard: clipboard.New(), dialSFTP: dialSFTP,
+	}
 }
 
 // Init initializes the Manager.
 func (m *Manager) Init(eventCh chan<- event.Event, redrawCh chan<- struct{}) {
 	m.eventCh, m.redrawCh = eventCh, redrawCh
-	m.mu = new(sync.Mutex)
+	m.mu, m.wg, m.hookMu = new(sync.Mutex), new(sync.WaitGroup), new(sync.Mutex)
+	m.doneCh = make(chan struct{})
+	m.wg.Add(1)
+	go m.watchFiles()
+}
+
+func (m *Manager) watchFiles() {
+	defer m.wg.Done()
+	defer event.RecoverPanic(m.eventCh, m.doneCh)
+	ticker := time.NewTicker(watchInterval)
+	defer ticker.Stop()
+	saved := time.Now()
+	for {
+		select {
+		case now := <-ticker.C:
+			if err := m.checkFiles(); err != nil {
+				m.sendEvent(event.Event{Type: event.Error, Error: err})
+			}
+			if d := m.options.Duration("autosave"); d > 0 && now.Sub(saved) >= d {
+				saved = now
+				if err := m.autosave(); err != nil {
+					m.sendEvent(event.Event{Type: event.Error, Error: err})
+				}
+			} else if d == 0 {
+				if err := m.saveSwaps(); err != nil {
+					m.sendEvent(event.Event{Type: event.Error, Error: err})
+				}
+			}
+		case <-m.doneCh:
+			return
+		}
+	}
+}
+
+// sendEvent sends the event from the background, which is given up when the
+// Manager is closed.
+func (m *Manager) sendEvent(e event.Event) {
+	select {
+	case m.eventCh <- e:
+	case <-m.doneCh:
+	}
+}
+
+// autosave writes the journals of the windows to the swap files, or writes the
+// modified files shown in the layout when the autowrite option is set.
+func (m *Manager) autosave() error {
+	m.mu.Lock()
+	windows := make([]*window, len(m.windows))
+	copy(windows, m.windows)
+	var writes []*window
+	if m.options.Bool("autowrite") {
+		for i := range m.layout.Collect() {
+			if m.autowritable(windows[i]) {
+				writes = append(writes, windows[i])
+			}
+		}
+	}
+	m.mu.Unlock()
+	for _, window := range writes {
+		if _, _, err := m.writeWindow(window, nil, window.filename); err != nil {
+			return err
+		}
+	}
+	if err := m.saveSwaps(); err != nil {
+		return err
+	}
+	if len(writes) > 0 {
+		select {
+		case m.redrawCh <- struct{}{}:
+		case <-m.doneCh:
+		}
+	}
+	return nil
+}
+
+// saveSwaps writes the swap files of the windows which have the changes not
+// written yet.
+func (m *Manager) saveSwaps() error {
+	m.mu.Lock()
+	windows := make([]*window, len(m.windows))
+	copy(windows, m.windows)
+	m.mu.Unlock()
+	for _, window := range windows {
+		if err := window.autosaveSwap(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// autowritable reports whether the window is written by autosave, which
+// excludes the new files without the name and the temporary files.
+func (m *Manager) autowritable(window *window) bool {
+	if window.filename == "" || isURL(window.filename) ||
+		!window.isModified() || window.options.Bool("readonly") {
+		return false
+	}
+	for _, f := range m.files {
+		if f.name == window.filename && f.temp {
+			return false
+		}
+	}
+	return true
+}
+
+// checkFiles reports the file changed on disk after it was opened, since the
+// windows read the contents lazily and would show stale or shifted data.
+func (m *Manager) checkFiles() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, f := range m.files {
+		if f.temp {
+			continue
+		}
+		info, err := os.Stat(f.name)
+		if err != nil {
+			continue
+		}
+		if !info.ModTime().Equal(f.modTime) || info.Size() != f.size {
+			m.updateFileInfo(f.name, info)
+			return fmt.Errorf("%s has changed on disk (use :e! to reload)", f.name)
+		}
+	}
+	return nil
+}
+
+func (m *Manager) updateFileInfo(name string, info os.FileInfo) {
+	for i := range m.files {
+		if m.files[i].name == name {
+			m.files[i].modTime, m.files[i].size = info.ModTime(), info.Size()
+		}
+	}
+}
+
+// runWindow runs the event loop of the window until the window is closed.
+func (m *Manager) runWindow(window *window) {
+	window.doneCh = m.doneCh
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		defer func() {
+			if r := recover(); r != nil {
+				// keep receiving the events not to block the manager until
+				// the editor handles the crash
+				go func() {
+					for range window.eventCh {
+					}
+				}()
+				m.waitEmit()
+				m.sendEvent(event.Event{Type: event.Crash,
+					Error: &event.PanicError{Value: r, Stack: debug.Stack()}})
+			}
+		}()
+		window.run()
+	}()
+}
+
+// waitEmit waits until the events in progress are handled. Emit sends the
+// result to the editor on the event loop of the editor, which gets stuck if
+// the crash of a window takes the slot of the channel in the meantime.
+func (m *Manager) waitEmit() {
+	for atomic.LoadInt32(&m.emitting) > 0 {
+		select {
+		case <-time.After(10 * time.Millisecond):
+		case <-m.doneCh:
+			return
+		}
+	}
 }
 
 // Open a new window.
@@ -58,14 +266,78 @@ func (m *Manager) Open(filename string) error {
 	if err != nil {
 		return err
 	}
-	go window.run()
+	m.runWindow(window)
 	m.windows = append(m.windows, window)
 	m.windowIndex, m.prevWindowIndex = len(m.windows)-1, m.windowIndex
 	m.layout = layout.NewLayout(m.windowIndex).Resize(0, 0, m.width, m.height)
+	if e := m.openedEvent(); e.Type != event.Redraw {
+		select {
+		case m.eventCh <- e:
+		default:
+		}
+	}
 	return nil
 }
 
+// openedEvent returns the event after opening a window, which notifies the
+// swap file or the compression of the window, or reports the broken file of
+// the bookmarks or the annotations.
+func (m *Manager) openedEvent() event.Event {
+	window := m.windows[m.windowIndex]
+	if window.marksErr != nil {
+		return event.Event{Type: event.Error, Error: window.marksErr}
+	}
+	if window.notesErr != nil {
+		return event.Event{Type: event.Error, Error: window.notesErr}
+	}
+	if err := window.notice(); err != nil {
+		return event.Event{Type: event.Info, Error: err}
+	}
+	return event.Event{Type: event.Redraw}
+}
+
 func (m *Manager) open(filename string) (*window, error) {
+	window, err := m.openFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	window.options, window.global = m.options.Clone(), m.options
+	if window.swap != nil {
+		for _, w := range m.windows {
+			if w.journaling(window.swap.path) {
+				window.swap = nil // journaled by the other window
+				break
+			}
+		}
+	}
+	m.addHook(event.Edit, window.filename)
+	return window, nil
+}
+
+// maxHooks is the maximum number of the events kept for the hooks.
+const maxHooks = 100
+
+// addHook records the event of opening or writing the file for the hooks of
+// the editor, which takes the events by TakeHooks after handling each event.
+func (m *Manager) addHook(typ event.Type, filename string) {
+	m.hookMu.Lock()
+	defer m.hookMu.Unlock()
+	if len(m.hooks) < maxHooks {
+		m.hooks = append(m.hooks, event.Event{Type: typ, Arg: filename})
+	}
+}
+
+// TakeHooks returns the events of opening (Edit) and writing (Write) the
+// files since the last call, with the file names in Arg.
+func (m *Manager) TakeHooks() []event.Event {
+	m.hookMu.Lock()
+	defer m.hookMu.Unlock()
+	hooks := m.hooks
+	m.hooks = nil
+	return hooks
+}
+
+func (m *Manager) openFile(filename string) (*window, error) {
 	if filename == "" {
 		window, err := newWindow(bytes.NewReader(nil), "", "", m.redrawCh)
 		if err != nil {
@@ -73,20 +345,34 @@ func (m *Manager) open(filename string) (*window, error) {
 		}
 		return window, nil
 	}
+	if filename == "-" {
+		return m.openStdin()
+	}
 	name, err := homedir.Expand(filename)
 	if err != nil {
 		return nil, err
 	}
 	filename = name
+	if isURL(filename) {
+		return m.openURL(filename)
+	}
+	if archive, entry, ok := splitArchive(filename); ok {
+		return m.openArchive(filename, archive, entry)
+	}
+	if isSFTP(filename) {
+		return m.openSFTP(filename)
+	}
 	f, err := os.Open(filename)
 	if err != nil {
 		if !os.IsNotExist(err) {
 			return nil, err
 		}
-		window, err := newWindow(bytes.NewReader(nil), filename, filepath.Base(filename), m.redrawCh)
+		r := bytes.NewReader(nil)
+		window, err := newWindow(r, filename, filepath.Base(filename), m.redrawCh)
 		if err != nil {
 			return nil, err
 		}
+		window.swap = newJournal(filename, nil, r)
 		return window, nil
 	}
 	info, err := os.Stat(filename)
@@ -96,11 +382,142 @@ func (m *Manager) open(filename string) (*window, error) {
 	if info.IsDir() {
 		return nil, fmt.Errorf("%s is a directory", filename)
 	}
-	m.files = append(m.files, file{name: filename, file: f, perm: info.Mode().Perm()})
-	window, err := newWindow(f, filename, filepath.Base(filename), m.redrawCh)
+	if info.Mode()&(os.ModeNamedPipe|os.ModeCharDevice) != 0 {
+		return m.openStream(f, filepath.Base(filename))
+	}
+	device := isBlockDevice(info)
+	if m.options.Bool("lock") && !device {
+		if ok, err := lockFile(f); err != nil {
+			f.Close()
+			return nil, err
+		} else if !ok {
+			f.Close()
+			return nil, fmt.Errorf("%s is locked by another process", filename)
+		}
+	}
+	m.files = append(m.files, file{
+		name: filename, file: f, perm: info.Mode().Perm(),
+		modTime: info.ModTime(), size: info.Size(), device: device,
+	})
+	m.updateFileInfo(filename, info)
+	var r readAtSeeker = f
+	var c, compressed *codec
+	var codecErr error
+	if device {
+		if r, err = newBlockDevice(f); err != nil {
+			return nil, err
+		}
+	} else if isEncrypted(f) {
+		if c, r, err = m.decrypt(filename, f); err != nil {
+			return nil, err
+		}
+	} else if d := detectCodec(f); d != nil && !m.options.Bool("decompress") {
+		compressed = d
+	} else if d != nil {
+		// the original bytes are edited when the contents cannot be decompressed
+		if tmpf, err := m.decompress(f, d); err != nil {
+			compressed, codecErr = d, err
+		} else {
+			c, r = d, tmpf
+		}
+	}
+	if !device && c == nil {
+		fr := newFileReader(f, info.Size())
+		m.files[len(m.files)-1].reader, r = fr, fr
+	}
+	window, err := newWindow(r, filename, filepath.Base(filename), m.redrawCh)
+	if err != nil {
+		return nil, err
+	}
+	window.codec, window.compressed, window.codecErr = c, compressed, codecErr
+	if !device && (c == nil || !c.encrypted) {
+		window.swap = newJournal(filename, info, r)
+	}
+	// the broken files of the bookmarks and the annotations are reported on
+	// opening the window
+	window.bookmarks, window.marksErr = loadBookmarks(filename)
+	window.annotations, window.notesErr = loadAnnotations(filename)
+	return window, nil
+}
+
+// openURL opens the remote file, which is read with the range requests. The
+// changes are kept in the buffer, and can be written to a local file.
+func (m *Manager) openURL(url string) (*window, error) {
+	r, err := newHTTPReader(httpClient, url)
+	if err != nil {
+		return nil, err
+	}
+	return newWindow(r, url, urlBaseName(url), m.redrawCh)
+}
+
+// openSFTP opens the remote file of sftp://host/path with the sftp subsystem
+// of ssh. The changes are written to the remote file in place.
+func (m *Manager) openSFTP(name string) (*window, error) {
+	host, p, err := splitSFTP(name)
+	if err != nil {
+		return nil, err
+	}
+	client, err := m.dialSFTP(host)
+	if err != nil {
+		return nil, err
+	}
+	f, err := newSFTPFile(client, name, p)
+	if err != nil {
+		client.Close()
+		return nil, fmt.Errorf("%s: %s", host, err)
+	}
+	m.remotes = append(m.remotes, f)
+	return newWindow(f, name, path.Base(p), m.redrawCh)
+}
+
+// openArchive reads the entry of the archive like archive.zip:path/inner.bin,
+// which is written back by re-packing the archive.
+func (m *Manager) openArchive(name, archive, entry string) (*window, error) {
+	bs, err := readArchiveEntry(archive, entry)
+	if err != nil {
+		return nil, err
+	}
+	return newWindow(bytes.NewReader(bs), name, path.Base(entry), m.redrawCh)
+}
+
+// decompress the file to a temporary file, which is removed on closing the
+// Manager. The window edits the decompressed contents and compresses them on
+// writing to the file.
+func (m *Manager) decompress(f *os.File, c *codec) (*os.File, error) {
+	tmpf, err := ioutil.TempFile("", "bed-decompress-")
+	if err != nil {
+		return nil, err
+	}
+	if err := c.decompress(tmpf, io.NewSectionReader(f, 0, 1<<62)); err != nil {
+		tmpf.Close()
+		os.Remove(tmpf.Name())
+		return nil, err
+	}
+	m.files = append(m.files, file{name: tmpf.Name(), file: tmpf, perm: 0600, temp: true})
+	return tmpf, nil
+}
+
+// openStdin opens the standard input, which is spooled to a temporary file on
+// demand, so that the window can seek the contents.
+func (m *Manager) openStdin() (*window, error) {
+	return m.openStream(m.stdin, "[stdin]")
+}
+
+// openStream opens the input which cannot be seeked. The input is spooled to
+// a temporary file, which is removed on closing the Manager.
+func (m *Manager) openStream(r io.Reader, name string) (*window, error) {
+	f, err := ioutil.TempFile("", "bed-stdin-")
+	if err != nil {
+		return nil, err
+	}
+	m.files = append(m.files, file{name: f.Name(), file: f, perm: 0600, temp: true})
+	s := newStream(r, f)
+	m.streams = append(m.streams, s)
+	window, err := newWindow(s, "", name, m.redrawCh)
 	if err != nil {
 		return nil, err
 	}
+	window.stream = s
 	return window, nil
 }
 
@@ -119,24 +536,59 @@ func (m *Manager) Resize(width, height int) {
 	}
 }
 
+// SetOptions sets the global options.
+func (m *Manager) SetOptions(options *option.Options) {
+	m.options = options
+}
+
 // Emit an event to the current window.
 func (m *Manager) Emit(e event.Event) {
+	atomic.AddInt32(&m.emitting, 1)
+	defer atomic.AddInt32(&m.emitting, -1)
+	if e.Range != nil {
+		m.mu.Lock()
+		window := m.windows[m.windowIndex]
+		m.mu.Unlock()
+		e.Range = rebaseRange(e.Range, window.options.Int64("baseaddress"))
+	}
+	if isEdit(e.Type) {
+		if err := m.Modifiable(); err != nil {
+			m.eventCh <- event.Event{Type: event.Error, Error: err}
+			return
+		}
+	}
 	switch e.Type {
 	case event.Edit:
 		if err := m.edit(e); err != nil {
 			m.eventCh <- event.Event{Type: event.Error, Error: err}
 		} else {
-			m.eventCh <- event.Event{Type: event.Redraw}
+			m.eventCh <- m.openedEvent()
 		}
 	case event.New:
 		if err := m.newWindow(e, false); err != nil {
 			m.eventCh <- event.Event{Type: event.Error, Error: err}
 		} else {
-			m.eventCh <- event.Event{Type: event.Redraw}
+			m.eventCh <- m.openedEvent()
 		}
 	case event.Vnew:
 		if err := m.newWindow(e, true); err != nil {
 			m.eventCh <- event.Event{Type: event.Error, Error: err}
+		} else {
+			m.eventCh <- m.openedEvent()
+		}
+	case event.Split:
+		if err := m.splitWindow(e, false); err != nil {
+			m.eventCh <- event.Event{Type: event.Error, Error: err}
+		} else if e.Arg != "" {
+			m.eventCh <- m.openedEvent()
+		} else {
+			m.eventCh <- event.Event{Type: event.Redraw}
+		}
+	case event.Vsplit:
+		if err := m.splitWindow(e, true); err != nil {
+			m.eventCh <- event.Event{Type: event.Error, Error: err}
+		} else if e.Arg != "" {
+			m.eventCh <- m.openedEvent()
 		} else {
 			m.eventCh <- event.Event{Type: event.Redraw}
 		}
@@ -214,143 +666,1179 @@ func (m *Manager) Emit(e event.Event) {
 		} else {
 			m.eventCh <- event.Event{Type: event.Redraw}
 		}
-	case event.Quit:
-		if err := m.quit(e); err != nil {
+	case event.IncreaseWindowHeight:
+		m.adjustWindow(0, int(mathutil.MaxInt64(e.Count, 1)))
+		m.eventCh <- event.Event{Type: event.Redraw}
+	case event.DecreaseWindowHeight:
+		m.adjustWindow(0, -int(mathutil.MaxInt64(e.Count, 1)))
+		m.eventCh <- event.Event{Type: event.Redraw}
+	case event.IncreaseWindowWidth:
+		m.adjustWindow(int(mathutil.MaxInt64(e.Count, 1)), 0)
+		m.eventCh <- event.Event{Type: event.Redraw}
+	case event.DecreaseWindowWidth:
+		m.adjustWindow(-int(mathutil.MaxInt64(e.Count, 1)), 0)
+		m.eventCh <- event.Event{Type: event.Redraw}
+	case event.EqualizeWindows:
+		m.equalizeWindows()
+		m.eventCh <- event.Event{Type: event.Redraw}
+	case event.InsertBytes:
+		if err := m.insertBytes(e); err != nil {
 			m.eventCh <- event.Event{Type: event.Error, Error: err}
 		}
-	case event.Write:
-		if err := m.write(e); err != nil {
+	case event.PasteText:
+		if err := m.pasteText(e); err != nil {
 			m.eventCh <- event.Event{Type: event.Error, Error: err}
 		}
-	case event.WriteQuit:
-		if err := m.writeQuit(e); err != nil {
+	case event.Fill:
+		if err := m.fill(e); err != nil {
 			m.eventCh <- event.Event{Type: event.Error, Error: err}
 		}
-	default:
-		m.windows[m.windowIndex].eventCh <- e
-	}
-}
-
-func (m *Manager) edit(e event.Event) error {
-	m.mu.Lock()
-	defer m.mu.Unlock()
-	var name string
-	if len(e.Arg) == 0 {
-		name = m.windows[m.windowIndex].filename
-	} else {
-		name = e.Arg
-	}
-	window, err := m.open(name)
-	if err != nil {
-		return err
-	}
-	go window.run()
-	m.windows = append(m.windows, window)
-	m.windowIndex, m.prevWindowIndex = len(m.windows)-1, m.windowIndex
-	m.layout = m.layout.Replace(m.windowIndex)
-	return nil
-}
-
-func (m *Manager) newWindow(e event.Event, vertical bool) error {
-	m.mu.Lock()
-	defer m.mu.Unlock()
-	window, err := m.open(e.Arg)
-	if err != nil {
-		return err
-	}
-	go window.run()
-	m.windows = append(m.windows, window)
-	m.windowIndex, m.prevWindowIndex = len(m.windows)-1, m.windowIndex
-	if vertical {
-		m.layout = m.layout.SplitLeft(m.windowIndex).Resize(0, 0, m.width, m.height)
-	} else {
-		m.layout = m.layout.SplitTop(m.windowIndex).Resize(0, 0, m.width, m.height)
-	}
-	return nil
-}
-
-func (m *Manager) wincmd(arg string) error {
-	switch arg {
-	case "n":
-		return m.newWindow(event.Event{}, false)
-	case "l":
-		m.focus(func(x, y layout.Window) bool {
-			return x.LeftMargin()+x.Width()+1 == y.LeftMargin() &&
-				y.TopMargin() <= x.TopMargin() &&
-				x.TopMargin() < y.TopMargin()+y.Height()
-		})
-	case "h":
-		m.focus(func(x, y layout.Window) bool {
-			return y.LeftMargin()+y.Width()+1 == x.LeftMargin() &&
-				y.TopMargin() <= x.TopMargin() &&
-				x.TopMargin() < y.TopMargin()+y.Height()
-		})
-	case "k":
-		m.focus(func(x, y layout.Window) bool {
-			return y.TopMargin()+y.Height() == x.TopMargin() &&
-				y.LeftMargin() <= x.LeftMargin() &&
-				x.LeftMargin() < y.LeftMargin()+y.Width()
-		})
-	case "j":
-		m.focus(func(x, y layout.Window) bool {
-			return x.TopMargin()+x.Height() == y.TopMargin() &&
-				y.LeftMargin() <= x.LeftMargin() &&
-				x.LeftMargin() < y.LeftMargin()+y.Width()
-		})
-	case "t":
-		m.focus(func(_, y layout.Window) bool {
-			return y.LeftMargin() == 0 && y.TopMargin() == 0
-		})
-	case "b":
-		m.focus(func(_, y layout.Window) bool {
-			return m.layout.LeftMargin()+m.layout.Width() == y.LeftMargin()+y.Width() &&
-				m.layout.TopMargin()+m.layout.Height() == y.TopMargin()+y.Height()
-		})
-	case "p":
-		m.focus(func(_, y layout.Window) bool {
-			return y.Index == m.prevWindowIndex
-		})
-	case "K":
-		m.move(func(x layout.Window, y layout.Layout) layout.Layout {
-			return layout.Horizontal{Top: x, Bottom: y}
-		})
-	case "J":
-		m.move(func(x layout.Window, y layout.Layout) layout.Layout {
-			return layout.Horizontal{Top: y, Bottom: x}
-		})
-	case "H":
-		m.move(func(x layout.Window, y layout.Layout) layout.Layout {
-			return layout.Vertical{Left: x, Right: y}
-		})
+	case event.Compress:
+		if err := m.compress(e); err != nil {
+			m.eventCh <- event.Event{Type: event.Error, Error: err}
+		}
+	case event.Substitute:
+		if err := m.substitute(e); err != nil {
+			m.eventCh <- event.Event{Type: event.Error, Error: err}
+		}
+	case event.ConfirmSubstitute:
+		m.mu.Lock()
+		quitting := m.quitting != nil
+		m.mu.Unlock()
+		if quitting {
+			if err := m.confirmQuit(e); err != nil {
+				m.eventCh <- event.Event{Type: event.Error, Error: err}
+			}
+		} else if err := m.confirmSubstitute(e); err != nil {
+			m.eventCh <- event.Event{Type: event.Error, Error: err}
+		}
+	case event.Yank:
+		if err := m.yank(e); err != nil {
+			m.eventCh <- event.Event{Type: event.Error, Error: err}
+		} else {
+			m.redrawCh <- struct{}{}
+		}
+	case event.Paste, event.PasteBefore, event.ReplaceVisual:
+		if err := m.paste(e); err != nil {
+			m.eventCh <- event.Event{Type: event.Error, Error: err}
+		}
+	case event.Copy:
+		if err := m.copyValue(e); err != nil {
+			m.eventCh <- event.Event{Type: event.Error, Error: err}
+		}
+	case event.Disassemble:
+		if err := m.disassemble(e); err != nil {
+			m.eventCh <- event.Event{Type: event.Error, Error: err}
+		}
+	case event.Plot:
+		if err := m.plot(e); err != nil {
+			m.eventCh <- event.Event{Type: event.Error, Error: err}
+		}
+	case event.Preview:
+		if err := m.preview(e); err != nil {
+			m.eventCh <- event.Event{Type: event.Error, Error: err}
+		}
+	case event.FixChecksum:
+		if err := m.fixChecksum(e); err != nil {
+			m.eventCh <- event.Event{Type: event.Error, Error: err}
+		}
+	case event.Bookmark:
+		if err := m.bookmark(e); err != nil {
+			m.eventCh <- event.Event{Type: event.Error, Error: err}
+		}
+	case event.CancelTask:
+		m.cancelTask()
+	case event.FinishTask:
+		if err := m.finishTask(); err != nil {
+			m.eventCh <- event.Event{Type: event.Error, Error: err}
+		}
+	case event.SearchAll:
+		if err := m.searchAll(e); err != nil {
+			m.eventCh <- event.Event{Type: event.Error, Error: err}
+		}
+	case event.Strings:
+		if err := m.extractStrings(e); err != nil {
+			m.eventCh <- event.Event{Type: event.Error, Error: err}
+		}
+	case event.Sections:
+		if err := m.listSections(e); err != nil {
+			m.eventCh <- event.Event{Type: event.Error, Error: err}
+		}
+	case event.GotoSection:
+		if err := m.gotoSection(e); err != nil {
+			m.eventCh <- event.Event{Type: event.Error, Error: err}
+		}
+	case event.Identify:
+		if err := m.identify(e); err != nil {
+			m.eventCh <- event.Event{Type: event.Error, Error: err}
+		}
+	case event.Scan:
+		if err := m.scan(e); err != nil {
+			m.eventCh <- event.Event{Type: event.Error, Error: err}
+		}
+	case event.Carve:
+		if err := m.carve(e); err != nil {
+			m.eventCh <- event.Event{Type: event.Error, Error: err}
+		}
+	case event.XorScan:
+		if err := m.xorscan(e); err != nil {
+			m.eventCh <- event.Event{Type: event.Error, Error: err}
+		}
+	case event.CompareStat:
+		if err := m.compareStat(e); err != nil {
+			m.eventCh <- event.Event{Type: event.Error, Error: err}
+		}
+	case event.Overlay:
+		if err := m.loadOverlay(e); err != nil {
+			m.eventCh <- event.Event{Type: event.Error, Error: err}
+		}
+	case event.Overlays:
+		if err := m.listOverlay(e); err != nil {
+			m.eventCh <- event.Event{Type: event.Error, Error: err}
+		}
+	case event.ToggleOverlay:
+		if err := m.toggleOverlay(e); err != nil {
+			m.eventCh <- event.Event{Type: event.Error, Error: err}
+		} else {
+			m.redrawCh <- struct{}{}
+		}
+	case event.CommitOverlay, event.DiscardOverlay:
+		if err := m.commitOverlay(e); err != nil {
+			m.eventCh <- event.Event{Type: event.Error, Error: err}
+		}
+	case event.Packets:
+		if err := m.listPackets(e); err != nil {
+			m.eventCh <- event.Event{Type: event.Error, Error: err}
+		}
+	case event.QuickfixList:
+		if err := m.listQuickfix(e); err != nil {
+			m.eventCh <- event.Event{Type: event.Error, Error: err}
+		}
+	case event.QuickfixGoto, event.QuickfixNext, event.QuickfixPrevious,
+		event.QuickfixFirst, event.QuickfixLast:
+		if err := m.gotoQuickfix(e); err != nil {
+			m.eventCh <- event.Event{Type: event.Error, Error: err}
+		}
+	case event.Bookmarks:
+		if err := m.listBookmarks(e); err != nil {
+			m.eventCh <- event.Event{Type: event.Error, Error: err}
+		}
+	case event.GotoBookmark:
+		if err := m.gotoBookmark(e); err != nil {
+			m.eventCh <- event.Event{Type: event.Error, Error: err}
+		}
+	case event.DeleteBookmark:
+		if err := m.deleteBookmark(e); err != nil {
+			m.eventCh <- event.Event{Type: event.Error, Error: err}
+		}
+	case event.Annotate:
+		if err := m.annotate(e); err != nil {
+			m.eventCh <- event.Event{Type: event.Error, Error: err}
+		} else {
+			m.eventCh <- event.Event{Type: event.Redraw}
+		}
+	case event.Annotations:
+		if err := m.listAnnotations(e); err != nil {
+			m.eventCh <- event.Event{Type: event.Error, Error: err}
+		}
+	case event.DeleteAnnotation:
+		if err := m.deleteAnnotation(e); err != nil {
+			m.eventCh <- event.Event{Type: event.Error, Error: err}
+		} else {
+			m.eventCh <- event.Event{Type: event.Redraw}
+		}
+	case event.Highlight:
+		if msg, err := m.highlight(e); err != nil {
+			m.eventCh <- event.Event{Type: event.Error, Error: err}
+		} else if msg != "" {
+			m.eventCh <- event.Event{Type: event.Info, Error: errors.New(msg)}
+		} else {
+			m.eventCh <- event.Event{Type: event.Redraw}
+		}
+	case event.NoHighlight:
+		if err := m.noHighlight(e); err != nil {
+			m.eventCh <- event.Event{Type: event.Error, Error: err}
+		} else {
+			m.eventCh <- event.Event{Type: event.Redraw}
+		}
+	case event.Set:
+		if msg, err := m.set(e); err != nil {
+			m.eventCh <- event.Event{Type: event.Error, Error: err}
+		} else if msg != "" {
+			m.eventCh <- event.Event{Type: event.Info, Error: errors.New(msg)}
+		} else {
+			m.eventCh <- event.Event{Type: event.Redraw}
+		}
+	case event.Changes:
+		if err := m.listChanges(e); err != nil {
+			m.eventCh <- event.Event{Type: event.Error, Error: err}
+		}
+	case event.UndoList:
+		if err := m.listUndo(e); err != nil {
+			m.eventCh <- event.Event{Type: event.Error, Error: err}
+		}
+	case event.UndoPartial:
+		if err := m.undoPartial(e); err != nil {
+			m.eventCh <- event.Event{Type: event.Error, Error: err}
+		}
+	case event.Undo, event.Redo:
+		m.undo(e)
+	case event.Quit:
+		if err := m.quit(e); err != nil {
+			m.eventCh <- event.Event{Type: event.Error, Error: err}
+		}
+	case event.Write:
+		if err := m.write(e); err != nil {
+			m.eventCh <- event.Event{Type: event.Error, Error: err}
+		}
+	case event.WriteQuit:
+		if err := m.writeQuit(e); err != nil {
+			m.eventCh <- event.Event{Type: event.Error, Error: err}
+		}
+	case event.QuitAll:
+		if err := m.quitAll(e); err != nil {
+			m.eventCh <- event.Event{Type: event.Error, Error: err}
+		}
+	case event.WriteQuitAll:
+		if err := m.writeQuitAll(e); err != nil {
+			m.eventCh <- event.Event{Type: event.Error, Error: err}
+		}
+	case event.ExitInsert:
+		if err := m.exitInsert(e); err != nil {
+			m.eventCh <- event.Event{Type: event.Error, Error: err}
+		}
+	case event.Encrypt:
+		if err := m.encrypt(e); err != nil {
+			m.eventCh <- event.Event{Type: event.Error, Error: err}
+		}
+	case event.SetPassphrase:
+		if err := m.setPassphrase(e); err != nil {
+			m.eventCh <- event.Event{Type: event.Error, Error: err}
+		}
+	case event.Recover:
+		if err := m.recover(e); err != nil {
+			m.eventCh <- event.Event{Type: event.Error, Error: err}
+		} else {
+			m.eventCh <- event.Event{Type: event.Redraw}
+		}
+	case event.ExecuteSearch, event.NextSearch, event.PreviousSearch:
+		target, err := event.Unescape(e.Arg)
+		if err != nil {
+			m.eventCh <- event.Event{Type: event.Error, Error: err}
+			break
+		}
+		e.Arg = string(target)
+		m.windows[m.windowIndex].eventCh <- e
+	default:
+		m.windows[m.windowIndex].eventCh <- e
+	}
+}
+
+// rebaseRange converts the absolute positions of the range, which are the
+// addresses offset by the baseaddress option, to the offsets in the buffer.
+func rebaseRange(r *event.Range, base int64) *event.Range {
+	if base == 0 {
+		return r
+	}
+	rebase := func(pos event.Position) event.Position {
+		if p, ok := pos.(event.Absolute); ok {
+			return event.Absolute{Offset: p.Offset - base}
+		}
+		return pos
+	}
+	return &event.Range{From: rebase(r.From), To: rebase(r.To)}
+}
+
+func isEdit(typ event.Type) bool {
+	switch typ {
+	case event.StartInsert, event.StartInsertHead, event.StartAppend,
+		event.StartAppendEnd, event.StartReplaceByte, event.StartReplace,
+		event.DeleteByte, event.DeletePrevByte, event.Increment, event.Decrement,
+		event.InsertBytes, event.InsertExpression, event.PasteText, event.Paste, event.PasteBefore,
+		event.ReplaceVisual, event.Fill, event.Compress, event.Substitute, event.CommitOverlay, event.FixChecksum,
+		event.Undo, event.Redo, event.UndoPartial:
+		return true
+	}
+	return false
+}
+
+// Modifiable returns an error when the buffer of the current window is
+// read-only or not modifiable.
+func (m *Manager) Modifiable() error {
+	m.mu.Lock()
+	window := m.windows[m.windowIndex]
+	m.mu.Unlock()
+	if !window.options.Bool("modifiable") {
+		return errors.New("cannot make changes, 'modifiable' is off")
+	}
+	if window.options.Bool("readonly") {
+		return errors.New("cannot make changes, 'readonly' is set")
+	}
+	return nil
+}
+
+func (m *Manager) edit(e event.Event) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	current := m.windows[m.windowIndex]
+	name := e.Arg
+	if name == "" {
+		name = current.filename
+	}
+	reload := name == current.filename
+	if reload && !e.Bang && current.isModified() {
+		return errors.New("no write since last change (add ! to override)")
+	}
+	if reload {
+		if err := current.removeSwap(); err != nil {
+			return err
+		}
+	}
+	window, err := m.open(name)
+	if err != nil {
+		return err
+	}
+	if reload {
+		window.inherit(current)
+		if m.quickfix != nil && m.quickfix.window == current {
+			m.quickfix.window = window
+		}
+	}
+	m.runWindow(window)
+	m.windows = append(m.windows, window)
+	m.windowIndex, m.prevWindowIndex = len(m.windows)-1, m.windowIndex
+	m.layout = m.layout.Replace(m.windowIndex)
+	return nil
+}
+
+func (m *Manager) newWindow(e event.Event, vertical bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	window, err := m.open(e.Arg)
+	if err != nil {
+		return err
+	}
+	m.addWindow(window, vertical)
+	return nil
+}
+
+// splitWindow opens a new view of the active window, which shares the buffer
+// and the history. The file is opened in a new window when it is specified.
+func (m *Manager) splitWindow(e event.Event, vertical bool) error {
+	if e.Arg != "" {
+		return m.newWindow(e, vertical)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.addWindow(m.windows[m.windowIndex].view(), vertical)
+	return nil
+}
+
+func (m *Manager) addWindow(window *window, vertical bool) {
+	m.runWindow(window)
+	m.windows = append(m.windows, window)
+	m.windowIndex, m.prevWindowIndex = len(m.windows)-1, m.windowIndex
+	if vertical {
+		m.layout = m.layout.SplitLeft(m.windowIndex).Resize(0, 0, m.width, m.height)
+	} else {
+		m.layout = m.layout.SplitTop(m.windowIndex).Resize(0, 0, m.width, m.height)
+	}
+}
+
+func (m *Manager) wincmd(arg string) error {
+	switch arg {
+	case "n":
+		return m.newWindow(event.Event{}, false)
+	case "s":
+		return m.splitWindow(event.Event{}, false)
+	case "v":
+		return m.splitWindow(event.Event{}, true)
+	case "l":
+		m.focus(func(x, y layout.Window) bool {
+			return x.LeftMargin()+x.Width()+1 == y.LeftMargin() &&
+				y.TopMargin() <= x.TopMargin() &&
+				x.TopMargin() < y.TopMargin()+y.Height()
+		})
+	case "h":
+		m.focus(func(x, y layout.Window) bool {
+			return y.LeftMargin()+y.Width()+1 == x.LeftMargin() &&
+				y.TopMargin() <= x.TopMargin() &&
+				x.TopMargin() < y.TopMargin()+y.Height()
+		})
+	case "k":
+		m.focus(func(x, y layout.Window) bool {
+			return y.TopMargin()+y.Height() == x.TopMargin() &&
+				y.LeftMargin() <= x.LeftMargin() &&
+				x.LeftMargin() < y.LeftMargin()+y.Width()
+		})
+	case "j":
+		m.focus(func(x, y layout.Window) bool {
+			return x.TopMargin()+x.Height() == y.TopMargin() &&
+				y.LeftMargin() <= x.LeftMargin() &&
+				x.LeftMargin() < y.LeftMargin()+y.Width()
+		})
+	case "t":
+		m.focus(func(_, y layout.Window) bool {
+			return y.LeftMargin() == 0 && y.TopMargin() == 0
+		})
+	case "b":
+		m.focus(func(_, y layout.Window) bool {
+			return m.layout.LeftMargin()+m.layout.Width() == y.LeftMargin()+y.Width() &&
+				m.layout.TopMargin()+m.layout.Height() == y.TopMargin()+y.Height()
+		})
+	case "p":
+		m.focus(func(_, y layout.Window) bool {
+			return y.Index == m.prevWindowIndex
+		})
+	case "K":
+		m.move(func(x layout.Window, y layout.Layout) layout.Layout {
+			return layout.Horizontal{Top: x, Bottom: y}
+		})
+	case "J":
+		m.move(func(x layout.Window, y layout.Layout) layout.Layout {
+			return layout.Horizontal{Top: y, Bottom: x}
+		})
+	case "H":
+		m.move(func(x layout.Window, y layout.Layout) layout.Layout {
+			return layout.Vertical{Left: x, Right: y}
+		})
 	case "L":
 		m.move(func(x layout.Window, y layout.Layout) layout.Layout {
 			return layout.Vertical{Left: y, Right: x}
 		})
+	case "+":
+		m.adjustWindow(0, 1)
+	case "-":
+		m.adjustWindow(0, -1)
+	case ">":
+		m.adjustWindow(1, 0)
+	case "<":
+		m.adjustWindow(-1, 0)
+	case "=":
+		m.equalizeWindows()
 	default:
 		return fmt.Errorf("Invalid argument for wincmd: %s", arg)
 	}
 	return nil
 }
 
-func (m *Manager) focus(search func(layout.Window, layout.Window) bool) {
-	m.mu.Lock()
-	defer m.mu.Unlock()
-	activeWindow := m.layout.ActiveWindow()
-	newWindow := m.layout.Lookup(func(l layout.Window) bool {
-		return search(activeWindow, l)
+func (m *Manager) focus(search func(layout.Window, layout.Window) bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	activeWindow := m.layout.ActiveWindow()
+	newWindow := m.layout.Lookup(func(l layout.Window) bool {
+		return search(activeWindow, l)
+	})
+	if newWindow.Index >= 0 {
+		m.windowIndex, m.prevWindowIndex = newWindow.Index, m.windowIndex
+		m.layout = m.layout.Activate(m.windowIndex)
+	}
+}
+
+func (m *Manager) move(modifier func(layout.Window, layout.Layout) layout.Layout) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	activeWindow := m.layout.ActiveWindow()
+	m.layout = modifier(activeWindow, m.layout.Close()).Activate(
+		activeWindow.Index).Resize(0, 0, m.width, m.height)
+}
+
+// adjustWindow adjusts the size of the active window. The windows are resized
+// on the next state, which sets the size of each window.
+func (m *Manager) adjustWindow(width, height int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.layout = m.layout.AdjustWidth(width).AdjustHeight(height).Resize(0, 0, m.width, m.height)
+}
+
+func (m *Manager) equalizeWindows() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.layout = m.layout.Equalize().Resize(0, 0, m.width, m.height)
+}
+
+func (m *Manager) insertBytes(e event.Event) error {
+	args, err := event.SplitArgs(e.Arg)
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 {
+		return fmt.Errorf("an argument is required for %s", e.CmdName)
+	}
+	if len(args) > 2 {
+		return fmt.Errorf("too many arguments for %s", e.CmdName)
+	}
+	count, err := strconv.ParseInt(args[0], 0, 64)
+	if err != nil || count <= 0 {
+		return fmt.Errorf("invalid count for %s: %s", e.CmdName, args[0])
+	}
+	pattern := []byte{0x00}
+	if len(args) > 1 {
+		if pattern, err = parseBytePattern(args[1]); err != nil {
+			return err
+		}
+	}
+	e.Count, e.Bytes = count, pattern
+	return m.insertLarge(m.windows[m.windowIndex], e, count, pattern)
+}
+
+// insertLarge sends the event inserting the bytes to the window, or spools the
+// large insertion to the temporary file in the background, not to block the
+// window while writing hundreds of megabytes.
+func (m *Manager) insertLarge(window *window, e event.Event, count int64, pattern []byte) error {
+	waitWindow(window)
+	window.mu.Lock()
+	spool, err := window.checkInsert(count)
+	window.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if !spool {
+		window.eventCh <- e
+		return nil
+	}
+	return m.startTask("inserting", count, func(t *task) (func() error, error) {
+		f, err := spoolBytes(count, pattern, t)
+		if err != nil {
+			return nil, err
+		}
+		return func() error {
+			return m.insertSpool(window, e, f, count)
+		}, nil
+	})
+}
+
+// insertSpool inserts the spooled bytes to the window, unless the window is
+// closed during the spooling.
+func (m *Manager) insertSpool(window *window, e event.Event, f *os.File, count int64) error {
+	m.mu.Lock()
+	var found bool
+	for _, w := range m.windows {
+		found = found || w == window
+	}
+	m.mu.Unlock()
+	if !found {
+		f.Close()
+		return os.Remove(f.Name())
+	}
+	waitWindow(window)
+	window.mu.Lock()
+	err := window.insertSpool(e, f, count)
+	window.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	m.eventCh <- event.Event{Type: event.Redraw}
+	return nil
+}
+
+// exitInsert repeats the bytes inserted in the insert mode started with the
+// count, and exits the insert mode. The large repetition is spooled in the
+// background, and inserted after the inserted bytes.
+func (m *Manager) exitInsert(e event.Event) error {
+	window := m.windows[m.windowIndex]
+	waitWindow(window)
+	window.mu.Lock()
+	r, err := window.repeatInsert()
+	window.mu.Unlock()
+	window.eventCh <- e
+	if err != nil || r == nil {
+		return err
+	}
+	return m.startTask("inserting", r.count, func(t *task) (func() error, error) {
+		f, err := spoolBytes(r.count, r.pattern, t)
+		if err != nil {
+			return nil, err
+		}
+		return func() error {
+			window.mu.Lock()
+			window.cursorGotoPos(event.Absolute{Offset: r.offset - 1})
+			window.mu.Unlock()
+			return m.insertSpool(window, event.Event{Type: event.Paste}, f, r.count)
+		}, nil
+	})
+}
+
+// pasteText inserts the text pasted from the terminal in insert mode. The text
+// is parsed as the hex digits ignoring the whitespaces and 0x prefixes, unless
+// the text column is focused.
+func (m *Manager) pasteText(e event.Event) error {
+	window := m.windows[m.windowIndex]
+	window.mu.Lock()
+	focusText := window.focusText
+	window.mu.Unlock()
+	if focusText {
+		e.Bytes = []byte(e.Arg)
+	} else {
+		var err error
+		if e.Bytes, err = parseHexText(e.Arg); err != nil {
+			return err
+		}
+	}
+	if len(e.Bytes) > 0 {
+		window.eventCh <- e
+	}
+	return nil
+}
+
+// parseHexText parses the hex digits separated by the whitespaces, each of
+// which may have the 0x prefix.
+func parseHexText(s string) ([]byte, error) {
+	var sb strings.Builder
+	for _, w := range strings.Fields(s) {
+		if strings.HasPrefix(w, "0x") || strings.HasPrefix(w, "0X") {
+			w = w[2:]
+		}
+		sb.WriteString(w)
+	}
+	bs, err := hex.DecodeString(sb.String())
+	if err != nil {
+		return nil, errors.New("the pasted text is not hex digits")
+	}
+	return bs, nil
+}
+
+// fill overwrites the bytes of the range, or the byte at the cursor, with the
+// pattern repeatedly. The visual block selection is filled for '<,'>.
+func (m *Manager) fill(e event.Event) error {
+	args, err := event.SplitArgs(e.Arg)
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 {
+		return fmt.Errorf("an argument is required for %s", e.CmdName)
+	}
+	if len(args) > 1 {
+		return fmt.Errorf("too many arguments for %s", e.CmdName)
+	}
+	pattern, err := parseBytePattern(args[0])
+	if err != nil {
+		return err
+	}
+	e.Bytes = pattern
+	m.windows[m.windowIndex].eventCh <- e
+	return nil
+}
+
+// parseBytePattern parses a byte value (255, 0xff, 0377), a sequence of
+// hex digits with 0x prefix (0xdeadbeef), or the quoted or escaped string
+// ("AB\x00", \xde\xad).
+func parseBytePattern(s string) ([]byte, error) {
+	if s[0] == '"' || s[0] == '\'' || strings.ContainsRune(s, '\\') {
+		bs, err := event.UnquoteArg(s)
+		if err != nil {
+			return nil, err
+		}
+		if len(bs) == 0 {
+			return nil, fmt.Errorf("invalid byte pattern: %s", s)
+		}
+		return bs, nil
+	}
+	if b, err := strconv.ParseUint(s, 0, 8); err == nil {
+		return []byte{byte(b)}, nil
+	}
+	if strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0X") {
+		if bs, err := hex.DecodeString(s[2:]); err == nil && len(bs) > 0 {
+			return bs, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid byte pattern: %s", s)
+}
+
+func (m *Manager) bookmark(e event.Event) error {
+	if e.Arg == "" {
+		return fmt.Errorf("an argument is required for %s", e.CmdName)
+	}
+	window := m.windows[m.windowIndex]
+	if window.filename == "" {
+		return errors.New("no file name")
+	}
+	window.mu.Lock()
+	defer window.mu.Unlock()
+	offset := window.cursor
+	if e.Range != nil {
+		var err error
+		if offset, err = window.positionToOffset(e.Range.From); err != nil {
+			return err
+		}
+	}
+	bookmarks := addBookmark(window.bookmarks, e.Arg, offset)
+	if err := saveBookmarks(window.filename, bookmarks); err != nil {
+		return err
+	}
+	window.bookmarks, window.marksErr = bookmarks, nil
+	m.eventCh <- event.Event{Type: event.Info, Error: fmt.Errorf("bookmark %s: 0x%x", e.Arg, offset)}
+	return nil
+}
+
+func (m *Manager) listBookmarks(e event.Event) error {
+	if len(e.Arg) > 0 {
+		return fmt.Errorf("too many arguments for %s", e.CmdName)
+	}
+	window := m.windows[m.windowIndex]
+	window.mu.Lock()
+	defer window.mu.Unlock()
+	if len(window.bookmarks) == 0 {
+		return errors.New("no bookmarks")
+	}
+	lines := make([]string, len(window.bookmarks))
+	for i, b := range window.bookmarks {
+		lines[i] = fmt.Sprintf("0x%08x %10d  %s", b.Offset, b.Offset, b.Label)
+	}
+	m.eventCh <- event.Event{Type: event.Info, Error: errors.New(strings.Join(lines, "\n"))}
+	return nil
+}
+
+func (m *Manager) gotoBookmark(e event.Event) error {
+	if e.Arg == "" {
+		return fmt.Errorf("an argument is required for %s", e.CmdName)
+	}
+	window := m.windows[m.windowIndex]
+	window.mu.Lock()
+	i := findBookmark(window.bookmarks, e.Arg)
+	var offset int64
+	if i >= 0 {
+		offset = window.bookmarks[i].Offset
+	}
+	window.mu.Unlock()
+	if i < 0 {
+		return fmt.Errorf("bookmark not found: %s", e.Arg)
+	}
+	window.eventCh <- event.Event{
+		Type:  event.CursorGoto,
+		Range: &event.Range{From: event.Absolute{Offset: offset}},
+		Mode:  e.Mode,
+	}
+	return nil
+}
+
+func (m *Manager) deleteBookmark(e event.Event) error {
+	if e.Arg == "" {
+		return fmt.Errorf("an argument is required for %s", e.CmdName)
+	}
+	window := m.windows[m.windowIndex]
+	window.mu.Lock()
+	defer window.mu.Unlock()
+	i := findBookmark(window.bookmarks, e.Arg)
+	if i < 0 {
+		return fmt.Errorf("bookmark not found: %s", e.Arg)
+	}
+	bookmarks := append(window.bookmarks[:i:i], window.bookmarks[i+1:]...)
+	if err := saveBookmarks(window.filename, bookmarks); err != nil {
+		return err
+	}
+	window.bookmarks, window.marksErr = bookmarks, nil
+	return nil
+}
+
+func (m *Manager) annotate(e event.Event) error {
+	if e.Arg == "" {
+		return fmt.Errorf("an argument is required for %s", e.CmdName)
+	}
+	window := m.windows[m.windowIndex]
+	if window.filename == "" {
+		return errors.New("no file name")
+	}
+	a, hasRange, err := parseAnnotation(e.Arg)
+	if err != nil {
+		return err
+	}
+	window.mu.Lock()
+	defer window.mu.Unlock()
+	if !hasRange {
+		a.From, a.To = window.cursor, window.cursor
+		if e.Range != nil {
+			if a.From, err = window.positionToOffset(e.Range.From); err != nil {
+				return err
+			}
+			a.To = a.From
+			if e.Range.To != nil {
+				if a.To, err = window.positionToOffset(e.Range.To); err != nil {
+					return err
+				}
+			}
+			if a.From > a.To {
+				a.From, a.To = a.To, a.From
+			}
+		}
+	}
+	annotations := addAnnotation(window.annotations, a)
+	if err := saveAnnotations(window.filename, annotations); err != nil {
+		return err
+	}
+	window.annotations, window.notesErr = annotations, nil
+	return nil
+}
+
+func (m *Manager) listAnnotations(e event.Event) error {
+	if len(e.Arg) > 0 {
+		return fmt.Errorf("too many arguments for %s", e.CmdName)
+	}
+	window := m.windows[m.windowIndex]
+	window.mu.Lock()
+	defer window.mu.Unlock()
+	if len(window.annotations) == 0 {
+		return errors.New("no annotations")
+	}
+	lines := make([]string, len(window.annotations))
+	for i, a := range window.annotations {
+		lines[i] = fmt.Sprintf("0x%08x-0x%08x %-8s %s", a.From, a.To, a.Color, a.Note)
+	}
+	m.eventCh <- event.Event{Type: event.Info, Error: errors.New(strings.Join(lines, "\n"))}
+	return nil
+}
+
+func (m *Manager) deleteAnnotation(e event.Event) error {
+	if len(e.Arg) > 0 {
+		return fmt.Errorf("too many arguments for %s", e.CmdName)
+	}
+	window := m.windows[m.windowIndex]
+	window.mu.Lock()
+	defer window.mu.Unlock()
+	annotations, n := deleteAnnotations(window.annotations, window.cursor)
+	if n == 0 {
+		return errors.New("no annotation at the cursor")
+	}
+	if err := saveAnnotations(window.filename, annotations); err != nil {
+		return err
+	}
+	window.annotations, window.notesErr = annotations, nil
+	return nil
+}
+
+// highlight adds the rule, or returns the list of the rules without the
+// argument.
+func (m *Manager) highlight(e event.Event) (string, error) {
+	if e.Arg == "" {
+		return m.listHighlights()
+	}
+	xs := strings.SplitN(e.Arg, " ", 2)
+	if len(xs) < 2 {
+		return "", fmt.Errorf("a pattern is required for %s", e.CmdName)
+	}
+	return "", m.AddHighlight(xs[0], xs[1])
+}
+
+// AddHighlight adds the rule to highlight the bytes matching the pattern.
+func (m *Manager) AddHighlight(color, pattern string) error {
+	rule, err := highlight.NewRule(color, pattern)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.highlights = append(m.highlights, rule)
+	return nil
+}
+
+func (m *Manager) listHighlights() (string, error) {
+	m.mu.Lock()
+	lines := make([]string, len(m.highlights))
+	for i, r := range m.highlights {
+		lines[i] = fmt.Sprintf("%-8s %s", r.Color, r.Pattern)
+	}
+	m.mu.Unlock()
+	if len(lines) == 0 {
+		return "", errors.New("no highlight rules")
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+func (m *Manager) noHighlight(e event.Event) error {
+	if len(e.Arg) > 0 {
+		return fmt.Errorf("too many arguments for %s", e.CmdName)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.highlights = nil
+	return nil
+}
+
+// set applies the options, and returns the values of the queried options.
+func (m *Manager) set(e event.Event) (string, error) {
+	window := m.windows[m.windowIndex]
+	args := option.SplitArgs(e.Arg)
+	if len(args) == 0 {
+		args = option.Names()
+		for i, name := range args {
+			args[i] = name + "?"
+		}
+	}
+	var lines []string
+	for _, arg := range args {
+		s, err := option.Parse(arg)
+		if err != nil {
+			return "", err
+		}
+		options := m.options
+		if s.Definition.Local {
+			options = window.options
+		}
+		if s.IsQuery() {
+			lines = append(lines, options.Format(s.Definition.Name))
+			continue
+		}
+		if s.Definition.Name == "schema" {
+			o := options.Clone()
+			o.Apply(s)
+			if _, err := schema.Parse(o.String("schema")); err != nil {
+				return "", err
+			}
+		}
+		if s.Definition.Name == "encoding" {
+			o := options.Clone()
+			o.Apply(s)
+			switch encoding := o.String("encoding"); encoding {
+			case "ascii", "utf-8", "shift_jis":
+			default:
+				return "", fmt.Errorf("unknown encoding: %s", encoding)
+			}
+		}
+		if s.Definition.Name == "rowsum" {
+			o := options.Clone()
+			o.Apply(s)
+			switch kind := o.String("rowsum"); kind {
+			case "", "sum8", "xor8", "crc8":
+			default:
+				return "", fmt.Errorf("unknown checksum: %s", kind)
+			}
+		}
+		options.Apply(s)
+		if s.Definition.Local {
+			m.options.Apply(s)
+		}
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// undo sends the undo or redo event to the current window, and reports the
+// changes undone or redone.
+func (m *Manager) undo(e event.Event) {
+	window := m.windows[m.windowIndex]
+	window.eventCh <- e
+	waitWindow(window)
+	window.mu.Lock()
+	msg := window.undoMessage
+	window.mu.Unlock()
+	m.eventCh <- event.Event{Type: event.Info, Error: errors.New(msg)}
+}
+
+// undoPartial restores the bytes overwritten in the last replace mode one by
+// one, while undo reverts the whole overwriting at once.
+func (m *Manager) undoPartial(e event.Event) error {
+	count := int64(1)
+	if len(e.Arg) > 0 {
+		var err error
+		if count, err = strconv.ParseInt(e.Arg, 0, 64); err != nil || count <= 0 {
+			return fmt.Errorf("invalid count for %s: %s", e.CmdName, e.Arg)
+		}
+	}
+	window := m.windows[m.windowIndex]
+	window.mu.Lock()
+	ok := window.canRestoreOvertype()
+	window.mu.Unlock()
+	if !ok {
+		return errors.New("no replaced bytes to undo")
+	}
+	e.Count = count
+	window.eventCh <- e
+	return nil
+}
+
+func (m *Manager) listUndo(e event.Event) error {
+	if len(e.Arg) > 0 {
+		return fmt.Errorf("too many arguments for %s", e.CmdName)
+	}
+	window := m.windows[m.windowIndex]
+	window.mu.Lock()
+	changes, index := window.history.Changes()
+	window.mu.Unlock()
+	if len(changes) <= 1 {
+		return errors.New("no changes")
+	}
+	lines := make([]string, 0, len(changes)-1)
+	for i, c := range changes[1:] {
+		mark := ' '
+		if i+1 == index {
+			mark = '>'
+		}
+		lines = append(lines, fmt.Sprintf("%c #%-4d %s  0x%08x %10d",
+			mark, c.Seq, c.Time.Format("15:04:05"), c.Offset, c.Size))
+	}
+	m.eventCh <- event.Event{Type: event.Info, Error: errors.New(strings.Join(lines, "\n"))}
+	return nil
+}
+
+func (m *Manager) listChanges(e event.Event) error {
+	if len(e.Arg) > 0 {
+		return fmt.Errorf("too many arguments for %s", e.CmdName)
+	}
+	window := m.windows[m.windowIndex]
+	window.mu.Lock()
+	eis, err := window.buffer.DirtyRanges()
+	if err != nil {
+		window.mu.Unlock()
+		return err
+	}
+	lines := make([]string, 0, len(eis)/2)
+	items := make([]quickfixItem, 0, len(eis)/2)
+	for i := 0; i < len(eis); i += 2 {
+		unsaved, err := window.unsavedRange(eis[i], eis[i+1])
+		if err != nil {
+			window.mu.Unlock()
+			return err
+		}
+		age := "saved"
+		if unsaved {
+			age = "unsaved"
+		}
+		lines = append(lines, fmt.Sprintf("0x%08x-0x%08x %10d  %s",
+			eis[i], eis[i+1]-1, eis[i+1]-eis[i], age))
+		items = append(items, quickfixItem{eis[i], eis[i+1] - eis[i], age})
+	}
+	window.mu.Unlock()
+	if len(lines) == 0 {
+		return errors.New("no changes")
+	}
+	m.setQuickfix(window, items)
+	m.eventCh <- event.Event{Type: event.Info, Error: errors.New(strings.Join(lines, "\n"))}
+	return nil
+}
+
+func (m *Manager) searchAll(e event.Event) error {
+	if e.Arg == "" {
+		return fmt.Errorf("an argument is required for %s", e.CmdName)
+	}
+	target, err := event.UnquoteArg(e.Arg)
+	if err != nil {
+		return err
+	}
+	window := m.windows[m.windowIndex]
+	waitWindow(window)
+	window.mu.Lock()
+	r, length := window.buffer.Clone(), window.length
+	window.mu.Unlock()
+	return m.startTask("searching", length, func(t *task) (func() error, error) {
+		items, err := searchAll(r, target, t)
+		if err != nil {
+			return nil, err
+		}
+		return func() error {
+			if len(items) == 0 {
+				return fmt.Errorf("pattern not found: %s", e.Arg)
+			}
+			m.setQuickfix(window, items)
+			return m.gotoQuickfix(event.Event{Type: event.QuickfixFirst, Mode: e.Mode})
+		}, nil
 	})
-	if newWindow.Index >= 0 {
-		m.windowIndex, m.prevWindowIndex = newWindow.Index, m.windowIndex
-		m.layout = m.layout.Activate(m.windowIndex)
+}
+
+func (m *Manager) extractStrings(e event.Event) error {
+	window := m.windows[m.windowIndex]
+	min := window.options.Int("stringlength")
+	if e.Arg != "" {
+		var err error
+		if min, err = strconv.Atoi(e.Arg); err != nil || min <= 0 {
+			return fmt.Errorf("invalid length for %s: %s", e.CmdName, e.Arg)
+		}
 	}
+	waitWindow(window)
+	window.mu.Lock()
+	r, length := window.buffer.Clone(), window.length
+	window.mu.Unlock()
+	return m.startTask("extracting strings", length, func(t *task) (func() error, error) {
+		items, err := extractStrings(r, min, t)
+		if err != nil {
+			return nil, err
+		}
+		return func() error {
+			if len(items) == 0 {
+				return errors.New("no strings found")
+			}
+			m.setQuickfix(window, items)
+			return m.listQuickfix(event.Event{})
+		}, nil
+	})
 }
 
-func (m *Manager) move(modifier func(layout.Window, layout.Layout) layout.Layout) {
+func (m *Manager) setQuickfix(window *window, items []quickfixItem) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
-	activeWindow := m.layout.ActiveWindow()
-	m.layout = modifier(activeWindow, m.layout.Close()).Activate(
-		activeWindow.Index).Resize(0, 0, m.width, m.height)
+	m.quickfix = &quickfix{window: window, items: items, index: -1}
+}
+
+func (m *Manager) listQuickfix(e event.Event) error {
+	if len(e.Arg) > 0 {
+		return fmt.Errorf("too many arguments for %s", e.CmdName)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.quickfix == nil {
+		return errors.New("no quickfix list")
+	}
+	m.eventCh <- event.Event{Type: event.Info, Error: errors.New(m.quickfix.format())}
+	return nil
+}
+
+// gotoQuickfix moves the cursor of the window of the quickfix list to the
+// item, and focuses the window.
+func (m *Manager) gotoQuickfix(e event.Event) error {
+	m.mu.Lock()
+	q := m.quickfix
+	if q == nil {
+		m.mu.Unlock()
+		return errors.New("no quickfix list")
+	}
+	index := q.index
+	switch e.Type {
+	case event.QuickfixGoto:
+		index = mathutil.MaxInt(index, 0)
+		if e.Arg != "" {
+			i, err := strconv.Atoi(e.Arg)
+			if err != nil {
+				m.mu.Unlock()
+				return fmt.Errorf("invalid item number for %s: %s", e.CmdName, e.Arg)
+			}
+			index = mathutil.MinInt(mathutil.MaxInt(i, 1), len(q.items)) - 1
+		}
+	case event.QuickfixNext:
+		index += int(mathutil.MaxInt64(e.Count, 1))
+	case event.QuickfixPrevious:
+		index -= int(mathutil.MaxInt64(e.Count, 1))
+	case event.QuickfixFirst:
+		index = 0
+	case event.QuickfixLast:
+		index = len(q.items) - 1
+	}
+	if index < 0 || index >= len(q.items) {
+		m.mu.Unlock()
+		return errors.New("no more items")
+	}
+	windowIndex := -1
+	for i, window := range m.windows {
+		if window == q.window {
+			windowIndex = i
+		}
+	}
+	if _, ok := m.layout.Collect()[windowIndex]; !ok {
+		m.mu.Unlock()
+		return errors.New("the window of the quickfix list is closed")
+	}
+	if windowIndex != m.windowIndex {
+		m.windowIndex, m.prevWindowIndex = windowIndex, m.windowIndex
+		m.layout = m.layout.Activate(m.windowIndex)
+	}
+	q.index = index
+	item := q.items[index]
+	m.mu.Unlock()
+	q.window.eventCh <- event.Event{
+		Type:  event.CursorGoto,
+		Range: &event.Range{From: event.Absolute{Offset: item.offset}},
+		Mode:  e.Mode,
+	}
+	m.eventCh <- event.Event{Type: event.Info, Error: fmt.Errorf("(%d of %d) 0x%08x: %s",
+		index+1, len(q.items), item.offset, item.text)}
+	return nil
 }
 
 func (m *Manager) quit(e event.Event) error {
@@ -359,7 +1847,10 @@ func (m *Manager) quit(e event.Event) error {
 	}
 	w, h := m.layout.Count()
 	if w == 1 && h == 1 {
-		m.eventCh <- event.Event{Type: event.QuitAll}
+		if !e.Bang && m.Modified() {
+			return errors.New("no write since last change (add ! to override)")
+		}
+		m.eventCh <- event.Event{Type: event.QuitAll, Bang: true}
 	} else {
 		m.mu.Lock()
 		m.layout = m.layout.Close().Resize(0, 0, m.width, m.height)
@@ -374,6 +1865,12 @@ func (m *Manager) write(e event.Event) error {
 	if e.Range != nil && e.Arg == "" {
 		return fmt.Errorf("cannot overwrite partially with %s", e.CmdName)
 	}
+	if e.Arg == "-" {
+		return m.writeStdout(e)
+	}
+	if err := m.checkReadonly(e); err != nil {
+		return err
+	}
 	filename, n, err := m.writeFile(e.Range, e.Arg)
 	if err != nil {
 		return err
@@ -382,6 +1879,18 @@ func (m *Manager) write(e event.Event) error {
 	return nil
 }
 
+func (m *Manager) writeStdout(e event.Event) error {
+	if !e.Bang {
+		return errors.New("cannot write to - (add ! to write to stdout)")
+	}
+	n, err := m.windows[m.windowIndex].writeTo(e.Range, m.stdout)
+	if err != nil {
+		return err
+	}
+	m.eventCh <- event.Event{Type: event.Info, Error: fmt.Errorf("stdout: %d (0x%x) bytes written", n, n)}
+	return nil
+}
+
 func (m *Manager) writeQuit(e event.Event) error {
 	if len(e.Arg) > 0 {
 		return fmt.Errorf("too many arguments for %s", e.CmdName)
@@ -389,13 +1898,112 @@ func (m *Manager) writeQuit(e event.Event) error {
 	if e.Range != nil {
 		return fmt.Errorf("range not allowed for %s", e.CmdName)
 	}
+	if err := m.checkReadonly(e); err != nil {
+		return err
+	}
 	if _, _, err := m.writeFile(nil, ""); err != nil {
 		return err
 	}
-	m.eventCh <- event.Event{Type: event.Quit}
+	m.eventCh <- event.Event{Type: event.Quit, Bang: e.Bang}
+	return nil
+}
+
+// checkReadonly refuses overwriting the file of the read-only window
+// unless ! is given.
+func (m *Manager) checkReadonly(e event.Event) error {
+	window := m.windows[m.windowIndex]
+	if !e.Bang && window.options.Bool("readonly") &&
+		(e.Arg == "" || e.Arg == window.filename) {
+		return errors.New("'readonly' option is set (add ! to override)")
+	}
 	return nil
 }
 
+// Modified reports whether any of the windows has unsaved changes.
+func (m *Manager) Modified() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, window := range m.windows {
+		if window.isModified() {
+			return true
+		}
+	}
+	return false
+}
+
+// ReadCursor returns the file name, the cursor and the length of the current
+// window, and the bytes from the cursor up to n bytes.
+func (m *Manager) ReadCursor(n int) (string, int64, int64, []byte, error) {
+	m.mu.Lock()
+	window := m.windows[m.windowIndex]
+	m.mu.Unlock()
+	waitWindow(window)
+	window.mu.Lock()
+	defer window.mu.Unlock()
+	if l := window.length - window.cursor; l < int64(n) {
+		n = int(mathutil.MaxInt64(l, 0))
+	}
+	n, bs, err := window.readBytes(window.cursor, n)
+	if err != nil {
+		return "", 0, 0, nil, err
+	}
+	return window.filename, window.cursor, window.length, bs[:n], nil
+}
+
+// ReadBytes returns at most n bytes at the offset of the current window.
+func (m *Manager) ReadBytes(offset int64, n int) ([]byte, error) {
+	m.mu.Lock()
+	window := m.windows[m.windowIndex]
+	m.mu.Unlock()
+	waitWindow(window)
+	window.mu.Lock()
+	defer window.mu.Unlock()
+	if offset < 0 || offset > window.length {
+		return nil, fmt.Errorf("offset out of range: %d", offset)
+	}
+	if l := window.length - offset; l < int64(n) {
+		n = int(l)
+	}
+	n, bs, err := window.readBytes(offset, n)
+	if err != nil {
+		return nil, err
+	}
+	return bs[:n], nil
+}
+
+// Recover restores the unsaved changes of the current window from the swap
+// file.
+func (m *Manager) Recover() error {
+	return m.recover(event.Event{})
+}
+
+// Rescue writes the journals of the windows to the swap files on the crash of
+// the editor, and returns the names of the files which have the unsaved
+// changes in the swap files. The swap files are kept on closing.
+func (m *Manager) Rescue() []string {
+	m.mu.Lock()
+	windows := make([]*window, len(m.windows))
+	copy(windows, m.windows)
+	m.mu.Unlock()
+	var names []string
+	for _, window := range windows {
+		if name, ok := window.rescueSwap(); ok {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func (m *Manager) recover(e event.Event) error {
+	if len(e.Arg) > 0 {
+		return fmt.Errorf("too many arguments for %s", e.CmdName)
+	}
+	if err := m.Modifiable(); err != nil {
+		return err
+	}
+	return m.windows[m.windowIndex].recoverSwap()
+}
+
 // State returns the state of the windows.
 func (m *Manager) State() (map[int]*state.WindowState, layout.Layout, int, error) {
 	m.mu.Lock()
@@ -404,11 +2012,38 @@ func (m *Manager) State() (map[int]*state.WindowState, layout.Layout, int, error
 	states := make(map[int]*state.WindowState, len(m.windows))
 	for i, window := range m.windows {
 		if l, ok := layouts[i]; ok {
-			window.setSize(hexWindowWidth(l.Width()), mathutil.MaxInt(l.Height()-2, 1))
+			height, width := l.Height()-1, hexWindowWidth(l.Width())
+			if window.options.String("rowsum") != "" {
+				width = hexWindowWidth(l.Width() - 3)
+			}
+			if m.options.Bool("ruler") {
+				height--
+			}
+			if w := window.options.Int("width"); w > 0 {
+				width = w
+			}
+			if _, size := window.table(); size > 0 {
+				width = int(size)
+			}
+			window.setSize(width, mathutil.MaxInt(height, 1))
 			var err error
 			if states[i], err = window.state(); err != nil {
 				return nil, m.layout, 0, err
 			}
+			states[i].Ruler = m.options.Bool("ruler")
+			states[i].Readonly = window.options.Bool("readonly")
+			states[i].RelativeOffset = window.options.Bool("relativeoffset")
+			states[i].BaseAddress = window.options.Int64("baseaddress")
+			states[i].RecordSize = int64(window.options.Int("recordsize"))
+			states[i].RowSum = window.options.String("rowsum")
+			states[i].Encoding = window.options.String("encoding")
+			if len(m.highlights) > 0 {
+				s := states[i]
+				for _, seg := range stateSegments(s) {
+					s.Highlights = append(s.Highlights, highlight.Match(m.highlights,
+						s.Bytes[seg.index:seg.index+seg.length], seg.offset)...)
+				}
+			}
 		}
 	}
 	return states, m.layout, m.windowIndex, nil
@@ -430,13 +2065,46 @@ func hexWindowWidth(width int) int {
 }
 
 func (m *Manager) writeFile(r *event.Range, name string) (string, int64, error) {
-	window := m.windows[m.windowIndex]
+	return m.writeWindow(m.windows[m.windowIndex], r, name)
+}
+
+func (m *Manager) writeWindow(window *window, r *event.Range, name string) (string, int64, error) {
+	name, n, err := m.saveWindow(window, r, name)
+	if err == nil {
+		m.addHook(event.Write, name)
+	}
+	return name, n, err
+}
+
+func (m *Manager) saveWindow(window *window, r *event.Range, name string) (string, int64, error) {
+	saving := r == nil && (name == "" || name == window.filename || window.filename == "")
 	if name == "" {
 		name = window.filename
 	}
 	if name == "" {
 		return name, 0, errors.New("no file name")
 	}
+	if saving {
+		if f := m.remoteFile(name); f != nil {
+			n, err := window.writeChangedTo(f, f.size)
+			if err != nil {
+				return name, n, err
+			}
+			window.markSaved()
+			return name, n, nil
+		}
+	}
+	if isURL(name) {
+		return name, 0, fmt.Errorf("cannot write to the remote file: %s", name)
+	}
+	if archive, entry, ok := splitArchive(name); ok {
+		n, err := m.writeArchive(window, r, archive, entry)
+		if err != nil || !saving {
+			return name, n, err
+		}
+		window.markSaved()
+		return name, n, nil
+	}
 	if runtime.GOOS == "windows" && name == window.filename {
 		return name, 0, errors.New("cannot overwrite the original file on Windows")
 	}
@@ -448,6 +2116,23 @@ func (m *Manager) writeFile(r *event.Range, name string) (string, int64, error)
 		window.filename = name
 		window.name = filepath.Base(name)
 	}
+	if saving && m.isDevice(name) {
+		n, err := m.writeDevice(window, name)
+		if err != nil {
+			return name, n, err
+		}
+		return name, n, m.markSaved(window, name, false)
+	}
+	if err := m.backupFile(name); err != nil {
+		return name, 0, err
+	}
+	if saving && window.codec == nil && m.options.Bool("writeinplace") {
+		if n, ok, err := m.writeInPlace(window, name); err != nil {
+			return name, n, err
+		} else if ok {
+			return name, n, m.markSaved(window, name, false)
+		}
+	}
 	tmpf, err := os.OpenFile(
 		name+"-"+strconv.FormatUint(rand.Uint64(), 16),
 		os.O_RDWR|os.O_CREATE|os.O_EXCL, m.filePerm(name),
@@ -456,12 +2141,194 @@ func (m *Manager) writeFile(r *event.Range, name string) (string, int64, error)
 		return name, 0, err
 	}
 	defer os.Remove(tmpf.Name())
-	n, err := window.writeTo(r, tmpf)
+	var n int64
+	if saving && window.codec != nil || r == nil && window.codec != nil && window.codec.encrypted {
+		n, err = writeCompressed(window, tmpf)
+	} else {
+		n, err = window.writeTo(r, tmpf)
+	}
 	tmpf.Close()
 	if err != nil {
 		return name, 0, err
 	}
-	return name, n, os.Rename(tmpf.Name(), name)
+	if err := preserveAttrs(tmpf.Name(), name, m.options.Bool("preservemtime")); err != nil {
+		return name, 0, err
+	}
+	if err := os.Rename(tmpf.Name(), name); err != nil {
+		return name, 0, err
+	}
+	if !saving {
+		if info, err := os.Stat(name); err == nil {
+			m.mu.Lock()
+			m.updateFileInfo(name, info)
+			m.mu.Unlock()
+		}
+		return name, n, nil
+	}
+	return name, n, m.markSaved(window, name, true)
+}
+
+// writeCompressed writes the contents of the window compressed by the codec.
+func writeCompressed(window *window, dst io.Writer) (int64, error) {
+	w, err := window.codec.compress(dst)
+	if err != nil {
+		return 0, err
+	}
+	n, err := window.writeTo(nil, w)
+	if err != nil {
+		w.Close()
+		return n, err
+	}
+	return n, w.Close()
+}
+
+// markSaved marks the window saved, and updates the information of the file
+// which is replaced or overwritten in place.
+func (m *Manager) markSaved(window *window, name string, replaced bool) error {
+	window.markSaved()
+	info, err := os.Stat(name)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	m.updateFileInfo(name, info)
+	m.mu.Unlock()
+	if err := window.savedSwap(info, replaced); err != nil {
+		return err
+	}
+	return window.saveMarks()
+}
+
+// backupFile copies the file before overwriting when the backup option is set.
+func (m *Manager) backupFile(name string) error {
+	if !m.options.Bool("backup") {
+		return nil
+	}
+	info, err := os.Stat(name)
+	if err != nil || !info.Mode().IsRegular() {
+		return nil
+	}
+	dir := m.options.String("backupdir")
+	if dir == "" {
+		dir = filepath.Dir(name)
+	} else if dir, err = homedir.Expand(dir); err != nil {
+		return err
+	}
+	src, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := os.OpenFile(filepath.Join(dir, filepath.Base(name)+"~"),
+		os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		return err
+	}
+	return dst.Close()
+}
+
+func isBlockDevice(info os.FileInfo) bool {
+	return info.Mode()&os.ModeDevice != 0 && info.Mode()&os.ModeCharDevice == 0
+}
+
+func (m *Manager) isDevice(name string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, f := range m.files {
+		if f.name == name {
+			return f.device
+		}
+	}
+	return false
+}
+
+// writeArchive writes the buffer to the entry of the archive.
+func (m *Manager) writeArchive(window *window, r *event.Range, archive, entry string) (int64, error) {
+	b := new(bytes.Buffer)
+	n, err := window.writeTo(r, b)
+	if err != nil {
+		return 0, err
+	}
+	if err := writeArchiveEntry(archive, entry, b.Bytes()); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+func (m *Manager) remoteFile(name string) *sftpFile {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := len(m.remotes) - 1; i >= 0; i-- {
+		if m.remotes[i].name == name {
+			return m.remotes[i] // the latest one on reloading
+		}
+	}
+	return nil
+}
+
+// writeDevice writes the changed ranges to the block device in place, since
+// the device cannot be replaced by renaming and rewriting whole the device
+// takes too long.
+func (m *Manager) writeDevice(window *window, name string) (int64, error) {
+	f, err := os.OpenFile(name, os.O_RDWR, 0)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	d, err := newBlockDevice(f)
+	if err != nil {
+		return 0, err
+	}
+	n, err := window.writeChangedTo(d, d.size)
+	if err != nil {
+		return n, err
+	}
+	return n, f.Sync()
+}
+
+// writeInPlace writes the changed ranges to the opened file in place, when the
+// file is not replaced after opening and the size does not change. It reports
+// false when the whole file should be written instead.
+func (m *Manager) writeInPlace(window *window, name string) (int64, bool, error) {
+	var f *os.File
+	var fr *fileReader
+	m.mu.Lock()
+	for _, g := range m.files {
+		if g.name == name && !g.temp {
+			f, fr = g.file, g.reader
+		}
+	}
+	m.mu.Unlock()
+	if f == nil {
+		return 0, false, nil
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return 0, false, err
+	}
+	if pathInfo, err := os.Stat(name); err != nil || !os.SameFile(info, pathInfo) {
+		return 0, false, nil
+	}
+	if ok, err := window.writableInPlace(info.Size()); !ok || err != nil {
+		return 0, false, err
+	}
+	dst, err := os.OpenFile(name, os.O_WRONLY, 0)
+	if err != nil {
+		return 0, false, err
+	}
+	defer dst.Close()
+	n, err := window.writeChangedTo(dst, info.Size())
+	if fr != nil {
+		fr.reset()
+	}
+	if err != nil {
+		return n, false, err
+	}
+	return n, true, dst.Sync()
 }
 
 func (m *Manager) filePerm(name string) os.FileMode {
@@ -475,10 +2342,28 @@ func (m *Manager) filePerm(name string) os.FileMode {
 
 // Close the Manager.
 func (m *Manager) Close() {
+	if m.doneCh != nil {
+		close(m.doneCh)
+		m.cancelTask()
+	}
 	for _, f := range m.files {
 		f.file.Close()
+		if f.temp {
+			os.Remove(f.name)
+		}
+	}
+	for _, f := range m.remotes {
+		f.Close()
+	}
+	for _, s := range m.streams {
+		s.Close()
 	}
 	for _, w := range m.windows {
+		_ = w.removeSwap()
+		w.removeSpools()
 		w.close()
 	}
+	if m.wg != nil {
+		m.wg.Wait()
+	}
 }
@@ -1,16 +1,24 @@
 package window
 
 import (
+	"bytes"
+	"compress/gzip"
+	"debug/elf"
+	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"reflect"
+	"regexp"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/itchyny/bed/event"
 	"github.com/itchyny/bed/layout"
 	"github.com/itchyny/bed/mode"
+	"github.com/itchyny/bed/state"
 )
 
 func TestManagerOpenEmpty(t *testing.T) {
@@ -159,6 +167,1054 @@ func TestManagerOpenNonExistsWrite(t *testing.T) {
 	wm.Close()
 }
 
+func TestManagerReadonly(t *testing.T) {
+	wm := NewManager()
+	eventCh, redrawCh := make(chan event.Event), make(chan struct{})
+	wm.Init(eventCh, redrawCh)
+	wm.SetSize(110, 20)
+	if err := wm.Open(""); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	_, _, _, _ = wm.State()
+	go wm.Emit(event.Event{Type: event.Set, Arg: "readonly"})
+	if e := <-eventCh; e.Type != event.Redraw {
+		t.Errorf("event type should be %d but got: %d", event.Redraw, e.Type)
+	}
+	if err := wm.Modifiable(); err == nil || err.Error() != "cannot make changes, 'readonly' is set" {
+		t.Errorf("err should be %q but got: %v", "cannot make changes, 'readonly' is set", err)
+	}
+	go wm.Emit(event.Event{Type: event.InsertBytes, Arg: "4"})
+	if e := <-eventCh; e.Type != event.Error {
+		t.Errorf("event type should be %d but got: %d", event.Error, e.Type)
+	}
+	windowStates, _, _, _ := wm.State()
+	if ws := windowStates[0]; ws.Length != 0 || !ws.Readonly {
+		t.Errorf("window should be read-only and not modified but got: %+v", ws)
+	}
+
+	go wm.Emit(event.Event{Type: event.Set, Arg: "noreadonly nomodifiable"})
+	<-eventCh
+	if err := wm.Modifiable(); err == nil || err.Error() != "cannot make changes, 'modifiable' is off" {
+		t.Errorf("err should be %q but got: %v", "cannot make changes, 'modifiable' is off", err)
+	}
+	go wm.Emit(event.Event{Type: event.Set, Arg: "modifiable"})
+	<-eventCh
+	go wm.Emit(event.Event{Type: event.InsertBytes, Arg: "4"})
+	<-redrawCh
+	if !wm.Modified() {
+		t.Errorf("wm.Modified() should be true")
+	}
+
+	go wm.Emit(event.Event{Type: event.Quit})
+	if e := <-eventCh; e.Type != event.Error ||
+		e.Error.Error() != "no write since last change (add ! to override)" {
+		t.Errorf("quit should be refused but got: %+v", e)
+	}
+	go wm.Emit(event.Event{Type: event.Quit, Bang: true})
+	if e := <-eventCh; e.Type != event.QuitAll || !e.Bang {
+		t.Errorf("event should be QuitAll with bang but got: %+v", e)
+	}
+	wm.Close()
+}
+
+func TestManagerLock(t *testing.T) {
+	f, err := ioutil.TempFile("", "bed-test-manager-lock")
+	if err != nil {
+		t.Fatalf("err should be nil but got: %v", err)
+	}
+	defer os.Remove(f.Name())
+	f.Close()
+	wm1, wm2 := NewManager(), NewManager()
+	for _, wm := range []*Manager{wm1, wm2} {
+		wm.Init(make(chan event.Event), make(chan struct{}))
+		wm.SetSize(110, 20)
+		if err := wm.options.Set("lock"); err != nil {
+			t.Fatalf("err should be nil but got: %v", err)
+		}
+	}
+	if err := wm1.Open(f.Name()); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	expected := f.Name() + " is locked by another process"
+	if err := wm2.Open(f.Name()); err == nil || err.Error() != expected {
+		t.Errorf("err should be %q but got: %v", expected, err)
+	}
+	wm1.Close()
+	if err := wm2.Open(f.Name()); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	wm2.Close()
+}
+
+func TestManagerUndoPartial(t *testing.T) {
+	wm := NewManager()
+	eventCh, redrawCh := make(chan event.Event), make(chan struct{})
+	wm.Init(eventCh, redrawCh)
+	wm.SetSize(110, 20)
+	if err := wm.Open(""); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	_, _, _, _ = wm.State()
+	go wm.Emit(event.Event{Type: event.UndoPartial, CmdName: "undopartial", Arg: "x"})
+	if e := <-eventCh; e.Type != event.Error || e.Error.Error() != "invalid count for undopartial: x" {
+		t.Errorf("undopartial should fail but got: %+v", e)
+	}
+	go wm.Emit(event.Event{Type: event.UndoPartial, CmdName: "undopartial"})
+	if e := <-eventCh; e.Type != event.Error || e.Error.Error() != "no replaced bytes to undo" {
+		t.Errorf("undopartial should fail but got: %+v", e)
+	}
+
+	for _, e := range []event.Event{
+		{Type: event.StartReplace, Mode: mode.Replace},
+		{Type: event.Rune, Rune: '4', Mode: mode.Replace},
+		{Type: event.Rune, Rune: '1', Mode: mode.Replace},
+		{Type: event.Rune, Rune: '4', Mode: mode.Replace},
+		{Type: event.Rune, Rune: '2', Mode: mode.Replace},
+		{Type: event.ExitInsert, Mode: mode.Normal},
+		{Type: event.UndoPartial, Mode: mode.Normal},
+	} {
+		go wm.Emit(e)
+		<-redrawCh
+	}
+	windowStates, _, _, _ := wm.State()
+	if ws := windowStates[0]; ws.Length != 1 || ws.Bytes[0] != 'A' {
+		t.Errorf("the last replaced byte should be restored but got: %+v", ws)
+	}
+	wm.Close()
+}
+
+func TestManagerPasteText(t *testing.T) {
+	wm := NewManager()
+	eventCh, redrawCh := make(chan event.Event), make(chan struct{})
+	wm.Init(eventCh, redrawCh)
+	wm.SetSize(110, 20)
+	if err := wm.Open(""); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	_, _, _, _ = wm.State()
+	go wm.Emit(event.Event{Type: event.PasteText, Arg: "de ad g", Mode: mode.Insert})
+	if e := <-eventCh; e.Type != event.Error || e.Error.Error() != "the pasted text is not hex digits" {
+		t.Errorf("pasting should fail but got: %+v", e)
+	}
+
+	for _, e := range []event.Event{
+		{Type: event.StartInsert, Mode: mode.Insert},
+		{Type: event.Rune, Rune: '4', Mode: mode.Insert},
+		{Type: event.Rune, Rune: '1', Mode: mode.Insert},
+		{Type: event.PasteText, Arg: "de ad\r\n0xbeef 0X42", Mode: mode.Insert},
+		{Type: event.ExitInsert, Mode: mode.Normal},
+	} {
+		go wm.Emit(e)
+		<-redrawCh
+	}
+	windowStates, _, _, _ := wm.State()
+	if ws, expected := windowStates[0], "A\xde\xad\xbe\xefB"; string(ws.Bytes[:ws.Length]) != expected {
+		t.Errorf("the pasted bytes should be inserted but got: %q", ws.Bytes[:ws.Length])
+	}
+	go wm.Emit(event.Event{Type: event.Undo, Mode: mode.Normal})
+	<-redrawCh
+	windowStates, _, _, _ = wm.State()
+	if ws := windowStates[0]; string(ws.Bytes[:ws.Length]) != "A" {
+		t.Errorf("the paste should be undone as one change but got: %q", ws.Bytes[:ws.Length])
+	}
+	go wm.Emit(event.Event{Type: event.Undo, Mode: mode.Normal})
+	<-redrawCh
+	windowStates, _, _, _ = wm.State()
+	if ws := windowStates[0]; ws.Length != 0 {
+		t.Errorf("the typed byte should be undone but got: %q", ws.Bytes[:ws.Length])
+	}
+	wm.Close()
+}
+
+func TestManagerReload(t *testing.T) {
+	wm := NewManager()
+	eventCh, redrawCh := make(chan event.Event), make(chan struct{})
+	wm.Init(eventCh, redrawCh)
+	wm.SetSize(110, 20)
+	f, _ := ioutil.TempFile("", "bed-test-manager-reload")
+	_, _ = f.WriteString("Hello, world!")
+	_ = f.Close()
+	defer os.Remove(f.Name())
+	if err := wm.Open(f.Name()); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	_, _, _, _ = wm.State()
+	go wm.Emit(event.Event{Type: event.CursorNext, Count: 7, Mode: mode.Normal})
+	<-redrawCh
+	go wm.Emit(event.Event{Type: event.SetMark, Mode: mode.Normal, Rune: 'a'})
+	<-redrawCh
+	if err := wm.checkFiles(); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	if err := ioutil.WriteFile(f.Name(), []byte("Hello, everyone!"), 0644); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	expected := f.Name() + " has changed on disk (use :e! to reload)"
+	if err := wm.checkFiles(); err == nil || err.Error() != expected {
+		t.Errorf("err should be %q but got: %v", expected, err)
+	}
+	if err := wm.checkFiles(); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+
+	go wm.Emit(event.Event{Type: event.DeleteByte, Mode: mode.Normal})
+	<-redrawCh
+	go wm.Emit(event.Event{Type: event.Edit})
+	if e := <-eventCh; e.Type != event.Error ||
+		e.Error.Error() != "no write since last change (add ! to override)" {
+		t.Errorf("edit should be refused but got: %+v", e)
+	}
+	go wm.Emit(event.Event{Type: event.Edit, Bang: true})
+	if e := <-eventCh; e.Type != event.Redraw {
+		t.Errorf("event type should be %d but got: %d", event.Redraw, e.Type)
+	}
+	windowStates, _, windowIndex, _ := wm.State()
+	ws := windowStates[windowIndex]
+	if expected := "Hello, everyone!"; !strings.HasPrefix(string(ws.Bytes), expected) {
+		t.Errorf("Bytes should starts with %q but got %q", expected, string(ws.Bytes))
+	}
+	if ws.Cursor != 7 {
+		t.Errorf("cursor should be %d but got %d", 7, ws.Cursor)
+	}
+	if ws.Modified {
+		t.Errorf("window should not be modified after reload")
+	}
+	go wm.Emit(event.Event{Type: event.CursorHead, Mode: mode.Normal})
+	<-redrawCh
+	go wm.Emit(event.Event{Type: event.GotoMark, Mode: mode.Normal, Rune: 'a'})
+	<-redrawCh
+	windowStates, _, windowIndex, _ = wm.State()
+	if ws := windowStates[windowIndex]; ws.Cursor != 7 {
+		t.Errorf("cursor should be %d but got %d", 7, ws.Cursor)
+	}
+
+	if err := ioutil.WriteFile(f.Name(), []byte("Hello"), 0644); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	go wm.Emit(event.Event{Type: event.Edit})
+	if e := <-eventCh; e.Type != event.Redraw {
+		t.Errorf("event type should be %d but got: %d", event.Redraw, e.Type)
+	}
+	windowStates, _, windowIndex, _ = wm.State()
+	if ws := windowStates[windowIndex]; ws.Cursor != 4 {
+		t.Errorf("cursor should be %d but got %d", 4, ws.Cursor)
+	}
+	if err := ioutil.WriteFile(f.Name(), []byte("Hello, world!"), 0644); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	go wm.Emit(event.Event{Type: event.Edit})
+	if e := <-eventCh; e.Type != event.Redraw {
+		t.Errorf("event type should be %d but got: %d", event.Redraw, e.Type)
+	}
+	windowStates, _, windowIndex, _ = wm.State()
+	if ws := windowStates[windowIndex]; ws.Cursor != 4 {
+		t.Errorf("cursor should be %d but got %d", 4, ws.Cursor)
+	}
+	go wm.Emit(event.Event{Type: event.GotoMark, Mode: mode.Normal, Rune: '`'})
+	<-redrawCh
+	windowStates, _, windowIndex, _ = wm.State()
+	if ws := windowStates[windowIndex]; ws.Cursor != 7 {
+		t.Errorf("cursor should be %d but got %d", 7, ws.Cursor)
+	}
+	wm.Close()
+}
+
+func TestManagerQuitAll(t *testing.T) {
+	wm := NewManager()
+	eventCh, redrawCh := make(chan event.Event), make(chan struct{})
+	wm.Init(eventCh, redrawCh)
+	wm.SetSize(110, 20)
+	var names []string
+	for _, str := range []string{"Hello", "world"} {
+		f, _ := ioutil.TempFile("", "bed-test-manager-quitall")
+		_, _ = f.WriteString(str)
+		_ = f.Close()
+		defer os.Remove(f.Name())
+		names = append(names, f.Name())
+	}
+	if err := wm.Open(names[0]); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	_, _, _, _ = wm.State()
+	go wm.Emit(event.Event{Type: event.QuitAll})
+	if e := <-eventCh; e.Type != event.QuitAll || !e.Bang {
+		t.Errorf("qall should quit without the changes but got: %+v", e)
+	}
+	go wm.Emit(event.Event{Type: event.DeleteByte, Mode: mode.Normal})
+	<-redrawCh
+	go wm.Emit(event.Event{Type: event.Vnew, Arg: names[1]})
+	<-eventCh
+	_, _, _, _ = wm.State()
+	go wm.Emit(event.Event{Type: event.DeleteByte, Mode: mode.Normal})
+	<-redrawCh
+
+	go wm.Emit(event.Event{Type: event.QuitAll})
+	prompt := "write the changes of " + filepath.Base(names[0]) + "? (y/n/a/q)"
+	if e := <-eventCh; e.Type != event.StartConfirm || e.Error.Error() != prompt {
+		t.Errorf("qall should ask for the first file but got: %+v", e)
+	}
+	go wm.Emit(event.Event{Type: event.ConfirmSubstitute, Rune: 'q'})
+	if e := <-eventCh; e.Type != event.ExitConfirm || e.Error.Error() != "quit canceled" {
+		t.Errorf("qall should be canceled but got: %+v", e)
+	}
+
+	go wm.Emit(event.Event{Type: event.QuitAll})
+	<-eventCh
+	go wm.Emit(event.Event{Type: event.ConfirmSubstitute, Rune: 'n'})
+	prompt = "write the changes of " + filepath.Base(names[1]) + "? (y/n/a/q)"
+	if e := <-eventCh; e.Type != event.StartConfirm || e.Error.Error() != prompt {
+		t.Errorf("qall should ask for the second file but got: %+v", e)
+	}
+	go wm.Emit(event.Event{Type: event.ConfirmSubstitute, Rune: 'y'})
+	if e := <-eventCh; e.Type != event.QuitAll || !e.Bang {
+		t.Errorf("qall should quit after the prompts but got: %+v", e)
+	}
+	for i, expected := range []string{"Hello", "orld"} {
+		if bs, _ := ioutil.ReadFile(names[i]); string(bs) != expected {
+			t.Errorf("file contents should be %q but got %q", expected, string(bs))
+		}
+	}
+	if !wm.Modified() {
+		t.Errorf("the discarded changes should be kept modified")
+	}
+
+	go wm.Emit(event.Event{Type: event.WriteQuitAll})
+	if e := <-eventCh; e.Type != event.QuitAll || !e.Bang {
+		t.Errorf("wqall should quit after writing but got: %+v", e)
+	}
+	if bs, _ := ioutil.ReadFile(names[0]); string(bs) != "ello" {
+		t.Errorf("file contents should be %q but got %q", "ello", string(bs))
+	}
+	if wm.Modified() {
+		t.Errorf("wqall should write all the changes")
+	}
+	wm.Close()
+}
+
+func TestManagerStdinStdout(t *testing.T) {
+	wm := NewManager()
+	eventCh, redrawCh := make(chan event.Event), make(chan struct{})
+	wm.Init(eventCh, redrawCh)
+	wm.SetSize(110, 20)
+	str := "Hello, world!"
+	stdout := new(bytes.Buffer)
+	wm.stdin, wm.stdout = strings.NewReader(str), stdout
+	if err := wm.Open("-"); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	windowStates, _, _, _ := wm.State()
+	ws := windowStates[0]
+	if ws.Name != "[stdin]" {
+		t.Errorf("name should be %q but got %q", "[stdin]", ws.Name)
+	}
+	if ws.Length != int64(len(str)) {
+		t.Errorf("Length should be %d but got %d", len(str), ws.Length)
+	}
+	go wm.Emit(event.Event{Type: event.Write, Arg: "-"})
+	if e := <-eventCh; e.Type != event.Error ||
+		e.Error.Error() != "cannot write to - (add ! to write to stdout)" {
+		t.Errorf("write should be refused but got: %+v", e)
+	}
+	go wm.Emit(event.Event{Type: event.Write, Arg: "-", Bang: true})
+	if e := <-eventCh; e.Type != event.Info ||
+		e.Error.Error() != "stdout: 13 (0xd) bytes written" {
+		t.Errorf("write should succeed but got: %+v", e)
+	}
+	if stdout.String() != str {
+		t.Errorf("stdout should be %q but got %q", str, stdout.String())
+	}
+	name := wm.files[0].name
+	wm.Close()
+	if _, err := os.Stat(name); !os.IsNotExist(err) {
+		t.Errorf("temporary file should be removed but got: %v", err)
+	}
+}
+
+func TestManagerStdinStream(t *testing.T) {
+	wm := NewManager()
+	eventCh, redrawCh := make(chan event.Event), make(chan struct{})
+	wm.Init(eventCh, redrawCh)
+	wm.SetSize(110, 20)
+	size := 2*streamChunk + 100
+	wm.stdin = io.LimitReader(strings.NewReader(strings.Repeat("0123456789abcdef", size/16+1)), int64(size))
+	if err := wm.Open("-"); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	windowStates, _, _, _ := wm.State()
+	ws := windowStates[0]
+	if !ws.LengthUnknown {
+		t.Errorf("LengthUnknown should be true")
+	}
+	if ws.Length >= streamChunk {
+		t.Errorf("Length should be less than %d but got %d", streamChunk, ws.Length)
+	}
+	for i := 0; i < 3; i++ {
+		go wm.Emit(event.Event{Type: event.PageEnd})
+		<-redrawCh
+	}
+	windowStates, _, _, _ = wm.State()
+	ws = windowStates[0]
+	if ws.LengthUnknown {
+		t.Errorf("LengthUnknown should be false")
+	}
+	if ws.Length != int64(size) {
+		t.Errorf("Length should be %d but got %d", size, ws.Length)
+	}
+	if expected := int64(size-1) / 16 * 16; ws.Cursor != expected {
+		t.Errorf("Cursor should be %d but got %d", expected, ws.Cursor)
+	}
+	wm.Close()
+}
+
+func TestManagerOpenURL(t *testing.T) {
+	wm := NewManager()
+	eventCh, redrawCh := make(chan event.Event), make(chan struct{})
+	wm.Init(eventCh, redrawCh)
+	wm.SetSize(110, 20)
+	var requests int32
+	server := newRangeServer([]byte("Hello, world!"), &requests)
+	defer server.Close()
+	url := server.URL + "/hello.bin"
+	if err := wm.Open(url); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	windowStates, _, windowIndex, _ := wm.State()
+	ws := windowStates[windowIndex]
+	if ws.Name != "hello.bin" {
+		t.Errorf("name should be %q but got %q", "hello.bin", ws.Name)
+	}
+	if expected := "Hello, world!"; !strings.HasPrefix(string(ws.Bytes), expected) {
+		t.Errorf("Bytes should starts with %q but got %q", expected, string(ws.Bytes))
+	}
+	go wm.Emit(event.Event{Type: event.DeleteByte, Mode: mode.Normal})
+	<-redrawCh
+	go wm.Emit(event.Event{Type: event.Write})
+	if e := <-eventCh; e.Type != event.Error || e.Error.Error() != "cannot write to the remote file: "+url {
+		t.Errorf("write should be refused but got: %+v", e)
+	}
+	f, _ := ioutil.TempFile("", "bed-test-manager-open-url")
+	_ = f.Close()
+	defer os.Remove(f.Name())
+	go wm.Emit(event.Event{Type: event.Write, Arg: f.Name()})
+	if e := <-eventCh; e.Type != event.Info {
+		t.Errorf("write should succeed but got: %+v", e)
+	}
+	if bs, _ := ioutil.ReadFile(f.Name()); string(bs) != "ello, world!" {
+		t.Errorf("file contents should be %q but got %q", "ello, world!", string(bs))
+	}
+	wm.Close()
+}
+
+func TestManagerOpenSFTP(t *testing.T) {
+	wm := NewManager()
+	eventCh, redrawCh := make(chan event.Event), make(chan struct{})
+	wm.Init(eventCh, redrawCh)
+	wm.SetSize(110, 20)
+	content := []byte("Hello, world!")
+	wm.dialSFTP = func(host string) (*sftpClient, error) {
+		if host != "user@host" {
+			t.Errorf("host should be %q but got %q", "user@host", host)
+		}
+		c, _, err := newMockSFTP(map[string][]byte{"/tmp/hello.bin": content})
+		return c, err
+	}
+	if err := wm.Open("sftp://user@host/tmp/hello.bin"); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	windowStates, _, windowIndex, _ := wm.State()
+	ws := windowStates[windowIndex]
+	if ws.Name != "hello.bin" {
+		t.Errorf("name should be %q but got %q", "hello.bin", ws.Name)
+	}
+	if expected := "Hello, world!"; !strings.HasPrefix(string(ws.Bytes), expected) {
+		t.Errorf("Bytes should starts with %q but got %q", expected, string(ws.Bytes))
+	}
+	go wm.Emit(event.Event{Type: event.Increment, Mode: mode.Normal})
+	<-redrawCh
+	go wm.Emit(event.Event{Type: event.Write})
+	if e := <-eventCh; e.Type != event.Info || e.Error.Error() != "sftp://user@host/tmp/hello.bin: 1 (0x1) bytes written" {
+		t.Errorf("write should succeed but got: %+v", e)
+	}
+	if string(content) != "Iello, world!" {
+		t.Errorf("remote file contents should be %q but got %q", "Iello, world!", string(content))
+	}
+	go wm.Emit(event.Event{Type: event.DeleteByte, Mode: mode.Normal})
+	<-redrawCh
+	go wm.Emit(event.Event{Type: event.Write})
+	if e := <-eventCh; e.Type != event.Error || e.Error.Error() != "cannot change the size in place" {
+		t.Errorf("write should be refused but got: %+v", e)
+	}
+	wm.Close()
+}
+
+func TestManagerOpenArchive(t *testing.T) {
+	wm := NewManager()
+	eventCh, redrawCh := make(chan event.Event), make(chan struct{})
+	wm.Init(eventCh, redrawCh)
+	wm.SetSize(110, 20)
+	archive := createZip(t, map[string]string{"a.txt": "Hello, world!", "dir/b.bin": "\x00\x01"})
+	defer os.Remove(archive)
+	if err := wm.Open(archive + ":a.txt"); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	windowStates, _, windowIndex, _ := wm.State()
+	ws := windowStates[windowIndex]
+	if ws.Name != "a.txt" {
+		t.Errorf("name should be %q but got %q", "a.txt", ws.Name)
+	}
+	go wm.Emit(event.Event{Type: event.DeleteByte, Mode: mode.Normal})
+	<-redrawCh
+	go wm.Emit(event.Event{Type: event.Write})
+	if e := <-eventCh; e.Type != event.Info || e.Error.Error() != archive+":a.txt: 12 (0xc) bytes written" {
+		t.Errorf("write should succeed but got: %+v", e)
+	}
+	if bs, _ := readArchiveEntry(archive, "a.txt"); string(bs) != "ello, world!" {
+		t.Errorf("entry should be %q but got %q", "ello, world!", string(bs))
+	}
+	if bs, _ := readArchiveEntry(archive, "dir/b.bin"); string(bs) != "\x00\x01" {
+		t.Errorf("entry should be %q but got %q", "\x00\x01", string(bs))
+	}
+	windowStates, _, windowIndex, _ = wm.State()
+	if windowStates[windowIndex].Modified {
+		t.Errorf("window should not be modified after writing")
+	}
+	wm.Close()
+}
+
+func TestManagerOpenCompressed(t *testing.T) {
+	wm := NewManager()
+	eventCh, redrawCh := make(chan event.Event), make(chan struct{})
+	wm.Init(eventCh, redrawCh)
+	wm.SetSize(110, 20)
+	f, _ := ioutil.TempFile("", "bed-test-manager-compressed-*.gz")
+	w := gzip.NewWriter(f)
+	_, _ = w.Write([]byte("Hello, world!"))
+	_ = w.Close()
+	_ = f.Close()
+	defer os.Remove(f.Name())
+	defer os.Remove(f.Name() + ".bedswp")
+	if err := wm.Open(f.Name()); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	expected := f.Name() + ": gzip compressed (:set decompress and :edit to edit the decompressed contents)"
+	if e := wm.openedEvent(); e.Type != event.Info || e.Error.Error() != expected {
+		t.Errorf("compression should be offered but got: %+v", e)
+	}
+	windowStates, _, windowIndex, _ := wm.State()
+	if ws := windowStates[windowIndex]; !strings.HasPrefix(string(ws.Bytes), "\x1f\x8b") {
+		t.Errorf("Bytes should starts with %q but got %q", "\x1f\x8b", string(ws.Bytes))
+	}
+	go wm.Emit(event.Event{Type: event.Set, Arg: "decompress"})
+	<-eventCh
+	go wm.Emit(event.Event{Type: event.Edit})
+	<-eventCh
+	expected = f.Name() + ": editing the gzip decompressed contents (offsets differ from the file on disk)"
+	if e := wm.openedEvent(); e.Type != event.Info || e.Error.Error() != expected {
+		t.Errorf("compression should be notified but got: %+v", e)
+	}
+	windowStates, _, windowIndex, _ = wm.State()
+	if ws := windowStates[windowIndex]; !strings.HasPrefix(string(ws.Bytes), "Hello, world!") {
+		t.Errorf("Bytes should starts with %q but got %q", "Hello, world!", string(ws.Bytes))
+	}
+	go wm.Emit(event.Event{Type: event.DeleteByte, Mode: mode.Normal})
+	<-redrawCh
+	go wm.Emit(event.Event{Type: event.Write})
+	if e := <-eventCh; e.Type != event.Info || !strings.HasSuffix(e.Error.Error(), "12 (0xc) bytes written") {
+		t.Errorf("write should succeed but got: %+v", e)
+	}
+	g, _ := os.Open(f.Name())
+	defer g.Close()
+	r, err := gzip.NewReader(g)
+	if err != nil {
+		t.Fatalf("file should be compressed but got: %v", err)
+	}
+	if bs, _ := ioutil.ReadAll(r); string(bs) != "ello, world!" {
+		t.Errorf("decompressed contents should be %q but got %q", "ello, world!", string(bs))
+	}
+	wm.Close()
+}
+
+func TestManagerOpenBrokenCompressed(t *testing.T) {
+	wm := NewManager()
+	eventCh, redrawCh := make(chan event.Event), make(chan struct{})
+	wm.Init(eventCh, redrawCh)
+	wm.SetSize(110, 20)
+	f, _ := ioutil.TempFile("", "bed-test-manager-broken-compressed")
+	_, _ = f.WriteString("\x1f\x8bnot really gzip data")
+	_ = f.Close()
+	defer os.Remove(f.Name())
+	defer os.Remove(f.Name() + ".bedswp")
+	if err := wm.options.Set("decompress"); err != nil {
+		t.Fatal(err)
+	}
+	if err := wm.Open(f.Name()); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	expected := f.Name() + ": editing the original bytes (gzip: invalid header)"
+	if e := wm.openedEvent(); e.Type != event.Info || e.Error.Error() != expected {
+		t.Errorf("the failure of decompression should be notified but got: %+v", e)
+	}
+	windowStates, _, windowIndex, _ := wm.State()
+	if ws := windowStates[windowIndex]; !strings.HasPrefix(string(ws.Bytes), "\x1f\x8bnot really gzip data") {
+		t.Errorf("Bytes should starts with %q but got %q", "\x1f\x8bnot really gzip data", string(ws.Bytes))
+	}
+	wm.Close()
+}
+
+func TestManagerOpenEncrypted(t *testing.T) {
+	wm := NewManager()
+	eventCh, redrawCh := make(chan event.Event), make(chan struct{})
+	wm.Init(eventCh, redrawCh)
+	wm.SetSize(110, 20)
+	keyfile, _ := ioutil.TempFile("", "bed-test-manager-keyfile")
+	_, _ = keyfile.WriteString("secret\n")
+	_ = keyfile.Close()
+	defer os.Remove(keyfile.Name())
+	f, _ := ioutil.TempFile("", "bed-test-manager-encrypted")
+	bs, _ := encrypt([]byte("Hello, world!"), []byte("secret"))
+	_, _ = f.Write(bs)
+	_ = f.Close()
+	defer os.Remove(f.Name())
+	if err := wm.Open(f.Name()); err == nil || !strings.HasSuffix(err.Error(), "no key to decrypt (enter :passphrase, set the keyfile option or BED_KEY)") {
+		t.Errorf("open should fail without the key but got: %v", err)
+	}
+	if err := wm.options.Set("keyfile=" + keyfile.Name()); err != nil {
+		t.Fatal(err)
+	}
+	if err := wm.Open(f.Name()); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	expected := f.Name() + ": editing the AES-GCM decrypted contents in memory"
+	if e := wm.openedEvent(); e.Type != event.Info || e.Error.Error() != expected {
+		t.Errorf("encryption should be notified but got: %+v", e)
+	}
+	windowStates, _, windowIndex, _ := wm.State()
+	if ws := windowStates[windowIndex]; !strings.HasPrefix(string(ws.Bytes), "Hello, world!") {
+		t.Errorf("Bytes should starts with %q but got %q", "Hello, world!", string(ws.Bytes))
+	}
+	go wm.Emit(event.Event{Type: event.DeleteByte, Mode: mode.Normal})
+	<-redrawCh
+	if _, err := os.Stat(f.Name() + ".bedswp"); err == nil {
+		t.Errorf("swap file should not be written")
+	}
+	go wm.Emit(event.Event{Type: event.Write})
+	if e := <-eventCh; e.Type != event.Info || !strings.HasSuffix(e.Error.Error(), "12 (0xc) bytes written") {
+		t.Errorf("write should succeed but got: %+v", e)
+	}
+	bs, _ = ioutil.ReadFile(f.Name())
+	if got, err := decrypt(bs, []byte("secret")); err != nil || string(got) != "ello, world!" {
+		t.Errorf("decrypted contents should be %q but got %q (%v)", "ello, world!", got, err)
+	}
+	wm.Close()
+}
+
+func TestManagerEncryptPassphrase(t *testing.T) {
+	wm := NewManager()
+	eventCh, redrawCh := make(chan event.Event), make(chan struct{})
+	wm.Init(eventCh, redrawCh)
+	wm.SetSize(110, 20)
+	f, _ := ioutil.TempFile("", "bed-test-manager-encrypt-passphrase")
+	_, _ = f.WriteString("Hello, world!")
+	_ = f.Close()
+	defer os.Remove(f.Name())
+	if err := wm.Open(f.Name()); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	go wm.Emit(event.Event{Type: event.Encrypt, CmdName: "encrypt"})
+	if e := <-eventCh; e.Type != event.StartCmdlinePassphrase || e.CmdName != "encrypt" {
+		t.Errorf("passphrase should be asked but got: %+v", e)
+	}
+	go wm.Emit(event.Event{Type: event.SetPassphrase, CmdName: "encrypt", Bytes: []byte("secret")})
+	if e := <-eventCh; e.Type != event.Info || e.Error.Error() != "the contents are encrypted on writing" {
+		t.Errorf("contents should be encrypted but got: %+v", e)
+	}
+	if spool, err := wm.windows[wm.windowIndex].checkInsert(largeInsertLength); spool || err != nil {
+		t.Errorf("bytes should not be spooled for the encrypted contents but got: %v, %v", spool, err)
+	}
+	go wm.Emit(event.Event{Type: event.Write})
+	if e := <-eventCh; e.Type != event.Info || !strings.HasSuffix(e.Error.Error(), "13 (0xd) bytes written") {
+		t.Errorf("write should succeed but got: %+v", e)
+	}
+	bs, _ := ioutil.ReadFile(f.Name())
+	if got, err := decrypt(bs, []byte("secret")); err != nil || string(got) != "Hello, world!" {
+		t.Errorf("decrypted contents should be %q but got %q (%v)", "Hello, world!", got, err)
+	}
+	wm.Close()
+}
+
+func TestManagerWriteInPlace(t *testing.T) {
+	wm := NewManager()
+	eventCh, redrawCh := make(chan event.Event), make(chan struct{})
+	wm.Init(eventCh, redrawCh)
+	wm.SetSize(110, 20)
+	f, _ := ioutil.TempFile("", "bed-test-manager-write-in-place")
+	_, _ = f.WriteString("Hello, world!")
+	_ = f.Close()
+	defer os.Remove(f.Name())
+	info, _ := os.Stat(f.Name())
+	if err := wm.Open(f.Name()); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	_, _, _, _ = wm.State()
+	go wm.Emit(event.Event{Type: event.Set, Arg: "writeinplace"})
+	<-eventCh
+	for i, testCase := range []struct {
+		typ      event.Type
+		expected string
+		message  string
+		inPlace  bool
+	}{
+		{event.Increment, "Iello, world!", "1 (0x1) bytes", true},
+		{event.DeleteByte, "ello, world!", "12 (0xc) bytes", false},
+		{event.Increment, "fllo, world!", "12 (0xc) bytes", false},
+	} {
+		go wm.Emit(event.Event{Type: testCase.typ, Mode: mode.Normal})
+		<-redrawCh
+		go wm.Emit(event.Event{Type: event.Write})
+		if e := <-eventCh; e.Type != event.Info || !strings.HasSuffix(e.Error.Error(), testCase.message+" written") {
+			t.Errorf("write should succeed but got: %+v", e)
+		}
+		bs, _ := ioutil.ReadFile(f.Name())
+		if string(bs) != testCase.expected {
+			t.Errorf("file contents should be %q but got %q", testCase.expected, string(bs))
+		}
+		newInfo, _ := os.Stat(f.Name())
+		if os.SameFile(info, newInfo) != testCase.inPlace {
+			t.Errorf("file should be written in place: %v (case %d)", testCase.inPlace, i)
+		}
+		info = newInfo
+	}
+	wm.Close()
+}
+
+func TestManagerPreserveMtime(t *testing.T) {
+	wm := NewManager()
+	eventCh, redrawCh := make(chan event.Event), make(chan struct{})
+	wm.Init(eventCh, redrawCh)
+	wm.SetSize(110, 20)
+	f, _ := ioutil.TempFile("", "bed-test-manager-preserve-mtime")
+	_, _ = f.WriteString("Hello, world!")
+	_ = f.Close()
+	defer os.Remove(f.Name())
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	if err := os.Chtimes(f.Name(), mtime, mtime); err != nil {
+		t.Fatalf("err should be nil but got: %v", err)
+	}
+	if err := wm.Open(f.Name()); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	_, _, _, _ = wm.State()
+	for _, testCase := range []struct {
+		arg      string
+		preserve bool
+	}{
+		{"preservemtime", true},
+		{"nopreservemtime", false},
+	} {
+		go wm.Emit(event.Event{Type: event.Set, Arg: testCase.arg})
+		<-eventCh
+		go wm.Emit(event.Event{Type: event.DeleteByte, Mode: mode.Normal})
+		<-redrawCh
+		go wm.Emit(event.Event{Type: event.Write})
+		if e := <-eventCh; e.Type != event.Info {
+			t.Errorf("write should succeed but got: %+v", e)
+		}
+		info, _ := os.Stat(f.Name())
+		if info.ModTime().Equal(mtime) != testCase.preserve {
+			t.Errorf("modification time should be preserved: %v but got: %v", testCase.preserve, info.ModTime())
+		}
+	}
+	wm.Close()
+}
+
+func TestManagerSetQuery(t *testing.T) {
+	wm := NewManager()
+	eventCh, redrawCh := make(chan event.Event, 2), make(chan struct{})
+	wm.Init(eventCh, redrawCh)
+	wm.SetSize(110, 20)
+	if err := wm.Open(""); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	_, _, _, _ = wm.State()
+	for _, arg := range []string{"", "ruler?", "ruler? width?"} {
+		wm.Emit(event.Event{Type: event.Set, Arg: arg})
+		if len(eventCh) != 1 {
+			t.Errorf("set %s should send one event but got %d events", arg, len(eventCh))
+		}
+		if e := <-eventCh; e.Type != event.Info || e.Error.Error() == "" {
+			t.Errorf("set %s should report the options but got: %+v", arg, e)
+		}
+		for len(eventCh) > 0 {
+			<-eventCh
+		}
+	}
+	wm.Emit(event.Event{Type: event.Set, Arg: "ruler? width?"})
+	if e := <-eventCh; e.Error.Error() != "ruler\nwidth=0" {
+		t.Errorf("set should report the options but got: %q", e.Error.Error())
+	}
+	wm.Emit(event.Event{Type: event.Set, Arg: "noruler"})
+	if e := <-eventCh; e.Type != event.Redraw {
+		t.Errorf("set should redraw but got: %+v", e)
+	}
+	wm.Close()
+}
+
+func TestManagerListHighlights(t *testing.T) {
+	wm := NewManager()
+	eventCh, redrawCh := make(chan event.Event, 2), make(chan struct{})
+	wm.Init(eventCh, redrawCh)
+	wm.SetSize(110, 20)
+	if err := wm.Open(""); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	_, _, _, _ = wm.State()
+	wm.Emit(event.Event{Type: event.Highlight, CmdName: "highlight"})
+	if e := <-eventCh; e.Type != event.Error || e.Error.Error() != "no highlight rules" {
+		t.Errorf("highlight should fail but got: %+v", e)
+	}
+	wm.Emit(event.Event{Type: event.Highlight, CmdName: "highlight", Arg: "red 0x00"})
+	if e := <-eventCh; e.Type != event.Redraw {
+		t.Errorf("highlight should redraw but got: %+v", e)
+	}
+	wm.Emit(event.Event{Type: event.Highlight, CmdName: "highlight"})
+	if len(eventCh) != 1 {
+		t.Errorf("highlight should send one event but got %d events", len(eventCh))
+	}
+	if e := <-eventCh; e.Type != event.Info || e.Error.Error() != "red      0x00" {
+		t.Errorf("highlight should list the rules but got: %+v", e)
+	}
+	wm.Close()
+}
+
+func TestManagerSwapRecover(t *testing.T) {
+	f, _ := ioutil.TempFile("", "bed-test-manager-swap")
+	_, _ = f.WriteString("Hello, world!")
+	_ = f.Close()
+	defer os.Remove(f.Name())
+	defer os.Remove(f.Name() + ".bedswp")
+	defer os.Remove(f.Name() + "~")
+
+	wm := NewManager()
+	eventCh, redrawCh := make(chan event.Event), make(chan struct{})
+	wm.Init(eventCh, redrawCh)
+	wm.SetSize(110, 20)
+	if err := wm.Open(f.Name()); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	_, _, _, _ = wm.State()
+	go wm.Emit(event.Event{Type: event.Increment, Mode: mode.Normal})
+	<-redrawCh
+	if fi, err := os.Stat(f.Name() + ".bedswp"); err != nil || fi.Mode().Perm() != 0600 {
+		t.Errorf("swap file should be created only for the user but got: %v, %v", fi, err)
+	}
+	go wm.Emit(event.Event{Type: event.DeleteByte, Mode: mode.Normal})
+	<-redrawCh
+	if bs, _ := ioutil.ReadFile(f.Name() + ".bedswp"); !bytes.Contains(bs, []byte(`"bytes"`)) {
+		t.Errorf("swap file should not be written in the interval but got: %s", bs)
+	}
+	if err := wm.saveSwaps(); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	if bs, _ := ioutil.ReadFile(f.Name() + ".bedswp"); bytes.Contains(bs, []byte(`"bytes"`)) {
+		t.Errorf("swap file should be written by the watcher but got: %s", bs)
+	}
+
+	// the swap file is left after a crash
+	wm = NewManager()
+	eventCh, redrawCh = make(chan event.Event), make(chan struct{})
+	wm.Init(eventCh, redrawCh)
+	wm.SetSize(110, 20)
+	if err := wm.Open(f.Name()); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	expected := "swap file found: " + f.Name() + ".bedswp (use :recover to restore the unsaved changes)"
+	if e := wm.openedEvent(); e.Type != event.Info || e.Error.Error() != expected {
+		t.Errorf("swap file should be notified but got: %+v", e)
+	}
+	go wm.Emit(event.Event{Type: event.Recover})
+	if e := <-eventCh; e.Type != event.Redraw {
+		t.Errorf("event type should be %d but got: %+v", event.Redraw, e)
+	}
+	windowStates, _, windowIndex, _ := wm.State()
+	ws := windowStates[windowIndex]
+	if expected := "ello, world!"; !strings.HasPrefix(string(ws.Bytes), expected) {
+		t.Errorf("Bytes should starts with %q but got %q", expected, string(ws.Bytes))
+	}
+	if !ws.Modified {
+		t.Errorf("window should be modified after recovery")
+	}
+
+	go wm.Emit(event.Event{Type: event.Set, Arg: "backup"})
+	<-eventCh
+	go wm.Emit(event.Event{Type: event.Write})
+	if e := <-eventCh; e.Type != event.Info {
+		t.Errorf("write should succeed but got: %+v", e)
+	}
+	if bs, _ := ioutil.ReadFile(f.Name()); string(bs) != "ello, world!" {
+		t.Errorf("file contents should be %q but got %q", "ello, world!", string(bs))
+	}
+	if bs, _ := ioutil.ReadFile(f.Name() + "~"); string(bs) != "Hello, world!" {
+		t.Errorf("backup file contents should be %q but got %q", "Hello, world!", string(bs))
+	}
+	if _, err := os.Stat(f.Name() + ".bedswp"); !os.IsNotExist(err) {
+		t.Errorf("swap file should be removed after writing but got: %v", err)
+	}
+	wm.Close()
+}
+
+func TestManagerRescue(t *testing.T) {
+	f, _ := ioutil.TempFile("", "bed-test-manager-rescue")
+	_, _ = f.WriteString("Hello, world!")
+	_ = f.Close()
+	defer os.Remove(f.Name())
+	defer os.Remove(f.Name() + ".bedswp")
+
+	wm := NewManager()
+	eventCh, redrawCh := make(chan event.Event), make(chan struct{})
+	wm.Init(eventCh, redrawCh)
+	wm.SetSize(110, 20)
+	if err := wm.Open(f.Name()); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	_, _, _, _ = wm.State()
+	go wm.Emit(event.Event{Type: event.Set, Arg: "autosave=1h"})
+	<-eventCh
+	go wm.Emit(event.Event{Type: event.DeleteByte, Mode: mode.Normal})
+	<-redrawCh
+	if names := wm.Rescue(); !reflect.DeepEqual(names, []string{f.Name()}) {
+		t.Errorf("rescued files should be %v but got %v", []string{f.Name()}, names)
+	}
+	wm.Close()
+	if _, err := os.Stat(f.Name() + ".bedswp"); err != nil {
+		t.Errorf("swap file should be kept after rescue but got: %v", err)
+	}
+
+	wm = NewManager()
+	eventCh, redrawCh = make(chan event.Event), make(chan struct{})
+	wm.Init(eventCh, redrawCh)
+	wm.SetSize(110, 20)
+	if err := wm.Open(f.Name()); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	if err := wm.Recover(); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	windowStates, _, windowIndex, _ := wm.State()
+	if expected := "ello, world!"; !strings.HasPrefix(string(windowStates[windowIndex].Bytes), expected) {
+		t.Errorf("Bytes should starts with %q but got %q", expected, string(windowStates[windowIndex].Bytes))
+	}
+	if names := wm.Rescue(); !reflect.DeepEqual(names, []string{f.Name()}) {
+		t.Errorf("rescued files should be %v but got %v", []string{f.Name()}, names)
+	}
+	wm.Close()
+}
+
+func TestManagerRescueSpool(t *testing.T) {
+	f, _ := ioutil.TempFile("", "bed-test-manager-rescue-spool")
+	_, _ = f.WriteString("Hello, world!")
+	_ = f.Close()
+	defer os.Remove(f.Name())
+	defer os.Remove(f.Name() + ".bedswp")
+	spool, _ := ioutil.TempFile("", "bed-test-manager-spool")
+	_, _ = spool.WriteString("xyz")
+	defer os.Remove(spool.Name())
+
+	wm := NewManager()
+	eventCh, redrawCh := make(chan event.Event), make(chan struct{})
+	wm.Init(eventCh, redrawCh)
+	wm.SetSize(110, 20)
+	if err := wm.Open(f.Name()); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	_, _, _, _ = wm.State()
+	window := wm.windows[wm.windowIndex]
+	window.mu.Lock()
+	err := window.insertSpool(event.Event{Type: event.InsertBytes}, spool, 3)
+	window.mu.Unlock()
+	if err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	if names := wm.Rescue(); !reflect.DeepEqual(names, []string{f.Name()}) {
+		t.Errorf("rescued files should be %v but got %v", []string{f.Name()}, names)
+	}
+	wm.Close()
+	if bs, _ := ioutil.ReadFile(f.Name() + ".bedswp"); !bytes.Contains(bs, []byte(`"spool":`)) ||
+		bytes.Contains(bs, []byte(`"bytes"`)) {
+		t.Errorf("swap file should refer to the spool file but got: %s", bs)
+	}
+	if _, err := os.Stat(spool.Name()); err != nil {
+		t.Errorf("spool file should be kept after rescue but got: %v", err)
+	}
+
+	wm = NewManager()
+	eventCh, redrawCh = make(chan event.Event), make(chan struct{})
+	wm.Init(eventCh, redrawCh)
+	wm.SetSize(110, 20)
+	if err := wm.Open(f.Name()); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	if err := wm.Recover(); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	windowStates, _, windowIndex, _ := wm.State()
+	if expected := "xyzHello, world!"; !strings.HasPrefix(string(windowStates[windowIndex].Bytes), expected) {
+		t.Errorf("Bytes should starts with %q but got %q", expected, string(windowStates[windowIndex].Bytes))
+	}
+	wm.Close()
+	if _, err := os.Stat(spool.Name()); !os.IsNotExist(err) {
+		t.Errorf("spool file should be removed on closing but got: %v", err)
+	}
+}
+
+func TestManagerAutosave(t *testing.T) {
+	wm := NewManager()
+	eventCh, redrawCh := make(chan event.Event), make(chan struct{})
+	wm.Init(eventCh, redrawCh)
+	wm.SetSize(110, 20)
+	f, _ := ioutil.TempFile("", "bed-test-manager-autosave")
+	_, _ = f.WriteString("Hello, world!")
+	_ = f.Close()
+	defer os.Remove(f.Name())
+	defer os.Remove(f.Name() + ".bedswp")
+	if err := wm.Open(f.Name()); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	_, _, _, _ = wm.State()
+	go wm.Emit(event.Event{Type: event.Set, Arg: "autosave=1h"})
+	<-eventCh
+	go wm.Emit(event.Event{Type: event.DeleteByte, Mode: mode.Normal})
+	<-redrawCh
+	if _, err := os.Stat(f.Name() + ".bedswp"); !os.IsNotExist(err) {
+		t.Errorf("swap file should not be written before autosave but got: %v", err)
+	}
+	if err := wm.autosave(); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	if _, err := os.Stat(f.Name() + ".bedswp"); err != nil {
+		t.Errorf("swap file should be written on autosave but got: %v", err)
+	}
+
+	go wm.Emit(event.Event{Type: event.Set, Arg: "autowrite"})
+	<-eventCh
+	go func() {
+		if err := wm.autosave(); err != nil {
+			t.Errorf("err should be nil but got: %v", err)
+		}
+	}()
+	<-redrawCh
+	if bs, _ := ioutil.ReadFile(f.Name()); string(bs) != "ello, world!" {
+		t.Errorf("file contents should be %q but got %q", "ello, world!", string(bs))
+	}
+	if _, err := os.Stat(f.Name() + ".bedswp"); !os.IsNotExist(err) {
+		t.Errorf("swap file should be removed after autowrite but got: %v", err)
+	}
+	windowStates, _, windowIndex, _ := wm.State()
+	if windowStates[windowIndex].Modified {
+		t.Errorf("window should not be modified after autowrite")
+	}
+	wm.Close()
+}
+
 func TestManagerWincmd(t *testing.T) {
 	wm := NewManager()
 	eventCh, redrawCh := make(chan event.Event), make(chan struct{})
@@ -176,31 +1232,851 @@ func TestManagerWincmd(t *testing.T) {
 		t.Errorf("err should be nil but got: %v", err)
 	}
 	wm.Emit(event.Event{Type: event.Wincmd, Arg: "n"})
-	wm.Emit(event.Event{Type: event.Wincmd, Arg: "n"})
-	wm.Emit(event.Event{Type: event.Wincmd, Arg: "n"})
-	wm.Emit(event.Event{Type: event.MoveWindowLeft})
-	wm.Emit(event.Event{Type: event.FocusWindowRight})
-	wm.Emit(event.Event{Type: event.FocusWindowBottomRight})
-	wm.Emit(event.Event{Type: event.MoveWindowRight})
-	wm.Emit(event.Event{Type: event.FocusWindowLeft})
-	wm.Emit(event.Event{Type: event.MoveWindowTop})
-	wm.Resize(110, 20)
+	wm.Emit(event.Event{Type: event.Wincmd, Arg: "n"})
+	wm.Emit(event.Event{Type: event.Wincmd, Arg: "n"})
+	wm.Emit(event.Event{Type: event.MoveWindowLeft})
+	wm.Emit(event.Event{Type: event.FocusWindowRight})
+	wm.Emit(event.Event{Type: event.FocusWindowBottomRight})
+	wm.Emit(event.Event{Type: event.MoveWindowRight})
+	wm.Emit(event.Event{Type: event.FocusWindowLeft})
+	wm.Emit(event.Event{Type: event.MoveWindowTop})
+	wm.Resize(110, 20)
+
+	_, got, _, _ := wm.State()
+	expected := layout.NewLayout(2).SplitBottom(0).SplitLeft(1).
+		SplitLeft(3).Activate(2).Resize(0, 0, 110, 20)
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("layout should be %#v but got %#v", expected, got)
+	}
+
+	wm.Emit(event.Event{Type: event.FocusWindowDown})
+	wm.Emit(event.Event{Type: event.FocusWindowRight})
+	wm.Emit(event.Event{Type: event.Quit})
+	_, got, _, _ = wm.State()
+	expected = layout.NewLayout(2).SplitBottom(0).SplitLeft(3).Resize(0, 0, 110, 20)
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("layout should be %#v but got %#v", expected, got)
+	}
+
+	wm.Close()
+}
+
+func TestManagerQuickfix(t *testing.T) {
+	wm := NewManager()
+	eventCh, redrawCh := make(chan event.Event), make(chan struct{})
+	wm.Init(eventCh, redrawCh)
+	wm.SetSize(110, 20)
+	f, _ := ioutil.TempFile("", "bed-test-manager-quickfix")
+	_, _ = f.WriteString("\x00\x01Hello\xff\x00world!\x00\x00Hello, world!")
+	_ = f.Close()
+	defer os.Remove(f.Name())
+	if err := wm.Open(f.Name()); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	_, _, _, _ = wm.State()
+
+	go wm.Emit(event.Event{Type: event.QuickfixNext})
+	if e := <-eventCh; e.Type != event.Error || e.Error.Error() != "no quickfix list" {
+		t.Errorf("cnext should be refused but got: %+v", e)
+	}
+
+	go wm.Emit(event.Event{Type: event.SearchAll, Arg: "world"})
+	finishTask(t, wm, eventCh)
+	<-redrawCh
+	if e := <-eventCh; e.Type != event.Info || e.Error.Error() != `(1 of 2) 0x00000009: "world"` {
+		t.Errorf("searchall should move to the first item but got: %+v", e)
+	}
+	go wm.Emit(event.Event{Type: event.QuickfixNext})
+	<-redrawCh
+	if e := <-eventCh; e.Type != event.Info || e.Error.Error() != `(2 of 2) 0x00000018: "world"` {
+		t.Errorf("cnext should move to the next item but got: %+v", e)
+	}
+	windowStates, _, _, _ := wm.State()
+	if ws := windowStates[0]; ws.Cursor != 0x18 {
+		t.Errorf("cursor should be %d but got %d", 0x18, ws.Cursor)
+	}
+	go wm.Emit(event.Event{Type: event.QuickfixNext})
+	if e := <-eventCh; e.Type != event.Error || e.Error.Error() != "no more items" {
+		t.Errorf("cnext should be refused but got: %+v", e)
+	}
+
+	go wm.Emit(event.Event{Type: event.Strings, Arg: "5"})
+	finishTask(t, wm, eventCh)
+	expected := `    1 0x00000002          5  "Hello"
+    2 0x00000009          6  "world!"
+    3 0x00000011         13  "Hello, world!"`
+	if e := <-eventCh; e.Type != event.Info || e.Error.Error() != expected {
+		t.Errorf("strings should list the strings but got: %+v", e)
+	}
+	go wm.Emit(event.Event{Type: event.QuickfixGoto, Arg: "3"})
+	<-redrawCh
+	<-eventCh
+	go wm.Emit(event.Event{Type: event.QuickfixList})
+	if e := <-eventCh; e.Type != event.Info || !strings.HasSuffix(e.Error.Error(), `>   3 0x00000011         13  "Hello, world!"`) {
+		t.Errorf("clist should mark the current item but got: %+v", e)
+	}
+	wm.Close()
+}
+
+func TestManagerCancelTask(t *testing.T) {
+	wm := NewManager()
+	eventCh, redrawCh := make(chan event.Event), make(chan struct{})
+	wm.Init(eventCh, redrawCh)
+	wm.SetSize(110, 20)
+	if err := wm.Open(""); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	_, _, _, _ = wm.State()
+
+	started, resumeCh := make(chan struct{}), make(chan struct{})
+	if err := wm.startTask("testing", 100, func(t *task) (func() error, error) {
+		close(started)
+		<-resumeCh
+		if err := t.check(50); err != nil {
+			return nil, err
+		}
+		return func() error { return nil }, nil
+	}); err != nil {
+		t.Fatalf("err should be nil but got: %v", err)
+	}
+	<-started
+	if err := wm.startTask("other", 100, nil); err == nil || err.Error() != "testing is running" {
+		t.Errorf("err should be %q but got: %v", "testing is running", err)
+	}
+	wm.Emit(event.Event{Type: event.CancelTask})
+	close(resumeCh)
+	e := <-eventCh
+	for e.Type == event.Info {
+		e = <-eventCh
+	}
+	if e.Type != event.FinishTask {
+		t.Fatalf("event type should be %d but got: %d", event.FinishTask, e.Type)
+	}
+	go wm.Emit(e)
+	if e := <-eventCh; e.Type != event.Info || e.Error.Error() != "testing canceled" {
+		t.Errorf("task should be canceled but got: %+v", e)
+	}
+	wm.Close()
+}
+
+func TestManagerCloseTask(t *testing.T) {
+	wm := NewManager()
+	eventCh, redrawCh := make(chan event.Event), make(chan struct{})
+	wm.Init(eventCh, redrawCh)
+	wm.SetSize(110, 20)
+	if err := wm.Open(""); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	_, _, _, _ = wm.State()
+
+	started := make(chan struct{})
+	if err := wm.startTask("testing", 100, func(t *task) (func() error, error) {
+		close(started)
+		for t.check(50) == nil {
+			time.Sleep(time.Millisecond)
+		}
+		return nil, errCanceled
+	}); err != nil {
+		t.Fatalf("err should be nil but got: %v", err)
+	}
+	<-started
+	go wm.Emit(event.Event{Type: event.CursorNext, Mode: mode.Normal})
+	time.Sleep(2 * progressInterval)
+	wm.Close()
+	close(eventCh)
+	close(redrawCh)
+	time.Sleep(2 * progressInterval)
+}
+
+func TestManagerCloseWatch(t *testing.T) {
+	wm := NewManager()
+	eventCh, redrawCh := make(chan event.Event), make(chan struct{})
+	wm.Init(eventCh, redrawCh)
+	wm.SetSize(110, 20)
+	f, _ := ioutil.TempFile("", "bed-test-manager-close-watch")
+	_, _ = f.WriteString("Hello, world!")
+	_ = f.Close()
+	defer os.Remove(f.Name())
+	if err := wm.Open(f.Name()); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	_, _, _, _ = wm.State()
+	if err := ioutil.WriteFile(f.Name(), []byte("Hello, everyone!"), 0644); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	time.Sleep(watchInterval + watchInterval/2)
+	wm.Close()
+	close(eventCh)
+	close(redrawCh)
+	time.Sleep(watchInterval / 10)
+}
+
+func finishTask(t *testing.T, wm *Manager, eventCh <-chan event.Event) {
+	e := <-eventCh
+	for e.Type == event.Info && strings.HasSuffix(e.Error.Error(), "(<C-c> to cancel)") {
+		e = <-eventCh
+	}
+	if e.Type != event.FinishTask {
+		t.Fatalf("event type should be %d but got: %+v", event.FinishTask, e)
+	}
+	go wm.Emit(e)
+}
+
+func TestManagerInsertLargeBytes(t *testing.T) {
+	wm := NewManager()
+	eventCh, redrawCh := make(chan event.Event), make(chan struct{})
+	wm.Init(eventCh, redrawCh)
+	wm.SetSize(110, 20)
+	if err := wm.Open(""); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	_, _, _, _ = wm.State()
+	go wm.Emit(event.Event{Type: event.InsertBytes, Arg: "4 0x41"})
+	<-redrawCh
+	go wm.Emit(event.Event{Type: event.InsertBytes, Arg: "9223372036854775807", CmdName: "insertbytes"})
+	if e := <-eventCh; e.Type != event.Error || e.Error.Error() != "too many bytes to insert: 9223372036854775807" {
+		t.Errorf("insertion should fail but got: %+v", e)
+	}
+
+	go wm.Emit(event.Event{Type: event.InsertBytes, Arg: fmt.Sprintf("%d 0x424344", largeInsertLength+1)})
+	finishTask(t, wm, eventCh)
+	if e := <-eventCh; e.Type != event.Redraw {
+		t.Errorf("event type should be %d but got: %+v", event.Redraw, e)
+	}
+	windowStates, _, windowIndex, _ := wm.State()
+	if ws := windowStates[windowIndex]; ws.Length != largeInsertLength+5 || !strings.HasPrefix(string(ws.Bytes), "BCDBCD") {
+		t.Errorf("bytes should be inserted but got: %d, %q", ws.Length, string(ws.Bytes[:6]))
+	}
+	if window := wm.windows[windowIndex]; len(window.spools) != 1 || window.buffer.MemoryLen() > 4 {
+		t.Errorf("inserted bytes should be spooled but got: %v", window.spools)
+	}
+
+	go wm.Emit(event.Event{Type: event.StartInsert, Count: 3, Mode: mode.Normal})
+	<-redrawCh
+	for _, ch := range "45" {
+		go wm.Emit(event.Event{Type: event.Rune, Rune: ch, Mode: mode.Insert})
+		<-redrawCh
+	}
+	go wm.Emit(event.Event{Type: event.ExitInsert, Mode: mode.Normal})
+	<-redrawCh
+	windowStates, _, windowIndex, _ = wm.State()
+	if ws := windowStates[windowIndex]; ws.Length != largeInsertLength+8 || !strings.HasPrefix(string(ws.Bytes), "EEEBCD") || ws.Cursor != 3 {
+		t.Errorf("bytes should be repeated but got: %d, %q, %d", ws.Length, string(ws.Bytes[:6]), ws.Cursor)
+	}
+
+	go wm.Emit(event.Event{Type: event.StartInsert, Count: largeInsertLength + 1, Mode: mode.Normal})
+	<-redrawCh
+	for _, ch := range "46" {
+		go wm.Emit(event.Event{Type: event.Rune, Rune: ch, Mode: mode.Insert})
+		<-redrawCh
+	}
+	go wm.Emit(event.Event{Type: event.ExitInsert, Mode: mode.Normal})
+	<-redrawCh
+	finishTask(t, wm, eventCh)
+	if e := <-eventCh; e.Type != event.Redraw {
+		t.Errorf("event type should be %d but got: %+v", event.Redraw, e)
+	}
+	windowStates, _, windowIndex, _ = wm.State()
+	if ws := windowStates[windowIndex]; ws.Length != 2*largeInsertLength+9 || ws.Cursor != largeInsertLength+3 {
+		t.Errorf("bytes should be repeated but got: %d, %d", ws.Length, ws.Cursor)
+	}
+	bs := make([]byte, 8)
+	if _, err := wm.windows[windowIndex].buffer.ReadAt(bs, largeInsertLength); err != nil || string(bs) != "FFFFBCDB" {
+		t.Errorf("bytes should be %q but got %q (%v)", "FFFFBCDB", string(bs), err)
+	}
+	wm.Close()
+}
+
+func TestManagerSplitView(t *testing.T) {
+	wm := NewManager()
+	eventCh, redrawCh := make(chan event.Event), make(chan struct{})
+	wm.Init(eventCh, redrawCh)
+	wm.SetSize(110, 20)
+	if err := wm.Open(""); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	go wm.Emit(event.Event{Type: event.InsertBytes, Arg: "16 0x41"})
+	<-redrawCh
+	go wm.Emit(event.Event{Type: event.Split})
+	if e := <-eventCh; e.Type != event.Redraw {
+		t.Errorf("event type should be %d but got: %d", event.Redraw, e.Type)
+	}
+	_, _, _, _ = wm.State()
+	go wm.Emit(event.Event{Type: event.CursorNext, Count: 10, Mode: mode.Normal})
+	<-redrawCh
+	go wm.Emit(event.Event{Type: event.DeleteByte, Count: 3, Mode: mode.Normal})
+	<-redrawCh
+
+	windowStates, _, windowIndex, _ := wm.State()
+	if windowIndex != 1 {
+		t.Errorf("window index should be %d but got %d", 1, windowIndex)
+	}
+	for i, cursor := range []int64{0, 10} {
+		ws := windowStates[i]
+		if expected := strings.Repeat("A", 13); string(ws.Bytes[:ws.Size]) != expected {
+			t.Errorf("Bytes of window %d should be %q but got %q", i, expected, string(ws.Bytes[:ws.Size]))
+		}
+		if ws.Cursor != cursor {
+			t.Errorf("Cursor of window %d should be %d but got %d", i, cursor, ws.Cursor)
+		}
+		if !ws.Modified {
+			t.Errorf("window %d should be modified", i)
+		}
+	}
+
+	go wm.Emit(event.Event{Type: event.Wincmd, Arg: "j"})
+	<-eventCh
+	go wm.Emit(event.Event{Type: event.Undo, Mode: mode.Normal})
+	<-redrawCh
+	windowStates, _, _, _ = wm.State()
+	for i := range []int{0, 1} {
+		if ws := windowStates[i]; ws.Size != 16 {
+			t.Errorf("Size of window %d should be %d after undo but got %d", i, 16, ws.Size)
+		}
+	}
+	wm.Close()
+}
+
+func TestManagerAdjustWindow(t *testing.T) {
+	wm := NewManager()
+	eventCh, redrawCh := make(chan event.Event), make(chan struct{})
+	wm.Init(eventCh, redrawCh)
+	go func() {
+		for {
+			select {
+			case <-eventCh:
+			case <-redrawCh:
+			}
+		}
+	}()
+	wm.SetSize(110, 20)
+	if err := wm.Open(""); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	wm.Emit(event.Event{Type: event.Wincmd, Arg: "n"})
+	wm.Emit(event.Event{Type: event.IncreaseWindowHeight, Count: 3})
+	wm.Emit(event.Event{Type: event.Wincmd, Arg: "-"})
 
-	_, got, _, _ := wm.State()
-	expected := layout.NewLayout(2).SplitBottom(0).SplitLeft(1).
-		SplitLeft(3).Activate(2).Resize(0, 0, 110, 20)
-	if !reflect.DeepEqual(got, expected) {
-		t.Errorf("layout should be %#v but got %#v", expected, got)
+	windowStates, got, _, _ := wm.State()
+	if top, bottom := got.Collect()[1].Height(), got.Collect()[0].Height(); top != 12 || bottom != 8 {
+		t.Errorf("heights should be 12 and 8 but got %d and %d", top, bottom)
+	}
+	if expected := (12 - 2) * 16; len(windowStates[1].Bytes) != expected {
+		t.Errorf("size of the window should be %d but got %d", expected, len(windowStates[1].Bytes))
 	}
 
-	wm.Emit(event.Event{Type: event.FocusWindowDown})
-	wm.Emit(event.Event{Type: event.FocusWindowRight})
-	wm.Emit(event.Event{Type: event.Quit})
+	wm.Emit(event.Event{Type: event.EqualizeWindows})
 	_, got, _, _ = wm.State()
-	expected = layout.NewLayout(2).SplitBottom(0).SplitLeft(3).Resize(0, 0, 110, 20)
+	expected := layout.NewLayout(0).SplitTop(1).Resize(0, 0, 110, 20)
 	if !reflect.DeepEqual(got, expected) {
 		t.Errorf("layout should be %#v but got %#v", expected, got)
 	}
 
 	wm.Close()
 }
+
+func TestManagerBookmarks(t *testing.T) {
+	f, err := ioutil.TempFile("", "bed-test-manager-bookmarks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteString(strings.Repeat("Hello, world!", 10)); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	defer os.Remove(f.Name())
+	defer os.Remove(bookmarksPath(f.Name()))
+
+	wm := NewManager()
+	eventCh, redrawCh := make(chan event.Event), make(chan struct{})
+	wm.Init(eventCh, redrawCh)
+	wm.SetSize(110, 20)
+	if err := wm.Open(f.Name()); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	go func() {
+		wm.Emit(event.Event{Type: event.Bookmark, Arg: "header",
+			Range: &event.Range{From: event.Absolute{Offset: 0x10}}})
+		wm.Emit(event.Event{Type: event.Bookmark, Arg: "start"})
+		wm.Emit(event.Event{Type: event.Bookmarks})
+		wm.Emit(event.Event{Type: event.GotoBookmark, Arg: "unknown"})
+	}()
+	for _, expected := range []string{"bookmark header: 0x10", "bookmark start: 0x0"} {
+		if e := <-eventCh; e.Type != event.Info || e.Error.Error() != expected {
+			t.Errorf("event should be info %q but got %+v", expected, e)
+		}
+	}
+	expected := "0x00000000          0  start\n0x00000010         16  header"
+	if e := <-eventCh; e.Type != event.Info || e.Error.Error() != expected {
+		t.Errorf("event should be info %q but got %+v", expected, e)
+	}
+	if e := <-eventCh; e.Type != event.Error || e.Error.Error() != "bookmark not found: unknown" {
+		t.Errorf("event should be an error but got %+v", e)
+	}
+	go wm.Emit(event.Event{Type: event.Split})
+	if e := <-eventCh; e.Type != event.Redraw {
+		t.Errorf("event type should be %d but got: %d", event.Redraw, e.Type)
+	}
+	go func() {
+		wm.Emit(event.Event{Type: event.Bookmark, Arg: "view",
+			Range: &event.Range{From: event.Absolute{Offset: 0x20}}})
+		wm.Emit(event.Event{Type: event.Wincmd, Arg: "j"})
+		wm.Emit(event.Event{Type: event.Bookmarks})
+	}()
+	if e := <-eventCh; e.Type != event.Info || e.Error.Error() != "bookmark view: 0x20" {
+		t.Errorf("event should be info %q but got %+v", "bookmark view: 0x20", e)
+	}
+	<-eventCh
+	expected = "0x00000000          0  start\n0x00000010         16  header\n0x00000020         32  view"
+	if e := <-eventCh; e.Type != event.Info || e.Error.Error() != expected {
+		t.Errorf("bookmarks should be shared by the views but got %+v", e)
+	}
+	wm.Close()
+
+	wm = NewManager()
+	wm.Init(eventCh, redrawCh)
+	wm.SetSize(110, 20)
+	if err := wm.Open(f.Name()); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	_, _, _, _ = wm.State()
+	go func() {
+		wm.Emit(event.Event{Type: event.GotoBookmark, Arg: "header"})
+	}()
+	<-redrawCh
+	windowStates, _, _, _ := wm.State()
+	if windowStates[0].Cursor != 0x10 {
+		t.Errorf("cursor should be %d but got %d", 0x10, windowStates[0].Cursor)
+	}
+	go func() {
+		wm.Emit(event.Event{Type: event.DeleteBookmark, Arg: "header"})
+		wm.Emit(event.Event{Type: event.DeleteBookmark, Arg: "start"})
+		wm.Emit(event.Event{Type: event.DeleteBookmark, Arg: "view"})
+		wm.Emit(event.Event{Type: event.Bookmarks})
+	}()
+	if e := <-eventCh; e.Type != event.Error || e.Error.Error() != "no bookmarks" {
+		t.Errorf("event should be an error but got %+v", e)
+	}
+	if _, err := os.Stat(bookmarksPath(f.Name())); !os.IsNotExist(err) {
+		t.Errorf("bookmarks file should be removed but got: %v", err)
+	}
+	wm.Close()
+}
+
+func TestManagerBookmarksShift(t *testing.T) {
+	f, err := ioutil.TempFile("", "bed-test-manager-bookmarks-shift")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteString(strings.Repeat("Hello, world!", 10)); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	defer os.Remove(f.Name())
+	defer os.Remove(bookmarksPath(f.Name()))
+	if err := ioutil.WriteFile(bookmarksPath(f.Name()), []byte("[{"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	wm := NewManager()
+	eventCh, redrawCh := make(chan event.Event), make(chan struct{})
+	wm.Init(eventCh, redrawCh)
+	wm.SetSize(110, 20)
+	if err := wm.Open(f.Name()); err != nil {
+		t.Errorf("file should be opened with the broken bookmarks but got: %v", err)
+	}
+	if e := wm.openedEvent(); e.Type != event.Error || !strings.HasPrefix(e.Error.Error(), bookmarksPath(f.Name())+": ") {
+		t.Errorf("broken bookmarks should be reported but got: %+v", e)
+	}
+	_, _, _, _ = wm.State()
+	go wm.Emit(event.Event{Type: event.Bookmark, Arg: "world",
+		Range: &event.Range{From: event.Absolute{Offset: 0x07}}})
+	<-eventCh
+	go wm.Emit(event.Event{Type: event.InsertBytes, Arg: "3 0x41"})
+	<-redrawCh
+	go wm.Emit(event.Event{Type: event.CursorGoto, Range: &event.Range{From: event.Absolute{Offset: 0x05}}, Mode: mode.Normal})
+	<-redrawCh
+	go wm.Emit(event.Event{Type: event.DeleteByte, Count: 2, Mode: mode.Normal})
+	<-redrawCh
+	go wm.Emit(event.Event{Type: event.GotoBookmark, Arg: "world"})
+	<-redrawCh
+	windowStates, _, _, _ := wm.State()
+	if windowStates[0].Cursor != 0x08 {
+		t.Errorf("cursor should be %d but got %d", 0x08, windowStates[0].Cursor)
+	}
+	if bookmarks, err := loadBookmarks(f.Name()); err != nil || bookmarks[0].Offset != 0x07 {
+		t.Errorf("bookmarks should not be saved before writing but got: %+v, %v", bookmarks, err)
+	}
+	go wm.Emit(event.Event{Type: event.Write})
+	<-eventCh
+	if bookmarks, err := loadBookmarks(f.Name()); err != nil || bookmarks[0].Offset != 0x08 {
+		t.Errorf("bookmarks should be saved on writing but got: %+v, %v", bookmarks, err)
+	}
+	wm.Close()
+}
+
+func TestManagerAnnotationsShift(t *testing.T) {
+	f, err := ioutil.TempFile("", "bed-test-manager-annotations-shift")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteString(strings.Repeat("Hello, world!", 10)); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	defer os.Remove(f.Name())
+	defer os.Remove(annotationsPath(f.Name()))
+	if err := ioutil.WriteFile(annotationsPath(f.Name()), []byte("[{"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	wm := NewManager()
+	eventCh, redrawCh := make(chan event.Event), make(chan struct{})
+	wm.Init(eventCh, redrawCh)
+	wm.SetSize(110, 20)
+	if err := wm.Open(f.Name()); err != nil {
+		t.Errorf("file should be opened with the broken annotations but got: %v", err)
+	}
+	if e := wm.openedEvent(); e.Type != event.Error || !strings.HasPrefix(e.Error.Error(), annotationsPath(f.Name())+": ") {
+		t.Errorf("broken annotations should be reported but got: %+v", e)
+	}
+	_, _, _, _ = wm.State()
+	go wm.Emit(event.Event{Type: event.Annotate, Arg: `0x07-0x0b "world"`})
+	<-eventCh
+	go wm.Emit(event.Event{Type: event.InsertBytes, Arg: "3 0x41"})
+	<-redrawCh
+	go wm.Emit(event.Event{Type: event.CursorGoto, Range: &event.Range{From: event.Absolute{Offset: 0x09}}, Mode: mode.Normal})
+	<-redrawCh
+	go wm.Emit(event.Event{Type: event.DeleteByte, Count: 2, Mode: mode.Normal})
+	<-redrawCh
+	windowStates, _, _, _ := wm.State()
+	if expected := []state.Annotation{{From: 0x09, To: 0x0c, Note: "world", Color: "yellow"}}; !reflect.DeepEqual(windowStates[0].Annotations, expected) {
+		t.Errorf("annotations should be %+v but got %+v", expected, windowStates[0].Annotations)
+	}
+	if annotations, err := loadAnnotations(f.Name()); err != nil || annotations[0].From != 0x07 {
+		t.Errorf("annotations should not be saved before writing but got: %+v, %v", annotations, err)
+	}
+	go wm.Emit(event.Event{Type: event.Write})
+	<-eventCh
+	if annotations, err := loadAnnotations(f.Name()); err != nil || annotations[0].From != 0x09 || annotations[0].To != 0x0c {
+		t.Errorf("annotations should be saved on writing but got: %+v, %v", annotations, err)
+	}
+	wm.Close()
+}
+
+func TestManagerChanges(t *testing.T) {
+	f, err := ioutil.TempFile("", "bed-test-manager-changes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteString("Hello, world!"); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	defer os.Remove(f.Name())
+
+	wm := NewManager()
+	eventCh, redrawCh := make(chan event.Event), make(chan struct{})
+	wm.Init(eventCh, redrawCh)
+	wm.SetSize(110, 20)
+	if err := wm.Open(f.Name()); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	_, _, _, _ = wm.State()
+	go wm.Emit(event.Event{Type: event.Changes})
+	if e := <-eventCh; e.Type != event.Error || e.Error.Error() != "no changes" {
+		t.Errorf("event should be an error but got %+v", e)
+	}
+	go wm.Emit(event.Event{Type: event.InsertBytes, Arg: "0x30000 0x41"})
+	<-redrawCh
+	go wm.Emit(event.Event{Type: event.Write})
+	<-eventCh
+	go wm.Emit(event.Event{Type: event.Changes})
+	if e, expected := <-eventCh, "0x00000000-0x0002ffff     196608  saved"; e.Type != event.Info || e.Error.Error() != expected {
+		t.Errorf("event should be info %q but got %+v", expected, e)
+	}
+	go wm.Emit(event.Event{Type: event.CursorGoto, Range: &event.Range{From: event.Absolute{Offset: 0x2ffff}}, Mode: mode.Normal})
+	<-redrawCh
+	go wm.Emit(event.Event{Type: event.Increment, Mode: mode.Normal})
+	<-redrawCh
+	go wm.Emit(event.Event{Type: event.Changes})
+	if e, expected := <-eventCh, "0x00000000-0x0002ffff     196608  unsaved"; e.Type != event.Info || e.Error.Error() != expected {
+		t.Errorf("event should be info %q but got %+v", expected, e)
+	}
+	wm.Close()
+}
+
+func TestManagerUndoList(t *testing.T) {
+	wm := NewManager()
+	eventCh, redrawCh := make(chan event.Event), make(chan struct{})
+	wm.Init(eventCh, redrawCh)
+	wm.SetSize(110, 20)
+	if err := wm.Open(""); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	_, _, _, _ = wm.State()
+	go func() {
+		for range redrawCh {
+		}
+	}()
+	go func() {
+		wm.Emit(event.Event{Type: event.UndoList})
+		wm.Emit(event.Event{Type: event.Undo})
+		wm.Emit(event.Event{Type: event.InsertBytes, Arg: "5 0x48"})
+		wm.Emit(event.Event{Type: event.InsertBytes, Arg: "2 0x21"})
+		wm.Emit(event.Event{Type: event.Undo, Count: 2})
+		wm.Emit(event.Event{Type: event.Redo})
+		wm.Emit(event.Event{Type: event.UndoList})
+		wm.Emit(event.Event{Type: event.Redo, Count: 3})
+		wm.Emit(event.Event{Type: event.Redo})
+		wm.Emit(event.Event{Type: event.Set, Arg: "undolevels=1"})
+		wm.Emit(event.Event{Type: event.InsertBytes, Arg: "1 0x3f"})
+		wm.Emit(event.Event{Type: event.Undo, Count: 3})
+	}()
+	if e := <-eventCh; e.Type != event.Error || e.Error.Error() != "no changes" {
+		t.Errorf("event should be an error but got %+v", e)
+	}
+	for _, pattern := range []string{
+		`^already at oldest change$`,
+		`^2 changes; before #1 \d\d:\d\d:\d\d; 5 bytes at 0x0$`,
+		`^1 change; after #1 \d\d:\d\d:\d\d; 5 bytes at 0x0$`,
+		`^> #1    \d\d:\d\d:\d\d  0x00000000          5\n  #2    \d\d:\d\d:\d\d  0x00000000          2$`,
+		`^1 change; after #2 \d\d:\d\d:\d\d; 2 bytes at 0x0$`,
+		`^already at newest change$`,
+	} {
+		if e := <-eventCh; e.Type != event.Info || !regexp.MustCompile(pattern).MatchString(e.Error.Error()) {
+			t.Errorf("event should be info matching %q but got %+v", pattern, e)
+		}
+	}
+	if e := <-eventCh; e.Type != event.Redraw {
+		t.Errorf("event type should be %d but got: %d", event.Redraw, e.Type)
+	}
+	pattern := `^1 change; before #3 \d\d:\d\d:\d\d; 1 bytes at 0x0$`
+	if e := <-eventCh; e.Type != event.Info || !regexp.MustCompile(pattern).MatchString(e.Error.Error()) {
+		t.Errorf("event should be info matching %q but got %+v", pattern, e)
+	}
+	wm.Close()
+}
+
+func TestManagerBaseAddress(t *testing.T) {
+	wm := NewManager()
+	eventCh, redrawCh := make(chan event.Event), make(chan struct{})
+	wm.Init(eventCh, redrawCh)
+	wm.SetSize(110, 20)
+	if err := wm.Open(""); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	_, _, _, _ = wm.State()
+	go wm.Emit(event.Event{Type: event.InsertBytes, Arg: "32 0x41"})
+	<-redrawCh
+	go wm.Emit(event.Event{Type: event.Set, Arg: "baseaddress=0x8000000"})
+	if e := <-eventCh; e.Type != event.Redraw {
+		t.Errorf("event type should be %d but got: %d", event.Redraw, e.Type)
+	}
+	go wm.Emit(event.Event{Type: event.CursorGoto, Mode: mode.Normal,
+		Range: &event.Range{From: event.Absolute{Offset: 0x8000010}}})
+	<-redrawCh
+	windowStates, _, _, _ := wm.State()
+	if s := windowStates[0]; s.Cursor != 0x10 || s.BaseAddress != 0x8000000 {
+		t.Errorf("cursor should be %d with base address %d but got %d and %d",
+			0x10, 0x8000000, s.Cursor, s.BaseAddress)
+	}
+	go wm.Emit(event.Event{Type: event.Copy, Arg: "offset", CmdName: "cop[y]"})
+	if e := <-eventCh; e.Type != event.Info || e.Error.Error() != "copied 0x8000010" {
+		t.Errorf("event should be info %q but got %+v", "copied 0x8000010", e)
+	}
+	wm.Close()
+}
+
+func TestManagerDisassemble(t *testing.T) {
+	f, _ := ioutil.TempFile("", "bed-test-manager-disassemble")
+	_, _ = f.Write([]byte{0x55, 0x48, 0x89, 0xe5, 0xc3})
+	_ = f.Close()
+	defer os.Remove(f.Name())
+
+	wm := NewManager()
+	eventCh, redrawCh := make(chan event.Event), make(chan struct{})
+	wm.Init(eventCh, redrawCh)
+	wm.SetSize(110, 20)
+	if err := wm.Open(f.Name()); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	_, _, _, _ = wm.State()
+	go wm.Emit(event.Event{Type: event.Disassemble, CmdName: "disassemble", Arg: "2"})
+	if e := <-eventCh; e.Type != event.Redraw {
+		t.Errorf("event type should be %d but got: %+v", event.Redraw, e)
+	}
+	windowStates, _, windowIndex, _ := wm.State()
+	expected := []string{"00000000  55           push rbp", "00000001  4889e5       mov rbp, rsp"}
+	if lines := windowStates[windowIndex].Disassembly; !reflect.DeepEqual(lines, expected) {
+		t.Errorf("disassembly should be %q but got %q", expected, lines)
+	}
+
+	go wm.Emit(event.Event{Type: event.CursorNext, Mode: mode.Normal, Count: 4})
+	<-redrawCh
+	windowStates, _, windowIndex, _ = wm.State()
+	expected = []string{"00000004  c3           ret"}
+	if lines := windowStates[windowIndex].Disassembly; !reflect.DeepEqual(lines, expected) {
+		t.Errorf("disassembly should follow the cursor %q but got %q", expected, lines)
+	}
+
+	go wm.Emit(event.Event{Type: event.Disassemble, CmdName: "disassemble"})
+	<-eventCh
+	windowStates, _, windowIndex, _ = wm.State()
+	if lines := windowStates[windowIndex].Disassembly; lines != nil {
+		t.Errorf("disassembly should be hidden but got %q", lines)
+	}
+
+	go wm.Emit(event.Event{Type: event.Set, Arg: "arch=mips"})
+	<-eventCh
+	go wm.Emit(event.Event{Type: event.Disassemble, CmdName: "disassemble"})
+	if e := <-eventCh; e.Type != event.Error || !strings.HasPrefix(e.Error.Error(), "unsupported architecture: mips") {
+		t.Errorf("disassemble should fail but got: %+v", e)
+	}
+	wm.Close()
+}
+
+func TestManagerSections(t *testing.T) {
+	f, err := elf.Open(os.Args[0])
+	if err != nil {
+		t.Skip("the test binary is not an ELF file")
+	}
+	text := f.Section(".text")
+	f.Close()
+	if text == nil {
+		t.Skip("the test binary has no text section")
+	}
+
+	wm := NewManager()
+	eventCh, redrawCh := make(chan event.Event), make(chan struct{})
+	wm.Init(eventCh, redrawCh)
+	wm.SetSize(110, 20)
+	if err := wm.Open(""); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	go wm.Emit(event.Event{Type: event.Sections})
+	if e := <-eventCh; e.Type != event.Error || e.Error.Error() != "not an ELF or PE file" {
+		t.Errorf("event should be an error but got %+v", e)
+	}
+	wm.Close()
+
+	wm = NewManager()
+	wm.Init(eventCh, redrawCh)
+	wm.SetSize(110, 20)
+	if err := wm.Open(os.Args[0]); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	_, _, _, _ = wm.State()
+	go wm.Emit(event.Event{Type: event.Sections})
+	e := <-eventCh
+	if expected := fmt.Sprintf("0x%08x %10d  .text SHT_PROGBITS", text.Offset, text.Size); e.Type != event.Info ||
+		!strings.Contains(e.Error.Error(), expected) {
+		t.Errorf("event should be info containing %q but got %+v", expected, e)
+	}
+	go wm.Emit(event.Event{Type: event.GotoSection, Arg: ".text", Mode: mode.Normal})
+	<-redrawCh
+	windowStates, _, _, _ := wm.State()
+	if windowStates[0].Cursor != int64(text.Offset) {
+		t.Errorf("cursor should be %d but got %d", text.Offset, windowStates[0].Cursor)
+	}
+	if expected := (state.Label{Offset: int64(text.Offset), Text: ".text"}); !containsLabel(windowStates[0].Labels, expected) {
+		t.Errorf("labels should contain %+v but got %+v", expected, windowStates[0].Labels)
+	}
+	go wm.Emit(event.Event{Type: event.GotoSection, Arg: ".unknown", CmdName: "sec[tion]"})
+	if e := <-eventCh; e.Type != event.Error || e.Error.Error() != "section not found: .unknown" {
+		t.Errorf("event should be an error but got %+v", e)
+	}
+	wm.Close()
+}
+
+func containsLabel(labels []state.Label, label state.Label) bool {
+	for _, l := range labels {
+		if l == label {
+			return true
+		}
+	}
+	return false
+}
+
+func TestManagerTable(t *testing.T) {
+	wm := NewManager()
+	eventCh, redrawCh := make(chan event.Event), make(chan struct{})
+	wm.Init(eventCh, redrawCh)
+	wm.SetSize(110, 20)
+	if err := wm.Open(""); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	_, _, _, _ = wm.State()
+	go wm.Emit(event.Event{Type: event.InsertBytes, Arg: "15 0x41"})
+	<-redrawCh
+	go wm.Emit(event.Event{Type: event.Set, Arg: "table"})
+	<-eventCh
+	go wm.Emit(event.Event{Type: event.Set, Arg: "schema=id:u16, v:f32"})
+	if e := <-eventCh; e.Type != event.Redraw {
+		t.Errorf("event type should be %d but got: %d", event.Redraw, e.Type)
+	}
+	windowStates, _, _, _ := wm.State()
+	s := windowStates[0]
+	if s.Width != 6 {
+		t.Errorf("width should be %d but got %d", 6, s.Width)
+	}
+	expected := [][]state.Field{
+		{{Text: "id=16705", Offset: 0, Size: 2}, {Text: "v=12.078431", Offset: 2, Size: 4}},
+		{{Text: "id=16705", Offset: 0, Size: 2}, {Text: "v=12.078431", Offset: 2, Size: 4}},
+		{{Text: "id=16705", Offset: 0, Size: 2}},
+	}
+	if !reflect.DeepEqual(s.Records, expected) {
+		t.Errorf("records should be %+v but got %+v", expected, s.Records)
+	}
+	go wm.Emit(event.Event{Type: event.Set, Arg: "recordsize=8"})
+	<-eventCh
+	if windowStates, _, _, _ = wm.State(); windowStates[0].Width != 8 || len(windowStates[0].Records) != 2 {
+		t.Errorf("width should be 8 with 2 records but got %d and %d",
+			windowStates[0].Width, len(windowStates[0].Records))
+	}
+	go wm.Emit(event.Event{Type: event.Set, Arg: "schema=u24"})
+	if e := <-eventCh; e.Type != event.Error || e.Error.Error() != "unknown type: u24" {
+		t.Errorf("event should be an error but got %+v", e)
+	}
+	wm.Close()
+}
+
+func TestParseBytePattern(t *testing.T) {
+	testCases := []struct {
+		target   string
+		expected string
+		err      string
+	}{
+		{"255", "\xff", ""},
+		{"0xdeadbeef", "\xde\xad\xbe\xef", ""},
+		{`"AB\x00"`, "AB\x00", ""},
+		{`\xde\xad`, "\xde\xad", ""},
+		{`'a b'`, "a b", ""},
+		{`""`, "", `invalid byte pattern: ""`},
+		{"abc", "", "invalid byte pattern: abc"},
+		{`\xg0`, "", `invalid escape sequence: \xg0`},
+	}
+	for _, testCase := range testCases {
+		got, err := parseBytePattern(testCase.target)
+		if testCase.err != "" {
+			if err == nil || err.Error() != testCase.err {
+				t.Errorf("parseBytePattern(%q) should return error %q but got: %v", testCase.target, testCase.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseBytePattern(%q) should not return an error but got: %v", testCase.target, err)
+		}
+		if string(got) != testCase.expected {
+			t.Errorf("parseBytePattern(%q) should return %q but got %q", testCase.target, testCase.expected, got)
+		}
+	}
+}
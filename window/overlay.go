@@ -0,0 +1,312 @@
+package window
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mitchellh/go-homedir"
+
+	"github.com/itchyny/bed/event"
+	"github.com/itchyny/bed/state"
+)
+
+const (
+	overlayColor         = "fuchsia" // the color of the enabled patches
+	overlayDisabledColor = "gray"    // the color of the disabled patches
+)
+
+// overlayPatch is the bytes overwriting the buffer at the offset, which is
+// displayed but not written to the buffer until the patches are committed.
+type overlayPatch struct {
+	offset  int64
+	bytes   []byte
+	enabled bool
+}
+
+// loadOverlay loads the patches from the file, and displays them over the
+// buffer as the pending edits.
+func (m *Manager) loadOverlay(e event.Event) error {
+	if e.Arg == "" {
+		return fmt.Errorf("an argument is required for %s", e.CmdName)
+	}
+	name, err := homedir.Expand(e.Arg)
+	if err != nil {
+		return err
+	}
+	bs, err := ioutil.ReadFile(name)
+	if err != nil {
+		return err
+	}
+	patches, err := parseOverlay(bs)
+	if err != nil {
+		return fmt.Errorf("%s: %s", e.Arg, err)
+	}
+	window := m.windows[m.windowIndex]
+	waitWindow(window)
+	window.mu.Lock()
+	for _, p := range patches {
+		if p.offset+int64(len(p.bytes)) > window.length {
+			window.mu.Unlock()
+			return fmt.Errorf("%s: patch at 0x%x exceeds the buffer", e.Arg, p.offset)
+		}
+	}
+	window.overlay = patches
+	window.mu.Unlock()
+	m.eventCh <- event.Event{Type: event.Info, Error: fmt.Errorf("%d patches loaded from %s", len(patches), e.Arg)}
+	return nil
+}
+
+// parseOverlay parses the patches in JSON ([{"offset": 16, "bytes": "dead"}])
+// or CSV (16,dead). The offsets can be the strings of the numbers (0x10), and
+// the bytes are the hex digits, which can be separated by the spaces.
+func parseOverlay(bs []byte) ([]*overlayPatch, error) {
+	var patches []*overlayPatch
+	if bs = bytes.TrimSpace(bs); len(bs) > 0 && bs[0] == '[' {
+		var xs []struct {
+			Offset json.RawMessage `json:"offset"`
+			Bytes  string          `json:"bytes"`
+		}
+		if err := json.Unmarshal(bs, &xs); err != nil {
+			return nil, err
+		}
+		for _, x := range xs {
+			p, err := parseOverlayPatch(strings.Trim(string(x.Offset), `"`), x.Bytes)
+			if err != nil {
+				return nil, err
+			}
+			patches = append(patches, p)
+		}
+	} else {
+		r := csv.NewReader(bytes.NewReader(bs))
+		r.Comment, r.FieldsPerRecord, r.TrimLeadingSpace = '#', 2, true
+		for line := 1; ; line++ {
+			xs, err := r.Read()
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				return nil, err
+			}
+			p, err := parseOverlayPatch(xs[0], xs[1])
+			if err != nil {
+				if line == 1 && err == errOverlayOffset {
+					continue // skip the header
+				}
+				return nil, fmt.Errorf("line %d: %s", line, err)
+			}
+			patches = append(patches, p)
+		}
+	}
+	if len(patches) == 0 {
+		return nil, errors.New("no patches")
+	}
+	sort.SliceStable(patches, func(i, j int) bool {
+		return patches[i].offset < patches[j].offset
+	})
+	return patches, nil
+}
+
+var errOverlayOffset = errors.New("invalid offset of patch")
+
+func parseOverlayPatch(offset, digits string) (*overlayPatch, error) {
+	o, err := strconv.ParseInt(strings.TrimSpace(offset), 0, 64)
+	if err != nil || o < 0 {
+		return nil, errOverlayOffset
+	}
+	digits = strings.Join(strings.Fields(digits), "")
+	if strings.HasPrefix(digits, "0x") || strings.HasPrefix(digits, "0X") {
+		digits = digits[2:]
+	}
+	bs, err := hex.DecodeString(digits)
+	if err != nil || len(bs) == 0 {
+		return nil, fmt.Errorf("invalid bytes of patch at 0x%x", o)
+	}
+	return &overlayPatch{offset: o, bytes: bs, enabled: true}, nil
+}
+
+// listOverlay lists the patches in the quickfix list for reviewing.
+func (m *Manager) listOverlay(e event.Event) error {
+	if len(e.Arg) > 0 {
+		return fmt.Errorf("too many arguments for %s", e.CmdName)
+	}
+	window := m.windows[m.windowIndex]
+	window.mu.Lock()
+	items := make([]quickfixItem, len(window.overlay))
+	for i, p := range window.overlay {
+		items[i] = quickfixItem{p.offset, int64(len(p.bytes)), p.String(i)}
+	}
+	window.mu.Unlock()
+	if len(items) == 0 {
+		return errors.New("no patches")
+	}
+	m.setQuickfix(window, items)
+	return m.listQuickfix(event.Event{})
+}
+
+func (p *overlayPatch) String(i int) string {
+	mark := " "
+	if p.enabled {
+		mark = "x"
+	}
+	return fmt.Sprintf("%d [%s] % x", i+1, mark, p.bytes)
+}
+
+// toggleOverlay toggles the patch of the number, or the patches at the cursor.
+func (m *Manager) toggleOverlay(e event.Event) error {
+	window := m.windows[m.windowIndex]
+	window.mu.Lock()
+	defer window.mu.Unlock()
+	if len(window.overlay) == 0 {
+		return errors.New("no patches")
+	}
+	if e.Arg != "" {
+		i, err := strconv.Atoi(e.Arg)
+		if err != nil || i < 1 || i > len(window.overlay) {
+			return fmt.Errorf("invalid patch number: %s", e.Arg)
+		}
+		window.overlay[i-1].enabled = !window.overlay[i-1].enabled
+		return nil
+	}
+	var found bool
+	for _, p := range window.overlay {
+		if p.offset <= window.cursor && window.cursor < p.offset+int64(len(p.bytes)) {
+			p.enabled, found = !p.enabled, true
+		}
+	}
+	if !found {
+		return errors.New("no patch at the cursor")
+	}
+	return nil
+}
+
+// commitOverlay writes the enabled patches to the buffer as one change, or
+// discards the patches on :discardoverlay.
+func (m *Manager) commitOverlay(e event.Event) error {
+	if len(e.Arg) > 0 {
+		return fmt.Errorf("too many arguments for %s", e.CmdName)
+	}
+	window := m.windows[m.windowIndex]
+	waitWindow(window)
+	window.mu.Lock()
+	if len(window.overlay) == 0 {
+		window.mu.Unlock()
+		return errors.New("no patches")
+	}
+	var count int
+	if e.Type == event.CommitOverlay {
+		count = window.commitOverlay()
+	}
+	window.overlay = nil
+	window.mu.Unlock()
+	msg := "patches discarded"
+	if e.Type == event.CommitOverlay {
+		msg = fmt.Sprintf("%d patches committed", count)
+	}
+	m.eventCh <- event.Event{Type: event.Info, Error: errors.New(msg)}
+	return nil
+}
+
+func (w *window) commitOverlay() int {
+	var count int
+	for _, p := range w.overlay {
+		if !p.enabled || p.offset+int64(len(p.bytes)) > w.length {
+			continue
+		}
+		for i, b := range p.bytes {
+			w.replace(p.offset+int64(i), b)
+		}
+		count++
+	}
+	if count > 0 {
+		w.pushHistory(w.offset, w.cursor)
+		w.changedSwap()
+	}
+	return count
+}
+
+// applyOverlay overwrites the bytes of the window state with the enabled
+// patches, and highlights the patches.
+func (w *window) applyOverlay(s *state.WindowState) {
+	for _, seg := range stateSegments(s) {
+		from, to := seg.offset, seg.offset+int64(seg.length)
+		for _, p := range w.overlay {
+			end := p.offset + int64(len(p.bytes))
+			if end <= from || to <= p.offset {
+				continue
+			}
+			color := overlayDisabledColor
+			if p.enabled {
+				color = overlayColor
+				for i, b := range p.bytes {
+					if o := p.offset + int64(i); from <= o && o < to {
+						s.Bytes[seg.index+int(o-from)] = b
+					}
+				}
+			}
+			s.Highlights = append(s.Highlights, state.Highlight{From: p.offset, To: end - 1, Color: color})
+		}
+	}
+}
+
+// PatchFile overwrites the bytes of the file in place with the patches; the
+// pairs of the offsets and the hex digits, and the patches in the spec file
+// of the overlay format. It returns the number of the written bytes.
+func PatchFile(name string, pairs [][2]string, spec string) (int64, error) {
+	var patches []*overlayPatch
+	for _, pair := range pairs {
+		p, err := parseOverlayPatch(pair[0], pair[1])
+		if err != nil {
+			if err == errOverlayOffset {
+				err = fmt.Errorf("%s: %s", err, pair[0])
+			}
+			return 0, err
+		}
+		patches = append(patches, p)
+	}
+	if spec != "" {
+		bs, err := ioutil.ReadFile(spec)
+		if err != nil {
+			return 0, err
+		}
+		ps, err := parseOverlay(bs)
+		if err != nil {
+			return 0, fmt.Errorf("%s: %s", spec, err)
+		}
+		patches = append(patches, ps...)
+	}
+	if len(patches) == 0 {
+		return 0, errors.New("no patches")
+	}
+	f, err := os.OpenFile(name, os.O_RDWR, 0)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	window, err := newWindow(f, name, filepath.Base(name), make(chan struct{}))
+	if err != nil {
+		return 0, err
+	}
+	for _, p := range patches {
+		if p.offset+int64(len(p.bytes)) > window.length {
+			return 0, fmt.Errorf("patch at 0x%x exceeds the file size: %d bytes", p.offset, window.length)
+		}
+		p.enabled = true
+	}
+	window.overlay = patches
+	window.commitOverlay()
+	n, err := window.writeChangedTo(f, window.length)
+	if err != nil {
+		return n, err
+	}
+	return n, f.Sync()
+}
@@ -0,0 +1,118 @@
+package window
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseOverlay(t *testing.T) {
+	testCases := []struct {
+		src      string
+		expected string
+		err      string
+	}{
+		{`[{"offset": 16, "bytes": "dead"}, {"offset": "0x4", "bytes": "0x01 02"}]`,
+			"0x4:0102,0x10:dead", ""},
+		{"offset,bytes\n# comment\n0x20, ff\n8,\"00 11\"\n", "0x8:0011,0x20:ff", ""},
+		{"0x20,ff\nx,00\n", "", "line 2: invalid offset of patch"},
+		{"0x20,fg\n", "", "line 1: invalid bytes of patch at 0x20"},
+		{`[{"offset": -1, "bytes": "00"}]`, "", "invalid offset of patch"},
+		{"[]", "", "no patches"},
+	}
+	for _, tc := range testCases {
+		patches, err := parseOverlay([]byte(tc.src))
+		if tc.err != "" {
+			if err == nil || err.Error() != tc.err {
+				t.Errorf("parseOverlay(%q) should return error %q but got: %v", tc.src, tc.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseOverlay(%q) should not return an error but got: %v", tc.src, err)
+			continue
+		}
+		var xs []string
+		for _, p := range patches {
+			xs = append(xs, fmt.Sprintf("0x%x:%x", p.offset, p.bytes))
+		}
+		if got := strings.Join(xs, ","); got != tc.expected {
+			t.Errorf("parseOverlay(%q) should return %q but got %q", tc.src, tc.expected, got)
+		}
+	}
+}
+
+func TestWindowOverlay(t *testing.T) {
+	window, err := newWindow(strings.NewReader("0123456789abcdef"), "test", "test", make(chan struct{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	window.setSize(16, 10)
+	window.overlay, err = parseOverlay([]byte("2,4142\n8,5a\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	window.overlay[1].enabled = false
+
+	s, err := window.state()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(s.Bytes[:16]); got != "01AB456789abcdef" {
+		t.Errorf("bytes should be overlaid but got %q", got)
+	}
+	if len(s.Highlights) != 2 || s.Highlights[0].From != 2 || s.Highlights[0].To != 3 ||
+		s.Highlights[0].Color != overlayColor || s.Highlights[1].Color != overlayDisabledColor {
+		t.Errorf("patches should be highlighted but got %+v", s.Highlights)
+	}
+	if bs, _ := window.readFull(0, window.length); string(bs) != "0123456789abcdef" {
+		t.Errorf("buffer should not be changed but got %q", bs)
+	}
+
+	if count := window.commitOverlay(); count != 1 {
+		t.Errorf("count should be 1 but got %d", count)
+	}
+	if bs, _ := window.readFull(0, window.length); string(bs) != "01AB456789abcdef" {
+		t.Errorf("enabled patches should be committed but got %q", bs)
+	}
+	window.undo(1)
+	if bs, _ := window.readFull(0, window.length); string(bs) != "0123456789abcdef" {
+		t.Errorf("committed patches should be undone but got %q", bs)
+	}
+}
+
+func TestPatchFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bed-patch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	name, spec := filepath.Join(dir, "test.bin"), filepath.Join(dir, "patches.json")
+	if err := ioutil.WriteFile(name, []byte("0123456789"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(spec, []byte(`[{"offset": 8, "bytes": "4142"}]`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	n, err := PatchFile(name, [][2]string{{"0x2", "ff ee"}}, spec)
+	if err != nil {
+		t.Fatalf("err should be nil but got: %v", err)
+	}
+	if n != 4 {
+		t.Errorf("written bytes should be 4 but got %d", n)
+	}
+	if bs, _ := ioutil.ReadFile(name); string(bs) != "01\xff\xee4567AB" {
+		t.Errorf("file should be patched but got %q", bs)
+	}
+	if _, err = PatchFile(name, [][2]string{{"9", "0000"}}, ""); err == nil ||
+		err.Error() != "patch at 0x9 exceeds the file size: 10 bytes" {
+		t.Errorf("err should be reported but got: %v", err)
+	}
+	if _, err = PatchFile(name, [][2]string{{"x", "00"}}, ""); err == nil ||
+		err.Error() != "invalid offset of patch: x" {
+		t.Errorf("err should be reported but got: %v", err)
+	}
+}
@@ -0,0 +1,79 @@
+package window
+
+// overtype is the byte overwritten in replace mode, which is restored one by
+// one by :undopartial, or by backspace in replace mode.
+type overtype struct {
+	offset   int64
+	original byte
+	appended bool // the byte is appended at the end, and is deleted on restore
+}
+
+// recordOvertype records the byte under the cursor before overwriting it in
+// replace mode. The records are discarded when the buffer is changed other
+// than overwriting in replace mode.
+func (w *window) recordOvertype() {
+	if w.overtypeTick != w.changedTick {
+		w.overtypes = w.overtypes[:0]
+	}
+	o := overtype{offset: w.cursor}
+	if w.extending && w.cursor == w.length-1 || w.length == 0 {
+		o.appended = true
+	} else if _, bytes, err := w.readBytes(w.cursor, 1); err == nil {
+		o.original = bytes[0]
+	}
+	w.overtypes = append(w.overtypes, o)
+}
+
+// canRestoreOvertype reports whether the overwritten bytes can be restored.
+func (w *window) canRestoreOvertype() bool {
+	return len(w.overtypes) > 0 && w.overtypeTick == w.changedTick
+}
+
+// restoreOvertype restores the last byte overwritten in replace mode, and
+// moves the cursor to the byte.
+func (w *window) restoreOvertype() bool {
+	if !w.canRestoreOvertype() {
+		return false
+	}
+	o := w.overtypes[len(w.overtypes)-1]
+	w.overtypes = w.overtypes[:len(w.overtypes)-1]
+	if o.appended {
+		w.delete(o.offset)
+		w.length--
+	} else {
+		w.replace(o.offset, o.original)
+	}
+	w.cursor = o.offset
+	w.overtypeTick = w.changedTick
+	return true
+}
+
+// backspaceReplace moves the cursor back in replace mode, restoring the byte
+// overwritten before the cursor like the backspace in replace mode of Vim.
+// The byte which is not overwritten is kept as it is.
+func (w *window) backspaceReplace() {
+	if n := len(w.overtypes); n > 0 && w.overtypes[n-1].offset == w.cursor-1 {
+		if w.restoreOvertype() {
+			return
+		}
+	}
+	if w.cursor > 0 {
+		w.cursor--
+	}
+}
+
+// undoPartial restores the bytes overwritten in the last replace mode, from
+// the last one by the count.
+func (w *window) undoPartial(count int64) {
+	for i := int64(0); i < count && w.restoreOvertype(); i++ {
+	}
+	if w.cursor >= w.length {
+		w.cursor = w.length - 1
+	}
+	if w.cursor < 0 {
+		w.cursor = 0
+	}
+	if w.cursor < w.offset {
+		w.offset = w.cursor / w.width * w.width
+	}
+}
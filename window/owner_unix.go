@@ -0,0 +1,21 @@
+// +build !windows
+
+package window
+
+import (
+	"os"
+	"syscall"
+)
+
+// copyOwner changes the owner of the file to the one of the original file.
+// The error is ignored when the user is not allowed to change the owner.
+func copyOwner(name string, info os.FileInfo) error {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil
+	}
+	if err := os.Chown(name, int(st.Uid), int(st.Gid)); err != nil && !os.IsPermission(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,388 @@
+package window
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/itchyny/bed/event"
+	"github.com/itchyny/bed/mathutil"
+	"github.com/itchyny/bed/state"
+)
+
+// pcapFile is the pcap or pcapng file in the buffer.
+type pcapFile struct {
+	w        *window
+	ng       bool
+	order    binary.ByteOrder
+	nano     bool
+	linkType uint32
+}
+
+// pcapRecord is the record of a packet in the pcap file, or the packet block
+// in the pcapng file. The data are the captured bytes of the packet, and the
+// record ends at the end.
+type pcapRecord struct {
+	file     *pcapFile
+	offset   int64
+	data     int64
+	captured int64
+	original int64
+	end      int64
+	linkType uint32
+	time     time.Time
+}
+
+// pcapInterface is the interface description block of the pcapng file.
+type pcapInterface struct {
+	linkType uint32
+	units    uint64 // the timestamp units per second
+}
+
+// pcap reads the header of the pcap or pcapng file.
+func (w *window) pcap() (*pcapFile, error) {
+	bs, err := w.readFull(0, 24)
+	if err != nil {
+		return nil, errors.New("not a pcap file")
+	}
+	p := &pcapFile{w: w}
+	switch {
+	case binary.LittleEndian.Uint32(bs) == 0xa1b2c3d4:
+		p.order = binary.LittleEndian
+	case binary.BigEndian.Uint32(bs) == 0xa1b2c3d4:
+		p.order = binary.BigEndian
+	case binary.LittleEndian.Uint32(bs) == 0xa1b23c4d:
+		p.order, p.nano = binary.LittleEndian, true
+	case binary.BigEndian.Uint32(bs) == 0xa1b23c4d:
+		p.order, p.nano = binary.BigEndian, true
+	case binary.BigEndian.Uint32(bs) == 0x0a0d0d0a:
+		p.ng = true
+		if p.order = pcapngOrder(bs[8:]); p.order == nil {
+			return nil, errors.New("not a pcap file")
+		}
+		return p, nil
+	default:
+		return nil, errors.New("not a pcap file")
+	}
+	p.linkType = p.order.Uint32(bs[20:]) & 0x0fffffff
+	return p, nil
+}
+
+// pcapngOrder returns the byte order of the section by the byte-order magic.
+func pcapngOrder(bs []byte) binary.ByteOrder {
+	switch {
+	case binary.LittleEndian.Uint32(bs) == 0x1a2b3c4d:
+		return binary.LittleEndian
+	case binary.BigEndian.Uint32(bs) == 0x1a2b3c4d:
+		return binary.BigEndian
+	default:
+		return nil
+	}
+}
+
+// records calls the function for each record until it returns false.
+func (p *pcapFile) records(f func(pcapRecord) bool) error {
+	if p.ng {
+		return p.blocks(f)
+	}
+	for offset := int64(24); offset < p.w.length; {
+		bs, err := p.w.readFull(offset, 16)
+		if err != nil {
+			return fmt.Errorf("broken pcap record at 0x%x", offset)
+		}
+		rec := pcapRecord{
+			file:     p,
+			offset:   offset,
+			data:     offset + 16,
+			captured: int64(p.order.Uint32(bs[8:])),
+			original: int64(p.order.Uint32(bs[12:])),
+			linkType: p.linkType,
+		}
+		rec.end = rec.data + rec.captured
+		if rec.end > p.w.length {
+			return fmt.Errorf("broken pcap record at 0x%x", offset)
+		}
+		frac := int64(p.order.Uint32(bs[4:]))
+		if !p.nano {
+			frac *= 1000
+		}
+		rec.time = time.Unix(int64(p.order.Uint32(bs)), frac).UTC()
+		if !f(rec) {
+			return nil
+		}
+		offset = rec.end
+	}
+	return nil
+}
+
+// blocks calls the function for each packet block of the pcapng file until
+// it returns false.
+func (p *pcapFile) blocks(f func(pcapRecord) bool) error {
+	order := p.order
+	var interfaces []pcapInterface
+	for offset := int64(0); offset < p.w.length; {
+		bs, err := p.w.readFull(offset, 12)
+		if err != nil {
+			return fmt.Errorf("broken pcapng block at 0x%x", offset)
+		}
+		typ := order.Uint32(bs)
+		if typ == 0x0a0d0d0a {
+			if order = pcapngOrder(bs[8:]); order == nil {
+				return fmt.Errorf("broken pcapng block at 0x%x", offset)
+			}
+			interfaces = nil
+		}
+		length := int64(order.Uint32(bs[4:]))
+		if length < 12 || length%4 != 0 || offset+length > p.w.length {
+			return fmt.Errorf("broken pcapng block at 0x%x", offset)
+		}
+		body, err := p.w.readFull(offset+8, length-12)
+		if err != nil {
+			return err
+		}
+		rec := pcapRecord{file: p, offset: offset, end: offset + length}
+		switch typ {
+		case 1: // interface description block
+			if len(body) < 8 {
+				return fmt.Errorf("broken pcapng block at 0x%x", offset)
+			}
+			interfaces = append(interfaces, pcapInterface{
+				linkType: uint32(order.Uint16(body)),
+				units:    pcapngUnits(order, body[8:]),
+			})
+			offset = rec.end
+			continue
+		case 2, 6: // packet block, enhanced packet block
+			if len(body) < 20 {
+				return fmt.Errorf("broken pcapng block at 0x%x", offset)
+			}
+			var id int
+			if typ == 2 {
+				id = int(order.Uint16(body))
+			} else {
+				id = int(order.Uint32(body))
+			}
+			if id >= len(interfaces) {
+				return fmt.Errorf("unknown interface %d of the pcapng block at 0x%x", id, offset)
+			}
+			rec.data = offset + 28
+			rec.captured = int64(order.Uint32(body[12:]))
+			rec.original = int64(order.Uint32(body[16:]))
+			rec.linkType = interfaces[id].linkType
+			ts := uint64(order.Uint32(body[4:]))<<32 | uint64(order.Uint32(body[8:]))
+			units := interfaces[id].units
+			rec.time = time.Unix(int64(ts/units), int64(float64(ts%units)*1e9/float64(units))).UTC()
+		case 3: // simple packet block
+			if len(body) < 4 || len(interfaces) == 0 {
+				return fmt.Errorf("broken pcapng block at 0x%x", offset)
+			}
+			rec.data = offset + 12
+			rec.original = int64(order.Uint32(body))
+			rec.captured = mathutil.MinInt64(rec.original, length-16)
+			rec.linkType = interfaces[0].linkType
+		default:
+			offset = rec.end
+			continue
+		}
+		if rec.data+rec.captured > rec.end-4 {
+			return fmt.Errorf("broken pcapng block at 0x%x", offset)
+		}
+		if !f(rec) {
+			return nil
+		}
+		offset = rec.end
+	}
+	return nil
+}
+
+// pcapngUnits returns the timestamp units per second by the if_tsresol option
+// of the interface description block. The default resolution is microseconds.
+func pcapngUnits(order binary.ByteOrder, bs []byte) uint64 {
+	for len(bs) >= 4 {
+		code, length := order.Uint16(bs), int(order.Uint16(bs[2:]))
+		if code == 0 || 4+length > len(bs) {
+			break
+		}
+		if code == 9 && length >= 1 {
+			resol, units := bs[4], uint64(1)
+			for i := 0; i < int(resol&0x7f) && units < 1<<60; i++ {
+				if resol&0x80 != 0 {
+					units *= 2
+				} else {
+					units *= 10
+				}
+			}
+			return units
+		}
+		bs = bs[mathutil.MinInt(4+(length+3)/4*4, len(bs)):]
+	}
+	return 1000000
+}
+
+// recordAt returns the record containing the offset.
+func (p *pcapFile) recordAt(offset int64) (pcapRecord, bool, error) {
+	var found pcapRecord
+	var ok bool
+	err := p.records(func(rec pcapRecord) bool {
+		if rec.offset <= offset && offset < rec.end {
+			found, ok = rec, true
+		}
+		return rec.end <= offset
+	})
+	return found, ok, err
+}
+
+// network returns the offset of the network layer of the packet.
+func (rec pcapRecord) network() (int64, error) {
+	switch rec.linkType {
+	case 1: // Ethernet
+		offset := rec.data + 12
+		for {
+			bs, err := rec.file.w.readFull(offset, 2)
+			if err != nil {
+				return 0, err
+			}
+			if typ := binary.BigEndian.Uint16(bs); typ != 0x8100 && typ != 0x88a8 {
+				return offset + 2, nil
+			}
+			offset += 4
+		}
+	case 101, 228: // raw IP, raw IPv4
+		return rec.data, nil
+	case 113: // Linux cooked capture
+		return rec.data + 16, nil
+	default:
+		return 0, fmt.Errorf("unsupported link type: %d", rec.linkType)
+	}
+}
+
+// summary returns the protocol and the addresses of the IP packet.
+func (rec pcapRecord) summary() string {
+	start, err := rec.network()
+	if err != nil || start >= rec.data+rec.captured {
+		return ""
+	}
+	bs, err := rec.file.w.readFull(start, mathutil.MinInt64(40, rec.data+rec.captured-start))
+	if err != nil {
+		return ""
+	}
+	var src, dst net.IP
+	var proto byte
+	switch {
+	case len(bs) >= 20 && bs[0]>>4 == 4:
+		src, dst, proto = net.IP(bs[12:16]), net.IP(bs[16:20]), bs[9]
+	case len(bs) >= 40 && bs[0]>>4 == 6:
+		src, dst, proto = net.IP(bs[8:24]), net.IP(bs[24:40]), bs[6]
+	default:
+		return ""
+	}
+	name := fmt.Sprintf("proto %d", proto)
+	switch proto {
+	case 1:
+		name = "ICMP"
+	case 6:
+		name = "TCP"
+	case 17:
+		name = "UDP"
+	case 58:
+		name = "ICMPv6"
+	}
+	return fmt.Sprintf("%s %s > %s", name, src, dst)
+}
+
+// packets returns the packets in the pcap or pcapng file.
+func (w *window) packets() ([]quickfixItem, error) {
+	p, err := w.pcap()
+	if err != nil {
+		return nil, err
+	}
+	var items []quickfixItem
+	if err := p.records(func(rec pcapRecord) bool {
+		text := fmt.Sprintf("%s %d/%d", rec.time.Format("2006-01-02 15:04:05.000000"),
+			rec.captured, rec.original)
+		if s := rec.summary(); s != "" {
+			text += " " + s
+		}
+		items = append(items, quickfixItem{rec.offset, rec.end - rec.offset, text})
+		return true
+	}); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+func (m *Manager) listPackets(e event.Event) error {
+	if len(e.Arg) > 0 {
+		return fmt.Errorf("too many arguments for %s", e.CmdName)
+	}
+	window := m.windows[m.windowIndex]
+	window.mu.Lock()
+	items, err := window.packets()
+	window.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if len(items) == 0 {
+		return errors.New("no packets found")
+	}
+	m.setQuickfix(window, items)
+	return m.listQuickfix(event.Event{})
+}
+
+// nextPacket moves the cursor to the start of the next packet record.
+func (w *window) nextPacket(count int64) {
+	p, err := w.pcap()
+	if err != nil {
+		return
+	}
+	count = mathutil.MaxInt64(count, 1)
+	offset := int64(-1)
+	_ = p.records(func(rec pcapRecord) bool {
+		if rec.offset > w.cursor {
+			offset, count = rec.offset, count-1
+		}
+		return count > 0
+	})
+	if offset >= 0 {
+		w.cursorGotoPos(event.Absolute{Offset: offset})
+	}
+}
+
+// previousPacket moves the cursor to the start of the previous packet record,
+// or the start of the packet record at the cursor.
+func (w *window) previousPacket(count int64) {
+	p, err := w.pcap()
+	if err != nil {
+		return
+	}
+	var offsets []int64
+	_ = p.records(func(rec pcapRecord) bool {
+		if rec.offset < w.cursor {
+			offsets = append(offsets, rec.offset)
+		}
+		return rec.end < w.cursor
+	})
+	if len(offsets) > 0 {
+		i := mathutil.MaxInt64(int64(len(offsets))-mathutil.MaxInt64(count, 1), 0)
+		w.cursorGotoPos(event.Absolute{Offset: offsets[i]})
+	}
+}
+
+// packetHighlights returns the headers of the packet records in the range,
+// from the start of the record to the start of the packet data.
+func (w *window) packetHighlights(from, to int64, color string) []state.Highlight {
+	p, err := w.pcap()
+	if err != nil {
+		return nil
+	}
+	var hs []state.Highlight
+	_ = p.records(func(rec pcapRecord) bool {
+		if rec.offset < to && from < rec.data {
+			hs = append(hs, state.Highlight{From: rec.offset, To: rec.data - 1, Color: color})
+		}
+		return rec.offset < to
+	})
+	return hs
+}
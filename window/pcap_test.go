@@ -0,0 +1,164 @@
+package window
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"testing"
+
+	"github.com/itchyny/bed/state"
+)
+
+var testPackets = [][]byte{
+	{
+		0x45, 0x00, 0x00, 0x1c, 0x00, 0x01, 0x00, 0x00, 0x40, 0x11, 0x00, 0x00,
+		0x0a, 0x00, 0x00, 0x01, 0x0a, 0x00, 0x00, 0x02,
+		0x30, 0x39, 0x00, 0x35, 0x00, 0x08, 0x00, 0x00,
+	},
+	{
+		0x45, 0x00, 0x00, 0x14, 0x00, 0x02, 0x00, 0x00, 0x40, 0x06, 0x00, 0x00,
+		0x0a, 0x00, 0x00, 0x02, 0x0a, 0x00, 0x00, 0x01,
+	},
+}
+
+func testPcap() []byte {
+	var b bytes.Buffer
+	le := binary.LittleEndian
+	for _, x := range []uint32{0xa1b2c3d4, 0x00040002, 0, 0, 0xffff, 101} {
+		binary.Write(&b, le, x)
+	}
+	for i, p := range testPackets {
+		for _, x := range []uint32{1600000000 + uint32(i), 123456, uint32(len(p)), uint32(len(p))} {
+			binary.Write(&b, le, x)
+		}
+		b.Write(p)
+	}
+	return b.Bytes()
+}
+
+func testPcapng() []byte {
+	var b bytes.Buffer
+	le := binary.LittleEndian
+	block := func(typ uint32, body []byte) {
+		for len(body)%4 != 0 {
+			body = append(body, 0)
+		}
+		binary.Write(&b, le, typ)
+		binary.Write(&b, le, uint32(len(body)+12))
+		b.Write(body)
+		binary.Write(&b, le, uint32(len(body)+12))
+	}
+	block(0x0a0d0d0a, []byte{0x4d, 0x3c, 0x2b, 0x1a, 1, 0, 0, 0, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff})
+	// raw IP with if_tsresol of nanoseconds
+	block(1, []byte{101, 0, 0, 0, 0, 0, 0, 0, 9, 0, 1, 0, 9, 0, 0, 0, 0, 0, 0, 0})
+	for i, p := range testPackets {
+		ts := uint64(1600000000+i)*1000000000 + 123456789
+		var body bytes.Buffer
+		for _, x := range []uint32{0, uint32(ts >> 32), uint32(ts), uint32(len(p)), uint32(len(p) + 4)} {
+			binary.Write(&body, le, x)
+		}
+		body.Write(p)
+		block(6, body.Bytes())
+	}
+	return b.Bytes()
+}
+
+func TestWindowPackets(t *testing.T) {
+	testCases := []struct {
+		name     string
+		bytes    []byte
+		header   int64
+		expected []quickfixItem
+	}{
+		{
+			name:   "pcap",
+			bytes:  testPcap(),
+			header: 16,
+			expected: []quickfixItem{
+				{24, 44, "2020-09-13 12:26:40.123456 28/28 UDP 10.0.0.1 > 10.0.0.2"},
+				{68, 36, "2020-09-13 12:26:41.123456 20/20 TCP 10.0.0.2 > 10.0.0.1"},
+			},
+		},
+		{
+			name:   "pcapng",
+			bytes:  testPcapng(),
+			header: 28,
+			expected: []quickfixItem{
+				{60, 60, "2020-09-13 12:26:40.123456 28/32 UDP 10.0.0.1 > 10.0.0.2"},
+				{120, 52, "2020-09-13 12:26:41.123456 20/24 TCP 10.0.0.2 > 10.0.0.1"},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			window, err := newWindow(bytes.NewReader(tc.bytes), "test", "test", make(chan struct{}))
+			if err != nil {
+				t.Fatal(err)
+			}
+			window.setSize(16, 10)
+			items, err := window.packets()
+			if err != nil {
+				t.Fatalf("err should be nil but got: %v", err)
+			}
+			if !reflect.DeepEqual(items, tc.expected) {
+				t.Errorf("packets should be %v but got %v", tc.expected, items)
+			}
+
+			window.nextPacket(1)
+			if window.cursor != tc.expected[0].offset {
+				t.Errorf("cursor should be %d but got %d", tc.expected[0].offset, window.cursor)
+			}
+			window.nextPacket(1)
+			if window.cursor != tc.expected[1].offset {
+				t.Errorf("cursor should be %d but got %d", tc.expected[1].offset, window.cursor)
+			}
+			window.nextPacket(1)
+			if window.cursor != tc.expected[1].offset {
+				t.Errorf("cursor should be %d but got %d", tc.expected[1].offset, window.cursor)
+			}
+			window.cursor += 5
+			window.previousPacket(1)
+			if window.cursor != tc.expected[1].offset {
+				t.Errorf("cursor should be %d but got %d", tc.expected[1].offset, window.cursor)
+			}
+			window.previousPacket(1)
+			if window.cursor != tc.expected[0].offset {
+				t.Errorf("cursor should be %d but got %d", tc.expected[0].offset, window.cursor)
+			}
+
+			s, err := window.state()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var expected []state.Highlight
+			for _, item := range tc.expected {
+				expected = append(expected, state.Highlight{
+					From: item.offset, To: item.offset + tc.header - 1, Color: "teal",
+				})
+			}
+			if !reflect.DeepEqual(s.Highlights, expected) {
+				t.Errorf("highlights should be %v but got %v", expected, s.Highlights)
+			}
+		})
+	}
+}
+
+func TestWindowPacketsError(t *testing.T) {
+	window, err := newWindow(bytes.NewReader(make([]byte, 64)), "test", "test", make(chan struct{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	window.setSize(16, 10)
+	if _, err := window.packets(); err == nil || err.Error() != "not a pcap file" {
+		t.Errorf("err should be %q but got: %v", "not a pcap file", err)
+	}
+	bs := testPcap()
+	window, err = newWindow(bytes.NewReader(bs[:len(bs)-1]), "test", "test", make(chan struct{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	window.setSize(16, 10)
+	if _, err := window.packets(); err == nil || err.Error() != "broken pcap record at 0x44" {
+		t.Errorf("err should be %q but got: %v", "broken pcap record at 0x44", err)
+	}
+}
@@ -0,0 +1,145 @@
+package window
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/itchyny/bed/event"
+	"github.com/itchyny/bed/mathutil"
+	"github.com/itchyny/bed/schema"
+)
+
+const (
+	plotWidth   = 64               // the number of the characters in a row
+	plotHeight  = 8                // the number of the rows
+	plotDefault = 4096             // the bytes plotted from the cursor without range
+	plotMaxSize = 16 * 1024 * 1024 // the maximum bytes of the range
+)
+
+// plot renders the values of the bytes in the range, interpreted as the
+// samples of the type (i16 by default), as a chart of braille characters.
+func (m *Manager) plot(e event.Event) error {
+	args := strings.Fields(e.Arg)
+	if len(args) > 1 {
+		return fmt.Errorf("too many arguments for %s", e.CmdName)
+	}
+	name := "i16"
+	if len(args) > 0 {
+		name = args[0]
+	}
+	t, err := schema.ParseType(name)
+	if err != nil {
+		return err
+	}
+	if t.Kind == 'c' {
+		return fmt.Errorf("cannot plot the characters: %s", name)
+	}
+	window := m.windows[m.windowIndex]
+	waitWindow(window)
+	window.mu.Lock()
+	bs, err := window.plotBytes(e.Range)
+	window.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	values := make([]float64, 0, len(bs)/t.Size)
+	for i := 0; i+t.Size <= len(bs); i += t.Size {
+		values = append(values, t.Float(bs[i:i+t.Size]))
+	}
+	lines, err := plotValues(values, name)
+	if err != nil {
+		return err
+	}
+	m.eventCh <- event.Event{Type: event.Info, Error: errors.New(strings.Join(lines, "\n"))}
+	return nil
+}
+
+// plotBytes reads the bytes of the range, or the bytes from the cursor.
+func (w *window) plotBytes(r *event.Range) ([]byte, error) {
+	from, to := w.cursor, w.cursor+plotDefault-1
+	if r != nil {
+		var err error
+		if from, err = w.positionToOffset(r.From); err != nil {
+			return nil, err
+		}
+		to = from
+		if r.To != nil {
+			if to, err = w.positionToOffset(r.To); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if from > to {
+		from, to = to, from
+	}
+	if to = mathutil.MinInt64(to, w.length-1); from > to {
+		return nil, errors.New("no bytes to plot")
+	}
+	if to-from+1 > plotMaxSize {
+		return nil, fmt.Errorf("too large range to plot: %d bytes", to-from+1)
+	}
+	return w.readFull(from, to-from+1)
+}
+
+// plotValues renders the values with the minimum and the maximum. Each
+// character has 2x4 dots, and each column of the dots shows the range of the
+// values of the samples in the column.
+func plotValues(values []float64, name string) ([]string, error) {
+	min, max, count := math.Inf(1), math.Inf(-1), 0
+	for _, v := range values {
+		if !math.IsNaN(v) && !math.IsInf(v, 0) {
+			min, max, count = math.Min(min, v), math.Max(max, v), count+1
+		}
+	}
+	if count == 0 {
+		return nil, errors.New("no values to plot")
+	}
+	columns := mathutil.MinInt(len(values), 2*plotWidth)
+	dots := make([][plotWidth]byte, plotHeight)
+	for x := 0; x < columns; x++ {
+		lo, hi := math.Inf(1), math.Inf(-1)
+		for _, v := range values[x*len(values)/columns : (x+1)*len(values)/columns] {
+			if !math.IsNaN(v) && !math.IsInf(v, 0) {
+				lo, hi = math.Min(lo, v), math.Max(hi, v)
+			}
+		}
+		if lo > hi {
+			continue
+		}
+		for y := plotDot(hi, min, max); y <= plotDot(lo, min, max); y++ {
+			dots[y/4][x/2] |= brailleDots[x%2][y%4]
+		}
+	}
+	lines := make([]string, 0, plotHeight+1)
+	lines = append(lines, fmt.Sprintf("%d samples of %s: min %g, max %g", len(values), name, min, max))
+	for i, row := range dots {
+		var sb strings.Builder
+		switch i {
+		case 0:
+			sb.WriteString(fmt.Sprintf("%12.6g ", max))
+		case plotHeight - 1:
+			sb.WriteString(fmt.Sprintf("%12.6g ", min))
+		default:
+			sb.WriteString(strings.Repeat(" ", 13))
+		}
+		for _, b := range row[:(columns+1)/2] {
+			sb.WriteRune(rune(0x2800 + int(b)))
+		}
+		lines = append(lines, sb.String())
+	}
+	return lines, nil
+}
+
+// brailleDots is the bits of the dots of the braille characters by the
+// column and the row.
+var brailleDots = [2][4]byte{{0x01, 0x02, 0x04, 0x40}, {0x08, 0x10, 0x20, 0x80}}
+
+// plotDot returns the row of the dots of the value, from the top.
+func plotDot(v, min, max float64) int {
+	if max == min {
+		return 2 * plotHeight
+	}
+	return int(math.Round((max - v) / (max - min) * float64(4*plotHeight-1)))
+}
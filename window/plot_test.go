@@ -0,0 +1,83 @@
+package window
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/itchyny/bed/event"
+)
+
+func TestPlotValues(t *testing.T) {
+	lines, err := plotValues([]float64{0, 1, 2, 3}, "i16")
+	if err != nil {
+		t.Fatalf("err should be nil but got: %v", err)
+	}
+	blank := strings.Repeat(" ", 13)
+	expected := []string{
+		"4 samples of i16: min 0, max 3",
+		"           3 ⠀⠈",
+		blank + "⠀⠀",
+		blank + "⠀⠄",
+		blank + "⠀⠀",
+		blank + "⠀⠀",
+		blank + "⠐⠀",
+		blank + "⠀⠀",
+		"           0 ⡀⠀",
+	}
+	if !reflect.DeepEqual(lines, expected) {
+		t.Errorf("lines should be %q but got %q", expected, lines)
+	}
+
+	values := make([]float64, 1000)
+	for i := range values {
+		values[i] = math.Sin(float64(i) / 50)
+	}
+	values[10] = math.NaN()
+	if lines, err = plotValues(values, "f32"); err != nil {
+		t.Fatalf("err should be nil but got: %v", err)
+	}
+	if len(lines) != plotHeight+1 {
+		t.Errorf("lines should have %d lines but got %d", plotHeight+1, len(lines))
+	}
+	for _, line := range lines[1:] {
+		if n := len([]rune(line)); n != 13+plotWidth {
+			t.Errorf("line should have %d characters but got %d: %q", 13+plotWidth, n, line)
+		}
+	}
+
+	if _, err = plotValues([]float64{math.NaN(), math.Inf(1)}, "f64"); err == nil || err.Error() != "no values to plot" {
+		t.Errorf("err should be %q but got: %v", "no values to plot", err)
+	}
+}
+
+func TestWindowPlotBytes(t *testing.T) {
+	window, err := newWindow(strings.NewReader(strings.Repeat("\x01\x00\xff\xff", 16)), "test", "test", make(chan struct{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	window.setSize(16, 10)
+	window.cursor = 60
+	bs, err := window.plotBytes(nil)
+	if err != nil {
+		t.Fatalf("err should be nil but got: %v", err)
+	}
+	if expected := "\x01\x00\xff\xff"; string(bs) != expected {
+		t.Errorf("bytes should be %q but got %q", expected, bs)
+	}
+	bs, err = window.plotBytes(&event.Range{From: event.Absolute{Offset: 8}, To: event.Absolute{Offset: 3}})
+	if err != nil {
+		t.Fatalf("err should be nil but got: %v", err)
+	}
+	if expected := "\xff\x01\x00\xff\xff\x01"; string(bs) != expected {
+		t.Errorf("bytes should be %q but got %q", expected, bs)
+	}
+	window, err = newWindow(strings.NewReader(""), "test", "test", make(chan struct{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = window.plotBytes(nil); err == nil || err.Error() != "no bytes to plot" {
+		t.Errorf("err should be %q but got: %v", "no bytes to plot", err)
+	}
+}
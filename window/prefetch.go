@@ -0,0 +1,24 @@
+package window
+
+import "github.com/itchyny/bed/mathutil"
+
+// prefetchScreens is the number of the screens read ahead of scrolling.
+const prefetchScreens = 4
+
+// prefetchAhead reads the next screens in the scrolling direction into the
+// cache of the reader in the background, so that continuous scrolling does
+// not wait for the remote or slow disk. The offsets of the window are used
+// as the offsets of the reader; they differ after inserting or deleting the
+// bytes, but prefetching is just a hint.
+func (w *window) prefetchAhead(size int64) {
+	if w.prefetcher == nil {
+		return
+	}
+	switch {
+	case w.offset > w.lastOffset:
+		w.prefetcher.prefetch(w.offset+size, w.offset+size*(prefetchScreens+1))
+	case w.offset < w.lastOffset && w.offset > 0:
+		w.prefetcher.prefetch(mathutil.MaxInt64(w.offset-size*prefetchScreens, 0), w.offset)
+	}
+	w.lastOffset = w.offset
+}
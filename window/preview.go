@@ -0,0 +1,139 @@
+package window
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/itchyny/bed/event"
+	"github.com/itchyny/bed/mathutil"
+	"github.com/itchyny/bed/state"
+)
+
+const (
+	previewWidth  = 64   // the maximum width of the previewed image
+	previewHeight = 32   // the maximum height of the previewed image
+	previewMax    = 2048 // the maximum width and height of the image
+)
+
+// pixelFormats is the bytes per pixel of the formats of the image.
+var pixelFormats = map[string]int{"rgb565": 2, "rgb888": 3, "gray": 1}
+
+// preview renders the bytes of the range, or the bytes from the cursor, as an
+// image of the size and the format (rgb888 by default), scaled down to fit in
+// the message lines.
+func (m *Manager) preview(e event.Event) error {
+	args := strings.Fields(e.Arg)
+	if len(args) == 0 || args[0] != "image" {
+		return fmt.Errorf("%s requires the kind of the preview: image", e.CmdName)
+	}
+	if len(args) < 2 {
+		return fmt.Errorf("%s image requires the size: WIDTHxHEIGHT", e.CmdName)
+	}
+	if len(args) > 3 {
+		return fmt.Errorf("too many arguments for %s", e.CmdName)
+	}
+	width, height, err := parseImageSize(args[1])
+	if err != nil {
+		return err
+	}
+	format := "rgb888"
+	if len(args) > 2 {
+		if !strings.HasPrefix(args[2], "format=") {
+			return fmt.Errorf("invalid argument for %s: %s", e.CmdName, args[2])
+		}
+		format = strings.TrimPrefix(args[2], "format=")
+	}
+	if _, ok := pixelFormats[format]; !ok {
+		return fmt.Errorf("unknown pixel format: %s", format)
+	}
+	window := m.windows[m.windowIndex]
+	waitWindow(window)
+	window.mu.Lock()
+	img, err := window.previewImage(e.Range, width, height, format)
+	window.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	m.eventCh <- event.Event{Type: event.Info, Error: img}
+	return nil
+}
+
+// parseImageSize parses the size of the image like 320x240.
+func parseImageSize(s string) (int, int, error) {
+	i := strings.IndexByte(s, 'x')
+	if i < 0 {
+		return 0, 0, fmt.Errorf("invalid image size: %s", s)
+	}
+	width, err := strconv.Atoi(s[:i])
+	if err != nil || width <= 0 || width > previewMax {
+		return 0, 0, fmt.Errorf("invalid image size: %s", s)
+	}
+	height, err := strconv.Atoi(s[i+1:])
+	if err != nil || height <= 0 || height > previewMax {
+		return 0, 0, fmt.Errorf("invalid image size: %s", s)
+	}
+	return width, height, nil
+}
+
+// previewImage reads the pixels of the image from the start of the range, or
+// the cursor. The pixels after the end of the range or the buffer are black.
+func (w *window) previewImage(r *event.Range, width, height int, format string) (*state.Image, error) {
+	size := int64(width * height * pixelFormats[format])
+	from, to := w.cursor, w.cursor+size-1
+	if r != nil {
+		var err error
+		if from, err = w.positionToOffset(r.From); err != nil {
+			return nil, err
+		}
+		if r.To != nil {
+			if to, err = w.positionToOffset(r.To); err != nil {
+				return nil, err
+			}
+			if from > to {
+				from, to = to, from
+			}
+		} else {
+			to = from + size - 1
+		}
+	}
+	if to = mathutil.MinInt64(mathutil.MinInt64(to, from+size-1), w.length-1); from > to {
+		return nil, errors.New("no bytes to preview")
+	}
+	bs, err := w.readFull(from, to-from+1)
+	if err != nil {
+		return nil, err
+	}
+	bs = append(bs, make([]byte, size-int64(len(bs)))...)
+	scale := math.Max(math.Max(float64(width)/previewWidth, float64(height)/previewHeight), 1)
+	img := &state.Image{
+		Title: fmt.Sprintf("%dx%d %s at 0x%x", width, height, format, from),
+		Width: int(math.Ceil(float64(width) / scale)), Height: int(math.Ceil(float64(height) / scale)),
+	}
+	img.Pixels = make([]uint32, img.Width*img.Height)
+	for y := 0; y < img.Height; y++ {
+		for x := 0; x < img.Width; x++ {
+			sx := mathutil.MinInt(int(float64(x)*scale), width-1)
+			sy := mathutil.MinInt(int(float64(y)*scale), height-1)
+			img.Pixels[y*img.Width+x] = pixelColor(bs, sy*width+sx, format)
+		}
+	}
+	return img, nil
+}
+
+// pixelColor returns the color of the pixel at the index as 0xRRGGBB. The
+// pixels of rgb565 are in little endian.
+func pixelColor(bs []byte, i int, format string) uint32 {
+	switch format {
+	case "rgb565":
+		c := uint32(bs[2*i]) | uint32(bs[2*i+1])<<8
+		r, g, b := c>>11&0x1f, c>>5&0x3f, c&0x1f
+		return (r<<3|r>>2)<<16 | (g<<2|g>>4)<<8 | (b<<3 | b>>2)
+	case "rgb888":
+		return uint32(bs[3*i])<<16 | uint32(bs[3*i+1])<<8 | uint32(bs[3*i+2])
+	default:
+		return uint32(bs[i]) * 0x010101
+	}
+}
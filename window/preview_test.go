@@ -0,0 +1,64 @@
+package window
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/itchyny/bed/event"
+)
+
+func TestWindowPreviewImage(t *testing.T) {
+	window, err := newWindow(strings.NewReader("\x00\x40\x80\xff\xff\x80\x40\x00\x00\xf8\xe0\x07\x1f\x00"),
+		"test", "test", make(chan struct{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	window.setSize(16, 10)
+
+	img, err := window.previewImage(nil, 4, 2, "gray")
+	if err != nil {
+		t.Fatalf("err should be nil but got: %v", err)
+	}
+	if expected := []uint32{0, 0x404040, 0x808080, 0xffffff, 0xffffff, 0x808080, 0x404040, 0}; img.Width != 4 ||
+		img.Height != 2 || !reflect.DeepEqual(img.Pixels, expected) {
+		t.Errorf("image should be 4x2 of %x but got %dx%d of %x", expected, img.Width, img.Height, img.Pixels)
+	}
+	if expected := "4x2 gray at 0x0\n▒░░▒"; img.Error() != expected {
+		t.Errorf("image text should be %q but got %q", expected, img.Error())
+	}
+
+	img, err = window.previewImage(&event.Range{From: event.Absolute{Offset: 8}}, 4, 1, "rgb565")
+	if err != nil {
+		t.Fatalf("err should be nil but got: %v", err)
+	}
+	if expected := []uint32{0xff0000, 0x00ff00, 0x0000ff, 0}; !reflect.DeepEqual(img.Pixels, expected) {
+		t.Errorf("pixels should be %x but got %x", expected, img.Pixels)
+	}
+
+	img, err = window.previewImage(&event.Range{From: event.Absolute{Offset: 1}, To: event.Absolute{Offset: 3}},
+		128, 8, "rgb888")
+	if err != nil {
+		t.Fatalf("err should be nil but got: %v", err)
+	}
+	if img.Width != 64 || img.Height != 4 {
+		t.Errorf("image should be 64x4 but got %dx%d", img.Width, img.Height)
+	}
+	if img.Pixels[0] != 0x4080ff || img.Pixels[1] != 0 {
+		t.Errorf("pixels should start with %x and %x but got %x", 0x4080ff, 0, img.Pixels[:2])
+	}
+	if expected := "128x8 rgb888 at 0x1"; !strings.HasPrefix(img.Error(), expected+"\n") {
+		t.Errorf("image text should start with %q but got %q", expected, img.Error())
+	}
+}
+
+func TestParseImageSize(t *testing.T) {
+	if width, height, err := parseImageSize("320x240"); err != nil || width != 320 || height != 240 {
+		t.Errorf("size should be 320x240 but got %dx%d (%v)", width, height, err)
+	}
+	for _, s := range []string{"320", "0x10", "10x", "x10", "4096x1"} {
+		if _, _, err := parseImageSize(s); err == nil || err.Error() != "invalid image size: "+s {
+			t.Errorf("parseImageSize(%q) should return an error but got %v", s, err)
+		}
+	}
+}
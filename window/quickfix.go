@@ -0,0 +1,126 @@
+package window
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// maxQuickfixItems is the maximum number of the items in the quickfix list.
+const maxQuickfixItems = 10000
+
+// quickfixItem points to a range of the buffer with the description.
+type quickfixItem struct {
+	offset int64
+	length int64
+	text   string
+}
+
+// quickfix is the list of the results of searching, listing the changes and
+// extracting the strings. The items are visited in the window of the list.
+type quickfix struct {
+	window *window
+	items  []quickfixItem
+	index  int
+}
+
+func (q *quickfix) format() string {
+	var b bytes.Buffer
+	for i, item := range q.items {
+		if i > 0 {
+			b.WriteByte('\n')
+		}
+		if i == q.index {
+			b.WriteString("> ")
+		} else {
+			b.WriteString("  ")
+		}
+		fmt.Fprintf(&b, "%3d 0x%08x %10d  %s", i+1, item.offset, item.length, item.text)
+	}
+	return b.String()
+}
+
+// scanBuffer reads the buffer of the window by chunks, which overlap by the
+// specified size.
+func (w *window) scanBuffer(overlap int, f func(base int64, bs []byte) bool) error {
+	return scanReader(w.buffer, overlap, nil, f)
+}
+
+// scanReader reads the reader by chunks, which overlap by the specified size.
+// It reports the progress to the task, and stops when the task is canceled.
+func scanReader(r io.ReaderAt, overlap int, t *task, f func(base int64, bs []byte) bool) error {
+	bs := make([]byte, 1<<20+overlap)
+	for base := int64(0); ; base += int64(len(bs) - overlap) {
+		if err := t.check(base); err != nil {
+			return err
+		}
+		n, err := r.ReadAt(bs, base)
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if !f(base, bs[:n]) || n < len(bs) {
+			return nil
+		}
+	}
+}
+
+// searchAll finds all the occurrences of the bytes in the reader.
+func searchAll(r io.ReaderAt, target []byte, t *task) ([]quickfixItem, error) {
+	var items []quickfixItem
+	var last int64 = -1
+	err := scanReader(r, len(target)-1, t, func(base int64, bs []byte) bool {
+		for i := 0; len(items) < maxQuickfixItems; {
+			j := bytes.Index(bs[i:], target)
+			if j < 0 {
+				break
+			}
+			if offset := base + int64(i+j); offset > last {
+				items = append(items, quickfixItem{offset, int64(len(target)), strconv.Quote(string(target))})
+				last = offset
+			}
+			i += j + 1
+		}
+		return len(items) < maxQuickfixItems
+	})
+	return items, err
+}
+
+// extractStrings finds the runs of the printable characters in the reader,
+// which are at least the specified length.
+func extractStrings(r io.ReaderAt, min int, t *task) ([]quickfixItem, error) {
+	var items []quickfixItem
+	var start int64 = -1
+	var run []byte
+	add := func(end int64) {
+		if int(end-start) >= min {
+			text := string(run)
+			if len(text) > 60 {
+				text = text[:60] + "..."
+			}
+			items = append(items, quickfixItem{start, end - start, strconv.Quote(text)})
+		}
+		start, run = -1, run[:0]
+	}
+	var length int64
+	err := scanReader(r, 0, t, func(base int64, bs []byte) bool {
+		length = base + int64(len(bs))
+		for i, b := range bs {
+			if isPrintable(b) {
+				if start < 0 {
+					start = base + int64(i)
+				}
+				if len(run) <= 60 {
+					run = append(run, b)
+				}
+			} else if start >= 0 {
+				add(base + int64(i))
+			}
+		}
+		return len(items) < maxQuickfixItems
+	})
+	if start >= 0 && len(items) < maxQuickfixItems {
+		add(length)
+	}
+	return items, err
+}
@@ -0,0 +1,118 @@
+package window
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/itchyny/bed/event"
+)
+
+// modifiedWindows returns the windows of the modified buffers, one window for
+// each buffer shared by the views.
+func (m *Manager) modifiedWindows() []*window {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var windows []*window
+	seen := make(map[*content]bool)
+	for _, window := range m.windows {
+		if !seen[window.content] && window.isModified() {
+			seen[window.content] = true
+			windows = append(windows, window)
+		}
+	}
+	return windows
+}
+
+// quitAll quits the editor, asking whether to write each modified buffer.
+func (m *Manager) quitAll(e event.Event) error {
+	if len(e.Arg) > 0 {
+		return fmt.Errorf("too many arguments for %s", e.CmdName)
+	}
+	windows := m.modifiedWindows()
+	m.mu.Lock()
+	m.quitting = windows
+	m.mu.Unlock()
+	return m.promptQuit()
+}
+
+// promptQuit asks whether to write the next modified buffer, or quits the
+// editor when all the buffers are written or discarded.
+func (m *Manager) promptQuit() error {
+	m.mu.Lock()
+	if len(m.quitting) == 0 {
+		m.quitting = nil
+		m.mu.Unlock()
+		m.eventCh <- event.Event{Type: event.QuitAll, Bang: true}
+		return nil
+	}
+	window := m.quitting[0]
+	m.mu.Unlock()
+	name := window.name
+	if name == "" {
+		name = "[No name]"
+	}
+	m.eventCh <- event.Event{Type: event.StartConfirm,
+		Error: fmt.Errorf("write the changes of %s? (y/n/a/q)", name)}
+	return nil
+}
+
+// confirmQuit writes or discards the modified buffer by the key; y (write),
+// n (discard), a (write all the rest) and q (cancel quitting).
+func (m *Manager) confirmQuit(e event.Event) error {
+	m.mu.Lock()
+	windows := m.quitting
+	m.mu.Unlock()
+	switch e.Rune {
+	case 'y', 'a':
+		if e.Rune == 'y' {
+			windows = windows[:1]
+		}
+		for _, window := range windows {
+			if _, _, err := m.writeWindow(window, nil, ""); err != nil {
+				m.mu.Lock()
+				m.quitting = nil
+				m.mu.Unlock()
+				return err
+			}
+		}
+		m.mu.Lock()
+		m.quitting = m.quitting[len(windows):]
+		m.mu.Unlock()
+	case 'n':
+		m.mu.Lock()
+		m.quitting = m.quitting[1:]
+		m.mu.Unlock()
+	case 'q', 0:
+		m.mu.Lock()
+		m.quitting = nil
+		m.mu.Unlock()
+		m.eventCh <- event.Event{Type: event.ExitConfirm, Error: errors.New("quit canceled")}
+		return nil
+	}
+	return m.promptQuit()
+}
+
+// writeQuitAll writes all the modified buffers and quits the editor.
+func (m *Manager) writeQuitAll(e event.Event) error {
+	if len(e.Arg) > 0 {
+		return fmt.Errorf("too many arguments for %s", e.CmdName)
+	}
+	if e.Range != nil {
+		return fmt.Errorf("range not allowed for %s", e.CmdName)
+	}
+	windows := m.modifiedWindows()
+	if !e.Bang {
+		for _, window := range windows {
+			if window.options.Bool("readonly") {
+				return fmt.Errorf("'readonly' option is set for %s (add ! to override)", window.name)
+			}
+		}
+	}
+	for _, window := range windows {
+		if _, _, err := m.writeWindow(window, nil, ""); err != nil {
+			return err
+		}
+	}
+	m.eventCh <- event.Event{Type: event.QuitAll, Bang: true}
+	return nil
+}
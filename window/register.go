@@ -0,0 +1,277 @@
+package window
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/itchyny/bed/event"
+	"github.com/itchyny/bed/mathutil"
+)
+
+// systemClipboard is the system clipboard, which is shared by the "+ and "*
+// registers.
+type systemClipboard interface {
+	Copy([]byte) error
+	Paste() ([]byte, error)
+}
+
+func isClipboardRegister(r rune) bool {
+	return r == '+' || r == '*'
+}
+
+// encodeClipboard encodes the bytes in the representation of the clipformat
+// option; raw bytes, hex digits or an escaped string.
+func encodeClipboard(bs []byte, format string) ([]byte, error) {
+	switch format {
+	case "raw":
+		return bs, nil
+	case "hex":
+		return []byte(hex.EncodeToString(bs)), nil
+	case "escaped":
+		s := strconv.Quote(string(bs))
+		return []byte(s[1 : len(s)-1]), nil
+	default:
+		return nil, fmt.Errorf("invalid clipboard format: %s", format)
+	}
+}
+
+// decodeClipboard decodes the contents of the clipboard encoded by
+// encodeClipboard. The hex digits can be separated by spaces and have the 0x
+// prefix.
+func decodeClipboard(bs []byte, format string) ([]byte, error) {
+	switch format {
+	case "raw":
+		return bs, nil
+	case "hex":
+		s := strings.Map(func(r rune) rune {
+			if unicode.IsSpace(r) {
+				return -1
+			}
+			return r
+		}, string(bs))
+		if strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0X") {
+			s = s[2:]
+		}
+		bs, err := hex.DecodeString(s)
+		if err != nil {
+			return nil, errors.New("clipboard is not hex digits")
+		}
+		return bs, nil
+	case "escaped":
+		s, err := strconv.Unquote(`"` + strings.TrimRight(string(bs), "\r\n") + `"`)
+		if err != nil {
+			return nil, errors.New("clipboard is not an escaped string")
+		}
+		return []byte(s), nil
+	default:
+		return nil, fmt.Errorf("invalid clipboard format: %s", format)
+	}
+}
+
+// yank copies the bytes of the current window to the register; the unnamed
+// register, or the clipboard by "+ or "*.
+func (m *Manager) yank(e event.Event) error {
+	window := m.windows[m.windowIndex]
+	waitWindow(window)
+	bs, err := window.yank(e.Count)
+	if err != nil {
+		return err
+	}
+	if isClipboardRegister(e.Rune) {
+		cs, err := encodeClipboard(bs, m.options.String("clipformat"))
+		if err != nil {
+			return err
+		}
+		if err := m.clipboard.Copy(cs); err != nil {
+			return err
+		}
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.register = bs
+	return nil
+}
+
+// waitWindow waits for the window to handle the preceding events, since the
+// window receives the next event after handling the previous one.
+func waitWindow(window *window) {
+	window.eventCh <- event.Event{Type: event.Nop}
+}
+
+// copyValue copies the text of the cursor offset in hex (offset) or decimal
+// (decimal), the byte at the cursor (byte), or the bytes of the range as a
+// hex string (hex) to the unnamed register, or the clipboard by + or *.
+func (m *Manager) copyValue(e event.Event) error {
+	args := strings.Fields(e.Arg)
+	if len(args) == 0 {
+		return fmt.Errorf("an argument is required for %s", e.CmdName)
+	}
+	if len(args) > 2 {
+		return fmt.Errorf("too many arguments for %s", e.CmdName)
+	}
+	if len(args) > 1 && (len(args[1]) != 1 || !isClipboardRegister(rune(args[1][0]))) {
+		return fmt.Errorf("invalid register: %s", args[1])
+	}
+	window := m.windows[m.windowIndex]
+	waitWindow(window)
+	text, err := window.copyValue(args[0], e.Range)
+	if err != nil {
+		return err
+	}
+	if len(args) > 1 {
+		if err := m.clipboard.Copy([]byte(text)); err != nil {
+			return err
+		}
+	}
+	m.mu.Lock()
+	m.register = []byte(text)
+	m.mu.Unlock()
+	msg := "copied " + text
+	if args[0] == "hex" {
+		msg = fmt.Sprintf("copied %d bytes in hex", len(text)/2)
+	}
+	m.eventCh <- event.Event{Type: event.Info, Error: errors.New(msg)}
+	return nil
+}
+
+// paste inserts the bytes of the register to the current window.
+func (m *Manager) paste(e event.Event) error {
+	var bs []byte
+	if isClipboardRegister(e.Rune) {
+		cs, err := m.clipboard.Paste()
+		if err != nil {
+			return err
+		}
+		if bs, err = decodeClipboard(cs, m.options.String("clipformat")); err != nil {
+			return err
+		}
+	} else {
+		m.mu.Lock()
+		bs = m.register
+		m.mu.Unlock()
+	}
+	if len(bs) == 0 {
+		return errors.New("nothing to paste")
+	}
+	e.Bytes = bs
+	count, err := pasteLength(e)
+	if err != nil {
+		return err
+	}
+	return m.insertLarge(m.windows[m.windowIndex], e, count, bs)
+}
+
+// pasteLength returns the number of the bytes to paste for the count.
+func pasteLength(e event.Event) (int64, error) {
+	count := mathutil.MaxInt64(e.Count, 1)
+	if count > math.MaxInt64/int64(len(e.Bytes)) {
+		return 0, fmt.Errorf("too many bytes to paste: %d times of %d bytes", count, len(e.Bytes))
+	}
+	return int64(len(e.Bytes)) * count, nil
+}
+
+// yank returns the bytes of the visual selection and exits the visual mode,
+// or the bytes from the cursor of the count. The bytes of the rows of the
+// visual block selection are concatenated.
+func (w *window) yank(count int64) ([]byte, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	from, to := w.cursor, w.cursor+mathutil.MaxInt64(count, 1)-1
+	if w.visualStart >= 0 && w.visualBlock {
+		ranges := w.visualRanges()
+		if w.visualStart = -1; len(ranges) == 0 {
+			return nil, errors.New("nothing to yank")
+		}
+		w.cursorGotoPos(event.Absolute{Offset: ranges[0][0]})
+		var bs []byte
+		for _, r := range ranges {
+			n, b, err := w.readBytes(r[0], int(r[1]-r[0]+1))
+			if err != nil {
+				return nil, err
+			}
+			bs = append(bs, b[:n]...)
+		}
+		return bs, nil
+	}
+	if w.visualStart >= 0 {
+		from, to = mathutil.MinInt64(w.cursor, w.visualStart), mathutil.MaxInt64(w.cursor, w.visualStart)
+		w.visualStart = -1
+		w.cursorGotoPos(event.Absolute{Offset: from})
+	}
+	if to = mathutil.MinInt64(to, w.length-1); from > to {
+		return nil, errors.New("nothing to yank")
+	}
+	n, bs, err := w.readBytes(from, int(to-from+1))
+	if err != nil {
+		return nil, err
+	}
+	return bs[:n], nil
+}
+
+// paste inserts the bytes of the count times after the cursor, or before the
+// cursor on PasteBefore. The cursor moves to the last byte of the insertion.
+// The large insertion is spooled by the Manager beforehand.
+func (w *window) paste(e event.Event) {
+	count, err := pasteLength(e)
+	if err != nil {
+		return
+	}
+	if spool, err := w.checkInsert(count); spool || err != nil {
+		return
+	}
+	if e.Type == event.Paste && w.cursor < w.length {
+		w.cursor++
+	}
+	w.insertBytes(count, e.Bytes)
+	w.cursorGotoPos(event.Absolute{Offset: w.cursor + count - 1})
+}
+
+// copyValue returns the text of the value to copy.
+func (w *window) copyValue(kind string, r *event.Range) (string, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	from, to := w.cursor, w.cursor
+	if r != nil {
+		var err error
+		if from, err = w.positionToOffset(r.From); err != nil {
+			return "", err
+		}
+		to = from
+		if r.To != nil {
+			if to, err = w.positionToOffset(r.To); err != nil {
+				return "", err
+			}
+			if from > to {
+				from, to = to, from
+			}
+		}
+	}
+	switch kind {
+	case "offset":
+		return fmt.Sprintf("0x%x", from+w.options.Int64("baseaddress")), nil
+	case "decimal":
+		return strconv.FormatInt(from+w.options.Int64("baseaddress"), 10), nil
+	case "byte", "hex":
+		if kind == "byte" {
+			to = from
+		}
+		if to = mathutil.MinInt64(to, w.length-1); from > to {
+			return "", errors.New("no bytes to copy")
+		}
+		n, bs, err := w.readBytes(from, int(to-from+1))
+		if err != nil {
+			return "", err
+		}
+		if kind == "byte" {
+			return fmt.Sprintf("0x%02x", bs[0]), nil
+		}
+		return hex.EncodeToString(bs[:n]), nil
+	default:
+		return "", fmt.Errorf("unknown value to copy: %s", kind)
+	}
+}
@@ -0,0 +1,188 @@
+package window
+
+import (
+	"testing"
+
+	"github.com/itchyny/bed/event"
+	"github.com/itchyny/bed/mode"
+)
+
+type mockClipboard struct {
+	bs []byte
+}
+
+func (c *mockClipboard) Copy(bs []byte) error {
+	c.bs = bs
+	return nil
+}
+
+func (c *mockClipboard) Paste() ([]byte, error) {
+	return c.bs, nil
+}
+
+func TestEncodeDecodeClipboard(t *testing.T) {
+	for _, tc := range []struct {
+		format   string
+		bytes    string
+		expected string
+	}{
+		{"raw", "\x00AB\n", "\x00AB\n"},
+		{"hex", "\x00AB\n", "0041420a"},
+		{"escaped", "\x00AB\n\"", `\x00AB\n\"`},
+	} {
+		got, err := encodeClipboard([]byte(tc.bytes), tc.format)
+		if err != nil {
+			t.Errorf("err should be nil but got: %v", err)
+		}
+		if string(got) != tc.expected {
+			t.Errorf("encoded bytes in %s should be %q but got %q", tc.format, tc.expected, string(got))
+		}
+		got, err = decodeClipboard(got, tc.format)
+		if err != nil {
+			t.Errorf("err should be nil but got: %v", err)
+		}
+		if string(got) != tc.bytes {
+			t.Errorf("decoded bytes in %s should be %q but got %q", tc.format, tc.bytes, string(got))
+		}
+	}
+	if got, err := decodeClipboard([]byte("0x00 41\nff\n"), "hex"); err != nil || string(got) != "\x00A\xff" {
+		t.Errorf("decoded bytes should be %q but got %q, %v", "\x00A\xff", string(got), err)
+	}
+	if _, err := decodeClipboard([]byte("xyz"), "hex"); err == nil || err.Error() != "clipboard is not hex digits" {
+		t.Errorf("err should be %q but got: %v", "clipboard is not hex digits", err)
+	}
+	if _, err := encodeClipboard(nil, "base64"); err == nil || err.Error() != "invalid clipboard format: base64" {
+		t.Errorf("err should be %q but got: %v", "invalid clipboard format: base64", err)
+	}
+}
+
+func TestManagerYankPaste(t *testing.T) {
+	wm := NewManager()
+	clipboard := &mockClipboard{}
+	wm.clipboard = clipboard
+	eventCh, redrawCh := make(chan event.Event), make(chan struct{})
+	wm.Init(eventCh, redrawCh)
+	wm.SetSize(110, 20)
+	if err := wm.Open(""); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	_, _, _, _ = wm.State()
+	go wm.Emit(event.Event{Type: event.Paste, Mode: mode.Normal})
+	if e := <-eventCh; e.Type != event.Error || e.Error.Error() != "nothing to paste" {
+		t.Errorf("paste should fail but got: %+v", e)
+	}
+	go wm.Emit(event.Event{Type: event.InsertBytes, Arg: "4 0x41424344"})
+	<-redrawCh
+	go wm.Emit(event.Event{Type: event.Yank, Count: 2, Mode: mode.Normal})
+	<-redrawCh
+	go wm.Emit(event.Event{Type: event.CursorNext, Count: 3, Mode: mode.Normal})
+	<-redrawCh
+	go wm.Emit(event.Event{Type: event.Paste, Mode: mode.Normal})
+	<-redrawCh
+	windowStates, _, windowIndex, _ := wm.State()
+	ws := windowStates[windowIndex]
+	if expected := "ABCDAB"; string(ws.Bytes[:ws.Size]) != expected {
+		t.Errorf("Bytes should be %q but got %q", expected, string(ws.Bytes[:ws.Size]))
+	}
+	if ws.Cursor != 5 {
+		t.Errorf("Cursor should be %d but got %d", 5, ws.Cursor)
+	}
+
+	if err := wm.options.Set("clipformat=hex"); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	go wm.Emit(event.Event{Type: event.StartVisual, Mode: mode.Normal})
+	<-redrawCh
+	go wm.Emit(event.Event{Type: event.CursorPrev, Count: 2, Mode: mode.Visual})
+	<-redrawCh
+	go wm.Emit(event.Event{Type: event.Yank, Rune: '+', Mode: mode.Normal})
+	<-redrawCh
+	if expected := "444142"; string(clipboard.bs) != expected {
+		t.Errorf("clipboard should be %q but got %q", expected, string(clipboard.bs))
+	}
+	clipboard.bs = []byte("0x00 ff\n")
+	go wm.Emit(event.Event{Type: event.PasteBefore, Rune: '+', Count: 2, Mode: mode.Normal})
+	<-redrawCh
+	windowStates, _, windowIndex, _ = wm.State()
+	ws = windowStates[windowIndex]
+	if expected := "ABC\x00\xff\x00\xffDAB"; string(ws.Bytes[:ws.Size]) != expected {
+		t.Errorf("Bytes should be %q but got %q", expected, string(ws.Bytes[:ws.Size]))
+	}
+	if ws.Cursor != 6 || ws.VisualStart != -1 {
+		t.Errorf("Cursor should be %d without visual selection but got %d, %d", 6, ws.Cursor, ws.VisualStart)
+	}
+
+	go wm.Emit(event.Event{Type: event.Paste, Mode: mode.Normal})
+	<-redrawCh
+	windowStates, _, windowIndex, _ = wm.State()
+	ws = windowStates[windowIndex]
+	if expected := "ABC\x00\xff\x00\xffDABDAB"; string(ws.Bytes[:ws.Size]) != expected {
+		t.Errorf("Bytes should be %q but got %q", expected, string(ws.Bytes[:ws.Size]))
+	}
+	clipboard.bs = []byte("xyz")
+	go wm.Emit(event.Event{Type: event.Paste, Rune: '+', Mode: mode.Normal})
+	if e := <-eventCh; e.Type != event.Error || e.Error.Error() != "clipboard is not hex digits" {
+		t.Errorf("paste should fail but got: %+v", e)
+	}
+	wm.Close()
+}
+
+func TestManagerCopyValue(t *testing.T) {
+	wm := NewManager()
+	clipboard := &mockClipboard{}
+	wm.clipboard = clipboard
+	eventCh, redrawCh := make(chan event.Event), make(chan struct{})
+	wm.Init(eventCh, redrawCh)
+	wm.SetSize(110, 20)
+	if err := wm.Open(""); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	_, _, _, _ = wm.State()
+	go wm.Emit(event.Event{Type: event.Copy, Arg: "byte", CmdName: "cop[y]"})
+	if e := <-eventCh; e.Type != event.Error || e.Error.Error() != "no bytes to copy" {
+		t.Errorf("copy should fail but got: %+v", e)
+	}
+	go wm.Emit(event.Event{Type: event.InsertBytes, Arg: "32 0x41424344"})
+	<-redrawCh
+	go wm.Emit(event.Event{Type: event.CursorNext, Count: 26, Mode: mode.Normal})
+	<-redrawCh
+	for _, tc := range []struct {
+		arg      string
+		rng      *event.Range
+		expected string
+		message  string
+	}{
+		{"offset", nil, "0x1a", "copied 0x1a"},
+		{"decimal", nil, "26", "copied 26"},
+		{"byte", nil, "0x43", "copied 0x43"},
+		{"hex", nil, "43", "copied 1 bytes in hex"},
+		{"hex +", &event.Range{From: event.Absolute{Offset: 3}, To: event.Absolute{Offset: 1}}, "424344", "copied 3 bytes in hex"},
+		{"offset *", &event.Range{From: event.Absolute{Offset: 0x10}}, "0x10", "copied 0x10"},
+	} {
+		go wm.Emit(event.Event{Type: event.Copy, Arg: tc.arg, Range: tc.rng, CmdName: "cop[y]"})
+		if e := <-eventCh; e.Type != event.Info || e.Error.Error() != tc.message {
+			t.Errorf("copy %q should emit %q but got: %+v", tc.arg, tc.message, e)
+		}
+		if string(wm.register) != tc.expected {
+			t.Errorf("register should be %q but got %q", tc.expected, string(wm.register))
+		}
+	}
+	if expected := "0x10"; string(clipboard.bs) != expected {
+		t.Errorf("clipboard should be %q but got %q", expected, string(clipboard.bs))
+	}
+	for _, tc := range []struct {
+		arg      string
+		expected string
+	}{
+		{"", "an argument is required for cop[y]"},
+		{"foo", "unknown value to copy: foo"},
+		{"byte a", "invalid register: a"},
+		{"byte + x", "too many arguments for cop[y]"},
+	} {
+		go wm.Emit(event.Event{Type: event.Copy, Arg: tc.arg, CmdName: "cop[y]"})
+		if e := <-eventCh; e.Type != event.Error || e.Error.Error() != tc.expected {
+			t.Errorf("copy %q should fail with %q but got: %+v", tc.arg, tc.expected, e)
+		}
+	}
+	wm.Close()
+}
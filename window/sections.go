@@ -0,0 +1,108 @@
+package window
+
+import (
+	"debug/elf"
+	"debug/pe"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/itchyny/bed/event"
+	"github.com/itchyny/bed/state"
+)
+
+// sections returns the sections of the ELF or PE file in the buffer, or the
+// segments of the ELF file without the section headers.
+func (w *window) sections() ([]quickfixItem, error) {
+	if f, err := elf.NewFile(w.buffer); err == nil {
+		var items []quickfixItem
+		for _, s := range f.Sections {
+			if s.Type == elf.SHT_NULL || s.Type == elf.SHT_NOBITS {
+				continue
+			}
+			items = append(items, quickfixItem{int64(s.Offset), int64(s.Size),
+				fmt.Sprintf("%s %s", s.Name, s.Type)})
+		}
+		if len(items) == 0 {
+			for _, p := range f.Progs {
+				items = append(items, quickfixItem{int64(p.Off), int64(p.Filesz),
+					fmt.Sprintf("%s %s", p.Type, p.Flags)})
+			}
+		}
+		return items, nil
+	}
+	if f, err := pe.NewFile(w.buffer); err == nil {
+		var items []quickfixItem
+		for _, s := range f.Sections {
+			if s.Size == 0 {
+				continue
+			}
+			items = append(items, quickfixItem{int64(s.Offset), int64(s.Size),
+				fmt.Sprintf("%s 0x%08x", s.Name, s.Characteristics)})
+		}
+		return items, nil
+	}
+	return nil, errors.New("not an ELF or PE file")
+}
+
+// sectionLabels returns the labels of the sections of the ELF or PE file,
+// which are cached until the buffer is changed.
+func (w *window) sectionLabels() []state.Label {
+	if w.labelsTick == w.changedTick+1 {
+		return w.labels
+	}
+	w.labels, w.labelsTick = nil, w.changedTick+1
+	items, err := w.sections()
+	if err != nil {
+		return nil
+	}
+	for _, item := range items {
+		w.labels = append(w.labels, state.Label{
+			Offset: item.offset, Text: strings.Fields(item.text)[0],
+		})
+	}
+	return w.labels
+}
+
+func (m *Manager) listSections(e event.Event) error {
+	if len(e.Arg) > 0 {
+		return fmt.Errorf("too many arguments for %s", e.CmdName)
+	}
+	window := m.windows[m.windowIndex]
+	window.mu.Lock()
+	items, err := window.sections()
+	window.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if len(items) == 0 {
+		return errors.New("no sections found")
+	}
+	m.setQuickfix(window, items)
+	return m.listQuickfix(event.Event{})
+}
+
+// gotoSection moves the cursor to the start of the section of the name.
+func (m *Manager) gotoSection(e event.Event) error {
+	if e.Arg == "" {
+		return fmt.Errorf("an argument is required for %s", e.CmdName)
+	}
+	window := m.windows[m.windowIndex]
+	window.mu.Lock()
+	items, err := window.sections()
+	window.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	for _, item := range items {
+		if strings.Fields(item.text)[0] == e.Arg {
+			window.eventCh <- event.Event{
+				Type:  event.CursorGoto,
+				Range: &event.Range{From: event.Absolute{Offset: item.offset}},
+				Mode:  e.Mode,
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("section not found: %s", e.Arg)
+}
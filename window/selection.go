@@ -0,0 +1,87 @@
+package window
+
+import (
+	"hash/crc32"
+	"io"
+
+	"github.com/itchyny/bed/mathutil"
+	"github.com/itchyny/bed/state"
+)
+
+// maxChecksumRead is the maximum number of the bytes read on drawing to
+// calculate the checksums of the visual selection.
+const maxChecksumRead = 16 * 1024 * 1024
+
+// selectionChecksum holds the checksums of the bytes from the start of the
+// visual selection, which are extended as the selection grows.
+type selectionChecksum struct {
+	from, to    int64
+	changedTick uint64
+	sum, crc32  uint32
+}
+
+// selection returns the summary of the visual selection.
+func (w *window) selection() (*state.Selection, error) {
+	if w.visualStart < 0 || w.length == 0 {
+		return nil, nil
+	}
+	if w.visualBlock {
+		return w.blockSelection()
+	}
+	from := mathutil.MinInt64(w.cursor, w.visualStart)
+	to := mathutil.MinInt64(mathutil.MaxInt64(w.cursor, w.visualStart)+1, w.length)
+	s := &state.Selection{From: from, To: to - 1}
+	c := w.checksum
+	if c == nil || c.from != from || c.to > to || c.changedTick != w.changedTick {
+		c = &selectionChecksum{from: from, to: from, changedTick: w.changedTick}
+	}
+	if to-c.to > maxChecksumRead {
+		return s, nil
+	}
+	bs := make([]byte, mathutil.MinInt64(to-c.to, 64*1024))
+	for c.to < to {
+		n, err := w.buffer.ReadAt(bs[:mathutil.MinInt64(to-c.to, int64(len(bs)))], c.to)
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		if n == 0 {
+			break
+		}
+		for _, b := range bs[:n] {
+			c.sum += uint32(b)
+		}
+		c.crc32 = crc32.Update(c.crc32, crc32.IEEETable, bs[:n])
+		c.to += int64(n)
+	}
+	w.checksum = c
+	s.Sum, s.CRC32, s.Checksummed = c.sum, c.crc32, c.to == to
+	return s, nil
+}
+
+// blockSelection returns the summary of the visual block selection. The
+// checksums are calculated on each drawing since the block is not continuous.
+func (w *window) blockSelection() (*state.Selection, error) {
+	from := mathutil.MinInt64(w.cursor, w.visualStart)
+	to := mathutil.MaxInt64(w.cursor, w.visualStart)
+	s := &state.Selection{
+		From:    from,
+		To:      to,
+		Rows:    to/w.width - from/w.width + 1,
+		Columns: mathutil.MaxInt64(w.cursor%w.width-w.visualStart%w.width, w.visualStart%w.width-w.cursor%w.width) + 1,
+	}
+	if s.Rows*s.Columns > maxChecksumRead {
+		return s, nil
+	}
+	for _, r := range w.blockRanges(from, to) {
+		n, bs, err := w.readBytes(r[0], int(r[1]-r[0]+1))
+		if err != nil {
+			return nil, err
+		}
+		for _, b := range bs[:n] {
+			s.Sum += uint32(b)
+		}
+		s.CRC32 = crc32.Update(s.CRC32, crc32.IEEETable, bs[:n])
+	}
+	s.Checksummed = true
+	return s, nil
+}
@@ -0,0 +1,398 @@
+package window
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+const (
+	// sftpBlockSize is the size of the bytes requested at once, which most
+	// servers accept in a single read request.
+	sftpBlockSize = 32 * 1024
+	// sftpCacheBlocks is the number of the blocks kept in the cache.
+	sftpCacheBlocks = 512
+)
+
+// The packet types and the flags of the SFTP protocol version 3.
+const (
+	sftpInit    = 1
+	sftpVersion = 2
+	sftpOpen    = 3
+	sftpClose   = 4
+	sftpRead    = 5
+	sftpWrite   = 6
+	sftpFstat   = 8
+	sftpStatus  = 101
+	sftpHandle  = 102
+	sftpData    = 103
+	sftpAttrs   = 105
+
+	sftpFlagRead  = 0x01
+	sftpFlagWrite = 0x02
+	sftpAttrSize  = 0x01
+	sftpStatusOK  = 0
+	sftpStatusEOF = 1
+)
+
+// isSFTP reports whether the name is a remote file like sftp://host/path or
+// sftp://user@host/~/path, which is relative to the home directory.
+func isSFTP(name string) bool {
+	return strings.HasPrefix(name, "sftp://")
+}
+
+func splitSFTP(name string) (string, string, error) {
+	host, path := strings.TrimPrefix(name, "sftp://"), "."
+	if i := strings.IndexByte(host, '/'); i >= 0 {
+		host, path = host[:i], host[i:]
+		if path == "/~" || path == "/~/" {
+			path = "."
+		} else if strings.HasPrefix(path, "/~/") {
+			path = path[3:]
+		}
+	}
+	if host == "" || strings.HasPrefix(host, "-") {
+		return "", "", fmt.Errorf("invalid host: %s", name)
+	}
+	return host, path, nil
+}
+
+// sftpClient sends the requests to the SFTP server, which is usually the sftp
+// subsystem of ssh so that the user configuration and the agent are used.
+type sftpClient struct {
+	r   io.Reader
+	w   io.Writer
+	cmd *exec.Cmd
+	id  uint32
+	mu  *sync.Mutex
+}
+
+func dialSFTP(host string) (*sftpClient, error) {
+	if strings.HasPrefix(host, "-") {
+		return nil, fmt.Errorf("invalid host: %s", host)
+	}
+	cmd := exec.Command("ssh", "-s", "--", host, "sftp")
+	w, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	r, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	c, err := newSFTPClient(r, w)
+	if err != nil {
+		w.Close()
+		_ = cmd.Wait()
+		return nil, fmt.Errorf("%s: %s", host, err)
+	}
+	c.cmd = cmd
+	return c, nil
+}
+
+func newSFTPClient(r io.Reader, w io.Writer) (*sftpClient, error) {
+	c := &sftpClient{r: r, w: w, mu: new(sync.Mutex)}
+	if err := c.send(sftpInit, appendUint32(nil, 3)); err != nil {
+		return nil, err
+	}
+	typ, _, err := c.recv()
+	if err != nil {
+		return nil, err
+	}
+	if typ != sftpVersion {
+		return nil, fmt.Errorf("unexpected sftp packet: %d", typ)
+	}
+	return c, nil
+}
+
+func (c *sftpClient) send(typ byte, payload []byte) error {
+	bs := appendUint32(make([]byte, 0, 5+len(payload)), uint32(1+len(payload)))
+	_, err := c.w.Write(append(append(bs, typ), payload...))
+	return err
+}
+
+func (c *sftpClient) recv() (byte, []byte, error) {
+	var bs [4]byte
+	if _, err := io.ReadFull(c.r, bs[:]); err != nil {
+		return 0, nil, err
+	}
+	n := binary.BigEndian.Uint32(bs[:])
+	if n == 0 || n > 1<<24 {
+		return 0, nil, errors.New("invalid sftp packet")
+	}
+	payload := make([]byte, n)
+	if _, err := io.ReadFull(c.r, payload); err != nil {
+		return 0, nil, err
+	}
+	return payload[0], payload[1:], nil
+}
+
+// request sends the request with a new id, and returns the response.
+func (c *sftpClient) request(typ byte, payload []byte) (byte, *sftpReader, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.id++
+	if err := c.send(typ, append(appendUint32(nil, c.id), payload...)); err != nil {
+		return 0, nil, err
+	}
+	typ, bs, err := c.recv()
+	if err != nil {
+		return 0, nil, err
+	}
+	r := &sftpReader{bs}
+	if id, err := r.uint32(); err != nil {
+		return 0, nil, err
+	} else if id != c.id {
+		return 0, nil, errors.New("unexpected sftp response")
+	}
+	if typ == sftpStatus {
+		return typ, r, r.status()
+	}
+	return typ, r, nil
+}
+
+func (c *sftpClient) open(path string, flags uint32) (string, error) {
+	payload := appendUint32(appendUint32(appendString(nil, path), flags), 0)
+	typ, r, err := c.request(sftpOpen, payload)
+	if err != nil {
+		return "", fmt.Errorf("%s: %s", path, err)
+	}
+	if typ != sftpHandle {
+		return "", fmt.Errorf("unexpected sftp packet: %d", typ)
+	}
+	return r.string()
+}
+
+func (c *sftpClient) fstat(handle string) (int64, error) {
+	typ, r, err := c.request(sftpFstat, appendString(nil, handle))
+	if err != nil {
+		return 0, err
+	}
+	if typ != sftpAttrs {
+		return 0, fmt.Errorf("unexpected sftp packet: %d", typ)
+	}
+	flags, err := r.uint32()
+	if err != nil {
+		return 0, err
+	}
+	if flags&sftpAttrSize == 0 {
+		return 0, errors.New("unknown size of the remote file")
+	}
+	size, err := r.uint64()
+	return int64(size), err
+}
+
+// read the bytes of the range, which may be shorter than requested.
+func (c *sftpClient) read(handle string, offset int64, n int) ([]byte, error) {
+	payload := appendUint32(appendUint64(appendString(nil, handle), uint64(offset)), uint32(n))
+	typ, r, err := c.request(sftpRead, payload)
+	if err != nil {
+		return nil, err
+	}
+	if typ != sftpData {
+		return nil, fmt.Errorf("unexpected sftp packet: %d", typ)
+	}
+	s, err := r.string()
+	return []byte(s), err
+}
+
+func (c *sftpClient) write(handle string, offset int64, p []byte) error {
+	payload := appendString(appendUint64(appendString(nil, handle), uint64(offset)), string(p))
+	_, _, err := c.request(sftpWrite, payload)
+	return err
+}
+
+func (c *sftpClient) close(handle string) error {
+	_, _, err := c.request(sftpClose, appendString(nil, handle))
+	return err
+}
+
+// Close the connection and wait for the ssh command to exit.
+func (c *sftpClient) Close() error {
+	if w, ok := c.w.(io.Closer); ok {
+		w.Close()
+	}
+	if c.cmd != nil {
+		return c.cmd.Wait()
+	}
+	return nil
+}
+
+func appendUint32(bs []byte, v uint32) []byte {
+	return append(bs, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+func appendUint64(bs []byte, v uint64) []byte {
+	return appendUint32(appendUint32(bs, uint32(v>>32)), uint32(v))
+}
+
+func appendString(bs []byte, s string) []byte {
+	return append(appendUint32(bs, uint32(len(s))), s...)
+}
+
+// sftpReader decodes the payload of the sftp packet.
+type sftpReader struct {
+	bs []byte
+}
+
+var errShortPacket = errors.New("short sftp packet")
+
+func (r *sftpReader) uint32() (uint32, error) {
+	if len(r.bs) < 4 {
+		return 0, errShortPacket
+	}
+	v := binary.BigEndian.Uint32(r.bs)
+	r.bs = r.bs[4:]
+	return v, nil
+}
+
+func (r *sftpReader) uint64() (uint64, error) {
+	if len(r.bs) < 8 {
+		return 0, errShortPacket
+	}
+	v := binary.BigEndian.Uint64(r.bs)
+	r.bs = r.bs[8:]
+	return v, nil
+}
+
+func (r *sftpReader) string() (string, error) {
+	n, err := r.uint32()
+	if err != nil {
+		return "", err
+	}
+	if uint32(len(r.bs)) < n {
+		return "", errShortPacket
+	}
+	s := string(r.bs[:n])
+	r.bs = r.bs[n:]
+	return s, nil
+}
+
+// status returns the error of the status response.
+func (r *sftpReader) status() error {
+	code, err := r.uint32()
+	if err != nil {
+		return err
+	}
+	switch code {
+	case sftpStatusOK:
+		return nil
+	case sftpStatusEOF:
+		return io.EOF
+	}
+	if msg, err := r.string(); err == nil && msg != "" {
+		return errors.New(msg)
+	}
+	return fmt.Errorf("sftp error code %d", code)
+}
+
+// sftpFile reads the remote file by the blocks, and writes the changes to the
+// file in place.
+type sftpFile struct {
+	name   string
+	path   string
+	client *sftpClient
+	handle string
+	size   int64
+	offset int64
+	cache  *blockCache
+	mu     *sync.Mutex
+}
+
+func newSFTPFile(client *sftpClient, name, path string) (*sftpFile, error) {
+	handle, err := client.open(path, sftpFlagRead)
+	if err != nil {
+		return nil, err
+	}
+	size, err := client.fstat(handle)
+	if err != nil {
+		return nil, err
+	}
+	f := &sftpFile{
+		name: name, path: path, client: client,
+		handle: handle, size: size, mu: new(sync.Mutex),
+	}
+	f.cache = newBlockCache(sftpBlockSize, sftpCacheBlocks, size, f.fetch)
+	return f, nil
+}
+
+// fetch the bytes of the range, which may take multiple read requests.
+func (f *sftpFile) fetch(from, to int64) ([]byte, error) {
+	bs := make([]byte, 0, to-from)
+	for int64(len(bs)) < to-from {
+		p, err := f.client.read(f.handle, from+int64(len(bs)), int(to-from)-len(bs))
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+		if len(p) == 0 {
+			return nil, io.ErrUnexpectedEOF
+		}
+		bs = append(bs, p...)
+	}
+	return bs, nil
+}
+
+// prefetch reads the blocks of the range into the cache in the background.
+func (f *sftpFile) prefetch(from, to int64) {
+	f.cache.prefetch(f.mu, from, to)
+}
+
+// ReadAt reads the bytes from the cached blocks.
+func (f *sftpFile) ReadAt(p []byte, offset int64) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.cache.ReadAt(p, offset)
+}
+
+// WriteAt writes the bytes to the remote file, and updates the cache.
+func (f *sftpFile) WriteAt(p []byte, offset int64) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	handle, err := f.client.open(f.path, sftpFlagWrite)
+	if err != nil {
+		return 0, err
+	}
+	defer f.client.close(handle)
+	for n := 0; n < len(p); n += sftpBlockSize {
+		end := n + sftpBlockSize
+		if end > len(p) {
+			end = len(p)
+		}
+		if err := f.client.write(handle, offset+int64(n), p[n:end]); err != nil {
+			return n, err
+		}
+	}
+	f.cache.update(p, offset)
+	return len(p), nil
+}
+
+// Seek sets the offset, which is used to get the size of the file.
+func (f *sftpFile) Seek(offset int64, whence int) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch whence {
+	case io.SeekStart:
+		f.offset = offset
+	case io.SeekCurrent:
+		f.offset += offset
+	case io.SeekEnd:
+		f.offset = f.size + offset
+	}
+	return f.offset, nil
+}
+
+// Close the file and the connection.
+func (f *sftpFile) Close() error {
+	_ = f.client.close(f.handle)
+	return f.client.Close()
+}
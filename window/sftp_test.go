@@ -0,0 +1,180 @@
+package window
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+// mockSFTPServer serves the files in memory with the subset of the protocol.
+type mockSFTPServer struct {
+	files    map[string][]byte
+	handles  map[string]string
+	requests map[byte]int
+}
+
+func newMockSFTP(files map[string][]byte) (*sftpClient, *mockSFTPServer, error) {
+	s := &mockSFTPServer{files: files, handles: make(map[string]string), requests: make(map[byte]int)}
+	cr, sw := io.Pipe()
+	sr, cw := io.Pipe()
+	go s.serve(&sftpClient{r: sr, w: sw})
+	c, err := newSFTPClient(cr, cw)
+	return c, s, err
+}
+
+func (s *mockSFTPServer) serve(c *sftpClient) {
+	for {
+		typ, bs, err := c.recv()
+		if err != nil {
+			return
+		}
+		s.requests[typ]++
+		if typ == sftpInit {
+			_ = c.send(sftpVersion, appendUint32(nil, 3))
+			continue
+		}
+		r := &sftpReader{bs}
+		id, _ := r.uint32()
+		status := func(code uint32, msg string) {
+			_ = c.send(sftpStatus, appendString(appendString(appendUint32(appendUint32(nil, id), code), msg), ""))
+		}
+		switch typ {
+		case sftpOpen:
+			path, _ := r.string()
+			if _, ok := s.files[path]; !ok {
+				status(2, "No such file")
+				continue
+			}
+			handle := string(rune('a' + len(s.handles)))
+			s.handles[handle] = path
+			_ = c.send(sftpHandle, appendString(appendUint32(nil, id), handle))
+		case sftpClose:
+			handle, _ := r.string()
+			delete(s.handles, handle)
+			status(sftpStatusOK, "")
+		case sftpFstat:
+			handle, _ := r.string()
+			size := uint64(len(s.files[s.handles[handle]]))
+			_ = c.send(sftpAttrs, appendUint64(appendUint32(appendUint32(nil, id), sftpAttrSize), size))
+		case sftpRead:
+			handle, _ := r.string()
+			offset, _ := r.uint64()
+			n, _ := r.uint32()
+			bs := s.files[s.handles[handle]]
+			if offset >= uint64(len(bs)) {
+				status(sftpStatusEOF, "")
+				continue
+			}
+			if n > 1000 {
+				n = 1000 // servers may return shorter data
+			}
+			end := offset + uint64(n)
+			if end > uint64(len(bs)) {
+				end = uint64(len(bs))
+			}
+			_ = c.send(sftpData, appendString(appendUint32(nil, id), string(bs[offset:end])))
+		case sftpWrite:
+			handle, _ := r.string()
+			offset, _ := r.uint64()
+			data, _ := r.string()
+			copy(s.files[s.handles[handle]][offset:], data)
+			status(sftpStatusOK, "")
+		}
+	}
+}
+
+func TestIsSFTP(t *testing.T) {
+	for _, testCase := range []struct {
+		name     string
+		expected bool
+	}{
+		{"sftp://host/tmp/test.bin", true},
+		{"sftp://user@host/~/test.bin", true},
+		{"host:/tmp/test.bin", false},
+		{"notes:v1", false},
+		{"C:test.bin", false},
+		{"https://example.com/test.bin", false},
+		{"test.bin", false},
+	} {
+		if got := isSFTP(testCase.name); got != testCase.expected {
+			t.Errorf("isSFTP(%q) should be %v but got %v", testCase.name, testCase.expected, got)
+		}
+	}
+}
+
+func TestSplitSFTP(t *testing.T) {
+	for _, testCase := range []struct {
+		name, host, path, err string
+	}{
+		{"sftp://host/tmp/test.bin", "host", "/tmp/test.bin", ""},
+		{"sftp://user@host/~/test.bin", "user@host", "test.bin", ""},
+		{"sftp://host", "host", ".", ""},
+		{"sftp://host/~", "host", ".", ""},
+		{"sftp:///tmp/test.bin", "", "", "invalid host: sftp:///tmp/test.bin"},
+		{"sftp://-oProxyCommand=id/x", "", "", "invalid host: sftp://-oProxyCommand=id/x"},
+	} {
+		host, path, err := splitSFTP(testCase.name)
+		if testCase.err != "" {
+			if err == nil || err.Error() != testCase.err {
+				t.Errorf("splitSFTP(%q) should fail with %q but got: %v", testCase.name, testCase.err, err)
+			}
+			continue
+		}
+		if err != nil || host != testCase.host || path != testCase.path {
+			t.Errorf("splitSFTP(%q) should be %q, %q but got %q, %q, %v",
+				testCase.name, testCase.host, testCase.path, host, path, err)
+		}
+	}
+}
+
+func TestSFTPFile(t *testing.T) {
+	content := []byte(strings.Repeat("0123456789abcdef", 3*sftpBlockSize/16+1))
+	c, s, err := newMockSFTP(map[string][]byte{"/tmp/test.bin": content})
+	if err != nil {
+		t.Fatalf("err should be nil but got: %v", err)
+	}
+	f, err := newSFTPFile(c, "host:/tmp/test.bin", "/tmp/test.bin")
+	if err != nil {
+		t.Fatalf("err should be nil but got: %v", err)
+	}
+	if f.size != int64(len(content)) {
+		t.Errorf("size should be %d but got %d", len(content), f.size)
+	}
+	p := make([]byte, 8)
+	n, err := f.ReadAt(p, sftpBlockSize-4)
+	if err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	if n != 8 || string(p) != "cdef0123" {
+		t.Errorf("ReadAt should read %q but got %q", "cdef0123", string(p[:n]))
+	}
+	reads := s.requests[sftpRead]
+	if _, err = f.ReadAt(p, sftpBlockSize+4); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	if s.requests[sftpRead] != reads {
+		t.Errorf("cached blocks should not be requested again")
+	}
+	if _, err = f.WriteAt([]byte("xyz"), sftpBlockSize-1); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	if got := string(content[sftpBlockSize-2 : sftpBlockSize+3]); got != "exyz2" {
+		t.Errorf("WriteAt should write %q but got %q", "exyz2", got)
+	}
+	if _, err = f.ReadAt(p, sftpBlockSize-4); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	if string(p) != "cdexyz23" {
+		t.Errorf("cached blocks should be updated but got %q", string(p))
+	}
+	n, err = f.ReadAt(p, int64(len(content))-4)
+	if err != io.EOF {
+		t.Errorf("err should be EOF but got: %v", err)
+	}
+	if n != 4 || string(p[:n]) != "cdef" {
+		t.Errorf("ReadAt should read %q but got %q", "cdef", string(p[:n]))
+	}
+	if _, err = newSFTPFile(c, "host:/tmp/none", "/tmp/none"); err == nil || err.Error() != "/tmp/none: No such file" {
+		t.Errorf("err should be %q but got: %v", "/tmp/none: No such file", err)
+	}
+}
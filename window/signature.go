@@ -0,0 +1,248 @@
+package window
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/itchyny/bed/event"
+)
+
+// signature is the magic bytes of a file format. The magic is at the offset
+// from the start of the file, and the check validates the bytes further. The
+// size detects the size of the file, which is used to carve the file.
+type signature struct {
+	name   string
+	magic  string
+	offset int64
+	check  func(w *window, start int64) bool
+	ext    string
+	size   func(w *window, start int64) (int64, bool)
+}
+
+// signatures is the signatures of the file formats. The former signature has
+// priority when more than one signature matches at the same offset.
+var signatures = []signature{
+	{name: "PNG image", magic: "\x89PNG\r\n\x1a\n", ext: "png", size: sizePNG},
+	{name: "JPEG start of image", magic: "\xff\xd8\xff", ext: "jpg", size: sizeJPEG},
+	{name: "GIF image", magic: "GIF87a", ext: "gif"},
+	{name: "GIF image", magic: "GIF89a", ext: "gif"},
+	{name: "TIFF image (little endian)", magic: "II*\x00", ext: "tif"},
+	{name: "TIFF image (big endian)", magic: "MM\x00*", ext: "tif"},
+	{name: "BMP image", magic: "BM", check: checkBMP, ext: "bmp", size: sizeBMP},
+	{name: "RIFF WAVE audio", magic: "RIFF", check: checkRIFF("WAVE"), ext: "wav", size: sizeRIFF},
+	{name: "RIFF AVI video", magic: "RIFF", check: checkRIFF("AVI "), ext: "avi", size: sizeRIFF},
+	{name: "RIFF WebP image", magic: "RIFF", check: checkRIFF("WEBP"), ext: "webp", size: sizeRIFF},
+	{name: "RIFF data", magic: "RIFF", ext: "riff", size: sizeRIFF},
+	{name: "Ogg stream", magic: "OggS", ext: "ogg"},
+	{name: "FLAC audio", magic: "fLaC", ext: "flac"},
+	{name: "MP3 audio with ID3 tag", magic: "ID3", ext: "mp3"},
+	{name: "PDF document", magic: "%PDF-", ext: "pdf", size: sizePDF},
+	{name: "zip local file header", magic: "PK\x03\x04", ext: "zip", size: sizeZip},
+	{name: "zip end of central directory", magic: "PK\x05\x06", ext: "zip"},
+	{name: "gzip compressed data", magic: "\x1f\x8b\x08", ext: "gz", size: sizeGzip},
+	{name: "bzip2 compressed data", magic: "BZh", check: checkBzip2, ext: "bz2"},
+	{name: "xz compressed data", magic: "\xfd7zXZ\x00", ext: "xz"},
+	{name: "zstd compressed data", magic: "\x28\xb5\x2f\xfd", ext: "zst"},
+	{name: "LZ4 frame", magic: "\x04\x22\x4d\x18", ext: "lz4"},
+	{name: "7-zip archive", magic: "7z\xbc\xaf\x27\x1c", ext: "7z"},
+	{name: "RAR archive", magic: "Rar!\x1a\x07", ext: "rar"},
+	{name: "tar archive", magic: "ustar", offset: 257, ext: "tar"},
+	{name: "cpio archive", magic: "070701", ext: "cpio"},
+	{name: "zlib stream", magic: "\x78", check: checkZlib, ext: "zlib", size: sizeZlib},
+	{name: "ELF executable", magic: "\x7fELF", ext: "elf", size: sizeELF},
+	{name: "PE executable", magic: "MZ", check: checkPE, ext: "exe", size: sizePE},
+	{name: "Mach-O executable", magic: "\xcf\xfa\xed\xfe", ext: "macho"},
+	{name: "Java class or Mach-O universal binary", magic: "\xca\xfe\xba\xbe", ext: "class"},
+	{name: "WebAssembly module", magic: "\x00asm", ext: "wasm"},
+	{name: "SQLite database", magic: "SQLite format 3\x00", ext: "sqlite", size: sizeSQLite},
+	{name: "pcap capture", magic: "\xd4\xc3\xb2\xa1", ext: "pcap"},
+	{name: "pcap capture", magic: "\xa1\xb2\xc3\xd4", ext: "pcap"},
+	{name: "pcapng capture", magic: "\x0a\x0d\x0d\x0a", ext: "pcapng"},
+	{name: "squashfs filesystem", magic: "hsqs", ext: "sqsh"},
+	{name: "U-Boot image", magic: "\x27\x05\x19\x56", ext: "uimg"},
+	{name: "device tree blob", magic: "\xd0\x0d\xfe\xed", ext: "dtb"},
+}
+
+// checkBMP checks the reserved fields and the offset of the pixels.
+func checkBMP(w *window, start int64) bool {
+	bs, err := w.readFull(start, 14)
+	if err != nil {
+		return false
+	}
+	size, offset := binary.LittleEndian.Uint32(bs[2:]), binary.LittleEndian.Uint32(bs[10:])
+	return binary.LittleEndian.Uint32(bs[6:]) == 0 && 14 < offset && offset < size
+}
+
+// checkRIFF returns the check of the form type of the RIFF file.
+func checkRIFF(form string) func(*window, int64) bool {
+	return func(w *window, start int64) bool {
+		bs, err := w.readFull(start+8, 4)
+		return err == nil && string(bs) == form
+	}
+}
+
+// checkBzip2 checks the block size and the magic of the first block.
+func checkBzip2(w *window, start int64) bool {
+	bs, err := w.readFull(start+3, 7)
+	return err == nil && '1' <= bs[0] && bs[0] <= '9' && string(bs[1:]) == "1AY&SY"
+}
+
+// checkZlib checks the header of the zlib stream and the first bytes of the
+// deflate stream, which are rarely decoded from random bytes.
+func checkZlib(w *window, start int64) bool {
+	bs, err := w.readFull(start, 2)
+	if err != nil || (uint16(bs[0])<<8|uint16(bs[1]))%31 != 0 || bs[1]&0x20 != 0 {
+		return false
+	}
+	r := flate.NewReader(io.NewSectionReader(w.buffer, start+2, w.length-start-2))
+	defer r.Close()
+	bs = make([]byte, 64)
+	for n := 0; n < len(bs); {
+		m, err := r.Read(bs[n:])
+		if err == io.EOF {
+			return true
+		} else if err != nil {
+			return false
+		}
+		n += m
+	}
+	return true
+}
+
+// checkPE checks the signature of the PE header.
+func checkPE(w *window, start int64) bool {
+	bs, err := w.readFull(start+0x3c, 4)
+	if err != nil {
+		return false
+	}
+	bs, err = w.readFull(start+int64(binary.LittleEndian.Uint32(bs)), 4)
+	return err == nil && string(bs) == "PE\x00\x00"
+}
+
+// identify returns the signature of the file format starting at the offset.
+func (w *window) identify(start int64) *signature {
+	for i, sig := range signatures {
+		bs, err := w.readFull(start+sig.offset, int64(len(sig.magic)))
+		if err == nil && string(bs) == sig.magic && (sig.check == nil || sig.check(w, start)) {
+			return &signatures[i]
+		}
+	}
+	return nil
+}
+
+// describe returns the description of the bytes at the offset, like the file
+// command.
+func (w *window) describe(start int64) string {
+	if sig := w.identify(start); sig != nil {
+		return sig.name
+	}
+	bs := make([]byte, 256)
+	n, err := w.buffer.ReadAt(bs, start)
+	if err != nil && err != io.EOF || n == 0 {
+		return "empty"
+	}
+	// the text may be padded with zero bytes
+	text := bytes.TrimRight(bs[:n], "\x00")
+	if len(text) == 0 {
+		return "zero bytes"
+	}
+	for _, b := range text {
+		if !isPrintable(b) && b != '\n' && b != '\r' && b != '\t' {
+			return "data"
+		}
+	}
+	return "ASCII text"
+}
+
+func (m *Manager) identify(e event.Event) error {
+	if len(e.Arg) > 0 {
+		return fmt.Errorf("too many arguments for %s", e.CmdName)
+	}
+	window := m.windows[m.windowIndex]
+	waitWindow(window)
+	window.mu.Lock()
+	cursor := window.cursor
+	msg := fmt.Sprintf("0x%x: %s", cursor, window.describe(cursor))
+	window.mu.Unlock()
+	m.eventCh <- event.Event{Type: event.Info, Error: errors.New(msg)}
+	return nil
+}
+
+// signatureMatch is the signature found at the offset.
+type signatureMatch struct {
+	offset int64
+	sig    *signature
+}
+
+// scanSignatures finds the signatures in the buffer, sorted by the offsets.
+func (w *window) scanSignatures() ([]signatureMatch, error) {
+	var overlap int
+	for _, sig := range signatures {
+		if n := int(sig.offset) + len(sig.magic); n > overlap {
+			overlap = n
+		}
+	}
+	var matches []signatureMatch
+	found := make(map[int64]bool)
+	err := w.scanBuffer(overlap-1, func(base int64, bs []byte) bool {
+		for k := range signatures {
+			sig := &signatures[k]
+			for i := 0; len(matches) < maxQuickfixItems; {
+				j := bytes.Index(bs[i:], []byte(sig.magic))
+				if j < 0 {
+					break
+				}
+				start := base + int64(i+j) - sig.offset
+				if i += j + 1; start < 0 || found[start] {
+					continue
+				}
+				if sig.check == nil || sig.check(w, start) {
+					found[start] = true
+					matches = append(matches, signatureMatch{start, sig})
+				}
+			}
+		}
+		return len(matches) < maxQuickfixItems
+	})
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].offset < matches[j].offset
+	})
+	return matches, err
+}
+
+// scan returns the offsets of the signatures found in the buffer.
+func (w *window) scan() ([]quickfixItem, error) {
+	matches, err := w.scanSignatures()
+	if err != nil {
+		return nil, err
+	}
+	items := make([]quickfixItem, len(matches))
+	for i, m := range matches {
+		items[i] = quickfixItem{m.offset, m.sig.offset + int64(len(m.sig.magic)), m.sig.name}
+	}
+	return items, nil
+}
+
+func (m *Manager) scan(e event.Event) error {
+	if len(e.Arg) > 0 {
+		return fmt.Errorf("too many arguments for %s", e.CmdName)
+	}
+	window := m.windows[m.windowIndex]
+	waitWindow(window)
+	window.mu.Lock()
+	items, err := window.scan()
+	window.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if len(items) == 0 {
+		return errors.New("no signatures found")
+	}
+	m.setQuickfix(window, items)
+	return m.listQuickfix(event.Event{})
+}
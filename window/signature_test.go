@@ -0,0 +1,74 @@
+package window
+
+import (
+	"bytes"
+	"compress/zlib"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func signatureTestBytes(t *testing.T) []byte {
+	var b bytes.Buffer
+	b.WriteString("\x01\x02\x03\x04")
+	b.WriteString("\x89PNG\r\n\x1a\n\x00\x00\x00\x0dIHDR")
+	zw := zlib.NewWriter(&b)
+	if _, err := zw.Write([]byte(strings.Repeat("hello, world\n", 10))); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	b.WriteString("RIFF\x24\x00\x00\x00WAVEfmt ")
+	b.WriteString("xx some text with x\n")
+	b.Write(make([]byte, 16))
+	return b.Bytes()
+}
+
+func TestWindowIdentify(t *testing.T) {
+	bs := signatureTestBytes(t)
+	window, err := newWindow(bytes.NewReader(bs), "test", "test", make(chan struct{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	riff := int64(bytes.Index(bs, []byte("RIFF")))
+	for _, testCase := range []struct {
+		offset   int64
+		expected string
+	}{
+		{0, "data"},
+		{4, "PNG image"},
+		{20, "zlib stream"},
+		{riff, "RIFF WAVE audio"},
+		{riff + 4, "data"},
+		{riff + 18, "ASCII text"},
+		{int64(len(bs)) - 16, "zero bytes"},
+		{int64(len(bs)), "empty"},
+	} {
+		if got := window.describe(testCase.offset); got != testCase.expected {
+			t.Errorf("describe(%d) should be %q but got %q", testCase.offset, testCase.expected, got)
+		}
+	}
+}
+
+func TestWindowScan(t *testing.T) {
+	bs := signatureTestBytes(t)
+	bs = append(bytes.Repeat([]byte("\xff"), 70000), bs...)
+	window, err := newWindow(bytes.NewReader(bs), "test", "test", make(chan struct{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	items, err := window.scan()
+	if err != nil {
+		t.Fatalf("err should be nil but got: %v", err)
+	}
+	riff := int64(bytes.Index(bs, []byte("RIFF")))
+	expected := []quickfixItem{
+		{70004, 8, "PNG image"},
+		{70020, 1, "zlib stream"},
+		{riff, 4, "RIFF WAVE audio"},
+	}
+	if !reflect.DeepEqual(items, expected) {
+		t.Errorf("items should be %+v but got %+v", expected, items)
+	}
+}
@@ -0,0 +1,214 @@
+package window
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/itchyny/bed/mathutil"
+	"github.com/itchyny/bed/state"
+)
+
+// sparseRuns holds the runs of the identical rows found in the buffer, which
+// are valid while the buffer and the width are unchanged.
+type sparseRuns struct {
+	changedTick uint64
+	width       int64
+	runs        [][2]int64
+}
+
+// sparseRow is a row of the sparse view. The marker row hides the identical
+// rows of the length from the offset.
+type sparseRow struct {
+	offset int64
+	hidden int64
+}
+
+// rowsRun returns the start and the end of the rows which have the same bytes
+// as the row at the offset.
+func (w *window) rowsRun(row int64) (int64, int64) {
+	c := w.sparse
+	if c == nil || c.changedTick != w.changedTick || c.width != w.width {
+		c = &sparseRuns{changedTick: w.changedTick, width: w.width}
+		w.sparse = c
+	}
+	for _, r := range c.runs {
+		if r[0] <= row && row < r[1] {
+			return r[0], r[1]
+		}
+	}
+	pattern := make([]byte, w.width)
+	if n, err := w.buffer.ReadAt(pattern, row); int64(n) < w.width || err != nil && err != io.EOF {
+		return row, row + w.width
+	}
+	bs := make([]byte, mathutil.MaxInt64(64*1024/w.width, 1)*w.width)
+	end := row + w.width
+	for {
+		n, err := w.buffer.ReadAt(bs, end)
+		if err != nil && err != io.EOF {
+			break
+		}
+		i := 0
+		for ; i+int(w.width) <= n && bytes.Equal(bs[i:i+int(w.width)], pattern); i += int(w.width) {
+		}
+		if end += int64(i); i < len(bs) {
+			break
+		}
+	}
+	start := row
+	for start > 0 {
+		base := mathutil.MaxInt64(start-int64(len(bs)), 0)
+		n, err := w.buffer.ReadAt(bs[:start-base], base)
+		if err != nil && err != io.EOF || n < int(start-base) {
+			break
+		}
+		i := n
+		for ; i >= int(w.width) && bytes.Equal(bs[i-int(w.width):i], pattern); i -= int(w.width) {
+		}
+		if start -= int64(n - i); i > 0 {
+			break
+		}
+	}
+	if end-start >= 4*w.width {
+		if len(c.runs) >= 64 {
+			c.runs = c.runs[1:]
+		}
+		c.runs = append(c.runs, [2]int64{start, end})
+	}
+	return start, end
+}
+
+// sparseRows returns the rows displayed from the offset in the sparse view.
+// The identical rows of a run are collapsed into a marker row, except for the
+// first and the last rows of the run and the row of the cursor.
+func (w *window) sparseRows(offset, height int64) []sparseRow {
+	cursorRow := w.cursor / w.width * w.width
+	rows := make([]sparseRow, 0, height)
+	for row := offset; int64(len(rows)) < height; row += w.width {
+		rows = append(rows, sparseRow{offset: row})
+		if row >= w.length || int64(len(rows)) == height {
+			continue
+		}
+		_, end := w.rowsRun(row)
+		if end -= w.width; row < cursorRow && cursorRow < end {
+			end = cursorRow
+		}
+		if end-row-w.width >= 2*w.width {
+			rows = append(rows, sparseRow{row + w.width, end - row - w.width})
+			row = end - w.width
+		}
+	}
+	return rows
+}
+
+// sparseLines returns the number of the rows to move the cursor by the count
+// of the displayed rows, skipping the collapsed rows.
+func (w *window) sparseLines(count int64, down bool) int64 {
+	var lines int64
+	row := w.cursor / w.width * w.width
+	for i := mathutil.MaxInt64(count, 1); i > 0 && row >= 0 && row < w.length; i-- {
+		start, end := w.rowsRun(row)
+		n := int64(1)
+		if down && end-w.width-row >= 3*w.width {
+			n = (end - w.width - row) / w.width
+		} else if !down && row-start >= 3*w.width {
+			n = (row - start) / w.width
+		}
+		if down {
+			row += n * w.width
+		} else {
+			row -= n * w.width
+		}
+		lines += n
+	}
+	return lines
+}
+
+// sparseVisible reports whether the cursor is in the sparse view.
+func (w *window) sparseVisible() bool {
+	cursorRow := w.cursor / w.width * w.width
+	for _, row := range w.sparseRows(w.offset, w.height) {
+		if row.hidden == 0 && row.offset == cursorRow {
+			return true
+		}
+	}
+	return false
+}
+
+// sparsePageDown returns the offset to scroll down by the count of pages in
+// the sparse view, keeping the last two rows displayed.
+func (w *window) sparsePageDown(count int64) int64 {
+	offset := w.offset
+	for i := mathutil.MaxInt64(count, 1); i > 0; i-- {
+		rows := w.sparseRows(offset, w.height)
+		offset = rows[mathutil.MaxInt(len(rows)-2, 0)].offset
+	}
+	return offset
+}
+
+// sparsePageUp returns the offset to scroll up by the count of pages in the
+// sparse view, counting a collapsed run as two rows.
+func (w *window) sparsePageUp(count int64) int64 {
+	offset := w.offset
+	for i := mathutil.MaxInt64(w.height-2, 1) * mathutil.MaxInt64(count, 1); i > 0 && offset > 0; i-- {
+		row := offset - w.width
+		if start, _ := w.rowsRun(row); row-start >= 2*w.width {
+			row, i = start, i-1
+		}
+		offset = row
+	}
+	return mathutil.MaxInt64(offset, 0)
+}
+
+// sparseSegments returns the offsets and the lengths of the bytes displayed
+// in the sparse view, and the collapsed rows.
+func (w *window) sparseSegments() ([][2]int64, []state.Skip) {
+	var segments [][2]int64
+	var skips []state.Skip
+	for i, row := range w.sparseRows(w.offset, w.height) {
+		if row.hidden > 0 {
+			skips = append(skips, state.Skip{Row: i, Offset: row.offset, Length: row.hidden})
+		} else if l := len(segments); l > 0 && segments[l-1][0]+segments[l-1][1] == row.offset {
+			segments[l-1][1] += w.width
+		} else {
+			segments = append(segments, [2]int64{row.offset, w.width})
+		}
+	}
+	return segments, skips
+}
+
+// sparseLastRow returns the offset of the last row of the bytes displayed in
+// the sparse view.
+func (w *window) sparseLastRow() int64 {
+	rows := w.sparseRows(w.offset, w.height)
+	for i := len(rows) - 1; i > 0; i-- {
+		if rows[i].hidden == 0 {
+			return rows[i].offset
+		}
+	}
+	return rows[0].offset
+}
+
+// stateSegment is the bytes displayed continuously in the window state.
+type stateSegment struct {
+	index, length int
+	offset        int64
+}
+
+// stateSegments returns the segments of the bytes in the window state, which
+// are split by the collapsed rows.
+func stateSegments(s *state.WindowState) []stateSegment {
+	var segments []stateSegment
+	var index, row int
+	offset := s.Offset
+	for _, skip := range s.Skips {
+		if n := mathutil.MinInt((skip.Row-row)*s.Width, s.Size-index); n > 0 {
+			segments = append(segments, stateSegment{index, n, offset})
+			index += n
+		}
+		row, offset = skip.Row+1, skip.Offset+skip.Length
+	}
+	if n := s.Size - index; n > 0 {
+		segments = append(segments, stateSegment{index, n, offset})
+	}
+	return segments
+}
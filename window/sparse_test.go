@@ -0,0 +1,110 @@
+package window
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/itchyny/bed/event"
+	"github.com/itchyny/bed/state"
+)
+
+func sparseTestRows(n int) string {
+	var sb strings.Builder
+	for i := 0; i < n; i++ {
+		sb.WriteString(strings.Repeat(string(rune('B'+i)), 16))
+	}
+	return sb.String()
+}
+
+func TestWindowSparse(t *testing.T) {
+	str := strings.Repeat("A", 16) + strings.Repeat("\x00", 16*100) + sparseTestRows(20)
+	window, err := newWindow(strings.NewReader(str), "test", "test", make(chan struct{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	window.setSize(16, 10)
+	_ = window.options.Set("sparse")
+
+	s, err := window.state()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []state.Skip{{Row: 2, Offset: 0x20, Length: 16 * 98}}; !reflect.DeepEqual(s.Skips, expected) {
+		t.Errorf("skips should be %v but got %v", expected, s.Skips)
+	}
+	if expected := 16 * 9; s.Size != expected {
+		t.Errorf("s.Size should be %d but got %d", expected, s.Size)
+	}
+	if expected := strings.Repeat("A", 16) + strings.Repeat("\x00", 32) + sparseTestRows(6); string(s.Bytes[:s.Size]) != expected {
+		t.Errorf("s.Bytes should be %q but got %q", expected, s.Bytes[:s.Size])
+	}
+
+	window.cursorDown(1)
+	if expected := int64(0x10); window.cursor != expected {
+		t.Errorf("cursor should be %d but got %d", expected, window.cursor)
+	}
+	window.cursorDown(1)
+	if expected := int64(0x640); window.cursor != expected {
+		t.Errorf("cursor should be %d but got %d", expected, window.cursor)
+	}
+	if expected := int64(0); window.offset != expected {
+		t.Errorf("offset should be %d but got %d", expected, window.offset)
+	}
+	window.cursorDown(2)
+	if expected := int64(0x660); window.cursor != expected {
+		t.Errorf("cursor should be %d but got %d", expected, window.cursor)
+	}
+	window.cursorUp(2)
+	if expected := int64(0x640); window.cursor != expected {
+		t.Errorf("cursor should be %d but got %d", expected, window.cursor)
+	}
+	window.cursorUp(1)
+	if expected := int64(0x10); window.cursor != expected {
+		t.Errorf("cursor should be %d but got %d", expected, window.cursor)
+	}
+
+	window.cursorGotoPos(event.Absolute{Offset: 0x300})
+	if s, err = window.state(); err != nil {
+		t.Fatal(err)
+	}
+	if expected := []state.Skip{
+		{Row: 1, Offset: 0x2d0, Length: 0x30},
+		{Row: 3, Offset: 0x310, Length: 0x330},
+	}; !reflect.DeepEqual(s.Skips, expected) {
+		t.Errorf("skips should be %v but got %v", expected, s.Skips)
+	}
+
+	_ = window.options.Set("nosparse")
+	if s, err = window.state(); err != nil {
+		t.Fatal(err)
+	}
+	if s.Skips != nil {
+		t.Errorf("skips should be nil but got %v", s.Skips)
+	}
+}
+
+func TestWindowSparsePage(t *testing.T) {
+	str := strings.Repeat("A", 16) + strings.Repeat("\x00", 16*100) + sparseTestRows(20)
+	window, err := newWindow(strings.NewReader(str), "test", "test", make(chan struct{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	window.setSize(16, 10)
+	_ = window.options.Set("sparse")
+
+	window.pageDown(1)
+	if expected := int64(0x690); window.offset != expected {
+		t.Errorf("offset should be %d but got %d", expected, window.offset)
+	}
+	if expected := int64(0x690); window.cursor != expected {
+		t.Errorf("cursor should be %d but got %d", expected, window.cursor)
+	}
+	window.pageUp(1)
+	if expected := int64(0); window.offset != expected {
+		t.Errorf("offset should be %d but got %d", expected, window.offset)
+	}
+	if expected := int64(0); window.cursor != expected {
+		t.Errorf("cursor should be %d but got %d", expected, window.cursor)
+	}
+}
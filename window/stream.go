@@ -0,0 +1,120 @@
+package window
+
+import (
+	"errors"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/itchyny/bed/mathutil"
+)
+
+// streamChunk is the size of the input read ahead on moving to the end.
+const streamChunk = 1 << 20
+
+// stream spools the input which cannot be seeked, such as pipes and character
+// devices, to the temporary file on demand, so that the window can be opened
+// before the whole input arrives. The length is unknown until the end of the
+// input, and seeking to the end returns the length read so far.
+type stream struct {
+	r     io.Reader
+	f     *os.File
+	size  int64
+	index int64
+	eof   bool
+	err   error
+	mu    sync.Mutex
+}
+
+func newStream(r io.Reader, f *os.File) *stream {
+	return &stream{r: r, f: f}
+}
+
+// fill reads the input until the size reaches n bytes or the end of input.
+func (s *stream) fill(n int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.read(n)
+}
+
+func (s *stream) read(n int64) error {
+	if s.size >= n || s.eof || s.err != nil {
+		return s.err
+	}
+	buf := make([]byte, 32*1024)
+	for s.size < n && !s.eof && s.err == nil {
+		k, err := s.r.Read(buf)
+		if k > 0 {
+			if _, err := s.f.WriteAt(buf[:k], s.size); err != nil {
+				s.err = err
+				break
+			}
+			s.size += int64(k)
+		}
+		if err == io.EOF {
+			s.eof = true
+		} else if err != nil {
+			s.err = err
+		}
+	}
+	return s.err
+}
+
+// next reads the next chunk of the input.
+func (s *stream) next() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.read(s.size + streamChunk)
+}
+
+// done reports whether the whole input is read, which fixes the length.
+func (s *stream) done() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.eof || s.err != nil
+}
+
+// ReadAt implements the io.ReaderAt interface.
+func (s *stream) ReadAt(p []byte, offset int64) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.read(offset + int64(len(p))); err != nil {
+		return 0, err
+	}
+	if offset >= s.size {
+		return 0, io.EOF
+	}
+	n, err := s.f.ReadAt(p[:mathutil.MinInt64(int64(len(p)), s.size-offset)], offset)
+	if err == nil && n < len(p) {
+		err = io.EOF
+	}
+	return n, err
+}
+
+// Seek implements the io.Seeker interface.
+func (s *stream) Seek(offset int64, whence int) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += s.index
+	case io.SeekEnd:
+		offset += s.size
+	default:
+		return 0, errors.New("stream.Seek: invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("stream.Seek: negative position")
+	}
+	s.index = offset
+	return offset, nil
+}
+
+// Close closes the input.
+func (s *stream) Close() error {
+	if c, ok := s.r.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
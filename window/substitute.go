@@ -0,0 +1,262 @@
+package window
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"unicode"
+
+	"github.com/itchyny/bed/event"
+	"github.com/itchyny/bed/mathutil"
+	"github.com/itchyny/bed/state"
+)
+
+// substituteColor is the color of the match being confirmed.
+const substituteColor = "red"
+
+// substitution is the state of the substitution confirming each match. The
+// end of the range is shifted by the replacements of the different length.
+type substitution struct {
+	pattern     []byte
+	replacement []byte
+	offset      int64
+	to          int64
+	count       int
+}
+
+// parseSubstituteArgs parses the arguments of the substitute command; the
+// pattern, the replacement and the flags separated by the delimiter, which is
+// the first character. The delimiter is escaped by the backslash, and the
+// other escape sequences are interpreted in the pattern and the replacement.
+func parseSubstituteArgs(arg string) (pattern, replacement []byte, global, confirm bool, err error) {
+	rs := []rune(arg)
+	if len(rs) == 0 {
+		return nil, nil, false, false, errors.New("pattern is required for substitute")
+	}
+	delim := rs[0]
+	if unicode.IsLetter(delim) || unicode.IsDigit(delim) || unicode.IsSpace(delim) || delim == '\\' {
+		return nil, nil, false, false, fmt.Errorf("invalid delimiter for substitute: %c", delim)
+	}
+	var parts [][]rune
+	var part []rune
+	for i := 1; i < len(rs); i++ {
+		if rs[i] == '\\' && i+1 < len(rs) && rs[i+1] == delim {
+			part = append(part, rs[i+1])
+			i++
+		} else if rs[i] == '\\' && i+1 < len(rs) {
+			part = append(part, rs[i], rs[i+1])
+			i++
+		} else if rs[i] == delim && len(parts) < 2 {
+			parts, part = append(parts, part), nil
+		} else {
+			part = append(part, rs[i])
+		}
+	}
+	if parts = append(parts, part); len(parts[0]) == 0 {
+		return nil, nil, false, false, errors.New("pattern is required for substitute")
+	}
+	if pattern, err = event.Unescape(string(parts[0])); err != nil {
+		return nil, nil, false, false, err
+	}
+	if len(parts) > 1 {
+		if replacement, err = event.Unescape(string(parts[1])); err != nil {
+			return nil, nil, false, false, err
+		}
+	}
+	if len(parts) > 2 {
+		for _, c := range parts[2] {
+			switch c {
+			case 'g':
+				global = true
+			case 'c':
+				confirm = true
+			default:
+				return nil, nil, false, false, fmt.Errorf("invalid flag for substitute: %c", c)
+			}
+		}
+	}
+	return pattern, replacement, global, confirm, nil
+}
+
+func (m *Manager) substitute(e event.Event) error {
+	pattern, replacement, global, confirm, err := parseSubstituteArgs(e.Arg)
+	if err != nil {
+		return err
+	}
+	window := m.windows[m.windowIndex]
+	waitWindow(window)
+	window.mu.Lock()
+	msg, err := window.substitute(e.Range, pattern, replacement, global, confirm)
+	confirming := window.substitution != nil
+	window.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if confirming {
+		m.eventCh <- event.Event{Type: event.StartConfirm, Error: errors.New(msg)}
+	} else {
+		m.eventCh <- event.Event{Type: event.Info, Error: errors.New(msg)}
+	}
+	return nil
+}
+
+func (m *Manager) confirmSubstitute(e event.Event) error {
+	window := m.windows[m.windowIndex]
+	window.mu.Lock()
+	msg, err := window.confirmSubstitute(e.Rune)
+	confirming := window.substitution != nil
+	window.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if confirming {
+		m.eventCh <- event.Event{Type: event.StartConfirm, Error: errors.New(msg)}
+	} else {
+		m.eventCh <- event.Event{Type: event.ExitConfirm, Error: errors.New(msg)}
+	}
+	return nil
+}
+
+// substitute replaces the matches of the pattern starting in the range (the
+// row of the cursor by default), which may end after the range. Only the first
+// match is replaced without the global flag. With the confirm flag, the first
+// match is highlighted and the replacements are confirmed one by one.
+func (w *window) substitute(r *event.Range, pattern, replacement []byte, global, confirm bool) (string, error) {
+	from, to := w.cursor, w.cursor
+	if w.width > 0 {
+		from = w.cursor / w.width * w.width
+		to = from + w.width - 1
+	}
+	if r != nil {
+		var err error
+		if from, err = w.positionToOffset(r.From); err != nil {
+			return "", err
+		}
+		to = from
+		if r.To != nil {
+			if to, err = w.positionToOffset(r.To); err != nil {
+				return "", err
+			}
+		}
+		if from > to {
+			from, to = to, from
+		}
+	}
+	to = mathutil.MinInt64(to, w.length-1)
+	offset, err := w.nextMatch(pattern, from, to)
+	if err != nil {
+		return "", err
+	}
+	if offset < 0 {
+		return "", fmt.Errorf("pattern not found: %s", strconv.Quote(string(pattern)))
+	}
+	s := &substitution{pattern: pattern, replacement: replacement, offset: offset, to: to}
+	w.visualStart = -1
+	w.cursorGotoPos(event.Absolute{Offset: offset})
+	if confirm {
+		w.substitution = s
+		return s.prompt(), nil
+	}
+	for offset >= 0 {
+		w.substituteMatch(s)
+		if !global {
+			break
+		}
+		if offset, err = w.nextMatch(pattern, s.offset, s.to); err != nil {
+			return "", err
+		}
+		s.offset = offset
+	}
+	w.pushHistory(w.offset, w.cursor)
+	w.changedSwap()
+	return s.message(), nil
+}
+
+// confirmSubstitute replaces or skips the match being confirmed by the key;
+// y (replace), n (skip), a (replace all the rest), l (replace and quit) and
+// q (quit). The replacements are pushed to the history as one change.
+func (w *window) confirmSubstitute(key rune) (string, error) {
+	s := w.substitution
+	if s == nil {
+		return "", nil
+	}
+	var err error
+	switch key {
+	case 'y', 'l':
+		w.substituteMatch(s)
+		if key == 'y' {
+			s.offset, err = w.nextMatch(s.pattern, s.offset, s.to)
+		} else {
+			s.offset = -1
+		}
+	case 'n':
+		s.offset, err = w.nextMatch(s.pattern, s.offset+1, s.to)
+	case 'a':
+		for s.offset >= 0 && err == nil {
+			w.substituteMatch(s)
+			s.offset, err = w.nextMatch(s.pattern, s.offset, s.to)
+		}
+	case 'q', 0:
+		s.offset = -1
+	default:
+		return s.prompt(), nil
+	}
+	if err != nil || s.offset < 0 {
+		w.substitution = nil
+		if s.count > 0 {
+			w.pushHistory(w.offset, w.cursor)
+			w.changedSwap()
+		}
+		return s.message(), err
+	}
+	w.cursorGotoPos(event.Absolute{Offset: s.offset})
+	return s.prompt(), nil
+}
+
+// substituteMatch replaces the match at the offset, and moves the offset to
+// the end of the replacement.
+func (w *window) substituteMatch(s *substitution) {
+	w.replaceBytes(s.offset, s.offset+int64(len(s.pattern))-1, s.replacement)
+	s.offset += int64(len(s.replacement))
+	s.to += int64(len(s.replacement) - len(s.pattern))
+	s.count++
+}
+
+// nextMatch returns the offset of the first match of the pattern starting
+// within the offsets (inclusive), or -1 when not found.
+func (w *window) nextMatch(pattern []byte, from, to int64) (int64, error) {
+	const chunkSize = 1 << 20
+	for base := from; base <= to; base += chunkSize {
+		end := mathutil.MinInt64(base+chunkSize-1, to)
+		size := mathutil.MinInt64(end-base+int64(len(pattern)), w.length-base)
+		bs, err := w.readFull(base, size)
+		if err != nil {
+			return -1, err
+		}
+		if i := bytes.Index(bs, pattern); i >= 0 && base+int64(i) <= end {
+			return base + int64(i), nil
+		}
+	}
+	return -1, nil
+}
+
+// highlight returns the match being confirmed.
+func (s *substitution) highlight() state.Highlight {
+	return state.Highlight{
+		From:  s.offset,
+		To:    s.offset + int64(len(s.pattern)) - 1,
+		Color: substituteColor,
+	}
+}
+
+func (s *substitution) prompt() string {
+	return fmt.Sprintf("replace with %s (y/n/a/q/l)?", strconv.Quote(string(s.replacement)))
+}
+
+func (s *substitution) message() string {
+	if s.count == 1 {
+		return "1 substitution"
+	}
+	return fmt.Sprintf("%d substitutions", s.count)
+}
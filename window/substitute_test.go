@@ -0,0 +1,168 @@
+package window
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/itchyny/bed/event"
+)
+
+func TestParseSubstituteArgs(t *testing.T) {
+	testCases := []struct {
+		arg                  string
+		pattern, replacement string
+		global, confirm      bool
+		err                  string
+	}{
+		{"/abc/xyz/", "abc", "xyz", false, false, ""},
+		{"/abc/xyz/gc", "abc", "xyz", true, true, ""},
+		{"/abc", "abc", "", false, false, ""},
+		{`#a\#b#c/d#g`, "a#b", "c/d", true, false, ""},
+		{`/a\\/b\/c/`, `a\`, "b/c", false, false, ""},
+		{`/\x00\xff/\u{3042}\n/`, "\x00\xff", "\u3042\n", false, false, ""},
+		{`/\q/x/`, "", "", false, false, `invalid escape sequence: \q`},
+		{"", "", "", false, false, "pattern is required for substitute"},
+		{"//x/", "", "", false, false, "pattern is required for substitute"},
+		{"xaxbx", "", "", false, false, "invalid delimiter for substitute: x"},
+		{"/a/b/gx", "", "", false, false, "invalid flag for substitute: x"},
+	}
+	for _, tc := range testCases {
+		pattern, replacement, global, confirm, err := parseSubstituteArgs(tc.arg)
+		if tc.err != "" {
+			if err == nil || err.Error() != tc.err {
+				t.Errorf("parseSubstituteArgs(%q) should return error %q but got: %v", tc.arg, tc.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseSubstituteArgs(%q) should not return an error but got: %v", tc.arg, err)
+			continue
+		}
+		if string(pattern) != tc.pattern || string(replacement) != tc.replacement ||
+			global != tc.global || confirm != tc.confirm {
+			t.Errorf("parseSubstituteArgs(%q) should return %q, %q, %v, %v but got %q, %q, %v, %v",
+				tc.arg, tc.pattern, tc.replacement, tc.global, tc.confirm,
+				pattern, replacement, global, confirm)
+		}
+	}
+}
+
+func TestWindowSubstitute(t *testing.T) {
+	window, err := newWindow(strings.NewReader("foo bar foo baz foo\n"), "test", "test", make(chan struct{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	window.setSize(16, 10)
+	r := &event.Range{From: event.Absolute{Offset: 0}, To: event.End{}}
+
+	msg, err := window.substitute(r, []byte("foo"), []byte("x"), false, false)
+	if err != nil {
+		t.Fatalf("err should be nil but got: %v", err)
+	}
+	if msg != "1 substitution" {
+		t.Errorf("message should be %q but got %q", "1 substitution", msg)
+	}
+	if bs, _ := window.readFull(0, window.length); string(bs) != "x bar foo baz foo\n" {
+		t.Errorf("bytes should be %q but got %q", "x bar foo baz foo\n", bs)
+	}
+
+	msg, err = window.substitute(r, []byte("ba"), []byte("BAA"), true, false)
+	if err != nil {
+		t.Fatalf("err should be nil but got: %v", err)
+	}
+	if msg != "2 substitutions" {
+		t.Errorf("message should be %q but got %q", "2 substitutions", msg)
+	}
+	if bs, _ := window.readFull(0, window.length); string(bs) != "x BAAr foo BAAz foo\n" {
+		t.Errorf("bytes should be %q but got %q", "x BAAr foo BAAz foo\n", bs)
+	}
+
+	window.undo(1)
+	if bs, _ := window.readFull(0, window.length); string(bs) != "x bar foo baz foo\n" {
+		t.Errorf("bytes should be %q but got %q", "x bar foo baz foo\n", bs)
+	}
+
+	if _, err = window.substitute(r, []byte("qux"), nil, true, false); err == nil ||
+		err.Error() != `pattern not found: "qux"` {
+		t.Errorf("err should be %q but got: %v", `pattern not found: "qux"`, err)
+	}
+
+	window.cursorGotoPos(event.Absolute{Offset: 0})
+	if _, err = window.substitute(nil, []byte("foo"), []byte("F"), true, false); err != nil {
+		t.Fatalf("err should be nil but got: %v", err)
+	}
+	if bs, _ := window.readFull(0, window.length); string(bs) != "x bar F baz F\n" {
+		t.Errorf("the match starting in the row should be replaced but got %q", bs)
+	}
+
+	window, err = newWindow(strings.NewReader("foo bar\n"), "test", "test", make(chan struct{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = window.substitute(nil, []byte("foo"), []byte("F"), true, false); err != nil {
+		t.Fatalf("err should be nil but got: %v", err)
+	}
+	if bs, _ := window.readFull(0, window.length); string(bs) != "F bar\n" {
+		t.Errorf("bytes should be %q but got %q", "F bar\n", bs)
+	}
+}
+
+func TestWindowSubstituteConfirm(t *testing.T) {
+	window, err := newWindow(strings.NewReader("foo bar foo baz foo foo\n"), "test", "test", make(chan struct{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	window.setSize(16, 10)
+	r := &event.Range{From: event.Absolute{Offset: 0}, To: event.End{}}
+
+	msg, err := window.substitute(r, []byte("foo"), []byte("quux"), true, true)
+	if err != nil {
+		t.Fatalf("err should be nil but got: %v", err)
+	}
+	if expected := `replace with "quux" (y/n/a/q/l)?`; msg != expected {
+		t.Errorf("message should be %q but got %q", expected, msg)
+	}
+	if window.substitution == nil || window.cursor != 0 || window.modified() {
+		t.Fatalf("the first match should be confirmed")
+	}
+	s, _ := window.state()
+	if len(s.Highlights) != 1 || s.Highlights[0].From != 0 || s.Highlights[0].To != 2 {
+		t.Errorf("the match should be highlighted but got: %+v", s.Highlights)
+	}
+
+	for _, key := range []rune{'y', 'x', 'n'} {
+		if _, err := window.confirmSubstitute(key); err != nil {
+			t.Fatalf("err should be nil but got: %v", err)
+		}
+	}
+	if window.substitution == nil || window.cursor != 17 {
+		t.Errorf("cursor should be 17 but got %d", window.cursor)
+	}
+	if msg, err = window.confirmSubstitute('a'); err != nil {
+		t.Fatalf("err should be nil but got: %v", err)
+	}
+	if msg != "3 substitutions" || window.substitution != nil {
+		t.Errorf("message should be %q but got %q", "3 substitutions", msg)
+	}
+	if bs, _ := window.readFull(0, window.length); string(bs) != "quux bar foo baz quux quux\n" {
+		t.Errorf("bytes should be %q but got %q", "quux bar foo baz quux quux\n", bs)
+	}
+
+	window.undo(1)
+	if bs, _ := window.readFull(0, window.length); string(bs) != "foo bar foo baz foo foo\n" {
+		t.Errorf("replacements should be undone at once but got %q", bs)
+	}
+
+	if _, err = window.substitute(r, []byte("foo"), nil, true, true); err != nil {
+		t.Fatalf("err should be nil but got: %v", err)
+	}
+	if msg, err = window.confirmSubstitute('q'); err != nil {
+		t.Fatalf("err should be nil but got: %v", err)
+	}
+	if msg != "0 substitutions" || window.substitution != nil {
+		t.Errorf("substitution should be quit but got %q", msg)
+	}
+	if bs, _ := window.readFull(0, window.length); string(bs) != "foo bar foo baz foo foo\n" {
+		t.Errorf("bytes should not be changed but got %q", bs)
+	}
+}
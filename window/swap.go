@@ -0,0 +1,299 @@
+package window
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/itchyny/bed/buffer"
+	"github.com/itchyny/bed/mathutil"
+)
+
+// swap is the journal of the unsaved changes, which is stored next to the
+// edited file to recover the changes after a crash.
+type swap struct {
+	Size     int64         `json:"size"`
+	ModTime  int64         `json:"mtime"`
+	Segments []swapSegment `json:"segments"`
+}
+
+// swapSegment refers to the file at the offset or consists of the bytes. The
+// inserted bytes spooled to the temporary file refer to the spool file.
+type swapSegment struct {
+	Offset int64  `json:"offset,omitempty"`
+	Length int64  `json:"length"`
+	Bytes  []byte `json:"bytes,omitempty"`
+	Spool  string `json:"spool,omitempty"`
+}
+
+// journal holds the state of the swap file of the window.
+type journal struct {
+	path    string
+	size    int64
+	modTime int64
+	reader  readAtSeeker
+	disk    *buffer.Buffer
+	found   bool
+	dirty   bool
+	saved   time.Time
+}
+
+func swapPath(filename string) string {
+	return filename + ".bedswp"
+}
+
+// newJournal creates the journal of the file, which is described by the file
+// info (nil for a new file). The swap file which already exists is left for
+// recovery, and the journal is not written until the changes are recovered.
+func newJournal(filename string, info os.FileInfo, r readAtSeeker) *journal {
+	j := &journal{path: swapPath(filename), reader: r}
+	j.setFileInfo(info)
+	if _, err := os.Stat(j.path); err == nil {
+		j.found = true
+	}
+	return j
+}
+
+func (j *journal) setFileInfo(info os.FileInfo) {
+	j.size, j.modTime = 0, 0
+	if info != nil {
+		j.size, j.modTime = info.Size(), info.ModTime().UnixNano()
+	}
+}
+
+func (j *journal) notice() error {
+	return fmt.Errorf("swap file found: %s (use :recover to restore the unsaved changes)", j.path)
+}
+
+// journaling reports whether the window writes the changes to the swap file.
+func (w *window) journaling(path string) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.swap != nil && w.swap.path == path && !w.swap.found
+}
+
+// swapInterval is the minimum interval of writing the swap file on the
+// changes. The changes in the interval are written by the watcher of the
+// manager, so that the journal is not written on every keystroke.
+const swapInterval = watchInterval
+
+// changedSwap records the changes to the journal. The swap file is written
+// on the change after the interval unless the autosave timer persists the
+// journal periodically.
+func (w *window) changedSwap() {
+	if w.swap == nil {
+		return
+	}
+	w.swap.dirty = true
+	if w.global.Duration("autosave") == 0 && time.Since(w.swap.saved) >= swapInterval {
+		_ = w.saveSwap()
+	}
+}
+
+// autosaveSwap writes the swap file if the journal has the changes which are
+// not written yet. This is called periodically by the watcher of the manager.
+func (w *window) autosaveSwap() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.swap == nil || !w.swap.dirty {
+		return nil
+	}
+	return w.saveSwap()
+}
+
+// saveSwap writes the unsaved changes to the swap file.
+func (w *window) saveSwap() error {
+	if w.swap == nil || w.swap.found {
+		return nil
+	}
+	if !w.modified() || !w.options.Bool("swapfile") {
+		w.swap.dirty = false
+		return saveSidecar(w.swap.path, nil, true)
+	}
+	segments, err := w.swapSegments()
+	if err != nil {
+		return err
+	}
+	s := swap{Size: w.swap.size, ModTime: w.swap.modTime, Segments: make([]swapSegment, len(segments))}
+	for i, seg := range segments {
+		s.Segments[i] = swapSegment{Offset: seg.Offset, Length: seg.Length, Bytes: seg.Bytes}
+		if seg.Spool != nil {
+			f, ok := seg.Spool.(*os.File)
+			if !ok {
+				return errors.New("spooled bytes without the file")
+			}
+			s.Segments[i].Spool = f.Name()
+		}
+	}
+	if err := saveSidecar(w.swap.path, s, false); err != nil {
+		return err
+	}
+	w.swap.dirty, w.swap.saved = false, time.Now()
+	return nil
+}
+
+// swapSegments returns the segments of the buffer which refer to the file on
+// disk. When the file is replaced on saving, the file has the contents of the
+// saved buffer, and the segments of the original reader are rebased onto it.
+func (w *window) swapSegments() ([]buffer.Segment, error) {
+	segments, err := w.buffer.Segments()
+	if err != nil || w.swap.disk == nil {
+		return segments, err
+	}
+	disk, err := w.swap.disk.Segments()
+	if err != nil {
+		return nil, err
+	}
+	var rebased []buffer.Segment
+	var offset int64
+	for _, s := range segments {
+		if s.Bytes != nil || s.Spool != nil {
+			rebased = append(rebased, s)
+			offset += s.Length
+			continue
+		}
+		for from, to := s.Offset, s.Offset+s.Length; from < to; {
+			seg, end := rebaseSegment(disk, from, to)
+			if seg.Bytes != nil {
+				if _, err := w.buffer.ReadAt(seg.Bytes, offset+from-s.Offset); err != nil {
+					return nil, err
+				}
+			}
+			rebased = append(rebased, seg)
+			from = end
+		}
+		offset += s.Length
+	}
+	return rebased, nil
+}
+
+// rebaseSegment finds the position of the original bytes from the offset in
+// the file on disk, or returns the segment to hold the bytes if not found.
+func rebaseSegment(disk []buffer.Segment, from, to int64) (buffer.Segment, int64) {
+	var offset int64
+	for _, d := range disk {
+		if d.Bytes == nil && d.Spool == nil && d.Offset <= from && from < d.Offset+d.Length {
+			end := mathutil.MinInt64(to, d.Offset+d.Length)
+			return buffer.Segment{Offset: offset + from - d.Offset, Length: end - from}, end
+		}
+		offset += d.Length
+	}
+	end := to
+	for _, d := range disk {
+		if d.Bytes == nil && d.Spool == nil && from < d.Offset && d.Offset < end {
+			end = d.Offset
+		}
+	}
+	return buffer.Segment{Length: end - from, Bytes: make([]byte, end-from)}, end
+}
+
+// savedSwap updates the journal after saving the file, which is replaced or
+// overwritten in place. The swap file is removed since there are no changes.
+func (w *window) savedSwap(info os.FileInfo, replaced bool) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.swap == nil {
+		return nil
+	}
+	w.swap.setFileInfo(info)
+	if replaced {
+		w.swap.disk = w.savedBuffer
+	}
+	return w.saveSwap()
+}
+
+// removeSwap removes the swap file and stops journaling, on closing or
+// discarding the changes of the window.
+func (w *window) removeSwap() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.swap == nil || w.swap.found {
+		return nil
+	}
+	path := w.swap.path
+	w.swap = nil
+	return saveSidecar(path, nil, true)
+}
+
+// rescueSwap writes the journal to the swap file on the crash of the editor,
+// and stops journaling so the swap file and the spool files referred by it are
+// kept on closing the window. It returns the file name and reports whether the
+// swap file has the changes.
+func (w *window) rescueSwap() (string, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.swap == nil || w.swap.found || !w.modified() || !w.options.Bool("swapfile") {
+		return "", false
+	}
+	if w.swap.dirty {
+		if err := w.saveSwap(); err != nil {
+			return "", false
+		}
+	}
+	w.swap, w.spools = nil, nil
+	return w.filename, true
+}
+
+// recoverSwap restores the changes from the swap file.
+func (w *window) recoverSwap() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.swap == nil {
+		return errors.New("no swap file")
+	}
+	if w.swap.disk != nil || w.modified() {
+		return errors.New("cannot recover after making changes")
+	}
+	var s swap
+	if err := loadSidecar(w.swap.path, &s); err != nil {
+		return err
+	}
+	if s.Segments == nil {
+		return fmt.Errorf("swap file not found: %s", w.swap.path)
+	}
+	if s.Size != w.swap.size || s.ModTime != w.swap.modTime {
+		return fmt.Errorf("file has changed since the swap file was written: %s", w.swap.path)
+	}
+	segments := make([]buffer.Segment, len(s.Segments))
+	spools := make(map[string]*os.File)
+	closeSpools := func() {
+		for _, f := range spools {
+			f.Close()
+		}
+	}
+	for i, seg := range s.Segments {
+		segments[i] = buffer.Segment{Offset: seg.Offset, Length: seg.Length, Bytes: seg.Bytes}
+		if seg.Spool == "" {
+			continue
+		}
+		f, ok := spools[seg.Spool]
+		if !ok {
+			var err error
+			if f, err = os.Open(seg.Spool); err != nil {
+				closeSpools()
+				return fmt.Errorf("spool file of the swap file not found: %s", seg.Spool)
+			}
+			spools[seg.Spool] = f
+		}
+		segments[i].Spool = f
+	}
+	b, err := buffer.Restore(w.swap.reader, segments)
+	if err != nil {
+		closeSpools()
+		return err
+	}
+	for _, f := range spools {
+		w.spools = append(w.spools, f)
+	}
+	if w.length, err = b.Len(); err != nil {
+		return err
+	}
+	w.buffer, w.swap.found = b, false
+	w.pushHistory(w.offset, w.cursor)
+	if w.width > 0 {
+		w.restorePosition(position{w.cursor, w.offset})
+	}
+	w.changedTick++
+	return nil
+}
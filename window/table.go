@@ -0,0 +1,42 @@
+package window
+
+import (
+	"github.com/itchyny/bed/mathutil"
+	"github.com/itchyny/bed/schema"
+	"github.com/itchyny/bed/state"
+)
+
+// table returns the schema and the size of the records of the table view, or
+// zero size when the table view is off. The size is the recordsize option, or
+// the size of the schema when the option is not set.
+func (w *window) table() (schema.Schema, int64) {
+	if !w.options.Bool("table") {
+		return nil, 0
+	}
+	s, err := schema.Parse(w.options.String("schema"))
+	if err != nil {
+		s = nil
+	}
+	size := int64(w.options.Int("recordsize"))
+	if size == 0 {
+		size = int64(s.Size())
+	}
+	return s, size
+}
+
+// tableRecords decodes the fields of the records in the window state, which
+// are displayed one record per row.
+func tableRecords(fields schema.Schema, s *state.WindowState) [][]state.Field {
+	var records [][]state.Field
+	for i := 0; i < s.Size; i += s.Width {
+		record := s.Bytes[i:mathutil.MinInt(i+s.Width, s.Size)]
+		fs := make([]state.Field, 0, len(fields))
+		for _, f := range fields {
+			if text := f.Format(record); text != "" {
+				fs = append(fs, state.Field{Text: text, Offset: f.Offset, Size: f.Type.Size})
+			}
+		}
+		records = append(records, fs)
+	}
+	return records
+}
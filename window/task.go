@@ -0,0 +1,122 @@
+package window
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/itchyny/bed/event"
+)
+
+// progressInterval is the interval of reporting the progress of the task.
+const progressInterval = 200 * time.Millisecond
+
+var errCanceled = errors.New("canceled")
+
+// task is the long operation running in the background, which reports the
+// progress to the message line, and is canceled by <C-c>.
+type task struct {
+	name     string
+	total    int64
+	done     int64
+	canceled int32
+	then     func() error
+}
+
+// check reports the progress, and returns errCanceled when the task is
+// canceled. The nil task is never canceled.
+func (t *task) check(done int64) error {
+	if t == nil {
+		return nil
+	}
+	if atomic.LoadInt32(&t.canceled) != 0 {
+		return errCanceled
+	}
+	atomic.StoreInt64(&t.done, done)
+	return nil
+}
+
+func (t *task) message() string {
+	var percent int64
+	if t.total > 0 {
+		percent = atomic.LoadInt64(&t.done) * 100 / t.total
+	}
+	return fmt.Sprintf("%s... %d%% (<C-c> to cancel)", t.name, percent)
+}
+
+// startTask runs the function in the background on the snapshot of the
+// buffer, and then the returned function in the event loop to update the
+// windows with the result. Only one task runs at once.
+func (m *Manager) startTask(name string, total int64, f func(*task) (func() error, error)) error {
+	t := &task{name: name, total: total}
+	m.mu.Lock()
+	if m.task != nil {
+		m.mu.Unlock()
+		return fmt.Errorf("%s is running", m.task.name)
+	}
+	m.task = t
+	m.mu.Unlock()
+	doneCh := make(chan struct{})
+	go func() {
+		defer close(doneCh)
+		defer event.RecoverPanic(m.eventCh, m.doneCh)
+		then, err := f(t)
+		if err != nil {
+			then = func() error { return err }
+		}
+		t.then = then
+	}()
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		defer event.RecoverPanic(m.eventCh, m.doneCh)
+		ticker := time.NewTicker(progressInterval)
+		defer ticker.Stop()
+		for {
+			e := event.Event{Type: event.Info}
+			select {
+			case <-ticker.C:
+				e.Error = errors.New(t.message())
+			case <-doneCh:
+				e.Type = event.FinishTask
+			case <-m.doneCh:
+				return
+			}
+			select {
+			case m.eventCh <- e:
+			case <-m.doneCh:
+				return
+			}
+			if e.Type == event.FinishTask {
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+// finishTask updates the windows with the result of the finished task.
+func (m *Manager) finishTask() error {
+	m.mu.Lock()
+	t := m.task
+	m.task = nil
+	m.mu.Unlock()
+	if t == nil || t.then == nil {
+		return nil
+	}
+	if err := t.then(); err != errCanceled {
+		return err
+	}
+	m.eventCh <- event.Event{Type: event.Info, Error: fmt.Errorf("%s canceled", t.name)}
+	return nil
+}
+
+// cancelTask cancels the running task.
+func (m *Manager) cancelTask() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.task != nil {
+		atomic.StoreInt32(&m.task.canceled, 1)
+	}
+}
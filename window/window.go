@@ -3,9 +3,13 @@ package window
 import (
 	"bytes"
 	"errors"
+	"fmt"
 	"io"
+	"os"
+	"sort"
 	"strconv"
 	"sync"
+	"time"
 	"unicode/utf8"
 
 	"github.com/itchyny/bed/buffer"
@@ -13,32 +17,80 @@ import (
 	"github.com/itchyny/bed/history"
 	"github.com/itchyny/bed/mathutil"
 	"github.com/itchyny/bed/mode"
+	"github.com/itchyny/bed/option"
 	"github.com/itchyny/bed/state"
 )
 
 type window struct {
-	buffer      *buffer.Buffer
-	changedTick uint64
-	prevChanged bool
-	history     *history.History
-	filename    string
-	name        string
-	height      int64
-	width       int64
-	offset      int64
-	cursor      int64
-	length      int64
-	stack       []position
-	append      bool
-	replaceByte bool
-	extending   bool
-	pending     bool
-	pendingByte byte
-	visualStart int64
-	focusText   bool
-	redrawCh    chan<- struct{}
-	eventCh     chan event.Event
-	mu          *sync.Mutex
+	*content
+	prevChanged  bool
+	filename     string
+	name         string
+	height       int64
+	width        int64
+	offset       int64
+	cursor       int64
+	lastOffset   int64
+	jumps        []position
+	jumpIndex    int
+	marks        map[rune]position
+	options      *option.Options
+	global       *option.Options
+	append       bool
+	replaceByte  bool
+	extending    bool
+	pending      bool
+	pendingByte  byte
+	literal      *literal
+	lowNibble    bool
+	nibbleByte   bool
+	visualStart  int64
+	visualBlock  bool
+	checksum     *selectionChecksum
+	sparse       *sparseRuns
+	substitution *substitution
+	overtypes    []overtype
+	overtypeTick uint64
+	overlay      []*overlayPatch
+	focusText    bool
+	disasmCount  int
+	insertCount  int64
+	insertFrom   int64
+	states       [2]state.WindowState
+	stateIndex   int
+	savedBytes   []byte
+	redrawCh     chan<- struct{}
+	eventCh      chan event.Event
+	doneCh       <-chan struct{}
+}
+
+// content is the buffer of the window with the history, the bookmarks and the
+// annotations, which is shared by the views of the window.
+type content struct {
+	buffer       *buffer.Buffer
+	savedBuffer  *buffer.Buffer
+	changedTick  uint64
+	savedTick    uint64
+	history      *history.History
+	undoMessage  string
+	lastEdit     event.Type
+	lastEditTime time.Time
+	length       int64
+	swap         *journal
+	codec        *codec
+	compressed   *codec
+	codecErr     error
+	stream       *stream
+	spools       []*os.File
+	bookmarks    []bookmark
+	annotations  []state.Annotation
+	marksMoved   bool
+	marksErr     error
+	notesErr     error
+	labels       []state.Label
+	labelsTick   uint64
+	prefetcher   prefetcher
+	mu           *sync.Mutex
 }
 
 type position struct {
@@ -59,23 +111,79 @@ func newWindow(r readAtSeeker, filename string, name string, redrawCh chan<- str
 	}
 	history := history.NewHistory()
 	history.Push(buffer, 0, 0)
+	prefetcher, _ := r.(prefetcher)
 	return &window{
-		buffer:      buffer,
-		history:     history,
+		content: &content{
+			buffer:      buffer,
+			savedBuffer: buffer.Clone(),
+			history:     history,
+			length:      length,
+			prefetcher:  prefetcher,
+			mu:          new(sync.Mutex),
+		},
 		filename:    filename,
 		name:        name,
-		length:      length,
 		visualStart: -1,
+		marks:       make(map[rune]position),
+		options:     option.New(),
 		redrawCh:    redrawCh,
 		eventCh:     make(chan event.Event),
-		mu:          new(sync.Mutex),
 	}, nil
 }
 
+// view creates a new window of the same buffer, which keeps the cursor and
+// the offset independently, and the edits appear in both windows.
+func (w *window) view() *window {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	marks := make(map[rune]position, len(w.marks))
+	for c, pos := range w.marks {
+		marks[c] = pos
+	}
+	return &window{
+		content:     w.content,
+		filename:    w.filename,
+		name:        w.name,
+		height:      w.height,
+		width:       w.width,
+		offset:      w.offset,
+		cursor:      w.cursor,
+		lastOffset:  w.offset,
+		marks:       marks,
+		visualStart: -1,
+		options:     w.options.Clone(),
+		global:      w.global,
+		redrawCh:    w.redrawCh,
+		eventCh:     make(chan event.Event),
+	}
+}
+
+// inherit takes over the positions of the window of the same file on reload;
+// the cursor, the offset, the marks, the jumps, and the local options. When
+// the file is shortened and the cursor is moved into the new length, the
+// position before the reload is pushed to the jumps to get back with <C-o>.
+func (w *window) inherit(old *window) {
+	old.mu.Lock()
+	defer old.mu.Unlock()
+	for c, pos := range old.marks {
+		w.marks[c] = pos
+	}
+	w.jumps, w.jumpIndex = append([]position(nil), old.jumps...), old.jumpIndex
+	w.options, w.focusText = old.options.Clone(), old.focusText
+	if old.width > 0 {
+		w.width, w.height = old.width, old.height
+		pos := position{old.cursor, old.offset}
+		if w.restorePosition(pos); w.cursor != pos.cursor {
+			w.pushJump(pos)
+		}
+	}
+}
+
 func (w *window) setSize(width, height int) {
 	w.mu.Lock()
 	defer w.mu.Unlock()
 	w.width, w.height = int64(width), int64(height)
+	w.fitCursor()
 	w.offset = w.offset / w.width * w.width
 	if w.cursor >= w.offset+w.height*w.width {
 		w.offset = (w.cursor - w.height*w.width + w.width) / w.width * w.width
@@ -86,19 +194,48 @@ func (w *window) setSize(width, height int) {
 	)
 }
 
+// fitCursor moves the cursor within the buffer, which may be shortened in the
+// other views of the buffer.
+func (w *window) fitCursor() {
+	if w.cursor > w.length {
+		w.cursor = mathutil.MaxInt64(w.length, 1) - 1
+	}
+}
+
 func (w *window) run() {
+	defer func() {
+		// the event is handled with the lock, which should be released for
+		// the editor to keep the changes in the swap file
+		if r := recover(); r != nil {
+			w.mu.Unlock()
+			panic(r)
+		}
+	}()
 	for e := range w.eventCh {
 		w.mu.Lock()
+		w.readStream(e)
+		w.fitCursor()
 		offset, cursor, changedTick := w.offset, w.cursor, w.changedTick
+		if w.literal != nil && e.Type != event.Rune && e.Type != event.Backspace {
+			w.finishLiteral()
+		}
 		switch e.Type {
 		case event.CursorUp:
 			w.cursorUp(e.Count)
 		case event.CursorDown:
 			w.cursorDown(e.Count)
 		case event.CursorLeft:
-			w.cursorLeft(e.Count)
+			if w.nibbleMode(e.Mode) {
+				w.nibbleLeft(e.Count)
+			} else {
+				w.cursorLeft(e.Count)
+			}
 		case event.CursorRight:
-			w.cursorRight(e.Mode, e.Count)
+			if w.nibbleMode(e.Mode) {
+				w.nibbleRight(e.Count)
+			} else {
+				w.cursorRight(e.Mode, e.Count)
+			}
 		case event.CursorPrev:
 			w.cursorPrev(e.Count)
 		case event.CursorNext:
@@ -113,22 +250,56 @@ func (w *window) run() {
 			w.scrollUp(e.Count)
 		case event.ScrollDown:
 			w.scrollDown(e.Count)
+		case event.ScrollCursorCenter:
+			w.scrollCursor(w.height / 2)
+		case event.ScrollCursorTop:
+			w.scrollCursor(0)
+		case event.ScrollCursorBottom:
+			w.scrollCursor(w.height - 1)
 		case event.PageUp:
-			w.pageUp()
+			w.pageUp(e.Count)
 		case event.PageDown:
-			w.pageDown()
+			w.pageDown(e.Count)
 		case event.PageUpHalf:
-			w.pageUpHalf()
+			w.pageUpHalf(e.Count)
 		case event.PageDownHalf:
-			w.pageDownHalf()
+			w.pageDownHalf(e.Count)
 		case event.PageTop:
-			w.pageTop()
+			w.pageTop(e.Count)
 		case event.PageEnd:
-			w.pageEnd()
+			w.pageEnd(e.Count)
+		case event.GotoPercent:
+			w.gotoPercent(e.Count)
 		case event.JumpTo:
 			w.jumpTo()
+		case event.JumpToPointer:
+			w.jumpToPointer(e.Count, e.Rune == 'F')
+		case event.JumpForward:
+			w.jumpForward(e.Count)
+		case event.GotoMark:
+			w.gotoMark(e.Rune, false)
+		case event.GotoMarkLine:
+			w.gotoMark(e.Rune, true)
+		case event.NextChange:
+			w.nextChange(e.Count)
+		case event.PreviousChange:
+			w.previousChange(e.Count)
+		case event.NextString:
+			w.nextString(e.Count)
+		case event.PreviousString:
+			w.previousString(e.Count)
+		case event.NextData:
+			w.nextData(e.Count)
+		case event.PreviousData:
+			w.previousData(e.Count)
+		case event.NextPacket:
+			w.nextPacket(e.Count)
+		case event.PreviousPacket:
+			w.previousPacket(e.Count)
 		case event.JumpBack:
-			w.jumpBack()
+			w.jumpBack(e.Count)
+		case event.SetMark:
+			w.setMark(e.Rune)
 
 		case event.DeleteByte:
 			w.deleteByte(e.Count)
@@ -138,15 +309,27 @@ func (w *window) run() {
 			w.increment(e.Count)
 		case event.Decrement:
 			w.decrement(e.Count)
+		case event.InsertBytes:
+			w.insertBytes(e.Count, e.Bytes)
+		case event.Paste, event.PasteBefore:
+			w.paste(e)
+		case event.InsertExpression:
+			w.insertExpression(e.Mode, e.Bytes)
+		case event.PasteText:
+			w.pasteText(e.Mode, e.Bytes, offset, cursor)
 
 		case event.StartInsert:
 			w.startInsert()
+			w.insertCount, w.insertFrom = e.Count, w.cursor
 		case event.StartInsertHead:
 			w.startInsertHead()
+			w.insertCount, w.insertFrom = e.Count, w.cursor
 		case event.StartAppend:
 			w.startAppend()
+			w.insertCount, w.insertFrom = e.Count, w.cursor
 		case event.StartAppendEnd:
 			w.startAppendEnd()
+			w.insertCount, w.insertFrom = e.Count, w.cursor
 		case event.StartReplaceByte:
 			w.startReplaceByte()
 		case event.StartReplace:
@@ -155,23 +338,35 @@ func (w *window) run() {
 			w.exitInsert()
 		case event.Rune:
 			w.insertRune(e.Mode, e.Rune)
+		case event.StartLiteral:
+			w.startLiteral(e.Mode)
 		case event.Backspace:
-			w.backspace()
+			w.backspace(e.Mode)
 		case event.Delete:
 			w.deleteByte(1)
 		case event.StartVisual:
-			w.startVisual()
+			w.startVisual(false)
+		case event.StartVisualBlock:
+			w.startVisual(true)
 		case event.SwitchVisualEnd:
 			w.switchVisualEnd()
+		case event.SwitchVisualBlock:
+			w.visualBlock = !w.visualBlock
 		case event.ExitVisual:
 			w.exitVisual()
+		case event.ReplaceVisual:
+			w.replaceVisual(e.Bytes)
+		case event.Fill:
+			w.fill(e.Range, e.Bytes)
 		case event.SwitchFocus:
 			w.focusText = !w.focusText
 			if w.pending {
 				w.pending = false
 				w.pendingByte = '\x00'
 			}
-			w.changedTick++
+			w.nibbleByte = false
+		case event.UndoPartial:
+			w.undoPartial(e.Count)
 		case event.Undo:
 			if e.Mode != mode.Normal {
 				panic("event.Undo should be emitted under normal mode")
@@ -192,18 +387,42 @@ func (w *window) run() {
 			w.mu.Unlock()
 			continue
 		}
-		changed := changedTick != w.changedTick
+		if w.cursor != cursor && !(w.nibbleMode(e.Mode) &&
+			event.CursorUp <= e.Type && e.Type <= event.CursorRight) {
+			w.lowNibble = false
+		}
+		if isJump(e.Type) && w.cursor != cursor &&
+			e.Mode != mode.Insert && e.Mode != mode.Replace {
+			w.pushJump(position{cursor, offset})
+		}
+		// switching the focus ends the edit like the changes, to push the
+		// pending edits to the history
+		changed := changedTick != w.changedTick || e.Type == event.SwitchFocus
 		if e.Type != event.Undo && e.Type != event.Redo {
-			if e.Mode == mode.Normal && changed || e.Type == event.ExitInsert && w.prevChanged {
-				w.history.Push(w.buffer, w.offset, w.cursor)
+			if e.Mode == mode.Normal && changed {
+				w.pushEdit(e.Type)
+				w.changedSwap()
+			} else if e.Type == event.PasteText && changed {
+				w.pushHistory(w.offset, w.cursor)
+				w.changedSwap()
+				changed = false
+			} else if e.Type == event.ExitInsert && w.prevChanged {
+				w.pushHistory(w.offset, w.cursor)
+				w.changedSwap()
 			} else if e.Mode != mode.Normal && w.prevChanged && !changed &&
 				event.CursorUp <= e.Type && e.Type <= event.JumpBack {
-				w.history.Push(w.buffer, offset, cursor)
+				w.pushHistory(offset, cursor)
+				w.changedSwap()
 			}
+		} else {
+			w.changedSwap()
 		}
 		w.prevChanged = changed
 		w.mu.Unlock()
-		w.redrawCh <- struct{}{}
+		select {
+		case w.redrawCh <- struct{}{}:
+		case <-w.doneCh:
+		}
 	}
 }
 
@@ -242,6 +461,56 @@ func (w *window) writeTo(r *event.Range, dst io.Writer) (int64, error) {
 	return io.Copy(dst, io.LimitReader(w.buffer, to-from+1))
 }
 
+func (w *window) writableInPlace(size int64) (bool, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.length != size {
+		return false, nil
+	}
+	shifted, err := w.buffer.Shifted()
+	return !shifted, err
+}
+
+// writeChangedTo writes only the changed ranges of the buffer to dst in place,
+// which should be the original reader of the buffer. The undo history is reset
+// because the previous buffers read the overwritten bytes.
+func (w *window) writeChangedTo(dst io.WriterAt, size int64) (int64, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.length != size {
+		return 0, errors.New("cannot change the size in place")
+	}
+	if shifted, err := w.buffer.Shifted(); err != nil {
+		return 0, err
+	} else if shifted {
+		return 0, errors.New("cannot write inserted or deleted bytes in place")
+	}
+	w.history = history.NewHistory()
+	w.history.Push(w.buffer, w.offset, w.cursor)
+	rs, err := w.buffer.DirtyRanges()
+	if err != nil {
+		return 0, err
+	}
+	var n int64
+	buf := make([]byte, 1<<20)
+	for i := 0; i < len(rs); i += 2 {
+		for from := rs[i]; from < rs[i+1]; {
+			k, err := w.buffer.ReadAt(buf[:mathutil.MinInt64(rs[i+1]-from, int64(len(buf)))], from)
+			if k == 0 {
+				if err == nil {
+					err = io.ErrUnexpectedEOF
+				}
+				return n, err
+			}
+			if k, err = dst.WriteAt(buf[:k], from); err != nil {
+				return n, err
+			}
+			n, from = n+int64(k), from+int64(k)
+		}
+	}
+	return n, nil
+}
+
 func (w *window) positionToOffset(pos event.Position) (int64, error) {
 	switch pos := pos.(type) {
 	case event.Absolute:
@@ -254,6 +523,12 @@ func (w *window) positionToOffset(pos event.Position) (int64, error) {
 			mathutil.MinInt64(pos.Offset, mathutil.MaxInt64(w.length, 1)-1-w.cursor),
 			-w.cursor,
 		), nil
+	case event.Percent:
+		percent := mathutil.MaxInt64(mathutil.MinInt64(pos.Percent, 100), 0)
+		return mathutil.MaxInt64(
+			mathutil.MinInt64(w.length*percent/100+pos.Offset, mathutil.MaxInt64(w.length, 1)-1),
+			0,
+		), nil
 	case event.End:
 		return mathutil.MaxInt64(w.length, 1) - 1 + mathutil.MaxInt64(
 			mathutil.MinInt64(pos.Offset, 0),
@@ -281,32 +556,123 @@ func (w *window) positionToOffset(pos event.Position) (int64, error) {
 	}
 }
 
+// state returns the state of the window. The two states are used
+// alternately to reuse the bytes on redraw, so the state is valid until the
+// second next call.
 func (w *window) state() (*state.WindowState, error) {
 	w.mu.Lock()
 	defer w.mu.Unlock()
-	n, bytes, err := w.readBytes(w.offset, int(w.height*w.width))
+	w.stateIndex ^= 1
+	s := &w.states[w.stateIndex]
+	size := int(w.height * w.width)
+	if cap(s.Bytes) < size {
+		s.Bytes = make([]byte, size)
+	}
+	bytes := s.Bytes[:size]
+	segments := [][2]int64{{w.offset, int64(size)}}
+	var skips []state.Skip
+	if w.options.Bool("sparse") {
+		segments, skips = w.sparseSegments()
+	}
+	var n int
+	var err error
+	for i, seg := range segments {
+		var m int
+		m, err = w.buffer.ReadAt(bytes[n:n+int(seg[1])], seg[0])
+		if n += m; m < int(seg[1]) {
+			segments = segments[:i+1]
+			segments[i][1] = int64(m)
+			break
+		}
+	}
+	if w.stream != nil {
+		w.length, _ = w.buffer.Len()
+	}
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	w.prefetchAhead(int64(size))
+	for i := n; i < size; i++ {
+		bytes[i] = 0
+	}
+	eis := s.EditedIndices[:0]
+	if eis == nil {
+		eis = []int64{}
+	}
+	uis, edited, k := s.UnsavedIndices[:0], w.buffer.EditedIndices(), 0
+	nibble := w.options.Bool("nibble") && !w.focusText
+	var ens, uns []int64
+	for _, seg := range segments {
+		if eis, err = w.buffer.AppendDirtyRangesIn(eis, seg[0], seg[0]+seg[1]); err != nil {
+			return nil, err
+		}
+		if uis, err = w.unsavedIndices(uis, edited, seg[0], bytes[k:k+int(seg[1])]); err != nil {
+			return nil, err
+		}
+		if nibble {
+			if ens, err = w.buffer.AppendDirtyNibblesIn(ens, seg[0], seg[0]+seg[1]); err != nil {
+				return nil, err
+			}
+			if uns, err = w.unsavedNibbles(uns, uis, seg[0], bytes[k:k+int(seg[1])]); err != nil {
+				return nil, err
+			}
+		}
+		k += int(seg[1])
+	}
+	if len(uis) == 0 {
+		uis = nil
+	}
+	selection, err := w.selection()
 	if err != nil {
 		return nil, err
 	}
-	return &state.WindowState{
-		Name:          w.name,
-		Width:         int(w.width),
-		Offset:        w.offset,
-		Cursor:        w.cursor,
-		Bytes:         bytes,
-		Size:          n,
-		Length:        w.length,
-		Pending:       w.pending,
-		PendingByte:   w.pendingByte,
-		VisualStart:   w.visualStart,
-		EditedIndices: w.buffer.EditedIndices(),
-		FocusText:     w.focusText,
-	}, nil
+	*s = state.WindowState{
+		Name:           w.name,
+		Width:          int(w.width),
+		Offset:         w.offset,
+		Cursor:         w.cursor,
+		Bytes:          bytes,
+		Size:           n,
+		Length:         w.length,
+		LengthUnknown:  w.stream != nil && !w.stream.done(),
+		Modified:       w.modified(),
+		Pending:        w.pending,
+		PendingByte:    w.pendingByte,
+		Literal:        w.literalString(),
+		Nibble:         nibble,
+		LowNibble:      nibble && w.lowNibble,
+		VisualStart:    w.visualStart,
+		VisualBlock:    w.visualBlock,
+		Selection:      selection,
+		EditedIndices:  eis,
+		UnsavedIndices: uis,
+		EditedNibbles:  ens,
+		UnsavedNibbles: uns,
+		FocusText:      w.focusText,
+		Annotations:    w.annotations,
+		Skips:          skips,
+		Labels:         w.sectionLabels(),
+		Disassembly:    w.disassembly(),
+	}
+	if color := w.options.String("packetcolor"); color != "" {
+		s.Highlights = w.packetHighlights(w.offset, w.offset+int64(n), color)
+	}
+	if len(w.overlay) > 0 {
+		w.applyOverlay(s)
+	}
+	if w.substitution != nil {
+		s.Highlights = append(s.Highlights, w.substitution.highlight())
+	}
+	if fields, size := w.table(); size == w.width && len(fields) > 0 {
+		s.Records = tableRecords(fields, s)
+	}
+	return s, nil
 }
 
 func (w *window) insert(offset int64, c byte) {
 	w.buffer.Insert(offset, c)
 	w.changedTick++
+	w.shiftMarks(offset, 1)
 }
 
 func (w *window) replace(offset int64, c byte) {
@@ -317,20 +683,114 @@ func (w *window) replace(offset int64, c byte) {
 func (w *window) delete(offset int64) {
 	w.buffer.Delete(offset)
 	w.changedTick++
+	w.shiftMarks(offset, -1)
+}
+
+func (w *window) deleteBytes(offset, n int64) {
+	w.buffer.DeleteBytes(offset, n)
+	w.changedTick++
+	w.length -= n
+	w.shiftMarks(offset, -n)
+}
+
+// shiftMarks moves the bookmarks and the annotations by the bytes inserted at
+// the offset, or deleted from the offset when n is negative. The moved ones
+// are saved on writing the file, so that they point to the same bytes in the
+// file.
+func (w *window) shiftMarks(offset, n int64) {
+	if shiftBookmarks(w.bookmarks, offset, n) {
+		w.marksMoved = true
+	}
+	if len(w.annotations) > 0 {
+		var moved bool
+		if w.annotations, moved = shiftAnnotations(w.annotations, offset, n); moved {
+			w.marksMoved = true
+		}
+	}
+}
+
+// saveMarks saves the moved bookmarks and annotations after writing the file.
+// The files failed to load are not overwritten.
+func (w *window) saveMarks() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.marksMoved || w.filename == "" {
+		return nil
+	}
+	w.marksMoved = false
+	if w.marksErr == nil {
+		if err := saveBookmarks(w.filename, w.bookmarks); err != nil {
+			return err
+		}
+	}
+	if w.notesErr == nil {
+		return saveAnnotations(w.filename, w.annotations)
+	}
+	return nil
+}
+
+// pushHistory pushes the buffer to the history, discarding the oldest entries
+// exceeding the undolevels and undomemory options (in MiB, 0 for no limit).
+func (w *window) pushHistory(offset, cursor int64) {
+	w.history.SetLimit(w.options.Int("undolevels")+1, int64(w.options.Int("undomemory"))<<20)
+	w.history.Push(w.buffer, offset, cursor)
+	w.lastEdit = event.Nop
+}
+
+// editRepeatInterval is the maximum interval of the repeated edits recorded
+// as one change, which is longer than the interval of key repeat.
+const editRepeatInterval = 150 * time.Millisecond
+
+// pushEdit pushes the buffer to the history on the edit in normal mode. The
+// edits of the same kind repeated within the interval, such as holding x, are
+// recorded as one change.
+func (w *window) pushEdit(typ event.Type) {
+	now := time.Now()
+	if w.lastEdit == typ && now.Sub(w.lastEditTime) < editRepeatInterval {
+		w.history.SetLimit(w.options.Int("undolevels")+1, int64(w.options.Int("undomemory"))<<20)
+		w.history.Amend(w.buffer, w.offset, w.cursor)
+	} else {
+		w.pushHistory(w.offset, w.cursor)
+	}
+	if isRepeatableEdit(typ) {
+		w.lastEdit, w.lastEditTime = typ, now
+	}
+}
+
+func isRepeatableEdit(typ event.Type) bool {
+	switch typ {
+	case event.DeleteByte, event.DeletePrevByte, event.Increment, event.Decrement:
+		return true
+	default:
+		return false
+	}
 }
 
 func (w *window) undo(count int64) {
+	w.lastEdit = event.Nop
+	var n int
+	var last history.Change
+	defer func() { w.undoMessage = formatUndoMessage(n, "before", last) }()
 	for i := int64(0); i < mathutil.MaxInt64(count, 1); i++ {
+		change := w.history.Current()
 		buffer, _, offset, cursor := w.history.Undo()
 		if buffer == nil {
 			return
 		}
 		w.buffer, w.offset, w.cursor = buffer, offset, cursor
 		w.length, _ = w.buffer.Len()
+		w.changedTick++
+		if w.history.Current() != change {
+			n, last = n+1, change
+		}
 	}
 }
 
 func (w *window) redo(count int64) {
+	w.lastEdit = event.Nop
+	var n int
+	var last history.Change
+	defer func() { w.undoMessage = formatUndoMessage(n, "after", last) }()
 	for i := int64(0); i < mathutil.MaxInt64(count, 1); i++ {
 		buffer, offset, cursor := w.history.Redo()
 		if buffer == nil {
@@ -338,11 +798,37 @@ func (w *window) redo(count int64) {
 		}
 		w.buffer, w.offset, w.cursor = buffer, offset, cursor
 		w.length, _ = w.buffer.Len()
+		w.changedTick++
+		n, last = n+1, w.history.Current()
 	}
 }
 
+// formatUndoMessage formats the message of undo or redo like
+// "1 change; before #42 12:03:05; 3 bytes at 0x1a".
+func formatUndoMessage(n int, dir string, c history.Change) string {
+	if n == 0 {
+		if dir == "before" {
+			return "already at oldest change"
+		}
+		return "already at newest change"
+	}
+	changes := "changes"
+	if n == 1 {
+		changes = "change"
+	}
+	return fmt.Sprintf("%d %s; %s #%d %s; %d bytes at 0x%x",
+		n, changes, dir, c.Seq, c.Time.Format("15:04:05"), c.Size, c.Offset)
+}
+
 func (w *window) cursorUp(count int64) {
-	w.cursor -= mathutil.MinInt64(mathutil.MaxInt64(count, 1), w.cursor/w.width) * w.width
+	if size := int64(w.options.Int("recordsize")); size > 0 {
+		w.cursor -= mathutil.MinInt64(mathutil.MaxInt64(count, 1), w.cursor/size) * size
+	} else {
+		if w.options.Bool("sparse") {
+			count = w.sparseLines(count, false)
+		}
+		w.cursor -= mathutil.MinInt64(mathutil.MaxInt64(count, 1), w.cursor/w.width) * w.width
+	}
 	if w.cursor < w.offset {
 		w.offset = w.cursor / w.width * w.width
 	}
@@ -356,13 +842,22 @@ func (w *window) cursorUp(count int64) {
 }
 
 func (w *window) cursorDown(count int64) {
-	w.cursor += mathutil.MinInt64(
-		mathutil.MinInt64(
-			mathutil.MaxInt64(count, 1),
-			(mathutil.MaxInt64(w.length, 1)-1)/w.width-w.cursor/w.width,
-		)*w.width,
-		mathutil.MaxInt64(w.length, 1)-1-w.cursor)
-	if w.cursor >= w.offset+w.height*w.width {
+	if size := int64(w.options.Int("recordsize")); size > 0 {
+		w.cursor += mathutil.MaxInt64(mathutil.MinInt64(mathutil.MaxInt64(count, 1),
+			(mathutil.MaxInt64(w.length, 1)-1-w.cursor)/size), 0) * size
+	} else {
+		if w.options.Bool("sparse") {
+			count = w.sparseLines(count, true)
+		}
+		w.cursor += mathutil.MinInt64(
+			mathutil.MinInt64(
+				mathutil.MaxInt64(count, 1),
+				(mathutil.MaxInt64(w.length, 1)-1)/w.width-w.cursor/w.width,
+			)*w.width,
+			mathutil.MaxInt64(w.length, 1)-1-w.cursor)
+	}
+	if w.cursor >= w.offset+w.height*w.width &&
+		!(w.options.Bool("sparse") && w.sparseVisible()) {
 		w.offset = (w.cursor - w.height*w.width + w.width) / w.width * w.width
 	}
 }
@@ -397,6 +892,31 @@ func (w *window) cursorRight(m mode.Mode, count int64) {
 	}
 }
 
+// nibbleMode reports whether h and l move the cursor by nibble.
+func (w *window) nibbleMode(m mode.Mode) bool {
+	return m == mode.Normal && !w.focusText && w.options.Bool("nibble")
+}
+
+func (w *window) nibbleLeft(count int64) {
+	pos := 2*w.cursor + nibbleIndex(w.lowNibble)
+	pos -= mathutil.MinInt64(mathutil.MaxInt64(count, 1), pos-2*(w.cursor-w.cursor%w.width))
+	w.cursor, w.lowNibble = pos/2, pos%2 == 1
+}
+
+func (w *window) nibbleRight(count int64) {
+	pos := 2*w.cursor + nibbleIndex(w.lowNibble)
+	end := 2*mathutil.MinInt64(w.cursor-w.cursor%w.width+w.width, mathutil.MaxInt64(w.length, 1)) - 1
+	pos += mathutil.MinInt64(mathutil.MaxInt64(count, 1), mathutil.MaxInt64(end-pos, 0))
+	w.cursor, w.lowNibble = pos/2, pos%2 == 1
+}
+
+func nibbleIndex(low bool) int64 {
+	if low {
+		return 1
+	}
+	return 0
+}
+
 func (w *window) cursorPrev(count int64) {
 	w.cursor -= mathutil.MinInt64(mathutil.MaxInt64(count, 1), w.cursor)
 	if w.cursor < w.offset {
@@ -454,6 +974,9 @@ func (w *window) cursorGoto(e event.Event) {
 func (w *window) cursorGotoPos(pos event.Position) {
 	if offset, err := w.positionToOffset(pos); err == nil {
 		w.cursor = mathutil.MaxInt64(mathutil.MinInt64(offset, mathutil.MaxInt64(w.length, 1)-1), 0)
+		if w.width == 0 { // not resized yet
+			return
+		}
 		if w.cursor < w.offset {
 			w.offset = (mathutil.MaxInt64(w.cursor/w.width, w.height/2) - w.height/2) * w.width
 		} else if w.cursor >= w.offset+w.height*w.width {
@@ -481,18 +1004,39 @@ func (w *window) scrollDown(count int64) {
 	}
 }
 
-func (w *window) pageUp() {
-	w.offset = mathutil.MaxInt64(w.offset-(w.height-2)*w.width, 0)
+// scrollCursor scrolls the view to show the line of the cursor at the row,
+// without moving the cursor.
+func (w *window) scrollCursor(row int64) {
+	w.offset = mathutil.MaxInt64(mathutil.MinInt64(
+		(w.cursor/w.width-row)*w.width,
+		mathutil.MaxInt64(w.length-1-w.height*w.width+w.width, 0)/w.width*w.width,
+	), 0)
+}
+
+func (w *window) pageUp(count int64) {
+	if w.options.Bool("sparse") {
+		w.offset = w.sparsePageUp(count)
+	} else {
+		w.offset = mathutil.MaxInt64(w.offset-(w.height-2)*mathutil.MaxInt64(count, 1)*w.width, 0)
+	}
 	if w.offset == 0 {
 		w.cursor = 0
+	} else if w.options.Bool("sparse") {
+		if !w.sparseVisible() {
+			w.cursor = w.sparseLastRow()
+		}
 	} else if w.cursor >= w.offset+w.height*w.width {
 		w.cursor = w.offset + (w.height-1)*w.width
 	}
 }
 
-func (w *window) pageDown() {
+func (w *window) pageDown(count int64) {
 	offset := mathutil.MaxInt64(((w.length+w.width-1)/w.width-w.height)*w.width, 0)
-	w.offset = mathutil.MinInt64(w.offset+(w.height-2)*w.width, offset)
+	if w.options.Bool("sparse") {
+		w.offset = mathutil.MinInt64(w.sparsePageDown(count), offset)
+	} else {
+		w.offset = mathutil.MinInt64(w.offset+(w.height-2)*mathutil.MaxInt64(count, 1)*w.width, offset)
+	}
 	if w.cursor < w.offset {
 		w.cursor = w.offset
 	} else if w.offset == offset {
@@ -500,8 +1044,8 @@ func (w *window) pageDown() {
 	}
 }
 
-func (w *window) pageUpHalf() {
-	w.offset = mathutil.MaxInt64(w.offset-mathutil.MaxInt64(w.height/2, 1)*w.width, 0)
+func (w *window) pageUpHalf(count int64) {
+	w.offset = mathutil.MaxInt64(w.offset-w.scrollLines(count)*w.width, 0)
 	if w.offset == 0 {
 		w.cursor = 0
 	} else if w.cursor >= w.offset+w.height*w.width {
@@ -509,9 +1053,9 @@ func (w *window) pageUpHalf() {
 	}
 }
 
-func (w *window) pageDownHalf() {
+func (w *window) pageDownHalf(count int64) {
 	offset := mathutil.MaxInt64(((w.length+w.width-1)/w.width-w.height)*w.width, 0)
-	w.offset = mathutil.MinInt64(w.offset+mathutil.MaxInt64(w.height/2, 1)*w.width, offset)
+	w.offset = mathutil.MinInt64(w.offset+w.scrollLines(count)*w.width, offset)
 	if w.cursor < w.offset {
 		w.cursor = w.offset
 	} else if w.offset == offset {
@@ -519,16 +1063,62 @@ func (w *window) pageDownHalf() {
 	}
 }
 
-func (w *window) pageTop() {
+// scrollLines returns the number of lines to scroll by half a page, or the
+// count if specified.
+func (w *window) scrollLines(count int64) int64 {
+	if count > 0 {
+		return count
+	}
+	return mathutil.MaxInt64(w.height/2, 1)
+}
+
+func (w *window) pageTop(count int64) {
+	if count > 0 {
+		w.cursorGotoLine(count)
+		return
+	}
 	w.offset = 0
 	w.cursor = 0
 }
 
-func (w *window) pageEnd() {
+func (w *window) pageEnd(count int64) {
+	if count > 0 {
+		w.cursorGotoLine(count)
+		return
+	}
 	w.offset = mathutil.MaxInt64(((w.length+w.width-1)/w.width-w.height)*w.width, 0)
 	w.cursor = ((mathutil.MaxInt64(w.length, 1)+w.width-1)/w.width - 1) * w.width
 }
 
+func (w *window) cursorGotoLine(line int64) {
+	line = mathutil.MinInt64(line, w.length/w.width+1)
+	w.cursorGotoPos(event.Absolute{Offset: (line - 1) * w.width})
+}
+
+func (w *window) gotoPercent(count int64) {
+	if count > 0 {
+		w.cursorGotoPos(event.Percent{Percent: count})
+	} else {
+		w.gotoMatchingBoundary()
+	}
+}
+
+// gotoMatchingBoundary jumps between the start and the end of the innermost
+// annotated range at the cursor, like the bracket matching of text editors.
+func (w *window) gotoMatchingBoundary() {
+	for i := len(w.annotations) - 1; i >= 0; i-- {
+		a := w.annotations[i]
+		if a.From <= w.cursor && w.cursor <= a.To {
+			if to := mathutil.MinInt64(a.To, mathutil.MaxInt64(w.length, 1)-1); w.cursor == to {
+				w.cursorGotoPos(event.Absolute{Offset: a.From})
+			} else {
+				w.cursorGotoPos(event.Absolute{Offset: to})
+			}
+			return
+		}
+	}
+}
+
 func isDigit(b byte) bool {
 	return '\x30' <= b && b <= '\x39'
 }
@@ -560,18 +1150,440 @@ func (w *window) jumpTo() {
 	if offset <= 0 || w.length <= offset {
 		return
 	}
-	w.stack = append(w.stack, position{w.cursor, w.offset})
 	w.cursor = offset
 	w.offset = mathutil.MaxInt64(offset-offset%w.width-mathutil.MaxInt64(w.height/3, 0)*w.width, 0)
 }
 
-func (w *window) jumpBack() {
-	if len(w.stack) == 0 {
+// jumpToPointer moves the cursor to the offset stored in the bytes at the
+// cursor, which is read as a little-endian integer of the width (4 bytes by
+// default), or as a big-endian integer when big is true. The pointer is the
+// address including the baseaddress option, like the offsets of the commands.
+func (w *window) jumpToPointer(width int64, big bool) {
+	if width == 0 {
+		width = 4
+	}
+	if width != 1 && width != 2 && width != 4 && width != 8 {
+		return
+	}
+	n, bytes, err := w.readBytes(w.cursor, int(width))
+	if err != nil || n < int(width) {
 		return
 	}
-	w.cursor = w.stack[len(w.stack)-1].cursor
-	w.offset = w.stack[len(w.stack)-1].offset
-	w.stack = w.stack[:len(w.stack)-1]
+	var offset uint64
+	for i, b := range bytes {
+		if big {
+			offset = offset<<8 | uint64(b)
+		} else {
+			offset |= uint64(b) << (8 * i)
+		}
+	}
+	offset -= uint64(w.options.Int64("baseaddress"))
+	if offset >= uint64(w.length) {
+		return
+	}
+	w.cursorGotoPos(event.Absolute{Offset: int64(offset)})
+}
+
+// isJump reports whether the event moves the cursor in a way
+// which should be recorded in the jump list.
+func isJump(typ event.Type) bool {
+	switch typ {
+	case event.CursorGoto, event.PageTop, event.PageEnd, event.GotoPercent,
+		event.JumpTo, event.JumpToPointer,
+		event.GotoMark, event.GotoMarkLine, event.NextChange, event.PreviousChange,
+		event.NextString, event.PreviousString, event.NextData, event.PreviousData,
+		event.NextPacket, event.PreviousPacket,
+		event.ExecuteSearch, event.NextSearch, event.PreviousSearch:
+		return true
+	default:
+		return false
+	}
+}
+
+const maxJumps = 100
+
+func (w *window) pushJump(pos position) {
+	w.jumps = append(w.jumps[:w.jumpIndex], pos)
+	if len(w.jumps) > maxJumps {
+		w.jumps = w.jumps[len(w.jumps)-maxJumps:]
+	}
+	w.jumpIndex = len(w.jumps)
+	w.marks['\''] = pos
+}
+
+func (w *window) jumpBack(count int64) {
+	if w.jumpIndex == 0 {
+		return
+	}
+	if w.jumpIndex == len(w.jumps) {
+		w.jumps = append(w.jumps, position{w.cursor, w.offset})
+	}
+	w.jumpIndex -= int(mathutil.MinInt64(mathutil.MaxInt64(count, 1), int64(w.jumpIndex)))
+	w.restorePosition(w.jumps[w.jumpIndex])
+}
+
+func (w *window) jumpForward(count int64) {
+	if w.jumpIndex >= len(w.jumps)-1 {
+		return
+	}
+	w.jumpIndex += int(mathutil.MinInt64(mathutil.MaxInt64(count, 1), int64(len(w.jumps)-1-w.jumpIndex)))
+	w.restorePosition(w.jumps[w.jumpIndex])
+}
+
+func (w *window) restorePosition(pos position) {
+	w.cursor = mathutil.MaxInt64(mathutil.MinInt64(pos.cursor, mathutil.MaxInt64(w.length, 1)-1), 0)
+	w.offset = pos.offset
+	if w.cursor < w.offset {
+		w.offset = w.cursor / w.width * w.width
+	} else if w.cursor >= w.offset+w.height*w.width {
+		w.offset = (w.cursor - w.height*w.width + w.width) / w.width * w.width
+	}
+}
+
+func (w *window) setMark(r rune) {
+	if r == 0 {
+		return
+	}
+	w.marks[r] = position{w.cursor, w.offset}
+}
+
+func (w *window) gotoMark(r rune, head bool) {
+	if r == '`' {
+		r = '\''
+	}
+	pos, ok := w.marks[r]
+	if !ok {
+		return
+	}
+	if head {
+		pos.cursor -= pos.cursor % w.width
+	}
+	w.restorePosition(pos)
+}
+
+func (w *window) nextChange(count int64) {
+	count = mathutil.MaxInt64(count, 1)
+	eis, err := w.buffer.DirtyRanges()
+	if err != nil {
+		return
+	}
+	offset := int64(-1)
+	for i := 0; i < len(eis) && count > 0; i += 2 {
+		if eis[i] > w.cursor {
+			offset = eis[i]
+			count--
+		}
+	}
+	if offset >= 0 {
+		w.cursorGotoPos(event.Absolute{Offset: offset})
+	}
+}
+
+func (w *window) previousChange(count int64) {
+	count = mathutil.MaxInt64(count, 1)
+	eis, err := w.buffer.DirtyRanges()
+	if err != nil {
+		return
+	}
+	offset := int64(-1)
+	for i := len(eis) - 2; i >= 0 && count > 0; i -= 2 {
+		if eis[i] < w.cursor {
+			offset = eis[i]
+			count--
+		}
+	}
+	if offset >= 0 {
+		w.cursorGotoPos(event.Absolute{Offset: offset})
+	}
+}
+
+// nextString moves the cursor to the start of the next run of the printable
+// characters, which is at least the length of the stringlength option.
+func (w *window) nextString(count int64) {
+	count = mathutil.MaxInt64(count, 1)
+	min := int64(w.options.Int("stringlength"))
+	start, offset, skip := int64(-1), int64(-1), true
+	bs := make([]byte, 64*1024)
+	for base := w.cursor; count > 0; base += int64(len(bs)) {
+		n, err := w.buffer.ReadAt(bs, base)
+		if err != nil && err != io.EOF {
+			return
+		}
+		for i := 0; i < n && count > 0; i++ {
+			if !isPrintable(bs[i]) {
+				start, skip = -1, false
+			} else if !skip {
+				if start < 0 {
+					start = base + int64(i)
+				}
+				if base+int64(i)-start+1 >= min {
+					offset, count = start, count-1
+					start, skip = -1, true
+				}
+			}
+		}
+		if n < len(bs) {
+			break
+		}
+	}
+	if offset >= 0 {
+		w.cursorGotoPos(event.Absolute{Offset: offset})
+	}
+}
+
+// previousString moves the cursor to the start of the previous run of the
+// printable characters, or the start of the run at the cursor.
+func (w *window) previousString(count int64) {
+	count = mathutil.MaxInt64(count, 1)
+	min := int64(w.options.Int("stringlength"))
+	bs := make([]byte, 64*1024)
+	// the length of the run at the cursor is counted from the bytes after it
+	var length int64
+	if n, err := w.buffer.ReadAt(bs[:mathutil.MinInt64(min, int64(len(bs)))], w.cursor); err == nil || err == io.EOF {
+		for _, b := range bs[:n] {
+			if !isPrintable(b) {
+				break
+			}
+			length++
+		}
+	}
+	offset := int64(-1)
+	for end := w.cursor; end > 0 && count > 0; {
+		base := mathutil.MaxInt64(end-int64(len(bs)), 0)
+		n, err := w.buffer.ReadAt(bs[:end-base], base)
+		if err != nil && err != io.EOF {
+			return
+		}
+		for i := n - 1; i >= -1 && count > 0; i-- {
+			if i >= 0 && isPrintable(bs[i]) {
+				length++
+				continue
+			}
+			if i >= 0 || base == 0 {
+				if length >= min && base+int64(i)+1 < w.cursor {
+					offset, count = base+int64(i)+1, count-1
+				}
+				length = 0
+			}
+		}
+		end = base
+	}
+	if offset >= 0 {
+		w.cursorGotoPos(event.Absolute{Offset: offset})
+	}
+}
+
+// minPaddingLength is the minimum length of the runs of the padding bytes
+// skipped by nextData and previousData.
+const minPaddingLength = 16
+
+// nextData moves the cursor to the next byte after the padding of 0x00 or 0xff.
+// When the cursor is on the padding, it moves to the end of the padding.
+func (w *window) nextData(count int64) {
+	count = mathutil.MaxInt64(count, 1)
+	var length int64
+	offset := int64(-1)
+	bs := make([]byte, 64*1024)
+	for base := w.cursor; count > 0; base += int64(len(bs)) {
+		n, err := w.buffer.ReadAt(bs, base)
+		if err != nil && err != io.EOF {
+			return
+		}
+		for i := 0; i < n && count > 0; i++ {
+			if isPadding(bs[i]) {
+				if length++; base+int64(i) == w.cursor {
+					length = minPaddingLength
+				}
+			} else {
+				if length >= minPaddingLength {
+					offset, count = base+int64(i), count-1
+				}
+				length = 0
+			}
+		}
+		if n < len(bs) {
+			break
+		}
+	}
+	if offset >= 0 {
+		w.cursorGotoPos(event.Absolute{Offset: offset})
+	}
+}
+
+// previousData moves the cursor to the start of the bytes after the previous
+// padding, or the start of the bytes at the cursor.
+func (w *window) previousData(count int64) {
+	count = mathutil.MaxInt64(count, 1)
+	var length int64
+	start, offset := int64(-1), int64(-1)
+	bs := make([]byte, 64*1024)
+	for end := w.cursor; end > 0 && count > 0; {
+		base := mathutil.MaxInt64(end-int64(len(bs)), 0)
+		n, err := w.buffer.ReadAt(bs[:end-base], base)
+		if err != nil && err != io.EOF {
+			return
+		}
+		for i := n - 1; i >= 0 && count > 0; i-- {
+			if isPadding(bs[i]) {
+				length++
+				continue
+			}
+			if start >= 0 && length >= minPaddingLength {
+				offset, count = start, count-1
+			}
+			start, length = base+int64(i), 0
+		}
+		end = base
+	}
+	if count > 0 && start >= 0 {
+		offset = start
+	}
+	if offset >= 0 {
+		w.cursorGotoPos(event.Absolute{Offset: offset})
+	}
+}
+
+// isPadding reports whether the byte fills the unused regions.
+func isPadding(b byte) bool {
+	return b == 0x00 || b == 0xff
+}
+
+// isPrintable reports whether the byte is a character of the strings.
+func isPrintable(b byte) bool {
+	return 0x20 <= b && b <= 0x7e || b == '\t'
+}
+
+// unsavedIndices returns the indices of the edited regions of the bytes at
+// the offset, which differ from the contents on the last save.
+func (w *window) unsavedIndices(uis, eis []int64, offset int64, bs []byte) ([]int64, error) {
+	if cap(w.savedBytes) < len(bs) {
+		w.savedBytes = make([]byte, len(bs))
+	}
+	saved := w.savedBytes[:len(bs)]
+	n, err := w.savedBuffer.ReadAt(saved, offset)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	i := 2 * sort.Search(len(eis)/2, func(i int) bool { return eis[2*i+1] > offset })
+	for ; i < len(eis) && eis[i] < offset+int64(len(bs)); i += 2 {
+		from := mathutil.MaxInt64(eis[i]-offset, 0)
+		to := mathutil.MinInt64(eis[i+1]-offset, int64(len(bs)))
+		for j := from; j < to; j++ {
+			if j < int64(n) && bs[j] == saved[j] {
+				continue
+			}
+			if l := len(uis); l > 0 && uis[l-1] == offset+j {
+				uis[l-1]++
+			} else {
+				uis = append(uis, offset+j, offset+j+1)
+			}
+		}
+	}
+	return uis, nil
+}
+
+// unsavedChunk is the size of the bytes compared at once with the contents on
+// the last save.
+const unsavedChunk = 64 << 10
+
+// unsavedRange reports whether the edited range differs from the contents on
+// the last save. The bytes are compared by chunks not to read the large range
+// into the memory at once.
+func (w *window) unsavedRange(from, to int64) (bool, error) {
+	eis := []int64{from, to}
+	bs := make([]byte, mathutil.MinInt64(to-from, unsavedChunk))
+	for offset := from; offset < to; offset += int64(len(bs)) {
+		n, err := w.buffer.ReadAt(bs[:mathutil.MinInt64(to-offset, int64(len(bs)))], offset)
+		if err != nil && err != io.EOF {
+			return false, err
+		}
+		uis, err := w.unsavedIndices(nil, eis, offset, bs[:n])
+		if err != nil {
+			return false, err
+		}
+		if len(uis) > 0 {
+			return true, nil
+		}
+		if n == 0 {
+			break
+		}
+	}
+	return false, nil
+}
+
+// unsavedNibbles appends the nibbles of the unsaved bytes in the ranges, of
+// which only one nibble differs from the saved byte, like the dirty nibbles.
+func (w *window) unsavedNibbles(ns, uis []int64, offset int64, bs []byte) ([]int64, error) {
+	var saved [1]byte
+	end := offset + int64(len(bs))
+	i := 2 * sort.Search(len(uis)/2, func(i int) bool { return uis[2*i+1] > offset })
+	for ; i < len(uis) && uis[i] < end; i += 2 {
+		for j := mathutil.MaxInt64(uis[i], offset); j < mathutil.MinInt64(uis[i+1], end); j++ {
+			n, err := w.savedBuffer.ReadAt(saved[:], j)
+			if err != nil && err != io.EOF {
+				return nil, err
+			}
+			if n == 0 {
+				continue
+			}
+			if d := bs[j-offset] ^ saved[0]; d&0x0f == 0 {
+				ns = append(ns, 2*j)
+			} else if d&0xf0 == 0 {
+				ns = append(ns, 2*j+1)
+			}
+		}
+	}
+	return ns, nil
+}
+
+// readStream reads the input stream ahead of the cursor before moving it, and
+// reads the next chunk on moving to the end, since the length is unknown.
+func (w *window) readStream(e event.Event) {
+	if w.stream == nil || w.stream.done() {
+		return
+	}
+	switch e.Type {
+	case event.PageEnd, event.CursorGoto, event.GotoPercent:
+		_ = w.stream.next()
+	default:
+		size := w.height * w.width
+		_ = w.stream.fill(mathutil.MaxInt64(w.offset, w.cursor) +
+			(mathutil.MaxInt64(e.Count, 1)+1)*mathutil.MaxInt64(size, w.width))
+	}
+	w.length, _ = w.buffer.Len()
+}
+
+// notice returns the message on opening the window.
+func (w *window) notice() error {
+	if w.swap != nil && w.swap.found {
+		return w.swap.notice()
+	}
+	if w.codec != nil {
+		return w.codec.notice(w.filename)
+	}
+	if w.compressed != nil {
+		return w.compressed.offer(w.filename, w.codecErr)
+	}
+	return nil
+}
+
+// modified reports whether the buffer is changed after it was saved, by
+// comparing the tick of the changes with the one on saving.
+func (c *content) modified() bool {
+	return c.changedTick != c.savedTick
+}
+
+func (w *window) isModified() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.modified()
+}
+
+func (w *window) markSaved() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.savedBuffer = w.buffer.Clone()
+	w.savedTick = w.changedTick
 }
 
 func (w *window) deleteByte(count int64) {
@@ -582,21 +1594,17 @@ func (w *window) deleteByte(count int64) {
 		mathutil.MinInt64(mathutil.MaxInt64(count, 1), w.width-w.cursor%w.width),
 		w.length-w.cursor,
 	))
-	for i := 0; i < cnt; i++ {
-		w.delete(w.cursor)
-		w.length--
-		if w.cursor == w.length && w.cursor > 0 {
-			w.cursor--
-		}
+	w.deleteBytes(w.cursor, int64(cnt))
+	if w.cursor == w.length && w.cursor > 0 {
+		w.cursor--
 	}
 }
 
 func (w *window) deletePrevByte(count int64) {
-	cnt := int(mathutil.MinInt64(mathutil.MaxInt64(count, 1), w.cursor%w.width))
-	for i := 0; i < cnt; i++ {
-		w.delete(w.cursor - 1)
-		w.cursor--
-		w.length--
+	cnt := mathutil.MinInt64(mathutil.MaxInt64(count, 1), w.cursor%w.width)
+	if cnt > 0 {
+		w.deleteBytes(w.cursor-cnt, cnt)
+		w.cursor -= cnt
 	}
 }
 
@@ -622,10 +1630,31 @@ func (w *window) decrement(count int64) {
 	}
 }
 
+// insertBytes inserts the pattern repeatedly for the count of bytes at the
+// cursor in memory. The large insertion is spooled by the Manager beforehand,
+// and insertSpool inserts the spooled bytes.
+func (w *window) insertBytes(count int64, pattern []byte) {
+	if count <= 0 || len(pattern) == 0 {
+		return
+	}
+	if spool, err := w.checkInsert(count); spool || err != nil {
+		return
+	}
+	bs := make([]byte, count)
+	for i := 0; i < len(bs); i += len(pattern) {
+		copy(bs[i:], pattern)
+	}
+	w.buffer.InsertBytes(w.cursor, bs)
+	w.changedTick++
+	w.length += count
+	w.shiftMarks(w.cursor, count)
+}
+
 func (w *window) startInsert() {
 	w.append = false
 	w.extending = false
 	w.pending = false
+	w.lowNibble = false
 	if w.cursor == w.length {
 		w.append = true
 		w.extending = true
@@ -638,6 +1667,7 @@ func (w *window) startInsertHead() {
 	w.append = false
 	w.extending = false
 	w.pending = false
+	w.lowNibble = false
 	if w.cursor == w.length {
 		w.append = true
 		w.extending = true
@@ -649,6 +1679,7 @@ func (w *window) startAppend() {
 	w.append = true
 	w.extending = false
 	w.pending = false
+	w.lowNibble = false
 	if w.length > 0 {
 		w.cursor++
 	}
@@ -668,20 +1699,24 @@ func (w *window) startAppendEnd() {
 
 func (w *window) startReplaceByte() {
 	w.replaceByte = true
+	w.nibbleByte = w.options.Bool("nibble") && !w.focusText
 	w.append = false
 	w.extending = false
 	w.pending = false
 }
 
 func (w *window) startReplace() {
+	w.overtypes = w.overtypes[:0]
 	w.replaceByte = false
 	w.append = false
 	w.extending = false
 	w.pending = false
+	w.lowNibble = false
 }
 
 func (w *window) exitInsert() {
 	w.pending = false
+	w.nibbleByte = false
 	if w.append {
 		if w.extending && w.length > 0 {
 			w.length--
@@ -698,6 +1733,9 @@ func (w *window) exitInsert() {
 
 func (w *window) insertRune(m mode.Mode, ch rune) {
 	if m == mode.Insert || m == mode.Replace {
+		if w.literal != nil && w.inputLiteral(ch) {
+			return
+		}
 		if w.focusText {
 			buf := make([]byte, 4)
 			n := utf8.EncodeRune(buf, ch)
@@ -714,14 +1752,22 @@ func (w *window) insertRune(m mode.Mode, ch rune) {
 }
 
 func (w *window) insertByte(m mode.Mode, b byte) {
-	if w.pending {
+	if w.nibbleByte && m == mode.Replace {
+		w.replaceNibble(b)
+	} else if w.pending {
 		switch m {
 		case mode.Insert:
 			w.insert(w.cursor, w.pendingByte|b)
 			w.cursor++
 			w.length++
 		case mode.Replace:
+			if !w.replaceByte {
+				w.recordOvertype()
+			}
 			w.replace(w.cursor, w.pendingByte|b)
+			if !w.replaceByte {
+				w.overtypeTick = w.changedTick
+			}
 			if w.length == 0 {
 				w.length++
 			}
@@ -747,10 +1793,55 @@ func (w *window) insertByte(m mode.Mode, b byte) {
 	}
 }
 
-func (w *window) backspace() {
-	if w.pending {
+// replaceNibble replaces the focused nibble of the byte under the cursor.
+func (w *window) replaceNibble(b byte) {
+	_, bytes, err := w.readBytes(w.cursor, 1)
+	if err != nil {
+		return
+	}
+	if w.lowNibble {
+		w.replace(w.cursor, bytes[0]&0xf0|b)
+	} else {
+		w.replace(w.cursor, bytes[0]&0x0f|b<<4)
+	}
+	if w.length == 0 {
+		w.length++
+	}
+	w.exitInsert()
+}
+
+func (w *window) insertExpression(m mode.Mode, bs []byte) {
+	w.pending = false
+	w.nibbleByte = false
+	if w.replaceByte && len(bs) > 0 {
+		bs = bs[len(bs)-1:]
+	}
+	for _, b := range bs {
+		w.insertByte(m, b>>4)
+		w.insertByte(m, b&0x0f)
+	}
+}
+
+// pasteText inserts the pasted bytes in insert mode as one change, pushing the
+// edits before the paste to the history separately.
+func (w *window) pasteText(m mode.Mode, bs []byte, offset, cursor int64) {
+	if m != mode.Insert && m != mode.Replace {
+		return
+	}
+	if w.prevChanged {
+		w.pushHistory(offset, cursor)
+	}
+	w.insertExpression(m, bs)
+}
+
+func (w *window) backspace(m mode.Mode) {
+	if w.literal != nil {
+		w.literal = nil
+	} else if w.pending {
 		w.pending = false
 		w.pendingByte = '\x00'
+	} else if m == mode.Replace {
+		w.backspaceReplace()
 	} else if w.cursor > 0 {
 		w.delete(w.cursor - 1)
 		w.cursor--
@@ -758,8 +1849,10 @@ func (w *window) backspace() {
 	}
 }
 
-func (w *window) startVisual() {
+func (w *window) startVisual(block bool) {
 	w.visualStart = w.cursor
+	w.visualBlock = block
+	w.lowNibble = false
 }
 
 func (w *window) switchVisualEnd() {
@@ -794,9 +1887,20 @@ func (w *window) searchForward(str string) {
 		return
 	}
 	i := bytes.Index(bs, target)
+	if i < 0 && w.global.Bool("wrapscan") {
+		var n int
+		base = 0
+		if n, bs, err = w.readBytes(base, int(mathutil.MinInt64(
+			int64(size), w.cursor+int64(len(target))))); err != nil {
+			return
+		}
+		i = bytes.Index(bs[:n], target)
+	}
 	if i >= 0 {
 		w.cursor = base + int64(i)
-		if w.cursor >= w.offset+w.height*w.width {
+		if w.cursor < w.offset {
+			w.offset = w.cursor / w.width * w.width
+		} else if w.cursor >= w.offset+w.height*w.width {
 			w.offset = (w.cursor - w.height*w.width + w.width + 1) / w.width * w.width
 		}
 	}
@@ -811,10 +1915,20 @@ func (w *window) searchBackward(str string) {
 		return
 	}
 	i := bytes.LastIndex(bs, target)
+	if i < 0 && w.global.Bool("wrapscan") {
+		var n int
+		base = mathutil.MaxInt64(w.cursor+1, w.length-int64(size))
+		if n, bs, err = w.readBytes(base, int(w.length-base)); err != nil {
+			return
+		}
+		i = bytes.LastIndex(bs[:n], target)
+	}
 	if i >= 0 {
 		w.cursor = base + int64(i)
 		if w.cursor < w.offset {
 			w.offset = w.cursor / w.width * w.width
+		} else if w.cursor >= w.offset+w.height*w.width {
+			w.offset = (w.cursor - w.height*w.width + w.width) / w.width * w.width
 		}
 	}
 }
@@ -2,13 +2,18 @@ package window
 
 import (
 	"bytes"
+	"fmt"
+	"hash/crc32"
 	"math"
+	"os"
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/itchyny/bed/event"
 	"github.com/itchyny/bed/mode"
+	"github.com/itchyny/bed/state"
 )
 
 func TestWindowState(t *testing.T) {
@@ -67,6 +72,43 @@ func TestWindowState(t *testing.T) {
 	}
 }
 
+func TestWindowStateReuse(t *testing.T) {
+	r := strings.NewReader(strings.Repeat("0123456789abcdef", 4))
+	window, err := newWindow(r, "test", "test", make(chan struct{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	window.setSize(16, 2)
+
+	s1, err := window.state()
+	if err != nil {
+		t.Fatal(err)
+	}
+	window.offset = 32
+	s2, err := window.state()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "0123456789abcdef0123456789abcdef"; string(s1.Bytes) != expected {
+		t.Errorf("s1.Bytes should be %q but got %q", expected, string(s1.Bytes))
+	}
+	if expected := "0123456789abcdef0123456789abcdef"; string(s2.Bytes) != expected || s2.Offset != 32 {
+		t.Errorf("s2.Bytes should be %q but got %q", expected, string(s2.Bytes))
+	}
+
+	window.offset = 48
+	s3, err := window.state()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s3 != s1 {
+		t.Errorf("state should reuse the state of the second last call")
+	}
+	if expected := "0123456789abcdef" + strings.Repeat("\x00", 16); string(s3.Bytes) != expected || s3.Size != 16 {
+		t.Errorf("s3.Bytes should be %q but got %q", expected, string(s3.Bytes))
+	}
+}
+
 func TestWindowEmptyState(t *testing.T) {
 	r := strings.NewReader("")
 	width, height := 16, 10
@@ -311,6 +353,79 @@ func TestWindowCursorMotions(t *testing.T) {
 	}
 }
 
+func TestWindowGotoPercent(t *testing.T) {
+	r := strings.NewReader(strings.Repeat("Hello, world!", 100))
+	width, height := 16, 10
+	window, _ := newWindow(r, "test", "test", make(chan struct{}))
+	window.setSize(width, height)
+
+	for _, tc := range []struct {
+		pos      event.Position
+		expected int64
+	}{
+		{event.Percent{Percent: 50}, 650},
+		{event.Percent{Percent: 50, Offset: -0x10}, 634},
+		{event.Percent{Percent: 100}, 1299},
+		{event.Percent{Percent: 200}, 1299},
+		{event.End{Offset: -0x100}, 1043},
+		{event.Relative{Offset: 0x10}, 1059},
+		{event.Relative{Offset: -0x20}, 1027},
+		{event.Percent{Percent: 0}, 0},
+	} {
+		window.cursorGotoPos(tc.pos)
+		s, _ := window.state()
+		if s.Cursor != tc.expected {
+			t.Errorf("s.Cursor should be %d but got %d for %#v", tc.expected, s.Cursor, tc.pos)
+		}
+		if s.Offset > s.Cursor || s.Cursor >= s.Offset+int64(width*height) {
+			t.Errorf("s.Offset should be adjusted to show the cursor but got %d", s.Offset)
+		}
+	}
+
+	window.gotoPercent(25)
+	s, _ := window.state()
+	if s.Cursor != 325 {
+		t.Errorf("s.Cursor should be %d but got %d", 325, s.Cursor)
+	}
+	window.gotoPercent(0)
+	s, _ = window.state()
+	if s.Cursor != 325 {
+		t.Errorf("s.Cursor should be %d but got %d", 325, s.Cursor)
+	}
+}
+
+func TestWindowGotoMatchingBoundary(t *testing.T) {
+	r := strings.NewReader(strings.Repeat("Hello, world!", 100))
+	width, height := 16, 10
+	window, _ := newWindow(r, "test", "test", make(chan struct{}))
+	window.setSize(width, height)
+	window.annotations = []state.Annotation{
+		{From: 0x10, To: 0x3ff, Note: "header"},
+		{From: 0x20, To: 0x2f, Note: "field"},
+		{From: 0x500, To: 0x1000, Note: "tail"},
+	}
+
+	for _, tc := range []struct {
+		cursor, expected int64
+	}{
+		{0x08, 0x08},
+		{0x10, 0x3ff},
+		{0x3ff, 0x10},
+		{0x24, 0x2f},
+		{0x2f, 0x20},
+		{0x100, 0x3ff},
+		{0x600, 0x513},
+		{0x513, 0x500},
+	} {
+		window.cursor = tc.cursor
+		window.gotoPercent(0)
+		s, _ := window.state()
+		if s.Cursor != tc.expected {
+			t.Errorf("s.Cursor should be %d but got %d from %d", tc.expected, s.Cursor, tc.cursor)
+		}
+	}
+}
+
 func TestWindowScreenMotions(t *testing.T) {
 	r := strings.NewReader(strings.Repeat("Hello, world!", 100))
 	width, height := 16, 10
@@ -325,7 +440,7 @@ func TestWindowScreenMotions(t *testing.T) {
 		t.Errorf("s.Cursor should be %d but got %d", 0, s.Cursor)
 	}
 
-	window.pageDown()
+	window.pageDown(0)
 	s, _ = window.state()
 	if s.Cursor != 128 {
 		t.Errorf("s.Cursor should be %d but got %d", 128, s.Cursor)
@@ -334,7 +449,7 @@ func TestWindowScreenMotions(t *testing.T) {
 		t.Errorf("s.Offset should be %d but got %d", 128, s.Offset)
 	}
 
-	window.pageDownHalf()
+	window.pageDownHalf(0)
 	s, _ = window.state()
 	if s.Cursor != 208 {
 		t.Errorf("s.Cursor should be %d but got %d", 208, s.Cursor)
@@ -379,7 +494,7 @@ func TestWindowScreenMotions(t *testing.T) {
 		t.Errorf("s.Offset should be %d but got %d", 208, s.Offset)
 	}
 
-	window.pageUpHalf()
+	window.pageUpHalf(0)
 	s, _ = window.state()
 	if s.Cursor != 272 {
 		t.Errorf("s.Cursor should be %d but got %d", 272, s.Cursor)
@@ -388,7 +503,7 @@ func TestWindowScreenMotions(t *testing.T) {
 		t.Errorf("s.Offset should be %d but got %d", 128, s.Offset)
 	}
 
-	window.pageUp()
+	window.pageUp(0)
 	s, _ = window.state()
 	if s.Cursor != 0 {
 		t.Errorf("s.Cursor should be %d but got %d", 0, s.Cursor)
@@ -397,7 +512,7 @@ func TestWindowScreenMotions(t *testing.T) {
 		t.Errorf("s.Offset should be %d but got %d", 0, s.Offset)
 	}
 
-	window.pageEnd()
+	window.pageEnd(0)
 	s, _ = window.state()
 	if s.Cursor != 1296 {
 		t.Errorf("s.Cursor should be %d but got %d", 1296, s.Cursor)
@@ -406,7 +521,7 @@ func TestWindowScreenMotions(t *testing.T) {
 		t.Errorf("s.Offset should be %d but got %d", width*72, s.Offset)
 	}
 
-	window.pageTop()
+	window.pageTop(0)
 	s, _ = window.state()
 	if s.Cursor != 0 {
 		t.Errorf("s.Cursor should be %d but got %d", 0, s.Cursor)
@@ -416,6 +531,97 @@ func TestWindowScreenMotions(t *testing.T) {
 	}
 }
 
+func TestWindowScreenMotionsCount(t *testing.T) {
+	r := strings.NewReader(strings.Repeat("Hello, world!", 100))
+	width, height := 16, 10
+	window, _ := newWindow(r, "test", "test", make(chan struct{}))
+	window.setSize(width, height)
+
+	for _, testCase := range []struct {
+		motion         func(int64)
+		count          int64
+		cursor, offset int64
+	}{
+		{window.pageDown, 3, 384, 384},
+		{window.pageUp, 2, 272, 128},
+		{window.pageDownHalf, 3, 272, 176},
+		{window.pageUpHalf, 7, 208, 64},
+		{window.pageEnd, 10, 144, 64},
+		{window.pageTop, 100, 1296, 1152},
+		{window.pageTop, 3, 32, 0},
+	} {
+		testCase.motion(testCase.count)
+		s, _ := window.state()
+		if s.Cursor != testCase.cursor {
+			t.Errorf("s.Cursor should be %d but got %d", testCase.cursor, s.Cursor)
+		}
+		if s.Offset != testCase.offset {
+			t.Errorf("s.Offset should be %d but got %d", testCase.offset, s.Offset)
+		}
+	}
+}
+
+func TestWindowScrollCursor(t *testing.T) {
+	r := strings.NewReader(strings.Repeat("Hello, world!", 100))
+	width, height := 16, 10
+	window, _ := newWindow(r, "test", "test", make(chan struct{}))
+	window.setSize(width, height)
+
+	for _, testCase := range []struct {
+		cursor, row, offset int64
+	}{
+		{400, 5, 320},
+		{400, 0, 400},
+		{400, 9, 256},
+		{40, 5, 0},
+		{1296, 0, 1152},
+		{1296, 9, 1152},
+	} {
+		window.cursor = testCase.cursor
+		window.scrollCursor(testCase.row)
+		s, _ := window.state()
+		if s.Cursor != testCase.cursor {
+			t.Errorf("s.Cursor should be %d but got %d", testCase.cursor, s.Cursor)
+		}
+		if s.Offset != testCase.offset {
+			t.Errorf("s.Offset should be %d but got %d", testCase.offset, s.Offset)
+		}
+	}
+}
+
+func TestWindowRecordSize(t *testing.T) {
+	r := strings.NewReader(strings.Repeat("Hello, world!", 100))
+	window, _ := newWindow(r, "test", "test", make(chan struct{}))
+	window.setSize(16, 10)
+	_ = window.options.Set("recordsize=24")
+
+	for _, testCase := range []struct {
+		down           bool
+		count          int64
+		cursor, offset int64
+	}{
+		{true, 0, 24, 0},
+		{true, 3, 96, 0},
+		{false, 2, 48, 0},
+		{true, 100, 1296, 1152},
+		{false, 0, 1272, 1152},
+		{false, 100, 0, 0},
+		{false, 1, 0, 0},
+	} {
+		if testCase.down {
+			window.cursorDown(testCase.count)
+		} else {
+			window.cursorUp(testCase.count)
+		}
+		if window.cursor != testCase.cursor {
+			t.Errorf("cursor should be %d but got %d", testCase.cursor, window.cursor)
+		}
+		if window.offset != testCase.offset {
+			t.Errorf("offset should be %d but got %d", testCase.offset, window.offset)
+		}
+	}
+}
+
 func TestWindowDeleteBytes(t *testing.T) {
 	r := strings.NewReader("Hello, world!")
 	width, height := 16, 10
@@ -563,6 +769,153 @@ func TestWindowIncrementDecrement(t *testing.T) {
 	}
 }
 
+func TestWindowInsertBytes(t *testing.T) {
+	r := strings.NewReader("Hello, world!")
+	width, height := 16, 10
+	window, _ := newWindow(r, "test", "test", make(chan struct{}))
+	window.setSize(width, height)
+
+	window.cursorNext(mode.Normal, 5)
+	window.insertBytes(3, []byte{0x00})
+	s, _ := window.state()
+	if !strings.HasPrefix(string(s.Bytes), "Hello\x00\x00\x00, world!\x00") {
+		t.Errorf("s.Bytes should start with %q but got %q", "Hello\x00\x00\x00, world!\x00", string(s.Bytes))
+	}
+	if s.Length != 16 {
+		t.Errorf("s.Length should be %d but got %d", 16, s.Length)
+	}
+	if s.Cursor != 5 {
+		t.Errorf("s.Cursor should be %d but got %d", 5, s.Cursor)
+	}
+
+	window.insertBytes(5, []byte("ab"))
+	s, _ = window.state()
+	if !strings.HasPrefix(string(s.Bytes), "Helloababa\x00\x00\x00, world!\x00") {
+		t.Errorf("s.Bytes should start with %q but got %q", "Helloababa\x00\x00\x00, world!\x00", string(s.Bytes))
+	}
+	if s.Length != 21 {
+		t.Errorf("s.Length should be %d but got %d", 21, s.Length)
+	}
+}
+
+func TestWindowInsertLargeBytes(t *testing.T) {
+	r := strings.NewReader("Hello, world!")
+	width, height := 16, 10
+	window, _ := newWindow(r, "test", "test", make(chan struct{}))
+	window.setSize(width, height)
+
+	window.cursorNext(mode.Normal, 5)
+	if spool, err := window.checkInsert(largeInsertLength + 1); !spool || err != nil {
+		t.Errorf("inserted bytes should be spooled but got: %v, %v", spool, err)
+	}
+	window.insertBytes(largeInsertLength+1, []byte("abc"))
+	if window.length != 13 {
+		t.Errorf("large insertion should be spooled beforehand but got: %d", window.length)
+	}
+	f, err := spoolBytes(largeInsertLength+1, []byte("abc"), nil)
+	if err != nil {
+		t.Fatalf("err should be nil but got: %v", err)
+	}
+	if err := window.insertSpool(event.Event{Type: event.InsertBytes}, f, largeInsertLength+1); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	if n := window.buffer.MemoryLen(); n != 0 {
+		t.Errorf("inserted bytes should not be held in memory but got %d bytes", n)
+	}
+	if len(window.spools) != 1 {
+		t.Fatalf("inserted bytes should be spooled to a file but got: %v", window.spools)
+	}
+	s, _ := window.state()
+	if !strings.HasPrefix(string(s.Bytes), "Helloabcabcabcab") {
+		t.Errorf("s.Bytes should start with %q but got %q", "Helloabcabcabcab", string(s.Bytes))
+	}
+	if expected := int64(largeInsertLength + 14); s.Length != expected {
+		t.Errorf("s.Length should be %d but got %d", expected, s.Length)
+	}
+	bs := make([]byte, 12)
+	if _, err := window.buffer.ReadAt(bs, largeInsertLength+2); err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	if expected := "bcab, world!"; string(bs) != expected {
+		t.Errorf("bytes should be %q but got %q", expected, string(bs))
+	}
+	if changes, _ := window.buffer.ChangedRanges(); !reflect.DeepEqual(changes, []int64{5, largeInsertLength + 14}) {
+		t.Errorf("changed ranges should be %v but got %v", []int64{5, largeInsertLength + 14}, changes)
+	}
+
+	name := window.spools[0].Name()
+	window.removeSpools()
+	if _, err := os.Stat(name); !os.IsNotExist(err) {
+		t.Errorf("spooled file should be removed but got: %v", err)
+	}
+	if _, err := window.checkInsert(math.MaxInt64 - 5); err == nil || err.Error() != "too many bytes to insert: 9223372036854775802" {
+		t.Errorf("err should be the overflow of the length but got: %v", err)
+	}
+}
+
+type testPrefetcher struct {
+	ranges [][2]int64
+}
+
+func (p *testPrefetcher) prefetch(from, to int64) {
+	p.ranges = append(p.ranges, [2]int64{from, to})
+}
+
+func TestWindowPrefetchAhead(t *testing.T) {
+	r := strings.NewReader(strings.Repeat("Hello, world!", 100))
+	width, height := 16, 10
+	window, _ := newWindow(r, "test", "test", make(chan struct{}))
+	window.setSize(width, height)
+	p := &testPrefetcher{}
+	window.prefetcher = p
+
+	_, _ = window.state()
+	window.pageDown(3)
+	_, _ = window.state()
+	_, _ = window.state()
+	window.pageUp(1)
+	_, _ = window.state()
+	window.pageUp(5)
+	_, _ = window.state()
+	expected := [][2]int64{{544, 1184}, {0, 256}}
+	if !reflect.DeepEqual(p.ranges, expected) {
+		t.Errorf("prefetched ranges should be %v but got %v", expected, p.ranges)
+	}
+}
+
+func TestWindowInsertExpression(t *testing.T) {
+	r := strings.NewReader("Hello, world!")
+	width, height := 16, 10
+	window, _ := newWindow(r, "test", "test", make(chan struct{}))
+	window.setSize(width, height)
+
+	window.cursorNext(mode.Normal, 5)
+	window.startInsert()
+	window.insertByte(mode.Insert, 0x04)
+	window.insertExpression(mode.Insert, []byte{0x60, 0x42})
+	s, _ := window.state()
+	if !strings.HasPrefix(string(s.Bytes), "Hello`B, world!\x00") {
+		t.Errorf("s.Bytes should start with %q but got %q", "Hello`B, world!\x00", string(s.Bytes))
+	}
+	if s.Cursor != 7 {
+		t.Errorf("s.Cursor should be %d but got %d", 7, s.Cursor)
+	}
+	if s.Pending != false {
+		t.Errorf("s.Pending should be %v but got %v", false, s.Pending)
+	}
+	window.exitInsert()
+
+	window.startReplace()
+	window.insertExpression(mode.Replace, []byte{0x21, 0x21})
+	s, _ = window.state()
+	if !strings.HasPrefix(string(s.Bytes), "Hello`B!!world!\x00") {
+		t.Errorf("s.Bytes should start with %q but got %q", "Hello`B!!world!\x00", string(s.Bytes))
+	}
+	if s.Length != 15 {
+		t.Errorf("s.Length should be %d but got %d", 15, s.Length)
+	}
+}
+
 func TestWindowIncrementDecrementEmpty(t *testing.T) {
 	r := strings.NewReader("")
 	width, height := 16, 10
@@ -707,7 +1060,7 @@ func TestWindowInsertHead(t *testing.T) {
 	window, _ := newWindow(r, "test", "test", make(chan struct{}))
 	window.setSize(width, height)
 
-	window.pageEnd()
+	window.pageEnd(0)
 	window.startInsertHead()
 	s, _ := window.state()
 	if s.Cursor != 16 {
@@ -888,6 +1241,70 @@ func TestWindowReplaceByte(t *testing.T) {
 	}
 }
 
+func TestWindowNibble(t *testing.T) {
+	r := strings.NewReader("\x12\x34\x56")
+	width, height := 16, 10
+	window, _ := newWindow(r, "test", "test", make(chan struct{}))
+	window.setSize(width, height)
+	if err := window.options.Set("nibble"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, testCase := range []struct {
+		motion    func(int64)
+		count     int64
+		cursor    int64
+		lowNibble bool
+	}{
+		{window.nibbleRight, 0, 0, true},
+		{window.nibbleRight, 3, 2, false},
+		{window.nibbleRight, 5, 2, true},
+		{window.nibbleLeft, 2, 1, true},
+		{window.nibbleLeft, 1, 1, false},
+		{window.nibbleLeft, 5, 0, false},
+		{window.nibbleRight, 3, 1, true},
+	} {
+		testCase.motion(testCase.count)
+		s, _ := window.state()
+		if s.Cursor != testCase.cursor {
+			t.Errorf("s.Cursor should be %d but got %d", testCase.cursor, s.Cursor)
+		}
+		if s.LowNibble != testCase.lowNibble {
+			t.Errorf("s.LowNibble should be %v but got %v", testCase.lowNibble, s.LowNibble)
+		}
+	}
+
+	window.startReplaceByte()
+	window.insertByte(mode.Replace, 0x0f)
+	s, _ := window.state()
+	if expected := "\x12\x3f\x56"; !strings.HasPrefix(string(s.Bytes), expected) {
+		t.Errorf("s.Bytes should start with %q but got %q", expected, string(s.Bytes))
+	}
+	if !s.LowNibble {
+		t.Errorf("s.LowNibble should be %v but got %v", true, s.LowNibble)
+	}
+
+	window.nibbleLeft(1)
+	window.startReplaceByte()
+	window.insertByte(mode.Replace, 0x0e)
+	s, _ = window.state()
+	if expected := "\x12\xef\x56"; !strings.HasPrefix(string(s.Bytes), expected) {
+		t.Errorf("s.Bytes should start with %q but got %q", expected, string(s.Bytes))
+	}
+	if s.Cursor != 1 {
+		t.Errorf("s.Cursor should be %d but got %d", 1, s.Cursor)
+	}
+
+	if err := window.options.Set("nonibble"); err != nil {
+		t.Fatal(err)
+	}
+	window.nibbleRight(1)
+	s, _ = window.state()
+	if s.Nibble || s.LowNibble {
+		t.Errorf("s.Nibble and s.LowNibble should be false but got %v and %v", s.Nibble, s.LowNibble)
+	}
+}
+
 func TestWindowReplaceByteEmpty(t *testing.T) {
 	r := strings.NewReader("")
 	width, height := 16, 10
@@ -990,6 +1407,93 @@ func TestWindowReplaceEmpty(t *testing.T) {
 	}
 }
 
+func TestWindowUndoPartial(t *testing.T) {
+	r := strings.NewReader("Hello, world!")
+	width, height := 16, 10
+	window, _ := newWindow(r, "test", "test", make(chan struct{}))
+	window.setSize(width, height)
+
+	window.cursorNext(mode.Normal, 10)
+	window.startReplace()
+	for _, b := range []byte(":;<=>") {
+		window.insertByte(mode.Replace, b>>4)
+		window.insertByte(mode.Replace, b&0x0f)
+	}
+	window.exitInsert()
+
+	for _, testCase := range []struct {
+		count    int64
+		expected string
+		cursor   int64
+	}{
+		{1, "Hello, wor:;<=\x00", 13},
+		{2, "Hello, wor:;!\x00", 12},
+		{10, "Hello, world!\x00", 10},
+		{1, "Hello, world!\x00", 10},
+	} {
+		window.undoPartial(testCase.count)
+		s, _ := window.state()
+		if !strings.HasPrefix(string(s.Bytes), testCase.expected) {
+			t.Errorf("s.Bytes should start with %q but got %q", testCase.expected, string(s.Bytes))
+		}
+		if expected := int64(strings.IndexByte(testCase.expected, 0)); s.Length != expected {
+			t.Errorf("s.Length should be %d but got %d", expected, s.Length)
+		}
+		if s.Cursor != testCase.cursor {
+			t.Errorf("s.Cursor should be %d but got %d", testCase.cursor, s.Cursor)
+		}
+	}
+	if window.canRestoreOvertype() {
+		t.Errorf("overwritten bytes should be restored")
+	}
+
+	window.startReplace()
+	window.insertByte(mode.Replace, 0x03)
+	window.insertByte(mode.Replace, 0x0a)
+	window.exitInsert()
+	if !window.canRestoreOvertype() {
+		t.Errorf("overwritten bytes should be restorable")
+	}
+	window.deleteByte(1)
+	if window.canRestoreOvertype() {
+		t.Errorf("overwritten bytes should not be restorable after other changes")
+	}
+}
+
+func TestWindowInsertLiteral(t *testing.T) {
+	r := strings.NewReader("Hello")
+	width, height := 16, 10
+	window, _ := newWindow(r, "test", "test", make(chan struct{}))
+	window.setSize(width, height)
+
+	window.startInsert()
+	for _, str := range []string{"065", "x42", "o103", "u3042", "U0001f600", "7ab", "z"} {
+		window.startLiteral(mode.Insert)
+		for _, ch := range str {
+			window.insertRune(mode.Insert, ch)
+		}
+	}
+	window.startLiteral(mode.Insert)
+	window.insertRune(mode.Insert, 'x')
+	window.insertRune(mode.Insert, '4')
+	s, _ := window.state()
+	if s.Literal != "^Vx4" {
+		t.Errorf("s.Literal should be %q but got %q", "^Vx4", s.Literal)
+	}
+	window.backspace(mode.Insert)
+	s, _ = window.state()
+	expected := "ABC\xe3\x81\x82\xf0\x9f\x98\x80\x07\xabzHello\x00"
+	if !strings.HasPrefix(string(s.Bytes), expected) {
+		t.Errorf("s.Bytes should start with %q but got %q", expected, string(s.Bytes))
+	}
+	if s.Literal != "" {
+		t.Errorf("s.Literal should be empty but got %q", s.Literal)
+	}
+	if s.Cursor != 13 {
+		t.Errorf("s.Cursor should be %d but got %d", 13, s.Cursor)
+	}
+}
+
 func TestWindowInsertByte2(t *testing.T) {
 	r := strings.NewReader("")
 	width, height := 16, 10
@@ -1028,22 +1532,59 @@ func TestWindowBackspace(t *testing.T) {
 
 	window.cursorNext(mode.Normal, 5)
 	window.startInsert()
-	window.backspace()
+	window.backspace(mode.Insert)
 	s, _ := window.state()
 	if !strings.HasPrefix(string(s.Bytes), "Hell, world!\x00") {
 		t.Errorf("s.Bytes should start with %q but got %q", "Hell, world!\x00", string(s.Bytes))
 	}
-	window.backspace()
-	window.backspace()
-	window.backspace()
-	window.backspace()
-	window.backspace()
+	window.backspace(mode.Insert)
+	window.backspace(mode.Insert)
+	window.backspace(mode.Insert)
+	window.backspace(mode.Insert)
+	window.backspace(mode.Insert)
 	s, _ = window.state()
 	if !strings.HasPrefix(string(s.Bytes), ", world!\x00") {
 		t.Errorf("s.Bytes should start with %q but got %q", ", world!\x00", string(s.Bytes))
 	}
 }
 
+func TestWindowBackspaceReplace(t *testing.T) {
+	r := strings.NewReader("Hello, world!")
+	width, height := 16, 10
+	window, _ := newWindow(r, "test", "test", make(chan struct{}))
+	window.setSize(width, height)
+
+	window.cursorNext(mode.Normal, 11)
+	window.startReplace()
+	for _, b := range []byte(":;<") {
+		window.insertByte(mode.Replace, b>>4)
+		window.insertByte(mode.Replace, b&0x0f)
+	}
+	for _, testCase := range []struct {
+		expected string
+		cursor   int64
+	}{
+		{"Hello, worl:;\x00", 13},
+		{"Hello, worl:!\x00", 12},
+		{"Hello, world!\x00", 11},
+		{"Hello, world!\x00", 10},
+	} {
+		window.backspace(mode.Replace)
+		s, _ := window.state()
+		if !strings.HasPrefix(string(s.Bytes), testCase.expected) {
+			t.Errorf("s.Bytes should start with %q but got %q", testCase.expected, string(s.Bytes))
+		}
+		if s.Cursor != testCase.cursor {
+			t.Errorf("s.Cursor should be %d but got %d", testCase.cursor, s.Cursor)
+		}
+	}
+	window.exitInsert()
+	s, _ := window.state()
+	if s.Length != 13 {
+		t.Errorf("s.Length should be %d but got %d", 13, s.Length)
+	}
+}
+
 func TestWindowBackspacePending(t *testing.T) {
 	r := strings.NewReader("Hello, world!")
 	width, height := 16, 10
@@ -1061,7 +1602,7 @@ func TestWindowBackspacePending(t *testing.T) {
 		t.Errorf("s.PendingByte should be %q but got %q", '\x30', s.PendingByte)
 	}
 
-	window.backspace()
+	window.backspace(mode.Insert)
 	s, _ = window.state()
 	if !strings.HasPrefix(string(s.Bytes), "Hello, world!\x00") {
 		t.Errorf("s.Bytes should start with %q but got %q", "Hello, world!\x00", string(s.Bytes))
@@ -1242,6 +1783,296 @@ func TestWindowEventUndoRedo(t *testing.T) {
 	}
 }
 
+func TestWindowRepeatedEdits(t *testing.T) {
+	width, height := 16, 10
+	redrawCh := make(chan struct{})
+	window, _ := newWindow(strings.NewReader("Hello, world!"), "test", "test", redrawCh)
+	window.setSize(width, height)
+	go window.run()
+	defer func() {
+		close(redrawCh)
+		window.close()
+	}()
+
+	emit := func(e event.Event) *state.WindowState {
+		window.eventCh <- e
+		<-redrawCh
+		s, _ := window.state()
+		return s
+	}
+
+	for i := 0; i < 5; i++ {
+		emit(event.Event{Type: event.DeleteByte, Mode: mode.Normal})
+	}
+	s := emit(event.Event{Type: event.Increment, Mode: mode.Normal})
+	s = emit(event.Event{Type: event.Increment, Mode: mode.Normal})
+	if !strings.HasPrefix(string(s.Bytes), ". world!\x00") {
+		t.Errorf("s.Bytes should start with %q but got %q", ". world!\x00", string(s.Bytes))
+	}
+	if changes, index := window.history.Changes(); len(changes) != 3 || index != 2 {
+		t.Errorf("repeated edits should be recorded as one change but got %d changes", len(changes))
+	}
+	s = emit(event.Event{Type: event.Undo, Mode: mode.Normal})
+	if !strings.HasPrefix(string(s.Bytes), ", world!\x00") {
+		t.Errorf("s.Bytes should start with %q but got %q", ", world!\x00", string(s.Bytes))
+	}
+	s = emit(event.Event{Type: event.Undo, Mode: mode.Normal})
+	if !strings.HasPrefix(string(s.Bytes), "Hello, world!\x00") {
+		t.Errorf("s.Bytes should start with %q but got %q", "Hello, world!\x00", string(s.Bytes))
+	}
+
+	time.Sleep(editRepeatInterval)
+	emit(event.Event{Type: event.DeleteByte, Mode: mode.Normal, Count: 3})
+	time.Sleep(editRepeatInterval)
+	s = emit(event.Event{Type: event.DeleteByte, Mode: mode.Normal})
+	if !strings.HasPrefix(string(s.Bytes), "o, world!\x00") {
+		t.Errorf("s.Bytes should start with %q but got %q", "o, world!\x00", string(s.Bytes))
+	}
+	if changes, index := window.history.Changes(); len(changes) != 3 || index != 2 {
+		t.Errorf("edits after the interval should be recorded separately but got %d changes", len(changes))
+	}
+}
+
+func TestWindowMarksAndJumps(t *testing.T) {
+	width, height := 16, 10
+	redrawCh := make(chan struct{})
+	window, _ := newWindow(strings.NewReader(strings.Repeat("Hello, world!", 100)), "test", "test", redrawCh)
+	window.setSize(width, height)
+	go window.run()
+	defer func() {
+		close(redrawCh)
+		window.close()
+	}()
+
+	emit := func(e event.Event) *state.WindowState {
+		window.eventCh <- e
+		<-redrawCh
+		s, _ := window.state()
+		return s
+	}
+
+	emit(event.Event{Type: event.CursorNext, Mode: mode.Normal, Count: 20})
+	emit(event.Event{Type: event.SetMark, Mode: mode.Normal, Rune: 'a'})
+	s := emit(event.Event{Type: event.PageEnd, Mode: mode.Normal})
+	if s.Cursor != 1296 {
+		t.Errorf("s.Cursor should be %d but got %d", 1296, s.Cursor)
+	}
+	s = emit(event.Event{Type: event.GotoMark, Mode: mode.Normal, Rune: 'a'})
+	if s.Cursor != 20 {
+		t.Errorf("s.Cursor should be %d but got %d", 20, s.Cursor)
+	}
+	s = emit(event.Event{Type: event.GotoMarkLine, Mode: mode.Normal, Rune: 'a'})
+	if s.Cursor != 16 {
+		t.Errorf("s.Cursor should be %d but got %d", 16, s.Cursor)
+	}
+	s = emit(event.Event{Type: event.GotoMark, Mode: mode.Normal, Rune: 'b'})
+	if s.Cursor != 16 {
+		t.Errorf("s.Cursor should be %d but got %d", 16, s.Cursor)
+	}
+
+	s = emit(event.Event{Type: event.JumpBack, Mode: mode.Normal})
+	if s.Cursor != 20 {
+		t.Errorf("s.Cursor should be %d but got %d", 20, s.Cursor)
+	}
+	s = emit(event.Event{Type: event.JumpBack, Mode: mode.Normal, Count: 2})
+	if s.Cursor != 20 {
+		t.Errorf("s.Cursor should be %d but got %d", 20, s.Cursor)
+	}
+	s = emit(event.Event{Type: event.JumpForward, Mode: mode.Normal})
+	if s.Cursor != 1296 {
+		t.Errorf("s.Cursor should be %d but got %d", 1296, s.Cursor)
+	}
+	s = emit(event.Event{Type: event.JumpForward, Mode: mode.Normal, Count: 5})
+	if s.Cursor != 16 {
+		t.Errorf("s.Cursor should be %d but got %d", 16, s.Cursor)
+	}
+	s = emit(event.Event{Type: event.JumpForward, Mode: mode.Normal})
+	if s.Cursor != 16 {
+		t.Errorf("s.Cursor should be %d but got %d", 16, s.Cursor)
+	}
+
+	s = emit(event.Event{Type: event.PageTop, Mode: mode.Normal})
+	if s.Cursor != 0 {
+		t.Errorf("s.Cursor should be %d but got %d", 0, s.Cursor)
+	}
+	s = emit(event.Event{Type: event.GotoMark, Mode: mode.Normal, Rune: '`'})
+	if s.Cursor != 16 {
+		t.Errorf("s.Cursor should be %d but got %d", 16, s.Cursor)
+	}
+	s = emit(event.Event{Type: event.GotoMark, Mode: mode.Normal, Rune: '`'})
+	if s.Cursor != 0 {
+		t.Errorf("s.Cursor should be %d but got %d", 0, s.Cursor)
+	}
+}
+
+func TestWindowSelection(t *testing.T) {
+	r := strings.NewReader("Hello, world!")
+	window, err := newWindow(r, "test", "test", make(chan struct{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	window.setSize(20, 10)
+	if s, _ := window.state(); s.Selection != nil {
+		t.Errorf("s.Selection should be nil but got %+v", s.Selection)
+	}
+	window.cursorNext(mode.Normal, 3)
+	window.startVisual(false)
+	for _, testCase := range []struct {
+		motion   func()
+		from, to int64
+		expected string
+	}{
+		{func() {}, 3, 3, "l"},
+		{func() { window.cursorNext(mode.Normal, 4) }, 3, 7, "lo, w"},
+		{func() { window.cursorNext(mode.Normal, 2) }, 3, 9, "lo, wor"},
+		{func() { window.replace(4, 'O') }, 3, 9, "lO, wor"},
+		{func() { window.cursorPrev(8) }, 1, 3, "ell"},
+	} {
+		testCase.motion()
+		s, err := window.state()
+		if err != nil {
+			t.Fatal(err)
+		}
+		v := s.Selection
+		if v == nil || v.From != testCase.from || v.To != testCase.to || !v.Checksummed {
+			t.Fatalf("s.Selection should be from %d to %d but got %+v", testCase.from, testCase.to, v)
+		}
+		var sum uint32
+		for _, b := range []byte(testCase.expected) {
+			sum += uint32(b)
+		}
+		if v.Sum != sum {
+			t.Errorf("s.Selection.Sum should be %d but got %d", sum, v.Sum)
+		}
+		if crc := crc32.ChecksumIEEE([]byte(testCase.expected)); v.CRC32 != crc {
+			t.Errorf("s.Selection.CRC32 should be %08x but got %08x", crc, v.CRC32)
+		}
+	}
+}
+
+func TestWindowStringMotions(t *testing.T) {
+	r := strings.NewReader("\x00ab\x00Hello\x00\x01world!\x00xyz\x00\x00PNG image")
+	window, err := newWindow(r, "test", "test", make(chan struct{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	window.setSize(16, 10)
+	for _, testCase := range []struct {
+		motion   func()
+		expected int64
+	}{
+		{func() { window.nextString(0) }, 4},
+		{func() { window.nextString(1) }, 11},
+		{func() { window.nextString(1) }, 23},
+		{func() { window.nextString(1) }, 23},
+		{func() { window.cursorPrev(21) }, 2},
+		{func() { window.nextString(2) }, 11},
+		{func() { window.cursorNext(mode.Normal, 16) }, 27},
+		{func() { window.previousString(1) }, 23},
+		{func() { window.previousString(1) }, 11},
+		{func() { window.previousString(3) }, 4},
+		{func() { window.previousString(1) }, 4},
+		{func() { window.options.Set("stringlength=2") }, 4},
+		{func() { window.previousString(1) }, 1},
+		{func() { window.nextString(3) }, 18},
+	} {
+		testCase.motion()
+		if window.cursor != testCase.expected {
+			t.Errorf("window.cursor should be %d but got %d", testCase.expected, window.cursor)
+		}
+	}
+}
+
+func TestWindowDataMotions(t *testing.T) {
+	r := strings.NewReader("AB" + strings.Repeat("\x00", 20) + "CD\x00\x00\x00\x00EF" + strings.Repeat("\xff", 18) + "GH")
+	window, err := newWindow(r, "test", "test", make(chan struct{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	window.setSize(16, 10)
+	for _, testCase := range []struct {
+		motion   func()
+		expected int64
+	}{
+		{func() { window.nextData(0) }, 22},
+		{func() { window.nextData(1) }, 48},
+		{func() { window.nextData(1) }, 48},
+		{func() { window.previousData(1) }, 22},
+		{func() { window.previousData(1) }, 0},
+		{func() { window.previousData(1) }, 0},
+		{func() { window.nextData(2) }, 48},
+		{func() { window.cursorPrev(38) }, 10},
+		{func() { window.nextData(1) }, 22},
+		{func() { window.cursorNext(mode.Normal, 27) }, 49},
+		{func() { window.previousData(2) }, 22},
+		{func() { window.cursorNext(mode.Normal, 7) }, 29},
+		{func() { window.previousData(1) }, 22},
+	} {
+		testCase.motion()
+		if window.cursor != testCase.expected {
+			t.Errorf("window.cursor should be %d but got %d", testCase.expected, window.cursor)
+		}
+	}
+}
+
+func TestWindowJumpToPointer(t *testing.T) {
+	width, height := 16, 10
+	redrawCh := make(chan struct{})
+	r := strings.NewReader("\x20\x00\x00\x00\x00\x30\xff\xff" + strings.Repeat("\x00", 24) +
+		"\x04\x00\x00\x00\x00\x00\x00\x00" + strings.Repeat("\x00", 24))
+	window, _ := newWindow(r, "test", "test", redrawCh)
+	window.setSize(width, height)
+	go window.run()
+	defer func() {
+		close(redrawCh)
+		window.close()
+	}()
+
+	emit := func(e event.Event) *state.WindowState {
+		window.eventCh <- e
+		<-redrawCh
+		s, _ := window.state()
+		return s
+	}
+
+	for _, testCase := range []struct {
+		event    event.Event
+		expected int64
+	}{
+		{event.Event{Type: event.JumpToPointer, Mode: mode.Normal, Rune: 'f'}, 0x20},
+		{event.Event{Type: event.JumpToPointer, Mode: mode.Normal, Rune: 'f', Count: 8}, 0x04},
+		{event.Event{Type: event.JumpToPointer, Mode: mode.Normal, Rune: 'F', Count: 2}, 0x30},
+		{event.Event{Type: event.JumpBack, Mode: mode.Normal}, 0x04},
+		{event.Event{Type: event.JumpBack, Mode: mode.Normal}, 0x20},
+		{event.Event{Type: event.JumpBack, Mode: mode.Normal}, 0x00},
+		{event.Event{Type: event.CursorNext, Mode: mode.Normal, Count: 6}, 0x06},
+		{event.Event{Type: event.JumpToPointer, Mode: mode.Normal, Rune: 'f'}, 0x06},
+		{event.Event{Type: event.JumpToPointer, Mode: mode.Normal, Rune: 'f', Count: 3}, 0x06},
+	} {
+		if s := emit(testCase.event); s.Cursor != testCase.expected {
+			t.Errorf("s.Cursor should be %d but got %d", testCase.expected, s.Cursor)
+		}
+	}
+}
+
+func TestWindowJumpToPointerBaseAddress(t *testing.T) {
+	r := strings.NewReader("\x30\x00\x00\x00\x08\x00\x00\x00" + strings.Repeat("\x00", 56))
+	window, _ := newWindow(r, "test", "test", make(chan struct{}))
+	window.setSize(16, 10)
+	if err := window.options.Set("baseaddress=0x10"); err != nil {
+		t.Fatal(err)
+	}
+	window.jumpToPointer(4, false)
+	if expected := int64(0x20); window.cursor != expected {
+		t.Errorf("cursor should be %d but got %d", expected, window.cursor)
+	}
+	window.cursor = 4
+	window.jumpToPointer(4, false)
+	if expected := int64(4); window.cursor != expected {
+		t.Errorf("cursor should be %d but got %d", expected, window.cursor)
+	}
+}
+
 func TestWindowWriteTo(t *testing.T) {
 	r := strings.NewReader("Hello, world!")
 	window, err := newWindow(r, "test", "test", make(chan struct{}))
@@ -1250,7 +2081,7 @@ func TestWindowWriteTo(t *testing.T) {
 	}
 	window.setSize(20, 10)
 	window.cursorNext(mode.Normal, 3)
-	window.startVisual()
+	window.startVisual(false)
 	window.cursorNext(mode.Normal, 7)
 	for _, testCase := range []struct {
 		r        *event.Range
@@ -1272,3 +2103,157 @@ func TestWindowWriteTo(t *testing.T) {
 		}
 	}
 }
+
+type writeAtRecorder struct {
+	writes []string
+}
+
+func (w *writeAtRecorder) WriteAt(p []byte, offset int64) (int, error) {
+	w.writes = append(w.writes, fmt.Sprintf("%d:%s", offset, p))
+	return len(p), nil
+}
+
+func TestWindowWriteChangedTo(t *testing.T) {
+	r := strings.NewReader("Hello, world!")
+	window, err := newWindow(r, "test", "test", make(chan struct{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	window.setSize(20, 10)
+	window.replace(0, 'h')
+	window.replace(7, 'W')
+	window.replace(8, 'O')
+	w := new(writeAtRecorder)
+	n, err := window.writeChangedTo(w, 13)
+	if err != nil {
+		t.Errorf("err should be nil but got: %v", err)
+	}
+	if n != 3 {
+		t.Errorf("writeChangedTo should return %d but got: %d", 3, n)
+	}
+	if expected := []string{"0:h", "7:WO"}; !reflect.DeepEqual(w.writes, expected) {
+		t.Errorf("writeChangedTo should write %v but got: %v", expected, w.writes)
+	}
+	window.insertBytes(1, []byte{'x'})
+	if _, err := window.writeChangedTo(w, 13); err == nil {
+		t.Errorf("writeChangedTo should return an error when the size changed")
+	}
+}
+
+func TestWindowEditedNibbles(t *testing.T) {
+	width, height := 16, 10
+	window, _ := newWindow(strings.NewReader("Hello, world!"), "test", "test", make(chan struct{}))
+	window.setSize(width, height)
+
+	window.replace(0, 0x4f)
+	window.replace(1, 0x95)
+	window.replace(2, 0x00)
+	s, _ := window.state()
+	if s.EditedNibbles != nil || s.UnsavedNibbles != nil {
+		t.Errorf("nibbles should be nil but got %v, %v", s.EditedNibbles, s.UnsavedNibbles)
+	}
+	if err := window.options.Set("nibble"); err != nil {
+		t.Fatalf("err should be nil but got: %v", err)
+	}
+	s, _ = window.state()
+	if expected := []int64{1, 2}; !reflect.DeepEqual(s.EditedNibbles, expected) {
+		t.Errorf("s.EditedNibbles should be %v but got %v", expected, s.EditedNibbles)
+	}
+	if expected := []int64{1, 2}; !reflect.DeepEqual(s.UnsavedNibbles, expected) {
+		t.Errorf("s.UnsavedNibbles should be %v but got %v", expected, s.UnsavedNibbles)
+	}
+
+	window.markSaved()
+	window.replace(0, 0x48)
+	s, _ = window.state()
+	if expected := []int64{2}; !reflect.DeepEqual(s.EditedNibbles, expected) {
+		t.Errorf("s.EditedNibbles should be %v but got %v", expected, s.EditedNibbles)
+	}
+	if expected := []int64{1}; !reflect.DeepEqual(s.UnsavedNibbles, expected) {
+		t.Errorf("s.UnsavedNibbles should be %v but got %v", expected, s.UnsavedNibbles)
+	}
+}
+
+func TestWindowChanges(t *testing.T) {
+	width, height := 16, 10
+	redrawCh := make(chan struct{})
+	window, _ := newWindow(strings.NewReader(strings.Repeat("Hello, world!", 100)), "test", "test", redrawCh)
+	window.setSize(width, height)
+	go window.run()
+	defer func() {
+		close(redrawCh)
+		window.close()
+	}()
+
+	emit := func(e event.Event) *state.WindowState {
+		window.eventCh <- e
+		<-redrawCh
+		s, _ := window.state()
+		return s
+	}
+
+	emit(event.Event{Type: event.CursorNext, Mode: mode.Normal, Count: 3})
+	emit(event.Event{Type: event.Increment, Mode: mode.Normal})
+	emit(event.Event{Type: event.CursorNext, Mode: mode.Normal, Count: 20})
+	s := emit(event.Event{Type: event.DeleteByte, Mode: mode.Normal})
+	if expected := []int64{3, 4}; !reflect.DeepEqual(s.EditedIndices, expected) {
+		t.Errorf("s.EditedIndices should be %v but got %v", expected, s.EditedIndices)
+	}
+	emit(event.Event{Type: event.PageTop, Mode: mode.Normal})
+	emit(event.Event{Type: event.StartReplaceByte, Mode: mode.Normal})
+	emit(event.Event{Type: event.Rune, Mode: mode.Replace, Rune: '4'})
+	emit(event.Event{Type: event.Rune, Mode: mode.Replace, Rune: '1'})
+	s = emit(event.Event{Type: event.ExitInsert, Mode: mode.Replace})
+	if expected := []int64{0, 1, 3, 4}; !reflect.DeepEqual(s.EditedIndices, expected) {
+		t.Errorf("s.EditedIndices should be %v but got %v", expected, s.EditedIndices)
+	}
+	if expected := []int64{0, 1, 3, 4}; !reflect.DeepEqual(s.UnsavedIndices, expected) {
+		t.Errorf("s.UnsavedIndices should be %v but got %v", expected, s.UnsavedIndices)
+	}
+
+	s = emit(event.Event{Type: event.NextChange, Mode: mode.Normal})
+	if s.Cursor != 3 {
+		t.Errorf("s.Cursor should be %d but got %d", 3, s.Cursor)
+	}
+	s = emit(event.Event{Type: event.NextChange, Mode: mode.Normal})
+	if s.Cursor != 3 {
+		t.Errorf("s.Cursor should be %d but got %d", 3, s.Cursor)
+	}
+	s = emit(event.Event{Type: event.PreviousChange, Mode: mode.Normal})
+	if s.Cursor != 0 {
+		t.Errorf("s.Cursor should be %d but got %d", 0, s.Cursor)
+	}
+
+	window.markSaved()
+	s, _ = window.state()
+	if s.UnsavedIndices != nil {
+		t.Errorf("s.UnsavedIndices should be nil but got %v", s.UnsavedIndices)
+	}
+	emit(event.Event{Type: event.CursorNext, Mode: mode.Normal, Count: 3})
+	s = emit(event.Event{Type: event.Decrement, Mode: mode.Normal})
+	if expected := []int64{3, 4}; !reflect.DeepEqual(s.UnsavedIndices, expected) {
+		t.Errorf("s.UnsavedIndices should be %v but got %v", expected, s.UnsavedIndices)
+	}
+	if expected := []int64{0, 1}; !reflect.DeepEqual(s.EditedIndices, expected) {
+		t.Errorf("s.EditedIndices should be %v but got %v", expected, s.EditedIndices)
+	}
+}
+
+func BenchmarkWindowState(b *testing.B) {
+	r := strings.NewReader(strings.Repeat("0123456789abcdef", 1<<16))
+	window, err := newWindow(r, "test", "test", make(chan struct{}))
+	if err != nil {
+		b.Fatal(err)
+	}
+	window.setSize(300, 80)
+	for i := int64(0); i < 1<<16; i++ {
+		window.replace(i*16, 'x')
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		window.offset = int64(i) * 96 % (1 << 19)
+		if _, err := window.state(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
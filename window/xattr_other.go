@@ -0,0 +1,7 @@
+// +build !linux,!darwin,!freebsd,!netbsd
+
+package window
+
+func copyXattrs(string, string) error {
+	return nil
+}
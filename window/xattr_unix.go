@@ -0,0 +1,49 @@
+// +build linux darwin freebsd netbsd
+
+package window
+
+import (
+	"bytes"
+
+	"golang.org/x/sys/unix"
+)
+
+// copyXattrs copies the extended attributes of the original file. The error
+// is ignored when the file system does not support them.
+func copyXattrs(dst, src string) error {
+	n, err := unix.Listxattr(src, nil)
+	if err != nil || n == 0 {
+		return ignoreXattrError(err)
+	}
+	buf := make([]byte, n)
+	if n, err = unix.Listxattr(src, buf); err != nil {
+		return ignoreXattrError(err)
+	}
+	for _, attr := range bytes.Split(buf[:n], []byte{0}) {
+		if len(attr) == 0 {
+			continue
+		}
+		n, err := unix.Getxattr(src, string(attr), nil)
+		if err != nil {
+			return ignoreXattrError(err)
+		}
+		data := make([]byte, n)
+		if n, err = unix.Getxattr(src, string(attr), data); err != nil {
+			return ignoreXattrError(err)
+		}
+		if err := unix.Setxattr(dst, string(attr), data[:n], 0); err != nil {
+			if err = ignoreXattrError(err); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func ignoreXattrError(err error) error {
+	switch err {
+	case unix.ENOTSUP, unix.EPERM, unix.EACCES:
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,259 @@
+package window
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/itchyny/bed/event"
+	"github.com/itchyny/bed/mathutil"
+)
+
+const (
+	xorMaxKeyLength = 4                // the maximum length of the keys
+	xorMaxSize      = 16 * 1024 * 1024 // the maximum bytes of the range
+	xorMinRatio     = 0.75             // the minimum printable ratio of the candidates
+	xorCandidates   = 10               // the number of the candidates by the printable ratio
+)
+
+// xorscan tries the XOR keys over the range, looking for the pattern or the
+// high ratio of the printable bytes, and lists the candidate keys.
+func (m *Manager) xorscan(e event.Event) error {
+	pattern, keyLength, err := parseXorscanArgs(e.Arg)
+	if err != nil {
+		return err
+	}
+	window := m.windows[m.windowIndex]
+	waitWindow(window)
+	window.mu.Lock()
+	items, err := window.xorscan(e.Range, pattern, keyLength)
+	window.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if len(items) == 0 {
+		return errors.New("no keys found")
+	}
+	m.setQuickfix(window, items)
+	return m.listQuickfix(event.Event{})
+}
+
+// parseXorscanArgs parses the pattern (text, or hex digits with 0x prefix)
+// and the maximum key length (keylen=N).
+func parseXorscanArgs(arg string) ([]byte, int, error) {
+	keyLength := 1
+	if i := strings.LastIndex(arg, "keylen="); i >= 0 && (i == 0 || arg[i-1] == ' ') {
+		var err error
+		s := arg[i+len("keylen="):]
+		if keyLength, err = strconv.Atoi(s); err != nil || keyLength < 1 || keyLength > xorMaxKeyLength {
+			return nil, 0, fmt.Errorf("invalid key length: %s", s)
+		}
+		arg = strings.TrimSpace(arg[:i])
+	}
+	if arg == "" {
+		return nil, keyLength, nil
+	}
+	if strings.HasPrefix(arg, "0x") || strings.HasPrefix(arg, "0X") {
+		if bs, err := hex.DecodeString(arg[2:]); err == nil && len(bs) > 0 {
+			return bs, keyLength, nil
+		}
+	}
+	return []byte(arg), keyLength, nil
+}
+
+// xorscan finds the keys in the range (the whole buffer by default). The keys
+// are applied cyclically from the start of the range.
+func (w *window) xorscan(r *event.Range, pattern []byte, keyLength int) ([]quickfixItem, error) {
+	from, to := int64(0), w.length-1
+	if r != nil {
+		var err error
+		if from, err = w.positionToOffset(r.From); err != nil {
+			return nil, err
+		}
+		to = from
+		if r.To != nil {
+			if to, err = w.positionToOffset(r.To); err != nil {
+				return nil, err
+			}
+		}
+		if from > to {
+			from, to = to, from
+		}
+	}
+	if to = mathutil.MinInt64(to, w.length-1); from > to {
+		return nil, errors.New("no bytes to scan")
+	}
+	if to-from+1 > xorMaxSize {
+		return nil, fmt.Errorf("too large range to scan: %d bytes", to-from+1)
+	}
+	bs, err := w.readFull(from, to-from+1)
+	if err != nil {
+		return nil, err
+	}
+	if pattern == nil {
+		return xorPrintable(bs, from, keyLength), nil
+	}
+	if len(pattern) < keyLength {
+		return nil, fmt.Errorf("pattern is shorter than the key length: %d", keyLength)
+	}
+	return xorPattern(bs, from, pattern, keyLength), nil
+}
+
+// xorPattern finds the keys which decode the pattern. The key is derived from
+// the bytes at each offset, and verified by the rest of the pattern.
+func xorPattern(bs []byte, from int64, pattern []byte, maxLength int) []quickfixItem {
+	type candidate struct {
+		offset int64
+		count  int
+	}
+	candidates := make(map[string]*candidate)
+	var keys []string
+	for length := 1; length <= maxLength; length++ {
+		key := make([]byte, length)
+	loop:
+		for i := 0; i+len(pattern) <= len(bs); i++ {
+			for k := 0; k < length; k++ {
+				key[(i+k)%length] = bs[i+k] ^ pattern[k]
+			}
+			// the zero key leaves the bytes as they are
+			if xorKeyPeriod(key) < length || length == 1 && key[0] == 0 {
+				continue
+			}
+			for k := length; k < len(pattern); k++ {
+				if bs[i+k]^key[(i+k)%length] != pattern[k] {
+					continue loop
+				}
+			}
+			if c, ok := candidates[string(key)]; ok {
+				c.count++
+			} else if len(keys) < maxQuickfixItems {
+				candidates[string(key)] = &candidate{from + int64(i), 1}
+				keys = append(keys, string(key))
+			}
+		}
+	}
+	sort.SliceStable(keys, func(i, j int) bool {
+		return candidates[keys[i]].offset < candidates[keys[j]].offset
+	})
+	items := make([]quickfixItem, len(keys))
+	for i, key := range keys {
+		c, matches := candidates[key], "matches"
+		if c.count == 1 {
+			matches = "match"
+		}
+		items[i] = quickfixItem{c.offset, int64(len(pattern)),
+			fmt.Sprintf("key 0x%x: %d %s", key, c.count, matches)}
+	}
+	return items
+}
+
+// xorPrintable finds the keys which decode the bytes to the printable text,
+// ranked by the frequencies of the letters in the decoded bytes. The keys longer
+// than a byte are built from the best bytes of the columns.
+func xorPrintable(bs []byte, from int64, maxLength int) []quickfixItem {
+	type candidate struct {
+		key          []byte
+		ratio, score float64
+	}
+	var candidates []candidate
+	add := func(key []byte) {
+		ratio, score := xorScore(bs, key)
+		candidates = append(candidates, candidate{key, ratio, score})
+	}
+	for k := 1; k < 256; k++ {
+		add([]byte{byte(k)})
+	}
+	for length := 2; length <= maxLength && length < len(bs); length++ {
+		key := make([]byte, length)
+		for i := range key {
+			var best int
+			for k := 0; k < 256; k++ {
+				if score := xorColumnScore(bs, length, i, byte(k)); score > best {
+					key[i], best = byte(k), score
+				}
+			}
+		}
+		if xorKeyPeriod(key) == length {
+			add(key)
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].score > candidates[j].score
+	})
+	var items []quickfixItem
+	for _, c := range candidates {
+		if len(items) == xorCandidates {
+			break
+		}
+		if c.ratio < xorMinRatio {
+			continue
+		}
+		preview := make([]byte, mathutil.MinInt(len(bs), 16))
+		for i := range preview {
+			preview[i] = bs[i] ^ c.key[i%len(c.key)]
+		}
+		items = append(items, quickfixItem{from, int64(len(bs)),
+			fmt.Sprintf("key 0x%x: %.0f%% printable: %s", c.key, c.ratio*100, strconv.Quote(string(preview)))})
+	}
+	return items
+}
+
+// letterFrequencies is the frequencies of the letters in English text per
+// mille, which are used to rank the decoded bytes.
+var letterFrequencies = [26]int{
+	82, 15, 28, 43, 127, 22, 20, 61, 70, 2, 8, 40, 24,
+	67, 75, 19, 1, 60, 63, 91, 28, 10, 24, 2, 20, 1,
+}
+
+// textScore scores the byte as a character of English text.
+func textScore(b byte) int {
+	switch {
+	case b == ' ':
+		return 130
+	case 'a' <= b && b <= 'z':
+		return letterFrequencies[b-'a']
+	case 'A' <= b && b <= 'Z':
+		return (letterFrequencies[b-'A'] + 1) / 2
+	case isPrintable(b), b == '\n', b == '\r':
+		return 1
+	default:
+		return 0
+	}
+}
+
+// xorScore returns the ratio of the printable bytes decoded by the key, and
+// the average score of the decoded bytes.
+func xorScore(bs, key []byte) (float64, float64) {
+	var count, score int
+	for i, b := range bs {
+		if s := textScore(b ^ key[i%len(key)]); s > 0 {
+			count, score = count+1, score+s
+		}
+	}
+	return float64(count) / float64(len(bs)), float64(score) / float64(len(bs))
+}
+
+// xorColumnScore returns the score of the bytes decoded by the key byte in
+// the column of the key.
+func xorColumnScore(bs []byte, length, column int, k byte) int {
+	var score int
+	for i := column; i < len(bs); i += length {
+		score += textScore(bs[i] ^ k)
+	}
+	return score
+}
+
+// xorKeyPeriod returns the shortest period of the key. The key of which the
+// period is shorter than the length is equivalent to the shorter key.
+func xorKeyPeriod(key []byte) int {
+	for period := 1; period < len(key); period++ {
+		if len(key)%period == 0 && bytes.Equal(key[period:], key[:len(key)-period]) {
+			return period
+		}
+	}
+	return len(key)
+}
@@ -0,0 +1,91 @@
+package window
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/itchyny/bed/event"
+)
+
+func xorBytes(bs, key []byte) []byte {
+	xs := make([]byte, len(bs))
+	for i, b := range bs {
+		xs[i] = b ^ key[i%len(key)]
+	}
+	return xs
+}
+
+func TestWindowXorscan(t *testing.T) {
+	bs := xorBytes([]byte("The flag is flag{xor} in this text.\n"), []byte{0x5a})
+	window, err := newWindow(bytes.NewReader(bs), "test", "test", make(chan struct{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	items, err := window.xorscan(nil, []byte("flag"), 1)
+	if err != nil {
+		t.Fatalf("err should be nil but got: %v", err)
+	}
+	if expected := []quickfixItem{{4, 4, "key 0x5a: 2 matches"}}; !reflect.DeepEqual(items, expected) {
+		t.Errorf("items should be %+v but got %+v", expected, items)
+	}
+	items, err = window.xorscan(nil, nil, 1)
+	if err != nil {
+		t.Fatalf("err should be nil but got: %v", err)
+	}
+	if len(items) == 0 || items[0].text != `key 0x5a: 100% printable: "The flag is flag"` {
+		t.Errorf("items should start with the key 0x5a but got %+v", items)
+	}
+
+	bs = append([]byte("\xff\xff"), xorBytes([]byte("The quick brown fox jumps over the lazy dog, "+
+		"and the flag is flag{xor} in this text.\n"), []byte("k3y"))...)
+	window, err = newWindow(bytes.NewReader(bs), "test", "test", make(chan struct{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &event.Range{From: event.Absolute{Offset: 2}, To: event.End{}}
+	items, err = window.xorscan(r, []byte("flag{"), 4)
+	if err != nil {
+		t.Fatalf("err should be nil but got: %v", err)
+	}
+	if expected := []quickfixItem{{63, 5, "key 0x6b3379: 1 match"}}; !reflect.DeepEqual(items, expected) {
+		t.Errorf("items should be %+v but got %+v", expected, items)
+	}
+	items, err = window.xorscan(r, nil, 3)
+	if err != nil {
+		t.Fatalf("err should be nil but got: %v", err)
+	}
+	if len(items) == 0 || items[0].text != `key 0x6b3379: 100% printable: "The quick brown "` {
+		t.Errorf("items should start with the key 0x6b3379 but got %+v", items)
+	}
+
+	if _, err = window.xorscan(nil, []byte("fl"), 3); err == nil || err.Error() != "pattern is shorter than the key length: 3" {
+		t.Errorf("xorscan should fail for the short pattern but got: %v", err)
+	}
+}
+
+func TestParseXorscanArgs(t *testing.T) {
+	for _, testCase := range []struct {
+		arg       string
+		pattern   []byte
+		keyLength int
+		err       string
+	}{
+		{"", nil, 1, ""},
+		{"flag{", []byte("flag{"), 1, ""},
+		{"0xcafe keylen=2", []byte{0xca, 0xfe}, 2, ""},
+		{"keylen=4", nil, 4, ""},
+		{"the key keylen=3", []byte("the key"), 3, ""},
+		{"flag keylen=5", nil, 0, "invalid key length: 5"},
+	} {
+		pattern, keyLength, err := parseXorscanArgs(testCase.arg)
+		if testCase.err != "" {
+			if err == nil || err.Error() != testCase.err {
+				t.Errorf("parseXorscanArgs(%q) should fail with %q but got: %v", testCase.arg, testCase.err, err)
+			}
+		} else if err != nil || !bytes.Equal(pattern, testCase.pattern) || keyLength != testCase.keyLength {
+			t.Errorf("parseXorscanArgs(%q) should be %q, %d but got %q, %d (%v)",
+				testCase.arg, testCase.pattern, testCase.keyLength, pattern, keyLength, err)
+		}
+	}
+}